@@ -0,0 +1,470 @@
+// Package broker is a minimal in-process MQTT 3.1.1 broker, so development setups
+// run without an external broker and tests can exercise the MQTT path.
+// Sessions are always clean, QoS 2 publishes are accepted but delivered at QoS 1
+// and QoS 1 messages are not redelivered once a client dropped.
+//
+// It is meant for tests and development. Production sites use an external broker
+// keeping persistent sessions, a gateway reconnecting to this one loses the
+// commands published while it was away.
+package broker
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/google/uuid"
+)
+
+const (
+	// Connection not sending CONNECT within this is closed
+	CONNECT_TIMEOUT time.Duration = 10 * time.Second
+	// Write to a client not completing within this drops the client
+	WRITE_TIMEOUT time.Duration = 10 * time.Second
+	// Packets waiting to be written per client, a client falling further behind is dropped
+	CLIENT_QUEUE_SIZE int = 1000
+	// Subscriptions are granted at most this QoS
+	MAX_QOS byte = 1
+)
+
+var ErrBrokerClosed = errors.New("broker closed")
+
+type Config struct {
+	Address   string      // e.g. ":8883", port 0 picks a free port
+	TLSConfig *tls.Config // plain TCP when nil
+	Username  string      // clients must connect with these when set
+	Password  string
+}
+
+type Broker struct {
+	cfg      Config
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	clients  map[string]*client
+	retained map[string]*packets.PublishPacket
+}
+
+func New(cfg Config) *Broker {
+	return &Broker{
+		cfg:      cfg,
+		clients:  map[string]*client{},
+		retained: map[string]*packets.PublishPacket{},
+	}
+}
+
+// Listen on the configured address and accept clients in the background
+func (b *Broker) Start() error {
+	var l net.Listener
+	var err error
+	if b.cfg.TLSConfig != nil {
+		l, err = tls.Listen("tcp", b.cfg.Address, b.cfg.TLSConfig)
+	} else {
+		l, err = net.Listen("tcp", b.cfg.Address)
+	}
+	if err != nil {
+		return err
+	}
+	b.listener = l
+	b.wg.Add(1)
+	go b.accept()
+	logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-BROKER] Listening on %s", l.Addr())
+	return nil
+}
+
+// Address the broker listens on, once started
+func (b *Broker) Addr() net.Addr {
+	if b.listener == nil {
+		return nil
+	}
+	return b.listener.Addr()
+}
+
+// Number of connected clients
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+// Stop accepting clients and disconnect the connected ones without publishing their wills
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	b.closed = true
+	clients := b.clients
+	b.clients = map[string]*client{}
+	for _, c := range clients {
+		c.will = nil
+	}
+	b.mu.Unlock()
+
+	var err error
+	if b.listener != nil {
+		err = b.listener.Close()
+	}
+	for _, c := range clients {
+		c.close()
+	}
+	b.wg.Wait()
+	logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-BROKER] Closed")
+	return err
+}
+
+func (b *Broker) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if b.isClosed() {
+				return
+			}
+			logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-BROKER] Accept failed: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		b.wg.Add(1)
+		go b.serve(conn)
+	}
+}
+
+func (b *Broker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+func (b *Broker) serve(conn net.Conn) {
+	defer b.wg.Done()
+	conn.SetReadDeadline(time.Now().Add(CONNECT_TIMEOUT))
+	cp, err := packets.ReadPacket(conn)
+	connect, ok := cp.(*packets.ConnectPacket)
+	if err != nil || !ok {
+		conn.Close()
+		return
+	}
+	code := connect.Validate()
+	if code == packets.Accepted {
+		code = b.authenticate(connect)
+	}
+	if code != packets.Accepted {
+		if code != packets.ErrProtocolViolation {
+			connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			connack.ReturnCode = code
+			conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+			connack.Write(conn)
+		}
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-BROKER] Refused client %s from %s: %s",
+			connect.ClientIdentifier, conn.RemoteAddr(), packets.ConnackReturnCodes[code])
+		conn.Close()
+		return
+	}
+
+	c := newClient(connect, conn)
+	if !b.register(c) {
+		conn.Close()
+		return
+	}
+	connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	connack.ReturnCode = packets.Accepted
+	c.send(connack)
+	go c.writeLoop()
+
+	graceful := b.readLoop(c, time.Duration(connect.Keepalive)*time.Second)
+	b.unregister(c, graceful)
+}
+
+func (b *Broker) authenticate(connect *packets.ConnectPacket) byte {
+	if b.cfg.Username == "" {
+		return packets.Accepted
+	}
+	username := subtle.ConstantTimeCompare([]byte(connect.Username), []byte(b.cfg.Username))
+	password := subtle.ConstantTimeCompare(connect.Password, []byte(b.cfg.Password))
+	if username&password != 1 {
+		return packets.ErrRefusedBadUsernameOrPassword
+	}
+	return packets.Accepted
+}
+
+// A client connecting with the ID of a connected one takes its place, the old connection
+// is closed without publishing its will as the client is still there
+func (b *Broker) register(c *client) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	if old, ok := b.clients[c.id]; ok {
+		old.will = nil
+		old.close()
+		logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-BROKER] Client %s took over its previous connection", c.id)
+	}
+	b.clients[c.id] = c
+	return true
+}
+
+// Will of a client that did not send DISCONNECT is published
+func (b *Broker) unregister(c *client, graceful bool) {
+	b.mu.Lock()
+	if b.clients[c.id] == c {
+		delete(b.clients, c.id)
+	}
+	will := c.will
+	b.mu.Unlock()
+	c.close()
+	if !graceful && will != nil {
+		b.publish(will)
+	}
+}
+
+// Handle packets of c until it disconnects, returns whether it sent DISCONNECT
+func (b *Broker) readLoop(c *client, keepalive time.Duration) bool {
+	// Packet IDs of QoS 2 publishes received and not yet released
+	qos2 := map[uint16]bool{}
+	for {
+		if keepalive > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(keepalive * 3 / 2))
+		} else {
+			c.conn.SetReadDeadline(time.Time{})
+		}
+		cp, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return false
+		}
+		switch p := cp.(type) {
+		case *packets.PublishPacket:
+			if !validTopic(p.TopicName) {
+				return false
+			}
+			switch p.Qos {
+			case 0:
+				b.publish(p)
+			case 1:
+				b.publish(p)
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				c.send(puback)
+			case 2:
+				if !qos2[p.MessageID] {
+					qos2[p.MessageID] = true
+					b.publish(p)
+				}
+				pubrec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				pubrec.MessageID = p.MessageID
+				c.send(pubrec)
+			default:
+				return false
+			}
+		case *packets.PubrelPacket:
+			delete(qos2, p.MessageID)
+			pubcomp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			pubcomp.MessageID = p.MessageID
+			c.send(pubcomp)
+		case *packets.SubscribePacket:
+			b.subscribe(c, p)
+		case *packets.UnsubscribePacket:
+			b.mu.Lock()
+			for _, filter := range p.Topics {
+				delete(c.subs, filter)
+			}
+			b.mu.Unlock()
+			unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			unsuback.MessageID = p.MessageID
+			c.send(unsuback)
+		case *packets.PingreqPacket:
+			c.send(packets.NewControlPacket(packets.Pingresp))
+		case *packets.PubackPacket, *packets.PubrecPacket, *packets.PubcompPacket:
+			// Messages sent to clients are not redelivered, nothing to release
+		case *packets.DisconnectPacket:
+			return true
+		default:
+			// CONNECT again or a packet only a server sends
+			return false
+		}
+	}
+}
+
+func (b *Broker) subscribe(c *client, p *packets.SubscribePacket) {
+	suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+	suback.MessageID = p.MessageID
+	type retainedMsg struct {
+		msg *packets.PublishPacket
+		qos byte
+	}
+	retained := []retainedMsg{}
+	b.mu.Lock()
+	for i, filter := range p.Topics {
+		if !validFilter(filter) {
+			suback.ReturnCodes = append(suback.ReturnCodes, 0x80)
+			continue
+		}
+		qos := p.Qoss[i]
+		if qos > MAX_QOS {
+			qos = MAX_QOS
+		}
+		c.subs[filter] = qos
+		suback.ReturnCodes = append(suback.ReturnCodes, qos)
+		for topic, msg := range b.retained {
+			if matchTopic(filter, topic) {
+				retained = append(retained, retainedMsg{msg, minQos(msg.Qos, qos)})
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	c.send(suback)
+	for _, r := range retained {
+		c.deliver(r.msg, r.qos, true)
+	}
+}
+
+// Route msg to every client subscribed to its topic and keep it when retained,
+// a retained message with an empty payload deletes the retained one
+func (b *Broker) publish(msg *packets.PublishPacket) {
+	b.mu.Lock()
+	if msg.Retain {
+		if len(msg.Payload) == 0 {
+			delete(b.retained, msg.TopicName)
+		} else {
+			b.retained[msg.TopicName] = msg
+		}
+	}
+	type recipient struct {
+		c   *client
+		qos byte
+	}
+	recipients := []recipient{}
+	for _, c := range b.clients {
+		if qos, ok := c.grantedQos(msg.TopicName); ok {
+			recipients = append(recipients, recipient{c, qos})
+		}
+	}
+	b.mu.Unlock()
+
+	for _, r := range recipients {
+		r.c.deliver(msg, minQos(msg.Qos, r.qos), false)
+	}
+}
+
+func minQos(a byte, b byte) byte {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type client struct {
+	id   string
+	conn net.Conn
+	will *packets.PublishPacket // guarded by Broker.mu once registered
+	subs map[string]byte        // filter to granted QoS, guarded by Broker.mu
+
+	out       chan packets.ControlPacket
+	done      chan struct{}
+	closeOnce sync.Once
+
+	idMu   sync.Mutex
+	nextID uint16
+}
+
+func newClient(connect *packets.ConnectPacket, conn net.Conn) *client {
+	c := &client{
+		id:   connect.ClientIdentifier,
+		conn: conn,
+		subs: map[string]byte{},
+		out:  make(chan packets.ControlPacket, CLIENT_QUEUE_SIZE),
+		done: make(chan struct{}),
+	}
+	if c.id == "" {
+		c.id = "auto-" + uuid.NewString()
+	}
+	if connect.WillFlag {
+		c.will = &packets.PublishPacket{
+			FixedHeader: packets.FixedHeader{Qos: minQos(connect.WillQos, MAX_QOS), Retain: connect.WillRetain},
+			TopicName:   connect.WillTopic,
+			Payload:     connect.WillMessage,
+		}
+	}
+	return c
+}
+
+// Highest QoS of the subscriptions matching topic
+func (c *client) grantedQos(topic string) (byte, bool) {
+	var granted byte
+	found := false
+	for filter, qos := range c.subs {
+		if matchTopic(filter, topic) {
+			found = true
+			if qos > granted {
+				granted = qos
+			}
+		}
+	}
+	return granted, found
+}
+
+// Send a copy of msg, packets are not shared between clients as writing them sets their length
+func (c *client) deliver(msg *packets.PublishPacket, qos byte, retain bool) {
+	p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	p.TopicName = msg.TopicName
+	p.Payload = msg.Payload
+	p.Qos = qos
+	p.Retain = retain
+	if p.Qos > 0 {
+		p.MessageID = c.messageID()
+	}
+	c.send(p)
+}
+
+func (c *client) messageID() uint16 {
+	c.idMu.Lock()
+	defer c.idMu.Unlock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+// Queue p to be written, a client whose queue is full is too slow and dropped
+func (c *client) send(p packets.ControlPacket) {
+	select {
+	case <-c.done:
+	case c.out <- p:
+	default:
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-BROKER] Client %s too slow, disconnecting", c.id)
+		c.close()
+	}
+}
+
+func (c *client) writeLoop() {
+	for {
+		select {
+		case p := <-c.out:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+			if err := p.Write(c.conn); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,239 @@
+//go:build unit
+// +build unit
+
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+const testTimeout = 2 * time.Second
+
+func startBroker(t *testing.T, cfg Config) *Broker {
+	t.Helper()
+	cfg.Address = "127.0.0.1:0"
+	b := New(cfg)
+	if err := b.Start(); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func clientOptions(b *Broker, id string) *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker("tcp://" + b.Addr().String())
+	opts.SetClientID(id)
+	opts.SetAutoReconnect(false)
+	return opts
+}
+
+func connect(t *testing.T, opts *mqtt.ClientOptions) mqtt.Client {
+	t.Helper()
+	c := mqtt.NewClient(opts)
+	if tk := c.Connect(); !tk.WaitTimeout(testTimeout) || tk.Error() != nil {
+		t.Fatalf("connect failed: %v", tk.Error())
+	}
+	t.Cleanup(func() { c.Disconnect(0) })
+	return c
+}
+
+// Subscribe to filter, messages received are sent on the returned channel
+func subscribe(t *testing.T, c mqtt.Client, filter string) <-chan mqtt.Message {
+	t.Helper()
+	received := make(chan mqtt.Message, 10)
+	tk := c.Subscribe(filter, 1, func(_ mqtt.Client, msg mqtt.Message) { received <- msg })
+	if !tk.WaitTimeout(testTimeout) || tk.Error() != nil {
+		t.Fatalf("subscribe failed: %v", tk.Error())
+	}
+	return received
+}
+
+func publish(t *testing.T, c mqtt.Client, topic string, qos byte, retained bool, payload string) {
+	t.Helper()
+	if tk := c.Publish(topic, qos, retained, payload); !tk.WaitTimeout(testTimeout) || tk.Error() != nil {
+		t.Fatalf("publish failed: %v", tk.Error())
+	}
+}
+
+func expectMessage(t *testing.T, received <-chan mqtt.Message, topic string, payload string) mqtt.Message {
+	t.Helper()
+	select {
+	case msg := <-received:
+		if msg.Topic() != topic || string(msg.Payload()) != payload {
+			t.Errorf("got %s %s, wanted %s %s", msg.Topic(), msg.Payload(), topic, payload)
+		}
+		return msg
+	case <-time.After(testTimeout):
+		t.Fatalf("no message on %s", topic)
+	}
+	return nil
+}
+
+func expectNoMessage(t *testing.T, received <-chan mqtt.Message) {
+	t.Helper()
+	select {
+	case msg := <-received:
+		t.Errorf("got unexpected %s %s", msg.Topic(), msg.Payload())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"gateway/bootup", "gateway/bootup", true},
+		{"gateway/bootup", "gateway/lastwill", false},
+		{"gateway/+", "gateway/bootup", true},
+		{"gateway/+", "gateway/doorlock/u", false},
+		{"gateway/#", "gateway/doorlock/u", true},
+		{"gateway/#", "gateway", true},
+		{"+/doorlock/+", "server/doorlock/c", true},
+		{"#", "server/lastwill", true},
+		{"#", "$SYS/clients", false},
+		{"$SYS/#", "$SYS/clients", true},
+	}
+	for _, test := range tests {
+		if got := matchTopic(test.filter, test.topic); got != test.match {
+			t.Errorf("match %s %s got %t, wanted %t", test.filter, test.topic, got, test.match)
+		}
+	}
+
+	for _, filter := range []string{"", "a/#/b", "a/b#", "a+/b"} {
+		if validFilter(filter) {
+			t.Errorf("filter %q accepted", filter)
+		}
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := startBroker(t, Config{})
+	server := connect(t, clientOptions(b, "server"))
+	gw := connect(t, clientOptions(b, "gw-1"))
+
+	all := subscribe(t, server, "gateway/#")
+	one := subscribe(t, server, "gateway/doorlock/+")
+	publish(t, gw, "gateway/bootup", 1, false, "boot")
+	publish(t, gw, "gateway/doorlock/u", 2, false, "update")
+
+	expectMessage(t, all, "gateway/bootup", "boot")
+	expectMessage(t, all, "gateway/doorlock/u", "update")
+	msg := expectMessage(t, one, "gateway/doorlock/u", "update")
+	if msg.Qos() != MAX_QOS {
+		t.Errorf("got QoS %d, wanted %d", msg.Qos(), MAX_QOS)
+	}
+	expectNoMessage(t, one)
+
+	if tk := server.Unsubscribe("gateway/#"); !tk.WaitTimeout(testTimeout) || tk.Error() != nil {
+		t.Fatalf("unsubscribe failed: %v", tk.Error())
+	}
+	publish(t, gw, "gateway/bootup", 0, false, "boot")
+	expectNoMessage(t, all)
+	if got := b.Clients(); got != 2 {
+		t.Errorf("got %d clients, wanted 2", got)
+	}
+}
+
+func TestRetained(t *testing.T) {
+	b := startBroker(t, Config{})
+	gw := connect(t, clientOptions(b, "gw-1"))
+	publish(t, gw, "gateway/status", 1, true, "online")
+
+	server := connect(t, clientOptions(b, "server"))
+	msg := expectMessage(t, subscribe(t, server, "gateway/+"), "gateway/status", "online")
+	if !msg.Retained() {
+		t.Error("retained message not flagged retained")
+	}
+
+	// Empty payload deletes it
+	publish(t, gw, "gateway/status", 1, true, "")
+	other := connect(t, clientOptions(b, "other"))
+	expectNoMessage(t, subscribe(t, other, "gateway/+"))
+}
+
+func TestWill(t *testing.T) {
+	b := startBroker(t, Config{})
+	server := connect(t, clientOptions(b, "server"))
+	wills := subscribe(t, server, "gateway/lastwill")
+
+	// Dropped connection publishes the will
+	opts := clientOptions(b, "gw-1")
+	opts.SetWill("gateway/lastwill", "gw-1 gone", 1, false)
+	connect(t, opts)
+	b.mu.Lock()
+	b.clients["gw-1"].conn.Close()
+	b.mu.Unlock()
+	expectMessage(t, wills, "gateway/lastwill", "gw-1 gone")
+
+	// DISCONNECT does not
+	opts = clientOptions(b, "gw-2")
+	opts.SetWill("gateway/lastwill", "gw-2 gone", 1, false)
+	connect(t, opts).Disconnect(100)
+	expectNoMessage(t, wills)
+}
+
+func TestTakeOver(t *testing.T) {
+	b := startBroker(t, Config{})
+	server := connect(t, clientOptions(b, "server"))
+	wills := subscribe(t, server, "gateway/lastwill")
+
+	opts := clientOptions(b, "gw-1")
+	opts.SetWill("gateway/lastwill", "gw-1 gone", 1, false)
+	lost := make(chan error, 1)
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) { lost <- err })
+	connect(t, opts)
+	connect(t, clientOptions(b, "gw-1"))
+
+	select {
+	case <-lost:
+	case <-time.After(testTimeout):
+		t.Fatal("previous connection not closed")
+	}
+	expectNoMessage(t, wills)
+	if got := b.Clients(); got != 2 {
+		t.Errorf("got %d clients, wanted 2", got)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	b := startBroker(t, Config{Username: "dms", Password: "secret"})
+
+	opts := clientOptions(b, "gw-1")
+	opts.SetUsername("dms")
+	opts.SetPassword("wrong")
+	tk := mqtt.NewClient(opts).Connect()
+	if !tk.WaitTimeout(testTimeout) || !errors.Is(tk.Error(), packets.ErrorRefusedBadUsernameOrPassword) {
+		t.Errorf("got %v, wanted bad username or password", tk.Error())
+	}
+
+	opts.SetPassword("secret")
+	connect(t, opts)
+}
+
+func TestClose(t *testing.T) {
+	b := startBroker(t, Config{})
+	opts := clientOptions(b, "gw-1")
+	lost := make(chan error, 1)
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) { lost <- err })
+	connect(t, opts)
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	select {
+	case <-lost:
+	case <-time.After(testTimeout):
+		t.Fatal("client still connected")
+	}
+	if err := b.Close(); err != ErrBrokerClosed {
+		t.Errorf("got %v, wanted %v", err, ErrBrokerClosed)
+	}
+}
@@ -0,0 +1,43 @@
+package broker
+
+import "strings"
+
+// Topic name of a publish, wildcards are only allowed in filters
+func validTopic(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "+#")
+}
+
+// Topic filter of a subscription, "+" matches one level and "#" the remaining levels
+func validFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether topic matches filter, topics starting with "$" are not matched by a leading wildcard
+func matchTopic(filter string, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,198 @@
+// Simulate gateways against a broker, e.g. `go run ./cmd/gwsim -gateways 50 -duration 1m`
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/gwsim"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const (
+	EXIT_SHUTDOWN string = "shutdown" // publish gateway/shutdown
+	EXIT_LASTWILL string = "lastwill" // publish gateway/lastwill as the broker would
+	EXIT_SILENT   string = "silent"   // only disconnect
+
+	CONNECT_TIMEOUT time.Duration = 10 * time.Second
+)
+
+var (
+	broker         = flag.String("broker", "tcp://localhost:1883", "broker URL, ssl:// brokers are verified with -ca")
+	caFile         = flag.String("ca", "certs/ca.pem", "CA certificate of ssl:// brokers")
+	username       = flag.String("username", "", "username of brokers requiring one, e.g. MQTT_USERNAME of the embedded broker")
+	password       = flag.String("password", "", "password of brokers requiring one")
+	gateways       = flag.Int("gateways", 1, "number of simulated gateways")
+	doorlocks      = flag.Int("doorlocks", 2, "doorlocks of each gateway")
+	prefix         = flag.String("prefix", "sim-gw-", "gateway ID prefix, IDs are prefix followed by 1 to gateways")
+	secretKey      = flag.String("secret", "", "secret key gateways boot with, the server sends its own when it differs")
+	statusInterval = flag.Duration("status-interval", 10*time.Second, "interval between doorlock status updates of each gateway, 0 disables")
+	logInterval    = flag.Duration("log-interval", 30*time.Second, "interval between logs of each gateway, 0 disables")
+	duration       = flag.Duration("duration", 0, "run time, until interrupted when 0")
+	exitMode       = flag.String("exit", EXIT_SHUTDOWN, "what gateways do on exit: shutdown, lastwill or silent")
+)
+
+func main() {
+	flag.Parse()
+	if *exitMode != EXIT_SHUTDOWN && *exitMode != EXIT_LASTWILL && *exitMode != EXIT_SILENT {
+		fmt.Printf("invalid -exit %s\n", *exitMode)
+		os.Exit(2)
+	}
+	tlsConfig, err := newTlsConfig()
+	if err != nil {
+		fmt.Printf("failed to read CA: %s\n", err)
+		os.Exit(2)
+	}
+
+	gwList := []*gwsim.Gateway{}
+	clients := []mqtt.Client{}
+	started := time.Now()
+	for i := 1; i <= *gateways; i++ {
+		cfg := gwsim.NewConfig(*prefix, i, *doorlocks)
+		cfg.SecretKey = *secretKey
+		g := gwsim.NewGateway(cfg)
+		opts := g.ClientOptions(*broker)
+		if tlsConfig != nil {
+			opts.SetTLSConfig(tlsConfig)
+		}
+		if *username != "" {
+			opts.SetUsername(*username)
+			opts.SetPassword(*password)
+		}
+		client := mqtt.NewClient(opts)
+		if t := client.Connect(); !t.WaitTimeout(CONNECT_TIMEOUT) || t.Error() != nil {
+			fmt.Printf("gateway %s failed to connect: %v\n", g.ID(), t.Error())
+			os.Exit(1)
+		}
+		if err := g.Start(client); err != nil {
+			fmt.Printf("gateway %s failed to start: %s\n", g.ID(), err)
+			os.Exit(1)
+		}
+		gwList = append(gwList, g)
+		clients = append(clients, client)
+	}
+	fmt.Printf("%d gateways booted in %s\n", len(gwList), time.Since(started).Round(time.Millisecond))
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	statusTick, logTick := ticker(*statusInterval), ticker(*logInterval)
+	for running := true; running; {
+		select {
+		case <-statusTick:
+			for _, g := range gwList {
+				publishRandomStatus(g)
+			}
+		case <-logTick:
+			for _, g := range gwList {
+				if err := g.PublishLog("info", "simulated log"); err != nil {
+					fmt.Printf("gateway %s failed to publish log: %s\n", g.ID(), err)
+				}
+			}
+		case <-stop:
+			running = false
+		case <-timeout:
+			running = false
+		}
+	}
+
+	for i, g := range gwList {
+		switch *exitMode {
+		case EXIT_SHUTDOWN:
+			g.Shutdown("simulation ended")
+		case EXIT_LASTWILL:
+			g.PublishLastWill()
+		}
+		clients[i].Disconnect(250)
+	}
+	printSummary(gwList, time.Since(started))
+}
+
+func newTlsConfig() (*tls.Config, error) {
+	if !strings.HasPrefix(*broker, "ssl://") {
+		return nil, nil
+	}
+	ca, err := ioutil.ReadFile(*caFile)
+	if err != nil {
+		return nil, err
+	}
+	certpool := x509.NewCertPool()
+	certpool.AppendCertsFromPEM(ca)
+	return &tls.Config{RootCAs: certpool}, nil
+}
+
+func ticker(interval time.Duration) <-chan time.Time {
+	if interval <= 0 {
+		return nil
+	}
+	return time.NewTicker(interval).C
+}
+
+// Random doorlock of the gateway opens or closes, and locks or unlocks
+func publishRandomStatus(g *gwsim.Gateway) {
+	addresses := g.Doorlocks()
+	if len(addresses) == 0 {
+		return
+	}
+	status := mqttSvc.GatewayDoorlockMessage{
+		DoorlockAddress: addresses[rand.Intn(len(addresses))],
+		ConnectState:    "connected",
+		OpenState:       []string{"open", "close"}[rand.Intn(2)],
+		LockState:       []string{"lock", "unlock"}[rand.Intn(2)],
+		LastOpenTime:    mqttSvc.FlexInt(time.Now().Unix()),
+	}
+	if err := g.PublishDoorlockStatus(status); err != nil {
+		fmt.Printf("gateway %s failed to publish status: %s\n", g.ID(), err)
+	}
+}
+
+func printSummary(gwList []*gwsim.Gateway, elapsed time.Duration) {
+	var published, received int64
+	syncs := []time.Duration{}
+	topics := map[string]int{}
+	for _, g := range gwList {
+		stats := g.Stats()
+		published += stats.Published
+		received += stats.Received
+		if stats.BootupSync > 0 {
+			syncs = append(syncs, stats.BootupSync)
+		}
+		for _, msg := range g.Messages("") {
+			topics[msg.Topic]++
+		}
+	}
+
+	seconds := elapsed.Seconds()
+	fmt.Printf("ran %s: published %d (%.1f/s), received %d (%.1f/s)\n",
+		elapsed.Round(time.Millisecond), published, float64(published)/seconds, received, float64(received)/seconds)
+	if len(syncs) > 0 {
+		sort.Slice(syncs, func(i, j int) bool { return syncs[i] < syncs[j] })
+		fmt.Printf("bootup synced %d/%d gateways: min %s, median %s, max %s\n", len(syncs), len(gwList),
+			syncs[0].Round(time.Millisecond), syncs[len(syncs)/2].Round(time.Millisecond), syncs[len(syncs)-1].Round(time.Millisecond))
+	} else {
+		fmt.Printf("bootup synced 0/%d gateways, is the server running?\n", len(gwList))
+	}
+
+	topicList := []string{}
+	for topic := range topics {
+		topicList = append(topicList, topic)
+	}
+	sort.Strings(topicList)
+	for _, topic := range topicList {
+		fmt.Printf("  %-32s %d\n", topic, topics[topic])
+	}
+}
@@ -0,0 +1,21 @@
+// Write the MQTT payload contracts document, run by `go generate ./mqttSvc`
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func main() {
+	out := flag.String("o", "docs/mqtt_contracts.md", "output file")
+	flag.Parse()
+
+	if err := ioutil.WriteFile(*out, []byte(mqttSvc.ContractDoc()), 0644); err != nil {
+		fmt.Printf("failed to write %s: %s\n", *out, err)
+		os.Exit(1)
+	}
+}
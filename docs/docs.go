@@ -25,6 +25,69 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/healthz": {
+            "get": {
+                "description": "200 while the server can reach the database, the MQTT connection is reported but does not fail it",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Health Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.HealthStatus"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.HealthStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "HTTP latency by route, MQTT messages in/out by topic, subscriber errors, publish failures, online gateways, DB pool and Go runtime stats in Prometheus text format",
+                "produces": [
+                    "text/plain"
+                ],
+                "summary": "Metrics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "200 once every component started while the database and the MQTT broker are reachable, 503 otherwise and during shutdown",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Readiness Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.HealthStatus"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.HealthStatus"
+                        }
+                    }
+                }
+            }
+        },
         "/v1//scheduler/excel": {
             "post": {
                 "description": "Append Scheduler base on Excel",
@@ -97,24 +160,109 @@ var doc = `{
                 }
             }
         },
-        "/v1/area": {
+        "/v1/accessEvents": {
+            "get": {
+                "description": "find entry and exit events newest first, default limit 100 and max 1000",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Access Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Doorlock ID",
+                        "name": "doorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Person ID",
+                        "name": "personId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "MSSV, MSNV or CCCD",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "granted, warned or denied",
+                        "name": "result",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From event time in unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To event time in unix seconds",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.AccessEvent"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/accessGroup": {
             "post": {
-                "description": "Create area",
+                "description": "Create access group without members",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Area",
+                "summary": "Create Access Group",
                 "parameters": [
                     {
-                        "description": "Fields need to create a area",
+                        "description": "Fields need to create an access group",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateArea"
+                            "$ref": "#/definitions/models.SwagCreateGroup"
                         }
                     }
                 ],
@@ -122,7 +270,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Area"
+                            "$ref": "#/definitions/models.AccessGroup"
                         }
                     },
                     "400": {
@@ -134,17 +282,17 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "Delete area using \"id\" field",
+                "description": "Delete access group using \"id\" field, groups used by an access policy can not be deleted",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Area By ID",
+                "summary": "Delete Access Group By ID",
                 "parameters": [
                     {
-                        "description": "Area ID",
+                        "description": "Access group ID",
                         "name": "data",
                         "in": "body",
                         "required": true,
@@ -181,22 +329,22 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update area, must have \"id\" field",
+                "description": "Update name and description of access group, must have \"id\" field",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Area By ID",
+                "summary": "Update Access Group By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a area",
+                        "description": "Fields need to update an access group",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagUpdateArea"
+                            "$ref": "#/definitions/models.SwagUpdateGroup"
                         }
                     }
                 ],
@@ -216,17 +364,17 @@ var doc = `{
                 }
             }
         },
-        "/v1/area/{id}": {
+        "/v1/accessGroup/{id}": {
             "get": {
-                "description": "find area info by area id",
+                "description": "find access group with its members",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Area By ID",
+                "summary": "Find Access Group By ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Area ID",
+                        "description": "Access group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -236,7 +384,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Area"
+                            "$ref": "#/definitions/models.AccessGroup"
                         }
                     },
                     "400": {
@@ -248,24 +396,39 @@ var doc = `{
                 }
             }
         },
-        "/v1/areas": {
-            "get": {
-                "description": "find all areas info",
+        "/v1/accessGroup/{id}/members": {
+            "post": {
+                "description": "Add people to access group. Registers of the new members are created and sent to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All Area",
+                "summary": "Add Access Group Members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Access group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Person IDs",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupMemberIDs"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Area"
-                                }
-                            }
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -275,26 +438,31 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/v1/block/cmd": {
-            "post": {
-                "description": "Unlock or Lock all doorlocks in a Block",
+            },
+            "delete": {
+                "description": "Remove people from access group. Registers of the removed members are deleted and sent to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Unlock or Lock all gateway's doorlocks by BlockID",
+                "summary": "Remove Access Group Members",
                 "parameters": [
                     {
-                        "description": "Gateway Block command",
+                        "type": "string",
+                        "description": "Access group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Person IDs",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.GatewayBlockCmd"
+                            "$ref": "#/definitions/models.GroupMemberIDs"
                         }
                     }
                 ],
@@ -302,7 +470,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -314,24 +482,82 @@ var doc = `{
                 }
             }
         },
-        "/v1/customer": {
+        "/v1/accessGroups": {
+            "get": {
+                "description": "find all access groups",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Access Groups",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.AccessGroup"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/accessPolicies": {
+            "get": {
+                "description": "find all access policies",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Access Policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.AccessPolicy"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/accessPolicy": {
             "post": {
-                "description": "Create customer",
+                "description": "Create access policy, dates are dd/mm/yyyy. A register is created and sent to MQTT broker for every member of the access group and doorlock of the door group. With a \"recurrence\" rule (FREQ=DAILY|WEEKLY, INTERVAL, BYDAY) the \"timeWindows\" (HH:MM-HH:MM, comma separated) in \"timeZone\" are used instead of class periods",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Customer",
+                "summary": "Create Access Policy",
                 "parameters": [
                     {
-                        "description": "Fields need to create a customer",
+                        "description": "Fields need to create an access policy",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateCustomer"
+                            "$ref": "#/definitions/models.SwagCreateAccessPolicy"
                         }
                     }
                 ],
@@ -339,7 +565,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Customer"
+                            "$ref": "#/definitions/models.AccessPolicy"
                         }
                     },
                     "400": {
@@ -351,22 +577,34 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "Delete customer using \"cccd\" field. Send deleted info to MQTT broker",
+                "description": "Delete access policy using \"id\" field. Its registers are deleted and sent to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Customer By CCCD",
+                "summary": "Delete Access Policy By ID",
                 "parameters": [
                     {
-                        "description": "Customer CCCD",
+                        "description": "Access policy ID",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DeleteCustomer"
+                            "allOf": [
+                                {
+                                    "type": "object"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "id": {
+                                            "type": "integer"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 ],
@@ -374,7 +612,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -386,22 +624,22 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update customer, must have correct \"id\" and \"cccd\" field. Send updated info to MQTT broker",
+                "description": "Update access policy, must have \"id\" field. Only changed registers are sent to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Customer By ID",
+                "summary": "Update Access Policy By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a customer",
+                        "description": "Fields need to update an access policy",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Customer"
+                            "$ref": "#/definitions/models.SwagUpdateAccessPolicy"
                         }
                     }
                 ],
@@ -409,7 +647,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -421,18 +659,18 @@ var doc = `{
                 }
             }
         },
-        "/v1/customer/{cccd}": {
+        "/v1/accessPolicy/{id}": {
             "get": {
-                "description": "find customer info by customer cccd",
+                "description": "find access policy with its access group and door group",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Customer By CCCD",
+                "summary": "Find Access Policy By ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Customer CCCD",
-                        "name": "cccd",
+                        "description": "Access policy ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -441,7 +679,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Customer"
+                            "$ref": "#/definitions/models.AccessPolicy"
                         }
                     },
                     "400": {
@@ -453,50 +691,22 @@ var doc = `{
                 }
             }
         },
-        "/v1/customer/{cccd}/scheduler": {
-            "post": {
-                "description": "Add scheduler that allows customer open specific door. Send updated info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/accessPolicy/{id}/registers": {
+            "get": {
+                "description": "find scheduler registers generated from access policy",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add Door Open Scheduler For Customer",
+                "summary": "Find Access Policy Registers",
                 "parameters": [
                     {
-                        "description": "Request with Scheduler, GatewayID, DoorlockAdress",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.UserSchedulerReq"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "boolean"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
-                        }
+                        "type": "string",
+                        "description": "Access policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
-                }
-            }
-        },
-        "/v1/customers": {
-            "get": {
-                "description": "find all customers info",
-                "produces": [
-                    "application/json"
                 ],
-                "summary": "Find All Customer",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -505,7 +715,7 @@ var doc = `{
                             "items": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/models.Customer"
+                                    "$ref": "#/definitions/models.Scheduler"
                                 }
                             }
                         }
@@ -519,24 +729,24 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlock": {
+        "/v1/area": {
             "post": {
-                "description": "Create doorlock. Send created info to MQTT broker",
+                "description": "Create area",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Doorlock",
+                "summary": "Create Area",
                 "parameters": [
                     {
-                        "description": "Fields need to create a doorlock",
+                        "description": "Fields need to create a area",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateDoorlock"
+                            "$ref": "#/definitions/models.SwagCreateArea"
                         }
                     }
                 ],
@@ -544,7 +754,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Doorlock"
+                            "$ref": "#/definitions/models.Area"
                         }
                     },
                     "400": {
@@ -556,17 +766,17 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "Delete doorlock using \"id\" field. Send deleted info to MQTT broker",
+                "description": "Delete area using \"id\" field",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Doorlock By ID",
+                "summary": "Delete Area By ID",
                 "parameters": [
                     {
-                        "description": "Doorlock Delete payload",
+                        "description": "Area ID",
                         "name": "data",
                         "in": "body",
                         "required": true,
@@ -603,22 +813,22 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update doorlock, must have \"gatewayId\" and \"doorlockAddress\" field. Send updated info to MQTT broker",
+                "description": "Update area, must have \"id\" field",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Doorlock By Doorlock Address and GatewayID",
+                "summary": "Update Area By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a doorlock",
+                        "description": "Fields need to update a area",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagUpdateDoorlock"
+                            "$ref": "#/definitions/models.SwagUpdateArea"
                         }
                     }
                 ],
@@ -638,32 +848,27 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlock/cmd": {
-            "patch": {
-                "description": "Update doorlock state, must have \"id\" field. Send updated info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/area/{id}": {
+            "get": {
+                "description": "find area info by area id",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Doorlock State By ID",
+                "summary": "Find Area By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a doorlock state",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.SwaggerDoorlockCmd"
-                        }
+                        "type": "string",
+                        "description": "Area ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.Area"
                         }
                     },
                     "400": {
@@ -675,32 +880,24 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlock/state/cmd": {
-            "patch": {
-                "description": "Send command lock/unlock forever to Doorlock by serialID and save its lock state",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/areas": {
+            "get": {
+                "description": "find all areas info",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Send command lock/unlock forever to Doorlock and update doorlock's lock state",
-                "parameters": [
-                    {
-                        "description": "Fields need to update a doorlock state",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.DoorlockCmd"
-                        }
-                    }
-                ],
+                "summary": "Find All Area",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Area"
+                                }
+                            }
                         }
                     },
                     "400": {
@@ -712,27 +909,80 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlock/status/{id}": {
+        "/v1/auditLogs": {
             "get": {
-                "description": "Get doorlock status by doorlock id",
+                "description": "find audit logs newest first, filter by query params",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get Doorlock Status By ID",
+                "summary": "Find Audit Logs",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Doorlock ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action: create, update, delete, restore, command",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity type",
+                        "name": "entityType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity ID",
+                        "name": "entityId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Correlation ID",
+                        "name": "correlationId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From time in unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To time in unix seconds",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max records, default 100, max 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Records to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.DoorlockStatus"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.AuditLog"
+                                }
+                            }
                         }
                     },
                     "400": {
@@ -744,27 +994,69 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlock/{id}": {
+        "/v1/auditLogs/export": {
             "get": {
-                "description": "find doorlock info by doorlock id",
+                "description": "export every matching audit log oldest first as CSV or JSON lines file, same filters as find audit logs without limit and offset",
                 "produces": [
-                    "application/json"
+                    "text/csv",
+                    "application/x-ndjson"
                 ],
-                "summary": "Find Doorlock By ID",
+                "summary": "Export Audit Logs",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Doorlock ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "csv (default) or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity type",
+                        "name": "entityType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity ID",
+                        "name": "entityId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Correlation ID",
+                        "name": "correlationId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From time in unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To time in unix seconds",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Doorlock"
+                            "type": "file"
                         }
                     },
                     "400": {
@@ -776,37 +1068,44 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlockStatusLog/:fromTime/:toTime": {
-            "get": {
-                "description": "find doorlock status logs in time range",
+        "/v1/block/cmd": {
+            "post": {
+                "description": "Unlock or Lock all doorlocks in a Block. Unlocking a Block with doorlocks requiring approval creates a pending unlock request instead",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find DoorlockStatusLog In Time Range",
+                "summary": "Unlock or Lock all gateway's doorlocks by BlockID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "From Unix time",
-                        "name": "fromTime",
-                        "in": "path",
-                        "required": true
+                        "description": "Gateway Block command",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GatewayBlockCmd"
+                        }
                     },
                     {
                         "type": "string",
-                        "description": "To Unix time",
-                        "name": "toTime",
-                        "in": "path",
-                        "required": true
+                        "description": "Approver token, required when the command waits for approval",
+                        "name": "X-Approver-Token",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.DoorlockStatusLog"
-                            }
+                            "type": "boolean"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
                         }
                     },
                     "400": {
@@ -814,29 +1113,41 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     }
                 }
-            },
+            }
+        },
+        "/v1/credential": {
             "delete": {
-                "description": "delete doorlock status logs in time range",
+                "description": "Delete credential using \"id\" field. Send updated credentials to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete DoorlockStatusLog In Time Range",
+                "summary": "Delete Credential By ID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "From Unix time",
-                        "name": "fromTime",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "To Unix time",
-                        "name": "toTime",
-                        "in": "path",
-                        "required": true
+                        "description": "Credential ID",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteID"
+                        }
                     }
                 ],
                 "responses": {
@@ -853,22 +1164,25 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/v1/doorlockStatusLog/door/:id": {
-            "delete": {
-                "description": "delete doorlock status logs by doorId",
+            },
+            "patch": {
+                "description": "Update value, status or validity dates of credential, must have \"ID\" field. Fields not given are kept, clearValidity removes both dates. Send updated credentials to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Doorlock status logs by doorId",
+                "summary": "Update Credential By ID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "DoorID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Fields need to update a credential",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagUpdateCredential"
+                        }
                     }
                 ],
                 "responses": {
@@ -887,27 +1201,39 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlockStatusLog/{doorId}": {
-            "get": {
-                "description": "find doorlock status logs by door id",
+        "/v1/credential/{id}/revoke": {
+            "post": {
+                "description": "Blacklist lost or stolen credential and send the revocation to every gateway holding registers of its person. Optional replacement value gives the person a new credential of the same type",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find DoorlockStatusLog By DoorID",
+                "summary": "Revoke Credential By ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "DoorlockStatusLog ID",
+                        "description": "Credential ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Revocation reason and replacement value",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RevokeCredentialReq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.DoorlockStatusLog"
+                            "$ref": "#/definitions/models.CredentialRevocation"
                         }
                     },
                     "400": {
@@ -919,53 +1245,32 @@ var doc = `{
                 }
             }
         },
-        "/v1/doorlockStatusLogs": {
-            "get": {
-                "description": "find all doorlock status logs info",
+        "/v1/customer": {
+            "post": {
+                "description": "Create customer",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All DoorlockStatusLog",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.DoorlockStatusLog"
-                                }
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                "summary": "Create Customer",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a customer",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/models.SwagCreateCustomer"
                         }
                     }
-                }
-            }
-        },
-        "/v1/doorlocks": {
-            "get": {
-                "description": "find all doorlocks info",
-                "produces": [
-                    "application/json"
                 ],
-                "summary": "Find All Doorlock",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Doorlock"
-                                }
-                            }
+                            "$ref": "#/definitions/models.Customer"
                         }
                     },
                     "400": {
@@ -973,28 +1278,32 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     }
                 }
-            }
-        },
-        "/v1/employee": {
-            "post": {
-                "description": "Create employee. Send created info to MQTT broker",
+            },
+            "delete": {
+                "description": "Delete customer using \"cccd\" field. Send deleted info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Employee",
+                "summary": "Delete Customer By CCCD",
                 "parameters": [
                     {
-                        "description": "Fields need to create a employee",
+                        "description": "Customer CCCD",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateEmployee"
+                            "$ref": "#/definitions/models.DeleteCustomer"
                         }
                     }
                 ],
@@ -1002,7 +1311,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Employee"
+                            "type": "boolean"
                         }
                     },
                     "400": {
@@ -1013,23 +1322,23 @@ var doc = `{
                     }
                 }
             },
-            "delete": {
-                "description": "Delete employee using \"msnv\" field. Send deleted info to MQTT broker",
+            "patch": {
+                "description": "Update customer, must have correct \"id\" and \"cccd\" field. Send updated info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Employee By MSNV",
+                "summary": "Update Customer By ID",
                 "parameters": [
                     {
-                        "description": "Employee MSNV",
+                        "description": "Fields need to update a customer",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DeleteEmployee"
+                            "$ref": "#/definitions/models.Customer"
                         }
                     }
                 ],
@@ -1047,32 +1356,29 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Update employee, must have correct \"id\" and \"msnv\" field. Send updated info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/v1/customer/{cccd}": {
+            "get": {
+                "description": "find customer info by customer cccd",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Employee By ID and MSNV",
+                "summary": "Find Customer By CCCD",
                 "parameters": [
                     {
-                        "description": "Fields need to update an employee",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Employee"
-                        }
+                        "type": "string",
+                        "description": "Customer CCCD",
+                        "name": "cccd",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.Customer"
                         }
                     },
                     "400": {
@@ -1084,18 +1390,18 @@ var doc = `{
                 }
             }
         },
-        "/v1/employee/{msnv}": {
-            "get": {
-                "description": "find employee info by employee msnv",
+        "/v1/customer/{cccd}/restore": {
+            "post": {
+                "description": "Restore soft deleted customer. Send credentials and registers of the customer to MQTT broker",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Employee By MSNV",
+                "summary": "Restore Deleted Customer By CCCD",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Employee MSNV",
-                        "name": "msnv",
+                        "description": "Customer CCCD",
+                        "name": "cccd",
                         "in": "path",
                         "required": true
                     }
@@ -1104,7 +1410,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Employee"
+                            "type": "boolean"
                         }
                     },
                     "400": {
@@ -1116,16 +1422,16 @@ var doc = `{
                 }
             }
         },
-        "/v1/employee/{msnv}/scheduler": {
+        "/v1/customer/{cccd}/scheduler": {
             "post": {
-                "description": "Add scheduler that allows employee open specific door. Send updated info to MQTT broker",
+                "description": "Add scheduler that allows customer open specific door. Send updated info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add Door Open Scheduler For Employee",
+                "summary": "Add Door Open Scheduler For Customer",
                 "parameters": [
                     {
                         "description": "Request with Scheduler, GatewayID, DoorlockAdress",
@@ -1153,13 +1459,13 @@ var doc = `{
                 }
             }
         },
-        "/v1/employees": {
+        "/v1/customers": {
             "get": {
-                "description": "find all employees info",
+                "description": "find all customers info",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All Employee",
+                "summary": "Find All Customer",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -1168,7 +1474,7 @@ var doc = `{
                             "items": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/models.Employee"
+                                    "$ref": "#/definitions/models.Customer"
                                 }
                             }
                         }
@@ -1182,24 +1488,97 @@ var doc = `{
                 }
             }
         },
-        "/v1/gateway": {
+        "/v1/customers/export": {
+            "get": {
+                "description": "Export all customers in the same format as import",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Export Customers To CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or xlsx",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/customers/import": {
             "post": {
-                "description": "Create gateway. Send created info to MQTT broker",
+                "description": "Create or update customers keyed on CCCD, only columns in the file are updated. Columns: cccd, name, phone, rfidPass, keypadPass. Returns result of every row. Send updated credentials to MQTT broker in batches",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Import Customers From CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV or XLSX file, first row is header",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or xlsx, taken from file extension when missing",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/doorGroup": {
+            "post": {
+                "description": "Create door group without doorlocks",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Gateway",
+                "summary": "Create Door Group",
                 "parameters": [
                     {
-                        "description": "Fields need to create a gateway",
+                        "description": "Fields need to create an door group",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateGateway"
+                            "$ref": "#/definitions/models.SwagCreateGroup"
                         }
                     }
                 ],
@@ -1207,7 +1586,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Gateway"
+                            "$ref": "#/definitions/models.DoorGroup"
                         }
                     },
                     "400": {
@@ -1219,17 +1598,17 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "Delete gateway using \"\" field. Send deleted info to MQTT broker",
+                "description": "Delete door group using \"id\" field, groups used by an access policy can not be deleted",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Gateway By Gateway ID",
+                "summary": "Delete Door Group By ID",
                 "parameters": [
                     {
-                        "description": "Gateway ID",
+                        "description": "Door group ID",
                         "name": "data",
                         "in": "body",
                         "required": true,
@@ -1241,8 +1620,8 @@ var doc = `{
                                 {
                                     "type": "object",
                                     "properties": {
-                                        "gateway_id": {
-                                            "type": "string"
+                                        "id": {
+                                            "type": "integer"
                                         }
                                     }
                                 }
@@ -1266,22 +1645,22 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update gateway, must have \"gateway_id\" field. Send updated info to MQTT broker",
+                "description": "Update name and description of door group, must have \"id\" field",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Gateway By Gateway ID",
+                "summary": "Update Door Group By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a gateway",
+                        "description": "Fields need to update an door group",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagUpateGateway"
+                            "$ref": "#/definitions/models.SwagUpdateGroup"
                         }
                     }
                 ],
@@ -1301,17 +1680,17 @@ var doc = `{
                 }
             }
         },
-        "/v1/gateway/{id}": {
+        "/v1/doorGroup/{id}": {
             "get": {
-                "description": "find gateway and doorlock info by gateway id",
+                "description": "find door group with its doorlocks",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Gateway By ID",
+                "summary": "Find Door Group By ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Gateway ID",
+                        "description": "Door group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -1321,7 +1700,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Gateway"
+                            "$ref": "#/definitions/models.DoorGroup"
                         }
                     },
                     "400": {
@@ -1333,147 +1712,39 @@ var doc = `{
                 }
             }
         },
-        "/v1/gateway/{id}/doorlock": {
+        "/v1/doorGroup/{id}/doorlocks": {
             "post": {
-                "description": "Add Doorlock for Gateway",
+                "description": "Add doorlocks to door group. Registers of the new doorlocks are created and sent to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add Doorlock for Gateway",
-                "parameters": [
-                    {
-                        "description": "Request with Doorlock",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Doorlock"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "boolean"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/v1/gatewayLog/{id}": {
-            "get": {
-                "description": "find gateway log info by id",
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Find GatewayLog By ID",
+                "summary": "Add Door Group Doorlocks",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "GatewayLog ID",
+                        "description": "Door group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.GatewayLog"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/v1/gatewayLogs": {
-            "get": {
-                "description": "find all gateway logs info",
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Find All GatewayLog",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.GatewayLog"
-                                }
-                            }
-                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    {
+                        "description": "Doorlock IDs",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/models.GroupMemberIDs"
                         }
                     }
-                }
-            }
-        },
-        "/v1/gatewayLogs/:id/period": {
-            "get": {
-                "description": "Get gateway's logs by log type (DEBUG/INFO) in a period of time (UNIX)",
-                "produces": [
-                    "application/json"
-                ],
-                "summary": "Find Gateway logs by log type in period of time",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "GatewayLog ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Log's type (DEBUG/INFO), default is DEBUG",
-                        "name": "type",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "From Unix time",
-                        "name": "from",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "To Unix time, default use current time",
-                        "name": "to",
-                        "in": "query"
-                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.GatewayLog"
-                            }
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -1483,78 +1754,39 @@ var doc = `{
                         }
                     }
                 }
-            }
-        },
-        "/v1/gatewayLogs/period": {
-            "post": {
-                "description": "Change time period for GatewayLogs Cleaner",
-                "produces": [
+            },
+            "delete": {
+                "description": "Remove doorlocks from door group. Registers of the removed doorlocks are deleted and sent to MQTT broker",
+                "consumes": [
                     "application/json"
                 ],
-                "summary": "Update GatewayLogs Cleaner time period (Default: 1 week)",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Period (HOURS)",
-                        "name": "period",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "boolean"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/v1/gatewayLogs/period/{id}/date/:from/:to": {
-            "get": {
-                "description": "find Gateway logs by period of time",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Gateway logs by period of time",
+                "summary": "Remove Door Group Doorlocks",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "GatewayLog ID",
+                        "description": "Door group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "From Unix time",
-                        "name": "from",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "To Unix time",
-                        "name": "to",
-                        "in": "path",
-                        "required": true
+                        "description": "Doorlock IDs",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GroupMemberIDs"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.GatewayLog"
-                            }
+                            "$ref": "#/definitions/models.RegisterDiff"
                         }
                     },
                     "400": {
@@ -1566,13 +1798,13 @@ var doc = `{
                 }
             }
         },
-        "/v1/gateways": {
+        "/v1/doorGroups": {
             "get": {
-                "description": "find all gateways info",
+                "description": "find all door groups",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All Gateway",
+                "summary": "Find All Door Groups",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -1581,7 +1813,7 @@ var doc = `{
                             "items": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/models.Gateway"
+                                    "$ref": "#/definitions/models.DoorGroup"
                                 }
                             }
                         }
@@ -1595,24 +1827,24 @@ var doc = `{
                 }
             }
         },
-        "/v1/scheduler": {
+        "/v1/doorlock": {
             "post": {
-                "description": "Create scheduler",
+                "description": "Create doorlock. Send created info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Scheduler",
+                "summary": "Create Doorlock",
                 "parameters": [
                     {
-                        "description": "Fields need to create a scheduler",
+                        "description": "Fields need to create a doorlock",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SwagCreateScheduler"
+                            "$ref": "#/definitions/models.SwagCreateDoorlock"
                         }
                     }
                 ],
@@ -1620,7 +1852,7 @@ var doc = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Scheduler"
+                            "$ref": "#/definitions/models.Doorlock"
                         }
                     },
                     "400": {
@@ -1632,17 +1864,17 @@ var doc = `{
                 }
             },
             "delete": {
-                "description": "Delete scheduler using \"id\" field. Send deleted info to MQTT broker",
+                "description": "Delete doorlock using \"id\" field. Send deleted info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Scheduler By ID",
+                "summary": "Delete Doorlock By ID",
                 "parameters": [
                     {
-                        "description": "Scheduler ID",
+                        "description": "Doorlock Delete payload",
                         "name": "data",
                         "in": "body",
                         "required": true,
@@ -1679,22 +1911,22 @@ var doc = `{
                 }
             },
             "patch": {
-                "description": "Update scheduler, must have \"id\" field. Send updated info to MQTT broker",
+                "description": "Update doorlock, must have \"gatewayId\" and \"doorlockAddress\" field. Send updated info to MQTT broker",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Scheduler By ID",
+                "summary": "Update Doorlock By Doorlock Address and GatewayID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a scheduler",
+                        "description": "Fields need to update a doorlock",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateScheduler"
+                            "$ref": "#/definitions/models.SwagUpdateDoorlock"
                         }
                     }
                 ],
@@ -1714,27 +1946,44 @@ var doc = `{
                 }
             }
         },
-        "/v1/scheduler/{id}": {
-            "get": {
-                "description": "find scheduler info by scheduler id",
+        "/v1/doorlock/approval": {
+            "patch": {
+                "description": "Flag doorlock so that unlock commands on it or on its Block wait for a second operator. Clearing the flag would let one operator unlock at once, so it creates a pending unlock request instead",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Scheduler By ID",
+                "summary": "Update Doorlock Requires Approval",
                 "parameters": [
+                    {
+                        "description": "Doorlock ID and flag",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DoorlockApproval"
+                        }
+                    },
                     {
                         "type": "string",
-                        "description": "Scheduler ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Approver token, required when the command waits for approval",
+                        "name": "X-Approver-Token",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Scheduler"
+                            "type": "boolean"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
                         }
                     },
                     "400": {
@@ -1742,28 +1991,54 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/v1/schedulers": {
-            "get": {
-                "description": "find all scheduler info",
+        "/v1/doorlock/cmd": {
+            "patch": {
+                "description": "Update doorlock state, must have \"id\" field. Send updated info to MQTT broker. Unlocking a doorlock requiring approval creates a pending unlock request instead",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All Scheduler",
+                "summary": "Update Doorlock State By ID",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a doorlock state",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwaggerDoorlockCmd"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Approver token, required when the command waits for approval",
+                        "name": "X-Approver-Token",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Scheduler"
-                                }
-                            }
+                            "type": "boolean"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
                         }
                     },
                     "400": {
@@ -1771,22 +2046,60 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/v1/secretkeys": {
-            "get": {
-                "description": "Find Mifare Card Secret Key",
+        "/v1/doorlock/state/cmd": {
+            "patch": {
+                "description": "Send command lock/unlock forever to Doorlock by serialID and save its lock state. Unlocking a doorlock requiring approval creates a pending unlock request instead",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Mifare Card Secret Key",
+                "summary": "Send command lock/unlock forever to Doorlock and update doorlock's lock state",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a doorlock state",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DoorlockCmd"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Approver token, required when the command waits for approval",
+                        "name": "X-Approver-Token",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.SecretKey"
+                            "type": "boolean"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
                         }
                     },
                     "400": {
@@ -1794,36 +2107,43 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/v1/student": {
-            "post": {
-                "description": "Create student",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/doorlock/status/{id}": {
+            "get": {
+                "description": "Get doorlock status by doorlock id",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create Student",
+                "summary": "Get Doorlock Status By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to create a student",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.SwagCreateStudent"
-                        }
+                        "type": "string",
+                        "description": "Doorlock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/models.DoorlockStatus"
                         }
                     },
                     "400": {
@@ -1833,32 +2153,29 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete student using \"mssv\" field. Send deleted info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/v1/doorlock/{id}": {
+            "get": {
+                "description": "find doorlock info by doorlock id",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete Student By MSSV",
+                "summary": "Find Doorlock By ID",
                 "parameters": [
                     {
-                        "description": "Student MSSV",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.DeleteStudent"
-                        }
+                        "type": "string",
+                        "description": "Doorlock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "$ref": "#/definitions/models.Doorlock"
                         }
                     },
                     "400": {
@@ -1868,25 +2185,22 @@ var doc = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Update student, must have correct \"id\" and \"mssv\" field. Send updated info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/v1/doorlock/{id}/restore": {
+            "post": {
+                "description": "Restore soft deleted doorlock. Send doorlock and its registers to MQTT broker",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update Student By ID",
+                "summary": "Restore Deleted Doorlock By ID",
                 "parameters": [
                     {
-                        "description": "Fields need to update a student",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Student"
-                        }
+                        "type": "string",
+                        "description": "Doorlock ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -1905,32 +2219,38 @@ var doc = `{
                 }
             }
         },
-        "/v1/student/{msnv}/scheduler": {
-            "post": {
-                "description": "Add scheduler that allows student open specific door. Send updated info to MQTT broker",
-                "consumes": [
-                    "application/json"
-                ],
+        "/v1/doorlockStats": {
+            "get": {
+                "description": "find open count, mean open duration and disconnected time of every doorlock with events, most disconnected first. Default range is the last 7 days",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Add Door Open Scheduler For Student",
+                "summary": "Find All Doorlock Stats",
                 "parameters": [
                     {
-                        "description": "Request with Scheduler, GatewayID, DoorlockAdress",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.UserSchedulerReq"
-                        }
+                        "type": "integer",
+                        "description": "From unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To unix seconds",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "boolean"
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.DoorlockStats"
+                                }
+                            }
                         }
                     },
                     "400": {
@@ -1942,27 +2262,51 @@ var doc = `{
                 }
             }
         },
-        "/v1/student/{mssv}": {
+        "/v1/doorlockStats/{doorId}": {
             "get": {
-                "description": "find student info by student mssv",
+                "description": "find open count, mean open duration and disconnected time of doorlock with a series per bucket (hour, day or week, default day) in the time zone. The bucket is widened when the range has more than 400 of them",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find Student By MSSV",
+                "summary": "Find Doorlock Stats By DoorID",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Student MSSV",
-                        "name": "mssv",
+                        "type": "integer",
+                        "description": "Doorlock ID",
+                        "name": "doorId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To unix seconds",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hour, day or week",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time zone of the buckets, default Asia/Ho_Chi_Minh",
+                        "name": "timeZone",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Student"
+                            "$ref": "#/definitions/models.DoorlockStats"
                         }
                     },
                     "400": {
@@ -1974,13 +2318,40 @@ var doc = `{
                 }
             }
         },
-        "/v1/students": {
+        "/v1/doorlockStatusEvents/{doorId}": {
             "get": {
-                "description": "find all students info",
+                "description": "find connect, door and lock events of doorlock oldest first, default range is the last 7 days, default limit 100 and max 1000",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Find All Student",
+                "summary": "Find Doorlock Status Events By DoorID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Doorlock ID",
+                        "name": "doorId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To unix seconds",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -1989,7 +2360,7 @@ var doc = `{
                             "items": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/models.Student"
+                                    "$ref": "#/definitions/models.DoorlockStatusEvent"
                                 }
                             }
                         }
@@ -2002,47 +2373,3255 @@ var doc = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Area": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "type": "integer"
-                },
-                "manager": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                }
-            }
         },
-        "models.Customer": {
-            "type": "object",
-            "required": [
-                "cccd"
-            ],
-            "properties": {
-                "cccd": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "keypadPass": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "phone": {
-                    "type": "string"
-                },
+        "/v1/doorlockStatusLog/:fromTime/:toTime": {
+            "get": {
+                "description": "find doorlock status logs in time range",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find DoorlockStatusLog In Time Range",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "From Unix time",
+                        "name": "fromTime",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "To Unix time",
+                        "name": "toTime",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.DoorlockStatusLog"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "delete doorlock status logs in time range",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete DoorlockStatusLog In Time Range",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "From Unix time",
+                        "name": "fromTime",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "To Unix time",
+                        "name": "toTime",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/doorlockStatusLog/door/:id": {
+            "delete": {
+                "description": "delete doorlock status logs by doorId",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Doorlock status logs by doorId",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "DoorID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/doorlockStatusLog/{doorId}": {
+            "get": {
+                "description": "find doorlock status logs by door id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find DoorlockStatusLog By DoorID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "DoorlockStatusLog ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DoorlockStatusLog"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/doorlockStatusLogs": {
+            "get": {
+                "description": "find all doorlock status logs info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All DoorlockStatusLog",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.DoorlockStatusLog"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/doorlocks": {
+            "get": {
+                "description": "find all doorlocks info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Doorlock",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Doorlock"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employee": {
+            "post": {
+                "description": "Create employee. Send created info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create Employee",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a employee",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateEmployee"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Employee"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete employee using \"msnv\" field. Send deleted info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Employee By MSNV",
+                "parameters": [
+                    {
+                        "description": "Employee MSNV",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteEmployee"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update employee, must have correct \"id\" and \"msnv\" field. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Employee By ID and MSNV",
+                "parameters": [
+                    {
+                        "description": "Fields need to update an employee",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Employee"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employee/{msnv}": {
+            "get": {
+                "description": "find employee info by employee msnv",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Employee By MSNV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Employee MSNV",
+                        "name": "msnv",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Employee"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employee/{msnv}/restore": {
+            "post": {
+                "description": "Restore soft deleted employee. Send credentials and registers of the employee to MQTT broker",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore Deleted Employee By MSNV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Employee MSNV",
+                        "name": "msnv",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employee/{msnv}/scheduler": {
+            "post": {
+                "description": "Add scheduler that allows employee open specific door. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add Door Open Scheduler For Employee",
+                "parameters": [
+                    {
+                        "description": "Request with Scheduler, GatewayID, DoorlockAdress",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UserSchedulerReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employees": {
+            "get": {
+                "description": "find all employees info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Employee",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Employee"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employees/export": {
+            "get": {
+                "description": "Export all employees in the same format as import",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Export Employees To CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or xlsx",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/employees/import": {
+            "post": {
+                "description": "Create or update employees keyed on MSNV, only columns in the file are updated. Columns: msnv, name, phone, email, department, role, rfidPass, keypadPass, highestPriority. Returns result of every row. Send updated credentials to MQTT broker in batches",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Import Employees From CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV or XLSX file, first row is header",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or xlsx, taken from file extension when missing",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gateway": {
+            "post": {
+                "description": "Create gateway. Send created info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create Gateway",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a gateway",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateGateway"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Gateway"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete gateway using \"\" field. Send deleted info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Gateway By Gateway ID",
+                "parameters": [
+                    {
+                        "description": "Gateway ID",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "type": "object"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "gateway_id": {
+                                            "type": "string"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update gateway, must have \"gateway_id\" field. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Gateway By Gateway ID",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a gateway",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagUpateGateway"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gateway/{id}": {
+            "get": {
+                "description": "find gateway and doorlock info by gateway id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Gateway By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Gateway ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Gateway"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gateway/{id}/doorlock": {
+            "post": {
+                "description": "Add Doorlock for Gateway",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add Doorlock for Gateway",
+                "parameters": [
+                    {
+                        "description": "Request with Doorlock",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Doorlock"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gateway/{id}/restore": {
+            "post": {
+                "description": "Restore soft deleted gateway and the doorlocks deleted with it. Send gateway, doorlocks and registers to MQTT broker",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore Deleted Gateway By Gateway ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Gateway ID (gatewayId field, not numeric id)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gatewayLog/{id}": {
+            "get": {
+                "description": "find gateway log info by id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find GatewayLog By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "GatewayLog ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.GatewayLog"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gatewayLogs": {
+            "get": {
+                "description": "find all gateway logs info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All GatewayLog",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.GatewayLog"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gatewayLogs/:id/period": {
+            "get": {
+                "description": "Get gateway's logs by log type (DEBUG/INFO) in a period of time (UNIX)",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Gateway logs by log type in period of time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "GatewayLog ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Log's type (DEBUG/INFO), default is DEBUG",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "From Unix time",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "To Unix time, default use current time",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GatewayLog"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gatewayLogs/period": {
+            "post": {
+                "description": "Change time period for GatewayLogs Cleaner, rounded up to hours of the gatewayLogs retention policy",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update GatewayLogs Cleaner time period (Default: 1 week)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Period (HOURS)",
+                        "name": "period",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gatewayLogs/period/{id}/date/:from/:to": {
+            "get": {
+                "description": "find Gateway logs by period of time",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Gateway logs by period of time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "GatewayLog ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "From Unix time",
+                        "name": "from",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "To Unix time",
+                        "name": "to",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GatewayLog"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/gateways": {
+            "get": {
+                "description": "find all gateways info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Gateway",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Gateway"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/jobs": {
+            "get": {
+                "description": "find background jobs (recycleBinPurge, retention, scheduledCommands) with their interval, next run, result of the last run and whether they are running",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Jobs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Job"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/mqttDeadLetter/{id}": {
+            "get": {
+                "description": "find MQTT dead letter with its payload, reason of its latest failure and attempts",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find MQTT Dead Letter By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.MqttDeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/mqttDeadLetter/{id}/discard": {
+            "post": {
+                "description": "Discard pending dead letter, it is kept for inspection but can not be replayed anymore",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Discard MQTT Dead Letter By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.MqttDeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/mqttDeadLetter/{id}/replay": {
+            "post": {
+                "description": "Handle pending dead letter again as if its gateway just sent it, e.g. once the doorlock it refers to exists. It is marked replayed on success, otherwise it stays pending with the new reason and one more attempt",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Replay MQTT Dead Letter By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.MqttDeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/mqttDeadLetters": {
+            "get": {
+                "description": "find gateway messages rejected by their contract or whose processing failed, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All MQTT Dead Letters",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending, replayed or discarded",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.MqttDeadLetter"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/occupancy/buildings": {
+            "get": {
+                "description": "find people count and capacity of every building, rooms are grouped by blockId of their doorlocks",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Building Occupancy",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.BuildingOccupancy"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/occupancy/room": {
+            "patch": {
+                "description": "Set anti-passback of room to off, soft (violations are warned) or hard (gateways deny violations). The room state is sent to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Room Anti-passback",
+                "parameters": [
+                    {
+                        "description": "Room ID and anti-passback mode",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagUpdateRoomSetting"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoomOccupancy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/occupancy/room/{roomId}": {
+            "get": {
+                "description": "find occupancy of room with the people inside",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Room Occupancy By Room ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Room ID of the doorlocks",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoomOccupancy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/occupancy/room/{roomId}/reset": {
+            "post": {
+                "description": "Mark everyone as out of the room, used when people left without passing an exit reader. The room state is sent to MQTT broker",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Reset Room Occupancy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Room ID of the doorlocks",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoomOccupancy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/occupancy/rooms": {
+            "get": {
+                "description": "find people count, capacity and anti-passback mode of every room with a reader doorlock or someone inside",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Room Occupancy",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.RoomOccupancy"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/people": {
+            "get": {
+                "description": "find all people with their credentials",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All People",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Person"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/person/{id}": {
+            "get": {
+                "description": "find person and credentials by person id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Person By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Person"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/person/{id}/credential": {
+            "post": {
+                "description": "Add RFID card or keypad PIN to person, status is active when missing. Send updated credentials to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add Credential To Person",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Person ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields need to create a credential",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateCredential"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Credential"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/recycleBin": {
+            "get": {
+                "description": "find soft deleted students, employees, customers, doorlocks and gateways that can still be restored",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Recycle Bin",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RecycleBin"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/retentionPolicies": {
+            "get": {
+                "description": "find retention policy of every log table with result of its last run, tables never configured have their default policy",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Retention Policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.RetentionPolicy"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/retentionPolicies/run": {
+            "post": {
+                "description": "Apply every retention policy now instead of waiting for the hourly run, fails when a run is in progress",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Run Retention Policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.RetentionPolicy"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/retentionPolicy": {
+            "patch": {
+                "description": "Set max age in hours, max rows and archive of a log table, 0 is no limit. Tables: gatewayLogs, doorlockStatusLogs, doorlockStatusEvents, accessEvents, auditLogs (archive required), scheduledCommandRuns",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Retention Policy By Table",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a retention policy",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagUpdateRetentionPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RetentionPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/revocation/{id}": {
+            "get": {
+                "description": "find credential revocation and acknowledgement of each gateway",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Credential Revocation By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Revocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CredentialRevocation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/revocation/{id}/resend": {
+            "post": {
+                "description": "Send revocation again to gateways which have not acknowledged it yet",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Resend Credential Revocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Revocation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CredentialRevocation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/revocations": {
+            "get": {
+                "description": "find credential revocations with acknowledgement of each gateway, pending=true keeps those not confirmed by every gateway",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Credential Revocations",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only revocations waiting for gateways",
+                        "name": "pending",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.CredentialRevocation"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/roomStats/{roomId}/busiestHours": {
+            "get": {
+                "description": "find door openings of the room doorlocks per hour of day in the time zone, busiest first. Default range is the last 7 days",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Room Busiest Hours",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Room ID of the doorlocks",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "From unix seconds",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "To unix seconds",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time zone of the hours, default Asia/Ho_Chi_Minh",
+                        "name": "timeZone",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.RoomBusiestHours"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduledCommand": {
+            "post": {
+                "description": "Create doorlock command sent at every occurrence of \"cron\" (minute hour day-of-month month day-of-week) in \"timeZone\" to the doorlocks of the target (doorlock, room, block or area). \"missedRunPolicy\" skip or runOnce handles runs missed while the server was down",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create Scheduled Command",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a scheduled command",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateScheduledCommand"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduledCommand"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete scheduled command and its run history using \"id\" field",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Scheduled Command By ID",
+                "parameters": [
+                    {
+                        "description": "Scheduled command ID",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "type": "object"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "id": {
+                                            "type": "integer"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update scheduled command, must have \"id\" field. The next run is computed again from now",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Scheduled Command By ID",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a scheduled command",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagUpdateScheduledCommand"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduledCommand/enable": {
+            "patch": {
+                "description": "Enable or disable scheduled command. An enabled command runs from its next occurrence, occurrences while disabled are not run",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Enable Scheduled Command By ID",
+                "parameters": [
+                    {
+                        "description": "Scheduled command ID and enabled",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduledCommandEnable"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduledCommand/{id}": {
+            "get": {
+                "description": "find scheduled command by id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Scheduled Command By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Scheduled command ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduledCommand"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduledCommand/{id}/runs": {
+            "get": {
+                "description": "find runs of scheduled command newest first, default limit 100 and max 1000",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Scheduled Command Runs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Scheduled command ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.ScheduledCommandRun"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduledCommands": {
+            "get": {
+                "description": "find all scheduled commands with their next and last run",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Scheduled Commands",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.ScheduledCommand"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduler": {
+            "post": {
+                "description": "Create scheduler",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create Scheduler",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a scheduler",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateScheduler"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Scheduler"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete scheduler using \"id\" field. Send deleted info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Scheduler By ID",
+                "parameters": [
+                    {
+                        "description": "Scheduler ID",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "type": "object"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "id": {
+                                            "type": "integer"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update scheduler, must have \"id\" field. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Scheduler By ID",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a scheduler",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateScheduler"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/scheduler/{id}": {
+            "get": {
+                "description": "find scheduler info by scheduler id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Scheduler By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Scheduler ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Scheduler"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/schedulers": {
+            "get": {
+                "description": "find all scheduler info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Scheduler",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Scheduler"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/secretkeys": {
+            "get": {
+                "description": "Find Mifare Card Secret Key",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Mifare Card Secret Key",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SecretKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/student": {
+            "post": {
+                "description": "Create student",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create Student",
+                "parameters": [
+                    {
+                        "description": "Fields need to create a student",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SwagCreateStudent"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete student using \"mssv\" field. Send deleted info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete Student By MSSV",
+                "parameters": [
+                    {
+                        "description": "Student MSSV",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteStudent"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update student, must have correct \"id\" and \"mssv\" field. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Update Student By ID",
+                "parameters": [
+                    {
+                        "description": "Fields need to update a student",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/student/{msnv}/scheduler": {
+            "post": {
+                "description": "Add scheduler that allows student open specific door. Send updated info to MQTT broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add Door Open Scheduler For Student",
+                "parameters": [
+                    {
+                        "description": "Request with Scheduler, GatewayID, DoorlockAdress",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UserSchedulerReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/student/{mssv}": {
+            "get": {
+                "description": "find student info by student mssv",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Student By MSSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Student MSSV",
+                        "name": "mssv",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Student"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/student/{mssv}/restore": {
+            "post": {
+                "description": "Restore soft deleted student. Send credentials and registers of the student to MQTT broker",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore Deleted Student By MSSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Student MSSV",
+                        "name": "mssv",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "boolean"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/students": {
+            "get": {
+                "description": "find all students info",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Student",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Student"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/students/export": {
+            "get": {
+                "description": "Export all students in the same format as import",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "summary": "Export Students To CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or xlsx",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/students/import": {
+            "post": {
+                "description": "Create or update students keyed on MSSV, only columns in the file are updated. Columns: mssv, name, phone, email, major, rfidPass, keypadPass. Returns result of every row. Send updated credentials to MQTT broker in batches",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Import Students From CSV Or XLSX",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV or XLSX file, first row is header",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv or xlsx, taken from file extension when missing",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/unlockRequest/{id}": {
+            "get": {
+                "description": "find unlock request by id",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find Unlock Request By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unlock request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/unlockRequest/{id}/approve": {
+            "post": {
+                "description": "Approve pending unlock request and send its command to MQTT broker. The approver is identified by its token in UNLOCK_APPROVER_TOKENS and must differ from the requester",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Approve Unlock Request By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unlock request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Approver token",
+                        "name": "X-Approver-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/unlockRequest/{id}/reject": {
+            "post": {
+                "description": "Reject pending unlock request with an approver token, the requester can reject its own request to cancel it",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Reject Unlock Request By ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unlock request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Approver token",
+                        "name": "X-Approver-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnlockRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/unlockRequests": {
+            "get": {
+                "description": "find unlock requests newest first, pending requests past their approval window are expired",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Find All Unlock Requests",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending, approved, rejected or expired",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.UnlockRequest"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.HealthStatus": {
+            "type": "object",
+            "properties": {
+                "database": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "mqtt": {
+                    "type": "string"
+                },
+                "ready": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AccessEvent": {
+            "type": "object",
+            "properties": {
+                "blockId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "direction": {
+                    "type": "string"
+                },
+                "doorId": {
+                    "type": "integer"
+                },
+                "eventTime": {
+                    "type": "string"
+                },
+                "gatewayId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "personId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "roomId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AccessGroup": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Person"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AccessPolicy": {
+            "type": "object",
+            "properties": {
+                "accessGroup": {
+                    "$ref": "#/definitions/models.AccessGroup"
+                },
+                "accessGroupId": {
+                    "type": "integer"
+                },
+                "doorGroup": {
+                    "$ref": "#/definitions/models.DoorGroup"
+                },
+                "doorGroupId": {
+                    "type": "integer"
+                },
+                "endClassTime": {
+                    "type": "integer"
+                },
+                "endDate": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "recurrence": {
+                    "type": "string"
+                },
+                "startClassTime": {
+                    "type": "integer"
+                },
+                "startDate": {
+                    "type": "string"
+                },
+                "timeWindows": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                },
+                "weekDay": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Area": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "manager": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditLog": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "description": "JSON snapshot, empty on delete",
+                    "type": "string"
+                },
+                "before": {
+                    "description": "JSON snapshot, empty on create",
+                    "type": "string"
+                },
+                "correlationId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "diff": {
+                    "description": "JSON map of field to AuditChange",
+                    "type": "string"
+                },
+                "entityId": {
+                    "type": "string"
+                },
+                "entityType": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "sourceIp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BuildingOccupancy": {
+            "type": "object",
+            "properties": {
+                "blockId": {
+                    "type": "string"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "occupancy": {
+                    "type": "integer"
+                },
+                "rooms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Credential": {
+            "type": "object",
+            "properties": {
+                "clearValidity": {
+                    "description": "Update only, removes both validity dates. Dates not given are kept otherwise",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "personId": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "validFrom": {
+                    "type": "string"
+                },
+                "validTo": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CredentialRevocation": {
+            "type": "object",
+            "properties": {
+                "acks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RevocationAck"
+                    }
+                },
+                "completedAt": {
+                    "type": "string"
+                },
+                "credentialId": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "personId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "revokedBy": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Customer": {
+            "type": "object",
+            "required": [
+                "cccd"
+            ],
+            "properties": {
+                "cccd": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "keypadPass": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "person": {
+                    "$ref": "#/definitions/models.Person"
+                },
+                "personId": {
+                    "type": "integer"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "rfidPass": {
+                    "type": "string"
+                },
+                "schedulers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scheduler"
+                    }
+                }
+            }
+        },
+        "models.DeleteCustomer": {
+            "type": "object",
+            "required": [
+                "cccd"
+            ],
+            "properties": {
+                "cccd": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeleteEmployee": {
+            "type": "object",
+            "required": [
+                "msnv"
+            ],
+            "properties": {
+                "msnv": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeleteID": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DeleteStudent": {
+            "type": "object",
+            "required": [
+                "mssv"
+            ],
+            "properties": {
+                "mssv": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorGroup": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "doorlocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Doorlock"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Doorlock": {
+            "type": "object",
+            "properties": {
+                "activeState": {
+                    "type": "string"
+                },
+                "blockId": {
+                    "type": "string"
+                },
+                "connectState": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "doorSerialId": {
+                    "type": "string"
+                },
+                "doorState": {
+                    "type": "string"
+                },
+                "doorlockAddress": {
+                    "type": "string"
+                },
+                "floorId": {
+                    "type": "string"
+                },
+                "gatewayId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastOpenTime": {
+                    "type": "integer"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "lockState": {
+                    "type": "string"
+                },
+                "readerDirection": {
+                    "description": "entry or exit reader of its room",
+                    "type": "string"
+                },
+                "requiresApproval": {
+                    "description": "Remote unlock commands wait for a second operator, set with SetDoorlockRequiresApproval only",
+                    "type": "boolean"
+                },
+                "roomId": {
+                    "type": "string"
+                },
+                "schedulers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scheduler"
+                    }
+                }
+            }
+        },
+        "models.DoorlockApproval": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "requiresApproval": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.DoorlockCmd": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "kept on the unlock request of doorlocks requiring approval",
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorlockStats": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "disconnectedSeconds": {
+                    "type": "number"
+                },
+                "doorId": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "meanOpenSeconds": {
+                    "type": "number"
+                },
+                "openCount": {
+                    "type": "integer"
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DoorlockStatsPoint"
+                    }
+                },
+                "timeZone": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorlockStatsPoint": {
+            "type": "object",
+            "properties": {
+                "disconnectedSeconds": {
+                    "type": "number"
+                },
+                "openCount": {
+                    "type": "integer"
+                },
+                "openSeconds": {
+                    "type": "number"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorlockStatus": {
+            "type": "object",
+            "properties": {
+                "connectState": {
+                    "type": "string"
+                },
+                "doorState": {
+                    "type": "string"
+                },
+                "doorlockAddress": {
+                    "type": "string"
+                },
+                "gatewayId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lockState": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorlockStatusEvent": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "connected, open or unlocked",
+                    "type": "boolean"
+                },
+                "doorId": {
+                    "type": "integer"
+                },
+                "eventTime": {
+                    "type": "string"
+                },
+                "eventType": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "roomId": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DoorlockStatusLog": {
+            "type": "object",
+            "properties": {
+                "doorId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "stateValue": {
+                    "description": "corresponding statetype",
+                    "type": "string"
+                },
+                "statusType": {
+                    "description": "ConnectState, DoorState, LockState",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Employee": {
+            "type": "object",
+            "required": [
+                "msnv"
+            ],
+            "properties": {
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
+                    "type": "string"
+                },
+                "department": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "highestPriority": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "keypadPass": {
+                    "type": "string"
+                },
+                "msnv": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "person": {
+                    "$ref": "#/definitions/models.Person"
+                },
+                "personId": {
+                    "type": "integer"
+                },
+                "phone": {
+                    "type": "string"
+                },
                 "rfidPass": {
                     "type": "string"
                 },
+                "role": {
+                    "type": "string"
+                },
                 "schedulers": {
                     "type": "array",
                     "items": {
@@ -2051,64 +5630,207 @@ var doc = `{
                 }
             }
         },
-        "models.DeleteCustomer": {
+        "models.Gateway": {
             "type": "object",
-            "required": [
-                "cccd"
-            ],
             "properties": {
-                "cccd": {
+                "areaId": {
+                    "type": "string"
+                },
+                "connectState": {
+                    "type": "boolean"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
+                    "type": "string"
+                },
+                "doorlocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Doorlock"
+                    }
+                },
+                "gatewayId": {
+                    "type": "string"
+                },
+                "gw_networks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.GwNetwork"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "softwareVersion": {
                     "type": "string"
                 }
             }
         },
-        "models.DeleteEmployee": {
+        "models.GatewayBlockCmd": {
             "type": "object",
             "required": [
-                "msnv"
+                "action",
+                "block_id"
             ],
             "properties": {
-                "msnv": {
+                "action": {
+                    "type": "string"
+                },
+                "block_id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "kept on the unlock request of blocks with doorlocks requiring approval",
                     "type": "string"
                 }
             }
         },
-        "models.DeleteStudent": {
+        "models.GatewayLog": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "gatewayId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "logTime": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GroupMemberIDs": {
             "type": "object",
             "required": [
-                "mssv"
+                "ids"
             ],
             "properties": {
-                "mssv": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.GwNetwork": {
+            "type": "object",
+            "properties": {
+                "gatewayID": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "interfaceName": {
+                    "type": "string"
+                },
+                "macAddress": {
+                    "type": "string"
+                },
+                "primaryIpAddress": {
+                    "type": "string"
+                },
+                "secondaryIpAddress": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.HourlyOpenCount": {
+            "type": "object",
+            "properties": {
+                "hour": {
+                    "type": "integer"
+                },
+                "openCount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportResult": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRowResult"
+                    }
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportRowResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "userId": {
                     "type": "string"
                 }
             }
         },
-        "models.Doorlock": {
+        "models.Job": {
             "type": "object",
             "properties": {
-                "activeState": {
-                    "type": "string"
+                "id": {
+                    "type": "integer"
                 },
-                "blockId": {
-                    "type": "string"
+                "intervalSeconds": {
+                    "type": "integer"
                 },
-                "connectState": {
+                "lastDurationMs": {
+                    "type": "integer"
+                },
+                "lastError": {
                     "type": "string"
                 },
-                "description": {
+                "lastRunAt": {
                     "type": "string"
                 },
-                "doorSerialId": {
+                "lastStatus": {
                     "type": "string"
                 },
-                "doorState": {
+                "name": {
                     "type": "string"
                 },
-                "doorlockAddress": {
+                "nextRunAt": {
                     "type": "string"
                 },
-                "floorId": {
+                "running": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.MqttDeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "createdAt": {
                     "type": "string"
                 },
                 "gatewayId": {
@@ -2117,212 +5839,340 @@ var doc = `{
                 "id": {
                     "type": "integer"
                 },
-                "lastOpenTime": {
-                    "type": "integer"
+                "payload": {
+                    "type": "string"
                 },
-                "location": {
+                "reason": {
                     "type": "string"
                 },
-                "lockState": {
+                "status": {
                     "type": "string"
                 },
-                "roomId": {
+                "topic": {
                     "type": "string"
                 },
-                "schedulers": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Scheduler"
-                    }
+                "updatedAt": {
+                    "type": "string"
                 }
             }
         },
-        "models.DoorlockCmd": {
+        "models.Person": {
             "type": "object",
             "properties": {
-                "duration": {
+                "credentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Credential"
+                    }
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
                     "type": "string"
                 },
                 "id": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
-                "state": {
+                "type": {
+                    "type": "string"
+                },
+                "userId": {
                     "type": "string"
                 }
             }
         },
-        "models.DoorlockStatus": {
+        "models.RecycleBin": {
             "type": "object",
             "properties": {
-                "connectState": {
-                    "type": "string"
+                "customers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Customer"
+                    }
                 },
-                "doorState": {
-                    "type": "string"
+                "doorlocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Doorlock"
+                    }
                 },
-                "doorlockAddress": {
-                    "type": "string"
+                "employees": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Employee"
+                    }
                 },
-                "gatewayId": {
-                    "type": "string"
+                "gateways": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Gateway"
+                    }
                 },
-                "id": {
-                    "type": "string"
+                "students": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Student"
+                    }
+                }
+            }
+        },
+        "models.RegisterDiff": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scheduler"
+                    }
                 },
-                "lockState": {
-                    "type": "string"
+                "deleted": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scheduler"
+                    }
+                },
+                "updated": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Scheduler"
+                    }
                 }
             }
         },
-        "models.DoorlockStatusLog": {
+        "models.RetentionPolicy": {
             "type": "object",
+            "required": [
+                "table"
+            ],
             "properties": {
-                "doorId": {
-                    "type": "string"
+                "archive": {
+                    "type": "boolean"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "stateValue": {
-                    "description": "corresponding statetype",
+                "lastArchive": {
+                    "description": "file of the last run",
                     "type": "string"
                 },
-                "statusType": {
-                    "description": "ConnectState, DoorState, LockState",
+                "lastDeleted": {
+                    "type": "integer"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "lastRunAt": {
+                    "type": "string"
+                },
+                "maxAgeHours": {
+                    "type": "integer"
+                },
+                "maxRows": {
+                    "type": "integer"
+                },
+                "table": {
                     "type": "string"
                 }
             }
         },
-        "models.Employee": {
+        "models.RevocationAck": {
             "type": "object",
-            "required": [
-                "msnv"
-            ],
             "properties": {
-                "department": {
+                "ackedAt": {
                     "type": "string"
                 },
-                "email": {
+                "gatewayId": {
                     "type": "string"
                 },
-                "highestPriority": {
-                    "type": "boolean"
-                },
                 "id": {
                     "type": "integer"
                 },
-                "keypadPass": {
+                "revocationId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.RevokeCredentialReq": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
                     "type": "string"
                 },
-                "msnv": {
+                "replacementValue": {
                     "type": "string"
-                },
-                "name": {
+                }
+            }
+        },
+        "models.RoomBusiestHours": {
+            "type": "object",
+            "properties": {
+                "from": {
                     "type": "string"
                 },
-                "phone": {
-                    "type": "string"
+                "hours": {
+                    "description": "busiest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.HourlyOpenCount"
+                    }
                 },
-                "rfidPass": {
+                "roomId": {
                     "type": "string"
                 },
-                "role": {
+                "timeZone": {
                     "type": "string"
                 },
-                "schedulers": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Scheduler"
-                    }
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "models.Gateway": {
+        "models.RoomOccupancy": {
             "type": "object",
             "properties": {
-                "areaId": {
+                "antiPassback": {
                     "type": "string"
                 },
-                "connectState": {
+                "blockId": {
+                    "type": "string"
+                },
+                "capacity": {
+                    "type": "integer"
+                },
+                "full": {
                     "type": "boolean"
                 },
-                "doorlocks": {
+                "occupancy": {
+                    "type": "integer"
+                },
+                "people": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.Doorlock"
+                        "$ref": "#/definitions/models.RoomPresence"
                     }
                 },
-                "gatewayId": {
+                "roomId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RoomPresence": {
+            "type": "object",
+            "properties": {
+                "blockId": {
                     "type": "string"
                 },
-                "gw_networks": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.GwNetwork"
-                    }
+                "enteredAt": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
+                "personId": {
+                    "type": "integer"
+                },
+                "roomId": {
                     "type": "string"
                 },
-                "softwareVersion": {
+                "userId": {
                     "type": "string"
                 }
             }
         },
-        "models.GatewayBlockCmd": {
+        "models.ScheduledCommand": {
             "type": "object",
-            "required": [
-                "action",
-                "block_id"
-            ],
             "properties": {
                 "action": {
                     "type": "string"
                 },
-                "block_id": {
+                "cron": {
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "timed action when set, door mode otherwise",
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastRunAt": {
+                    "type": "string"
+                },
+                "missedRunPolicy": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nextRunAt": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "targetType": {
+                    "type": "string"
+                },
+                "timeZone": {
                     "type": "string"
                 }
             }
         },
-        "models.GatewayLog": {
+        "models.ScheduledCommandEnable": {
             "type": "object",
+            "required": [
+                "id"
+            ],
             "properties": {
-                "content": {
-                    "type": "string"
-                },
-                "gatewayId": {
-                    "type": "string"
+                "enabled": {
+                    "type": "boolean"
                 },
                 "id": {
                     "type": "integer"
-                },
-                "logTime": {
-                    "type": "string"
                 }
             }
         },
-        "models.GwNetwork": {
+        "models.ScheduledCommandRun": {
             "type": "object",
             "properties": {
-                "gatewayID": {
+                "doorlocks": {
+                    "description": "commands sent",
+                    "type": "integer"
+                },
+                "errorMsg": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "interfaceName": {
-                    "type": "string"
+                "missed": {
+                    "description": "server was down at ScheduledAt",
+                    "type": "boolean"
                 },
-                "macAddress": {
+                "ranAt": {
                     "type": "string"
                 },
-                "primaryIpAddress": {
+                "scheduledAt": {
                     "type": "string"
                 },
-                "secondaryIpAddress": {
+                "scheduledCommandId": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "doorlocks requiring approval for unlock",
+                    "type": "integer"
+                },
+                "status": {
                     "type": "string"
                 }
             }
@@ -2330,6 +6180,10 @@ var doc = `{
         "models.Scheduler": {
             "type": "object",
             "properties": {
+                "accessPolicyId": {
+                    "description": "Set on registers generated from an access policy, they change with the policy only",
+                    "type": "integer"
+                },
                 "amount": {
                     "type": "integer"
                 },
@@ -2370,6 +6224,9 @@ var doc = `{
                 "lecturerName": {
                     "type": "string"
                 },
+                "personId": {
+                    "type": "integer"
+                },
                 "role": {
                     "description": "value in [\"employee\", \"student\", \"customer\"]",
                     "type": "string"
@@ -2420,6 +6277,12 @@ var doc = `{
                 "mssv"
             ],
             "properties": {
+                "deletedAt": {
+                    "type": "string"
+                },
+                "deletedBy": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
@@ -2438,6 +6301,12 @@ var doc = `{
                 "name": {
                     "type": "string"
                 },
+                "person": {
+                    "$ref": "#/definitions/models.Person"
+                },
+                "personId": {
+                    "type": "integer"
+                },
                 "phone": {
                     "type": "string"
                 },
@@ -2452,6 +6321,44 @@ var doc = `{
                 }
             }
         },
+        "models.SwagCreateAccessPolicy": {
+            "type": "object",
+            "properties": {
+                "accessGroupId": {
+                    "type": "integer"
+                },
+                "doorGroupId": {
+                    "type": "integer"
+                },
+                "endClassTime": {
+                    "type": "integer"
+                },
+                "endDate": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "recurrence": {
+                    "type": "string"
+                },
+                "startClassTime": {
+                    "type": "integer"
+                },
+                "startDate": {
+                    "type": "string"
+                },
+                "timeWindows": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                },
+                "weekDay": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.SwagCreateArea": {
             "type": "object",
             "properties": {
@@ -2466,6 +6373,26 @@ var doc = `{
                 }
             }
         },
+        "models.SwagCreateCredential": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "validFrom": {
+                    "type": "string"
+                },
+                "validTo": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SwagCreateCustomer": {
             "type": "object",
             "required": [
@@ -2561,6 +6488,49 @@ var doc = `{
                 }
             }
         },
+        "models.SwagCreateGroup": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SwagCreateScheduledCommand": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "missedRunPolicy": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "targetType": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SwagCreateScheduler": {
             "type": "object",
             "properties": {
@@ -2660,6 +6630,47 @@ var doc = `{
                 }
             }
         },
+        "models.SwagUpdateAccessPolicy": {
+            "type": "object",
+            "properties": {
+                "accessGroupId": {
+                    "type": "integer"
+                },
+                "doorGroupId": {
+                    "type": "integer"
+                },
+                "endClassTime": {
+                    "type": "integer"
+                },
+                "endDate": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "recurrence": {
+                    "type": "string"
+                },
+                "startClassTime": {
+                    "type": "integer"
+                },
+                "startDate": {
+                    "type": "string"
+                },
+                "timeWindows": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                },
+                "weekDay": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.SwagUpdateArea": {
             "type": "object",
             "properties": {
@@ -2677,6 +6688,29 @@ var doc = `{
                 }
             }
         },
+        "models.SwagUpdateCredential": {
+            "type": "object",
+            "properties": {
+                "clearValidity": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "validFrom": {
+                    "type": "string"
+                },
+                "validTo": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SwagUpdateDoorlock": {
             "type": "object",
             "properties": {
@@ -2707,11 +6741,91 @@ var doc = `{
                 "location": {
                     "type": "string"
                 },
+                "readerDirection": {
+                    "type": "string"
+                },
+                "roomId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SwagUpdateGroup": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SwagUpdateRetentionPolicy": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "type": "boolean"
+                },
+                "maxAgeHours": {
+                    "type": "integer"
+                },
+                "maxRows": {
+                    "type": "integer"
+                },
+                "table": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SwagUpdateRoomSetting": {
+            "type": "object",
+            "properties": {
+                "antiPassback": {
+                    "type": "string"
+                },
                 "roomId": {
                     "type": "string"
                 }
             }
         },
+        "models.SwagUpdateScheduledCommand": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "missedRunPolicy": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "targetType": {
+                    "type": "string"
+                },
+                "timeZone": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SwagUpdateScheduler": {
             "type": "object",
             "properties": {
@@ -2777,7 +6891,55 @@ var doc = `{
                 "id": {
                     "type": "string"
                 },
+                "reason": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UnlockRequest": {
+            "type": "object",
+            "properties": {
+                "blockId": {
+                    "type": "string"
+                },
+                "command": {
+                    "type": "string"
+                },
+                "decidedAt": {
+                    "type": "string"
+                },
+                "decidedBy": {
+                    "type": "string"
+                },
+                "doorId": {
+                    "type": "integer"
+                },
+                "duration": {
+                    "type": "string"
+                },
+                "executedAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requestedBy": {
+                    "type": "string"
+                },
                 "state": {
+                    "description": "doorlock state or block action",
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
                 }
             }
@@ -2789,6 +6951,10 @@ var doc = `{
                 "userId"
             ],
             "properties": {
+                "accessPolicyId": {
+                    "description": "Set on registers generated from an access policy, they change with the policy only",
+                    "type": "integer"
+                },
                 "amount": {
                     "type": "integer"
                 },
@@ -2832,6 +6998,9 @@ var doc = `{
                 "lecturerName": {
                     "type": "string"
                 },
+                "personId": {
+                    "type": "integer"
+                },
                 "role": {
                     "description": "value in [\"employee\", \"student\", \"customer\"]",
                     "type": "string"
@@ -2872,6 +7041,10 @@ var doc = `{
                 "gatewayId"
             ],
             "properties": {
+                "accessPolicyId": {
+                    "description": "Set on registers generated from an access policy, they change with the policy only",
+                    "type": "integer"
+                },
                 "amount": {
                     "type": "integer"
                 },
@@ -2918,6 +7091,9 @@ var doc = `{
                 "lecturerName": {
                     "type": "string"
                 },
+                "personId": {
+                    "type": "integer"
+                },
                 "role": {
                     "description": "value in [\"employee\", \"student\", \"customer\"]",
                     "type": "string"
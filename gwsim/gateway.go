@@ -0,0 +1,394 @@
+// Package gwsim simulates gateways talking to the server over MQTT, so that mqttSvc can be exercised
+// without physical gateways. A simulated gateway publishes what a real one does, answers doorlock
+// commands and credential revocations, and records every server message addressed to it
+package gwsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// Every server topic, messages of other gateways are ignored
+	SERVER_TOPICS string = "server/#"
+
+	DEFAULT_SOFTWARE_VERSION string = "gwsim-1.0.0"
+
+	PUBLISH_TIMEOUT time.Duration = 10 * time.Second
+)
+
+// Gateway identity and hardware sent on bootup
+type Config struct {
+	GatewayId       string
+	SecretKey       string
+	SoftwareVersion string
+	Doorlocks       []mqttSvc.GatewayBootupDoorlock
+	Interfaces      []mqttSvc.GatewayInterface
+}
+
+// Config of the gateway number n with doorlocks addressed 1 to doorlocks and one eth0 interface
+func NewConfig(prefix string, n int, doorlocks int) Config {
+	cfg := Config{
+		GatewayId:       fmt.Sprintf("%s%d", prefix, n),
+		SoftwareVersion: DEFAULT_SOFTWARE_VERSION,
+		Interfaces: []mqttSvc.GatewayInterface{{
+			InterfaceName:    "eth0",
+			PrimaryIpAddress: fmt.Sprintf("10.%d.%d.%d", (n>>16)&0xff, (n>>8)&0xff, n&0xff),
+			MacAddress:       fmt.Sprintf("02:00:00:%02x:%02x:%02x", (n>>16)&0xff, (n>>8)&0xff, n&0xff),
+		}},
+	}
+	for i := 1; i <= doorlocks; i++ {
+		cfg.Doorlocks = append(cfg.Doorlocks, mqttSvc.GatewayBootupDoorlock{
+			DoorlockAddress: strconv.Itoa(i),
+			Location:        fmt.Sprintf("%s room %d", cfg.GatewayId, i),
+		})
+	}
+	return cfg
+}
+
+// Server message received by a gateway
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Received time.Time
+}
+
+// Message field, e.g. "doorlock_address" or "0.user_id" for an array message
+func (m Message) Get(path string) string {
+	return gjson.GetBytes(m.Payload, "message."+path).String()
+}
+
+type Stats struct {
+	Published int64
+	Received  int64
+	// From bootup to the server/system/bootup answer, 0 until answered
+	BootupSync time.Duration
+}
+
+// Simulated gateway. It keeps the doorlocks and secret key the server syncs to it
+type Gateway struct {
+	cfg    Config
+	client mqtt.Client
+
+	mu         sync.Mutex
+	doorlocks  map[string]bool
+	acked      map[string]bool
+	messages   []Message
+	received   chan struct{}
+	bootupAt   time.Time
+	bootupSync time.Duration
+
+	published int64
+}
+
+func NewGateway(cfg Config) *Gateway {
+	if cfg.SoftwareVersion == "" {
+		cfg.SoftwareVersion = DEFAULT_SOFTWARE_VERSION
+	}
+	g := &Gateway{
+		cfg:       cfg,
+		doorlocks: map[string]bool{},
+		acked:     map[string]bool{},
+		received:  make(chan struct{}),
+	}
+	for _, dl := range cfg.Doorlocks {
+		g.doorlocks[dl.DoorlockAddress] = true
+	}
+	return g
+}
+
+func (g *Gateway) ID() string { return g.cfg.GatewayId }
+
+// Options of a client connecting the gateway to broker, the broker publishes its last will when the
+// connection drops
+func (g *Gateway) ClientOptions(broker string) *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(broker)
+	opts.SetClientID("gwsim-" + g.cfg.GatewayId)
+	opts.SetCleanSession(true)
+	opts.SetWill(mqttSvc.TOPIC_GW_LASTWILL, g.lastWillPayload(), 1, false)
+	return opts
+}
+
+// Subscribe to the server topics with connected client, then boot up
+func (g *Gateway) Start(client mqtt.Client) error {
+	g.client = client
+	t := client.Subscribe(SERVER_TOPICS, 1, g.handle)
+	if !t.WaitTimeout(PUBLISH_TIMEOUT) {
+		return fmt.Errorf("subscribe to %s timed out", SERVER_TOPICS)
+	}
+	if err := t.Error(); err != nil {
+		return err
+	}
+	return g.Bootup()
+}
+
+// Publish bootup with the doorlocks and secret key the gateway has now
+func (g *Gateway) Bootup() error {
+	g.mu.Lock()
+	msg := mqttSvc.GatewayBootupMessage{
+		System: mqttSvc.GatewaySystem{
+			SecretKey:       g.cfg.SecretKey,
+			SoftwareVersion: g.cfg.SoftwareVersion,
+			Interfaces:      g.cfg.Interfaces,
+		},
+		Doorlocks: []mqttSvc.GatewayBootupDoorlock{},
+	}
+	configured := map[string]mqttSvc.GatewayBootupDoorlock{}
+	for _, dl := range g.cfg.Doorlocks {
+		configured[dl.DoorlockAddress] = dl
+	}
+	for _, address := range g.addresses() {
+		dl, ok := configured[address]
+		if !ok {
+			dl = mqttSvc.GatewayBootupDoorlock{DoorlockAddress: address}
+		}
+		msg.Doorlocks = append(msg.Doorlocks, dl)
+	}
+	g.bootupAt, g.bootupSync = time.Now(), 0
+	g.mu.Unlock()
+
+	return g.publish(mqttSvc.TOPIC_GW_BOOTUP, mqttSvc.GatewayBootupPayload{
+		Version:   mqttSvc.CONTRACT_VERSION,
+		GatewayId: g.cfg.GatewayId,
+		Message:   msg,
+	})
+}
+
+// Publish doorlock state, empty states are left unchanged by the server
+func (g *Gateway) PublishDoorlockStatus(status mqttSvc.GatewayDoorlockMessage) error {
+	return g.publish(mqttSvc.TOPIC_GW_DOORLOCK_U, mqttSvc.GatewayDoorlockPayload{
+		Version:   mqttSvc.CONTRACT_VERSION,
+		GatewayId: g.cfg.GatewayId,
+		Message:   status,
+	})
+}
+
+func (g *Gateway) PublishLog(logType string, data string) error {
+	return g.publish(mqttSvc.TOPIC_GW_LOG_C, mqttSvc.GatewayLogPayload{
+		Version:   mqttSvc.CONTRACT_VERSION,
+		GatewayId: g.cfg.GatewayId,
+		Message: mqttSvc.GatewayLogMessage{
+			LogType: logType,
+			LogData: mqttSvc.FlexString(data),
+			LogTime: mqttSvc.FlexInt(time.Now().Unix()),
+		},
+	})
+}
+
+// Publish the last will as the broker does when the gateway connection drops
+func (g *Gateway) PublishLastWill() error {
+	return g.publish(mqttSvc.TOPIC_GW_LASTWILL, json.RawMessage(g.lastWillPayload()))
+}
+
+// Announce a clean shutdown, the server deletes the gateway until its next bootup
+func (g *Gateway) Shutdown(reason string) error {
+	return g.publish(mqttSvc.TOPIC_GW_SHUTDOWN, mqttSvc.GatewayEnvelope{
+		Version:   mqttSvc.CONTRACT_VERSION,
+		GatewayId: g.cfg.GatewayId,
+		Message:   mqttSvc.FlexString(reason),
+	})
+}
+
+// Addresses of the doorlocks the gateway has, as synced by the server
+func (g *Gateway) Doorlocks() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.addresses()
+}
+
+func (g *Gateway) SecretKey() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.cfg.SecretKey
+}
+
+// Server messages received on topic in order, every one when topic is empty
+func (g *Gateway) Messages(topic string) []Message {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.filter(topic)
+}
+
+// Wait until n server messages were received on topic
+func (g *Gateway) WaitForMessages(topic string, n int, timeout time.Duration) ([]Message, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		g.mu.Lock()
+		msgList, received := g.filter(topic), g.received
+		g.mu.Unlock()
+		if len(msgList) >= n {
+			return msgList, nil
+		}
+		select {
+		case <-received:
+		case <-deadline.C:
+			return msgList, fmt.Errorf("gateway %s got %d messages on %s, wanted %d", g.cfg.GatewayId, len(msgList), topic, n)
+		}
+	}
+}
+
+func (g *Gateway) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return Stats{
+		Published:  atomic.LoadInt64(&g.published),
+		Received:   int64(len(g.messages)),
+		BootupSync: g.bootupSync,
+	}
+}
+
+func (g *Gateway) filter(topic string) []Message {
+	msgList := []Message{}
+	for _, msg := range g.messages {
+		if topic == "" || msg.Topic == topic {
+			msgList = append(msgList, msg)
+		}
+	}
+	return msgList
+}
+
+func (g *Gateway) addresses() []string {
+	addresses := []string{}
+	for address := range g.doorlocks {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Record server message addressed to the gateway, or to every gateway, and answer it as firmwares do
+func (g *Gateway) handle(c mqtt.Client, m mqtt.Message) {
+	payload := m.Payload()
+	if gwId := gjson.GetBytes(payload, "gateway_id"); gwId.Exists() && gwId.String() != g.cfg.GatewayId {
+		return
+	}
+	msg := Message{Topic: m.Topic(), Payload: payload, Received: time.Now()}
+
+	g.mu.Lock()
+	g.messages = append(g.messages, msg)
+	close(g.received)
+	g.received = make(chan struct{})
+	acks := g.apply(msg)
+	g.mu.Unlock()
+
+	// Publish without waiting, a handler waiting on its client blocks it
+	for _, ack := range acks {
+		g.publishAsync(ack.topic, ack.payload)
+	}
+}
+
+type ack struct {
+	topic   string
+	payload interface{}
+}
+
+// Update the synced state, return the messages answering msg. Called with mu held
+func (g *Gateway) apply(msg Message) []ack {
+	message := gjson.GetBytes(msg.Payload, "message")
+	switch msg.Topic {
+	case mqttSvc.TOPIC_SV_SYSTEM_BOOTUP:
+		if !g.bootupAt.IsZero() && g.bootupSync == 0 {
+			g.bootupSync = msg.Received.Sub(g.bootupAt)
+		}
+		g.cfg.SecretKey = message.Get("secret_key").String()
+	case mqttSvc.TOPIC_SV_SYSTEM_U:
+		g.cfg.SecretKey = message.Get("secret_key").String()
+	case mqttSvc.TOPIC_SV_DOORLOCK_C:
+		g.doorlocks[message.Get("doorlock_address").String()] = true
+	case mqttSvc.TOPIC_SV_DOORLOCK_D:
+		delete(g.doorlocks, message.Get("doorlock_address").String())
+	case mqttSvc.TOPIC_SV_DOORLOCK_BOOTUP:
+		g.doorlocks = map[string]bool{}
+		for _, dl := range message.Array() {
+			g.doorlocks[dl.Get("doorlock_address").String()] = true
+		}
+	case mqttSvc.TOPIC_SV_DOORLOCK_CMD:
+		return g.commandAcks(message)
+	case mqttSvc.TOPIC_SV_CREDENTIAL_REVOKE:
+		return g.revokeAcks([]gjson.Result{message})
+	case mqttSvc.TOPIC_SV_BLACKLIST_BOOTUP:
+		return g.revokeAcks(message.Array())
+	}
+	return nil
+}
+
+// Doorlocks report the commanded state, a command without doorlock_address is for every doorlock
+func (g *Gateway) commandAcks(message gjson.Result) []ack {
+	action := message.Get("action").String()
+	addresses := []string{}
+	if address := message.Get("doorlock_address"); address.Exists() {
+		if !g.doorlocks[address.String()] {
+			return nil
+		}
+		addresses = append(addresses, address.String())
+	} else {
+		addresses = g.addresses()
+	}
+
+	acks := []ack{}
+	for _, address := range addresses {
+		acks = append(acks, ack{mqttSvc.TOPIC_GW_DOORLOCK_U, mqttSvc.GatewayDoorlockPayload{
+			Version:   mqttSvc.CONTRACT_VERSION,
+			GatewayId: g.cfg.GatewayId,
+			Message:   mqttSvc.GatewayDoorlockMessage{DoorlockAddress: address, LockState: action},
+		}})
+	}
+	return acks
+}
+
+// Each revocation is acknowledged once
+func (g *Gateway) revokeAcks(revocations []gjson.Result) []ack {
+	acks := []ack{}
+	for _, r := range revocations {
+		id := r.Get("revocation_id").String()
+		if id == "" || g.acked[id] {
+			continue
+		}
+		g.acked[id] = true
+		revocationId, _ := strconv.ParseInt(id, 10, 64)
+		acks = append(acks, ack{mqttSvc.TOPIC_GW_CREDENTIAL_REVOKE_ACK, mqttSvc.GatewayRevokeAckPayload{
+			Version:   mqttSvc.CONTRACT_VERSION,
+			GatewayId: g.cfg.GatewayId,
+			Message:   mqttSvc.GatewayRevokeAckMessage{RevocationId: mqttSvc.FlexInt(revocationId)},
+		}})
+	}
+	return acks
+}
+
+func (g *Gateway) lastWillPayload() string {
+	b, _ := json.Marshal(mqttSvc.GatewayEnvelope{Version: mqttSvc.CONTRACT_VERSION, GatewayId: g.cfg.GatewayId})
+	return string(b)
+}
+
+func (g *Gateway) publish(topic string, payload interface{}) error {
+	if g.client == nil {
+		return fmt.Errorf("gateway %s is not started", g.cfg.GatewayId)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&g.published, 1)
+	t := g.client.Publish(topic, 1, false, b)
+	if !t.WaitTimeout(PUBLISH_TIMEOUT) {
+		return fmt.Errorf("publish on %s timed out", topic)
+	}
+	return t.Error()
+}
+
+func (g *Gateway) publishAsync(topic string, payload interface{}) {
+	b, _ := json.Marshal(payload)
+	atomic.AddInt64(&g.published, 1)
+	g.client.Publish(topic, 1, false, b)
+}
@@ -0,0 +1,179 @@
+//go:build unit
+// +build unit
+
+package gwsim
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/tidwall/gjson"
+)
+
+type doneToken struct{ done chan struct{} }
+
+func newDoneToken() *doneToken {
+	dt := &doneToken{done: make(chan struct{})}
+	close(dt.done)
+	return dt
+}
+
+func (dt *doneToken) Wait() bool                     { return true }
+func (dt *doneToken) WaitTimeout(time.Duration) bool { return true }
+func (dt *doneToken) Done() <-chan struct{}          { return dt.done }
+func (dt *doneToken) Error() error                   { return nil }
+
+type published struct {
+	topic   string
+	payload []byte
+}
+
+// Client recording publishes, server messages are delivered by the test
+type loopbackClient struct {
+	mqtt.Client
+	mu        sync.Mutex
+	handler   mqtt.MessageHandler
+	published []published
+}
+
+func (lc *loopbackClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	lc.handler = callback
+	return newDoneToken()
+}
+
+func (lc *loopbackClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.published = append(lc.published, published{topic, payload.([]byte)})
+	return newDoneToken()
+}
+
+func (lc *loopbackClient) deliver(topic string, payload string) {
+	lc.handler(lc, &serverMessage{topic: topic, payload: payload})
+}
+
+func (lc *loopbackClient) publishedOn(topic string) []published {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	pList := []published{}
+	for _, p := range lc.published {
+		if p.topic == topic {
+			pList = append(pList, p)
+		}
+	}
+	return pList
+}
+
+type serverMessage struct {
+	mqtt.Message
+	topic   string
+	payload string
+}
+
+func (sm *serverMessage) Topic() string   { return sm.topic }
+func (sm *serverMessage) Payload() []byte { return []byte(sm.payload) }
+
+func startGateway(t *testing.T) (*Gateway, *loopbackClient) {
+	t.Helper()
+	lc := &loopbackClient{}
+	g := NewGateway(NewConfig("gw-", 1, 2))
+	if err := g.Start(lc); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	return g, lc
+}
+
+func TestGatewayBootup(t *testing.T) {
+	_, lc := startGateway(t)
+
+	bootups := lc.publishedOn(mqttSvc.TOPIC_GW_BOOTUP)
+	if len(bootups) != 1 {
+		t.Fatalf("got %d bootups, wanted 1", len(bootups))
+	}
+	if _, err := mqttSvc.ValidatePayload(mqttSvc.TOPIC_GW_BOOTUP, bootups[0].payload); err != nil {
+		t.Errorf("bootup rejected: %v", err)
+	}
+	payload := bootups[0].payload
+	if got := gjson.GetBytes(payload, "gateway_id").String(); got != "gw-1" {
+		t.Errorf("got gateway %s, wanted gw-1", got)
+	}
+	if got := gjson.GetBytes(payload, "message.doorlocks.#").Int(); got != 2 {
+		t.Errorf("got %d doorlocks, wanted 2", got)
+	}
+}
+
+func TestGatewaySync(t *testing.T) {
+	g, lc := startGateway(t)
+
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_D, mqttSvc.ServerDeleteDoorlockPayload(&models.Doorlock{GatewayID: "gw-1", DoorlockAddress: "2"}))
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_C, mqttSvc.ServerCreateDoorlockPayload(&models.Doorlock{GatewayID: "gw-1", DoorlockAddress: "7"}))
+	// Other gateway
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_C, mqttSvc.ServerCreateDoorlockPayload(&models.Doorlock{GatewayID: "gw-2", DoorlockAddress: "8"}))
+	lc.deliver(mqttSvc.TOPIC_SV_SYSTEM_BOOTUP, mqttSvc.ServerBootupSystemPayload("gw-1", "server-key"))
+	lc.deliver(mqttSvc.TOPIC_SV_LASTWILL, mqttSvc.SERVER_SHUTDOWN_PAYLOAD)
+
+	if got := strings.Join(g.Doorlocks(), ","); got != "1,7" {
+		t.Errorf("got doorlocks %s, wanted 1,7", got)
+	}
+	if got := g.SecretKey(); got != "server-key" {
+		t.Errorf("got secret key %s, wanted server-key", got)
+	}
+	stats := g.Stats()
+	if stats.Received != 4 || stats.BootupSync <= 0 {
+		t.Errorf("got %+v, wanted 4 received and bootup synced", stats)
+	}
+	if msgList, err := g.WaitForMessages(mqttSvc.TOPIC_SV_DOORLOCK_C, 1, time.Second); err != nil || msgList[0].Get("doorlock_address") != "7" {
+		t.Errorf("got %v %v, wanted doorlock 7 created", msgList, err)
+	}
+	if _, err := g.WaitForMessages(mqttSvc.TOPIC_SV_DOORLOCK_U, 1, 10*time.Millisecond); err == nil {
+		t.Error("wait for a message never sent succeeded")
+	}
+}
+
+func TestGatewayAcks(t *testing.T) {
+	g, lc := startGateway(t)
+
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_CMD, mqttSvc.ServerCmdDoorlockPayload("gw-1", "1", &models.DoorlockCmd{State: "unlock"}))
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_CMD, mqttSvc.ServerCmdDoorlockPayload("gw-1", "9", &models.DoorlockCmd{State: "unlock"}))
+	lc.deliver(mqttSvc.TOPIC_SV_DOORLOCK_CMD, mqttSvc.ServerUpdateGatewayCmd("gw-1", "lock"))
+
+	updates := lc.publishedOn(mqttSvc.TOPIC_GW_DOORLOCK_U)
+	want := []string{"1:unlock", "1:lock", "2:lock"}
+	if len(updates) != len(want) {
+		t.Fatalf("got %d doorlock updates, wanted %d", len(updates), len(want))
+	}
+	for i, u := range updates {
+		got := gjson.GetBytes(u.payload, "message.doorlock_address").String() + ":" + gjson.GetBytes(u.payload, "message.doorlock_lock_state").String()
+		if got != want[i] {
+			t.Errorf("got update %s, wanted %s", got, want[i])
+		}
+		if _, err := mqttSvc.ValidatePayload(mqttSvc.TOPIC_GW_DOORLOCK_U, u.payload); err != nil {
+			t.Errorf("update rejected: %v", err)
+		}
+	}
+
+	r := &models.CredentialRevocation{UserID: "u1", Type: models.CREDENTIAL_TYPE_RFID, Value: "card"}
+	r.ID = 5
+	lc.deliver(mqttSvc.TOPIC_SV_CREDENTIAL_REVOKE, mqttSvc.ServerRevokeCredentialPayload("gw-1", r))
+	r2 := *r
+	r2.ID = 6
+	lc.deliver(mqttSvc.TOPIC_SV_BLACKLIST_BOOTUP, mqttSvc.ServerBootupBlacklistPayload("gw-1", []models.CredentialRevocation{*r, r2}))
+
+	acks := lc.publishedOn(mqttSvc.TOPIC_GW_CREDENTIAL_REVOKE_ACK)
+	if len(acks) != 2 {
+		t.Fatalf("got %d acks, wanted 2", len(acks))
+	}
+	for i, id := range []string{"5", "6"} {
+		if got := gjson.GetBytes(acks[i].payload, "message.revocation_id").String(); got != id {
+			t.Errorf("got ack of %s, wanted %s", got, id)
+		}
+	}
+	if got := g.Stats().Published; got != 6 {
+		t.Errorf("got %d published, wanted 6", got)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessGroupHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewAccessGroupHandler(deps *HandlerDependencies) *AccessGroupHandler {
+	return &AccessGroupHandler{
+		deps,
+	}
+}
+
+// Find all access groups
+// @Summary Find All Access Groups
+// @Schemes
+// @Description find all access groups
+// @Produce json
+// @Success 200 {array} []models.AccessGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroups [get]
+func (h *AccessGroupHandler) FindAllAccessGroup(c *gin.Context) {
+	agList, err := h.deps.SvcOpts.AccessGroupSvc.FindAllAccessGroup(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all access groups failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, agList)
+}
+
+// Find access group by id
+// @Summary Find Access Group By ID
+// @Schemes
+// @Description find access group with its members
+// @Produce json
+// @Param        id	path	string	true	"Access group ID"
+// @Success 200 {object} models.AccessGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup/{id} [get]
+func (h *AccessGroupHandler) FindAccessGroupByID(c *gin.Context) {
+	ag, err := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get access group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ag)
+}
+
+// Create access group
+// @Summary Create Access Group
+// @Schemes
+// @Description Create access group without members
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagCreateGroup	true	"Fields need to create an access group"
+// @Success 200 {object} models.AccessGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup [post]
+func (h *AccessGroupHandler) CreateAccessGroup(c *gin.Context) {
+	ag := &models.AccessGroup{}
+	err := c.ShouldBind(ag)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	ag.ID = 0
+
+	ag, err = h.deps.SvcOpts.AccessGroupSvc.CreateAccessGroup(c.Request.Context(), ag)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create access group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_ACCESS_GROUP, idString(ag.ID), nil, ag)
+	utils.ResponseJson(c, http.StatusOK, ag)
+}
+
+// Update access group
+// @Summary Update Access Group By ID
+// @Schemes
+// @Description Update name and description of access group, must have "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateGroup	true	"Fields need to update an access group"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup [patch]
+func (h *AccessGroupHandler) UpdateAccessGroup(c *gin.Context) {
+	ag := &models.AccessGroup{}
+	err := c.ShouldBind(ag)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), idString(ag.ID))
+	isSuccess, err := h.deps.SvcOpts.AccessGroupSvc.UpdateAccessGroup(c.Request.Context(), ag)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update access group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), idString(ag.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_ACCESS_GROUP, idString(ag.ID), before, after)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete access group
+// @Summary Delete Access Group By ID
+// @Schemes
+// @Description Delete access group using "id" field, groups used by an access policy can not be deleted
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Access group ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup [delete]
+func (h *AccessGroupHandler) DeleteAccessGroup(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), idString(dId.ID))
+	isSuccess, err := h.deps.SvcOpts.AccessGroupSvc.DeleteAccessGroup(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete access group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_ACCESS_GROUP, idString(dId.ID), before, nil)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Add access group members
+// @Summary Add Access Group Members
+// @Schemes
+// @Description Add people to access group. Registers of the new members are created and sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Access group ID"
+// @Param	data	body	models.GroupMemberIDs	true	"Person IDs"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup/{id}/members [post]
+func (h *AccessGroupHandler) AddAccessGroupMembers(c *gin.Context) {
+	h.changeMembers(c, h.deps.SvcOpts.AccessGroupSvc.AddAccessGroupMembers)
+}
+
+// Remove access group members
+// @Summary Remove Access Group Members
+// @Schemes
+// @Description Remove people from access group. Registers of the removed members are deleted and sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Access group ID"
+// @Param	data	body	models.GroupMemberIDs	true	"Person IDs"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessGroup/{id}/members [delete]
+func (h *AccessGroupHandler) RemoveAccessGroupMembers(c *gin.Context) {
+	h.changeMembers(c, h.deps.SvcOpts.AccessGroupSvc.RemoveAccessGroupMembers)
+}
+
+func (h *AccessGroupHandler) changeMembers(c *gin.Context, change groupChangeFunc) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid access group id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	ids := &models.GroupMemberIDs{}
+	err = c.ShouldBind(ids)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), c.Param("id"))
+	diff, err := change(c.Request.Context(), uint(id), ids.IDs)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Change access group members failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.AccessGroupSvc.FindAccessGroupByID(c.Request.Context(), c.Param("id"))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_ACCESS_GROUP, c.Param("id"), before, after)
+
+	if err := publishRegisterDiff(h.deps, c.Request.Context(), diff); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Change access group members mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, diff)
+}
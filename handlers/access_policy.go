@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessPolicyHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewAccessPolicyHandler(deps *HandlerDependencies) *AccessPolicyHandler {
+	return &AccessPolicyHandler{
+		deps,
+	}
+}
+
+// Find all access policies
+// @Summary Find All Access Policies
+// @Schemes
+// @Description find all access policies
+// @Produce json
+// @Success 200 {array} []models.AccessPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicies [get]
+func (h *AccessPolicyHandler) FindAllAccessPolicy(c *gin.Context) {
+	apList, err := h.deps.SvcOpts.AccessPolicySvc.FindAllAccessPolicy(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all access policies failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, apList)
+}
+
+// Find access policy by id
+// @Summary Find Access Policy By ID
+// @Schemes
+// @Description find access policy with its access group and door group
+// @Produce json
+// @Param        id	path	string	true	"Access policy ID"
+// @Success 200 {object} models.AccessPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicy/{id} [get]
+func (h *AccessPolicyHandler) FindAccessPolicyByID(c *gin.Context) {
+	ap, err := h.deps.SvcOpts.AccessPolicySvc.FindAccessPolicyByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get access policy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ap)
+}
+
+// Find registers of access policy
+// @Summary Find Access Policy Registers
+// @Schemes
+// @Description find scheduler registers generated from access policy
+// @Produce json
+// @Param        id	path	string	true	"Access policy ID"
+// @Success 200 {array} []models.Scheduler
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicy/{id}/registers [get]
+func (h *AccessPolicyHandler) FindAccessPolicyRegisters(c *gin.Context) {
+	sList, err := h.deps.SvcOpts.AccessPolicySvc.FindAccessPolicyRegisters(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get access policy registers failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, sList)
+}
+
+// Create access policy
+// @Summary Create Access Policy
+// @Schemes
+// @Description Create access policy, dates are dd/mm/yyyy. A register is created and sent to MQTT broker for every member of the access group and doorlock of the door group. With a "recurrence" rule (FREQ=DAILY|WEEKLY, INTERVAL, BYDAY) the "timeWindows" (HH:MM-HH:MM, comma separated) in "timeZone" are used instead of class periods
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagCreateAccessPolicy	true	"Fields need to create an access policy"
+// @Success 200 {object} models.AccessPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicy [post]
+func (h *AccessPolicyHandler) CreateAccessPolicy(c *gin.Context) {
+	ap := &models.AccessPolicy{}
+	err := c.ShouldBind(ap)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	ap.ID = 0
+
+	ap, diff, err := h.deps.SvcOpts.AccessPolicySvc.CreateAccessPolicy(c.Request.Context(), ap)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create access policy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_ACCESS_POLICY, idString(ap.ID), nil, ap)
+
+	if err := publishRegisterDiff(h.deps, c.Request.Context(), diff); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create access policy mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, ap)
+}
+
+// Update access policy
+// @Summary Update Access Policy By ID
+// @Schemes
+// @Description Update access policy, must have "id" field. Only changed registers are sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateAccessPolicy	true	"Fields need to update an access policy"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicy [patch]
+func (h *AccessPolicyHandler) UpdateAccessPolicy(c *gin.Context) {
+	ap := &models.AccessPolicy{}
+	err := c.ShouldBind(ap)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.AccessPolicySvc.FindAccessPolicyByID(c.Request.Context(), idString(ap.ID))
+	diff, err := h.deps.SvcOpts.AccessPolicySvc.UpdateAccessPolicy(c.Request.Context(), ap)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update access policy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.AccessPolicySvc.FindAccessPolicyByID(c.Request.Context(), idString(ap.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_ACCESS_POLICY, idString(ap.ID), before, after)
+
+	if err := publishRegisterDiff(h.deps, c.Request.Context(), diff); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update access policy mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, diff)
+}
+
+// Delete access policy
+// @Summary Delete Access Policy By ID
+// @Schemes
+// @Description Delete access policy using "id" field. Its registers are deleted and sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Access policy ID"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessPolicy [delete]
+func (h *AccessPolicyHandler) DeleteAccessPolicy(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.AccessPolicySvc.FindAccessPolicyByID(c.Request.Context(), idString(dId.ID))
+	diff, err := h.deps.SvcOpts.AccessPolicySvc.DeleteAccessPolicy(c.Request.Context(), dId.ID)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete access policy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_ACCESS_POLICY, idString(dId.ID), before, nil)
+
+	if err := publishRegisterDiff(h.deps, c.Request.Context(), diff); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete access policy mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, diff)
+}
+
+// Add or remove members of a group, returns the changed registers
+type groupChangeFunc func(ctx context.Context, id uint, ids []uint) (*models.RegisterDiff, error)
+
+// Send changed registers to the gateways of their doorlocks, each doorlock and person is loaded once
+func publishRegisterDiff(deps *HandlerDependencies, ctx context.Context, diff *models.RegisterDiff) error {
+	doorlocks := map[uint]*models.Doorlock{}
+	findDoorlock := func(id uint) *models.Doorlock {
+		if dl, ok := doorlocks[id]; ok {
+			return dl
+		}
+		dl, _ := deps.SvcOpts.DoorlockSvc.FindDoorlockByID(ctx, idString(id))
+		doorlocks[id] = dl
+		return dl
+	}
+	users := map[uint]*mqttSvc.UserIDPassword{}
+	findUser := func(personID uint) *mqttSvc.UserIDPassword {
+		if uP, ok := users[personID]; ok {
+			return uP
+		}
+		var uP *mqttSvc.UserIDPassword
+		if p, err := deps.SvcOpts.PersonSvc.FindPersonByID(ctx, idString(personID)); err == nil {
+			uP = mqttSvc.NewUserIDPassword(p.UserID, p)
+		}
+		users[personID] = uP
+		return uP
+	}
+	policies := map[uint]*models.AccessPolicy{}
+	// Nil unless the register belongs to a recurring policy, which is sent as version 2 register
+	findRecurringPolicy := func(sche *models.Scheduler) *models.AccessPolicy {
+		if sche.AccessPolicyID == nil {
+			return nil
+		}
+		ap, ok := policies[*sche.AccessPolicyID]
+		if !ok {
+			ap, _ = deps.SvcOpts.AccessPolicySvc.FindAccessPolicyByID(ctx, idString(*sche.AccessPolicyID))
+			policies[*sche.AccessPolicyID] = ap
+		}
+		if ap == nil || !ap.IsRecurring() {
+			return nil
+		}
+		return ap
+	}
+
+	for i := range diff.Created {
+		sche := &diff.Created[i]
+		dl := findDoorlock(sche.DoorID)
+		if dl == nil || sche.PersonID == nil {
+			continue
+		}
+		uP := findUser(*sche.PersonID)
+		if uP == nil {
+			continue
+		}
+		var t mqtt.Token
+		if ap := findRecurringPolicy(sche); ap != nil {
+			t = deps.MqttClient.Publish(mqttSvc.TOPIC_SV_REGISTER_V2_C, 1, false,
+				mqttSvc.ServerRegisterV2Payload(dl.GatewayID, mqttSvc.NewRegisterV2(dl.DoorlockAddress, sche, ap, uP)))
+		} else {
+			t = deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false,
+				mqttSvc.ServerCreateRegisterPayload(dl.GatewayID, dl.DoorlockAddress, sche, uP))
+		}
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	for i := range diff.Updated {
+		sche := &diff.Updated[i]
+		dl := findDoorlock(sche.DoorID)
+		if dl == nil {
+			continue
+		}
+		// Version 2 update carries the whole register, also when a policy becomes recurring
+		if ap := findRecurringPolicy(sche); ap != nil && sche.PersonID != nil {
+			uP := findUser(*sche.PersonID)
+			if uP == nil {
+				continue
+			}
+			t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_REGISTER_V2_U, 1, false,
+				mqttSvc.ServerRegisterV2Payload(dl.GatewayID, mqttSvc.NewRegisterV2(dl.DoorlockAddress, sche, ap, uP)))
+			if err := mqttSvc.HandleMqttErr(t); err != nil {
+				return err
+			}
+			continue
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_U, 1, false,
+			mqttSvc.ServerUpdateRegisterPayload(dl.GatewayID, &models.UpdateScheduler{
+				UserID:          sche.UserID,
+				DoorlockAddress: dl.DoorlockAddress,
+				Scheduler:       *sche,
+			}))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	for i := range diff.Deleted {
+		sche := &diff.Deleted[i]
+		// Doorlock deleted in the meantime, gateway dropped its registers already
+		dl := findDoorlock(sche.DoorID)
+		if dl == nil {
+			continue
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_D, 1, false,
+			mqttSvc.ServerDeleteRegisterPayload(dl.GatewayID, sche.ID))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -28,7 +28,7 @@ func NewAreaHandler(deps *HandlerDependencies) *AreaHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/areas [get]
 func (h *AreaHandler) FindAllArea(c *gin.Context) {
-	aList, err := h.deps.SvcOpts.AreaSvc.FindAllArea(c)
+	aList, err := h.deps.SvcOpts.AreaSvc.FindAllArea(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +52,7 @@ func (h *AreaHandler) FindAllArea(c *gin.Context) {
 func (h *AreaHandler) FindAreaByID(c *gin.Context) {
 	id := c.Param("id")
 
-	a, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(c, id)
+	a, err := h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -85,7 +85,7 @@ func (h *AreaHandler) CreateArea(c *gin.Context) {
 		})
 		return
 	}
-	a, err = h.deps.SvcOpts.AreaSvc.CreateArea(a, c.Request.Context())
+	a, err = h.deps.SvcOpts.AreaSvc.CreateArea(c.Request.Context(), a)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -94,6 +94,7 @@ func (h *AreaHandler) CreateArea(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_AREA, idString(a.ID), nil, a)
 	utils.ResponseJson(c, http.StatusOK, a)
 }
 
@@ -118,6 +119,7 @@ func (h *AreaHandler) UpdateArea(c *gin.Context) {
 		})
 		return
 	}
+	before, _ := h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), idString(a.ID))
 	isSuccess, err := h.deps.SvcOpts.AreaSvc.UpdateArea(c.Request.Context(), a)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -127,6 +129,8 @@ func (h *AreaHandler) UpdateArea(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), idString(a.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_AREA, idString(a.ID), before, after)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
@@ -152,6 +156,7 @@ func (h *AreaHandler) DeleteArea(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.AreaSvc.FindAreaByID(c.Request.Context(), idString(dId.ID))
 	isSuccess, err := h.deps.SvcOpts.AreaSvc.DeleteArea(c.Request.Context(), dId.ID)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -161,6 +166,7 @@ func (h *AreaHandler) DeleteArea(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_AREA, idString(dId.ID), before, nil)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 
 }
@@ -0,0 +1,83 @@
+//go:build unit
+// +build unit
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/gin-gonic/gin"
+)
+
+type mockAreaSvc struct {
+	models.AreaService
+	aList   []models.Area
+	findErr error
+}
+
+func (m *mockAreaSvc) FindAllArea(ctx context.Context) ([]models.Area, error) {
+	return m.aList, m.findErr
+}
+
+func (m *mockAreaSvc) CreateArea(ctx context.Context, a *models.Area) (*models.Area, error) {
+	a.ID = uint(len(m.aList) + 1)
+	m.aList = append(m.aList, *a)
+	return a, nil
+}
+
+type mockAuditLogSvc struct {
+	models.AuditLogService
+	alList []models.AuditLog
+}
+
+func (m *mockAuditLogSvc) CreateAuditLog(ctx context.Context, al *models.AuditLog) (*models.AuditLog, error) {
+	m.alList = append(m.alList, *al)
+	return al, nil
+}
+
+func newAreaRouter(svcOpts *models.ServiceOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewAreaHandler(&HandlerDependencies{SvcOpts: svcOpts})
+	r := gin.New()
+	r.GET("/v1/areas", h.FindAllArea)
+	r.POST("/v1/area", h.CreateArea)
+	return r
+}
+
+func TestAreaHandler(t *testing.T) {
+	areaSvc := &mockAreaSvc{}
+	auditSvc := &mockAuditLogSvc{}
+	r := newAreaRouter(&models.ServiceOptions{AreaSvc: areaSvc, AuditLogSvc: auditSvc})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/area", strings.NewReader(`{"name":"A","manager":"M"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, wanted %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if len(auditSvc.alList) != 1 || auditSvc.alList[0].EntityID != "1" || auditSvc.alList[0].Action != models.AUDIT_ACTION_CREATE {
+		t.Errorf("got audit logs %+v, wanted creation of area 1", auditSvc.alList)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/areas", nil))
+	var aList []models.Area
+	if err := json.Unmarshal(w.Body.Bytes(), &aList); err != nil || len(aList) != 1 || aList[0].Name != "A" {
+		t.Errorf("got %d %s, wanted area A", w.Code, w.Body.String())
+	}
+
+	areaSvc.findErr = errors.New("db down")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/areas", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "db down") {
+		t.Errorf("got %d %s, wanted failure", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	EXPORT_FORMAT_CSV   string = "csv"
+	EXPORT_FORMAT_JSONL string = "jsonl"
+)
+
+type AuditLogHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewAuditLogHandler(deps *HandlerDependencies) *AuditLogHandler {
+	return &AuditLogHandler{
+		deps,
+	}
+}
+
+// Find audit logs
+// @Summary Find Audit Logs
+// @Schemes
+// @Description find audit logs newest first, filter by query params
+// @Produce json
+// @Param	actor	query	string	false	"Actor"
+// @Param	action	query	string	false	"Action: create, update, delete, restore, command"
+// @Param	entityType	query	string	false	"Entity type"
+// @Param	entityId	query	string	false	"Entity ID"
+// @Param	correlationId	query	string	false	"Correlation ID"
+// @Param	from	query	int	false	"From time in unix seconds"
+// @Param	to	query	int	false	"To time in unix seconds"
+// @Param	limit	query	int	false	"Max records, default 100, max 1000"
+// @Param	offset	query	int	false	"Records to skip"
+// @Success 200 {array} []models.AuditLog
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/auditLogs [get]
+func (h *AuditLogHandler) FindAuditLogs(c *gin.Context) {
+	filter := &models.AuditLogFilter{}
+	if err := c.ShouldBindQuery(filter); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	alList, err := h.deps.SvcOpts.AuditLogSvc.FindAuditLogs(c.Request.Context(), filter)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get audit logs failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, alList)
+}
+
+// Export audit logs
+// @Summary Export Audit Logs
+// @Schemes
+// @Description export every matching audit log oldest first as CSV or JSON lines file, same filters as find audit logs without limit and offset
+// @Produce text/csv,application/x-ndjson
+// @Param	format	query	string	false	"csv (default) or jsonl"
+// @Param	actor	query	string	false	"Actor"
+// @Param	action	query	string	false	"Action"
+// @Param	entityType	query	string	false	"Entity type"
+// @Param	entityId	query	string	false	"Entity ID"
+// @Param	correlationId	query	string	false	"Correlation ID"
+// @Param	from	query	int	false	"From time in unix seconds"
+// @Param	to	query	int	false	"To time in unix seconds"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/auditLogs/export [get]
+func (h *AuditLogHandler) ExportAuditLogs(c *gin.Context) {
+	filter := &models.AuditLogFilter{}
+	if err := c.ShouldBindQuery(filter); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", EXPORT_FORMAT_CSV)
+	if format != EXPORT_FORMAT_CSV && format != EXPORT_FORMAT_JSONL {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid export format",
+			ErrorMsg:   fmt.Sprintf("format must be %s or %s", EXPORT_FORMAT_CSV, EXPORT_FORMAT_JSONL),
+		})
+		return
+	}
+
+	fileName := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+
+	var err error
+	if format == EXPORT_FORMAT_CSV {
+		c.Header("Content-Type", "text/csv")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "createdAt", "actor", "action", "entityType", "entityId", "before", "after", "diff", "sourceIp", "correlationId"})
+		err = h.deps.SvcOpts.AuditLogSvc.EachAuditLog(c.Request.Context(), filter, func(al *models.AuditLog) error {
+			return w.Write([]string{
+				strconv.FormatUint(uint64(al.ID), 10), al.CreatedAt.Format(time.RFC3339), al.Actor, al.Action,
+				al.EntityType, al.EntityID, al.Before, al.After, al.Diff, al.SourceIP, al.CorrelationID,
+			})
+		})
+		w.Flush()
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		err = h.deps.SvcOpts.AuditLogSvc.EachAuditLog(c.Request.Context(), filter, func(al *models.AuditLog) error {
+			return enc.Encode(al)
+		})
+	}
+	// Headers are already sent, only log the failure
+	if err != nil {
+		logger.LogfWithoutFields(logger.GINROUTER, logger.ErrorLevel, "Export audit logs failed: %s", err.Error())
+	}
+}
+
+// Record audit log for an administrative action, failures are logged and do not fail the request
+func (deps *HandlerDependencies) audit(c *gin.Context, action string, entityType string, entityID string, before interface{}, after interface{}) {
+	deps.auditAs(c, getActor(c), action, entityType, entityID, before, after)
+}
+
+// Audit log of an actor verified by the server instead of the X-Actor header
+func (deps *HandlerDependencies) auditAs(c *gin.Context, actor string, action string, entityType string, entityID string, before interface{}, after interface{}) {
+	al, err := models.NewAuditLog(action, entityType, entityID, before, after)
+	if err != nil {
+		logger.LogfWithoutFields(logger.GINROUTER, logger.ErrorLevel, "Build audit log for %s %s failed: %s", entityType, entityID, err.Error())
+		return
+	}
+	al.Actor = actor
+	al.SourceIP = c.ClientIP()
+	al.CorrelationID = getCorrelationID(c)
+	if _, err := deps.SvcOpts.AuditLogSvc.CreateAuditLog(c.Request.Context(), al); err != nil {
+		logger.LogfWithoutFields(logger.GINROUTER, logger.ErrorLevel, "Save audit log for %s %s failed: %s", entityType, entityID, err.Error())
+	}
+}
+
+func idString(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
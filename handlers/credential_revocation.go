@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type RevocationHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewRevocationHandler(deps *HandlerDependencies) *RevocationHandler {
+	return &RevocationHandler{
+		deps,
+	}
+}
+
+// Revoke credential
+// @Summary Revoke Credential By ID
+// @Schemes
+// @Description Blacklist lost or stolen credential and send the revocation to every gateway holding registers of its person. Optional replacement value gives the person a new credential of the same type
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Credential ID"
+// @Param	data	body	models.RevokeCredentialReq	true	"Revocation reason and replacement value"
+// @Success 200 {object} models.CredentialRevocation
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/credential/{id}/revoke [post]
+func (h *RevocationHandler) RevokeCredential(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid credential id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	req := &models.RevokeCredentialReq{}
+	err = c.ShouldBind(req)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, err := h.deps.SvcOpts.PersonSvc.FindCredentialByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	r, replacement, err := h.deps.SvcOpts.RevocationSvc.RevokeCredential(c.Request.Context(), uint(id), req, getActor(c))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Revoke credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.PersonSvc.FindCredentialByID(c.Request.Context(), c.Param("id"))
+	h.deps.audit(c, models.AUDIT_ACTION_REVOKE, models.AUDIT_ENTITY_CREDENTIAL, c.Param("id"), before, after)
+	if replacement != nil {
+		h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CREDENTIAL, idString(replacement.ID), nil, replacement)
+	}
+
+	if err := publishRevocation(h.deps, r); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Revoke credential mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	if err := publishPersonCredentials(h.deps, c.Request.Context(), r.PersonID); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update credential mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, r)
+}
+
+// Find all revocations
+// @Summary Find All Credential Revocations
+// @Schemes
+// @Description find credential revocations with acknowledgement of each gateway, pending=true keeps those not confirmed by every gateway
+// @Produce json
+// @Param        pending	query	bool	false	"Only revocations waiting for gateways"
+// @Success 200 {array} []models.CredentialRevocation
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/revocations [get]
+func (h *RevocationHandler) FindAllRevocation(c *gin.Context) {
+	pendingOnly, _ := strconv.ParseBool(c.Query("pending"))
+	rList, err := h.deps.SvcOpts.RevocationSvc.FindAllRevocation(c.Request.Context(), pendingOnly)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all revocations failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rList)
+}
+
+// Find revocation by id
+// @Summary Find Credential Revocation By ID
+// @Schemes
+// @Description find credential revocation and acknowledgement of each gateway
+// @Produce json
+// @Param        id	path	string	true	"Revocation ID"
+// @Success 200 {object} models.CredentialRevocation
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/revocation/{id} [get]
+func (h *RevocationHandler) FindRevocationByID(c *gin.Context) {
+	r, err := h.deps.SvcOpts.RevocationSvc.FindRevocationByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get revocation failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, r)
+}
+
+// Resend revocation
+// @Summary Resend Credential Revocation
+// @Schemes
+// @Description Send revocation again to gateways which have not acknowledged it yet
+// @Produce json
+// @Param        id	path	string	true	"Revocation ID"
+// @Success 200 {object} models.CredentialRevocation
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/revocation/{id}/resend [post]
+func (h *RevocationHandler) ResendRevocation(c *gin.Context) {
+	r, err := h.deps.SvcOpts.RevocationSvc.FindRevocationByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get revocation failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	if err := publishRevocation(h.deps, r); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Resend revocation mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_CREDENTIAL, idString(r.CredentialID), nil, r)
+
+	utils.ResponseJson(c, http.StatusOK, r)
+}
+
+// Send revocation to every gateway which has not acknowledged it
+func publishRevocation(deps *HandlerDependencies, r *models.CredentialRevocation) error {
+	for _, ack := range r.Acks {
+		if ack.AckedAt != nil {
+			continue
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_CREDENTIAL_REVOKE, 1, false,
+			mqttSvc.ServerRevokeCredentialPayload(ack.GatewayID, r))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
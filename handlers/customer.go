@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/ecoprohcm/DMS_BackendServer/models"
@@ -28,7 +29,7 @@ func NewCustomerHandler(deps *HandlerDependencies) *CustomerHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/customers [get]
 func (h *CustomerHandler) FindAllCustomer(c *gin.Context) {
-	sList, err := h.deps.SvcOpts.CustomerSvc.FindAllCustomer(c)
+	sList, err := h.deps.SvcOpts.CustomerSvc.FindAllCustomer(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +53,7 @@ func (h *CustomerHandler) FindAllCustomer(c *gin.Context) {
 func (h *CustomerHandler) FindCustomerByCCCD(c *gin.Context) {
 	cccd := c.Param("cccd")
 
-	cus, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c, cccd)
+	cus, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), cccd)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -73,6 +74,7 @@ func (h *CustomerHandler) FindCustomerByCCCD(c *gin.Context) {
 // @Param	data	body	models.SwagCreateCustomer	true	"Fields need to create a customer"
 // @Success 200 {object} models.Customer
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
 // @Router /v1/customer [post]
 func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 	cus := &models.Customer{}
@@ -88,13 +90,18 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 
 	_, err = h.deps.SvcOpts.CustomerSvc.CreateCustomer(c.Request.Context(), cus)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
+		code := http.StatusBadRequest
+		if errors.Is(err, models.ErrDeletedRecord) {
+			code = http.StatusConflict
+		}
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
 			Msg:        "Create customer failed",
 			ErrorMsg:   err.Error(),
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CUSTOMER, cus.CCCD, nil, cus)
 	utils.ResponseJson(c, http.StatusOK, cus)
 }
 
@@ -120,6 +127,7 @@ func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), cus.CCCD)
 	isSuccess, err := h.deps.SvcOpts.CustomerSvc.UpdateCustomer(c.Request.Context(), cus)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -129,8 +137,18 @@ func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
 		})
 		return
 	}
+	after, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), cus.CCCD)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get customer failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_CUSTOMER, cus.CCCD, before, after)
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_U, 1, false,
-		mqttSvc.ServerUpdateUserPayload("0", cus.CCCD, cus.RfidPass, cus.KeypadPass))
+		mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(cus.CCCD, after.Person)))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -165,7 +183,8 @@ func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
 		return
 	}
 
-	isSuccess, err := h.deps.SvcOpts.CustomerSvc.DeleteCustomer(c.Request.Context(), dcus.CCCD)
+	before, _ := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), dcus.CCCD)
+	isSuccess, err := h.deps.SvcOpts.CustomerSvc.DeleteCustomer(c.Request.Context(), dcus.CCCD, getActor(c))
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -174,6 +193,7 @@ func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_CUSTOMER, dcus.CCCD, before, nil)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_D, 1, false,
 		mqttSvc.ServerDeleteUserPayload("0", dcus.CCCD))
@@ -189,6 +209,53 @@ func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
+// Restore customer
+// @Summary Restore Deleted Customer By CCCD
+// @Schemes
+// @Description Restore soft deleted customer. Send credentials and registers of the customer to MQTT broker
+// @Produce json
+// @Param        cccd	path	string	true	"Customer CCCD"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/customer/{cccd}/restore [post]
+func (h *CustomerHandler) RestoreCustomer(c *gin.Context) {
+	cccd := c.Param("cccd")
+
+	isSuccess, err := h.deps.SvcOpts.CustomerSvc.RestoreCustomer(c.Request.Context(), cccd)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore customer failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	cus, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), cccd)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get customer failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_RESTORE, models.AUDIT_ENTITY_CUSTOMER, cus.CCCD, nil, cus)
+
+	userTopic := mqttSvc.TOPIC_SV_USER_U
+	err = publishUserAccess(h.deps, c.Request.Context(), mqttSvc.NewUserIDPassword(cus.CCCD, cus.Person), userTopic, cus.Schedulers)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore customer mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
 // Add customer scheduler
 // @Summary Add Door Open Scheduler For Customer
 // @Schemes
@@ -212,7 +279,7 @@ func (h *CustomerHandler) AppendCustomerScheduler(c *gin.Context) {
 		return
 	}
 
-	cus, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c, cccd)
+	cus, err := h.deps.SvcOpts.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), cccd)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -226,7 +293,7 @@ func (h *CustomerHandler) AppendCustomerScheduler(c *gin.Context) {
 	sche.CustomerID = &cus.CCCD
 	sche.Role = "customer"
 	sche.UserID = cus.CCCD
-	_, err = h.deps.SvcOpts.SchedulerSvc.CreateScheduler(c, sche)
+	_, err = h.deps.SvcOpts.SchedulerSvc.CreateScheduler(c.Request.Context(), sche)
 
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -236,16 +303,13 @@ func (h *CustomerHandler) AppendCustomerScheduler(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULER, idString(sche.ID), nil, sche)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false, mqttSvc.ServerCreateRegisterPayload(
 		usu.GatewayID,
 		usu.DoorlockAddress,
 		sche,
-		&mqttSvc.UserIDPassword{
-			UserId:     cus.CCCD,
-			RfidPass:   cus.RfidPass,
-			KeypadPass: cus.KeypadPass,
-		},
+		mqttSvc.NewUserIDPassword(cus.CCCD, cus.Person),
 	))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -268,3 +332,79 @@ func (h *CustomerHandler) AppendCustomerScheduler(c *gin.Context) {
 
 	utils.ResponseJson(c, http.StatusOK, true)
 }
+
+// Import customers
+// @Summary Import Customers From CSV Or XLSX
+// @Schemes
+// @Description Create or update customers keyed on CCCD, only columns in the file are updated. Columns: cccd, name, phone, rfidPass, keypadPass. Returns result of every row. Send updated credentials to MQTT broker in batches
+// @Accept  multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"CSV or XLSX file, first row is header"
+// @Param	format	query	string	false	"csv or xlsx, taken from file extension when missing"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/customers/import [post]
+func (h *CustomerHandler) ImportCustomers(c *gin.Context) {
+	rows, err := readImportSheet(c)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	fields, sheetRows, err := models.ParseUserSheet(rows, models.CustomerSheetColumns)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	ir := h.deps.SvcOpts.CustomerSvc.ImportCustomers(c.Request.Context(), fields, sheetRows)
+	h.deps.auditImport(c, models.AUDIT_ENTITY_CUSTOMER, ir)
+
+	users := []mqttSvc.UserIDPassword{}
+	for _, rr := range ir.Rows {
+		if rr.Result != models.IMPORT_RESULT_UPDATED {
+			continue
+		}
+		cus := rr.After.(*models.Customer)
+		users = append(users, *mqttSvc.NewUserIDPassword(cus.CCCD, cus.Person))
+	}
+	if err := publishUserBatch(h.deps, users); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Import customers mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, ir)
+}
+
+// Export customers
+// @Summary Export Customers To CSV Or XLSX
+// @Schemes
+// @Description Export all customers in the same format as import
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param	format	query	string	false	"csv (default) or xlsx"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/customers/export [get]
+func (h *CustomerHandler) ExportCustomers(c *gin.Context) {
+	cList, err := h.deps.SvcOpts.CustomerSvc.FindAllCustomer(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all customers failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	responseExportSheet(c, "customers", models.CustomerSheet(cList))
+}
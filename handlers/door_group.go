@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type DoorGroupHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewDoorGroupHandler(deps *HandlerDependencies) *DoorGroupHandler {
+	return &DoorGroupHandler{
+		deps,
+	}
+}
+
+// Find all door groups
+// @Summary Find All Door Groups
+// @Schemes
+// @Description find all door groups
+// @Produce json
+// @Success 200 {array} []models.DoorGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroups [get]
+func (h *DoorGroupHandler) FindAllDoorGroup(c *gin.Context) {
+	dgList, err := h.deps.SvcOpts.DoorGroupSvc.FindAllDoorGroup(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all door groups failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dgList)
+}
+
+// Find door group by id
+// @Summary Find Door Group By ID
+// @Schemes
+// @Description find door group with its doorlocks
+// @Produce json
+// @Param        id	path	string	true	"Door group ID"
+// @Success 200 {object} models.DoorGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup/{id} [get]
+func (h *DoorGroupHandler) FindDoorGroupByID(c *gin.Context) {
+	dg, err := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get door group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dg)
+}
+
+// Create door group
+// @Summary Create Door Group
+// @Schemes
+// @Description Create door group without doorlocks
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagCreateGroup	true	"Fields need to create an door group"
+// @Success 200 {object} models.DoorGroup
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup [post]
+func (h *DoorGroupHandler) CreateDoorGroup(c *gin.Context) {
+	dg := &models.DoorGroup{}
+	err := c.ShouldBind(dg)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	dg.ID = 0
+
+	dg, err = h.deps.SvcOpts.DoorGroupSvc.CreateDoorGroup(c.Request.Context(), dg)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create door group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_DOOR_GROUP, idString(dg.ID), nil, dg)
+	utils.ResponseJson(c, http.StatusOK, dg)
+}
+
+// Update door group
+// @Summary Update Door Group By ID
+// @Schemes
+// @Description Update name and description of door group, must have "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateGroup	true	"Fields need to update an door group"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup [patch]
+func (h *DoorGroupHandler) UpdateDoorGroup(c *gin.Context) {
+	dg := &models.DoorGroup{}
+	err := c.ShouldBind(dg)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), idString(dg.ID))
+	isSuccess, err := h.deps.SvcOpts.DoorGroupSvc.UpdateDoorGroup(c.Request.Context(), dg)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update door group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), idString(dg.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_DOOR_GROUP, idString(dg.ID), before, after)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete door group
+// @Summary Delete Door Group By ID
+// @Schemes
+// @Description Delete door group using "id" field, groups used by an access policy can not be deleted
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Door group ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup [delete]
+func (h *DoorGroupHandler) DeleteDoorGroup(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), idString(dId.ID))
+	isSuccess, err := h.deps.SvcOpts.DoorGroupSvc.DeleteDoorGroup(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete door group failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOOR_GROUP, idString(dId.ID), before, nil)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Add door group doorlocks
+// @Summary Add Door Group Doorlocks
+// @Schemes
+// @Description Add doorlocks to door group. Registers of the new doorlocks are created and sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Door group ID"
+// @Param	data	body	models.GroupMemberIDs	true	"Doorlock IDs"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup/{id}/doorlocks [post]
+func (h *DoorGroupHandler) AddDoorGroupDoorlocks(c *gin.Context) {
+	h.changeDoorlocks(c, h.deps.SvcOpts.DoorGroupSvc.AddDoorGroupDoorlocks)
+}
+
+// Remove door group doorlocks
+// @Summary Remove Door Group Doorlocks
+// @Schemes
+// @Description Remove doorlocks from door group. Registers of the removed doorlocks are deleted and sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Door group ID"
+// @Param	data	body	models.GroupMemberIDs	true	"Doorlock IDs"
+// @Success 200 {object} models.RegisterDiff
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorGroup/{id}/doorlocks [delete]
+func (h *DoorGroupHandler) RemoveDoorGroupDoorlocks(c *gin.Context) {
+	h.changeDoorlocks(c, h.deps.SvcOpts.DoorGroupSvc.RemoveDoorGroupDoorlocks)
+}
+
+func (h *DoorGroupHandler) changeDoorlocks(c *gin.Context, change groupChangeFunc) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid door group id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	ids := &models.GroupMemberIDs{}
+	err = c.ShouldBind(ids)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), c.Param("id"))
+	diff, err := change(c.Request.Context(), uint(id), ids.IDs)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Change door group doorlocks failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.DoorGroupSvc.FindDoorGroupByID(c.Request.Context(), c.Param("id"))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_DOOR_GROUP, c.Param("id"), before, after)
+
+	if err := publishRegisterDiff(h.deps, c.Request.Context(), diff); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Change door group doorlocks mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, diff)
+}
@@ -28,7 +28,7 @@ func NewDoorlockHandler(deps *HandlerDependencies) *DoorlockHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/doorlocks [get]
 func (h *DoorlockHandler) FindAllDoorlock(c *gin.Context) {
-	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlock(c)
+	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlock(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +52,7 @@ func (h *DoorlockHandler) FindAllDoorlock(c *gin.Context) {
 func (h *DoorlockHandler) FindDoorlockByID(c *gin.Context) {
 	id := c.Param("id")
 
-	dl, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(c, id)
+	dl, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -115,6 +115,7 @@ func (h *DoorlockHandler) CreateDoorlock(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_DOORLOCK, idString(dl.ID), nil, dl)
 	utils.ResponseJson(c, http.StatusOK, dl)
 
 }
@@ -152,6 +153,7 @@ func (h *DoorlockHandler) UpdateDoorlock(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByAddress(c.Request.Context(), dl.DoorlockAddress, dl.GatewayID)
 	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.UpdateDoorlock(c.Request.Context(), dl)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -161,18 +163,26 @@ func (h *DoorlockHandler) UpdateDoorlock(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByAddress(c.Request.Context(), dl.DoorlockAddress, dl.GatewayID)
+	if after != nil {
+		h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_DOORLOCK, idString(after.ID), before, after)
+	}
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
 // Update doorlock state
 // @Summary Update Doorlock State By ID
 // @Schemes
-// @Description Update doorlock state, must have "id" field. Send updated info to MQTT broker
+// @Description Update doorlock state, must have "id" field. Send updated info to MQTT broker. Unlocking a doorlock requiring approval creates a pending unlock request instead
 // @Accept  json
 // @Produce json
 // @Param	data	body	models.SwaggerDoorlockCmd	true	"Fields need to update a doorlock state"
+// @Param        X-Approver-Token	header	string	false	"Approver token, required when the command waits for approval"
 // @Success 200 {boolean} true
+// @Success 202 {object} models.UnlockRequest
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
 // @Router /v1/doorlock/cmd [patch]
 func (h *DoorlockHandler) UpdateDoorlockCmd(c *gin.Context) {
 	dl := &models.DoorlockCmd{}
@@ -195,6 +205,19 @@ func (h *DoorlockHandler) UpdateDoorlockCmd(c *gin.Context) {
 		})
 		return
 	}
+	if checkDL.RequiresApproval && models.IsUnlockCommand(dl.State) {
+		h.deps.requestUnlockApproval(c, &models.UnlockRequest{
+			Command:  models.UNLOCK_CMD_DOORLOCK,
+			DoorID:   &checkDL.ID,
+			State:    dl.State,
+			Duration: dl.Duration,
+			Reason:   dl.Reason,
+		})
+		return
+	}
+	if !h.deps.requireMqttConnection(c) {
+		return
+	}
 
 	t := h.deps.MqttClient.Publish(string(mqttSvc.TOPIC_SV_DOORLOCK_CMD), 1, false,
 		mqttSvc.ServerCmdDoorlockPayload(checkDL.GatewayID, checkDL.DoorlockAddress, dl))
@@ -206,6 +229,7 @@ func (h *DoorlockHandler) UpdateDoorlockCmd(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_DOORLOCK, dl.ID, nil, dl)
 
 	// TODO: Guarantee mqtt req/res
 	// isMqttReps := waitForMqttDoorlockResponse(c, 60)
@@ -285,7 +309,7 @@ func (h *DoorlockHandler) DeleteDoorlock(c *gin.Context) {
 	// 	return
 	// }
 
-	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.DeleteDoorlock(c.Request.Context(), dl.ID)
+	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.DeleteDoorlock(c.Request.Context(), dl.ID, getActor(c))
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -294,10 +318,56 @@ func (h *DoorlockHandler) DeleteDoorlock(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOORLOCK, dl.ID, checkDL, nil)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 
 }
 
+// Restore doorlock
+// @Summary Restore Deleted Doorlock By ID
+// @Schemes
+// @Description Restore soft deleted doorlock. Send doorlock and its registers to MQTT broker
+// @Produce json
+// @Param        id	path	string	true	"Doorlock ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorlock/{id}/restore [post]
+func (h *DoorlockHandler) RestoreDoorlock(c *gin.Context) {
+	id := c.Param("id")
+
+	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.RestoreDoorlock(c.Request.Context(), id)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore doorlock failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	dl, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(c.Request.Context(), id)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Find doorlock fail",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_RESTORE, models.AUDIT_ENTITY_DOORLOCK, id, nil, dl)
+
+	if err := publishDoorlockAccess(h.deps, dl); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore doorlock mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
 // Get doorlock status by id
 // @Summary Get Doorlock Status By ID
 // @Schemes
@@ -309,7 +379,7 @@ func (h *DoorlockHandler) DeleteDoorlock(c *gin.Context) {
 // @Router /v1/doorlock/status/{id} [get]
 func (h *DoorlockHandler) GetDoorlockStatusByID(c *gin.Context) {
 	id := c.Param("id")
-	dl, err := h.deps.SvcOpts.DoorlockSvc.GetDoorlockStatusByID(c, id)
+	dl, err := h.deps.SvcOpts.DoorlockSvc.GetDoorlockStatusByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -325,12 +395,16 @@ func (h *DoorlockHandler) GetDoorlockStatusByID(c *gin.Context) {
 // Send command lock/unlock forever to Doorlock and update doorlock's lock state
 // @Summary Send command lock/unlock forever to Doorlock and update doorlock's lock state
 // @Schemes
-// @Description Send command lock/unlock forever to Doorlock by serialID and save its lock state
+// @Description Send command lock/unlock forever to Doorlock by serialID and save its lock state. Unlocking a doorlock requiring approval creates a pending unlock request instead
 // @Accept  json
 // @Produce json
 // @Param	data	body	models.DoorlockCmd	true	"Fields need to update a doorlock state"
+// @Param        X-Approver-Token	header	string	false	"Approver token, required when the command waits for approval"
 // @Success 200 {boolean} true
+// @Success 202 {object} models.UnlockRequest
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
 // @Router /v1/doorlock/state/cmd [patch]
 func (h *DoorlockHandler) UpdateDoorlockStateCmd(c *gin.Context) {
 	dl := &models.DoorlockCmd{}
@@ -355,6 +429,19 @@ func (h *DoorlockHandler) UpdateDoorlockStateCmd(c *gin.Context) {
 	}
 
 	dl.Duration = ""
+	if checkDL.RequiresApproval && models.IsUnlockCommand(dl.State) {
+		h.deps.requestUnlockApproval(c, &models.UnlockRequest{
+			Command:  models.UNLOCK_CMD_DOORLOCK_STATE,
+			DoorID:   &checkDL.ID,
+			State:    dl.State,
+			Duration: dl.Duration,
+			Reason:   dl.Reason,
+		})
+		return
+	}
+	if !h.deps.requireMqttConnection(c) {
+		return
+	}
 	t := h.deps.MqttClient.Publish(string(mqttSvc.TOPIC_SV_DOORLOCK_CMD), 1, false,
 		mqttSvc.ServerCmdDoorlockPayload(checkDL.GatewayID, checkDL.DoorlockAddress, dl))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
@@ -365,6 +452,7 @@ func (h *DoorlockHandler) UpdateDoorlockStateCmd(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_DOORLOCK, dl.ID, nil, dl)
 
 	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.UpdateDoorlockStateCmd(c.Request.Context(), dl)
 	if err != nil || !isSuccess {
@@ -29,7 +29,7 @@ func NewDoorlockStatusLogHandler(deps *HandlerDependencies) *DoorlockStatusLogHa
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/doorlockStatusLogs [get]
 func (h *DoorlockStatusLogHandler) GetAllDoorlockStatusLogs(c *gin.Context) {
-	dlslList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetAllDoorlockStatusLogs(c)
+	dlslList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetAllDoorlockStatusLogs(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +52,7 @@ func (h *DoorlockStatusLogHandler) GetAllDoorlockStatusLogs(c *gin.Context) {
 // @Router /v1/doorlockStatusLog/{doorId} [get]
 func (h *DoorlockStatusLogHandler) GetDoorlockStatusLogByDoorID(c *gin.Context) {
 	doorId := c.Param("doorId")
-	gl, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetDoorlockStatusLogByDoorID(c, doorId)
+	gl, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetDoorlockStatusLogByDoorID(c.Request.Context(), doorId)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -79,9 +79,7 @@ func (h *DoorlockStatusLogHandler) GetDoorlockStatusLogInTimeRange(c *gin.Contex
 	to := c.Param("toTime")
 	fromInt, _ := strconv.ParseInt(from, 10, 64)
 	toInt, _ := strconv.ParseInt(to, 10, 64)
-	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	dlslList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetDoorlockStatusLogInTimeRange(fromFormatted, toFormatted)
+	dlslList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.GetDoorlockStatusLogInTimeRange(c.Request.Context(), time.Unix(fromInt, 0), time.Unix(toInt, 0))
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -107,16 +105,16 @@ func (h *DoorlockStatusLogHandler) DeleteDoorlockStatusLogInTimeRange(c *gin.Con
 	to := c.Param("toTime")
 	fromInt, _ := strconv.ParseInt(from, 10, 64)
 	toInt, _ := strconv.ParseInt(to, 10, 64)
-	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	isSuccess, err := h.deps.SvcOpts.DoorlockStatusLogSvc.DeleteDoorlockStatusLogInTimeRange(fromFormatted, toFormatted)
+	isSuccess, err := h.deps.SvcOpts.DoorlockStatusLogSvc.DeleteDoorlockStatusLogInTimeRange(c.Request.Context(), time.Unix(fromInt, 0), time.Unix(toInt, 0))
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
 			Msg:        "Failed to delete doorlock status logs",
 			ErrorMsg:   err.Error(),
 		})
+		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOORLOCK_STATUS_LOG, from+"-"+to, nil, nil)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
@@ -131,13 +129,162 @@ func (h *DoorlockStatusLogHandler) DeleteDoorlockStatusLogInTimeRange(c *gin.Con
 // @Router /v1/doorlockStatusLog/door/:id [delete]
 func (h *DoorlockStatusLogHandler) DeleteDoorlockStatusLogByDoorID(c *gin.Context) {
 	doorId := c.Param("id")
-	isSuccess, err := h.deps.SvcOpts.DoorlockStatusLogSvc.DeleteDoorlockStatusLogByDoorID(doorId)
+	isSuccess, err := h.deps.SvcOpts.DoorlockStatusLogSvc.DeleteDoorlockStatusLogByDoorID(c.Request.Context(), doorId)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
 			Msg:        "Failed to delete doorlock status logs",
 			ErrorMsg:   err.Error(),
 		})
+		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOORLOCK_STATUS_LOG, doorId, nil, nil)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
+
+// Find typed status events of doorlock
+// @Summary Find Doorlock Status Events By DoorID
+// @Schemes
+// @Description find connect, door and lock events of doorlock oldest first, default range is the last 7 days, default limit 100 and max 1000
+// @Produce json
+// @Param        doorId	path	int	true	"Doorlock ID"
+// @Param	from	query	int	false	"From unix seconds"
+// @Param	to	query	int	false	"To unix seconds"
+// @Param	limit	query	int	false	"Limit"
+// @Success 200 {array} []models.DoorlockStatusEvent
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorlockStatusEvents/{doorId} [get]
+func (h *DoorlockStatusLogHandler) FindDoorlockStatusEvents(c *gin.Context) {
+	doorID, q, ok := bindDoorlockStatsQuery(c)
+	if !ok {
+		return
+	}
+	dlseList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.FindDoorlockStatusEvents(c.Request.Context(), doorID, q)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get doorlock status events failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlseList)
+}
+
+// Find statistics of all doorlocks
+// @Summary Find All Doorlock Stats
+// @Schemes
+// @Description find open count, mean open duration and disconnected time of every doorlock with events, most disconnected first. Default range is the last 7 days
+// @Produce json
+// @Param	from	query	int	false	"From unix seconds"
+// @Param	to	query	int	false	"To unix seconds"
+// @Success 200 {array} []models.DoorlockStats
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorlockStats [get]
+func (h *DoorlockStatusLogHandler) FindAllDoorlockStats(c *gin.Context) {
+	q := &models.DoorlockStatsQuery{}
+	if err := c.ShouldBindQuery(q); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	dsList, err := h.deps.SvcOpts.DoorlockStatusLogSvc.FindAllDoorlockStats(c.Request.Context(), q)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all doorlock stats failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dsList)
+}
+
+// Find statistics of doorlock
+// @Summary Find Doorlock Stats By DoorID
+// @Schemes
+// @Description find open count, mean open duration and disconnected time of doorlock with a series per bucket (hour, day or week, default day) in the time zone. The bucket is widened when the range has more than 400 of them
+// @Produce json
+// @Param        doorId	path	int	true	"Doorlock ID"
+// @Param	from	query	int	false	"From unix seconds"
+// @Param	to	query	int	false	"To unix seconds"
+// @Param	bucket	query	string	false	"hour, day or week"
+// @Param	timeZone	query	string	false	"Time zone of the buckets, default Asia/Ho_Chi_Minh"
+// @Success 200 {object} models.DoorlockStats
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/doorlockStats/{doorId} [get]
+func (h *DoorlockStatusLogHandler) FindDoorlockStats(c *gin.Context) {
+	doorID, q, ok := bindDoorlockStatsQuery(c)
+	if !ok {
+		return
+	}
+	ds, err := h.deps.SvcOpts.DoorlockStatusLogSvc.FindDoorlockStats(c.Request.Context(), doorID, q)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get doorlock stats failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ds)
+}
+
+// Find busiest hours of room
+// @Summary Find Room Busiest Hours
+// @Schemes
+// @Description find door openings of the room doorlocks per hour of day in the time zone, busiest first. Default range is the last 7 days
+// @Produce json
+// @Param        roomId	path	string	true	"Room ID of the doorlocks"
+// @Param	from	query	int	false	"From unix seconds"
+// @Param	to	query	int	false	"To unix seconds"
+// @Param	timeZone	query	string	false	"Time zone of the hours, default Asia/Ho_Chi_Minh"
+// @Success 200 {object} models.RoomBusiestHours
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/roomStats/{roomId}/busiestHours [get]
+func (h *DoorlockStatusLogHandler) FindRoomBusiestHours(c *gin.Context) {
+	q := &models.DoorlockStatsQuery{}
+	if err := c.ShouldBindQuery(q); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	rbh, err := h.deps.SvcOpts.DoorlockStatusLogSvc.FindRoomBusiestHours(c.Request.Context(), c.Param("roomId"), q)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get room busiest hours failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rbh)
+}
+
+func bindDoorlockStatsQuery(c *gin.Context) (uint, *models.DoorlockStatsQuery, bool) {
+	doorID, err := strconv.ParseUint(c.Param("doorId"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid doorlock id",
+			ErrorMsg:   err.Error(),
+		})
+		return 0, nil, false
+	}
+	q := &models.DoorlockStatsQuery{}
+	if err := c.ShouldBindQuery(q); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return 0, nil, false
+	}
+	return uint(doorID), q, true
+}
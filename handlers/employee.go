@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
@@ -28,7 +30,7 @@ func NewEmployeeHandler(deps *HandlerDependencies) *EmployeeHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/employees [get]
 func (h *EmployeeHandler) FindAllEmployee(c *gin.Context) {
-	eList, err := h.deps.SvcOpts.EmployeeSvc.FindAllEmployee(c)
+	eList, err := h.deps.SvcOpts.EmployeeSvc.FindAllEmployee(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +54,7 @@ func (h *EmployeeHandler) FindAllEmployee(c *gin.Context) {
 func (h *EmployeeHandler) FindEmployeeByMSNV(c *gin.Context) {
 	msnv := c.Param("msnv")
 
-	emp, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c, msnv)
+	emp, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c.Request.Context(), msnv)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -73,6 +75,7 @@ func (h *EmployeeHandler) FindEmployeeByMSNV(c *gin.Context) {
 // @Param	data	body	models.SwagCreateEmployee	true	"Fields need to create a employee"
 // @Success 200 {object} models.Employee
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
 // @Router /v1/employee [post]
 func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 	emp := &models.Employee{}
@@ -88,17 +91,22 @@ func (h *EmployeeHandler) CreateEmployee(c *gin.Context) {
 
 	_, err = h.deps.SvcOpts.EmployeeSvc.CreateEmployee(c.Request.Context(), emp)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
+		code := http.StatusBadRequest
+		if errors.Is(err, models.ErrDeletedRecord) {
+			code = http.StatusConflict
+		}
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
 			Msg:        "Create employee failed",
 			ErrorMsg:   err.Error(),
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_EMPLOYEE, emp.MSNV, nil, emp)
 
 	if emp.HighestPriority {
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_C, 1, false,
-			mqttSvc.ServerUpdateUserPayload("0", emp.MSNV, emp.RfidPass, emp.KeypadPass))
+			mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person)))
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
@@ -154,10 +162,20 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 		})
 		return
 	}
+	after, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c.Request.Context(), reqEmp.MSNV)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get employee failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_EMPLOYEE, reqEmp.MSNV, findEmp, after)
 
 	if !isUpdatingHPEmpl && reqEmp.HighestPriority {
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_C, 1, false,
-			mqttSvc.ServerUpdateUserPayload("0", reqEmp.MSNV, reqEmp.RfidPass, reqEmp.KeypadPass))
+			mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(reqEmp.MSNV, after.Person)))
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
@@ -168,7 +186,7 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 		}
 	} else if isUpdatingHPEmpl && reqEmp.HighestPriority {
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_U, 1, false,
-			mqttSvc.ServerUpdateUserPayload("0", reqEmp.MSNV, reqEmp.RfidPass, reqEmp.KeypadPass))
+			mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(reqEmp.MSNV, after.Person)))
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
@@ -190,7 +208,7 @@ func (h *EmployeeHandler) UpdateEmployee(c *gin.Context) {
 		}
 	} else {
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_U, 1, false,
-			mqttSvc.ServerUpdateUserPayload("0", reqEmp.MSNV, reqEmp.RfidPass, reqEmp.KeypadPass))
+			mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(reqEmp.MSNV, after.Person)))
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
@@ -237,7 +255,7 @@ func (h *EmployeeHandler) DeleteEmployee(c *gin.Context) {
 	}
 	isDeletingHPEmpl := findEmp.HighestPriority
 
-	isSuccess, err := h.deps.SvcOpts.EmployeeSvc.DeleteEmployee(c.Request.Context(), de.MSNV)
+	isSuccess, err := h.deps.SvcOpts.EmployeeSvc.DeleteEmployee(c.Request.Context(), de.MSNV, getActor(c))
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -246,6 +264,7 @@ func (h *EmployeeHandler) DeleteEmployee(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_EMPLOYEE, de.MSNV, findEmp, nil)
 	if isDeletingHPEmpl {
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_D, 1, false,
 			mqttSvc.ServerDeleteUserPayload("0", de.MSNV))
@@ -272,6 +291,56 @@ func (h *EmployeeHandler) DeleteEmployee(c *gin.Context) {
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
+// Restore employee
+// @Summary Restore Deleted Employee By MSNV
+// @Schemes
+// @Description Restore soft deleted employee. Send credentials and registers of the employee to MQTT broker
+// @Produce json
+// @Param        msnv	path	string	true	"Employee MSNV"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/employee/{msnv}/restore [post]
+func (h *EmployeeHandler) RestoreEmployee(c *gin.Context) {
+	msnv := c.Param("msnv")
+
+	isSuccess, err := h.deps.SvcOpts.EmployeeSvc.RestoreEmployee(c.Request.Context(), msnv)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore employee failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	emp, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c.Request.Context(), msnv)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get employee failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_RESTORE, models.AUDIT_ENTITY_EMPLOYEE, emp.MSNV, nil, emp)
+
+	userTopic := mqttSvc.TOPIC_SV_USER_U
+	if emp.HighestPriority {
+		userTopic = mqttSvc.TOPIC_SV_HP_C
+	}
+	err = publishUserAccess(h.deps, c.Request.Context(), mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person), userTopic, emp.Schedulers)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore employee mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
 // Add employee scheduler
 // @Summary Add Door Open Scheduler For Employee
 // @Schemes
@@ -295,7 +364,7 @@ func (h *EmployeeHandler) AppendEmployeeScheduler(c *gin.Context) {
 		return
 	}
 
-	emp, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c, msnv)
+	emp, err := h.deps.SvcOpts.EmployeeSvc.FindEmployeeByMSNV(c.Request.Context(), msnv)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -318,16 +387,13 @@ func (h *EmployeeHandler) AppendEmployeeScheduler(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULER, idString(sche.ID), nil, sche)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false, mqttSvc.ServerCreateRegisterPayload(
 		usu.GatewayID,
 		usu.DoorlockAddress,
 		sche,
-		&mqttSvc.UserIDPassword{
-			UserId:     emp.MSNV,
-			RfidPass:   emp.RfidPass,
-			KeypadPass: emp.KeypadPass,
-		}))
+		mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person)))
 
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -350,3 +416,106 @@ func (h *EmployeeHandler) AppendEmployeeScheduler(c *gin.Context) {
 
 	utils.ResponseJson(c, http.StatusOK, true)
 }
+
+// Import employees
+// @Summary Import Employees From CSV Or XLSX
+// @Schemes
+// @Description Create or update employees keyed on MSNV, only columns in the file are updated. Columns: msnv, name, phone, email, department, role, rfidPass, keypadPass, highestPriority. Returns result of every row. Send updated credentials to MQTT broker in batches
+// @Accept  multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"CSV or XLSX file, first row is header"
+// @Param	format	query	string	false	"csv or xlsx, taken from file extension when missing"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/employees/import [post]
+func (h *EmployeeHandler) ImportEmployees(c *gin.Context) {
+	rows, err := readImportSheet(c)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	fields, sheetRows, err := models.ParseUserSheet(rows, models.EmployeeSheetColumns)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	ir := h.deps.SvcOpts.EmployeeSvc.ImportEmployees(c.Request.Context(), fields, sheetRows)
+	h.deps.auditImport(c, models.AUDIT_ENTITY_EMPLOYEE, ir)
+
+	// Highest priority employees keep their own topics, other updated users are batched
+	users := []mqttSvc.UserIDPassword{}
+	for _, rr := range ir.Rows {
+		if rr.Result == models.IMPORT_RESULT_FAILED {
+			continue
+		}
+		emp := rr.After.(*models.Employee)
+		wasHPEmpl := rr.Result == models.IMPORT_RESULT_UPDATED && rr.Before.(*models.Employee).HighestPriority
+
+		var t mqtt.Token
+		switch {
+		case !wasHPEmpl && emp.HighestPriority:
+			t = h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_C, 1, false,
+				mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person)))
+		case wasHPEmpl && emp.HighestPriority:
+			t = h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_U, 1, false,
+				mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person)))
+		case wasHPEmpl && !emp.HighestPriority:
+			t = h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_HP_D, 1, false,
+				mqttSvc.ServerDeleteUserPayload("0", emp.MSNV))
+		case rr.Result == models.IMPORT_RESULT_UPDATED:
+			users = append(users, *mqttSvc.NewUserIDPassword(emp.MSNV, emp.Person))
+			continue
+		default:
+			continue
+		}
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Msg:        "Import employees mqtt failed",
+				ErrorMsg:   err.Error(),
+			})
+			return
+		}
+	}
+	if err := publishUserBatch(h.deps, users); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Import employees mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, ir)
+}
+
+// Export employees
+// @Summary Export Employees To CSV Or XLSX
+// @Schemes
+// @Description Export all employees in the same format as import
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param	format	query	string	false	"csv (default) or xlsx"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/employees/export [get]
+func (h *EmployeeHandler) ExportEmployees(c *gin.Context) {
+	eList, err := h.deps.SvcOpts.EmployeeSvc.FindAllEmployee(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all employees failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	responseExportSheet(c, "employees", models.EmployeeSheet(eList))
+}
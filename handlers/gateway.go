@@ -3,7 +3,6 @@ package handlers
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
@@ -30,7 +29,7 @@ func NewGatewayHandler(deps *HandlerDependencies) *GatewayHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gateways [get]
 func (h *GatewayHandler) FindAllGateway(c *gin.Context) {
-	gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGateway(c)
+	gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGateway(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -54,7 +53,7 @@ func (h *GatewayHandler) FindAllGateway(c *gin.Context) {
 func (h *GatewayHandler) FindGatewayByID(c *gin.Context) {
 	id := c.Param("id")
 
-	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c, id)
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -105,6 +104,7 @@ func (h *GatewayHandler) CreateGateway(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_GATEWAY, gw.GatewayID, nil, gw)
 	utils.ResponseJson(c, http.StatusOK, gw)
 }
 
@@ -130,6 +130,7 @@ func (h *GatewayHandler) UpdateGateway(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.GatewaySvc.FindGatewayByMacID(c.Request.Context(), gw.GatewayID)
 	isSuccess, err := h.deps.SvcOpts.GatewaySvc.UpdateGateway(c.Request.Context(), gw)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -139,6 +140,8 @@ func (h *GatewayHandler) UpdateGateway(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.GatewaySvc.FindGatewayByMacID(c.Request.Context(), gw.GatewayID)
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_GATEWAY, gw.GatewayID, before, after)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_U, 1, false, mqttSvc.ServerUpdateGatewayPayload(gw))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
@@ -186,7 +189,8 @@ func (h *GatewayHandler) DeleteGateway(c *gin.Context) {
 	}
 
 	//delete gateway first
-	isSuccess, err := h.deps.SvcOpts.GatewaySvc.DeleteGateway(c.Request.Context(), dgw.GatewayID)
+	before, _ := h.deps.SvcOpts.GatewaySvc.FindGatewayByMacID(c.Request.Context(), dgw.GatewayID)
+	isSuccess, err := h.deps.SvcOpts.GatewaySvc.DeleteGateway(c.Request.Context(), dgw.GatewayID, getActor(c))
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -195,6 +199,7 @@ func (h *GatewayHandler) DeleteGateway(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_GATEWAY, dgw.GatewayID, before, nil)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_D, 1, false, mqttSvc.ServerDeleteGatewayPayload(dgw.GatewayID))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
@@ -206,29 +211,87 @@ func (h *GatewayHandler) DeleteGateway(c *gin.Context) {
 		return
 	}
 
-	// delete doorlock belong to this gateway
+	// doorlocks belong to this gateway are deleted with it
 	for i := 0; i < len(dls); i++ {
-		isSuccess, err := h.deps.SvcOpts.DoorlockSvc.DeleteDoorlock(c.Request.Context(), strconv.FormatUint(uint64(dls[i].ID), 10))
-		if err != nil || !isSuccess {
+		h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOORLOCK, idString(dls[i].ID), &dls[i], nil)
+
+		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_DOORLOCK_D, 1, false,
+			mqttSvc.ServerDeleteDoorlockPayload(&dls[i]))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
-				Msg:        "Delete doorlock failed",
+				Msg:        "Delete doorlock mqtt failed",
 				ErrorMsg:   err.Error(),
 			})
 			return
 		}
 
-		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_DOORLOCK_D, 1, false,
-			mqttSvc.ServerDeleteDoorlockPayload(&dls[i]))
-		if err := mqttSvc.HandleMqttErr(t); err != nil {
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Restore gateway
+// @Summary Restore Deleted Gateway By Gateway ID
+// @Schemes
+// @Description Restore soft deleted gateway and the doorlocks deleted with it. Send gateway, doorlocks and registers to MQTT broker
+// @Produce json
+// @Param        id	path	string	true	"Gateway ID (gatewayId field, not numeric id)"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/gateway/{id}/restore [post]
+func (h *GatewayHandler) RestoreGateway(c *gin.Context) {
+	gwId := c.Param("id")
+
+	isSuccess, err := h.deps.SvcOpts.GatewaySvc.RestoreGateway(c.Request.Context(), gwId)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore gateway failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByMacID(c.Request.Context(), gwId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get gateway failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_RESTORE, models.AUDIT_ENTITY_GATEWAY, gwId, nil, gw)
+
+	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_U, 1, false, mqttSvc.ServerUpdateGatewayPayload(gw))
+	if err := mqttSvc.HandleMqttErr(t); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore gateway mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	dls, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlockByGatewayID(c.Request.Context(), gwId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Find doorlock failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	for i := range dls {
+		if err := publishDoorlockAccess(h.deps, &dls[i]); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 				StatusCode: http.StatusBadRequest,
-				Msg:        "Delete doorlock mqtt failed",
+				Msg:        "Restore doorlock mqtt failed",
 				ErrorMsg:   err.Error(),
 			})
 			return
 		}
-
 	}
 
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
@@ -247,7 +310,7 @@ func (h *GatewayHandler) DeleteGatewayDoorlock(c *gin.Context) {
 		return
 	}
 
-	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c, gwId)
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c.Request.Context(), gwId)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -266,6 +329,7 @@ func (h *GatewayHandler) DeleteGatewayDoorlock(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_DOORLOCK, idString(d.ID), d, nil)
 
 	utils.ResponseJson(c, http.StatusOK, true)
 }
@@ -273,12 +337,16 @@ func (h *GatewayHandler) DeleteGatewayDoorlock(c *gin.Context) {
 // Unlock or Lock all gateway's doorlocks by BlockID
 // @Summary Unlock or Lock all gateway's doorlocks by BlockID
 // @Schemes
-// @Description Unlock or Lock all doorlocks in a Block
+// @Description Unlock or Lock all doorlocks in a Block. Unlocking a Block with doorlocks requiring approval creates a pending unlock request instead
 // @Accept  json
 // @Produce json
 // @Param	data	body	models.GatewayBlockCmd	true	"Gateway Block command"
+// @Param        X-Approver-Token	header	string	false	"Approver token, required when the command waits for approval"
 // @Success 200 {boolean} true
+// @Success 202 {object} models.UnlockRequest
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
 // @Router /v1/block/cmd [post]
 func (h *GatewayHandler) UpdateGatewayCmdByBlockID(c *gin.Context) {
 	cmd := &models.GatewayBlockCmd{}
@@ -291,6 +359,29 @@ func (h *GatewayHandler) UpdateGatewayCmdByBlockID(c *gin.Context) {
 		})
 		return
 	}
+	if models.IsUnlockCommand(cmd.Action) {
+		cnt, err := h.deps.SvcOpts.UnlockRequestSvc.CountBlockApprovalDoorlocks(c.Request.Context(), cmd.BlockId)
+		if err != nil {
+			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Msg:        "Find doorlocks requiring approval failed",
+				ErrorMsg:   err.Error(),
+			})
+			return
+		}
+		if cnt > 0 {
+			h.deps.requestUnlockApproval(c, &models.UnlockRequest{
+				Command: models.UNLOCK_CMD_BLOCK,
+				BlockID: cmd.BlockId,
+				State:   cmd.Action,
+				Reason:  cmd.Reason,
+			})
+			return
+		}
+	}
+	if !h.deps.requireMqttConnection(c) {
+		return
+	}
 	// Find all gateways based on Block ID
 	gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGatewaysByBlockID(c.Request.Context(), cmd.BlockId)
 	if err != nil {
@@ -322,6 +413,7 @@ func (h *GatewayHandler) UpdateGatewayCmdByBlockID(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_BLOCK, cmd.BlockId, nil, cmd)
 	utils.ResponseJson(c, http.StatusOK, true)
 }
 
@@ -348,7 +440,7 @@ func (h *GatewayHandler) AppendGatewayDoorlock(c *gin.Context) {
 		return
 	}
 
-	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c, gwID)
+	gw, err := h.deps.SvcOpts.GatewaySvc.FindGatewayByID(c.Request.Context(), gwID)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -359,7 +451,7 @@ func (h *GatewayHandler) AppendGatewayDoorlock(c *gin.Context) {
 	}
 	dl.GatewayID = gw.GatewayID
 
-	isSuccess, err := h.deps.SvcOpts.GatewaySvc.AppendGatewayDoorlock(c, gw, dl)
+	isSuccess, err := h.deps.SvcOpts.GatewaySvc.AppendGatewayDoorlock(c.Request.Context(), gw, dl)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -368,6 +460,7 @@ func (h *GatewayHandler) AppendGatewayDoorlock(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_DOORLOCK, idString(dl.ID), nil, dl)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_GATEWAY_U, 1, false, mqttSvc.ServerUpdateGatewayPayload(gw))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
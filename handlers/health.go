@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HEALTH_CHECK_TIMEOUT time.Duration = 2 * time.Second
+
+	HEALTH_OK   string = "ok"
+	HEALTH_DOWN string = "down"
+)
+
+type HealthHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewHealthHandler(deps *HandlerDependencies) *HealthHandler {
+	return &HealthHandler{
+		deps,
+	}
+}
+
+type HealthStatus struct {
+	Status   string            `json:"status"`
+	Database string            `json:"database"`
+	Mqtt     string            `json:"mqtt"`
+	Ready    bool              `json:"ready"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// Liveness of the server
+// @Summary Health Check
+// @Schemes
+// @Description 200 while the server can reach the database, the MQTT connection is reported but does not fail it
+// @Produce json
+// @Success 200 {object} handlers.HealthStatus
+// @Failure 503 {object} handlers.HealthStatus
+// @Router /healthz [get]
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	hs := h.check(c.Request.Context())
+	code := http.StatusOK
+	if hs.Database != HEALTH_OK {
+		hs.Status, code = HEALTH_DOWN, http.StatusServiceUnavailable
+	}
+	c.JSON(code, hs)
+}
+
+// Readiness of the server
+// @Summary Readiness Check
+// @Schemes
+// @Description 200 once every component started while the database and the MQTT broker are reachable, 503 otherwise and during shutdown
+// @Produce json
+// @Success 200 {object} handlers.HealthStatus
+// @Failure 503 {object} handlers.HealthStatus
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	hs := h.check(c.Request.Context())
+	code := http.StatusOK
+	if hs.Database != HEALTH_OK || hs.Mqtt != HEALTH_OK || !hs.Ready {
+		hs.Status, code = HEALTH_DOWN, http.StatusServiceUnavailable
+	}
+	c.JSON(code, hs)
+}
+
+// Prometheus metrics
+// @Summary Metrics
+// @Schemes
+// @Description HTTP latency by route, MQTT messages in/out by topic, subscriber errors, publish failures, online gateways, DB pool and Go runtime stats in Prometheus text format
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (h *HealthHandler) Metrics(c *gin.Context) {
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+func (h *HealthHandler) check(ctx context.Context) *HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+
+	hs := &HealthStatus{Status: HEALTH_OK, Database: HEALTH_OK, Mqtt: HEALTH_OK, Errors: map[string]string{}}
+	if err := h.deps.SvcOpts.HealthSvc.PingDatabase(ctx); err != nil {
+		hs.Database, hs.Errors["database"] = HEALTH_DOWN, err.Error()
+	}
+	if h.deps.MqttClient == nil || !h.deps.MqttClient.IsConnectionOpen() {
+		hs.Mqtt = HEALTH_DOWN
+	}
+	hs.Ready = h.deps.Lifecycle == nil || h.deps.Lifecycle.IsReady()
+	return hs
+}
+
+// Respond 503 when the MQTT broker is unreachable, so commands fail fast instead of being
+// sent late. Other publishes are queued by the MQTT client and need no check
+func (deps *HandlerDependencies) requireMqttConnection(c *gin.Context) bool {
+	if deps.MqttClient != nil && deps.MqttClient.IsConnectionOpen() {
+		return true
+	}
+	utils.ResponseJson(c, http.StatusServiceUnavailable, &utils.ErrorResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Msg:        "Execute command failed",
+		ErrorMsg:   mqttSvc.ErrBrokerDisconnected.Error(),
+	})
+	return false
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewJobHandler(deps *HandlerDependencies) *JobHandler {
+	return &JobHandler{
+		deps,
+	}
+}
+
+// Find all background jobs
+// @Summary Find All Jobs
+// @Schemes
+// @Description find background jobs (recycleBinPurge, retention, scheduledCommands) with their interval, next run, result of the last run and whether they are running
+// @Produce json
+// @Success 200 {array} []models.Job
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/jobs [get]
+func (h *JobHandler) FindAllJob(c *gin.Context) {
+	jobList, err := h.deps.SvcOpts.JobRunner.FindAllJob(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all jobs failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, jobList)
+}
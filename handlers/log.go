@@ -30,7 +30,7 @@ func NewGatewayLogHandler(deps *HandlerDependencies) *GatewayLogHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gatewayLogs [get]
 func (h *GatewayLogHandler) FindAllGatewayLog(c *gin.Context) {
-	glList, err := h.deps.SvcOpts.LogSvc.FindAllGatewayLog(c)
+	glList, err := h.deps.SvcOpts.LogSvc.FindAllGatewayLog(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -53,7 +53,7 @@ func (h *GatewayLogHandler) FindAllGatewayLog(c *gin.Context) {
 // @Router /v1/gatewayLog/{id} [get]
 func (h *GatewayLogHandler) FindGatewayLogByID(c *gin.Context) {
 	id := c.Param("id")
-	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayLogByID(c, id)
+	gl, err := h.deps.SvcOpts.LogSvc.FindGatewayLogByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -68,7 +68,7 @@ func (h *GatewayLogHandler) FindGatewayLogByID(c *gin.Context) {
 // Update GatewayLogs Cleaner time period (Default: 1 week)
 // @Summary Update GatewayLogs Cleaner time period (Default: 1 week)
 // @Schemes
-// @Description Change time period for GatewayLogs Cleaner
+// @Description Change time period for GatewayLogs Cleaner, rounded up to hours of the gatewayLogs retention policy
 // @Produce json
 // @Param        period	path	string	true	"Period (HOURS)"
 // @Success 200 {object} boolean
@@ -85,14 +85,19 @@ func (h *GatewayLogHandler) UpdateGatewayLogCleanPeriod(c *gin.Context) {
 		})
 		return
 	}
-	err = h.deps.SvcOpts.LogSvc.UpdateGatewayLogCleanPeriod(&period)
+	maxAgeHours, err := period.ToRetentionHours()
+	if err == nil {
+		_, err = h.deps.SvcOpts.RetentionSvc.SetRetentionMaxAge(c.Request.Context(), models.RETENTION_TABLE_GATEWAY_LOGS, maxAgeHours)
+	}
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
 			Msg:        "Incorrect period",
 			ErrorMsg:   err.Error(),
 		})
+		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_GATEWAY_LOG_PERIOD, "", nil, period)
 	utils.ResponseJson(c, http.StatusOK, true)
 }
 
@@ -113,9 +118,7 @@ func (h *GatewayLogHandler) FindGatewayLogsByTime(c *gin.Context) {
 	to := c.Param("to")
 	fromInt, _ := strconv.ParseInt(from, 10, 64)
 	toInt, _ := strconv.ParseInt(to, 10, 64)
-	fromFormatted := time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	toFormatted := time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
-	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByTime(gatewayId, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsByTime(c.Request.Context(), gatewayId, time.Unix(fromInt, 0), time.Unix(toInt, 0))
 
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -140,7 +143,7 @@ func (h *GatewayLogHandler) FindGatewayLogsByTime(c *gin.Context) {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/gatewayLogs/:id/period [get]
 func (h *GatewayLogHandler) FindGatewayLogsTypeByTime(c *gin.Context) {
-	var fromFormatted, toFormatted string
+	var fromTime, toTime time.Time
 	gatewayId := c.Param("id")
 	logType := c.DefaultQuery("type", "DEBUG")
 	from, isExist := c.GetQuery("from")
@@ -153,18 +156,18 @@ func (h *GatewayLogHandler) FindGatewayLogsTypeByTime(c *gin.Context) {
 		})
 	} else {
 		fromInt, _ := strconv.ParseInt(from, 10, 64)
-		fromFormatted = time.Unix(fromInt, 0).Format(models.DEFAULT_TIME_FORMAT)
+		fromTime = time.Unix(fromInt, 0)
 	}
 
 	to, isExist := c.GetQuery("to")
 	if !isExist || to == "" {
 		/* If to parameter not exist or to empty, use current time instead */
-		toFormatted = time.Now().Format(models.DEFAULT_TIME_FORMAT)
+		toTime = time.Now()
 	} else {
 		toInt, _ := strconv.ParseInt(to, 10, 64)
-		toFormatted = time.Unix(toInt, 0).Format(models.DEFAULT_TIME_FORMAT)
+		toTime = time.Unix(toInt, 0)
 	}
-	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsTypeByTime(gatewayId, logType, fromFormatted, toFormatted)
+	glList, err := h.deps.SvcOpts.LogSvc.FindGatewayLogsTypeByTime(c.Request.Context(), gatewayId, logType, fromTime, toTime)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type MqttDeadLetterHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewMqttDeadLetterHandler(deps *HandlerDependencies) *MqttDeadLetterHandler {
+	return &MqttDeadLetterHandler{
+		deps,
+	}
+}
+
+// Find all MQTT dead letters
+// @Summary Find All MQTT Dead Letters
+// @Schemes
+// @Description find gateway messages rejected by their contract or whose processing failed, newest first
+// @Produce json
+// @Param	status	query	string	false	"pending, replayed or discarded"
+// @Success 200 {array} []models.MqttDeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/mqttDeadLetters [get]
+func (h *MqttDeadLetterHandler) FindAllMqttDeadLetter(c *gin.Context) {
+	dltList, err := h.deps.SvcOpts.MqttDeadLetterSvc.FindAllMqttDeadLetter(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all MQTT dead letters failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dltList)
+}
+
+// Find MQTT dead letter by id
+// @Summary Find MQTT Dead Letter By ID
+// @Schemes
+// @Description find MQTT dead letter with its payload, reason of its latest failure and attempts
+// @Produce json
+// @Param        id	path	string	true	"Dead letter ID"
+// @Success 200 {object} models.MqttDeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/mqttDeadLetter/{id} [get]
+func (h *MqttDeadLetterHandler) FindMqttDeadLetterByID(c *gin.Context) {
+	dlt, err := h.deps.SvcOpts.MqttDeadLetterSvc.FindMqttDeadLetterByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get MQTT dead letter failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlt)
+}
+
+// Replay MQTT dead letter
+// @Summary Replay MQTT Dead Letter By ID
+// @Schemes
+// @Description Handle pending dead letter again as if its gateway just sent it, e.g. once the doorlock it refers to exists. It is marked replayed on success, otherwise it stays pending with the new reason and one more attempt
+// @Produce json
+// @Param        id	path	string	true	"Dead letter ID"
+// @Success 200 {object} models.MqttDeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/mqttDeadLetter/{id}/replay [post]
+func (h *MqttDeadLetterHandler) ReplayMqttDeadLetter(c *gin.Context) {
+	dlt, err := h.deps.SvcOpts.MqttDeadLetterSvc.FindMqttDeadLetterByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get MQTT dead letter failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	err = mqttSvc.ReplayDeadLetter(h.deps.MqttClient, h.deps.SvcOpts, dlt)
+	if replayed, findErr := h.deps.SvcOpts.MqttDeadLetterSvc.FindMqttDeadLetterByID(c.Request.Context(), idString(dlt.ID)); findErr == nil {
+		h.deps.audit(c, models.AUDIT_ACTION_REPLAY, models.AUDIT_ENTITY_MQTT_DEAD_LETTER, idString(dlt.ID), dlt, replayed)
+		dlt = replayed
+	}
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Replay MQTT dead letter failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, dlt)
+}
+
+// Discard MQTT dead letter
+// @Summary Discard MQTT Dead Letter By ID
+// @Schemes
+// @Description Discard pending dead letter, it is kept for inspection but can not be replayed anymore
+// @Produce json
+// @Param        id	path	string	true	"Dead letter ID"
+// @Success 200 {object} models.MqttDeadLetter
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/mqttDeadLetter/{id}/discard [post]
+func (h *MqttDeadLetterHandler) DiscardMqttDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid dead letter id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	dlt, err := h.deps.SvcOpts.MqttDeadLetterSvc.DiscardMqttDeadLetter(c.Request.Context(), uint(id))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Discard MQTT dead letter failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DISCARD, models.AUDIT_ENTITY_MQTT_DEAD_LETTER, idString(dlt.ID), nil, dlt)
+	utils.ResponseJson(c, http.StatusOK, dlt)
+}
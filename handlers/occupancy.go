@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type OccupancyHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewOccupancyHandler(deps *HandlerDependencies) *OccupancyHandler {
+	return &OccupancyHandler{
+		deps,
+	}
+}
+
+// Find live occupancy of all rooms
+// @Summary Find All Room Occupancy
+// @Schemes
+// @Description find people count, capacity and anti-passback mode of every room with a reader doorlock or someone inside
+// @Produce json
+// @Success 200 {array} []models.RoomOccupancy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/occupancy/rooms [get]
+func (h *OccupancyHandler) FindAllRoomOccupancy(c *gin.Context) {
+	roList, err := h.deps.SvcOpts.OccupancySvc.FindAllRoomOccupancy(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all room occupancy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, roList)
+}
+
+// Find live occupancy of room
+// @Summary Find Room Occupancy By Room ID
+// @Schemes
+// @Description find occupancy of room with the people inside
+// @Produce json
+// @Param        roomId	path	string	true	"Room ID of the doorlocks"
+// @Success 200 {object} models.RoomOccupancy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/occupancy/room/{roomId} [get]
+func (h *OccupancyHandler) FindRoomOccupancy(c *gin.Context) {
+	ro, err := h.deps.SvcOpts.OccupancySvc.FindRoomOccupancy(c.Request.Context(), c.Param("roomId"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get room occupancy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ro)
+}
+
+// Find live occupancy of all buildings
+// @Summary Find All Building Occupancy
+// @Schemes
+// @Description find people count and capacity of every building, rooms are grouped by blockId of their doorlocks
+// @Produce json
+// @Success 200 {array} []models.BuildingOccupancy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/occupancy/buildings [get]
+func (h *OccupancyHandler) FindAllBuildingOccupancy(c *gin.Context) {
+	boList, err := h.deps.SvcOpts.OccupancySvc.FindAllBuildingOccupancy(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all building occupancy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, boList)
+}
+
+// Update anti-passback mode of room
+// @Summary Update Room Anti-passback
+// @Schemes
+// @Description Set anti-passback of room to off, soft (violations are warned) or hard (gateways deny violations). The room state is sent to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateRoomSetting	true	"Room ID and anti-passback mode"
+// @Success 200 {object} models.RoomOccupancy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/occupancy/room [patch]
+func (h *OccupancyHandler) UpdateRoomSetting(c *gin.Context) {
+	rs := &models.RoomSetting{}
+	err := c.ShouldBind(rs)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	rs.ID = 0
+
+	before, _ := h.deps.SvcOpts.OccupancySvc.FindRoomOccupancy(c.Request.Context(), rs.RoomID)
+	rs, err = h.deps.SvcOpts.OccupancySvc.UpdateRoomSetting(c.Request.Context(), rs)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update room anti-passback failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_ROOM_SETTING, rs.RoomID, before, rs)
+
+	h.publishRoom(c, rs.RoomID, "Update room anti-passback mqtt failed")
+}
+
+// Reset occupancy of room
+// @Summary Reset Room Occupancy
+// @Schemes
+// @Description Mark everyone as out of the room, used when people left without passing an exit reader. The room state is sent to MQTT broker
+// @Produce json
+// @Param        roomId	path	string	true	"Room ID of the doorlocks"
+// @Success 200 {object} models.RoomOccupancy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/occupancy/room/{roomId}/reset [post]
+func (h *OccupancyHandler) ResetRoomOccupancy(c *gin.Context) {
+	roomID := c.Param("roomId")
+	before, _ := h.deps.SvcOpts.OccupancySvc.FindRoomOccupancy(c.Request.Context(), roomID)
+	isSuccess, err := h.deps.SvcOpts.OccupancySvc.ResetRoomOccupancy(c.Request.Context(), roomID)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Reset room occupancy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_ROOM_OCCUPANCY, roomID, before, nil)
+
+	h.publishRoom(c, roomID, "Reset room occupancy mqtt failed")
+}
+
+// Find access events
+// @Summary Find Access Events
+// @Schemes
+// @Description find entry and exit events newest first, default limit 100 and max 1000
+// @Produce json
+// @Param	roomId	query	string	false	"Room ID"
+// @Param	doorId	query	int	false	"Doorlock ID"
+// @Param	personId	query	int	false	"Person ID"
+// @Param	userId	query	string	false	"MSSV, MSNV or CCCD"
+// @Param	result	query	string	false	"granted, warned or denied"
+// @Param	from	query	int	false	"From event time in unix seconds"
+// @Param	to	query	int	false	"To event time in unix seconds"
+// @Param	limit	query	int	false	"Limit"
+// @Param	offset	query	int	false	"Offset"
+// @Success 200 {array} []models.AccessEvent
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/accessEvents [get]
+func (h *OccupancyHandler) FindAccessEvents(c *gin.Context) {
+	filter := &models.AccessEventFilter{}
+	if err := c.ShouldBindQuery(filter); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid query params",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	aeList, err := h.deps.SvcOpts.OccupancySvc.FindAccessEvents(c.Request.Context(), filter)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get access events failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, aeList)
+}
+
+// Send room state to the gateways of its reader doorlocks and respond with it
+func (h *OccupancyHandler) publishRoom(c *gin.Context, roomID string, mqttFailedMsg string) {
+	ro, err := h.deps.SvcOpts.OccupancySvc.FindRoomOccupancy(c.Request.Context(), roomID)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get room occupancy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	if err := mqttSvc.PublishRoomAntiPassback(h.deps.MqttClient, h.deps.SvcOpts, ro); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        mqttFailedMsg,
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ro)
+}
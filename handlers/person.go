@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type PersonHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewPersonHandler(deps *HandlerDependencies) *PersonHandler {
+	return &PersonHandler{
+		deps,
+	}
+}
+
+// Find all people
+// @Summary Find All People
+// @Schemes
+// @Description find all people with their credentials
+// @Produce json
+// @Success 200 {array} []models.Person
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/people [get]
+func (h *PersonHandler) FindAllPerson(c *gin.Context) {
+	pList, err := h.deps.SvcOpts.PersonSvc.FindAllPerson(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all people failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, pList)
+}
+
+// Find person by id
+// @Summary Find Person By ID
+// @Schemes
+// @Description find person and credentials by person id
+// @Produce json
+// @Param        id	path	string	true	"Person ID"
+// @Success 200 {object} models.Person
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/person/{id} [get]
+func (h *PersonHandler) FindPersonByID(c *gin.Context) {
+	id := c.Param("id")
+
+	p, err := h.deps.SvcOpts.PersonSvc.FindPersonByID(c.Request.Context(), id)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get person failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, p)
+}
+
+// Add credential
+// @Summary Add Credential To Person
+// @Schemes
+// @Description Add RFID card or keypad PIN to person, status is active when missing. Send updated credentials to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param        id	path	string	true	"Person ID"
+// @Param	data	body	models.SwagCreateCredential	true	"Fields need to create a credential"
+// @Success 200 {object} models.Credential
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/person/{id}/credential [post]
+func (h *PersonHandler) CreateCredential(c *gin.Context) {
+	p, err := h.deps.SvcOpts.PersonSvc.FindPersonByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get person failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	cred := &models.Credential{}
+	err = c.ShouldBind(cred)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	cred.ID = 0
+	cred.PersonID = p.ID
+
+	cred, err = h.deps.SvcOpts.PersonSvc.CreateCredential(c.Request.Context(), cred)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_CREDENTIAL, idString(cred.ID), nil, cred)
+
+	if err := publishPersonCredentials(h.deps, c.Request.Context(), p.ID); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create credential mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, cred)
+}
+
+// Update credential
+// @Summary Update Credential By ID
+// @Schemes
+// @Description Update value, status or validity dates of credential, must have "ID" field. Fields not given are kept, clearValidity removes both dates. Send updated credentials to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateCredential	true	"Fields need to update a credential"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/credential [patch]
+func (h *PersonHandler) UpdateCredential(c *gin.Context) {
+	cred := &models.Credential{}
+	err := c.ShouldBind(cred)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, err := h.deps.SvcOpts.PersonSvc.FindCredentialByID(c.Request.Context(), idString(cred.ID))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.PersonSvc.UpdateCredential(c.Request.Context(), cred)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.PersonSvc.FindCredentialByID(c.Request.Context(), idString(cred.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_CREDENTIAL, idString(cred.ID), before, after)
+
+	if err := publishPersonCredentials(h.deps, c.Request.Context(), before.PersonID); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update credential mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete credential
+// @Summary Delete Credential By ID
+// @Schemes
+// @Description Delete credential using "id" field. Send updated credentials to MQTT broker
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.DeleteID	true	"Credential ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/credential [delete]
+func (h *PersonHandler) DeleteCredential(c *gin.Context) {
+	dc := &models.DeleteID{}
+	err := c.ShouldBind(dc)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, err := h.deps.SvcOpts.PersonSvc.FindCredentialByID(c.Request.Context(), idString(dc.ID))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	isSuccess, err := h.deps.SvcOpts.PersonSvc.DeleteCredential(c.Request.Context(), dc.ID)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete credential failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_CREDENTIAL, idString(dc.ID), before, nil)
+
+	if err := publishPersonCredentials(h.deps, c.Request.Context(), before.PersonID); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete credential mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Send all usable credentials of person to MQTT broker, HP employees go to the HP topic
+func publishPersonCredentials(deps *HandlerDependencies, ctx context.Context, personID uint) error {
+	return mqttSvc.PublishPersonCredentials(ctx, deps.MqttClient, deps.SvcOpts, personID)
+}
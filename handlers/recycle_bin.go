@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type RecycleBinHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewRecycleBinHandler(deps *HandlerDependencies) *RecycleBinHandler {
+	return &RecycleBinHandler{
+		deps,
+	}
+}
+
+// Find all deleted records
+// @Summary Find Recycle Bin
+// @Schemes
+// @Description find soft deleted students, employees, customers, doorlocks and gateways that can still be restored
+// @Produce json
+// @Success 200 {object} models.RecycleBin
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/recycleBin [get]
+func (h *RecycleBinHandler) FindRecycleBin(c *gin.Context) {
+	rb, err := h.deps.SvcOpts.RecycleBinSvc.FindRecycleBin(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get recycle bin failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rb)
+}
+
+// Send restored user credentials and registers of its schedulers to MQTT broker
+func publishUserAccess(deps *HandlerDependencies, ctx context.Context, uP *mqttSvc.UserIDPassword, userTopic string, scheList []models.Scheduler) error {
+	t := deps.MqttClient.Publish(userTopic, 1, false,
+		mqttSvc.ServerUpdateUserPayload("0", uP))
+	if err := mqttSvc.HandleMqttErr(t); err != nil {
+		return err
+	}
+
+	for i := range scheList {
+		sche := &scheList[i]
+		// Door deleted in the meantime, gateway gets the register when door is restored
+		dl, err := deps.SvcOpts.DoorlockSvc.FindDoorlockByID(ctx, strconv.FormatUint(uint64(sche.DoorID), 10))
+		if err != nil {
+			continue
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false,
+			mqttSvc.ServerCreateRegisterPayload(dl.GatewayID, dl.DoorlockAddress, sche, uP))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send restored doorlock and registers of its schedulers to MQTT broker
+func publishDoorlockAccess(deps *HandlerDependencies, dl *models.Doorlock) error {
+	t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_DOORLOCK_C, 1, false,
+		mqttSvc.ServerCreateDoorlockPayload(dl))
+	if err := mqttSvc.HandleMqttErr(t); err != nil {
+		return err
+	}
+
+	for i := range dl.Schedulers {
+		sche := &dl.Schedulers[i]
+		// Scheduler user is deleted, skip its register
+		uP, ok := mqttSvc.GetUserPassInfoFromScheduler(deps.SvcOpts, *sche)
+		if !ok {
+			continue
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false,
+			mqttSvc.ServerCreateRegisterPayload(dl.GatewayID, dl.DoorlockAddress, sche, &uP))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// Request header naming the operator who sends the request
+	ACTOR_HEADER string = "X-Actor"
+	// Request header carrying the secret token of an unlock approver, see UNLOCK_APPROVER_TOKENS
+	APPROVER_TOKEN_HEADER string = "X-Approver-Token"
+	// Request header tying together every record written for one request, generated when missing
+	CORRELATION_ID_HEADER string = "X-Correlation-ID"
+
+	correlationIDKey string = "correlationId"
+)
+
+// Operator name recorded on deleted records and audit logs, fall back to client IP
+func getActor(c *gin.Context) string {
+	if actor := c.GetHeader(ACTOR_HEADER); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+func getCorrelationID(c *gin.Context) string {
+	return c.GetString(correlationIDKey)
+}
+
+// Keep correlation ID from request header or generate one, echo it in response header
+func CorrelationIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationID := c.GetHeader(CORRELATION_ID_HEADER)
+		if correlationID == "" || len(correlationID) > 64 {
+			correlationID = uuid.New().String()
+		}
+		c.Set(correlationIDKey, correlationID)
+		c.Writer.Header().Set(CORRELATION_ID_HEADER, correlationID)
+		c.Next()
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewRetentionHandler(deps *HandlerDependencies) *RetentionHandler {
+	return &RetentionHandler{
+		deps,
+	}
+}
+
+// Find all retention policies
+// @Summary Find All Retention Policies
+// @Schemes
+// @Description find retention policy of every log table with result of its last run, tables never configured have their default policy
+// @Produce json
+// @Success 200 {array} []models.RetentionPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/retentionPolicies [get]
+func (h *RetentionHandler) FindAllRetentionPolicy(c *gin.Context) {
+	rpList, err := h.deps.SvcOpts.RetentionSvc.FindAllRetentionPolicy(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all retention policies failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rpList)
+}
+
+// Update retention policy
+// @Summary Update Retention Policy By Table
+// @Schemes
+// @Description Set max age in hours, max rows and archive of a log table, 0 is no limit. Tables: gatewayLogs, doorlockStatusLogs, doorlockStatusEvents, accessEvents, auditLogs (archive required), scheduledCommandRuns
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateRetentionPolicy	true	"Fields need to update a retention policy"
+// @Success 200 {object} models.RetentionPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/retentionPolicy [patch]
+func (h *RetentionHandler) UpdateRetentionPolicy(c *gin.Context) {
+	rp := &models.RetentionPolicy{}
+	err := c.ShouldBind(rp)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before := h.findRetentionPolicy(c, rp.Table)
+	rp, err = h.deps.SvcOpts.RetentionSvc.UpdateRetentionPolicy(c.Request.Context(), rp)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update retention policy failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_RETENTION_POLICY, rp.Table, before, rp)
+	utils.ResponseJson(c, http.StatusOK, rp)
+}
+
+// Run retention policies
+// @Summary Run Retention Policies
+// @Schemes
+// @Description Apply every retention policy now instead of waiting for the hourly run, fails when a run is in progress
+// @Produce json
+// @Success 200 {array} []models.RetentionPolicy
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/retentionPolicies/run [post]
+func (h *RetentionHandler) RunRetention(c *gin.Context) {
+	rpList, err := h.deps.SvcOpts.RetentionSvc.RunRetention(c.Request.Context(), time.Now())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Run retention failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, rpList)
+}
+
+func (h *RetentionHandler) findRetentionPolicy(c *gin.Context, table string) *models.RetentionPolicy {
+	rpList, _ := h.deps.SvcOpts.RetentionSvc.FindAllRetentionPolicy(c.Request.Context())
+	for i := range rpList {
+		if rpList[i].Table == table {
+			return &rpList[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledCommandHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewScheduledCommandHandler(deps *HandlerDependencies) *ScheduledCommandHandler {
+	return &ScheduledCommandHandler{
+		deps,
+	}
+}
+
+// Find all scheduled commands
+// @Summary Find All Scheduled Commands
+// @Schemes
+// @Description find all scheduled commands with their next and last run
+// @Produce json
+// @Success 200 {array} []models.ScheduledCommand
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommands [get]
+func (h *ScheduledCommandHandler) FindAllScheduledCommand(c *gin.Context) {
+	scList, err := h.deps.SvcOpts.ScheduledCommandSvc.FindAllScheduledCommand(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all scheduled commands failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, scList)
+}
+
+// Find scheduled command by id
+// @Summary Find Scheduled Command By ID
+// @Schemes
+// @Description find scheduled command by id
+// @Produce json
+// @Param        id	path	string	true	"Scheduled command ID"
+// @Success 200 {object} models.ScheduledCommand
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand/{id} [get]
+func (h *ScheduledCommandHandler) FindScheduledCommandByID(c *gin.Context) {
+	sc, err := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get scheduled command failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, sc)
+}
+
+// Find run history of scheduled command
+// @Summary Find Scheduled Command Runs
+// @Schemes
+// @Description find runs of scheduled command newest first, default limit 100 and max 1000
+// @Produce json
+// @Param        id	path	string	true	"Scheduled command ID"
+// @Param	limit	query	int	false	"Limit"
+// @Success 200 {array} []models.ScheduledCommandRun
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand/{id}/runs [get]
+func (h *ScheduledCommandHandler) FindScheduledCommandRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runList, err := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandRuns(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get scheduled command runs failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, runList)
+}
+
+// Create scheduled command
+// @Summary Create Scheduled Command
+// @Schemes
+// @Description Create doorlock command sent at every occurrence of "cron" (minute hour day-of-month month day-of-week) in "timeZone" to the doorlocks of the target (doorlock, room, block or area). "missedRunPolicy" skip or runOnce handles runs missed while the server was down
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagCreateScheduledCommand	true	"Fields need to create a scheduled command"
+// @Success 200 {object} models.ScheduledCommand
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand [post]
+func (h *ScheduledCommandHandler) CreateScheduledCommand(c *gin.Context) {
+	sc := &models.ScheduledCommand{}
+	err := c.ShouldBind(sc)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	sc.ID = 0
+
+	sc, err = h.deps.SvcOpts.ScheduledCommandSvc.CreateScheduledCommand(c.Request.Context(), sc)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Create scheduled command failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULED_COMMAND, idString(sc.ID), nil, sc)
+	utils.ResponseJson(c, http.StatusOK, sc)
+}
+
+// Update scheduled command
+// @Summary Update Scheduled Command By ID
+// @Schemes
+// @Description Update scheduled command, must have "id" field. The next run is computed again from now
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.SwagUpdateScheduledCommand	true	"Fields need to update a scheduled command"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand [patch]
+func (h *ScheduledCommandHandler) UpdateScheduledCommand(c *gin.Context) {
+	sc := &models.ScheduledCommand{}
+	err := c.ShouldBind(sc)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), idString(sc.ID))
+	isSuccess, err := h.deps.SvcOpts.ScheduledCommandSvc.UpdateScheduledCommand(c.Request.Context(), sc)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update scheduled command failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), idString(sc.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SCHEDULED_COMMAND, idString(sc.ID), before, after)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Enable or disable scheduled command
+// @Summary Enable Scheduled Command By ID
+// @Schemes
+// @Description Enable or disable scheduled command. An enabled command runs from its next occurrence, occurrences while disabled are not run
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.ScheduledCommandEnable	true	"Scheduled command ID and enabled"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand/enable [patch]
+func (h *ScheduledCommandHandler) EnableScheduledCommand(c *gin.Context) {
+	sce := &models.ScheduledCommandEnable{}
+	err := c.ShouldBind(sce)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), idString(sce.ID))
+	isSuccess, err := h.deps.SvcOpts.ScheduledCommandSvc.EnableScheduledCommand(c.Request.Context(), sce.ID, sce.Enabled)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Enable scheduled command failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), idString(sce.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SCHEDULED_COMMAND, idString(sce.ID), before, after)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Delete scheduled command
+// @Summary Delete Scheduled Command By ID
+// @Schemes
+// @Description Delete scheduled command and its run history using "id" field
+// @Accept  json
+// @Produce json
+// @Param	data	body	object{id=int}	true	"Scheduled command ID"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/scheduledCommand [delete]
+func (h *ScheduledCommandHandler) DeleteScheduledCommand(c *gin.Context) {
+	dId := &models.DeleteID{}
+	err := c.ShouldBind(dId)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, _ := h.deps.SvcOpts.ScheduledCommandSvc.FindScheduledCommandByID(c.Request.Context(), idString(dId.ID))
+	isSuccess, err := h.deps.SvcOpts.ScheduledCommandSvc.DeleteScheduledCommand(c.Request.Context(), dId.ID)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete scheduled command failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_SCHEDULED_COMMAND, idString(dId.ID), before, nil)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
@@ -29,7 +29,7 @@ func NewSchedulerHandler(deps *HandlerDependencies) *SchedulerHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/schedulers [get]
 func (h *SchedulerHandler) FindAllScheduler(c *gin.Context) {
-	sList, err := h.deps.SvcOpts.SchedulerSvc.FindAllScheduler(c)
+	sList, err := h.deps.SvcOpts.SchedulerSvc.FindAllScheduler(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -53,7 +53,7 @@ func (h *SchedulerHandler) FindAllScheduler(c *gin.Context) {
 func (h *SchedulerHandler) FindSchedulerByID(c *gin.Context) {
 	id := c.Param("id")
 
-	s, err := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c, id)
+	s, err := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), id)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -96,6 +96,7 @@ func (h *SchedulerHandler) CreateScheduler(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULER, idString(s.ID), nil, s)
 	utils.ResponseJson(c, http.StatusOK, s)
 }
 
@@ -121,6 +122,7 @@ func (h *SchedulerHandler) UpdateScheduler(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), idString(s.ID))
 	isSuccess, err := h.deps.SvcOpts.SchedulerSvc.UpdateScheduler(c.Request.Context(), &s.Scheduler)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -130,6 +132,8 @@ func (h *SchedulerHandler) UpdateScheduler(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), idString(s.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SCHEDULER, idString(s.ID), before, after)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_U, 1, false,
 		mqttSvc.ServerUpdateRegisterPayload("0", s))
@@ -167,6 +171,15 @@ func (h *SchedulerHandler) DeleteScheduler(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), idString(dId.ID))
+	if before != nil && before.AccessPolicyID != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Delete scheduler failed",
+			ErrorMsg:   "scheduler is managed by an access policy",
+		})
+		return
+	}
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_D, 1, false,
 		mqttSvc.ServerDeleteRegisterPayload("0", dId.ID))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
@@ -187,6 +200,7 @@ func (h *SchedulerHandler) DeleteScheduler(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_SCHEDULER, idString(dId.ID), before, nil)
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 
 }
@@ -214,7 +228,7 @@ func (h *SchedulerHandler) AppendSchedulerOnExcel(c *gin.Context) {
 	}
 
 	roomId := userScheduler.ScheInfo.RoomID
-	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlocksByRoomID(c, roomId)
+	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlocksByRoomID(c.Request.Context(), roomId)
 
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -225,7 +239,7 @@ func (h *SchedulerHandler) AppendSchedulerOnExcel(c *gin.Context) {
 		return
 	}
 	userRole := userScheduler.ScheInfo.Role
-	userScheduler, err = getUserInformation(c, h.deps.SvcOpts, userRole, userScheduler)
+	userScheduler, person, err := getUserInformation(c, h.deps.SvcOpts, userRole, userScheduler)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -246,16 +260,13 @@ func (h *SchedulerHandler) AppendSchedulerOnExcel(c *gin.Context) {
 			})
 			return
 		}
+		h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULER, idString(newScheduler.ID), nil, &newScheduler)
 
 		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false, mqttSvc.ServerCreateRegisterPayload(
 			dlList[i].GatewayID,
 			dlList[i].DoorlockAddress,
 			&newScheduler,
-			&mqttSvc.UserIDPassword{
-				UserId:     userScheduler.UserID,
-				RfidPass:   userScheduler.RfidPass,
-				KeypadPass: userScheduler.KeypadPass,
-			}))
+			mqttSvc.NewUserIDPassword(userScheduler.UserID, person)))
 
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -332,7 +343,7 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 	}
 
 	roomId := userScheduler.ScheInfo.RoomID
-	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlocksByRoomID(c, roomId)
+	dlList, err := h.deps.SvcOpts.DoorlockSvc.FindAllDoorlocksByRoomID(c.Request.Context(), roomId)
 
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -343,7 +354,7 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 		return
 	}
 	userRole := userScheduler.ScheInfo.Role
-	userScheduler, err = getUserInformation(c, h.deps.SvcOpts, userRole, userScheduler)
+	userScheduler, person, err := getUserInformation(c, h.deps.SvcOpts, userRole, userScheduler)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -353,6 +364,7 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), idString(userScheduler.ScheInfo.ID))
 	isSuccess, err := h.deps.SvcOpts.SchedulerSvc.UpdateScheduler(c.Request.Context(), &userScheduler.ScheInfo)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -362,6 +374,8 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.SchedulerSvc.FindSchedulerByID(c.Request.Context(), idString(userScheduler.ScheInfo.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SCHEDULER, idString(userScheduler.ScheInfo.ID), before, after)
 
 	for i := 0; i < len(dlList); i++ {
 
@@ -369,11 +383,7 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 			dlList[i].GatewayID,
 			dlList[i].DoorlockAddress,
 			&userScheduler.ScheInfo,
-			&mqttSvc.UserIDPassword{
-				UserId:     userScheduler.UserID,
-				RfidPass:   userScheduler.RfidPass,
-				KeypadPass: userScheduler.KeypadPass,
-			}))
+			mqttSvc.NewUserIDPassword(userScheduler.UserID, person)))
 
 		if err := mqttSvc.HandleMqttErr(t); err != nil {
 			utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -388,38 +398,45 @@ func (h *SchedulerHandler) UpdateSchedulerOnExcel(c *gin.Context) {
 	utils.ResponseJson(c, http.StatusOK, true)
 }
 
-func getUserInformation(c *gin.Context, optSvc *models.ServiceOptions, userRole string, userScheduler *models.UserScheduler) (*models.UserScheduler, error) {
+func getUserInformation(c *gin.Context, optSvc *models.ServiceOptions, userRole string, userScheduler *models.UserScheduler) (*models.UserScheduler, *models.Person, error) {
+	var person *models.Person
 	if userRole == "employee" {
-		userEmp, err := optSvc.EmployeeSvc.FindEmployeeByMSNV(c, userScheduler.ScheInfo.UserID)
+		userEmp, err := optSvc.EmployeeSvc.FindEmployeeByMSNV(c.Request.Context(), userScheduler.ScheInfo.UserID)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		userScheduler.UserID = userEmp.MSNV
 		userScheduler.RfidPass = userEmp.RfidPass
 		userScheduler.KeypadPass = userEmp.KeypadPass
+		userScheduler.ScheInfo.PersonID = userEmp.PersonID
+		person = userEmp.Person
 		userScheduler.ScheInfo.EmployeeID = &userEmp.MSNV
 	} else if userRole == "student" {
-		userStu, err := optSvc.StudentSvc.FindStudentByMSSV(c, userScheduler.ScheInfo.UserID)
+		userStu, err := optSvc.StudentSvc.FindStudentByMSSV(c.Request.Context(), userScheduler.ScheInfo.UserID)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		userScheduler.UserID = userStu.MSSV
 		userScheduler.RfidPass = userStu.RfidPass
 		userScheduler.KeypadPass = userStu.KeypadPass
+		userScheduler.ScheInfo.PersonID = userStu.PersonID
+		person = userStu.Person
 		userScheduler.ScheInfo.StudentID = &userStu.MSSV
 	} else if userRole == "customer" {
-		userCus, err := optSvc.CustomerSvc.FindCustomerByCCCD(c, userScheduler.ScheInfo.UserID)
+		userCus, err := optSvc.CustomerSvc.FindCustomerByCCCD(c.Request.Context(), userScheduler.ScheInfo.UserID)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		userScheduler.UserID = userCus.CCCD
 		userScheduler.RfidPass = userCus.RfidPass
 		userScheduler.KeypadPass = userCus.KeypadPass
+		userScheduler.ScheInfo.PersonID = userCus.PersonID
+		person = userCus.Person
 		userScheduler.ScheInfo.CustomerID = &userCus.CCCD
 
 	} else {
 
-		return nil, fmt.Errorf("no user record")
+		return nil, nil, fmt.Errorf("no user record")
 	}
-	return userScheduler, nil
+	return userScheduler, person, nil
 }
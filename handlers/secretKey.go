@@ -28,7 +28,7 @@ func NewSecretKeyHandler(deps *HandlerDependencies) *SecretKeyHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/secretkeys [get]
 func (h *SecretKeyHandler) FindSecretKey(c *gin.Context) {
-	sk, err := h.deps.SvcOpts.SecretKeySvc.FindSecretKey(c)
+	sk, err := h.deps.SvcOpts.SecretKeySvc.FindSecretKey(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -72,6 +72,7 @@ func (h *SecretKeyHandler) CreateSecretKey(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SECRET_KEY, idString(csk.ID), nil, csk)
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SYSTEM_U, 1, false,
 		mqttSvc.ServerUpdateSecretKeyPayload("0", csk.Secret))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
@@ -109,6 +110,7 @@ func (h *SecretKeyHandler) UpdateSecretKey(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.SecretKeySvc.FindSecretKey(c.Request.Context())
 	isSuccess, err := h.deps.SvcOpts.SecretKeySvc.UpdateSecretKey(c.Request.Context(), s)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -118,6 +120,8 @@ func (h *SecretKeyHandler) UpdateSecretKey(c *gin.Context) {
 		})
 		return
 	}
+	after, _ := h.deps.SvcOpts.SecretKeySvc.FindSecretKey(c.Request.Context())
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_SECRET_KEY, idString(s.ID), before, after)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SYSTEM_U, 1, false,
 		mqttSvc.ServerUpdateSecretKeyPayload("0", s.Secret))
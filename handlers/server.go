@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"net/http"
+
+	"github.com/ecoprohcm/DMS_BackendServer/lifecycle"
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,7 +19,16 @@ func SetupRouter(
 	r.Use(gin.Recovery())
 	r.Use(logger.GinLogger())
 	r.Use(CORSMiddleware())
+	r.Use(CorrelationIDMiddleware())
+	r.Use(metrics.GinMiddleware())
+
+	// Health and metrics routes, answered during startup and shutdown
+	r.GET("/healthz", hOpts.HealthHandler.Healthz)
+	r.GET("/readyz", hOpts.HealthHandler.Readyz)
+	r.GET("/metrics", hOpts.HealthHandler.Metrics)
+
 	v1R := r.Group("/v1")
+	v1R.Use(ReadinessMiddleware(hOpts.Lifecycle))
 	{
 		// Gateway routes
 		v1R.GET("/gateways", hOpts.GatewayHandler.FindAllGateway)
@@ -22,6 +36,7 @@ func SetupRouter(
 		v1R.POST("/gateway", hOpts.GatewayHandler.CreateGateway)
 		v1R.PATCH("/gateway", hOpts.GatewayHandler.UpdateGateway)
 		v1R.DELETE("/gateway", hOpts.GatewayHandler.DeleteGateway)
+		v1R.POST("/gateway/:id/restore", hOpts.GatewayHandler.RestoreGateway)
 		v1R.DELETE("/gateway/:id/doorlock", hOpts.GatewayHandler.DeleteGatewayDoorlock)
 		v1R.POST("/block/cmd", hOpts.GatewayHandler.UpdateGatewayCmdByBlockID)
 
@@ -42,6 +57,7 @@ func SetupRouter(
 		v1R.PATCH("/doorlock/cmd", hOpts.DoorlockHandler.UpdateDoorlockCmd)
 		v1R.PATCH("/doorlock/state/cmd", hOpts.DoorlockHandler.UpdateDoorlockStateCmd)
 		v1R.DELETE("/doorlock", hOpts.DoorlockHandler.DeleteDoorlock)
+		v1R.POST("/doorlock/:id/restore", hOpts.DoorlockHandler.RestoreDoorlock)
 
 		// Doorlock log route
 		v1R.GET("/doorlockStatusLogs", hOpts.DoorlockStatusLogHandler.GetAllDoorlockStatusLogs)
@@ -49,31 +65,101 @@ func SetupRouter(
 		v1R.GET("/doorlockStatusLog/date/:fromTime/:toTime", hOpts.DoorlockStatusLogHandler.GetDoorlockStatusLogInTimeRange)
 		v1R.DELETE("/doorlockStatusLog/:doorId", hOpts.DoorlockStatusLogHandler.DeleteDoorlockStatusLogByDoorID)
 		v1R.DELETE("/doorlockStatusLog/date/:fromTime/:toTime", hOpts.DoorlockStatusLogHandler.DeleteDoorlockStatusLogInTimeRange)
+		v1R.GET("/doorlockStatusEvents/:doorId", hOpts.DoorlockStatusLogHandler.FindDoorlockStatusEvents)
+		v1R.GET("/doorlockStats", hOpts.DoorlockStatusLogHandler.FindAllDoorlockStats)
+		v1R.GET("/doorlockStats/:doorId", hOpts.DoorlockStatusLogHandler.FindDoorlockStats)
+		v1R.GET("/roomStats/:roomId/busiestHours", hOpts.DoorlockStatusLogHandler.FindRoomBusiestHours)
 
 		// Student routes
 		v1R.GET("/students", hOpts.StudentHandler.FindAllStudent)
+		v1R.GET("/students/export", hOpts.StudentHandler.ExportStudents)
+		v1R.POST("/students/import", hOpts.StudentHandler.ImportStudents)
 		v1R.GET("/student/:mssv", hOpts.StudentHandler.FindStudentByMSSV)
 		v1R.POST("/student", hOpts.StudentHandler.CreateStudent)
 		v1R.PATCH("/student", hOpts.StudentHandler.UpdateStudent)
 		v1R.DELETE("/student", hOpts.StudentHandler.DeleteStudent)
+		v1R.POST("/student/:mssv/restore", hOpts.StudentHandler.RestoreStudent)
 		v1R.POST("/student/:mssv/scheduler", hOpts.StudentHandler.AppendStudentScheduler)
 
 		// Employee routes
 		v1R.GET("/employees", hOpts.EmployeeHandler.FindAllEmployee)
+		v1R.GET("/employees/export", hOpts.EmployeeHandler.ExportEmployees)
+		v1R.POST("/employees/import", hOpts.EmployeeHandler.ImportEmployees)
 		v1R.GET("/employee/:msnv", hOpts.EmployeeHandler.FindEmployeeByMSNV)
 		v1R.POST("/employee", hOpts.EmployeeHandler.CreateEmployee)
 		v1R.PATCH("/employee", hOpts.EmployeeHandler.UpdateEmployee)
 		v1R.DELETE("/employee", hOpts.EmployeeHandler.DeleteEmployee)
+		v1R.POST("/employee/:msnv/restore", hOpts.EmployeeHandler.RestoreEmployee)
 		v1R.POST("/employee/:msnv/scheduler", hOpts.EmployeeHandler.AppendEmployeeScheduler)
 
 		// Customer routes
 		v1R.GET("/customers", hOpts.CustomerHandler.FindAllCustomer)
+		v1R.GET("/customers/export", hOpts.CustomerHandler.ExportCustomers)
+		v1R.POST("/customers/import", hOpts.CustomerHandler.ImportCustomers)
 		v1R.GET("/customer/:cccd", hOpts.CustomerHandler.FindCustomerByCCCD)
 		v1R.POST("/customer", hOpts.CustomerHandler.CreateCustomer)
 		v1R.PATCH("/customer", hOpts.CustomerHandler.UpdateCustomer)
 		v1R.DELETE("/customer", hOpts.CustomerHandler.DeleteCustomer)
+		v1R.POST("/customer/:cccd/restore", hOpts.CustomerHandler.RestoreCustomer)
 		v1R.POST("/customer/:cccd/scheduler", hOpts.CustomerHandler.AppendCustomerScheduler)
 
+		// Person and credential routes
+		v1R.GET("/people", hOpts.PersonHandler.FindAllPerson)
+		v1R.GET("/person/:id", hOpts.PersonHandler.FindPersonByID)
+		v1R.POST("/person/:id/credential", hOpts.PersonHandler.CreateCredential)
+		v1R.PATCH("/credential", hOpts.PersonHandler.UpdateCredential)
+		v1R.DELETE("/credential", hOpts.PersonHandler.DeleteCredential)
+		v1R.POST("/credential/:id/revoke", hOpts.RevocationHandler.RevokeCredential)
+		v1R.GET("/revocations", hOpts.RevocationHandler.FindAllRevocation)
+		v1R.GET("/revocation/:id", hOpts.RevocationHandler.FindRevocationByID)
+		v1R.POST("/revocation/:id/resend", hOpts.RevocationHandler.ResendRevocation)
+
+		// Access group, door group and access policy routes
+		v1R.GET("/accessGroups", hOpts.AccessGroupHandler.FindAllAccessGroup)
+		v1R.GET("/accessGroup/:id", hOpts.AccessGroupHandler.FindAccessGroupByID)
+		v1R.POST("/accessGroup", hOpts.AccessGroupHandler.CreateAccessGroup)
+		v1R.PATCH("/accessGroup", hOpts.AccessGroupHandler.UpdateAccessGroup)
+		v1R.DELETE("/accessGroup", hOpts.AccessGroupHandler.DeleteAccessGroup)
+		v1R.POST("/accessGroup/:id/members", hOpts.AccessGroupHandler.AddAccessGroupMembers)
+		v1R.DELETE("/accessGroup/:id/members", hOpts.AccessGroupHandler.RemoveAccessGroupMembers)
+		v1R.GET("/doorGroups", hOpts.DoorGroupHandler.FindAllDoorGroup)
+		v1R.GET("/doorGroup/:id", hOpts.DoorGroupHandler.FindDoorGroupByID)
+		v1R.POST("/doorGroup", hOpts.DoorGroupHandler.CreateDoorGroup)
+		v1R.PATCH("/doorGroup", hOpts.DoorGroupHandler.UpdateDoorGroup)
+		v1R.DELETE("/doorGroup", hOpts.DoorGroupHandler.DeleteDoorGroup)
+		v1R.POST("/doorGroup/:id/doorlocks", hOpts.DoorGroupHandler.AddDoorGroupDoorlocks)
+		v1R.DELETE("/doorGroup/:id/doorlocks", hOpts.DoorGroupHandler.RemoveDoorGroupDoorlocks)
+		v1R.GET("/accessPolicies", hOpts.AccessPolicyHandler.FindAllAccessPolicy)
+		v1R.GET("/accessPolicy/:id", hOpts.AccessPolicyHandler.FindAccessPolicyByID)
+		v1R.GET("/accessPolicy/:id/registers", hOpts.AccessPolicyHandler.FindAccessPolicyRegisters)
+		v1R.POST("/accessPolicy", hOpts.AccessPolicyHandler.CreateAccessPolicy)
+		v1R.PATCH("/accessPolicy", hOpts.AccessPolicyHandler.UpdateAccessPolicy)
+		v1R.DELETE("/accessPolicy", hOpts.AccessPolicyHandler.DeleteAccessPolicy)
+
+		// Occupancy and anti-passback routes
+		v1R.GET("/occupancy/rooms", hOpts.OccupancyHandler.FindAllRoomOccupancy)
+		v1R.GET("/occupancy/room/:roomId", hOpts.OccupancyHandler.FindRoomOccupancy)
+		v1R.GET("/occupancy/buildings", hOpts.OccupancyHandler.FindAllBuildingOccupancy)
+		v1R.PATCH("/occupancy/room", hOpts.OccupancyHandler.UpdateRoomSetting)
+		v1R.POST("/occupancy/room/:roomId/reset", hOpts.OccupancyHandler.ResetRoomOccupancy)
+		v1R.GET("/accessEvents", hOpts.OccupancyHandler.FindAccessEvents)
+
+		// Remote unlock approval routes
+		v1R.PATCH("/doorlock/approval", hOpts.UnlockRequestHandler.UpdateDoorlockApproval)
+		v1R.GET("/unlockRequests", hOpts.UnlockRequestHandler.FindAllUnlockRequest)
+		v1R.GET("/unlockRequest/:id", hOpts.UnlockRequestHandler.FindUnlockRequestByID)
+		v1R.POST("/unlockRequest/:id/approve", hOpts.UnlockRequestHandler.ApproveUnlockRequest)
+		v1R.POST("/unlockRequest/:id/reject", hOpts.UnlockRequestHandler.RejectUnlockRequest)
+
+		// Scheduled command routes
+		v1R.GET("/scheduledCommands", hOpts.ScheduledCommandHandler.FindAllScheduledCommand)
+		v1R.GET("/scheduledCommand/:id", hOpts.ScheduledCommandHandler.FindScheduledCommandByID)
+		v1R.GET("/scheduledCommand/:id/runs", hOpts.ScheduledCommandHandler.FindScheduledCommandRuns)
+		v1R.POST("/scheduledCommand", hOpts.ScheduledCommandHandler.CreateScheduledCommand)
+		v1R.PATCH("/scheduledCommand", hOpts.ScheduledCommandHandler.UpdateScheduledCommand)
+		v1R.PATCH("/scheduledCommand/enable", hOpts.ScheduledCommandHandler.EnableScheduledCommand)
+		v1R.DELETE("/scheduledCommand", hOpts.ScheduledCommandHandler.DeleteScheduledCommand)
+
 		// Scheduler routes
 		v1R.GET("/schedulers", hOpts.SchedulerHandler.FindAllScheduler)
 		v1R.GET("/scheduler/:id", hOpts.SchedulerHandler.FindSchedulerByID)
@@ -89,10 +175,31 @@ func SetupRouter(
 		v1R.POST("/gatewayLogs/period", hOpts.LogHandler.UpdateGatewayLogCleanPeriod)
 		v1R.GET("/gatewayLogs/:id/period", hOpts.LogHandler.FindGatewayLogsTypeByTime)
 
+		// Retention policy routes
+		v1R.GET("/retentionPolicies", hOpts.RetentionHandler.FindAllRetentionPolicy)
+		v1R.PATCH("/retentionPolicy", hOpts.RetentionHandler.UpdateRetentionPolicy)
+		v1R.POST("/retentionPolicies/run", hOpts.RetentionHandler.RunRetention)
+
+		// Job routes
+		v1R.GET("/jobs", hOpts.JobHandler.FindAllJob)
+
+		// MQTT dead letter routes
+		v1R.GET("/mqttDeadLetters", hOpts.MqttDeadLetterHandler.FindAllMqttDeadLetter)
+		v1R.GET("/mqttDeadLetter/:id", hOpts.MqttDeadLetterHandler.FindMqttDeadLetterByID)
+		v1R.POST("/mqttDeadLetter/:id/replay", hOpts.MqttDeadLetterHandler.ReplayMqttDeadLetter)
+		v1R.POST("/mqttDeadLetter/:id/discard", hOpts.MqttDeadLetterHandler.DiscardMqttDeadLetter)
+
 		// Secret key routes
 		v1R.GET("/secretkeys", hOpts.SecretKeyHandler.FindSecretKey)
 		v1R.POST("/secretkey", hOpts.SecretKeyHandler.CreateSecretKey)
 		v1R.PATCH("/secretkey", hOpts.SecretKeyHandler.UpdateSecretKey)
+
+		// Audit log routes
+		v1R.GET("/auditLogs", hOpts.AuditLogHandler.FindAuditLogs)
+		v1R.GET("/auditLogs/export", hOpts.AuditLogHandler.ExportAuditLogs)
+
+		// Recycle bin routes
+		v1R.GET("/recycleBin", hOpts.RecycleBinHandler.FindRecycleBin)
 	}
 	return r
 }
@@ -102,8 +209,9 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Accept, Origin, Cache-Control, X-Requested-With, User-Agent, Accept-Language, Accept-Encoding")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Accept, Origin, Cache-Control, X-Requested-With, X-Actor, X-Approver-Token, X-Correlation-ID, User-Agent, Accept-Language, Accept-Encoding")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Correlation-ID, Content-Disposition")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -113,3 +221,20 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// Refuse API requests with 503 until every component started and once shutdown began
+func ReadinessMiddleware(lc *lifecycle.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if lc != nil && !lc.IsReady() {
+			c.Header("Retry-After", "5")
+			utils.ResponseJson(c, http.StatusServiceUnavailable, &utils.ErrorResponse{
+				StatusCode: http.StatusServiceUnavailable,
+				Msg:        "Server is not ready",
+				ErrorMsg:   "server is starting or shutting down",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/ecoprohcm/DMS_BackendServer/models"
@@ -28,7 +29,7 @@ func NewStudentHandler(deps *HandlerDependencies) *StudentHandler {
 // @Failure 400 {object} utils.ErrorResponse
 // @Router /v1/students [get]
 func (h *StudentHandler) FindAllStudent(c *gin.Context) {
-	sList, err := h.deps.SvcOpts.StudentSvc.FindAllStudent(c)
+	sList, err := h.deps.SvcOpts.StudentSvc.FindAllStudent(c.Request.Context())
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -52,7 +53,7 @@ func (h *StudentHandler) FindAllStudent(c *gin.Context) {
 func (h *StudentHandler) FindStudentByMSSV(c *gin.Context) {
 	mssv := c.Param("mssv")
 
-	s, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c, mssv)
+	s, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), mssv)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -73,6 +74,7 @@ func (h *StudentHandler) FindStudentByMSSV(c *gin.Context) {
 // @Param	data	body	models.SwagCreateStudent	true	"Fields need to create a student"
 // @Success 200 {object} models.Student
 // @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
 // @Router /v1/student [post]
 func (h *StudentHandler) CreateStudent(c *gin.Context) {
 	s := &models.Student{}
@@ -88,13 +90,18 @@ func (h *StudentHandler) CreateStudent(c *gin.Context) {
 
 	_, err = h.deps.SvcOpts.StudentSvc.CreateStudent(c.Request.Context(), s)
 	if err != nil {
-		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
-			StatusCode: http.StatusBadRequest,
+		code := http.StatusBadRequest
+		if errors.Is(err, models.ErrDeletedRecord) {
+			code = http.StatusConflict
+		}
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
 			Msg:        "Create student failed",
 			ErrorMsg:   err.Error(),
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_STUDENT, s.MSSV, nil, s)
 	utils.ResponseJson(c, http.StatusOK, s)
 }
 
@@ -120,6 +127,7 @@ func (h *StudentHandler) UpdateStudent(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), s.MSSV)
 	isSuccess, err := h.deps.SvcOpts.StudentSvc.UpdateStudent(c.Request.Context(), s)
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -129,9 +137,19 @@ func (h *StudentHandler) UpdateStudent(c *gin.Context) {
 		})
 		return
 	}
+	after, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), s.MSSV)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get student failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_STUDENT, s.MSSV, before, after)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_U, 1, false,
-		mqttSvc.ServerUpdateUserPayload("0", s.MSSV, s.RfidPass, s.KeypadPass))
+		mqttSvc.ServerUpdateUserPayload("0", mqttSvc.NewUserIDPassword(s.MSSV, after.Person)))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -166,7 +184,8 @@ func (h *StudentHandler) DeleteStudent(c *gin.Context) {
 		return
 	}
 
-	isSuccess, err := h.deps.SvcOpts.StudentSvc.DeleteStudent(c.Request.Context(), ds.MSSV)
+	before, _ := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), ds.MSSV)
+	isSuccess, err := h.deps.SvcOpts.StudentSvc.DeleteStudent(c.Request.Context(), ds.MSSV, getActor(c))
 	if err != nil || !isSuccess {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -175,6 +194,7 @@ func (h *StudentHandler) DeleteStudent(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_DELETE, models.AUDIT_ENTITY_STUDENT, ds.MSSV, before, nil)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_D, 1, false,
 		mqttSvc.ServerDeleteUserPayload("0", ds.MSSV))
@@ -190,6 +210,53 @@ func (h *StudentHandler) DeleteStudent(c *gin.Context) {
 	utils.ResponseJson(c, http.StatusOK, isSuccess)
 }
 
+// Restore student
+// @Summary Restore Deleted Student By MSSV
+// @Schemes
+// @Description Restore soft deleted student. Send credentials and registers of the student to MQTT broker
+// @Produce json
+// @Param        mssv	path	string	true	"Student MSSV"
+// @Success 200 {boolean} true
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/student/{mssv}/restore [post]
+func (h *StudentHandler) RestoreStudent(c *gin.Context) {
+	mssv := c.Param("mssv")
+
+	isSuccess, err := h.deps.SvcOpts.StudentSvc.RestoreStudent(c.Request.Context(), mssv)
+	if err != nil || !isSuccess {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore student failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	s, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), mssv)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get student failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.audit(c, models.AUDIT_ACTION_RESTORE, models.AUDIT_ENTITY_STUDENT, s.MSSV, nil, s)
+
+	userTopic := mqttSvc.TOPIC_SV_USER_U
+	err = publishUserAccess(h.deps, c.Request.Context(), mqttSvc.NewUserIDPassword(s.MSSV, s.Person), userTopic, s.Schedulers)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Restore student mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
 // Add student scheduler
 // @Summary Add Door Open Scheduler For Student
 // @Schemes
@@ -213,7 +280,7 @@ func (h *StudentHandler) AppendStudentScheduler(c *gin.Context) {
 		return
 	}
 
-	s, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c, mssv)
+	s, err := h.deps.SvcOpts.StudentSvc.FindStudentByMSSV(c.Request.Context(), mssv)
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
 			StatusCode: http.StatusBadRequest,
@@ -227,7 +294,7 @@ func (h *StudentHandler) AppendStudentScheduler(c *gin.Context) {
 	sche.StudentID = &s.MSSV
 	sche.Role = "student"
 	sche.UserID = s.MSSV
-	_, err = h.deps.SvcOpts.SchedulerSvc.CreateScheduler(c, sche)
+	_, err = h.deps.SvcOpts.SchedulerSvc.CreateScheduler(c.Request.Context(), sche)
 
 	if err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -237,16 +304,13 @@ func (h *StudentHandler) AppendStudentScheduler(c *gin.Context) {
 		})
 		return
 	}
+	h.deps.audit(c, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_SCHEDULER, idString(sche.ID), nil, sche)
 
 	t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_SCHEDULER_C, 1, false, mqttSvc.ServerCreateRegisterPayload(
 		usu.GatewayID,
 		usu.DoorlockAddress,
 		sche,
-		&mqttSvc.UserIDPassword{
-			UserId:     s.MSSV,
-			RfidPass:   s.RfidPass,
-			KeypadPass: s.KeypadPass,
-		},
+		mqttSvc.NewUserIDPassword(s.MSSV, s.Person),
 	))
 	if err := mqttSvc.HandleMqttErr(t); err != nil {
 		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
@@ -269,3 +333,79 @@ func (h *StudentHandler) AppendStudentScheduler(c *gin.Context) {
 
 	utils.ResponseJson(c, http.StatusOK, true)
 }
+
+// Import students
+// @Summary Import Students From CSV Or XLSX
+// @Schemes
+// @Description Create or update students keyed on MSSV, only columns in the file are updated. Columns: mssv, name, phone, email, major, rfidPass, keypadPass. Returns result of every row. Send updated credentials to MQTT broker in batches
+// @Accept  multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"CSV or XLSX file, first row is header"
+// @Param	format	query	string	false	"csv or xlsx, taken from file extension when missing"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/students/import [post]
+func (h *StudentHandler) ImportStudents(c *gin.Context) {
+	rows, err := readImportSheet(c)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	fields, sheetRows, err := models.ParseUserSheet(rows, models.StudentSheetColumns)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid import file",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	ir := h.deps.SvcOpts.StudentSvc.ImportStudents(c.Request.Context(), fields, sheetRows)
+	h.deps.auditImport(c, models.AUDIT_ENTITY_STUDENT, ir)
+
+	users := []mqttSvc.UserIDPassword{}
+	for _, rr := range ir.Rows {
+		if rr.Result != models.IMPORT_RESULT_UPDATED {
+			continue
+		}
+		s := rr.After.(*models.Student)
+		users = append(users, *mqttSvc.NewUserIDPassword(s.MSSV, s.Person))
+	}
+	if err := publishUserBatch(h.deps, users); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Import students mqtt failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	utils.ResponseJson(c, http.StatusOK, ir)
+}
+
+// Export students
+// @Summary Export Students To CSV Or XLSX
+// @Schemes
+// @Description Export all students in the same format as import
+// @Produce text/csv,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param	format	query	string	false	"csv (default) or xlsx"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/students/export [get]
+func (h *StudentHandler) ExportStudents(c *gin.Context) {
+	sList, err := h.deps.SvcOpts.StudentSvc.FindAllStudent(c.Request.Context())
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all students failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	responseExportSheet(c, "students", models.StudentSheet(sList))
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/lifecycle"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 )
 
@@ -16,9 +17,25 @@ type HandlerOptions struct {
 	SchedulerHandler         *SchedulerHandler
 	SecretKeyHandler         *SecretKeyHandler
 	DoorlockStatusLogHandler *DoorlockStatusLogHandler
+	RecycleBinHandler        *RecycleBinHandler
+	AuditLogHandler          *AuditLogHandler
+	PersonHandler            *PersonHandler
+	RevocationHandler        *RevocationHandler
+	AccessGroupHandler       *AccessGroupHandler
+	DoorGroupHandler         *DoorGroupHandler
+	AccessPolicyHandler      *AccessPolicyHandler
+	OccupancyHandler         *OccupancyHandler
+	UnlockRequestHandler     *UnlockRequestHandler
+	ScheduledCommandHandler  *ScheduledCommandHandler
+	RetentionHandler         *RetentionHandler
+	JobHandler               *JobHandler
+	MqttDeadLetterHandler    *MqttDeadLetterHandler
+	HealthHandler            *HealthHandler
+	Lifecycle                *lifecycle.Manager
 }
 
 type HandlerDependencies struct {
 	SvcOpts    *models.ServiceOptions
 	MqttClient mqtt.Client
+	Lifecycle  *lifecycle.Manager
 }
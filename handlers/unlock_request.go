@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type UnlockRequestHandler struct {
+	deps *HandlerDependencies
+}
+
+func NewUnlockRequestHandler(deps *HandlerDependencies) *UnlockRequestHandler {
+	return &UnlockRequestHandler{
+		deps,
+	}
+}
+
+// Find all unlock requests
+// @Summary Find All Unlock Requests
+// @Schemes
+// @Description find unlock requests newest first, pending requests past their approval window are expired
+// @Produce json
+// @Param	status	query	string	false	"pending, approved, rejected or expired"
+// @Success 200 {array} []models.UnlockRequest
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/unlockRequests [get]
+func (h *UnlockRequestHandler) FindAllUnlockRequest(c *gin.Context) {
+	urList, err := h.deps.SvcOpts.UnlockRequestSvc.FindAllUnlockRequest(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get all unlock requests failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, urList)
+}
+
+// Find unlock request by id
+// @Summary Find Unlock Request By ID
+// @Schemes
+// @Description find unlock request by id
+// @Produce json
+// @Param        id	path	string	true	"Unlock request ID"
+// @Success 200 {object} models.UnlockRequest
+// @Failure 400 {object} utils.ErrorResponse
+// @Router /v1/unlockRequest/{id} [get]
+func (h *UnlockRequestHandler) FindUnlockRequestByID(c *gin.Context) {
+	ur, err := h.deps.SvcOpts.UnlockRequestSvc.FindUnlockRequestByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get unlock request failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	utils.ResponseJson(c, http.StatusOK, ur)
+}
+
+// Approve unlock request
+// @Summary Approve Unlock Request By ID
+// @Schemes
+// @Description Approve pending unlock request and send its command to MQTT broker. The approver is identified by its token in UNLOCK_APPROVER_TOKENS and must differ from the requester
+// @Produce json
+// @Param        id	path	string	true	"Unlock request ID"
+// @Param        X-Approver-Token	header	string	true	"Approver token"
+// @Success 200 {object} models.UnlockRequest
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Failure 503 {object} utils.ErrorResponse
+// @Router /v1/unlockRequest/{id}/approve [post]
+func (h *UnlockRequestHandler) ApproveUnlockRequest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid unlock request id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	// Approval is kept pending while the command cannot be sent
+	if !h.deps.requireMqttConnection(c) {
+		return
+	}
+
+	// Approval is committed only once the command is sent, a request failing to execute stays pending to be approved again,
+	// which sends the command again when only saving the doorlock state failed.
+	// The request context carries the unit of work meanwhile so the audits take part in it
+	var ur *models.UnlockRequest
+	approved := false
+	reqCtx := c.Request.Context()
+	err = h.deps.SvcOpts.UnitOfWork.Do(reqCtx, func(ctx context.Context) error {
+		c.Request = c.Request.WithContext(ctx)
+		defer func() { c.Request = c.Request.WithContext(reqCtx) }()
+		var err error
+		if ur, err = h.deps.SvcOpts.UnlockRequestSvc.ApproveUnlockRequest(ctx, uint(id), c.GetHeader(APPROVER_TOKEN_HEADER)); err != nil {
+			return err
+		}
+		approved = true
+		h.deps.auditAs(c, ur.DecidedBy, models.AUDIT_ACTION_APPROVE, models.AUDIT_ENTITY_UNLOCK_REQUEST, idString(ur.ID), nil, ur)
+		return h.execute(c, ur)
+	})
+	if err != nil {
+		code, msg := unlockRequestErrCode(err), "Approve unlock request failed"
+		if approved {
+			msg = "Execute unlock request failed"
+		}
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
+			Msg:        msg,
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	if executed, err := h.deps.SvcOpts.UnlockRequestSvc.FindUnlockRequestByID(c.Request.Context(), idString(ur.ID)); err == nil {
+		ur = executed
+	}
+	utils.ResponseJson(c, http.StatusOK, ur)
+}
+
+// Reject unlock request
+// @Summary Reject Unlock Request By ID
+// @Schemes
+// @Description Reject pending unlock request with an approver token, the requester can reject its own request to cancel it
+// @Produce json
+// @Param        id	path	string	true	"Unlock request ID"
+// @Param        X-Approver-Token	header	string	true	"Approver token"
+// @Success 200 {object} models.UnlockRequest
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /v1/unlockRequest/{id}/reject [post]
+func (h *UnlockRequestHandler) RejectUnlockRequest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid unlock request id",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	ur, err := h.deps.SvcOpts.UnlockRequestSvc.RejectUnlockRequest(c.Request.Context(), uint(id), c.GetHeader(APPROVER_TOKEN_HEADER))
+	if err != nil {
+		code := unlockRequestErrCode(err)
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
+			Msg:        "Reject unlock request failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	h.deps.auditAs(c, ur.DecidedBy, models.AUDIT_ACTION_REJECT, models.AUDIT_ENTITY_UNLOCK_REQUEST, idString(ur.ID), nil, ur)
+	utils.ResponseJson(c, http.StatusOK, ur)
+}
+
+// Flag doorlock as requiring approval
+// @Summary Update Doorlock Requires Approval
+// @Schemes
+// @Description Flag doorlock so that unlock commands on it or on its Block wait for a second operator. Clearing the flag would let one operator unlock at once, so it creates a pending unlock request instead
+// @Accept  json
+// @Produce json
+// @Param	data	body	models.DoorlockApproval	true	"Doorlock ID and flag"
+// @Param        X-Approver-Token	header	string	false	"Approver token, required when the command waits for approval"
+// @Success 200 {boolean} true
+// @Success 202 {object} models.UnlockRequest
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 401 {object} utils.ErrorResponse
+// @Router /v1/doorlock/approval [patch]
+func (h *UnlockRequestHandler) UpdateDoorlockApproval(c *gin.Context) {
+	da := &models.DoorlockApproval{}
+	err := c.ShouldBind(da)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Invalid req body",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	before, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(c.Request.Context(), idString(da.ID))
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Get doorlock failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	if before.RequiresApproval && !da.RequiresApproval {
+		h.deps.requestUnlockApproval(c, &models.UnlockRequest{
+			Command: models.UNLOCK_CMD_APPROVAL_OFF,
+			DoorID:  &before.ID,
+		})
+		return
+	}
+
+	isSuccess, err := h.deps.SvcOpts.DoorlockSvc.SetDoorlockRequiresApproval(c.Request.Context(), da.ID, da.RequiresApproval)
+	if err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Update doorlock approval failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	after, _ := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(c.Request.Context(), idString(da.ID))
+	h.deps.audit(c, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_DOORLOCK, idString(da.ID), before, after)
+	utils.ResponseJson(c, http.StatusOK, isSuccess)
+}
+
+// Send approved command to MQTT broker and save the doorlock state, as the command endpoints do without approval
+func (h *UnlockRequestHandler) execute(c *gin.Context, ur *models.UnlockRequest) error {
+	ctx := c.Request.Context()
+	switch ur.Command {
+	case models.UNLOCK_CMD_DOORLOCK, models.UNLOCK_CMD_DOORLOCK_STATE:
+		if ur.DoorID == nil {
+			return fmt.Errorf("unlock request has no doorlock")
+		}
+		cmd := &models.DoorlockCmd{ID: idString(*ur.DoorID), State: ur.State, Duration: ur.Duration}
+		dl, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(ctx, cmd.ID)
+		if err != nil {
+			return err
+		}
+		t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_DOORLOCK_CMD, 1, false,
+			mqttSvc.ServerCmdDoorlockPayload(dl.GatewayID, dl.DoorlockAddress, cmd))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+		h.deps.auditAs(c, ur.DecidedBy, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_DOORLOCK, cmd.ID, nil, cmd)
+		if ur.Command == models.UNLOCK_CMD_DOORLOCK {
+			_, err = h.deps.SvcOpts.DoorlockSvc.UpdateDoorlockState(ctx, cmd)
+		} else {
+			_, err = h.deps.SvcOpts.DoorlockSvc.UpdateDoorlockStateCmd(ctx, cmd)
+		}
+		if err != nil {
+			return err
+		}
+	case models.UNLOCK_CMD_BLOCK:
+		gwList, err := h.deps.SvcOpts.GatewaySvc.FindAllGatewaysByBlockID(ctx, ur.BlockID)
+		if err != nil {
+			return err
+		}
+		for _, v := range gwList {
+			t := h.deps.MqttClient.Publish(mqttSvc.TOPIC_SV_DOORLOCK_CMD, 1, false, mqttSvc.ServerUpdateGatewayCmd(v, ur.State))
+			if err := mqttSvc.HandleMqttErr(t); err != nil {
+				return err
+			}
+		}
+		cmd := &models.GatewayBlockCmd{BlockId: ur.BlockID, Action: ur.State, Reason: ur.Reason}
+		h.deps.auditAs(c, ur.DecidedBy, models.AUDIT_ACTION_COMMAND, models.AUDIT_ENTITY_BLOCK, ur.BlockID, nil, cmd)
+		if _, err := h.deps.SvcOpts.GatewaySvc.UpdateAllDoorlocksStateByBlockID(ctx, ur.BlockID, ur.State); err != nil {
+			return err
+		}
+	case models.UNLOCK_CMD_APPROVAL_OFF:
+		if ur.DoorID == nil {
+			return fmt.Errorf("unlock request has no doorlock")
+		}
+		before, err := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(ctx, idString(*ur.DoorID))
+		if err != nil {
+			return err
+		}
+		if _, err := h.deps.SvcOpts.DoorlockSvc.SetDoorlockRequiresApproval(ctx, *ur.DoorID, false); err != nil {
+			return err
+		}
+		after, _ := h.deps.SvcOpts.DoorlockSvc.FindDoorlockByID(ctx, idString(*ur.DoorID))
+		h.deps.auditAs(c, ur.DecidedBy, models.AUDIT_ACTION_UPDATE, models.AUDIT_ENTITY_DOORLOCK, idString(*ur.DoorID), before, after)
+	default:
+		return fmt.Errorf("unknown unlock command %s", ur.Command)
+	}
+
+	_, err := h.deps.SvcOpts.UnlockRequestSvc.MarkUnlockRequestExecuted(ctx, ur.ID)
+	return err
+}
+
+// Hold unlock command for a second operator, responds 202 with the pending request.
+// The requester is the approver holding the token, X-Actor is ignored so it can not approve the request itself
+func (deps *HandlerDependencies) requestUnlockApproval(c *gin.Context, ur *models.UnlockRequest) {
+	ur, err := deps.SvcOpts.UnlockRequestSvc.CreateUnlockRequest(c.Request.Context(), ur, c.GetHeader(APPROVER_TOKEN_HEADER))
+	if err != nil {
+		code := unlockRequestErrCode(err)
+		utils.ResponseJson(c, code, &utils.ErrorResponse{
+			StatusCode: code,
+			Msg:        "Create unlock request failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+	deps.auditAs(c, ur.RequestedBy, models.AUDIT_ACTION_CREATE, models.AUDIT_ENTITY_UNLOCK_REQUEST, idString(ur.ID), nil, ur)
+	utils.ResponseJson(c, http.StatusAccepted, ur)
+}
+
+// Requests without the token of an approver are unauthorized
+func unlockRequestErrCode(err error) int {
+	if errors.Is(err, models.ErrApproverTokenInvalid) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
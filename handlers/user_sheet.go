@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const IMPORT_FILE_FIELD string = "file"
+
+// Read uploaded import file, format is taken from "format" query or file extension
+func readImportSheet(c *gin.Context) ([][]string, error) {
+	fh, err := c.FormFile(IMPORT_FILE_FIELD)
+	if err != nil {
+		return nil, err
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = utils.SheetFormatFromFileName(fh.Filename)
+	}
+	if format != utils.SHEET_FORMAT_CSV && format != utils.SHEET_FORMAT_XLSX {
+		return nil, fmt.Errorf("format must be %s or %s", utils.SHEET_FORMAT_CSV, utils.SHEET_FORMAT_XLSX)
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return utils.ReadSheet(f, format)
+}
+
+// Send sheet rows as downloaded CSV or XLSX file, format is taken from "format" query
+func responseExportSheet(c *gin.Context, name string, rows [][]string) {
+	format := c.DefaultQuery("format", utils.SHEET_FORMAT_CSV)
+	contentType := "text/csv"
+	if format == utils.SHEET_FORMAT_XLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	buf := &bytes.Buffer{}
+	if err := utils.WriteSheet(buf, format, rows); err != nil {
+		utils.ResponseJson(c, http.StatusBadRequest, &utils.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Msg:        "Export failed",
+			ErrorMsg:   err.Error(),
+		})
+		return
+	}
+
+	fileName := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// Record audit logs for created and updated rows of an import
+func (deps *HandlerDependencies) auditImport(c *gin.Context, entityType string, ir *models.ImportResult) {
+	for _, rr := range ir.Rows {
+		switch rr.Result {
+		case models.IMPORT_RESULT_CREATED:
+			deps.audit(c, models.AUDIT_ACTION_CREATE, entityType, rr.UserID, nil, rr.After)
+		case models.IMPORT_RESULT_UPDATED:
+			deps.audit(c, models.AUDIT_ACTION_UPDATE, entityType, rr.UserID, rr.Before, rr.After)
+		}
+	}
+}
+
+// Send user credentials to MQTT broker, MAX_USER_BATCH_SIZE users per message
+func publishUserBatch(deps *HandlerDependencies, users []mqttSvc.UserIDPassword) error {
+	for start := 0; start < len(users); start += mqttSvc.MAX_USER_BATCH_SIZE {
+		end := start + mqttSvc.MAX_USER_BATCH_SIZE
+		if end > len(users) {
+			end = len(users)
+		}
+		t := deps.MqttClient.Publish(mqttSvc.TOPIC_SV_USER_BATCH_U, 1, false,
+			mqttSvc.ServerBatchUpdateUserPayload("0", users[start:end]))
+		if err := mqttSvc.HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
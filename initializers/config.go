@@ -2,13 +2,34 @@ package initializers
 
 type Config struct {
 	ServerHost string `envconfig:"SERVER_HOST"`
+	DbDriver   string `envconfig:"DB_DRIVER" default:"sqlserver"` // sqlserver, postgres, sqlite
 	DbHost     string `envconfig:"DB_HOST"`
 	DbPort     string `envconfig:"DB_PORT"`
 	DbUser     string `envconfig:"DB_USER"`
 	DbPass     string `envconfig:"DB_PASS"`
-	DbName     string `envconfig:"DB_NAME"`
+	DbName     string `envconfig:"DB_NAME"` // file path or ":memory:" when using sqlite
 	MqttHost   string `envconfig:"MQTT_HOST"`
 	MqttPort   string `envconfig:"MQTT_PORT"`
 	MqttClient string `envconfig:"MQTT_CLIENT"`
 	SvLogPath  string `envconfig:"SV_LOG_FILE"`
+
+	MqttWorkers   uint `envconfig:"MQTT_WORKERS" default:"8"`        // gateway messages are handled in parallel, in order per gateway
+	MqttQueueSize uint `envconfig:"MQTT_QUEUE_SIZE" default:"1000"`  // messages waiting per worker before receiving blocks
+	MqttOutboxMax uint `envconfig:"MQTT_OUTBOX_MAX" default:"10000"` // messages queued while the broker is unreachable, oldest dropped beyond this
+
+	MqttUsername     string `envconfig:"MQTT_USERNAME"` // server connects with these, the embedded broker requires them from every client
+	MqttPassword     string `envconfig:"MQTT_PASSWORD"`
+	MqttEmbedded     bool   `envconfig:"MQTT_EMBEDDED" default:"false"`      // run the broker in process instead of connecting to MQTT_PORT, for tests and development only: no persistent sessions, QoS 1 is not redelivered after a reconnect
+	MqttEmbeddedAddr string `envconfig:"MQTT_EMBEDDED_ADDR" default:":8883"` // address gateways connect to
+	MqttTlsCert      string `envconfig:"MQTT_TLS_CERT"`                      // PEM certificate and key of the embedded broker, plain TCP when empty
+	MqttTlsKey       string `envconfig:"MQTT_TLS_KEY"`
+
+	HttpDrainSeconds uint `envconfig:"HTTP_DRAIN_SECONDS" default:"15"` // in-flight requests are given this long on shutdown
+
+	SoftDeleteRetentionDays uint `envconfig:"SOFT_DELETE_RETENTION_DAYS" default:"30"` // deleted records are purged after this
+
+	UnlockApproverTokens  []string `envconfig:"UNLOCK_APPROVER_TOKENS"`               // comma separated name:token of operators requesting and approving, no request can be made when empty
+	UnlockApprovalMinutes uint     `envconfig:"UNLOCK_APPROVAL_MINUTES" default:"15"` // pending unlock requests expire after this
+
+	RetentionArchiveDir string `envconfig:"RETENTION_ARCHIVE_DIR" default:"./archives"` // compressed rows archived by retention policies
 }
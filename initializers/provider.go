@@ -1,55 +1,164 @@
 package initializers
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/broker"
 	"github.com/ecoprohcm/DMS_BackendServer/handlers"
+	"github.com/ecoprohcm/DMS_BackendServer/lifecycle"
 	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/glebarez/sqlite"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
 
+const (
+	DB_DRIVER_SQLSERVER string = "sqlserver"
+	DB_DRIVER_POSTGRES  string = "postgres"
+	DB_DRIVER_SQLITE    string = "sqlite"
+)
+
 type ContextContainer struct {
 	Config         Config
 	Db             *gorm.DB
+	SvcOptions     *models.ServiceOptions
 	MqttClient     mqtt.Client
 	HandlerOptions *handlers.HandlerOptions
+	Lifecycle      *lifecycle.Manager
 }
 
-func ProvideConfig(envFilePath string) (Config, error) {
+// Load config and open the server log, the log file is flushed and closed last on cleanup
+func ProvideConfig(envFilePath string) (Config, func(), error) {
 	cfg := Config{}
 	err := godotenv.Load(envFilePath) //use env.local for localhost
 	if err != nil {
 		fmt.Printf("Error loading .env file %s", err)
-		return Config{}, err
+		return Config{}, nil, err
 	}
 	err = envconfig.Process("", &cfg)
 	if err != nil {
-		return cfg, err
+		return cfg, nil, err
 	}
 
 	logger.InitLogger(cfg.SvLogPath)
-	return cfg, nil
+	return cfg, func() {
+		if err := logger.CloseLogger(); err != nil {
+			fmt.Printf("Close log file failed: %s\n", err)
+		}
+	}, nil
+}
+
+// Open database for the server, refuse to start when schema is not at the
+// version expected by this binary
+func ProvideGormDb(config Config) (*gorm.DB, func(), error) {
+	db, err := openGormDb(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { closeGormDb(db) }
+	if err := migrations.NewMigrator(db).CheckVersion(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return db, cleanup, nil
+}
+
+// Open database and bring its schema up to date, for throwaway databases such as in-memory SQLite in tests
+func ProvideMigratedGormDb(config Config) (*gorm.DB, func(), error) {
+	db, err := openGormDb(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { closeGormDb(db) }
+	if err := migrations.NewMigrator(db).Up(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return db, cleanup, nil
+}
+
+// Open database for the migrate command, schema version is not checked
+func ProvideMigrator(config Config) (*migrations.Migrator, func(), error) {
+	db, err := openGormDb(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrations.NewMigrator(db), func() { closeGormDb(db) }, nil
 }
 
-func ProvideGormDb(config Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", config.DbUser, config.DbPass, config.ServerHost, config.DbPort, config.DbName)
-	db, err := gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
+func closeGormDb(db *gorm.DB) {
+	sqlDb, err := db.DB()
+	if err == nil {
+		err = sqlDb.Close()
+	}
+	if err != nil {
+		logger.LogfWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Close database failed: %s", err.Error())
+		return
+	}
+	logger.LogWithoutFields(logger.SQLSERVER, logger.InfoLevel, "Database closed")
+}
+
+func openGormDb(config Config) (*gorm.DB, error) {
+	dialector, err := newDialector(config)
+	if err != nil {
+		return nil, err
+	}
+	gormConfig := &gorm.Config{}
+	if config.DbDriver == DB_DRIVER_SQLITE {
+		// SQLite compares timestamps as text, keep them all in UTC
+		gormConfig.NowFunc = func() time.Time { return time.Now().UTC() }
+	}
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		fmt.Println("failed to connect database")
 		return nil, err
 	}
-	models.Migrate(db)
+	if config.DbDriver == DB_DRIVER_SQLITE {
+		// SQLite allows a single writer, and every new connection to an
+		// in-memory database would open an empty database
+		sqlDb, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDb.SetMaxOpenConns(1)
+	}
 	return db, nil
 }
 
-func ProvideSvcOptions(db *gorm.DB) *models.ServiceOptions {
-	return &models.ServiceOptions{
+// Select gorm dialector based on DB_DRIVER, default is sqlserver
+func newDialector(config Config) (gorm.Dialector, error) {
+	switch config.DbDriver {
+	case DB_DRIVER_SQLSERVER, "":
+		dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s", config.DbUser, config.DbPass, config.ServerHost, config.DbPort, config.DbName)
+		return sqlserver.Open(dsn), nil
+	case DB_DRIVER_POSTGRES:
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", config.ServerHost, config.DbPort, config.DbUser, config.DbPass, config.DbName)
+		return postgres.Open(dsn), nil
+	case DB_DRIVER_SQLITE:
+		return sqlite.Open(fmt.Sprintf("%s?_pragma=foreign_keys(1)&_time_format=sqlite", config.DbName)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %s", config.DbDriver)
+	}
+}
+
+func ProvideSvcOptions(config Config, db *gorm.DB) (*models.ServiceOptions, error) {
+	approvers, err := models.ParseUnlockApprovers(config.UnlockApproverTokens)
+	if err != nil {
+		return nil, err
+	}
+	svcOptions := &models.ServiceOptions{
 		GatewaySvc:           models.NewGatewaySvc(db),
 		GwNetworkSvc:         models.NewGwNetworkSvc(db),
 		AreaSvc:              models.NewAreaSvc(db),
@@ -61,22 +170,124 @@ func ProvideSvcOptions(db *gorm.DB) *models.ServiceOptions {
 		CustomerSvc:          models.NewCustomerSvc(db),
 		SecretKeySvc:         models.NewSecretKeySvc(db),
 		DoorlockStatusLogSvc: models.NewDoorlockStatusLogSvc(db),
+		RecycleBinSvc:        models.NewRecycleBinSvc(db, config.SoftDeleteRetentionDays),
+		AuditLogSvc:          models.NewAuditLogSvc(db),
+		PersonSvc:            models.NewPersonSvc(db),
+		RevocationSvc:        models.NewCredentialRevocationSvc(db),
+		AccessGroupSvc:       models.NewAccessGroupSvc(db),
+		DoorGroupSvc:         models.NewDoorGroupSvc(db),
+		AccessPolicySvc:      models.NewAccessPolicySvc(db),
+		OccupancySvc:         models.NewOccupancySvc(db),
+		UnlockRequestSvc: models.NewUnlockRequestSvc(db, approvers,
+			time.Duration(config.UnlockApprovalMinutes)*time.Minute),
+		ScheduledCommandSvc: models.NewScheduledCommandSvc(db),
+		RetentionSvc:        models.NewRetentionSvc(db, config.RetentionArchiveDir),
+		JobRunner:           models.NewJobRunner(db, models.SystemClock{}),
+		HealthSvc:           models.NewHealthSvc(db),
+		MqttOutboxSvc:       models.NewMqttOutboxSvc(db, config.MqttOutboxMax),
+		MqttDeadLetterSvc:   models.NewMqttDeadLetterSvc(db),
+		UnitOfWork:          models.NewUnitOfWork(db),
 	}
+
+	// Jobs needing the MQTT client are registered with it, the runner starts once all are registered
+	if err := svcOptions.JobRunner.Register(models.JOB_RECYCLE_BIN_PURGE, models.DEFAULT_PURGE_PERIOD,
+		svcOptions.RecycleBinSvc.PurgeExpiredJob); err != nil {
+		return nil, err
+	}
+	if err := svcOptions.JobRunner.Register(models.JOB_RETENTION, models.DEFAULT_RETENTION_PERIOD,
+		svcOptions.RetentionSvc.RunRetentionJob); err != nil {
+		return nil, err
+	}
+	return svcOptions, nil
+}
+
+// Start the embedded broker when enabled, nil otherwise. It is closed after the server client disconnected
+func ProvideMqttBroker(config Config) (*broker.Broker, func(), error) {
+	if !config.MqttEmbedded {
+		return nil, func() {}, nil
+	}
+	var tlsConfig *tls.Config
+	if config.MqttTlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.MqttTlsCert, config.MqttTlsKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load MQTT TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	b := broker.New(broker.Config{
+		Address:   config.MqttEmbeddedAddr,
+		TLSConfig: tlsConfig,
+		Username:  config.MqttUsername,
+		Password:  config.MqttPassword,
+	})
+	if err := b.Start(); err != nil {
+		return nil, nil, fmt.Errorf("start embedded MQTT broker: %w", err)
+	}
+	logger.LogWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Embedded MQTT broker is for tests and development only, gateways reconnecting lose QoS 1 messages sent meanwhile")
+	return b, func() {
+		if err := b.Close(); err != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Close embedded broker failed: %s", err)
+		}
+	}, nil
+}
+
+// Broker of the server client, the embedded one is reached over loopback with the
+// certificate checked against SERVER_HOST
+func mqttBrokerConfig(config Config, b *broker.Broker) mqttSvc.BrokerConfig {
+	bc := mqttSvc.BrokerConfig{
+		Username: config.MqttUsername,
+		Password: config.MqttPassword,
+	}
+	if b == nil {
+		bc.URL = fmt.Sprintf("ssl://%s:%s", config.ServerHost, config.MqttPort)
+		bc.TLSConfig = mqttSvc.NewTlsConfig()
+		return bc
+	}
+	port := b.Addr().(*net.TCPAddr).Port
+	if config.MqttTlsCert == "" {
+		bc.URL = fmt.Sprintf("tcp://127.0.0.1:%d", port)
+		return bc
+	}
+	bc.URL = fmt.Sprintf("ssl://127.0.0.1:%d", port)
+	bc.TLSConfig = mqttSvc.NewTlsConfig()
+	bc.TLSConfig.ServerName = config.ServerHost
+	return bc
 }
 
-func ProvideMqttClient(config Config, svcOptions *models.ServiceOptions) mqtt.Client {
-	return mqttSvc.MqttClient(
+func ProvideMqttClient(config Config, svcOptions *models.ServiceOptions, b *broker.Broker) (mqtt.Client, func()) {
+	client := mqttSvc.MqttClient(
 		config.MqttClient,
-		config.ServerHost,
-		config.MqttPort,
+		mqttBrokerConfig(config, b),
+		mqttPipelineConfig(config),
 		svcOptions,
 	)
+	return client, func() { mqttSvc.Shutdown(client, svcOptions) }
 }
 
-func ProvideHandlerOptions(svcOptions *models.ServiceOptions, mqttClient mqtt.Client) *handlers.HandlerOptions {
+// Connection the server MQTT client is built on instead of a broker, e.g. a recording fake in tests
+type MqttTransport mqtt.Client
+
+func ProvideTransportMqttClient(config Config, svcOptions *models.ServiceOptions, transport MqttTransport) (mqtt.Client, func()) {
+	client := mqttSvc.NewServerClient(transport, mqttPipelineConfig(config), svcOptions)
+	return client, func() { mqttSvc.Shutdown(client, svcOptions) }
+}
+
+func mqttPipelineConfig(config Config) mqttSvc.PipelineConfig {
+	return mqttSvc.PipelineConfig{
+		Workers:   int(config.MqttWorkers),
+		QueueSize: int(config.MqttQueueSize),
+	}
+}
+
+func ProvideLifecycle(config Config) *lifecycle.Manager {
+	return lifecycle.NewManager(time.Duration(config.HttpDrainSeconds) * time.Second)
+}
+
+func ProvideHandlerOptions(svcOptions *models.ServiceOptions, mqttClient mqtt.Client, lc *lifecycle.Manager) *handlers.HandlerOptions {
 	deps := &handlers.HandlerDependencies{
 		SvcOpts:    svcOptions,
 		MqttClient: mqttClient,
+		Lifecycle:  lc,
 	}
 
 	return &handlers.HandlerOptions{
@@ -90,14 +301,65 @@ func ProvideHandlerOptions(svcOptions *models.ServiceOptions, mqttClient mqtt.Cl
 		SchedulerHandler:         handlers.NewSchedulerHandler(deps),
 		SecretKeyHandler:         handlers.NewSecretKeyHandler(deps),
 		DoorlockStatusLogHandler: handlers.NewDoorlockStatusLogHandler(deps),
+		RecycleBinHandler:        handlers.NewRecycleBinHandler(deps),
+		AuditLogHandler:          handlers.NewAuditLogHandler(deps),
+		PersonHandler:            handlers.NewPersonHandler(deps),
+		RevocationHandler:        handlers.NewRevocationHandler(deps),
+		AccessGroupHandler:       handlers.NewAccessGroupHandler(deps),
+		DoorGroupHandler:         handlers.NewDoorGroupHandler(deps),
+		AccessPolicyHandler:      handlers.NewAccessPolicyHandler(deps),
+		OccupancyHandler:         handlers.NewOccupancyHandler(deps),
+		UnlockRequestHandler:     handlers.NewUnlockRequestHandler(deps),
+		ScheduledCommandHandler:  handlers.NewScheduledCommandHandler(deps),
+		RetentionHandler:         handlers.NewRetentionHandler(deps),
+		JobHandler:               handlers.NewJobHandler(deps),
+		MqttDeadLetterHandler:    handlers.NewMqttDeadLetterHandler(deps),
+		HealthHandler:            handlers.NewHealthHandler(deps),
+		Lifecycle:                lc,
 	}
 }
 
-func ProvideAppInfrastructure(config Config, db *gorm.DB, mqttClient mqtt.Client, handlerOpts *handlers.HandlerOptions) *ContextContainer {
-	return &ContextContainer{
+// Components are started by their providers in dependency order: database, services, MQTT,
+// handlers, then background jobs. The application is ready once all of them started, wire
+// cleanups stop them in reverse order
+func ProvideAppInfrastructure(config Config, db *gorm.DB, svcOptions *models.ServiceOptions, mqttClient mqtt.Client,
+	handlerOpts *handlers.HandlerOptions, lc *lifecycle.Manager) (*ContextContainer, func()) {
+	registerMetrics(db, svcOptions)
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	svcOptions.JobRunner.Start(jobCtx)
+	lc.SetReady(true)
+
+	cc := &ContextContainer{
 		Config:         config,
 		Db:             db,
+		SvcOptions:     svcOptions,
 		MqttClient:     mqttClient,
 		HandlerOptions: handlerOpts,
+		Lifecycle:      lc,
+	}
+	return cc, func() {
+		lc.SetReady(false)
+		stopJobs()
+		svcOptions.JobRunner.Wait()
+		logger.LogWithoutFields(logger.DMSSERVER, logger.InfoLevel, "Background jobs stopped")
+	}
+}
+
+func registerMetrics(db *gorm.DB, svcOptions *models.ServiceOptions) {
+	err := metrics.RegisterGatewaysOnline(func() float64 {
+		cnt, _ := svcOptions.HealthSvc.CountOnlineGateway(context.Background())
+		return float64(cnt)
+	})
+	if err == nil {
+		err = metrics.RegisterMqttOutboxSize(func() float64 {
+			cnt, _ := svcOptions.MqttOutboxSvc.CountMqttOutboxMessage(context.Background())
+			return float64(cnt)
+		})
+	}
+	if sqlDb, dbErr := db.DB(); err == nil && dbErr == nil {
+		err = metrics.RegisterDBStats(sqlDb)
+	}
+	if err != nil {
+		logger.LogfWithoutFields(logger.DMSSERVER, logger.ErrorLevel, "Register metrics failed: %s", err.Error())
 	}
 }
@@ -4,6 +4,7 @@
 package initializers
 
 import (
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
 	"github.com/google/wire"
 )
 
@@ -11,7 +12,19 @@ var ApplicationSet = wire.NewSet(
 	ProvideConfig,
 	ProvideGormDb,
 	ProvideSvcOptions,
+	ProvideMqttBroker,
 	ProvideMqttClient,
+	ProvideLifecycle,
+	ProvideHandlerOptions,
+	ProvideAppInfrastructure,
+)
+
+// ApplicationSet on a database migrated at startup and a given MQTT transport, for hermetic tests
+var TestApplicationSet = wire.NewSet(
+	ProvideMigratedGormDb,
+	ProvideSvcOptions,
+	ProvideTransportMqttClient,
+	ProvideLifecycle,
 	ProvideHandlerOptions,
 	ProvideAppInfrastructure,
 )
@@ -22,3 +35,18 @@ func InitApplication(envFilePath string) (*ContextContainer, func(), error) {
 	)
 	return nil, nil, nil
 }
+
+func InitMigrator(envFilePath string) (*migrations.Migrator, func(), error) {
+	wire.Build(
+		ProvideConfig,
+		ProvideMigrator,
+	)
+	return nil, nil, nil
+}
+
+func InitTestApplication(config Config, transport MqttTransport) (*ContextContainer, func(), error) {
+	wire.Build(
+		TestApplicationSet,
+	)
+	return nil, nil, nil
+}
@@ -7,25 +7,81 @@
 package initializers
 
 import (
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
 	"github.com/google/wire"
 )
 
 // Injectors from wire.go:
 
 func InitApplication(envFilePath string) (*ContextContainer, func(), error) {
-	config, err := ProvideConfig(envFilePath)
+	config, cleanup, err := ProvideConfig(envFilePath)
 	if err != nil {
 		return nil, nil, err
 	}
-	db, err := ProvideGormDb(config)
+	db, cleanup2, err := ProvideGormDb(config)
 	if err != nil {
+		cleanup()
 		return nil, nil, err
 	}
-	serviceOptions := ProvideSvcOptions(db)
-	client := ProvideMqttClient(config, serviceOptions)
-	handlerOptions := ProvideHandlerOptions(serviceOptions, client)
-	contextContainer := ProvideAppInfrastructure(config, db, client, handlerOptions)
+	serviceOptions, err := ProvideSvcOptions(config, db)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	broker, cleanup3, err := ProvideMqttBroker(config)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	client, cleanup4 := ProvideMqttClient(config, serviceOptions, broker)
+	manager := ProvideLifecycle(config)
+	handlerOptions := ProvideHandlerOptions(serviceOptions, client, manager)
+	contextContainer, cleanup5 := ProvideAppInfrastructure(config, db, serviceOptions, client, handlerOptions, manager)
+	return contextContainer, func() {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func InitMigrator(envFilePath string) (*migrations.Migrator, func(), error) {
+	config, cleanup, err := ProvideConfig(envFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrator, cleanup2, err := ProvideMigrator(config)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return migrator, func() {
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func InitTestApplication(config Config, transport MqttTransport) (*ContextContainer, func(), error) {
+	db, cleanup, err := ProvideMigratedGormDb(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceOptions, err := ProvideSvcOptions(config, db)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	client, cleanup2 := ProvideTransportMqttClient(config, serviceOptions, transport)
+	manager := ProvideLifecycle(config)
+	handlerOptions := ProvideHandlerOptions(serviceOptions, client, manager)
+	contextContainer, cleanup3 := ProvideAppInfrastructure(config, db, serviceOptions, client, handlerOptions, manager)
 	return contextContainer, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
 	}, nil
 }
 
@@ -35,7 +91,19 @@ var ApplicationSet = wire.NewSet(
 	ProvideConfig,
 	ProvideGormDb,
 	ProvideSvcOptions,
+	ProvideMqttBroker,
 	ProvideMqttClient,
+	ProvideLifecycle,
+	ProvideHandlerOptions,
+	ProvideAppInfrastructure,
+)
+
+// ApplicationSet on a database migrated at startup and a given MQTT transport, for hermetic tests
+var TestApplicationSet = wire.NewSet(
+	ProvideMigratedGormDb,
+	ProvideSvcOptions,
+	ProvideTransportMqttClient,
+	ProvideLifecycle,
 	ProvideHandlerOptions,
 	ProvideAppInfrastructure,
 )
@@ -0,0 +1,89 @@
+// Package lifecycle runs the HTTP server until SIGTERM/SIGINT and
+// shuts the application down in order
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+)
+
+const (
+	// In-flight HTTP requests are given this long to finish on shutdown
+	DEFAULT_DRAIN_TIMEOUT time.Duration = 15 * time.Second
+)
+
+// Manager tracks readiness, the application is ready once every component started
+// and not ready as soon as shutdown begins
+type Manager struct {
+	ready        int32
+	drainTimeout time.Duration
+}
+
+func NewManager(drainTimeout time.Duration) *Manager {
+	if drainTimeout <= 0 {
+		drainTimeout = DEFAULT_DRAIN_TIMEOUT
+	}
+	return &Manager{
+		drainTimeout: drainTimeout,
+	}
+}
+
+func (m *Manager) IsReady() bool {
+	return atomic.LoadInt32(&m.ready) == 1
+}
+
+func (m *Manager) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&m.ready, v)
+}
+
+// Serve srv until SIGTERM/SIGINT, then stop accepting requests, drain the in-flight ones
+// and run cleanup, which stops the other components in reverse start order
+func (m *Manager) Run(srv *http.Server, cleanup func()) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	return m.run(ctx, srv, cleanup)
+}
+
+func (m *Manager) run(ctx context.Context, srv *http.Server, cleanup func()) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.LogfWithoutFields(logger.DMSSERVER, logger.InfoLevel, "HTTP server listening on %s", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		logger.LogfWithoutFields(logger.DMSSERVER, logger.ErrorLevel, "HTTP server stopped: %s", err.Error())
+	case <-ctx.Done():
+		logger.LogWithoutFields(logger.DMSSERVER, logger.InfoLevel, "Shutdown signal received")
+	}
+
+	m.SetReady(false)
+	drainCtx, cancel := context.WithTimeout(context.Background(), m.drainTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(drainCtx); shutdownErr != nil {
+		logger.LogfWithoutFields(logger.DMSSERVER, logger.ErrorLevel, "Drain HTTP requests failed: %s", shutdownErr.Error())
+	}
+	logger.LogWithoutFields(logger.DMSSERVER, logger.InfoLevel, "HTTP server stopped, stopping components")
+	if cleanup != nil {
+		cleanup()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
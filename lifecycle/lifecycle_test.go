@@ -0,0 +1,51 @@
+//go:build unit
+// +build unit
+
+package lifecycle
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunShutdown(t *testing.T) {
+	m := NewManager(time.Second)
+	m.SetReady(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{Addr: "127.0.0.1:0", Handler: http.NotFoundHandler()}
+	cleaned := make(chan bool, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.run(ctx, srv, func() {
+			if m.IsReady() {
+				t.Errorf("expected not ready before cleanup")
+			}
+			cleaned <- true
+		})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("run did not return after shutdown signal")
+	}
+	if len(cleaned) != 1 {
+		t.Fatalf("expected cleanup to run")
+	}
+}
+
+func TestRunServeError(t *testing.T) {
+	m := NewManager(0)
+	srv := &http.Server{Addr: "invalid-address", Handler: http.NotFoundHandler()}
+	cleaned := false
+	if err := m.run(context.Background(), srv, func() { cleaned = true }); err == nil || !cleaned {
+		t.Fatalf("expected serve error and cleanup, got %v, %v", err, cleaned)
+	}
+}
@@ -15,7 +15,7 @@ type LogFormatter struct {
 	// FieldsOrder - default: fields sorted alphabetically
 	FieldsOrder []string
 
-	// TimestampFormat - default: models.DEFAULT_TIME_FORMAT"
+	// TimestampFormat - default: time.StampMilli
 	TimestampFormat string
 
 	// Add caller fields
@@ -31,6 +31,7 @@ const (
 )
 
 var logger = log.New()
+var logFile *os.File
 
 type (
 	mqttLogger interface {
@@ -53,14 +54,29 @@ func InitLogger(logFilePath string) {
 		TimestampFormat: LOGGER_TIME_FORMAT,
 		WithCallerField: true,
 	})
-	logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	file, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
 		Fatal("Cannot open ", logFilePath)
 	}
+	logFile = file
 	logger.SetLevel(log.DebugLevel)
 	logger.SetOutput(io.MultiWriter(os.Stdout, logFile))
 }
 
+// Flush and close the log file, later logs only go to stdout
+func CloseLogger() error {
+	if logFile == nil {
+		return nil
+	}
+	logger.SetOutput(os.Stdout)
+	err := logFile.Sync()
+	if closeErr := logFile.Close(); err == nil {
+		err = closeErr
+	}
+	logFile = nil
+	return err
+}
+
 func createFieldsWithComponent(comp ServerComponent, fields LoggerFields) (logFields map[string]interface{}) {
 	if fields == nil || len(fields) == 0 {
 		logFields = make(map[string]interface{}, 1)
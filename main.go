@@ -3,6 +3,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/ecoprohcm/DMS_BackendServer/handlers"
@@ -21,16 +22,23 @@ import (
 // @BasePath  /v1
 
 func main() {
-	cc, _, err := initializers.InitApplication("./.env")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	cc, cleanup, err := initializers.InitApplication("./.env")
 	if err != nil {
 		fmt.Printf("failed to create event: %s\n", err)
 		os.Exit(2)
 	}
-	// HTTP Serve
+	// HTTP Serve until SIGTERM/SIGINT, then stop jobs, MQTT, database and logs
 	r := handlers.SetupRouter(cc.HandlerOptions)
 	initSwagger(r)
-	r.Run(":8080")
-	cc.MqttClient.Disconnect(250)
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	if err := cc.Lifecycle.Run(srv, cleanup); err != nil {
+		fmt.Printf("server stopped: %s\n", err)
+		os.Exit(1)
+	}
 }
 
 func initSwagger(r *gin.Engine) {
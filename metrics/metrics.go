@@ -0,0 +1,152 @@
+// Package metrics defines the Prometheus metrics of the server,
+// served on /metrics
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	NAMESPACE string = "dms"
+)
+
+// Registry of the server metrics, kept apart from the global one so tests can build the app more than once
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	MqttMessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "messages_received_total",
+		Help:      "MQTT messages received from gateways by topic.",
+	}, []string{"topic"})
+
+	MqttMessagesPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "messages_published_total",
+		Help:      "MQTT messages published by the server by topic.",
+	}, []string{"topic"})
+
+	MqttSubscriberErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "subscriber_errors_total",
+		Help:      "Received MQTT messages whose processing failed by topic.",
+	}, []string{"topic"})
+
+	MqttPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "publish_failures_total",
+		Help:      "MQTT publishes that failed or were not acknowledged in time by topic.",
+	}, []string{"topic"})
+
+	MqttPipelineQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "pipeline_queue_depth",
+		Help:      "Received MQTT messages waiting for a worker.",
+	})
+
+	MqttPipelineBackpressure = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "pipeline_backpressure_total",
+		Help:      "Received MQTT messages that waited for a full worker queue.",
+	})
+
+	MqttDuplicatesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "duplicates_dropped_total",
+		Help:      "Redelivered MQTT messages dropped by topic.",
+	}, []string{"topic"})
+
+	MqttOutboxDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "outbox_dropped_total",
+		Help:      "Queued MQTT messages dropped because the outbox was full.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequestDuration,
+		MqttMessagesReceived,
+		MqttMessagesPublished,
+		MqttSubscriberErrors,
+		MqttPublishFailures,
+		MqttPipelineQueueDepth,
+		MqttPipelineBackpressure,
+		MqttDuplicatesDropped,
+		MqttOutboxDropped,
+	)
+}
+
+// Gauge of gateways online, counted on every scrape
+func RegisterGatewaysOnline(count func() float64) error {
+	return register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "gateways_online",
+		Help:      "Gateways connected to the broker.",
+	}, count))
+}
+
+// Gauge of MQTT messages waiting for the broker, counted on every scrape
+func RegisterMqttOutboxSize(count func() float64) error {
+	return register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Subsystem: "mqtt",
+		Name:      "outbox_size",
+		Help:      "MQTT messages queued while the broker is unreachable.",
+	}, count))
+}
+
+// Connection pool stats of the server database
+func RegisterDBStats(db *sql.DB) error {
+	return register(collectors.NewDBStatsCollector(db, NAMESPACE))
+}
+
+// Replace a collector registered by an earlier app instance
+func register(c prometheus.Collector) error {
+	Registry.Unregister(c)
+	return Registry.Register(c)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Observe latency of every request by route pattern, so /v1/gateway/:id is a single series
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HttpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
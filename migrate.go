@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ecoprohcm/DMS_BackendServer/initializers"
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
+)
+
+const migrateUsage = `Usage: dms-be migrate <command>
+
+Commands:
+  status        list migrations and whether they are applied
+  up            apply all pending migrations
+  down          roll back the latest applied migration
+  to <version>  migrate up or down to version, 0 drops every table`
+
+// Handle `migrate` subcommand, return process exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	migrator, cleanup, err := initializers.InitMigrator("./.env")
+	if err != nil {
+		fmt.Printf("failed to create migrator: %s\n", err)
+		return 2
+	}
+	defer cleanup()
+
+	switch args[0] {
+	case "status":
+		err = printMigrationStatus(migrator)
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "to":
+		if len(args) < 2 {
+			fmt.Println(migrateUsage)
+			return 2
+		}
+		version, convErr := strconv.ParseUint(args[1], 10, 32)
+		if convErr != nil {
+			fmt.Printf("invalid version %s\n", args[1])
+			return 2
+		}
+		err = migrator.To(uint(version))
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Printf("migrate %s failed: %s\n", args[0], err)
+		return 1
+	}
+
+	if args[0] != "status" {
+		current, err := migrator.CurrentVersion()
+		if err != nil {
+			fmt.Printf("get current version failed: %s\n", err)
+			return 1
+		}
+		fmt.Printf("database is at version %d\n", current)
+	}
+	return 0
+}
+
+func printMigrationStatus(migrator *migrations.Migrator) error {
+	statusList, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statusList {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema snapshot of the tables created by AutoMigrate before versioned
+// migrations were introduced. Snapshots must never follow later model changes,
+// new columns and tables belong to new migrations.
+
+// Embedded structs are exported, GORM skips unexported embedded fields
+type BaselineModel struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type BaselineUserPass struct {
+	RfidPass   string `gorm:"type:varchar(256)"`
+	KeypadPass string `gorm:"type:varchar(256)"`
+}
+
+type baselineArea struct {
+	BaselineModel
+	Name    string `gorm:"unique;not null"`
+	Manager string `gorm:"not null"`
+}
+
+func (baselineArea) TableName() string { return "areas" }
+
+type baselineGateway struct {
+	BaselineModel
+	AreaID          string
+	GatewayID       string `gorm:"type:varchar(256);unique;not null;"`
+	Name            string
+	ConnectState    bool `gorm:"type:bool;not null;"`
+	SoftwareVersion string
+	Doorlocks       []baselineDoorlock  `gorm:"foreignKey:GatewayID;references:GatewayID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	GwNetworks      []baselineGwNetwork `gorm:"foreignKey:GatewayID;references:GatewayID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (baselineGateway) TableName() string { return "gateways" }
+
+type baselineDoorlock struct {
+	BaselineModel
+	DoorSerialID    string `gorm:"type:varchar(256);unique;not null"`
+	Location        string
+	Description     string
+	GatewayID       string `gorm:"type:varchar(256);"`
+	LastOpenTime    uint
+	ConnectState    string
+	BlockId         string
+	FloorId         string
+	RoomId          string
+	DoorState       string
+	LockState       string
+	DoorlockAddress string
+	ActiveState     string
+	Schedulers      []baselineScheduler `gorm:"foreignKey:DoorID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (baselineDoorlock) TableName() string { return "doorlocks" }
+
+type baselineGatewayLog struct {
+	ID        uint `gorm:"primarykey;"`
+	GatewayID string
+	LogType   string
+	Content   string
+	LogTime   time.Time
+	CreatedAt time.Time
+}
+
+func (baselineGatewayLog) TableName() string { return "gateway_logs" }
+
+type baselineEmployee struct {
+	BaselineModel
+	MSNV       string `gorm:"type:varchar(256); unique; not null;"`
+	Name       string
+	Phone      string `gorm:"type:varchar(50)"`
+	Email      string `gorm:"type:varchar(256); not null;"`
+	Department string
+	Role       string `gorm:"not null;"`
+	BaselineUserPass
+	HighestPriority bool
+	Schedulers      []baselineScheduler `gorm:"foreignKey:EmployeeID;references:MSNV;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (baselineEmployee) TableName() string { return "employees" }
+
+type baselineStudent struct {
+	BaselineModel
+	MSSV  string `gorm:"type:varchar(256); unique; not null;"`
+	Name  string
+	Phone string `gorm:"type:varchar(50)"`
+	Email string `gorm:"type:varchar(256); unique; not null;"`
+	Major string `gorm:"not null;"`
+	BaselineUserPass
+	Schedulers []baselineScheduler `gorm:"foreignKey:StudentID;references:MSSV;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (baselineStudent) TableName() string { return "students" }
+
+type baselineCustomer struct {
+	BaselineModel
+	CCCD  string `gorm:"type:varchar(256); unique; not null;"`
+	Name  string
+	Phone string `gorm:"type:varchar(50)"`
+	BaselineUserPass
+	Schedulers []baselineScheduler `gorm:"foreignKey:CustomerID;references:CCCD;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (baselineCustomer) TableName() string { return "customers" }
+
+type baselineScheduler struct {
+	BaselineModel
+	Base           string `gorm:"not null;"`
+	RoomRow        string `gorm:"not null;"`
+	RoomID         string `gorm:" not null;"`
+	RoomName       string `gorm:" not null;"`
+	StartDate      string `gorm:"type:varchar(50) not null;"`
+	EndDate        string `gorm:"type:varchar(50) not null;"`
+	ClassID        string `gorm:" not null;"`
+	ClassName      string `gorm:" not null;"`
+	LecturerID     string `gorm:" not null;"`
+	LecturerName   string `gorm:" not null;"`
+	Capacity       uint
+	WeekDay        uint
+	StartClassTime uint
+	EndClassTime   uint
+	Amount         uint
+	Status         string
+	DoorID         uint
+	EmployeeID     *string `gorm:"type:varchar(256);"`
+	StudentID      *string `gorm:"type:varchar(256);"`
+	CustomerID     *string `gorm:"type:varchar(256);"`
+	Role           string
+	UserID         string
+}
+
+func (baselineScheduler) TableName() string { return "schedulers" }
+
+type baselineSecretKey struct {
+	BaselineModel
+	Secret string `gorm:"varchar(255); unique;not null"`
+}
+
+func (baselineSecretKey) TableName() string { return "secret_keys" }
+
+type baselineGwNetwork struct {
+	BaselineModel
+	GatewayID          string `gorm:"type:varchar(256);"`
+	InterfaceName      string `gorm:"type:varchar(50);not null"`
+	PrimaryIpAddress   string `gorm:"type:varchar(20);"`
+	SecondaryIpAddress string `gorm:"type:varchar(20);"`
+	MacAddress         string `gorm:"type:varchar(20);not null;"`
+}
+
+func (baselineGwNetwork) TableName() string { return "gw_networks" }
+
+type baselineDoorlockStatusLog struct {
+	BaselineModel
+	DoorID     string
+	StateType  string
+	StateValue string
+}
+
+func (baselineDoorlockStatusLog) TableName() string { return "doorlock_status_logs" }
+
+func init() {
+	register(&Migration{
+		Version: 1,
+		Name:    "baseline",
+		// AutoMigrate only creates what is missing, so databases created by the
+		// old startup AutoMigrate are adopted without changes
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&baselineArea{}, &baselineGateway{}, &baselineDoorlock{}, &baselineGatewayLog{},
+				&baselineEmployee{}, &baselineStudent{}, &baselineCustomer{}, &baselineScheduler{},
+				&baselineSecretKey{}, &baselineGwNetwork{}, &baselineDoorlockStatusLog{})
+		},
+		// Referencing tables are dropped first
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&baselineScheduler{}, &baselineDoorlockStatusLog{}, &baselineGwNetwork{},
+				&baselineDoorlock{}, &baselineGatewayLog{}, &baselineEmployee{}, &baselineStudent{},
+				&baselineCustomer{}, &baselineSecretKey{}, &baselineGateway{}, &baselineArea{})
+		},
+	})
+}
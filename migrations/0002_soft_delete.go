@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Columns added by soft delete, shared by every table below
+type SoftDeleteColumns struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	DeletedBy string         `gorm:"type:varchar(256)"`
+}
+
+type softDeleteStudent struct{ SoftDeleteColumns }
+
+func (softDeleteStudent) TableName() string { return "students" }
+
+type softDeleteEmployee struct{ SoftDeleteColumns }
+
+func (softDeleteEmployee) TableName() string { return "employees" }
+
+type softDeleteCustomer struct{ SoftDeleteColumns }
+
+func (softDeleteCustomer) TableName() string { return "customers" }
+
+type softDeleteDoorlock struct{ SoftDeleteColumns }
+
+func (softDeleteDoorlock) TableName() string { return "doorlocks" }
+
+type softDeleteGateway struct{ SoftDeleteColumns }
+
+func (softDeleteGateway) TableName() string { return "gateways" }
+
+func softDeleteTables() []interface{} {
+	return []interface{}{&softDeleteStudent{}, &softDeleteEmployee{}, &softDeleteCustomer{}, &softDeleteDoorlock{}, &softDeleteGateway{}}
+}
+
+func init() {
+	register(&Migration{
+		Version: 2,
+		Name:    "soft_delete",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(softDeleteTables()...)
+		},
+		// Soft deleted rows would come back to life, purge them first
+		Down: func(tx *gorm.DB) error {
+			for _, table := range softDeleteTables() {
+				if err := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(table).Error; err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropIndex(table, "DeletedAt"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table, "DeletedAt"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table, "DeletedBy"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type auditLogV3 struct {
+	ID            uint      `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"index"`
+	Actor         string    `gorm:"type:varchar(256);index"`
+	Action        string    `gorm:"type:varchar(50);not null"`
+	EntityType    string    `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity"`
+	EntityID      string    `gorm:"type:varchar(256);index:idx_audit_logs_entity"`
+	Before        string
+	After         string
+	Diff          string
+	SourceIP      string `gorm:"type:varchar(64)"`
+	CorrelationID string `gorm:"type:varchar(64);index"`
+}
+
+func (auditLogV3) TableName() string { return "audit_logs" }
+
+func init() {
+	register(&Migration{
+		Version: 3,
+		Name:    "audit_logs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&auditLogV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&auditLogV3{})
+		},
+	})
+}
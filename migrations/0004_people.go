@@ -0,0 +1,224 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type personV4 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	SoftDeleteColumns
+	Type   string `gorm:"type:varchar(50);not null;uniqueIndex:idx_people_type_user_id"`
+	UserID string `gorm:"type:varchar(256);not null;uniqueIndex:idx_people_type_user_id"`
+	Name   string
+}
+
+func (personV4) TableName() string { return "people" }
+
+type credentialV4 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	PersonID  uint      `gorm:"not null;index"`
+	Person    *personV4 `gorm:"foreignKey:PersonID;constraint:OnDelete:CASCADE;"`
+	Type      string    `gorm:"type:varchar(50);not null"`
+	Value     string    `gorm:"type:varchar(256);not null"`
+	Status    string    `gorm:"type:varchar(50);not null"`
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+func (credentialV4) TableName() string { return "credentials" }
+
+// Column linking a user profile or scheduler to its person
+type PersonLinkColumns struct {
+	PersonID *uint `gorm:"index"`
+}
+
+type personLinkStudent struct{ PersonLinkColumns }
+
+func (personLinkStudent) TableName() string { return "students" }
+
+type personLinkEmployee struct{ PersonLinkColumns }
+
+func (personLinkEmployee) TableName() string { return "employees" }
+
+type personLinkCustomer struct{ PersonLinkColumns }
+
+func (personLinkCustomer) TableName() string { return "customers" }
+
+type personLinkScheduler struct{ PersonLinkColumns }
+
+func (personLinkScheduler) TableName() string { return "schedulers" }
+
+type legacyPassStudent struct{ BaselineUserPass }
+
+func (legacyPassStudent) TableName() string { return "students" }
+
+type legacyPassEmployee struct{ BaselineUserPass }
+
+func (legacyPassEmployee) TableName() string { return "employees" }
+
+type legacyPassCustomer struct{ BaselineUserPass }
+
+func (legacyPassCustomer) TableName() string { return "customers" }
+
+// User profile table moved to people, keyColumn is the user ID column of the table
+type personProfileTable struct {
+	personType string
+	keyColumn  string
+	link       interface{}
+	legacyPass interface{}
+	softDelete interface{}
+}
+
+func personProfileTables() []personProfileTable {
+	return []personProfileTable{
+		{"student", "mssv", &personLinkStudent{}, &legacyPassStudent{}, &softDeleteStudent{}},
+		{"employee", "msnv", &personLinkEmployee{}, &legacyPassEmployee{}, &softDeleteEmployee{}},
+		{"customer", "cccd", &personLinkCustomer{}, &legacyPassCustomer{}, &softDeleteCustomer{}},
+	}
+}
+
+// Profile row read during backfill
+type legacyProfileRow struct {
+	ID         uint
+	UserID     string
+	Name       string
+	RfidPass   string
+	KeypadPass string
+	DeletedAt  gorm.DeletedAt
+	DeletedBy  string
+}
+
+func backfillPeople(tx *gorm.DB, table personProfileTable) error {
+	rows := []legacyProfileRow{}
+	err := tx.Model(table.legacyPass).Unscoped().
+		Select("id, " + table.keyColumn + " AS user_id, name, rfid_pass, keypad_pass, deleted_at, deleted_by").
+		Order("id").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		p := &personV4{
+			SoftDeleteColumns: SoftDeleteColumns{DeletedAt: row.DeletedAt, DeletedBy: row.DeletedBy},
+			Type:              table.personType,
+			UserID:            row.UserID,
+			Name:              row.Name,
+		}
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		for _, cred := range []credentialV4{{Type: "rfid", Value: row.RfidPass}, {Type: "keypad", Value: row.KeypadPass}} {
+			if cred.Value == "" {
+				continue
+			}
+			cred.PersonID = p.ID
+			cred.Status = "active"
+			if err := tx.Create(&cred).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(table.link).Where("id = ?", row.ID).Update("person_id", p.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&personLinkScheduler{}).
+			Where("(role = ? AND user_id = ?) OR "+table.personType+"_id = ?", table.personType, row.UserID, row.UserID).
+			Update("person_id", p.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy first active credentials back to the profile columns
+func restoreLegacyPass(tx *gorm.DB, table personProfileTable) error {
+	for _, cred := range []struct{ credType, column string }{{"rfid", "rfid_pass"}, {"keypad", "keypad_pass"}} {
+		firstID := tx.Model(&credentialV4{}).Select("MIN(id)").
+			Where("status = ? AND type = ?", "active", cred.credType).Group("person_id")
+		values := []struct {
+			PersonID uint
+			Value    string
+		}{}
+		if err := tx.Model(&credentialV4{}).Select("person_id, value").Where("id IN (?)", firstID).Scan(&values).Error; err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := tx.Model(table.legacyPass).Where("person_id = ?", v.PersonID).Update(cred.column, v.Value).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dropPersonLink(tx *gorm.DB, link interface{}) error {
+	if err := tx.Migrator().DropIndex(link, "PersonID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn(link, "PersonID")
+}
+
+func init() {
+	register(&Migration{
+		Version: 4,
+		Name:    "people",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&personV4{}, &credentialV4{}); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(&personLinkScheduler{}); err != nil {
+				return err
+			}
+			for _, table := range personProfileTables() {
+				if err := tx.AutoMigrate(table.link); err != nil {
+					return err
+				}
+				if err := backfillPeople(tx, table); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table.legacyPass, "RfidPass"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table.legacyPass, "KeypadPass"); err != nil {
+					return err
+				}
+				// SQLite drops columns by copying the table, which loses its indexes
+				for _, indexed := range []interface{}{table.link, table.softDelete} {
+					if err := tx.AutoMigrate(indexed); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		// Only the first active credential of each type fits in the old columns, others are lost
+		Down: func(tx *gorm.DB) error {
+			for _, table := range personProfileTables() {
+				if err := tx.AutoMigrate(table.legacyPass); err != nil {
+					return err
+				}
+				if err := restoreLegacyPass(tx, table); err != nil {
+					return err
+				}
+			}
+			for _, table := range personProfileTables() {
+				if err := dropPersonLink(tx, table.link); err != nil {
+					return err
+				}
+				if err := tx.AutoMigrate(table.softDelete); err != nil {
+					return err
+				}
+			}
+			if err := dropPersonLink(tx, &personLinkScheduler{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&credentialV4{}, &personV4{})
+		},
+	})
+}
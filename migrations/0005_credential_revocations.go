@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type credentialRevocationV5 struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	CredentialID uint   `gorm:"not null;index"`
+	PersonID     uint   `gorm:"not null;index"`
+	UserID       string `gorm:"type:varchar(256);not null"`
+	Type         string `gorm:"type:varchar(50);not null"`
+	Value        string `gorm:"type:varchar(256);not null"`
+	Reason       string
+	RevokedBy    string `gorm:"type:varchar(256)"`
+	CompletedAt  *time.Time
+}
+
+func (credentialRevocationV5) TableName() string { return "credential_revocations" }
+
+type revocationAckV5 struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	RevocationID uint                    `gorm:"not null;uniqueIndex:idx_revocation_acks_revocation_gateway"`
+	Revocation   *credentialRevocationV5 `gorm:"foreignKey:RevocationID;constraint:OnDelete:CASCADE;"`
+	GatewayID    string                  `gorm:"type:varchar(256);not null;uniqueIndex:idx_revocation_acks_revocation_gateway"`
+	AckedAt      *time.Time
+}
+
+func (revocationAckV5) TableName() string { return "revocation_acks" }
+
+func init() {
+	register(&Migration{
+		Version: 5,
+		Name:    "credential_revocations",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&credentialRevocationV5{}, &revocationAckV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&revocationAckV5{}, &credentialRevocationV5{})
+		},
+	})
+}
@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type accessGroupV6 struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `gorm:"type:varchar(256);unique;not null"`
+	Description string
+}
+
+func (accessGroupV6) TableName() string { return "access_groups" }
+
+type doorGroupV6 struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `gorm:"type:varchar(256);unique;not null"`
+	Description string
+}
+
+func (doorGroupV6) TableName() string { return "door_groups" }
+
+type accessGroupMemberV6 struct {
+	AccessGroupID uint           `gorm:"primaryKey"`
+	AccessGroup   *accessGroupV6 `gorm:"foreignKey:AccessGroupID;constraint:OnDelete:CASCADE;"`
+	PersonID      uint           `gorm:"primaryKey"`
+	Person        *personV4      `gorm:"foreignKey:PersonID;constraint:OnDelete:CASCADE;"`
+}
+
+func (accessGroupMemberV6) TableName() string { return "access_group_members" }
+
+type doorGroupDoorlockV6 struct {
+	DoorGroupID uint              `gorm:"primaryKey"`
+	DoorGroup   *doorGroupV6      `gorm:"foreignKey:DoorGroupID;constraint:OnDelete:CASCADE;"`
+	DoorlockID  uint              `gorm:"primaryKey"`
+	Doorlock    *baselineDoorlock `gorm:"foreignKey:DoorlockID;constraint:OnDelete:CASCADE;"`
+}
+
+func (doorGroupDoorlockV6) TableName() string { return "door_group_doorlocks" }
+
+type accessPolicyV6 struct {
+	ID             uint `gorm:"primaryKey"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string         `gorm:"type:varchar(256);not null"`
+	AccessGroupID  uint           `gorm:"not null;index"`
+	AccessGroup    *accessGroupV6 `gorm:"foreignKey:AccessGroupID"`
+	DoorGroupID    uint           `gorm:"not null;index"`
+	DoorGroup      *doorGroupV6   `gorm:"foreignKey:DoorGroupID"`
+	StartDate      string         `gorm:"type:varchar(50);not null"`
+	EndDate        string         `gorm:"type:varchar(50);not null"`
+	WeekDay        uint
+	StartClassTime uint
+	EndClassTime   uint
+}
+
+func (accessPolicyV6) TableName() string { return "access_policies" }
+
+// Scheduler register generated from an access policy
+type accessPolicyLinkScheduler struct {
+	AccessPolicyID *uint `gorm:"index"`
+}
+
+func (accessPolicyLinkScheduler) TableName() string { return "schedulers" }
+
+func init() {
+	register(&Migration{
+		Version: 6,
+		Name:    "access_groups",
+		Up: func(tx *gorm.DB) error {
+			err := tx.Migrator().CreateTable(&accessGroupV6{}, &doorGroupV6{},
+				&accessGroupMemberV6{}, &doorGroupDoorlockV6{}, &accessPolicyV6{})
+			if err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&accessPolicyLinkScheduler{})
+		},
+		// Registers generated from policies are kept as plain schedulers
+		Down: func(tx *gorm.DB) error {
+			if err := dropAccessPolicyLink(tx); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&accessPolicyV6{}, &doorGroupDoorlockV6{}, &accessGroupMemberV6{},
+				&doorGroupV6{}, &accessGroupV6{})
+		},
+	})
+}
+
+func dropAccessPolicyLink(tx *gorm.DB) error {
+	link := &accessPolicyLinkScheduler{}
+	if err := tx.Migrator().DropIndex(link, "AccessPolicyID"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropColumn(link, "AccessPolicyID"); err != nil {
+		return err
+	}
+	// SQLite drops columns by copying the table, which loses its indexes
+	return tx.AutoMigrate(&personLinkScheduler{})
+}
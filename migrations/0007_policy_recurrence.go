@@ -0,0 +1,33 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// Recurrence columns of access policies
+type accessPolicyRecurrenceV7 struct {
+	Recurrence  string `gorm:"type:varchar(256)"`
+	TimeWindows string `gorm:"type:varchar(256)"`
+	TimeZone    string `gorm:"type:varchar(64)"`
+}
+
+func (accessPolicyRecurrenceV7) TableName() string { return "access_policies" }
+
+func init() {
+	register(&Migration{
+		Version: 7,
+		Name:    "policy_recurrence",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&accessPolicyRecurrenceV7{})
+		},
+		// Recurring policies keep their registers without time fields
+		Down: func(tx *gorm.DB) error {
+			recurrence := &accessPolicyRecurrenceV7{}
+			for _, field := range []string{"Recurrence", "TimeWindows", "TimeZone"} {
+				if err := tx.Migrator().DropColumn(recurrence, field); err != nil {
+					return err
+				}
+			}
+			// SQLite drops columns by copying the table, which loses its indexes
+			return tx.AutoMigrate(&accessPolicyV6{})
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type roomSettingV8 struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	RoomID       string `gorm:"type:varchar(256);unique;not null"`
+	AntiPassback string `gorm:"type:varchar(16);not null"`
+}
+
+func (roomSettingV8) TableName() string { return "room_settings" }
+
+type accessEventV8 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	EventTime time.Time `gorm:"index"`
+	GatewayID string    `gorm:"type:varchar(256)"`
+	DoorID    uint      `gorm:"index"`
+	RoomID    string    `gorm:"type:varchar(256);index"`
+	BlockID   string    `gorm:"type:varchar(256)"`
+	PersonID  *uint     `gorm:"index"`
+	UserID    string    `gorm:"type:varchar(256)"`
+	Direction string    `gorm:"type:varchar(16)"`
+	Result    string    `gorm:"type:varchar(16);not null"`
+	Reason    string
+}
+
+func (accessEventV8) TableName() string { return "access_events" }
+
+type roomPresenceV8 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	RoomID    string    `gorm:"type:varchar(256);not null;uniqueIndex:idx_room_presences_room_person"`
+	PersonID  uint      `gorm:"not null;uniqueIndex:idx_room_presences_room_person"`
+	Person    *personV4 `gorm:"foreignKey:PersonID;constraint:OnDelete:CASCADE;"`
+	UserID    string    `gorm:"type:varchar(256)"`
+	BlockID   string    `gorm:"type:varchar(256)"`
+	EnteredAt time.Time
+}
+
+func (roomPresenceV8) TableName() string { return "room_presences" }
+
+// Entry or exit reader of a doorlock
+type readerDirectionDoorlock struct {
+	ReaderDirection string `gorm:"type:varchar(16)"`
+}
+
+func (readerDirectionDoorlock) TableName() string { return "doorlocks" }
+
+func init() {
+	register(&Migration{
+		Version: 8,
+		Name:    "occupancy",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&roomSettingV8{}, &accessEventV8{}, &roomPresenceV8{}); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&readerDirectionDoorlock{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&readerDirectionDoorlock{}, "ReaderDirection"); err != nil {
+				return err
+			}
+			// SQLite drops columns by copying the table, which loses its indexes
+			if err := tx.AutoMigrate(&softDeleteDoorlock{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&roomPresenceV8{}, &accessEventV8{}, &roomSettingV8{})
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type unlockRequestV9 struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Command     string `gorm:"type:varchar(50);not null"`
+	DoorID      *uint  `gorm:"index"`
+	BlockID     string `gorm:"type:varchar(256)"`
+	State       string `gorm:"type:varchar(50)"`
+	Duration    string `gorm:"type:varchar(50)"`
+	Reason      string
+	Status      string `gorm:"type:varchar(50);not null;index"`
+	RequestedBy string `gorm:"type:varchar(256);not null"`
+	DecidedBy   string `gorm:"type:varchar(256)"`
+	DecidedAt   *time.Time
+	ExpiresAt   time.Time
+	ExecutedAt  *time.Time
+}
+
+func (unlockRequestV9) TableName() string { return "unlock_requests" }
+
+// Remote commands on the doorlock wait for a second operator
+type requiresApprovalDoorlock struct {
+	RequiresApproval bool `gorm:"not null;default:false"`
+}
+
+func (requiresApprovalDoorlock) TableName() string { return "doorlocks" }
+
+func init() {
+	register(&Migration{
+		Version: 9,
+		Name:    "unlock_requests",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&unlockRequestV9{}); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&requiresApprovalDoorlock{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&requiresApprovalDoorlock{}, "RequiresApproval"); err != nil {
+				return err
+			}
+			// SQLite drops columns by copying the table, which loses its indexes
+			if err := tx.AutoMigrate(&softDeleteDoorlock{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&unlockRequestV9{})
+		},
+	})
+}
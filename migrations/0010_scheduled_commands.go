@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type scheduledCommandV10 struct {
+	ID              uint `gorm:"primaryKey"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string     `gorm:"type:varchar(256);not null"`
+	Cron            string     `gorm:"type:varchar(100);not null"`
+	TimeZone        string     `gorm:"type:varchar(64)"`
+	TargetType      string     `gorm:"type:varchar(16);not null"`
+	TargetID        string     `gorm:"type:varchar(256);not null"`
+	Action          string     `gorm:"type:varchar(50);not null"`
+	Duration        string     `gorm:"type:varchar(50)"`
+	MissedRunPolicy string     `gorm:"type:varchar(16);not null"`
+	Enabled         bool       `gorm:"not null;index"`
+	NextRunAt       *time.Time `gorm:"index"`
+	LastRunAt       *time.Time
+}
+
+func (scheduledCommandV10) TableName() string { return "scheduled_commands" }
+
+type scheduledCommandRunV10 struct {
+	ID                 uint `gorm:"primaryKey"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	ScheduledCommandID uint `gorm:"not null;index"`
+	ScheduledAt        time.Time
+	RanAt              time.Time
+	Missed             bool   `gorm:"not null"`
+	Status             string `gorm:"type:varchar(16);not null"`
+	Doorlocks          int
+	Skipped            int
+	ErrorMsg           string
+}
+
+func (scheduledCommandRunV10) TableName() string { return "scheduled_command_runs" }
+
+func init() {
+	register(&Migration{
+		Version: 10,
+		Name:    "scheduled_commands",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&scheduledCommandV10{}, &scheduledCommandRunV10{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&scheduledCommandRunV10{}, &scheduledCommandV10{})
+		},
+	})
+}
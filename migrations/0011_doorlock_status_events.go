@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type doorlockStatusEventV11 struct {
+	ID        uint      `gorm:"primaryKey"`
+	DoorID    uint      `gorm:"not null;index:idx_doorlock_status_events_door_time,priority:1"`
+	RoomID    string    `gorm:"type:varchar(256);index"`
+	EventType string    `gorm:"type:varchar(16);not null"`
+	Value     string    `gorm:"type:varchar(50)"`
+	Active    bool      `gorm:"not null"`
+	EventTime time.Time `gorm:"not null;index;index:idx_doorlock_status_events_door_time,priority:2"`
+}
+
+func (doorlockStatusEventV11) TableName() string { return "doorlock_status_events" }
+
+type doorlockRoomV11 struct {
+	ID     uint
+	RoomId string
+}
+
+func (doorlockRoomV11) TableName() string { return "doorlocks" }
+
+// Legacy state types of the status logs
+var statusEventTypesV11 = map[string]string{
+	"connectState": "connect",
+	"doorState":    "door",
+	"lockState":    "lock",
+}
+
+// Typed state of the reported value as defined when the events were introduced
+func statusEventActiveV11(eventType string, value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch eventType {
+	case "connect":
+		return v != "disconnected" && v != "disconnect" && v != "offline" && v != "false" && v != "0"
+	case "door":
+		return v == "open" || v == "opened" || v == "true" || v == "1"
+	case "lock":
+		return v == "unlock" || v == "unlocked" || v == "open"
+	}
+	return false
+}
+
+func init() {
+	register(&Migration{
+		Version: 11,
+		Name:    "doorlock_status_events",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&doorlockStatusEventV11{}); err != nil {
+				return err
+			}
+
+			var rooms []doorlockRoomV11
+			if err := tx.Unscoped().Find(&rooms).Error; err != nil {
+				return err
+			}
+			roomMap := map[uint]string{}
+			for _, r := range rooms {
+				roomMap[r.ID] = r.RoomId
+			}
+
+			// Status logs are kept for /v1/doorlockStatusLogs, their history is copied to the events
+			var logs []baselineDoorlockStatusLog
+			return tx.Order("id").FindInBatches(&logs, 1000, func(batch *gorm.DB, _ int) error {
+				var events []doorlockStatusEventV11
+				for _, l := range logs {
+					doorID, err := strconv.ParseUint(l.DoorID, 10, 32)
+					eventType, ok := statusEventTypesV11[l.StateType]
+					if err != nil || !ok {
+						continue
+					}
+					events = append(events, doorlockStatusEventV11{
+						DoorID:    uint(doorID),
+						RoomID:    roomMap[uint(doorID)],
+						EventType: eventType,
+						Value:     l.StateValue,
+						Active:    statusEventActiveV11(eventType, l.StateValue),
+						EventTime: l.CreatedAt,
+					})
+				}
+				if len(events) == 0 {
+					return nil
+				}
+				return tx.Create(&events).Error
+			}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&doorlockStatusEventV11{})
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type retentionPolicyV12 struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Table       string `gorm:"column:log_table;type:varchar(64);unique;not null"`
+	MaxAgeHours uint
+	MaxRows     uint
+	Archive     bool `gorm:"not null"`
+	LastRunAt   *time.Time
+	LastDeleted int64
+	LastArchive string
+	LastError   string
+}
+
+func (retentionPolicyV12) TableName() string { return "retention_policies" }
+
+func init() {
+	register(&Migration{
+		Version: 12,
+		Name:    "retention_policies",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&retentionPolicyV12{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&retentionPolicyV12{})
+		},
+	})
+}
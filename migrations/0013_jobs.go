@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type jobV13 struct {
+	ID              uint `gorm:"primaryKey"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Name            string `gorm:"type:varchar(64);unique;not null"`
+	IntervalSeconds int64
+	NextRunAt       time.Time
+	LastRunAt       *time.Time
+	LastStatus      string `gorm:"type:varchar(16)"`
+	LastError       string
+	LastDurationMs  int64
+}
+
+func (jobV13) TableName() string { return "jobs" }
+
+func init() {
+	register(&Migration{
+		Version: 13,
+		Name:    "jobs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&jobV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&jobV13{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type mqttOutboxMessageV14 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Topic     string `gorm:"type:varchar(256);not null"`
+	Qos       byte
+	Retained  bool `gorm:"not null"`
+	Payload   []byte
+}
+
+func (mqttOutboxMessageV14) TableName() string { return "mqtt_outbox_messages" }
+
+func init() {
+	register(&Migration{
+		Version: 14,
+		Name:    "mqtt_outbox",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&mqttOutboxMessageV14{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&mqttOutboxMessageV14{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type mqttDeadLetterV15 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	Topic     string    `gorm:"type:varchar(256);not null;index"`
+	GatewayID string    `gorm:"type:varchar(256);index"`
+	Payload   string
+	Reason    string
+}
+
+func (mqttDeadLetterV15) TableName() string { return "mqtt_dead_letters" }
+
+func init() {
+	register(&Migration{
+		Version: 15,
+		Name:    "mqtt_dead_letters",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&mqttDeadLetterV15{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&mqttDeadLetterV15{})
+		},
+	})
+}
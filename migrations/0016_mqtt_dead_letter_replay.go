@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Replay columns of MQTT dead letters, letters kept before are pending
+type mqttDeadLetterReplayV16 struct {
+	UpdatedAt time.Time
+	Attempts  uint   `gorm:"not null;default:1"`
+	Status    string `gorm:"type:varchar(50);not null;default:pending;index"`
+}
+
+func (mqttDeadLetterReplayV16) TableName() string { return "mqtt_dead_letters" }
+
+func init() {
+	register(&Migration{
+		Version: 16,
+		Name:    "mqtt_dead_letter_replay",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&mqttDeadLetterReplayV16{})
+		},
+		Down: func(tx *gorm.DB) error {
+			replay := &mqttDeadLetterReplayV16{}
+			for _, field := range []string{"UpdatedAt", "Attempts", "Status"} {
+				if err := tx.Migrator().DropColumn(replay, field); err != nil {
+					return err
+				}
+			}
+			// SQLite drops columns by copying the table, which loses its indexes
+			return tx.AutoMigrate(&mqttDeadLetterV15{})
+		},
+	})
+}
@@ -0,0 +1,243 @@
+// Package migrations provides versioned database schema migrations.
+// Every schema change is a numbered migration with an up and a down step,
+// the applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"gorm.io/gorm"
+)
+
+// Struct defines one schema change. Up and Down run inside a transaction
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Struct defines a row of schema_migrations table
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(256);not null" json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// Struct defines migration state reported by status command
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+var registry = []*Migration{}
+
+// Add migration to registry, called from init() of every migration file
+func register(m *Migration) {
+	for _, r := range registry {
+		if r.Version == m.Version {
+			panic(fmt.Sprintf("duplicate migration version %d", m.Version))
+		}
+	}
+	registry = append(registry, m)
+	sort.Slice(registry, func(i, j int) bool {
+		return registry[i].Version < registry[j].Version
+	})
+}
+
+type Migrator struct {
+	db *gorm.DB
+}
+
+func NewMigrator(db *gorm.DB) *Migrator {
+	return &Migrator{
+		db: db,
+	}
+}
+
+// Latest version known by this binary
+func (m *Migrator) LatestVersion() uint {
+	if len(registry) == 0 {
+		return 0
+	}
+	return registry[len(registry)-1].Version
+}
+
+// Latest version applied to database, 0 when nothing was applied
+func (m *Migrator) CurrentVersion() (uint, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+	var version uint
+	err := m.db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	statusList := []MigrationStatus{}
+	for _, mig := range registry {
+		status := MigrationStatus{
+			Version: mig.Version,
+			Name:    mig.Name,
+		}
+		if sm, ok := applied[mig.Version]; ok {
+			appliedAt := sm.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statusList = append(statusList, status)
+	}
+	return statusList, nil
+}
+
+// Apply all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.LatestVersion())
+}
+
+// Roll back the latest applied migration
+func (m *Migrator) Down() error {
+	current, err := m.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return fmt.Errorf("no migration to roll back")
+	}
+	target := uint(0)
+	for _, mig := range registry {
+		if mig.Version < current {
+			target = mig.Version
+		}
+	}
+	return m.To(target)
+}
+
+// Migrate up or down until target version is the latest applied one
+func (m *Migrator) To(target uint) error {
+	if target != 0 && m.find(target) == nil {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	// Roll back applied migrations above target, newest first
+	for i := len(registry) - 1; i >= 0; i-- {
+		mig := registry[i]
+		if _, ok := applied[mig.Version]; ok && mig.Version > target {
+			if err := m.runDown(mig); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Apply pending migrations up to target, oldest first
+	for _, mig := range registry {
+		if _, ok := applied[mig.Version]; !ok && mig.Version <= target {
+			if err := m.runUp(mig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Return error when database schema does not match this binary
+func (m *Migrator) CheckVersion() error {
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for version := range applied {
+		if m.find(version) == nil {
+			return fmt.Errorf("database has migration %d unknown to this server, please upgrade the server", version)
+		}
+	}
+	pending := []uint{}
+	for _, mig := range registry {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is not up to date, pending migrations %v. Run `migrate up` first", pending)
+	}
+	return nil
+}
+
+func (m *Migrator) runUp(mig *Migration) error {
+	logger.LogfWithoutFields(logger.SQLSERVER, logger.InfoLevel, "Apply migration %d %s", mig.Version, mig.Name)
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %d %s failed: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) runDown(mig *Migration) error {
+	logger.LogfWithoutFields(logger.SQLSERVER, logger.InfoLevel, "Roll back migration %d %s", mig.Version, mig.Name)
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Down(tx); err != nil {
+			return err
+		}
+		return tx.Where("version = ?", mig.Version).Delete(&SchemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("roll back migration %d %s failed: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) appliedMigrations() (map[uint]SchemaMigration, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+	smList := []SchemaMigration{}
+	if err := m.db.Find(&smList).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]SchemaMigration, len(smList))
+	for _, sm := range smList {
+		applied[sm.Version] = sm
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	if m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	return m.db.Migrator().CreateTable(&SchemaMigration{})
+}
+
+func (m *Migrator) find(version uint) *Migration {
+	for _, mig := range registry {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+//go:build unit
+// +build unit
+
+package migrations
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:?_pragma=foreign_keys(1)"), &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql db failed: %v", err)
+	}
+	sqlDb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDb.Close() })
+	return db
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := newTestDb(t)
+	m := NewMigrator(db)
+
+	if err := m.CheckVersion(); err == nil {
+		t.Fatalf("expected version mismatch on empty database")
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	current, err := m.CurrentVersion()
+	if err != nil {
+		t.Fatalf("current version failed: %v", err)
+	}
+	if current != m.LatestVersion() {
+		t.Fatalf("expected version %d, got %d", m.LatestVersion(), current)
+	}
+	if err := m.CheckVersion(); err != nil {
+		t.Fatalf("expected schema up to date, got %v", err)
+	}
+	if !db.Migrator().HasTable("doorlocks") {
+		t.Fatalf("expected doorlocks table after up")
+	}
+
+	// Up is a no-op once every migration is applied
+	if err := m.Up(); err != nil {
+		t.Fatalf("second up failed: %v", err)
+	}
+
+	if err := m.To(0); err != nil {
+		t.Fatalf("to 0 failed: %v", err)
+	}
+	if db.Migrator().HasTable("doorlocks") {
+		t.Fatalf("expected doorlocks table dropped after to 0")
+	}
+	current, _ = m.CurrentVersion()
+	if current != 0 {
+		t.Fatalf("expected version 0, got %d", current)
+	}
+
+	if err := m.Down(); err == nil {
+		t.Fatalf("expected error when nothing to roll back")
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	m := NewMigrator(newTestDb(t))
+
+	statusList, err := m.Status()
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if len(statusList) != len(registry) {
+		t.Fatalf("expected %d migrations, got %d", len(registry), len(statusList))
+	}
+	for _, s := range statusList {
+		if s.Applied {
+			t.Fatalf("expected migration %d pending", s.Version)
+		}
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	statusList, _ = m.Status()
+	for _, s := range statusList {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Fatalf("expected migration %d applied", s.Version)
+		}
+	}
+}
+
+func TestMigratorUnknownVersion(t *testing.T) {
+	db := newTestDb(t)
+	m := NewMigrator(db)
+
+	if err := m.To(m.LatestVersion() + 1); err == nil {
+		t.Fatalf("expected error for unknown target version")
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	db.Create(&SchemaMigration{Version: m.LatestVersion() + 1, Name: "from newer server"})
+	if err := m.CheckVersion(); err == nil {
+		t.Fatalf("expected error for database newer than binary")
+	}
+}
+
+func TestPeopleMigrationBackfill(t *testing.T) {
+	db := newTestDb(t)
+	m := NewMigrator(db)
+	if err := m.To(3); err != nil {
+		t.Fatalf("to 3 failed: %v", err)
+	}
+
+	db.Exec("INSERT INTO students (mssv, name, email, major, rfid_pass, keypad_pass) VALUES ('s1', 'A', 's1@mail', 'it', 'card1', '1234')")
+	db.Exec("INSERT INTO customers (cccd, name, rfid_pass, deleted_at, deleted_by) VALUES ('c1', 'B', '', CURRENT_TIMESTAMP, 'admin')")
+	db.Omit("DoorID").Create(&baselineScheduler{Role: "student", UserID: "s1"})
+
+	if err := m.To(4); err != nil {
+		t.Fatalf("to 4 failed: %v", err)
+	}
+	if db.Migrator().HasColumn("students", "rfid_pass") {
+		t.Fatalf("expected rfid_pass column dropped")
+	}
+
+	people := []personV4{}
+	db.Unscoped().Order("id").Find(&people)
+	if len(people) != 2 || people[0].UserID != "s1" || people[1].Type != "customer" || !people[1].DeletedAt.Valid {
+		t.Fatalf("unexpected people %+v", people)
+	}
+	var credCnt int64
+	db.Model(&credentialV4{}).Where("person_id = ? AND status = ?", people[0].ID, "active").Count(&credCnt)
+	if credCnt != 2 {
+		t.Fatalf("expected 2 credentials for student, got %d", credCnt)
+	}
+	var studentPerson, schedulerPerson uint
+	db.Table("students").Select("person_id").Where("mssv = ?", "s1").Scan(&studentPerson)
+	db.Table("schedulers").Select("person_id").Where("user_id = ?", "s1").Scan(&schedulerPerson)
+	if studentPerson != people[0].ID || schedulerPerson != people[0].ID {
+		t.Fatalf("expected student and scheduler linked to person %d, got %d and %d", people[0].ID, studentPerson, schedulerPerson)
+	}
+
+	if err := m.To(3); err != nil {
+		t.Fatalf("to 3 failed: %v", err)
+	}
+	var rfidPass string
+	db.Table("students").Select("rfid_pass").Where("mssv = ?", "s1").Scan(&rfidPass)
+	if rfidPass != "card1" || db.Migrator().HasTable("people") {
+		t.Fatalf("expected credentials copied back and people dropped, got %q", rfidPass)
+	}
+}
+
+func TestStatusEventMigrationBackfill(t *testing.T) {
+	db := newTestDb(t)
+	m := NewMigrator(db)
+	if err := m.To(10); err != nil {
+		t.Fatalf("to 10 failed: %v", err)
+	}
+
+	db.Exec("INSERT INTO doorlocks (door_serial_id, room_id) VALUES ('d1', 'r1')")
+	for _, l := range []baselineDoorlockStatusLog{
+		{DoorID: "1", StateType: "doorState", StateValue: "open"},
+		{DoorID: "1", StateType: "connectState", StateValue: "disconnected"},
+		{DoorID: "x", StateType: "doorState", StateValue: "open"},
+	} {
+		l := l
+		db.Create(&l)
+	}
+
+	if err := m.To(11); err != nil {
+		t.Fatalf("to 11 failed: %v", err)
+	}
+	events := []doorlockStatusEventV11{}
+	db.Order("id").Find(&events)
+	if len(events) != 2 || events[0].RoomID != "r1" || events[0].EventType != "door" || !events[0].Active ||
+		events[1].EventType != "connect" || events[1].Active {
+		t.Fatalf("unexpected status events %+v", events)
+	}
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Set of people sharing the same access policies
+type AccessGroup struct {
+	GormModel
+	Name        string   `gorm:"type:varchar(256);unique;not null" json:"name"`
+	Description string   `json:"description"`
+	Members     []Person `gorm:"many2many:access_group_members;constraint:OnDelete:CASCADE;" json:"members"`
+}
+
+// Struct defines HTTP request payload for adding or removing group members
+type GroupMemberIDs struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// Access groups and their members
+type AccessGroupService interface {
+	FindAllAccessGroup(ctx context.Context) (agList []AccessGroup, err error)
+	FindAccessGroupByID(ctx context.Context, id string) (ag *AccessGroup, err error)
+	CreateAccessGroup(ctx context.Context, ag *AccessGroup) (*AccessGroup, error)
+	UpdateAccessGroup(ctx context.Context, ag *AccessGroup) (bool, error)
+	DeleteAccessGroup(ctx context.Context, id uint) (bool, error)
+	AddAccessGroupMembers(ctx context.Context, id uint, personIDs []uint) (*RegisterDiff, error)
+	RemoveAccessGroupMembers(ctx context.Context, id uint, personIDs []uint) (*RegisterDiff, error)
+}
+
+type AccessGroupSvc struct {
+	db *gorm.DB
+}
+
+func NewAccessGroupSvc(db *gorm.DB) *AccessGroupSvc {
+	return &AccessGroupSvc{
+		db: db,
+	}
+}
+
+func (ags *AccessGroupSvc) FindAllAccessGroup(ctx context.Context) (agList []AccessGroup, err error) {
+	result := conn(ctx, ags.db).Find(&agList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return agList, nil
+}
+
+func (ags *AccessGroupSvc) FindAccessGroupByID(ctx context.Context, id string) (ag *AccessGroup, err error) {
+	result := conn(ctx, ags.db).Preload("Members").First(&ag, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return ag, nil
+}
+
+func (ags *AccessGroupSvc) CreateAccessGroup(ctx context.Context, ag *AccessGroup) (*AccessGroup, error) {
+	// Members are added with AddAccessGroupMembers so their registers are created
+	ag.Members = nil
+	if err := conn(ctx, ags.db).Create(ag).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return ag, nil
+}
+
+func (ags *AccessGroupSvc) UpdateAccessGroup(ctx context.Context, ag *AccessGroup) (bool, error) {
+	result := conn(ctx, ags.db).Model(&AccessGroup{}).Where("id = ?", ag.ID).
+		Select("Name", "Description").Updates(ag)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Access group used by a policy can not be deleted
+func (ags *AccessGroupSvc) DeleteAccessGroup(ctx context.Context, id uint) (bool, error) {
+	var cnt int64
+	if err := conn(ctx, ags.db).Model(&AccessPolicy{}).Where("access_group_id = ?", id).Count(&cnt).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	if cnt > 0 {
+		return false, fmt.Errorf("access group is used by %d access policies", cnt)
+	}
+	result := conn(ctx, ags.db).Where("id = ?", id).Delete(&AccessGroup{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Add people to access group, registers are created for the new members only
+func (ags *AccessGroupSvc) AddAccessGroupMembers(ctx context.Context, id uint, personIDs []uint) (*RegisterDiff, error) {
+	return ags.changeMembers(ctx, id, func(tx *gorm.DB, ag *AccessGroup) error {
+		people := []Person{}
+		if err := tx.Where("id IN ?", personIDs).Find(&people).Error; err != nil {
+			return err
+		}
+		if len(people) != len(personIDs) {
+			return fmt.Errorf("person not found")
+		}
+		return tx.Model(ag).Omit("Members.*").Association("Members").Append(people)
+	})
+}
+
+// Remove people from access group, only their registers are deleted
+func (ags *AccessGroupSvc) RemoveAccessGroupMembers(ctx context.Context, id uint, personIDs []uint) (*RegisterDiff, error) {
+	return ags.changeMembers(ctx, id, func(tx *gorm.DB, ag *AccessGroup) error {
+		return tx.Where("access_group_id = ? AND person_id IN ?", ag.ID, personIDs).
+			Delete(&accessGroupMember{}).Error
+	})
+}
+
+func (ags *AccessGroupSvc) changeMembers(ctx context.Context, id uint, change func(tx *gorm.DB, ag *AccessGroup) error) (*RegisterDiff, error) {
+	diff := &RegisterDiff{}
+	err := conn(ctx, ags.db).Transaction(func(tx *gorm.DB) error {
+		ag := &AccessGroup{}
+		if err := tx.First(ag, id).Error; err != nil {
+			return err
+		}
+		if err := change(tx, ag); err != nil {
+			return err
+		}
+		var err error
+		diff, err = syncGroupPolicies(tx, "access_group_id", id)
+		return err
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return diff, nil
+}
+
+// Row of the access group members join table
+type accessGroupMember struct {
+	AccessGroupID uint
+	PersonID      uint
+}
+
+func (accessGroupMember) TableName() string { return "access_group_members" }
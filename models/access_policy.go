@@ -0,0 +1,389 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const ACCESS_POLICY_DATE_LAYOUT string = "2/1/2006"
+
+// Time policy giving every member of an access group access to every doorlock of a door group.
+// It is expanded into one scheduler register per member and doorlock. Policies with a recurrence use
+// its time windows in TimeZone instead of the weekday and class periods
+type AccessPolicy struct {
+	GormModel
+	Name           string       `gorm:"type:varchar(256);not null" json:"name"`
+	AccessGroupID  uint         `gorm:"not null;index" json:"accessGroupId"`
+	AccessGroup    *AccessGroup `gorm:"foreignKey:AccessGroupID" json:"accessGroup,omitempty"`
+	DoorGroupID    uint         `gorm:"not null;index" json:"doorGroupId"`
+	DoorGroup      *DoorGroup   `gorm:"foreignKey:DoorGroupID" json:"doorGroup,omitempty"`
+	StartDate      string       `gorm:"type:varchar(50);not null" json:"startDate"`
+	EndDate        string       `gorm:"type:varchar(50);not null" json:"endDate"`
+	WeekDay        uint         `json:"weekDay"`
+	StartClassTime uint         `json:"startClassTime"`
+	EndClassTime   uint         `json:"endClassTime"`
+	Recurrence     string       `gorm:"type:varchar(256)" json:"recurrence"`
+	TimeWindows    string       `gorm:"type:varchar(256)" json:"timeWindows"`
+	TimeZone       string       `gorm:"type:varchar(64)" json:"timeZone"`
+}
+
+func (ap *AccessPolicy) IsRecurring() bool {
+	return ap.Recurrence != ""
+}
+
+// Access of recurring policy is open at t, dates and windows are taken in the policy time zone
+func (ap *AccessPolicy) IsActiveAt(t time.Time) bool {
+	if !ap.IsRecurring() {
+		return false
+	}
+	loc, err := time.LoadLocation(ap.TimeZone)
+	if err != nil {
+		return false
+	}
+	r, err := ParseRecurrence(ap.Recurrence)
+	if err != nil {
+		return false
+	}
+	twList, err := ParseTimeWindows(ap.TimeWindows)
+	if err != nil {
+		return false
+	}
+	start, errStart := time.ParseInLocation(ACCESS_POLICY_DATE_LAYOUT, ap.StartDate, loc)
+	end, errEnd := time.ParseInLocation(ACCESS_POLICY_DATE_LAYOUT, ap.EndDate, loc)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	if local.Before(start) || !local.Before(end.AddDate(0, 0, 1)) || !r.OccursOn(start, local) {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	for _, tw := range twList {
+		if minute >= tw.Start && minute < tw.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Registers changed by an access policy or group change, to be sent to the gateways of their doorlocks
+type RegisterDiff struct {
+	Created []Scheduler `json:"created"`
+	Updated []Scheduler `json:"updated"`
+	Deleted []Scheduler `json:"deleted"`
+}
+
+func (d *RegisterDiff) merge(other *RegisterDiff) {
+	d.Created = append(d.Created, other.Created...)
+	d.Updated = append(d.Updated, other.Updated...)
+	d.Deleted = append(d.Deleted, other.Deleted...)
+}
+
+// Access policies and the registers generated from them
+type AccessPolicyService interface {
+	FindAllAccessPolicy(ctx context.Context) (apList []AccessPolicy, err error)
+	FindAccessPolicyByID(ctx context.Context, id string) (ap *AccessPolicy, err error)
+	FindAccessPolicyRegisters(ctx context.Context, id string) (sList []Scheduler, err error)
+	CreateAccessPolicy(ctx context.Context, ap *AccessPolicy) (*AccessPolicy, *RegisterDiff, error)
+	UpdateAccessPolicy(ctx context.Context, ap *AccessPolicy) (*RegisterDiff, error)
+	DeleteAccessPolicy(ctx context.Context, id uint) (*RegisterDiff, error)
+}
+
+type AccessPolicySvc struct {
+	db *gorm.DB
+}
+
+func NewAccessPolicySvc(db *gorm.DB) *AccessPolicySvc {
+	return &AccessPolicySvc{
+		db: db,
+	}
+}
+
+func (aps *AccessPolicySvc) FindAllAccessPolicy(ctx context.Context) (apList []AccessPolicy, err error) {
+	result := conn(ctx, aps.db).Find(&apList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return apList, nil
+}
+
+func (aps *AccessPolicySvc) FindAccessPolicyByID(ctx context.Context, id string) (ap *AccessPolicy, err error) {
+	result := conn(ctx, aps.db).Preload("AccessGroup").Preload("DoorGroup").First(&ap, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return ap, nil
+}
+
+// Registers generated from the access policy
+func (aps *AccessPolicySvc) FindAccessPolicyRegisters(ctx context.Context, id string) (sList []Scheduler, err error) {
+	result := conn(ctx, aps.db).Where("access_policy_id = ?", id).Order("id").Find(&sList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return sList, nil
+}
+
+// Create access policy and a register for every member of its access group and doorlock of its door group
+func (aps *AccessPolicySvc) CreateAccessPolicy(ctx context.Context, ap *AccessPolicy) (*AccessPolicy, *RegisterDiff, error) {
+	if err := validateAccessPolicy(ap); err != nil {
+		return nil, nil, err
+	}
+	diff := &RegisterDiff{}
+	err := conn(ctx, aps.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("AccessGroup", "DoorGroup").Create(ap).Error; err != nil {
+			return err
+		}
+		var err error
+		diff, err = syncPolicyRegisters(tx, ap)
+		return err
+	})
+	if err != nil {
+		return nil, nil, utils.HandleQueryError(err)
+	}
+	return ap, diff, nil
+}
+
+// Update access policy, registers follow its groups and time fields
+func (aps *AccessPolicySvc) UpdateAccessPolicy(ctx context.Context, ap *AccessPolicy) (*RegisterDiff, error) {
+	if err := validateAccessPolicy(ap); err != nil {
+		return nil, err
+	}
+	diff := &RegisterDiff{}
+	err := conn(ctx, aps.db).Transaction(func(tx *gorm.DB) error {
+		found := &AccessPolicy{}
+		if err := tx.First(found, ap.ID).Error; err != nil {
+			return err
+		}
+		timeChanged := found.StartDate != ap.StartDate || found.EndDate != ap.EndDate || found.WeekDay != ap.WeekDay ||
+			found.StartClassTime != ap.StartClassTime || found.EndClassTime != ap.EndClassTime ||
+			found.Recurrence != ap.Recurrence || found.TimeWindows != ap.TimeWindows || found.TimeZone != ap.TimeZone
+		result := tx.Model(found).
+			Select("Name", "AccessGroupID", "DoorGroupID", "StartDate", "EndDate", "WeekDay", "StartClassTime", "EndClassTime",
+				"Recurrence", "TimeWindows", "TimeZone").
+			Updates(ap)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		if diff, err = syncPolicyRegisters(tx, ap); err != nil {
+			return err
+		}
+		if !timeChanged {
+			return nil
+		}
+
+		// Registers kept by the sync still have the old time fields
+		kept := tx.Model(&Scheduler{}).Where("access_policy_id = ?", ap.ID)
+		if len(diff.Created) > 0 {
+			kept = kept.Where("id NOT IN ?", schedulerIDs(diff.Created))
+		}
+		if err := kept.Updates(policyTimeFields(ap)).Error; err != nil {
+			return err
+		}
+		updated := tx.Where("access_policy_id = ?", ap.ID).Order("id")
+		if len(diff.Created) > 0 {
+			updated = updated.Where("id NOT IN ?", schedulerIDs(diff.Created))
+		}
+		return updated.Find(&diff.Updated).Error
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return diff, nil
+}
+
+// Delete access policy with all its registers
+func (aps *AccessPolicySvc) DeleteAccessPolicy(ctx context.Context, id uint) (*RegisterDiff, error) {
+	diff := &RegisterDiff{}
+	err := conn(ctx, aps.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("access_policy_id = ?", id).Order("id").Find(&diff.Deleted).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("access_policy_id = ?", id).Delete(&Scheduler{}).Error; err != nil {
+			return err
+		}
+		_, err := utils.ReturnBoolStateFromResult(tx.Where("id = ?", id).Delete(&AccessPolicy{}))
+		return err
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return diff, nil
+}
+
+// Recurrence, time windows and time zone are stored in canonical form
+func validateAccessPolicy(ap *AccessPolicy) error {
+	if ap.Name == "" {
+		return fmt.Errorf("access policy name is required")
+	}
+	if ap.AccessGroupID == 0 || ap.DoorGroupID == 0 {
+		return fmt.Errorf("access group and door group are required")
+	}
+	start, err := time.Parse(ACCESS_POLICY_DATE_LAYOUT, ap.StartDate)
+	if err != nil {
+		return fmt.Errorf("startDate must be dd/mm/yyyy")
+	}
+	end, err := time.Parse(ACCESS_POLICY_DATE_LAYOUT, ap.EndDate)
+	if err != nil {
+		return fmt.Errorf("endDate must be dd/mm/yyyy")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("endDate must be after startDate")
+	}
+	if ap.TimeZone == "" {
+		ap.TimeZone = DEFAULT_POLICY_TIME_ZONE
+	}
+	if _, err := time.LoadLocation(ap.TimeZone); err != nil {
+		return fmt.Errorf("unknown timeZone %s", ap.TimeZone)
+	}
+
+	if !ap.IsRecurring() {
+		if ap.TimeWindows != "" {
+			return fmt.Errorf("timeWindows needs a recurrence")
+		}
+		if ap.EndClassTime < ap.StartClassTime {
+			return fmt.Errorf("endClassTime must be after startClassTime")
+		}
+		return nil
+	}
+
+	r, err := ParseRecurrence(ap.Recurrence)
+	if err != nil {
+		return err
+	}
+	if ap.TimeWindows == "" {
+		return fmt.Errorf("timeWindows is required with a recurrence")
+	}
+	twList, err := ParseTimeWindows(ap.TimeWindows)
+	if err != nil {
+		return err
+	}
+	windows := []string{}
+	for _, tw := range twList {
+		windows = append(windows, tw.String())
+	}
+	ap.Recurrence = r.String()
+	ap.TimeWindows = strings.Join(windows, ",")
+	// Class periods do not apply to recurring policies
+	ap.WeekDay, ap.StartClassTime, ap.EndClassTime = 0, 0, 0
+	return nil
+}
+
+func policyTimeFields(ap *AccessPolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"start_date":       ap.StartDate,
+		"end_date":         ap.EndDate,
+		"week_day":         ap.WeekDay,
+		"start_class_time": ap.StartClassTime,
+		"end_class_time":   ap.EndClassTime,
+	}
+}
+
+func schedulerIDs(sList []Scheduler) []uint {
+	ids := []uint{}
+	for _, s := range sList {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// Create missing and delete extra registers of the policy, registers of unchanged members and doorlocks are kept
+func syncPolicyRegisters(tx *gorm.DB, ap *AccessPolicy) (*RegisterDiff, error) {
+	diff := &RegisterDiff{}
+
+	people := []Person{}
+	memberIDs := tx.Table("access_group_members").Select("person_id").Where("access_group_id = ?", ap.AccessGroupID)
+	if err := tx.Where("id IN (?)", memberIDs).Order("id").Find(&people).Error; err != nil {
+		return nil, err
+	}
+	doorIDs := []uint{}
+	groupDoorIDs := tx.Table("door_group_doorlocks").Select("doorlock_id").Where("door_group_id = ?", ap.DoorGroupID)
+	if err := tx.Model(&Doorlock{}).Where("id IN (?)", groupDoorIDs).Order("id").Pluck("id", &doorIDs).Error; err != nil {
+		return nil, err
+	}
+
+	type registerKey struct{ personID, doorID uint }
+	existing := []Scheduler{}
+	if err := tx.Where("access_policy_id = ?", ap.ID).Order("id").Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	kept := map[registerKey]bool{}
+	for _, s := range existing {
+		key := registerKey{doorID: s.DoorID}
+		if s.PersonID != nil {
+			key.personID = *s.PersonID
+		}
+		kept[key] = true
+	}
+
+	wanted := map[registerKey]bool{}
+	for i := range people {
+		for _, doorID := range doorIDs {
+			key := registerKey{people[i].ID, doorID}
+			wanted[key] = true
+			if kept[key] {
+				continue
+			}
+			s := Scheduler{
+				DoorID:         doorID,
+				Role:           people[i].Type,
+				UserID:         people[i].UserID,
+				PersonID:       &people[i].ID,
+				AccessPolicyID: &ap.ID,
+				StartDate:      ap.StartDate,
+				EndDate:        ap.EndDate,
+				WeekDay:        ap.WeekDay,
+				StartClassTime: ap.StartClassTime,
+				EndClassTime:   ap.EndClassTime,
+			}
+			if err := tx.Create(&s).Error; err != nil {
+				return nil, err
+			}
+			diff.Created = append(diff.Created, s)
+		}
+	}
+
+	for _, s := range existing {
+		key := registerKey{doorID: s.DoorID}
+		if s.PersonID != nil {
+			key.personID = *s.PersonID
+		}
+		if !wanted[key] {
+			diff.Deleted = append(diff.Deleted, s)
+		}
+	}
+	if len(diff.Deleted) > 0 {
+		if err := tx.Where("id IN ?", schedulerIDs(diff.Deleted)).Delete(&Scheduler{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return diff, nil
+}
+
+// Sync registers of every policy using the group, column is access_group_id or door_group_id
+func syncGroupPolicies(tx *gorm.DB, column string, groupID uint) (*RegisterDiff, error) {
+	policies := []AccessPolicy{}
+	if err := tx.Where(column+" = ?", groupID).Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	diff := &RegisterDiff{}
+	for i := range policies {
+		policyDiff, err := syncPolicyRegisters(tx, &policies[i])
+		if err != nil {
+			return nil, err
+		}
+		diff.merge(policyDiff)
+	}
+	return diff, nil
+}
@@ -0,0 +1,301 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestAccessPolicyRegisters(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss, gs, dls := NewStudentSvc(db), NewGatewaySvc(db), NewDoorlockSvc(db)
+	ags, dgs, aps := NewAccessGroupSvc(db), NewDoorGroupSvc(db), NewAccessPolicySvc(db)
+
+	s1, _ := ss.CreateStudent(ctx, &Student{MSSV: "s1", Email: "s1@mail", Major: "it", UserPass: UserPass{RfidPass: "card1"}})
+	s2, _ := ss.CreateStudent(ctx, &Student{MSSV: "s2", Email: "s2@mail", Major: "it", UserPass: UserPass{RfidPass: "card2"}})
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-2"})
+	d1, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d1", GatewayID: "gw-1", DoorlockAddress: "1"})
+	d2, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d2", GatewayID: "gw-2", DoorlockAddress: "1"})
+
+	ag, _ := ags.CreateAccessGroup(ctx, &AccessGroup{Name: "lab students"})
+	dg, _ := dgs.CreateDoorGroup(ctx, &DoorGroup{Name: "lab doors"})
+	if _, err := ags.AddAccessGroupMembers(ctx, ag.ID, []uint{*s1.PersonID}); err != nil {
+		t.Fatalf("add members failed: %v", err)
+	}
+	if _, err := dgs.AddDoorGroupDoorlocks(ctx, dg.ID, []uint{d1.ID, d2.ID}); err != nil {
+		t.Fatalf("add doorlocks failed: %v", err)
+	}
+
+	if _, _, err := aps.CreateAccessPolicy(ctx, &AccessPolicy{Name: "bad", AccessGroupID: ag.ID, DoorGroupID: dg.ID,
+		StartDate: "2022-09-01", EndDate: "31/12/2022"}); err == nil {
+		t.Fatalf("expected error on invalid start date")
+	}
+	ap, diff, err := aps.CreateAccessPolicy(ctx, &AccessPolicy{Name: "lab", AccessGroupID: ag.ID, DoorGroupID: dg.ID,
+		StartDate: "1/9/2022", EndDate: "31/12/2022", WeekDay: 2, StartClassTime: 1, EndClassTime: 3})
+	if err != nil {
+		t.Fatalf("create access policy failed: %v", err)
+	}
+	if len(diff.Created) != 2 || diff.Created[0].UserID != "s1" || diff.Created[0].Role != PERSON_TYPE_STUDENT {
+		t.Fatalf("expected 2 registers of s1, got %+v", diff.Created)
+	}
+
+	// New member gets registers on every door, existing ones are untouched
+	diff, _ = ags.AddAccessGroupMembers(ctx, ag.ID, []uint{*s2.PersonID})
+	if len(diff.Created) != 2 || len(diff.Deleted) != 0 || *diff.Created[0].PersonID != *s2.PersonID {
+		t.Fatalf("expected 2 registers of s2 created, got %+v", diff)
+	}
+
+	// Removed door only loses its own registers
+	diff, _ = dgs.RemoveDoorGroupDoorlocks(ctx, dg.ID, []uint{d2.ID})
+	if len(diff.Created) != 0 || len(diff.Deleted) != 2 || diff.Deleted[0].DoorID != d2.ID || diff.Deleted[1].DoorID != d2.ID {
+		t.Fatalf("expected 2 registers of d2 deleted, got %+v", diff)
+	}
+	registers, _ := aps.FindAccessPolicyRegisters(ctx, fmt.Sprint(ap.ID))
+	if len(registers) != 2 {
+		t.Fatalf("expected 2 registers left, got %d", len(registers))
+	}
+
+	// Generated registers change with their policy only
+	if _, err := NewSchedulerSvc(db).DeleteScheduler(ctx, registers[0].ID); err == nil {
+		t.Fatalf("expected error on deleting policy register")
+	}
+	if _, err := ags.DeleteAccessGroup(ctx, ag.ID); err == nil {
+		t.Fatalf("expected error on deleting access group used by policy")
+	}
+
+	ap.Name = "lab renamed"
+	if diff, _ = aps.UpdateAccessPolicy(ctx, ap); len(diff.Updated)+len(diff.Created)+len(diff.Deleted) != 0 {
+		t.Fatalf("expected no register change on rename, got %+v", diff)
+	}
+	ap.EndClassTime = 5
+	diff, _ = aps.UpdateAccessPolicy(ctx, ap)
+	if len(diff.Updated) != 2 || diff.Updated[0].EndClassTime != 5 {
+		t.Fatalf("expected 2 registers updated, got %+v", diff)
+	}
+
+	// Recurring policy drops class periods of its registers
+	ap.Recurrence, ap.TimeWindows = "FREQ=DAILY", "05:00-06:00"
+	diff, err = aps.UpdateAccessPolicy(ctx, ap)
+	if err != nil || len(diff.Updated) != 2 || diff.Updated[0].WeekDay != 0 || diff.Updated[0].EndClassTime != 0 {
+		t.Fatalf("expected 2 registers updated without class periods, got %+v, %v", diff, err)
+	}
+	if found, _ := aps.FindAccessPolicyByID(ctx, fmt.Sprint(ap.ID)); !found.IsRecurring() || found.TimeZone != DEFAULT_POLICY_TIME_ZONE {
+		t.Fatalf("expected recurring policy in default time zone, got %+v", found)
+	}
+
+	diff, _ = aps.DeleteAccessPolicy(ctx, ap.ID)
+	if len(diff.Deleted) != 2 {
+		t.Fatalf("expected 2 registers deleted with policy, got %+v", diff)
+	}
+	if registers, _ := aps.FindAccessPolicyRegisters(ctx, fmt.Sprint(ap.ID)); len(registers) != 0 {
+		t.Fatalf("expected no register left, got %d", len(registers))
+	}
+}
+
+// Students s1 to s3 with doorlocks d1 and d2 in one door group, s1 alone in the access group of a class policy
+type policyFixture struct {
+	ags    *AccessGroupSvc
+	dgs    *DoorGroupSvc
+	aps    *AccessPolicySvc
+	people []uint
+	doors  []uint
+	ag     *AccessGroup
+	dg     *DoorGroup
+	ap     *AccessPolicy
+}
+
+func newPolicyFixture(t *testing.T) *policyFixture {
+	t.Helper()
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss, gs, dls := NewStudentSvc(db), NewGatewaySvc(db), NewDoorlockSvc(db)
+	f := &policyFixture{ags: NewAccessGroupSvc(db), dgs: NewDoorGroupSvc(db), aps: NewAccessPolicySvc(db)}
+
+	for i := 1; i <= 3; i++ {
+		s, err := ss.CreateStudent(ctx, &Student{MSSV: fmt.Sprintf("s%d", i), Email: fmt.Sprintf("s%d@mail", i), Major: "it",
+			UserPass: UserPass{RfidPass: fmt.Sprintf("card%d", i)}})
+		if err != nil {
+			t.Fatalf("create student failed: %v", err)
+		}
+		f.people = append(f.people, *s.PersonID)
+	}
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	for i := 1; i <= 2; i++ {
+		d, err := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: fmt.Sprintf("d%d", i), GatewayID: "gw-1", DoorlockAddress: fmt.Sprint(i)})
+		if err != nil {
+			t.Fatalf("create doorlock failed: %v", err)
+		}
+		f.doors = append(f.doors, d.ID)
+	}
+
+	f.ag, _ = f.ags.CreateAccessGroup(ctx, &AccessGroup{Name: "lab students"})
+	f.dg, _ = f.dgs.CreateDoorGroup(ctx, &DoorGroup{Name: "lab doors"})
+	f.ags.AddAccessGroupMembers(ctx, f.ag.ID, f.people[:1])
+	f.dgs.AddDoorGroupDoorlocks(ctx, f.dg.ID, f.doors)
+	var err error
+	f.ap, _, err = f.aps.CreateAccessPolicy(ctx, &AccessPolicy{Name: "lab", AccessGroupID: f.ag.ID, DoorGroupID: f.dg.ID,
+		StartDate: "1/9/2022", EndDate: "31/12/2022", WeekDay: 2, StartClassTime: 1, EndClassTime: 3})
+	if err != nil {
+		t.Fatalf("create access policy failed: %v", err)
+	}
+	return f
+}
+
+// Registers of the policy by id
+func (f *policyFixture) registers(t *testing.T) map[uint]Scheduler {
+	t.Helper()
+	sList, err := f.aps.FindAccessPolicyRegisters(context.Background(), fmt.Sprint(f.ap.ID))
+	if err != nil {
+		t.Fatalf("find registers failed: %v", err)
+	}
+	registers := map[uint]Scheduler{}
+	for _, s := range sList {
+		registers[s.ID] = s
+	}
+	return registers
+}
+
+func TestAccessGroupMembersRegisterDiff(t *testing.T) {
+	f := newPolicyFixture(t)
+	ctx := context.Background()
+	s2, s3 := f.people[1], f.people[2]
+	before := f.registers(t)
+
+	// New members get a register on every door, the registers of s1 are untouched
+	diff, err := f.ags.AddAccessGroupMembers(ctx, f.ag.ID, []uint{s2, s3})
+	if err != nil {
+		t.Fatalf("add members failed: %v", err)
+	}
+	if len(diff.Created) != 4 || len(diff.Updated) != 0 || len(diff.Deleted) != 0 {
+		t.Fatalf("got %d created, %d updated, %d deleted, wanted 4 created only", len(diff.Created), len(diff.Updated), len(diff.Deleted))
+	}
+	for _, s := range diff.Created {
+		if *s.PersonID != s2 && *s.PersonID != s3 {
+			t.Errorf("got register of person %d created, wanted s2 or s3", *s.PersonID)
+		}
+	}
+	after := f.registers(t)
+	for id, s := range before {
+		if _, ok := after[id]; !ok || *after[id].PersonID != *s.PersonID {
+			t.Errorf("register %d of s1 was replaced", id)
+		}
+	}
+
+	// Removed member loses only its own registers
+	diff, err = f.ags.RemoveAccessGroupMembers(ctx, f.ag.ID, []uint{s2})
+	if err != nil {
+		t.Fatalf("remove members failed: %v", err)
+	}
+	if len(diff.Created) != 0 || len(diff.Updated) != 0 || len(diff.Deleted) != 2 {
+		t.Fatalf("got %d created, %d updated, %d deleted, wanted 2 deleted only", len(diff.Created), len(diff.Updated), len(diff.Deleted))
+	}
+	for _, s := range diff.Deleted {
+		if *s.PersonID != s2 {
+			t.Errorf("got register of person %d deleted, wanted s2", *s.PersonID)
+		}
+		delete(after, s.ID)
+	}
+	if left := f.registers(t); !reflect.DeepEqual(schedulerIDSet(left), schedulerIDSet(after)) {
+		t.Errorf("got registers %v left, wanted %v", schedulerIDSet(left), schedulerIDSet(after))
+	}
+
+	// Removing a person not in the group changes nothing
+	diff, _ = f.ags.RemoveAccessGroupMembers(ctx, f.ag.ID, []uint{s2})
+	if len(diff.Created)+len(diff.Updated)+len(diff.Deleted) != 0 {
+		t.Errorf("got %+v, wanted no register change", diff)
+	}
+}
+
+func TestUpdateAccessPolicyTimes(t *testing.T) {
+	f := newPolicyFixture(t)
+	ctx := context.Background()
+	f.ags.AddAccessGroupMembers(ctx, f.ag.ID, f.people[1:2])
+	before := f.registers(t)
+
+	// Time fields are changed in place, the registers keep their ids
+	ap := *f.ap
+	ap.StartDate, ap.EndDate, ap.WeekDay, ap.StartClassTime, ap.EndClassTime = "1/1/2023", "31/5/2023", 4, 2, 6
+	diff, err := f.aps.UpdateAccessPolicy(ctx, &ap)
+	if err != nil {
+		t.Fatalf("update access policy failed: %v", err)
+	}
+	if len(diff.Created) != 0 || len(diff.Deleted) != 0 || len(diff.Updated) != len(before) {
+		t.Fatalf("got %d created, %d updated, %d deleted, wanted %d updated only", len(diff.Created), len(diff.Updated), len(diff.Deleted), len(before))
+	}
+	after := f.registers(t)
+	if !reflect.DeepEqual(schedulerIDSet(after), schedulerIDSet(before)) {
+		t.Fatalf("got registers %v, wanted %v kept", schedulerIDSet(after), schedulerIDSet(before))
+	}
+	for _, s := range after {
+		if s.StartDate != "1/1/2023" || s.EndDate != "31/5/2023" || s.WeekDay != 4 || s.StartClassTime != 2 || s.EndClassTime != 6 {
+			t.Errorf("got register %+v, wanted new time fields", s)
+		}
+	}
+
+	// Moving to a door group without d2 drops its registers, the others are updated
+	dg, _ := f.dgs.CreateDoorGroup(ctx, &DoorGroup{Name: "front door"})
+	f.dgs.AddDoorGroupDoorlocks(ctx, dg.ID, f.doors[:1])
+	ap.DoorGroupID, ap.EndClassTime = dg.ID, 8
+	diff, err = f.aps.UpdateAccessPolicy(ctx, &ap)
+	if err != nil {
+		t.Fatalf("update access policy failed: %v", err)
+	}
+	if len(diff.Created) != 0 || len(diff.Deleted) != 2 || len(diff.Updated) != 2 {
+		t.Fatalf("got %d created, %d updated, %d deleted, wanted 2 updated and 2 deleted", len(diff.Created), len(diff.Updated), len(diff.Deleted))
+	}
+	for _, s := range diff.Updated {
+		if s.DoorID != f.doors[0] || s.EndClassTime != 8 {
+			t.Errorf("got register %+v updated, wanted d1 ending at 8", s)
+		}
+		if _, ok := before[s.ID]; !ok {
+			t.Errorf("register %d was recreated", s.ID)
+		}
+	}
+	for _, s := range diff.Deleted {
+		if s.DoorID != f.doors[1] {
+			t.Errorf("got register of door %d deleted, wanted d2", s.DoorID)
+		}
+	}
+}
+
+func TestDeleteGroupUsedByPolicy(t *testing.T) {
+	f := newPolicyFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.ags.DeleteAccessGroup(ctx, f.ag.ID); err == nil {
+		t.Fatal("expected error on deleting access group used by policy")
+	}
+	if _, err := f.dgs.DeleteDoorGroup(ctx, f.dg.ID); err == nil {
+		t.Fatal("expected error on deleting door group used by policy")
+	}
+	if ag, err := f.ags.FindAccessGroupByID(ctx, fmt.Sprint(f.ag.ID)); err != nil || len(ag.Members) != 1 {
+		t.Fatalf("got %+v, %v, wanted access group kept with its member", ag, err)
+	}
+	if registers := f.registers(t); len(registers) != 2 {
+		t.Fatalf("got %d registers, wanted 2 kept", len(registers))
+	}
+
+	// Deleted once no policy uses it
+	if _, err := f.aps.DeleteAccessPolicy(ctx, f.ap.ID); err != nil {
+		t.Fatalf("delete access policy failed: %v", err)
+	}
+	if ok, err := f.ags.DeleteAccessGroup(ctx, f.ag.ID); !ok || err != nil {
+		t.Fatalf("delete access group failed: %v", err)
+	}
+}
+
+func schedulerIDSet(registers map[uint]Scheduler) []uint {
+	ids := []uint{}
+	for id := range registers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
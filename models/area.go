@@ -12,6 +12,16 @@ type Area struct {
 	Name    string `gorm:"unique;not null" json:"name"`
 	Manager string `gorm:"not null" json:"manager"`
 }
+
+// Areas
+type AreaService interface {
+	FindAllArea(ctx context.Context) (aList []Area, err error)
+	FindAreaByID(ctx context.Context, id string) (a *Area, err error)
+	CreateArea(ctx context.Context, a *Area) (*Area, error)
+	UpdateArea(ctx context.Context, a *Area) (bool, error)
+	DeleteArea(ctx context.Context, areaId uint) (bool, error)
+}
+
 type AreaSvc struct {
 	db *gorm.DB
 }
@@ -23,7 +33,7 @@ func NewAreaSvc(db *gorm.DB) *AreaSvc {
 }
 
 func (as *AreaSvc) FindAllArea(ctx context.Context) (aList []Area, err error) {
-	result := as.db.Find(&aList)
+	result := conn(ctx, as.db).Find(&aList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -32,7 +42,7 @@ func (as *AreaSvc) FindAllArea(ctx context.Context) (aList []Area, err error) {
 }
 
 func (as *AreaSvc) FindAreaByID(ctx context.Context, id string) (a *Area, err error) {
-	result := as.db.First(&a, id)
+	result := conn(ctx, as.db).First(&a, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -40,8 +50,8 @@ func (as *AreaSvc) FindAreaByID(ctx context.Context, id string) (a *Area, err er
 	return a, nil
 }
 
-func (as *AreaSvc) CreateArea(a *Area, ctx context.Context) (*Area, error) {
-	if err := as.db.Create(&a).Error; err != nil {
+func (as *AreaSvc) CreateArea(ctx context.Context, a *Area) (*Area, error) {
+	if err := conn(ctx, as.db).Create(&a).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -49,11 +59,11 @@ func (as *AreaSvc) CreateArea(a *Area, ctx context.Context) (*Area, error) {
 }
 
 func (as *AreaSvc) UpdateArea(ctx context.Context, a *Area) (bool, error) {
-	result := as.db.Model(&a).Where("id = ?", a.ID).Updates(a)
+	result := conn(ctx, as.db).Model(&a).Where("id = ?", a.ID).Updates(a)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (as *AreaSvc) DeleteArea(ctx context.Context, areaId uint) (bool, error) {
-	result := as.db.Unscoped().Where("id = ?", areaId).Delete(&Area{})
+	result := conn(ctx, as.db).Unscoped().Where("id = ?", areaId).Delete(&Area{})
 	return utils.ReturnBoolStateFromResult(result)
 }
@@ -0,0 +1,291 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	AUDIT_ACTION_CREATE  string = "create"
+	AUDIT_ACTION_UPDATE  string = "update"
+	AUDIT_ACTION_DELETE  string = "delete"
+	AUDIT_ACTION_RESTORE string = "restore"
+	AUDIT_ACTION_COMMAND string = "command" // MQTT command sent to gateway
+	AUDIT_ACTION_REVOKE  string = "revoke"
+	AUDIT_ACTION_APPROVE string = "approve"
+	AUDIT_ACTION_REJECT  string = "reject"
+	AUDIT_ACTION_REPLAY  string = "replay"
+	AUDIT_ACTION_DISCARD string = "discard"
+
+	AUDIT_ENTITY_AREA                string = "area"
+	AUDIT_ENTITY_GATEWAY             string = "gateway"
+	AUDIT_ENTITY_DOORLOCK            string = "doorlock"
+	AUDIT_ENTITY_BLOCK               string = "block"
+	AUDIT_ENTITY_STUDENT             string = "student"
+	AUDIT_ENTITY_EMPLOYEE            string = "employee"
+	AUDIT_ENTITY_CUSTOMER            string = "customer"
+	AUDIT_ENTITY_SCHEDULER           string = "scheduler"
+	AUDIT_ENTITY_SECRET_KEY          string = "secretKey"
+	AUDIT_ENTITY_GATEWAY_LOG_PERIOD  string = "gatewayLogCleanPeriod"
+	AUDIT_ENTITY_DOORLOCK_STATUS_LOG string = "doorlockStatusLog"
+	AUDIT_ENTITY_CREDENTIAL          string = "credential"
+	AUDIT_ENTITY_ACCESS_GROUP        string = "accessGroup"
+	AUDIT_ENTITY_DOOR_GROUP          string = "doorGroup"
+	AUDIT_ENTITY_ACCESS_POLICY       string = "accessPolicy"
+	AUDIT_ENTITY_ROOM_SETTING        string = "roomSetting"
+	AUDIT_ENTITY_ROOM_OCCUPANCY      string = "roomOccupancy"
+	AUDIT_ENTITY_UNLOCK_REQUEST      string = "unlockRequest"
+	AUDIT_ENTITY_SCHEDULED_COMMAND   string = "scheduledCommand"
+	AUDIT_ENTITY_RETENTION_POLICY    string = "retentionPolicy"
+	AUDIT_ENTITY_MQTT_DEAD_LETTER    string = "mqttDeadLetter"
+
+	DEFAULT_AUDIT_LOG_LIMIT int = 100
+	MAX_AUDIT_LOG_LIMIT     int = 1000
+
+	auditMaskedValue string = "***"
+)
+
+// Fields never written in clear text to audit logs, changes are still reported
+var auditMaskedFields = map[string]bool{
+	"rfidPass":   true,
+	"keypadPass": true,
+	"secret":     true,
+	"value":      true, // credential value
+}
+
+var ErrAuditLogAppendOnly = fmt.Errorf("audit logs are append-only")
+
+type AuditLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `gorm:"index" json:"createdAt"`
+	Actor         string    `gorm:"type:varchar(256);index" json:"actor"`
+	Action        string    `gorm:"type:varchar(50);not null" json:"action"`
+	EntityType    string    `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity" json:"entityType"`
+	EntityID      string    `gorm:"type:varchar(256);index:idx_audit_logs_entity" json:"entityId"`
+	Before        string    `json:"before"` // JSON snapshot, empty on create
+	After         string    `json:"after"`  // JSON snapshot, empty on delete
+	Diff          string    `json:"diff"`   // JSON map of field to AuditChange
+	SourceIP      string    `gorm:"type:varchar(64)" json:"sourceIp"`
+	CorrelationID string    `gorm:"type:varchar(64);index" json:"correlationId"`
+}
+
+// Struct defines changed field in audit log diff
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Struct defines HTTP query for finding audit logs, time range in unix seconds
+type AuditLogFilter struct {
+	Actor         string `form:"actor"`
+	Action        string `form:"action"`
+	EntityType    string `form:"entityType"`
+	EntityID      string `form:"entityId"`
+	CorrelationID string `form:"correlationId"`
+	From          int64  `form:"from"`
+	To            int64  `form:"to"`
+	Limit         int    `form:"limit"`
+	Offset        int    `form:"offset"`
+}
+
+func (al *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+func (al *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// Build audit log from entity snapshots, before is nil on create and after is nil on delete
+func NewAuditLog(action string, entityType string, entityID string, before interface{}, after interface{}) (*AuditLog, error) {
+	beforeMap, err := auditSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	afterMap, err := auditSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]AuditChange{}
+	for k, v := range afterMap {
+		if bv, ok := beforeMap[k]; !ok || !reflect.DeepEqual(bv, v) {
+			diff[k] = AuditChange{From: beforeMap[k], To: v}
+		}
+	}
+	for k, v := range beforeMap {
+		if _, ok := afterMap[k]; !ok {
+			diff[k] = AuditChange{From: v, To: nil}
+		}
+	}
+	maskAuditFields(beforeMap, afterMap, diff)
+
+	al := &AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	if al.Before, err = marshalAuditMap(beforeMap); err != nil {
+		return nil, err
+	}
+	if al.After, err = marshalAuditMap(afterMap); err != nil {
+		return nil, err
+	}
+	diffJson, err := json.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	al.Diff = string(diffJson)
+	return al, nil
+}
+
+// Append-only audit log
+type AuditLogService interface {
+	CreateAuditLog(ctx context.Context, al *AuditLog) (*AuditLog, error)
+	FindAuditLogs(ctx context.Context, filter *AuditLogFilter) (alList []AuditLog, err error)
+	EachAuditLog(ctx context.Context, filter *AuditLogFilter, fn func(al *AuditLog) error) error
+}
+
+type AuditLogSvc struct {
+	db *gorm.DB
+}
+
+func NewAuditLogSvc(db *gorm.DB) *AuditLogSvc {
+	return &AuditLogSvc{
+		db: db,
+	}
+}
+
+func (als *AuditLogSvc) CreateAuditLog(ctx context.Context, al *AuditLog) (*AuditLog, error) {
+	if err := conn(ctx, als.db).Create(al).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return al, nil
+}
+
+// Find audit logs newest first, limit is capped at MAX_AUDIT_LOG_LIMIT
+func (als *AuditLogSvc) FindAuditLogs(ctx context.Context, filter *AuditLogFilter) (alList []AuditLog, err error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DEFAULT_AUDIT_LOG_LIMIT
+	}
+	if limit > MAX_AUDIT_LOG_LIMIT {
+		limit = MAX_AUDIT_LOG_LIMIT
+	}
+	result := als.filterQuery(ctx, filter).Order("id desc").Limit(limit).Offset(filter.Offset).Find(&alList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return alList, nil
+}
+
+// Walk every matching audit log oldest first in batches, used by export
+func (als *AuditLogSvc) EachAuditLog(ctx context.Context, filter *AuditLogFilter, fn func(al *AuditLog) error) error {
+	alList := []AuditLog{}
+	result := als.filterQuery(ctx, filter).Order("id asc").FindInBatches(&alList, MAX_AUDIT_LOG_LIMIT, func(tx *gorm.DB, batch int) error {
+		for i := range alList {
+			if err := fn(&alList[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return err
+	}
+	return nil
+}
+
+func (als *AuditLogSvc) filterQuery(ctx context.Context, filter *AuditLogFilter) *gorm.DB {
+	query := conn(ctx, als.db).Model(&AuditLog{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.CorrelationID != "" {
+		query = query.Where("correlation_id = ?", filter.CorrelationID)
+	}
+	if filter.From > 0 {
+		query = query.Where("created_at >= ?", time.Unix(filter.From, 0).UTC())
+	}
+	if filter.To > 0 {
+		query = query.Where("created_at <= ?", time.Unix(filter.To, 0).UTC())
+	}
+	return query
+}
+
+// Convert entity to JSON map, associations are left out since they are audited on their own
+// and null values are left out so a missing association does not show up as a change
+func auditSnapshot(entity interface{}) (map[string]interface{}, error) {
+	snapshot := map[string]interface{}{}
+	if entity == nil {
+		return snapshot, nil
+	}
+	if v := reflect.ValueOf(entity); v.Kind() == reflect.Ptr && v.IsNil() {
+		return snapshot, nil
+	}
+	entityJson, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(entityJson, &snapshot); err != nil {
+		return nil, err
+	}
+	for k, v := range snapshot {
+		_, isList := v.([]interface{})
+		_, isObject := v.(map[string]interface{})
+		if isList || isObject || v == nil {
+			delete(snapshot, k)
+		}
+	}
+	return snapshot, nil
+}
+
+func maskAuditFields(beforeMap map[string]interface{}, afterMap map[string]interface{}, diff map[string]AuditChange) {
+	for field := range auditMaskedFields {
+		if _, ok := beforeMap[field]; ok {
+			beforeMap[field] = auditMaskedValue
+		}
+		if _, ok := afterMap[field]; ok {
+			afterMap[field] = auditMaskedValue
+		}
+		if change, ok := diff[field]; ok {
+			diff[field] = AuditChange{From: maskAuditValue(change.From), To: maskAuditValue(change.To)}
+		}
+	}
+}
+
+func maskAuditValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return auditMaskedValue
+}
+
+func marshalAuditMap(m map[string]interface{}) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	mJson, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(mJson), nil
+}
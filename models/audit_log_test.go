@@ -0,0 +1,119 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestNewAuditLogDiffAndMask(t *testing.T) {
+	before := &Student{MSSV: "s1", Name: "old", UserPass: UserPass{RfidPass: "1111"}}
+	after := &Student{MSSV: "s1", Name: "new", UserPass: UserPass{RfidPass: "2222"}}
+
+	al, err := NewAuditLog(AUDIT_ACTION_UPDATE, AUDIT_ENTITY_STUDENT, "s1", before, after)
+	if err != nil {
+		t.Fatalf("build audit log failed: %v", err)
+	}
+
+	diff := map[string]AuditChange{}
+	if err := json.Unmarshal([]byte(al.Diff), &diff); err != nil {
+		t.Fatalf("decode diff failed: %v", err)
+	}
+	if len(diff) != 2 {
+		t.Fatalf("expected name and rfidPass in diff, got %v", diff)
+	}
+	if diff["name"].From != "old" || diff["name"].To != "new" {
+		t.Fatalf("unexpected name change %+v", diff["name"])
+	}
+	if diff["rfidPass"].From != auditMaskedValue || diff["rfidPass"].To != auditMaskedValue {
+		t.Fatalf("rfidPass is not masked in diff %+v", diff["rfidPass"])
+	}
+
+	snapshot := map[string]interface{}{}
+	json.Unmarshal([]byte(al.After), &snapshot)
+	if snapshot["rfidPass"] != auditMaskedValue {
+		t.Fatalf("rfidPass is not masked in snapshot %v", snapshot["rfidPass"])
+	}
+	if _, ok := snapshot["schedulers"]; ok {
+		t.Fatalf("associations should be left out of snapshot")
+	}
+}
+
+func TestNewAuditLogCreateAndDelete(t *testing.T) {
+	s := &Student{MSSV: "s1"}
+
+	created, err := NewAuditLog(AUDIT_ACTION_CREATE, AUDIT_ENTITY_STUDENT, "s1", nil, s)
+	if err != nil {
+		t.Fatalf("build audit log failed: %v", err)
+	}
+	if created.Before != "" || created.After == "" {
+		t.Fatalf("expected only after snapshot on create, got %+v", created)
+	}
+
+	var nilStudent *Student
+	deleted, err := NewAuditLog(AUDIT_ACTION_DELETE, AUDIT_ENTITY_STUDENT, "s1", s, nilStudent)
+	if err != nil {
+		t.Fatalf("build audit log failed: %v", err)
+	}
+	if deleted.Before == "" || deleted.After != "" {
+		t.Fatalf("expected only before snapshot on delete, got %+v", deleted)
+	}
+}
+
+func TestAuditLogAppendOnly(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	als := NewAuditLogSvc(db)
+
+	al, err := als.CreateAuditLog(ctx, &AuditLog{Actor: "admin", Action: AUDIT_ACTION_CREATE, EntityType: AUDIT_ENTITY_AREA, EntityID: "1"})
+	if err != nil {
+		t.Fatalf("create audit log failed: %v", err)
+	}
+
+	if err := db.Model(al).Update("actor", "someone").Error; err != ErrAuditLogAppendOnly {
+		t.Fatalf("expected update to be rejected, got %v", err)
+	}
+	if err := db.Delete(al).Error; err != ErrAuditLogAppendOnly {
+		t.Fatalf("expected delete to be rejected, got %v", err)
+	}
+}
+
+func TestFindAuditLogs(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	als := NewAuditLogSvc(db)
+
+	als.CreateAuditLog(ctx, &AuditLog{Actor: "admin", Action: AUDIT_ACTION_CREATE, EntityType: AUDIT_ENTITY_AREA, EntityID: "1", CorrelationID: "c1"})
+	als.CreateAuditLog(ctx, &AuditLog{Actor: "admin", Action: AUDIT_ACTION_DELETE, EntityType: AUDIT_ENTITY_AREA, EntityID: "1", CorrelationID: "c2"})
+	als.CreateAuditLog(ctx, &AuditLog{Actor: "guard", Action: AUDIT_ACTION_COMMAND, EntityType: AUDIT_ENTITY_DOORLOCK, EntityID: "5", CorrelationID: "c2"})
+
+	alList, err := als.FindAuditLogs(ctx, &AuditLogFilter{EntityType: AUDIT_ENTITY_AREA})
+	if err != nil {
+		t.Fatalf("find audit logs failed: %v", err)
+	}
+	if len(alList) != 2 || alList[0].Action != AUDIT_ACTION_DELETE {
+		t.Fatalf("expected 2 area logs newest first, got %+v", alList)
+	}
+
+	alList, _ = als.FindAuditLogs(ctx, &AuditLogFilter{CorrelationID: "c2", Actor: "guard"})
+	if len(alList) != 1 || alList[0].EntityID != "5" {
+		t.Fatalf("expected guard command log, got %+v", alList)
+	}
+
+	alList, _ = als.FindAuditLogs(ctx, &AuditLogFilter{Limit: 1, Offset: 1})
+	if len(alList) != 1 || alList[0].Action != AUDIT_ACTION_DELETE {
+		t.Fatalf("expected second newest log, got %+v", alList)
+	}
+
+	exported := 0
+	err = als.EachAuditLog(ctx, &AuditLogFilter{}, func(al *AuditLog) error {
+		exported++
+		return nil
+	})
+	if err != nil || exported != 3 {
+		t.Fatalf("expected 3 exported logs, got %d, err %v", exported, err)
+	}
+}
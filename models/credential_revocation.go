@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Blacklisted credential pushed to gateways, completed when every gateway holding registers of the user has acknowledged
+type CredentialRevocation struct {
+	GormModel
+	CredentialID uint            `gorm:"not null;index" json:"credentialId"`
+	PersonID     uint            `gorm:"not null;index" json:"personId"`
+	UserID       string          `gorm:"type:varchar(256);not null" json:"userId"`
+	Type         string          `gorm:"type:varchar(50);not null" json:"type"`
+	Value        string          `gorm:"type:varchar(256);not null" json:"value"`
+	Reason       string          `json:"reason"`
+	RevokedBy    string          `gorm:"type:varchar(256)" json:"revokedBy"`
+	CompletedAt  *time.Time      `json:"completedAt"`
+	Acks         []RevocationAck `gorm:"foreignKey:RevocationID;constraint:OnDelete:CASCADE;" json:"acks"`
+}
+
+// Acknowledgement of a revocation by one gateway, AckedAt is nil until the gateway confirms
+type RevocationAck struct {
+	GormModel
+	RevocationID uint       `gorm:"not null;uniqueIndex:idx_revocation_acks_revocation_gateway" json:"revocationId"`
+	GatewayID    string     `gorm:"type:varchar(256);not null;uniqueIndex:idx_revocation_acks_revocation_gateway" json:"gatewayId"`
+	AckedAt      *time.Time `json:"ackedAt"`
+}
+
+// Struct defines HTTP request payload for revoking a credential, replacement value gives the person a new credential of the same type
+type RevokeCredentialReq struct {
+	Reason           string `json:"reason" binding:"required"`
+	ReplacementValue string `json:"replacementValue"`
+}
+
+// Revoked credentials and their acknowledgement by gateways
+type CredentialRevocationService interface {
+	FindAllRevocation(ctx context.Context, pendingOnly bool) (rList []CredentialRevocation, err error)
+	FindRevocationByID(ctx context.Context, id string) (r *CredentialRevocation, err error)
+	FindBlacklist(ctx context.Context) (rList []CredentialRevocation, err error)
+	RevokeCredential(ctx context.Context, credentialID uint, req *RevokeCredentialReq, revokedBy string) (r *CredentialRevocation, replacement *Credential, err error)
+	AckRevocation(ctx context.Context, revocationID uint, gatewayID string) (bool, error)
+}
+
+type CredentialRevocationSvc struct {
+	db *gorm.DB
+}
+
+func NewCredentialRevocationSvc(db *gorm.DB) *CredentialRevocationSvc {
+	return &CredentialRevocationSvc{
+		db: db,
+	}
+}
+
+// Find revocations oldest first, pendingOnly keeps those still waiting for gateway acknowledgements
+func (rs *CredentialRevocationSvc) FindAllRevocation(ctx context.Context, pendingOnly bool) (rList []CredentialRevocation, err error) {
+	query := conn(ctx, rs.db).Preload("Acks")
+	if pendingOnly {
+		query = query.Where("completed_at IS NULL")
+	}
+	result := query.Order("id").Find(&rList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return rList, nil
+}
+
+func (rs *CredentialRevocationSvc) FindRevocationByID(ctx context.Context, id string) (r *CredentialRevocation, err error) {
+	result := conn(ctx, rs.db).Preload("Acks").First(&r, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// All revoked credentials, sent to gateways at bootup
+func (rs *CredentialRevocationSvc) FindBlacklist(ctx context.Context) (rList []CredentialRevocation, err error) {
+	result := conn(ctx, rs.db).Order("id").Find(&rList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return rList, nil
+}
+
+// Blacklist credential and wait for acknowledgement of every gateway holding registers of its person.
+// Replacement credential is returned when a replacement value is given
+func (rs *CredentialRevocationSvc) RevokeCredential(
+	ctx context.Context,
+	credentialID uint,
+	req *RevokeCredentialReq,
+	revokedBy string,
+) (r *CredentialRevocation, replacement *Credential, err error) {
+	err = conn(ctx, rs.db).Transaction(func(tx *gorm.DB) error {
+		cred := &Credential{}
+		if err := tx.First(cred, credentialID).Error; err != nil {
+			return utils.HandleQueryError(err)
+		}
+		if cred.Status == CREDENTIAL_STATUS_BLACKLISTED {
+			return fmt.Errorf("credential is revoked already")
+		}
+		p := &Person{}
+		if err := tx.First(p, cred.PersonID).Error; err != nil {
+			return utils.HandleQueryError(err)
+		}
+
+		if err := tx.Model(cred).Update("status", CREDENTIAL_STATUS_BLACKLISTED).Error; err != nil {
+			return err
+		}
+		if req.ReplacementValue != "" {
+			replacement = &Credential{
+				PersonID:  cred.PersonID,
+				Type:      cred.Type,
+				Value:     req.ReplacementValue,
+				Status:    CREDENTIAL_STATUS_ACTIVE,
+				ValidFrom: cred.ValidFrom,
+				ValidTo:   cred.ValidTo,
+			}
+			if err := validateCredential(tx, replacement); err != nil {
+				return err
+			}
+			if err := tx.Create(replacement).Error; err != nil {
+				return err
+			}
+		}
+
+		gwIDs, err := personGatewayIDs(tx, p.ID)
+		if err != nil {
+			return err
+		}
+		r = &CredentialRevocation{
+			CredentialID: cred.ID,
+			PersonID:     p.ID,
+			UserID:       p.UserID,
+			Type:         cred.Type,
+			Value:        cred.Value,
+			Reason:       req.Reason,
+			RevokedBy:    revokedBy,
+		}
+		for _, gwID := range gwIDs {
+			r.Acks = append(r.Acks, RevocationAck{GatewayID: gwID})
+		}
+		if len(r.Acks) == 0 {
+			now := time.Now()
+			r.CompletedAt = &now
+		}
+		return tx.Create(r).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, replacement, nil
+}
+
+// Mark revocation acknowledged by gateway, revocation is completed with the last acknowledgement
+func (rs *CredentialRevocationSvc) AckRevocation(ctx context.Context, revocationID uint, gatewayID string) (bool, error) {
+	isSuccess := false
+	err := conn(ctx, rs.db).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&RevocationAck{}).
+			Where("revocation_id = ? AND gateway_id = ? AND acked_at IS NULL", revocationID, gatewayID).
+			Update("acked_at", now)
+		var err error
+		if isSuccess, err = utils.ReturnBoolStateFromResult(result); err != nil {
+			return err
+		}
+
+		var pending int64
+		if err := tx.Model(&RevocationAck{}).Where("revocation_id = ? AND acked_at IS NULL", revocationID).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return nil
+		}
+		return tx.Model(&CredentialRevocation{}).Where("id = ? AND completed_at IS NULL", revocationID).
+			Update("completed_at", now).Error
+	})
+	return isSuccess, err
+}
+
+// Gateways holding registers of the person, HP employees are registered on every gateway
+func personGatewayIDs(tx *gorm.DB, personID uint) (gwIDs []string, err error) {
+	var hp int64
+	err = tx.Model(&Employee{}).Where("person_id = ? AND highest_priority = ?", personID, true).Count(&hp).Error
+	if err != nil {
+		return nil, err
+	}
+	if hp > 0 {
+		err = tx.Model(&Gateway{}).Order("gateway_id").Pluck("gateway_id", &gwIDs).Error
+		return gwIDs, err
+	}
+
+	doorIDs := tx.Model(&Scheduler{}).Select("door_id").Where("person_id = ?", personID)
+	err = tx.Model(&Doorlock{}).Distinct("gateway_id").
+		Where("id IN (?) AND gateway_id IS NOT NULL AND gateway_id <> ''", doorIDs).
+		Order("gateway_id").Pluck("gateway_id", &gwIDs).Error
+	return gwIDs, err
+}
@@ -0,0 +1,81 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestRevokeCredential(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ps := NewPersonSvc(db)
+	rs := NewCredentialRevocationSvc(db)
+
+	s, _ := NewStudentSvc(db).CreateStudent(ctx, &Student{MSSV: "s1", Name: "A", Email: "s1@mail", Major: "it",
+		UserPass: UserPass{RfidPass: "card1", KeypadPass: "1234"}})
+	gs, dls := NewGatewaySvc(db), NewDoorlockSvc(db)
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-2"})
+	d1, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d1", GatewayID: "gw-1", DoorlockAddress: "1"})
+	dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d2", GatewayID: "gw-2", DoorlockAddress: "1"})
+	NewSchedulerSvc(db).CreateScheduler(ctx, &Scheduler{DoorID: d1.ID, Role: PERSON_TYPE_STUDENT, UserID: "s1"})
+
+	var card1 *Credential
+	for i := range s.Person.Credentials {
+		if s.Person.Credentials[i].Type == CREDENTIAL_TYPE_RFID {
+			card1 = &s.Person.Credentials[i]
+		}
+	}
+
+	// Only the gateway holding registers of the student has to confirm
+	r, replacement, err := rs.RevokeCredential(ctx, card1.ID, &RevokeCredentialReq{Reason: "lost", ReplacementValue: "card2"}, "admin")
+	if err != nil {
+		t.Fatalf("revoke credential failed: %v", err)
+	}
+	if len(r.Acks) != 1 || r.Acks[0].GatewayID != "gw-1" || r.CompletedAt != nil {
+		t.Fatalf("expected pending ack of gw-1, got %+v", r.Acks)
+	}
+	if r.Value != "card1" || r.UserID != "s1" || r.RevokedBy != "admin" {
+		t.Fatalf("unexpected revocation %+v", r)
+	}
+	p, _ := ps.FindPersonByID(ctx, fmt.Sprint(r.PersonID))
+	if up := p.PrimaryUserPass(replacement.CreatedAt); up.RfidPass != "card2" || up.KeypadPass != "1234" {
+		t.Fatalf("expected replacement card in use, got %+v", up)
+	}
+
+	// Blacklisted card can not be revoked twice, changed or given to anyone
+	if _, _, err := rs.RevokeCredential(ctx, card1.ID, &RevokeCredentialReq{Reason: "lost"}, "admin"); err == nil {
+		t.Fatalf("expected error on revoking blacklisted credential")
+	}
+	if _, err := ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card1.ID}, Status: CREDENTIAL_STATUS_ACTIVE}); err == nil {
+		t.Fatalf("expected error on updating blacklisted credential")
+	}
+	if _, err := ps.CreateCredential(ctx, &Credential{PersonID: r.PersonID, Type: CREDENTIAL_TYPE_RFID, Value: "card1"}); err == nil {
+		t.Fatalf("expected error on reusing blacklisted card")
+	}
+
+	// Gateway without registers of the student is not tracked
+	if ok, _ := rs.AckRevocation(ctx, r.ID, "gw-2"); ok {
+		t.Fatalf("expected ack of untracked gateway ignored")
+	}
+	if pending, _ := rs.FindAllRevocation(ctx, true); len(pending) != 1 {
+		t.Fatalf("expected 1 pending revocation, got %d", len(pending))
+	}
+	if ok, err := rs.AckRevocation(ctx, r.ID, "gw-1"); !ok || err != nil {
+		t.Fatalf("ack revocation failed: %v", err)
+	}
+	r, _ = rs.FindRevocationByID(ctx, fmt.Sprint(r.ID))
+	if r.CompletedAt == nil || r.Acks[0].AckedAt == nil {
+		t.Fatalf("expected revocation completed, got %+v", r)
+	}
+	if pending, _ := rs.FindAllRevocation(ctx, true); len(pending) != 0 {
+		t.Fatalf("expected no pending revocation, got %d", len(pending))
+	}
+	if blacklist, _ := rs.FindBlacklist(ctx); len(blacklist) != 1 {
+		t.Fatalf("expected 1 blacklisted credential, got %d", len(blacklist))
+	}
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Years searched for the next run before a schedule is considered never due, e.g. "0 0 30 2 *"
+const CRON_SEARCH_YEARS int = 5
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var cronDayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// Standard 5 field cron expression "minute hour day-of-month month day-of-week", fields are bit sets
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Cron matches a day by day-of-month or day-of-week when both are restricted
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+// Parse "45 6 * * MON-FRI", lists, ranges, steps, month and day names and @daily like macros are supported
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	cs := &CronSchedule{}
+	var err error
+	if cs.minute, err = parseCronField(fields[0], cronField{"minute", 0, 59, nil}); err != nil {
+		return nil, err
+	}
+	if cs.hour, err = parseCronField(fields[1], cronField{"hour", 0, 23, nil}); err != nil {
+		return nil, err
+	}
+	if cs.dom, err = parseCronField(fields[2], cronField{"day-of-month", 1, 31, nil}); err != nil {
+		return nil, err
+	}
+	if cs.month, err = parseCronField(fields[3], cronField{"month", 1, 12, cronMonthNames}); err != nil {
+		return nil, err
+	}
+	// 7 is Sunday too
+	if cs.dow, err = parseCronField(fields[4], cronField{"day-of-week", 0, 7, cronDayNames}); err != nil {
+		return nil, err
+	}
+	if cs.dow&(1<<7) != 0 {
+		cs.dow |= 1
+	}
+	cs.domStar = strings.HasPrefix(fields[2], "*")
+	cs.dowStar = strings.HasPrefix(fields[4], "*")
+	return cs, nil
+}
+
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step in %q", f.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = cronValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if end, err = cronValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rangePart)
+			}
+		default:
+			var err error
+			if start, err = cronValue(rangePart, f); err != nil {
+				return 0, err
+			}
+			end = start
+			// "5/15" runs from 5 to the end of the field
+			if step > 1 {
+				end = f.max
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, f cronField) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// First run strictly after t in loc, zero time when there is none within CRON_SEARCH_YEARS
+func (cs *CronSchedule) Next(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	yearLimit := t.Year() + CRON_SEARCH_YEARS
+	for t.Year() <= yearLimit {
+		if cs.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if cs.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if cs.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (cs *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domStar || cs.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,66 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"45 6 * * MON-FRI", "30 21 * * 1-5", "*/15 * * * *", "0 0 1,15 * *", "@daily", "0 12 * JAN-MAR 7", "5/20 8-18 * * *"} {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("parse %q failed: %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "0 0 * * FUNDAY"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected error on %q", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	loc, _ := time.LoadLocation(DEFAULT_POLICY_TIME_ZONE)
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr  string
+		after string
+		want  string
+	}{
+		// 2026-10-16 is a Friday
+		{"45 6 * * MON-FRI", "2026-10-16 06:44", "2026-10-16 06:45"},
+		{"45 6 * * MON-FRI", "2026-10-16 06:45", "2026-10-19 06:45"},
+		{"30 21 * * 1-5", "2026-10-17 12:00", "2026-10-19 21:30"},
+		{"*/15 * * * *", "2026-10-16 23:50", "2026-10-17 00:00"},
+		{"0 0 1,15 * *", "2026-10-16 00:00", "2026-11-01 00:00"},
+		// Restricted day-of-month and day-of-week match either
+		{"0 8 13 * FRI", "2026-10-10 00:00", "2026-10-13 08:00"},
+		{"0 8 13 * FRI", "2026-10-13 08:00", "2026-10-16 08:00"},
+		{"@monthly", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		cs, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", tt.expr, err)
+		}
+		if got := cs.Next(at(tt.after), loc); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s: got %s, want %s", tt.expr, tt.after, got.In(loc).Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	cs, _ := ParseCron("0 0 30 2 *")
+	if got := cs.Next(at("2026-01-01 00:00"), loc); !got.IsZero() {
+		t.Errorf("expected no run on February 30, got %s", got)
+	}
+}
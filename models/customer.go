@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -10,18 +11,77 @@ import (
 
 type Customer struct {
 	GormModel
-	CCCD  string `gorm:"type:varchar(256); unique; not null;" json:"cccd"  binding:"required"`
-	Name  string `json:"name"`
-	Phone string `gorm:"type:varchar(50)" json:"phone"`
-	UserPass
+	SoftDelete
+	CCCD       string `gorm:"type:varchar(256); unique; not null;" json:"cccd"  binding:"required"`
+	Name       string `json:"name"`
+	Phone      string `gorm:"type:varchar(50)" json:"phone"`
+	UserPass   `gorm:"-"`
+	PersonID   *uint       `gorm:"index" json:"personId"`
+	Person     *Person     `gorm:"foreignKey:PersonID" json:"person,omitempty"`
 	Schedulers []Scheduler `gorm:"foreignKey:CustomerID;references:CCCD;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"schedulers"`
 }
 
+// Create person identity of a new customer, legacy password fields become its first credentials
+func (c *Customer) BeforeCreate(tx *gorm.DB) error {
+	return linkPerson(tx, &c.PersonID, &c.Person, PERSON_TYPE_CUSTOMER, c.CCCD, c.Name, c.UserPass)
+}
+
+// Load the linked person so the created customer carries its credentials
+func (c *Customer) AfterCreate(tx *gorm.DB) (err error) {
+	c.Person, err = loadPerson(tx, c.PersonID)
+	return err
+}
+
+// Fill legacy password fields from the first usable credentials of the person
+func (c *Customer) AfterFind(tx *gorm.DB) error {
+	c.UserPass = c.Person.PrimaryUserPass(time.Now())
+	return nil
+}
+
+func (c *Customer) personInfo() (string, UserPass) {
+	return c.Name, c.UserPass
+}
+
 // Struct defines HTTP request payload for deleting customer
 type DeleteCustomer struct {
 	CCCD string `json:"cccd" binding:"required"`
 }
 
+// Columns of customer import/export sheet
+var CustomerSheetColumns = []SheetColumn{
+	{Header: "cccd", Field: "CCCD", Required: true},
+	{Header: "name", Field: "Name"},
+	{Header: "phone", Field: "Phone"},
+	{Header: "rfidPass"},
+	{Header: "keypadPass"},
+}
+
+var customerSheet = &userSheet{
+	columns: CustomerSheetColumns,
+	newUser: func() interface{} { return &Customer{} },
+	fromValues: func(v map[string]string) (interface{}, error) {
+		return &Customer{
+			CCCD:     v["cccd"],
+			Name:     v["name"],
+			Phone:    v["phone"],
+			UserPass: UserPass{RfidPass: v["rfidPass"], KeypadPass: v["keypadPass"]},
+		}, nil
+	},
+}
+
+// Customers and their registers
+type CustomerService interface {
+	FindAllCustomer(ctx context.Context) (cList []Customer, err error)
+	FindCustomerByCCCD(ctx context.Context, cccd string) (c *Customer, err error)
+	CreateCustomer(ctx context.Context, c *Customer) (*Customer, error)
+	UpdateCustomer(ctx context.Context, c *Customer) (bool, error)
+	DeleteCustomer(ctx context.Context, cccd string, deletedBy string) (bool, error)
+	RestoreCustomer(ctx context.Context, cccd string) (bool, error)
+	AppendCustomerScheduler(ctx context.Context, c *Customer, usu *UserSchedulerReq, sche *Scheduler) (*Customer, error)
+	AppendCustomerSchedulerExcel(ctx context.Context, sche *Scheduler) (*Customer, error)
+	ImportCustomers(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult
+}
+
 type CustomerSvc struct {
 	db *gorm.DB
 }
@@ -33,7 +93,7 @@ func NewCustomerSvc(db *gorm.DB) *CustomerSvc {
 }
 
 func (cs *CustomerSvc) FindAllCustomer(ctx context.Context) (cList []Customer, err error) {
-	result := cs.db.Preload("Schedulers").Find(&cList)
+	result := conn(ctx, cs.db).Preload("Schedulers").Preload("Person.Credentials").Find(&cList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -43,7 +103,7 @@ func (cs *CustomerSvc) FindAllCustomer(ctx context.Context) (cList []Customer, e
 
 func (cs *CustomerSvc) FindCustomerByCCCD(ctx context.Context, cccd string) (c *Customer, err error) {
 	var cnt int64
-	result := cs.db.Preload("Schedulers").Where("cccd = ?", cccd).Find(&c).Count(&cnt)
+	result := conn(ctx, cs.db).Preload("Schedulers").Preload("Person.Credentials").Where("cccd = ?", cccd).Find(&c).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -57,7 +117,11 @@ func (cs *CustomerSvc) FindCustomerByCCCD(ctx context.Context, cccd string) (c *
 }
 
 func (cs *CustomerSvc) CreateCustomer(ctx context.Context, c *Customer) (*Customer, error) {
-	if err := cs.db.Create(&c).Error; err != nil {
+	db := conn(ctx, cs.db)
+	if err := checkNotDeleted(db, c, "cccd", c.CCCD); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&c).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -65,31 +129,42 @@ func (cs *CustomerSvc) CreateCustomer(ctx context.Context, c *Customer) (*Custom
 }
 
 func (cs *CustomerSvc) UpdateCustomer(ctx context.Context, c *Customer) (bool, error) {
-	result := cs.db.Model(&c).Where("id = ? AND cccd = ?", c.ID, c.CCCD).Updates(c)
-	return utils.ReturnBoolStateFromResult(result)
+	isSuccess := false
+	err := conn(ctx, cs.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result := tx.Model(&c).Omit("Person").Where("id = ? AND cccd = ?", c.ID, c.CCCD).Updates(c)
+		if isSuccess, err = utils.ReturnBoolStateFromResult(result); err != nil {
+			return err
+		}
+		return syncPerson(tx, &Customer{}, c.Name, c.UserPass, "id = ?", c.ID)
+	})
+	return isSuccess, err
+}
+
+func (cs *CustomerSvc) DeleteCustomer(ctx context.Context, cccd string, deletedBy string) (bool, error) {
+	return softDeleteProfile(conn(ctx, cs.db), &Customer{}, deletedBy, "cccd = ?", cccd)
 }
 
-func (cs *CustomerSvc) DeleteCustomer(ctx context.Context, cccd string) (bool, error) {
-	result := cs.db.Unscoped().Where("cccd = ?", cccd).Delete(&Customer{})
-	return utils.ReturnBoolStateFromResult(result)
+func (cs *CustomerSvc) RestoreCustomer(ctx context.Context, cccd string) (bool, error) {
+	return restoreProfile(conn(ctx, cs.db), &Customer{}, "cccd = ?", cccd)
 }
 
 func (cs *CustomerSvc) AppendCustomerScheduler(ctx context.Context, c *Customer, usu *UserSchedulerReq, sche *Scheduler) (*Customer, error) {
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := cs.db.Where("gateway_id = ? AND doorlock_address = ?", usu.GatewayID, usu.DoorlockAddress).First(door)
+	doorResult := conn(ctx, cs.db).Where("gateway_id = ? AND doorlock_address = ?", usu.GatewayID, usu.DoorlockAddress).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := cs.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, cs.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	// Add scheduler for customer
-	if err := cs.db.Model(&c).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, cs.db).Model(&c).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -99,13 +174,13 @@ func (cs *CustomerSvc) AppendCustomerScheduler(ctx context.Context, c *Customer,
 func (cs *CustomerSvc) AppendCustomerSchedulerExcel(ctx context.Context, sche *Scheduler) (*Customer, error) {
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := cs.db.Where("id = ?", sche.DoorID).First(door)
+	doorResult := conn(ctx, cs.db).Where("id = ?", sche.DoorID).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := cs.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, cs.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -116,10 +191,24 @@ func (cs *CustomerSvc) AppendCustomerSchedulerExcel(ctx context.Context, sche *S
 	}
 
 	// Add scheduler for customer
-	if err := cs.db.Model(&userCus).Association("Schedulers").Append(sche); err != nil {
+	if err := conn(ctx, cs.db).Model(&userCus).Association("Schedulers").Append(sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	return userCus, nil
 }
+
+// Create or update customers keyed on CCCD, only fields of the sheet columns are updated
+func (cs *CustomerSvc) ImportCustomers(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult {
+	return importUsers(conn(ctx, cs.db), customerSheet, fields, sheetRows)
+}
+
+// Convert customers to sheet rows in the import format
+func CustomerSheet(cList []Customer) [][]string {
+	rows := [][]string{SheetHeader(CustomerSheetColumns)}
+	for _, cus := range cList {
+		rows = append(rows, []string{cus.CCCD, cus.Name, cus.Phone, cus.RfidPass, cus.KeypadPass})
+	}
+	return rows
+}
@@ -0,0 +1,37 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// Open a fresh in-memory SQLite database with all tables migrated
+func newTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:?_pragma=foreign_keys(1)&_time_format=sqlite"), &gorm.Config{
+		Logger:  gormLogger.Default.LogMode(gormLogger.Silent),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql db failed: %v", err)
+	}
+	sqlDb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDb.Close() })
+
+	if err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Set of doorlocks opened by the same access policies
+type DoorGroup struct {
+	GormModel
+	Name        string     `gorm:"type:varchar(256);unique;not null" json:"name"`
+	Description string     `json:"description"`
+	Doorlocks   []Doorlock `gorm:"many2many:door_group_doorlocks;constraint:OnDelete:CASCADE;" json:"doorlocks"`
+}
+
+// Door groups and their doorlocks
+type DoorGroupService interface {
+	FindAllDoorGroup(ctx context.Context) (dgList []DoorGroup, err error)
+	FindDoorGroupByID(ctx context.Context, id string) (dg *DoorGroup, err error)
+	CreateDoorGroup(ctx context.Context, dg *DoorGroup) (*DoorGroup, error)
+	UpdateDoorGroup(ctx context.Context, dg *DoorGroup) (bool, error)
+	DeleteDoorGroup(ctx context.Context, id uint) (bool, error)
+	AddDoorGroupDoorlocks(ctx context.Context, id uint, doorIDs []uint) (*RegisterDiff, error)
+	RemoveDoorGroupDoorlocks(ctx context.Context, id uint, doorIDs []uint) (*RegisterDiff, error)
+}
+
+type DoorGroupSvc struct {
+	db *gorm.DB
+}
+
+func NewDoorGroupSvc(db *gorm.DB) *DoorGroupSvc {
+	return &DoorGroupSvc{
+		db: db,
+	}
+}
+
+func (dgs *DoorGroupSvc) FindAllDoorGroup(ctx context.Context) (dgList []DoorGroup, err error) {
+	result := conn(ctx, dgs.db).Find(&dgList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dgList, nil
+}
+
+func (dgs *DoorGroupSvc) FindDoorGroupByID(ctx context.Context, id string) (dg *DoorGroup, err error) {
+	result := conn(ctx, dgs.db).Preload("Doorlocks").First(&dg, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dg, nil
+}
+
+func (dgs *DoorGroupSvc) CreateDoorGroup(ctx context.Context, dg *DoorGroup) (*DoorGroup, error) {
+	// Doorlocks are added with AddDoorGroupDoorlocks so their registers are created
+	dg.Doorlocks = nil
+	if err := conn(ctx, dgs.db).Create(dg).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dg, nil
+}
+
+func (dgs *DoorGroupSvc) UpdateDoorGroup(ctx context.Context, dg *DoorGroup) (bool, error) {
+	result := conn(ctx, dgs.db).Model(&DoorGroup{}).Where("id = ?", dg.ID).
+		Select("Name", "Description").Updates(dg)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Door group used by a policy can not be deleted
+func (dgs *DoorGroupSvc) DeleteDoorGroup(ctx context.Context, id uint) (bool, error) {
+	var cnt int64
+	if err := conn(ctx, dgs.db).Model(&AccessPolicy{}).Where("door_group_id = ?", id).Count(&cnt).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	if cnt > 0 {
+		return false, fmt.Errorf("door group is used by %d access policies", cnt)
+	}
+	result := conn(ctx, dgs.db).Where("id = ?", id).Delete(&DoorGroup{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Add doorlocks to door group, registers are created for the new doorlocks only
+func (dgs *DoorGroupSvc) AddDoorGroupDoorlocks(ctx context.Context, id uint, doorIDs []uint) (*RegisterDiff, error) {
+	return dgs.changeDoorlocks(ctx, id, func(tx *gorm.DB, dg *DoorGroup) error {
+		dls := []Doorlock{}
+		if err := tx.Where("id IN ?", doorIDs).Find(&dls).Error; err != nil {
+			return err
+		}
+		if len(dls) != len(doorIDs) {
+			return fmt.Errorf("doorlock not found")
+		}
+		return tx.Model(dg).Omit("Doorlocks.*").Association("Doorlocks").Append(dls)
+	})
+}
+
+// Remove doorlocks from door group, only their registers are deleted
+func (dgs *DoorGroupSvc) RemoveDoorGroupDoorlocks(ctx context.Context, id uint, doorIDs []uint) (*RegisterDiff, error) {
+	return dgs.changeDoorlocks(ctx, id, func(tx *gorm.DB, dg *DoorGroup) error {
+		return tx.Where("door_group_id = ? AND doorlock_id IN ?", dg.ID, doorIDs).
+			Delete(&doorGroupDoorlock{}).Error
+	})
+}
+
+func (dgs *DoorGroupSvc) changeDoorlocks(ctx context.Context, id uint, change func(tx *gorm.DB, dg *DoorGroup) error) (*RegisterDiff, error) {
+	diff := &RegisterDiff{}
+	err := conn(ctx, dgs.db).Transaction(func(tx *gorm.DB) error {
+		dg := &DoorGroup{}
+		if err := tx.First(dg, id).Error; err != nil {
+			return err
+		}
+		if err := change(tx, dg); err != nil {
+			return err
+		}
+		var err error
+		diff, err = syncGroupPolicies(tx, "door_group_id", id)
+		return err
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return diff, nil
+}
+
+// Row of the door group doorlocks join table
+type doorGroupDoorlock struct {
+	DoorGroupID uint
+	DoorlockID  uint
+}
+
+func (doorGroupDoorlock) TableName() string { return "door_group_doorlocks" }
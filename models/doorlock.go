@@ -11,20 +11,24 @@ import (
 
 type Doorlock struct {
 	GormModel
-	DoorSerialID    string      `gorm:"type:varchar(256);unique;not null" json:"doorSerialId"`
-	Location        string      `json:"location"`
-	Description     string      `json:"description"`
-	GatewayID       string      `gorm:"type:varchar(256);" json:"gatewayId"`
-	LastOpenTime    uint        `json:"lastOpenTime"`
-	ConnectState    string      `json:"connectState"`
-	BlockId         string      `json:"blockId"`
-	FloorId         string      `json:"floorId"`
-	RoomId          string      `json:"roomId"`
-	DoorState       string      `json:"doorState"`
-	LockState       string      `json:"lockState"`
-	DoorlockAddress string      `json:"doorlockAddress"`
-	ActiveState     string      `json:"activeState"`
-	Schedulers      []Scheduler `gorm:"foreignKey:DoorID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"schedulers"`
+	SoftDelete
+	DoorSerialID    string `gorm:"type:varchar(256);unique;not null" json:"doorSerialId"`
+	Location        string `json:"location"`
+	Description     string `json:"description"`
+	GatewayID       string `gorm:"type:varchar(256);" json:"gatewayId"`
+	LastOpenTime    uint   `json:"lastOpenTime"`
+	ConnectState    string `json:"connectState"`
+	BlockId         string `json:"blockId"`
+	FloorId         string `json:"floorId"`
+	RoomId          string `json:"roomId"`
+	DoorState       string `json:"doorState"`
+	LockState       string `json:"lockState"`
+	DoorlockAddress string `json:"doorlockAddress"`
+	ActiveState     string `json:"activeState"`
+	ReaderDirection string `gorm:"type:varchar(16)" json:"readerDirection"` // entry or exit reader of its room
+	// Remote unlock commands wait for a second operator, set with SetDoorlockRequiresApproval only
+	RequiresApproval bool        `gorm:"not null;default:false" json:"requiresApproval"`
+	Schedulers       []Scheduler `gorm:"foreignKey:DoorID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"schedulers"`
 }
 
 // Struct defines HTTP request payload for openning doorlock
@@ -32,6 +36,7 @@ type DoorlockCmd struct {
 	ID       string `json:"id"`
 	State    string `json:"state"`
 	Duration string `json:"duration"`
+	Reason   string `json:"reason"` // kept on the unlock request of doorlocks requiring approval
 }
 
 // Struct defines HTTP request payload for deleting doorlock
@@ -49,6 +54,29 @@ type DoorlockStatus struct {
 	LockState       string `json:"lockState"`
 }
 
+// Doorlocks and their state
+type DoorlockService interface {
+	FindAllDoorlock(ctx context.Context) (dlList []Doorlock, err error)
+	FindDoorlockByID(ctx context.Context, id string) (dl *Doorlock, err error)
+	FindDoorlockByAddress(ctx context.Context, address string, gwID string) (dl *Doorlock, err error)
+	CreateDoorlock(ctx context.Context, dl *Doorlock) (*Doorlock, error)
+	UpdateDoorlock(ctx context.Context, dl *Doorlock) (bool, error)
+	SetDoorlockRequiresApproval(ctx context.Context, id uint, requiresApproval bool) (bool, error)
+	UpdateDoorlockByAddress(ctx context.Context, dl *Doorlock) (bool, error)
+	UpdateDoorlockState(ctx context.Context, dl *DoorlockCmd) (bool, error)
+	DeleteDoorlock(ctx context.Context, id string, deletedBy string) (bool, error)
+	DeleteDoorlockByAddress(ctx context.Context, dl *Doorlock, deletedBy string) (bool, error)
+	RestoreDoorlock(ctx context.Context, id string) (bool, error)
+	RestoreDoorlockByAddress(ctx context.Context, address string, gwID string) (bool, error)
+	UpdateDoorlockStatus(ctx context.Context, dl *DoorlockStatus) (bool, error)
+	UpdateDoorState(ctx context.Context, dl *DoorlockStatus) (bool, error)
+	UpdateLockState(ctx context.Context, dl *DoorlockStatus) (bool, error)
+	GetDoorlockStatusByID(ctx context.Context, id string) (dl *DoorlockStatus, err error)
+	UpdateDoorlockStateCmd(ctx context.Context, dl *DoorlockCmd) (bool, error)
+	FindAllDoorlocksByRoomID(ctx context.Context, roomId string) (dl []*Doorlock, err error)
+	FindAllDoorlockByGatewayID(ctx context.Context, gwId string) (dlList []Doorlock, err error)
+}
+
 type DoorlockSvc struct {
 	db *gorm.DB
 }
@@ -60,7 +88,7 @@ func NewDoorlockSvc(db *gorm.DB) *DoorlockSvc {
 }
 
 func (dls *DoorlockSvc) FindAllDoorlock(ctx context.Context) (dlList []Doorlock, err error) {
-	result := dls.db.Preload("Schedulers").Find(&dlList)
+	result := conn(ctx, dls.db).Preload("Schedulers").Find(&dlList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -69,7 +97,7 @@ func (dls *DoorlockSvc) FindAllDoorlock(ctx context.Context) (dlList []Doorlock,
 }
 
 func (dls *DoorlockSvc) FindDoorlockByID(ctx context.Context, id string) (dl *Doorlock, err error) {
-	result := dls.db.Preload("Schedulers").First(&dl, id)
+	result := conn(ctx, dls.db).Preload("Schedulers").First(&dl, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -79,7 +107,7 @@ func (dls *DoorlockSvc) FindDoorlockByID(ctx context.Context, id string) (dl *Do
 
 func (dls *DoorlockSvc) FindDoorlockByAddress(ctx context.Context, address string, gwID string) (dl *Doorlock, err error) {
 	var cnt int64
-	result := dls.db.Preload("Schedulers").Where("doorlock_address = ? AND gateway_id = ?", address, gwID).Find(&dl).Count(&cnt)
+	result := conn(ctx, dls.db).Preload("Schedulers").Where("doorlock_address = ? AND gateway_id = ?", address, gwID).Find(&dl).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -93,7 +121,7 @@ func (dls *DoorlockSvc) FindDoorlockByAddress(ctx context.Context, address strin
 }
 
 func (dls *DoorlockSvc) CreateDoorlock(ctx context.Context, dl *Doorlock) (*Doorlock, error) {
-	if err := dls.db.Create(&dl).Error; err != nil {
+	if err := conn(ctx, dls.db).Create(&dl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -101,48 +129,71 @@ func (dls *DoorlockSvc) CreateDoorlock(ctx context.Context, dl *Doorlock) (*Door
 }
 
 func (dls *DoorlockSvc) UpdateDoorlock(ctx context.Context, dl *Doorlock) (bool, error) {
-	result := dls.db.Model(&dl).Where("doorlock_address = ? AND gateway_id = ?", dl.DoorlockAddress, dl.GatewayID).Updates(dl)
+	if dl.ReaderDirection != "" && dl.ReaderDirection != READER_DIRECTION_ENTRY && dl.ReaderDirection != READER_DIRECTION_EXIT {
+		return false, fmt.Errorf("readerDirection must be %s or %s", READER_DIRECTION_ENTRY, READER_DIRECTION_EXIT)
+	}
+	result := conn(ctx, dls.db).Model(&dl).Where("doorlock_address = ? AND gateway_id = ?", dl.DoorlockAddress, dl.GatewayID).Updates(dl)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (dls *DoorlockSvc) SetDoorlockRequiresApproval(ctx context.Context, id uint, requiresApproval bool) (bool, error) {
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("id = ?", id).Update("requires_approval", requiresApproval)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) UpdateDoorlockByAddress(ctx context.Context, dl *Doorlock) (bool, error) {
-	result := dls.db.Model(&dl).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Updates(dl)
+	result := conn(ctx, dls.db).Model(&dl).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Updates(dl)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) UpdateDoorlockState(ctx context.Context, dl *DoorlockCmd) (bool, error) {
-	result := dls.db.Model(&Doorlock{}).Where("id = ?", dl.ID).Update("last_open_time", time.Now().UnixMilli())
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("id = ?", dl.ID).Update("last_open_time", time.Now().UnixMilli())
 	return utils.ReturnBoolStateFromResult(result)
 }
 
-func (dls *DoorlockSvc) DeleteDoorlock(ctx context.Context, id string) (bool, error) {
-	result := dls.db.Unscoped().Where("id = ?", id).Delete(&Doorlock{})
+func (dls *DoorlockSvc) DeleteDoorlock(ctx context.Context, id string, deletedBy string) (bool, error) {
+	result := softDelete(conn(ctx, dls.db), &Doorlock{}, deletedBy, "id = ?", id)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
-func (dls *DoorlockSvc) DeleteDoorlockByAddress(ctx context.Context, dl *Doorlock) (bool, error) {
-	result := dls.db.Unscoped().Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Delete(&Doorlock{})
+func (dls *DoorlockSvc) DeleteDoorlockByAddress(ctx context.Context, dl *Doorlock, deletedBy string) (bool, error) {
+	result := softDelete(conn(ctx, dls.db), &Doorlock{}, deletedBy, "gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
+func (dls *DoorlockSvc) RestoreDoorlock(ctx context.Context, id string) (bool, error) {
+	result := restoreDeleted(conn(ctx, dls.db), &Doorlock{}, "id = ?", id)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Restore doorlock reported again by its gateway, return false when none was deleted
+func (dls *DoorlockSvc) RestoreDoorlockByAddress(ctx context.Context, address string, gwID string) (bool, error) {
+	result := restoreDeleted(conn(ctx, dls.db), &Doorlock{}, "doorlock_address = ? AND gateway_id = ?", address, gwID)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return false, err
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (dls *DoorlockSvc) UpdateDoorlockStatus(ctx context.Context, dl *DoorlockStatus) (bool, error) {
-	result := dls.db.Model(&Doorlock{}).Where("id = ?", dl.ID).Updates(Doorlock{DoorState: dl.DoorState, LockState: dl.LockState})
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("id = ?", dl.ID).Updates(Doorlock{DoorState: dl.DoorState, LockState: dl.LockState})
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) UpdateDoorState(ctx context.Context, dl *DoorlockStatus) (bool, error) {
-	result := dls.db.Model(&Doorlock{}).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Update("door_state", dl.DoorState)
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Update("door_state", dl.DoorState)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) UpdateLockState(ctx context.Context, dl *DoorlockStatus) (bool, error) {
-	result := dls.db.Model(&Doorlock{}).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Update("lock_state", dl.LockState)
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("gateway_id = ? AND doorlock_address = ?", dl.GatewayID, dl.DoorlockAddress).Update("lock_state", dl.LockState)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) GetDoorlockStatusByID(ctx context.Context, id string) (dl *DoorlockStatus, err error) {
 	var cnt int64
-	result := dls.db.Model(&Doorlock{}).Where("id = ?", id).Find(&dl).Count(&cnt)
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("id = ?", id).Find(&dl).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -156,13 +207,13 @@ func (dls *DoorlockSvc) GetDoorlockStatusByID(ctx context.Context, id string) (d
 }
 
 func (dls *DoorlockSvc) UpdateDoorlockStateCmd(ctx context.Context, dl *DoorlockCmd) (bool, error) {
-	result := dls.db.Model(&Doorlock{}).Where("id = ?", dl.ID).Update("lock_state", dl.State)
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("id = ?", dl.ID).Update("lock_state", dl.State)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (dls *DoorlockSvc) FindAllDoorlocksByRoomID(ctx context.Context, roomId string) (dl []*Doorlock, err error) {
 	var cnt int64
-	result := dls.db.Model(&Doorlock{}).Where("room_id = ?", roomId).Find(&dl).Count(&cnt)
+	result := conn(ctx, dls.db).Model(&Doorlock{}).Where("room_id = ?", roomId).Find(&dl).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -176,7 +227,7 @@ func (dls *DoorlockSvc) FindAllDoorlocksByRoomID(ctx context.Context, roomId str
 }
 
 func (dls *DoorlockSvc) FindAllDoorlockByGatewayID(ctx context.Context, gwId string) (dlList []Doorlock, err error) {
-	result := dls.db.Preload("Schedulers").Where("gateway_id = ?", gwId).Find(&dlList)
+	result := conn(ctx, dls.db).Preload("Schedulers").Where("gateway_id = ?", gwId).Find(&dlList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
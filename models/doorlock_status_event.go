@@ -0,0 +1,387 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+)
+
+const (
+	STATUS_EVENT_CONNECT string = "connect" // Active is connected
+	STATUS_EVENT_DOOR    string = "door"    // Active is open
+	STATUS_EVENT_LOCK    string = "lock"    // Active is unlocked
+
+	STATS_BUCKET_HOUR string = "hour"
+	STATS_BUCKET_DAY  string = "day"
+	STATS_BUCKET_WEEK string = "week"
+
+	DEFAULT_STATUS_EVENT_LIMIT int = 100
+	MAX_STATUS_EVENT_LIMIT     int = 1000
+
+	// Buckets are widened until the series fits
+	MAX_STATS_POINTS     int           = 400
+	DEFAULT_STATS_PERIOD time.Duration = 7 * 24 * time.Hour
+)
+
+// Ordered finest first for downsampling
+var statsBuckets = []struct {
+	name string
+	size time.Duration
+}{
+	{STATS_BUCKET_HOUR, time.Hour},
+	{STATS_BUCKET_DAY, 24 * time.Hour},
+	{STATS_BUCKET_WEEK, 7 * 24 * time.Hour},
+}
+
+// Doorlock status change reported by its gateway, Value is kept as reported
+type DoorlockStatusEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DoorID    uint      `gorm:"not null;index:idx_doorlock_status_events_door_time,priority:1" json:"doorId"`
+	RoomID    string    `gorm:"type:varchar(256);index" json:"roomId"`
+	EventType string    `gorm:"type:varchar(16);not null" json:"eventType"`
+	Value     string    `gorm:"type:varchar(50)" json:"value"`
+	Active    bool      `gorm:"not null" json:"active"` // connected, open or unlocked
+	EventTime time.Time `gorm:"not null;index;index:idx_doorlock_status_events_door_time,priority:2" json:"eventTime"`
+}
+
+// Query params of the statistics endpoints, from and to are unix seconds
+type DoorlockStatsQuery struct {
+	From     int64  `form:"from"`
+	To       int64  `form:"to"`
+	Bucket   string `form:"bucket"`
+	TimeZone string `form:"timeZone"`
+	Limit    int    `form:"limit"`
+}
+
+type DoorlockStatsPoint struct {
+	Start               time.Time `json:"start"`
+	OpenCount           int       `json:"openCount"`
+	OpenSeconds         float64   `json:"openSeconds"`
+	DisconnectedSeconds float64   `json:"disconnectedSeconds"`
+}
+
+type DoorlockStats struct {
+	DoorID              uint                 `json:"doorId"`
+	From                time.Time            `json:"from"`
+	To                  time.Time            `json:"to"`
+	Bucket              string               `json:"bucket"`
+	TimeZone            string               `json:"timeZone"`
+	OpenCount           int                  `json:"openCount"`
+	MeanOpenSeconds     float64              `json:"meanOpenSeconds"`
+	DisconnectedSeconds float64              `json:"disconnectedSeconds"`
+	Series              []DoorlockStatsPoint `json:"series,omitempty"`
+}
+
+type HourlyOpenCount struct {
+	Hour      int `json:"hour"`
+	OpenCount int `json:"openCount"`
+}
+
+type RoomBusiestHours struct {
+	RoomID   string            `json:"roomId"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	TimeZone string            `json:"timeZone"`
+	Hours    []HourlyOpenCount `json:"hours"` // busiest first
+}
+
+type statusInterval struct {
+	start, end time.Time
+	// Began inside the range, not carried over from the state before it
+	began bool
+}
+
+// Map gateway reported value to the typed state
+func IsStatusEventActive(eventType string, value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch eventType {
+	case STATUS_EVENT_CONNECT:
+		return v != "disconnected" && v != "disconnect" && v != "offline" && v != "false" && v != "0"
+	case STATUS_EVENT_DOOR:
+		return v == "open" || v == "opened" || v == "true" || v == "1"
+	case STATUS_EVENT_LOCK:
+		return v == "unlock" || v == "unlocked" || v == "open"
+	}
+	return false
+}
+
+func (dlsls *DoorlockStatusLogSvc) CreateDoorlockStatusEvent(ctx context.Context, dlse *DoorlockStatusEvent) (*DoorlockStatusEvent, error) {
+	if dlse.EventTime.IsZero() {
+		dlse.EventTime = time.Now()
+	}
+	dlse.EventTime = dlse.EventTime.UTC()
+	dlse.Active = IsStatusEventActive(dlse.EventType, dlse.Value)
+	if err := conn(ctx, dlsls.db).Create(dlse).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlse, nil
+}
+
+// Find events of doorlock in range oldest first, limit is capped at MAX_STATUS_EVENT_LIMIT
+func (dlsls *DoorlockStatusLogSvc) FindDoorlockStatusEvents(ctx context.Context, doorID uint, q *DoorlockStatsQuery) (dlseList []DoorlockStatusEvent, err error) {
+	from, to, _, err := statsRange(q)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DEFAULT_STATUS_EVENT_LIMIT
+	}
+	if limit > MAX_STATUS_EVENT_LIMIT {
+		limit = MAX_STATUS_EVENT_LIMIT
+	}
+	result := conn(ctx, dlsls.db).Where("door_id = ? AND event_time >= ? AND event_time < ?", doorID, from, to).
+		Order("event_time, id").Limit(limit).Find(&dlseList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlseList, nil
+}
+
+// Open count, open duration and disconnected time of doorlock per bucket, the bucket is widened for long ranges
+func (dlsls *DoorlockStatusLogSvc) FindDoorlockStats(ctx context.Context, doorID uint, q *DoorlockStatsQuery) (*DoorlockStats, error) {
+	from, to, loc, err := statsRange(q)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := statsBucket(q.Bucket, from, to)
+	if err != nil {
+		return nil, err
+	}
+	eventMap, err := dlsls.findStatusEvents(ctx, from, to, doorID)
+	if err != nil {
+		return nil, err
+	}
+	ds := newDoorlockStats(doorID, from, to, loc, eventMap[doorID])
+	ds.Bucket = bucket
+	ds.Series = statsSeries(from, to, loc, bucket, eventMap[doorID])
+	return ds, nil
+}
+
+// Totals of every doorlock with events, most disconnected first to spot failing locks
+func (dlsls *DoorlockStatusLogSvc) FindAllDoorlockStats(ctx context.Context, q *DoorlockStatsQuery) ([]DoorlockStats, error) {
+	from, to, loc, err := statsRange(q)
+	if err != nil {
+		return nil, err
+	}
+	eventMap, err := dlsls.findStatusEvents(ctx, from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+	dsList := []DoorlockStats{}
+	for doorID, events := range eventMap {
+		dsList = append(dsList, *newDoorlockStats(doorID, from, to, loc, events))
+	}
+	sort.Slice(dsList, func(i, j int) bool {
+		if dsList[i].DisconnectedSeconds != dsList[j].DisconnectedSeconds {
+			return dsList[i].DisconnectedSeconds > dsList[j].DisconnectedSeconds
+		}
+		return dsList[i].DoorID < dsList[j].DoorID
+	})
+	return dsList, nil
+}
+
+// Door openings of the room per hour of day in the time zone, busiest first
+func (dlsls *DoorlockStatusLogSvc) FindRoomBusiestHours(ctx context.Context, roomID string, q *DoorlockStatsQuery) (*RoomBusiestHours, error) {
+	from, to, loc, err := statsRange(q)
+	if err != nil {
+		return nil, err
+	}
+	var openTimes []time.Time
+	result := conn(ctx, dlsls.db).Model(&DoorlockStatusEvent{}).
+		Where("room_id = ? AND event_type = ? AND active = ? AND event_time >= ? AND event_time < ?",
+			roomID, STATUS_EVENT_DOOR, true, from, to).
+		Pluck("event_time", &openTimes)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+
+	rbh := &RoomBusiestHours{RoomID: roomID, From: from, To: to, TimeZone: loc.String()}
+	for hour := 0; hour < 24; hour++ {
+		rbh.Hours = append(rbh.Hours, HourlyOpenCount{Hour: hour})
+	}
+	for _, t := range openTimes {
+		rbh.Hours[t.In(loc).Hour()].OpenCount++
+	}
+	sort.SliceStable(rbh.Hours, func(i, j int) bool { return rbh.Hours[i].OpenCount > rbh.Hours[j].OpenCount })
+	return rbh, nil
+}
+
+// Events in range by doorlock, each led by the last event of every type before the range
+func (dlsls *DoorlockStatusLogSvc) findStatusEvents(ctx context.Context, from time.Time, to time.Time, doorID uint) (map[uint][]DoorlockStatusEvent, error) {
+	db := conn(ctx, dlsls.db)
+	latest := db.Model(&DoorlockStatusEvent{}).Select("MAX(id)").Where("event_time < ?", from).Group("door_id, event_type")
+	query := db.Where("(event_time >= ? AND event_time < ?) OR id IN (?)", from, to, latest)
+	if doorID != 0 {
+		query = db.Where("door_id = ?", doorID).Where(query)
+	}
+	var events []DoorlockStatusEvent
+	if err := query.Order("event_time, id").Find(&events).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	eventMap := map[uint][]DoorlockStatusEvent{}
+	for _, e := range events {
+		eventMap[e.DoorID] = append(eventMap[e.DoorID], e)
+	}
+	return eventMap, nil
+}
+
+func newDoorlockStats(doorID uint, from time.Time, to time.Time, loc *time.Location, events []DoorlockStatusEvent) *DoorlockStats {
+	ds := &DoorlockStats{DoorID: doorID, From: from, To: to, TimeZone: loc.String()}
+	var openSeconds float64
+	for _, in := range statusIntervals(events, STATUS_EVENT_DOOR, true, from, to) {
+		if in.began {
+			ds.OpenCount++
+			openSeconds += in.end.Sub(in.start).Seconds()
+		}
+	}
+	if ds.OpenCount > 0 {
+		ds.MeanOpenSeconds = openSeconds / float64(ds.OpenCount)
+	}
+	for _, in := range statusIntervals(events, STATUS_EVENT_CONNECT, false, from, to) {
+		ds.DisconnectedSeconds += in.end.Sub(in.start).Seconds()
+	}
+	return ds
+}
+
+func statsSeries(from time.Time, to time.Time, loc *time.Location, bucket string, events []DoorlockStatusEvent) []DoorlockStatsPoint {
+	series := []DoorlockStatsPoint{}
+	for start := bucketStart(from, bucket, loc); start.Before(to); start = nextBucket(start, bucket) {
+		series = append(series, DoorlockStatsPoint{Start: start})
+	}
+	// Bucket of t, series is short enough for a linear search
+	pointAt := func(t time.Time) int {
+		for i := len(series) - 1; i > 0; i-- {
+			if !t.Before(series[i].Start) {
+				return i
+			}
+		}
+		return 0
+	}
+	// Seconds of the interval in each bucket
+	spread := func(in statusInterval, add func(p *DoorlockStatsPoint, seconds float64)) {
+		for i := pointAt(in.start); i < len(series) && series[i].Start.Before(in.end); i++ {
+			start, end := series[i].Start, nextBucket(series[i].Start, bucket)
+			if in.start.After(start) {
+				start = in.start
+			}
+			if in.end.Before(end) {
+				end = in.end
+			}
+			add(&series[i], end.Sub(start).Seconds())
+		}
+	}
+
+	for _, in := range statusIntervals(events, STATUS_EVENT_DOOR, true, from, to) {
+		if in.began {
+			series[pointAt(in.start)].OpenCount++
+		}
+		spread(in, func(p *DoorlockStatsPoint, seconds float64) { p.OpenSeconds += seconds })
+	}
+	for _, in := range statusIntervals(events, STATUS_EVENT_CONNECT, false, from, to) {
+		spread(in, func(p *DoorlockStatsPoint, seconds float64) { p.DisconnectedSeconds += seconds })
+	}
+	return series
+}
+
+// Periods within [from, to) where the state of eventType is active, ongoing periods end at to or now
+func statusIntervals(events []DoorlockStatusEvent, eventType string, active bool, from time.Time, to time.Time) []statusInterval {
+	if now := time.Now(); now.Before(to) {
+		to = now
+	}
+	var inList []statusInterval
+	var current *statusInterval
+	for _, e := range events {
+		if e.EventType != eventType {
+			continue
+		}
+		at, began := e.EventTime, true
+		if at.Before(from) {
+			at, began = from, false
+		}
+		if e.Active == active && current == nil {
+			current = &statusInterval{start: at, began: began}
+		} else if e.Active != active && current != nil {
+			current.end = at
+			inList = append(inList, *current)
+			current = nil
+		}
+	}
+	if current != nil && current.start.Before(to) {
+		current.end = to
+		inList = append(inList, *current)
+	}
+	return inList
+}
+
+// Default range is the last DEFAULT_STATS_PERIOD, default time zone is the access policy one
+func statsRange(q *DoorlockStatsQuery) (time.Time, time.Time, *time.Location, error) {
+	to := time.Now().UTC()
+	if q.To > 0 {
+		to = time.Unix(q.To, 0).UTC()
+	}
+	from := to.Add(-DEFAULT_STATS_PERIOD)
+	if q.From > 0 {
+		from = time.Unix(q.From, 0).UTC()
+	}
+	if !from.Before(to) {
+		return from, to, nil, fmt.Errorf("from must be before to")
+	}
+	tz := q.TimeZone
+	if tz == "" {
+		tz = DEFAULT_POLICY_TIME_ZONE
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return from, to, nil, fmt.Errorf("unknown timeZone %s", tz)
+	}
+	return from, to, loc, nil
+}
+
+// Requested bucket (day by default) widened until the range fits MAX_STATS_POINTS
+func statsBucket(requested string, from time.Time, to time.Time) (string, error) {
+	if requested == "" {
+		requested = STATS_BUCKET_DAY
+	}
+	idx := -1
+	for i, b := range statsBuckets {
+		if b.name == requested {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return "", fmt.Errorf("bucket must be hour, day or week")
+	}
+	for idx < len(statsBuckets)-1 && to.Sub(from)/statsBuckets[idx].size > time.Duration(MAX_STATS_POINTS) {
+		idx++
+	}
+	return statsBuckets[idx].name, nil
+}
+
+func bucketStart(t time.Time, bucket string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch bucket {
+	case STATS_BUCKET_HOUR:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case STATS_BUCKET_WEEK:
+		return startOfWeek(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc))
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case STATS_BUCKET_HOUR:
+		return start.Add(time.Hour)
+	case STATS_BUCKET_WEEK:
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
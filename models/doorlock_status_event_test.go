@@ -0,0 +1,72 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDoorlockStats(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	dlsls := NewDoorlockStatusLogSvc(db)
+	loc, _ := time.LoadLocation(DEFAULT_POLICY_TIME_ZONE)
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, loc)
+
+	for _, e := range []DoorlockStatusEvent{
+		{DoorID: 1, EventType: STATUS_EVENT_CONNECT, Value: "disconnected", EventTime: day.Add(-time.Hour)},
+		{DoorID: 1, EventType: STATUS_EVENT_CONNECT, Value: "connected", EventTime: day.Add(time.Hour)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "open", EventTime: day.Add(8 * time.Hour)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "close", EventTime: day.Add(8*time.Hour + 10*time.Minute)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "open", EventTime: day.Add(9 * time.Hour)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "close", EventTime: day.Add(9*time.Hour + 20*time.Minute)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "open", EventTime: day.Add(32*time.Hour + 30*time.Minute)},
+		{DoorID: 1, RoomID: "r1", EventType: STATUS_EVENT_DOOR, Value: "close", EventTime: day.Add(32*time.Hour + 35*time.Minute)},
+		{DoorID: 2, RoomID: "r2", EventType: STATUS_EVENT_DOOR, Value: "open", EventTime: day.Add(10 * time.Hour)},
+	} {
+		e := e
+		if _, err := dlsls.CreateDoorlockStatusEvent(ctx, &e); err != nil {
+			t.Fatalf("create doorlock status event failed: %v", err)
+		}
+	}
+
+	q := &DoorlockStatsQuery{From: day.Unix(), To: day.AddDate(0, 0, 2).Unix()}
+	ds, err := dlsls.FindDoorlockStats(ctx, 1, q)
+	if err != nil {
+		t.Fatalf("find doorlock stats failed: %v", err)
+	}
+	if ds.OpenCount != 3 || ds.MeanOpenSeconds != 700 || ds.DisconnectedSeconds != 3600 || ds.Bucket != STATS_BUCKET_DAY {
+		t.Fatalf("expected 3 openings of 700s and 1h disconnected, got %+v", ds)
+	}
+	if len(ds.Series) != 2 || !ds.Series[0].Start.Equal(day) || ds.Series[0].OpenCount != 2 || ds.Series[0].OpenSeconds != 1800 ||
+		ds.Series[0].DisconnectedSeconds != 3600 || ds.Series[1].OpenCount != 1 || ds.Series[1].OpenSeconds != 300 {
+		t.Fatalf("expected 2 daily points, got %+v", ds.Series)
+	}
+
+	// Hourly buckets are widened for long ranges
+	ds, _ = dlsls.FindDoorlockStats(ctx, 1, &DoorlockStatsQuery{From: day.Unix(), To: day.AddDate(0, 0, 30).Unix(), Bucket: STATS_BUCKET_HOUR})
+	if ds.Bucket != STATS_BUCKET_DAY || len(ds.Series) != 30 {
+		t.Fatalf("expected 30 daily points, got %s with %d points", ds.Bucket, len(ds.Series))
+	}
+	if _, err := dlsls.FindDoorlockStats(ctx, 1, &DoorlockStatsQuery{Bucket: "minute"}); err == nil {
+		t.Fatalf("expected error on unknown bucket")
+	}
+
+	dsList, _ := dlsls.FindAllDoorlockStats(ctx, q)
+	if len(dsList) != 2 || dsList[0].DoorID != 1 || dsList[1].OpenCount != 1 || dsList[1].Series != nil {
+		t.Fatalf("expected most disconnected doorlock first, got %+v", dsList)
+	}
+
+	rbh, err := dlsls.FindRoomBusiestHours(ctx, "r1", q)
+	if err != nil || len(rbh.Hours) != 24 || rbh.Hours[0].Hour != 8 || rbh.Hours[0].OpenCount != 2 ||
+		rbh.Hours[1].Hour != 9 || rbh.Hours[1].OpenCount != 1 {
+		t.Fatalf("expected 8h then 9h busiest, got %+v, %v", rbh, err)
+	}
+
+	if dlseList, _ := dlsls.FindDoorlockStatusEvents(ctx, 1, q); len(dlseList) != 7 || !dlseList[0].Active {
+		t.Fatalf("expected 7 events of doorlock 1 in range, got %+v", dlseList)
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -19,6 +20,22 @@ type DoorlockStatusLog struct {
 	StateType  string `json:"statusType"` // ConnectState, DoorState, LockState
 	StateValue string `json:"stateValue"` // corresponding statetype
 }
+
+// Doorlock status logs and events
+type DoorlockStatusLogService interface {
+	GetAllDoorlockStatusLogs(ctx context.Context) (dlslList []DoorlockStatusLog, err error)
+	GetDoorlockStatusLogByDoorID(ctx context.Context, doorId string) (dlslList []DoorlockStatusLog, err error)
+	CreateDoorlockStatusLog(ctx context.Context, dlsl *DoorlockStatusLog) (*DoorlockStatusLog, error)
+	GetDoorlockStatusLogInTimeRange(ctx context.Context, from time.Time, to time.Time) (dlslList *[]DoorlockStatusLog, err error)
+	DeleteDoorlockStatusLogInTimeRange(ctx context.Context, from time.Time, to time.Time) (bool, error)
+	DeleteDoorlockStatusLogByDoorID(ctx context.Context, doorId string) (bool, error)
+	CreateDoorlockStatusEvent(ctx context.Context, dlse *DoorlockStatusEvent) (*DoorlockStatusEvent, error)
+	FindDoorlockStatusEvents(ctx context.Context, doorID uint, q *DoorlockStatsQuery) (dlseList []DoorlockStatusEvent, err error)
+	FindDoorlockStats(ctx context.Context, doorID uint, q *DoorlockStatsQuery) (*DoorlockStats, error)
+	FindAllDoorlockStats(ctx context.Context, q *DoorlockStatsQuery) ([]DoorlockStats, error)
+	FindRoomBusiestHours(ctx context.Context, roomID string, q *DoorlockStatsQuery) (*RoomBusiestHours, error)
+}
+
 type DoorlockStatusLogSvc struct {
 	db *gorm.DB
 }
@@ -31,7 +48,7 @@ func NewDoorlockStatusLogSvc(db *gorm.DB) *DoorlockStatusLogSvc {
 }
 
 func (dlsls *DoorlockStatusLogSvc) GetAllDoorlockStatusLogs(ctx context.Context) (dlslList []DoorlockStatusLog, err error) {
-	result := dlsls.db.Find(&dlslList)
+	result := conn(ctx, dlsls.db).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -40,7 +57,7 @@ func (dlsls *DoorlockStatusLogSvc) GetAllDoorlockStatusLogs(ctx context.Context)
 }
 
 func (dlsls *DoorlockStatusLogSvc) GetDoorlockStatusLogByDoorID(ctx context.Context, doorId string) (dlslList []DoorlockStatusLog, err error) {
-	result := dlsls.db.Where("door_id = ?", doorId).Find(&dlslList)
+	result := conn(ctx, dlsls.db).Where("door_id = ?", doorId).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -49,15 +66,15 @@ func (dlsls *DoorlockStatusLogSvc) GetDoorlockStatusLogByDoorID(ctx context.Cont
 }
 
 func (dlsls *DoorlockStatusLogSvc) CreateDoorlockStatusLog(ctx context.Context, dlsl *DoorlockStatusLog) (*DoorlockStatusLog, error) {
-	if err := dlsls.db.Create(&dlsl).Error; err != nil {
+	if err := conn(ctx, dlsls.db).Create(&dlsl).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return dlsl, nil
 }
 
-func (dlsls *DoorlockStatusLogSvc) GetDoorlockStatusLogInTimeRange(from string, to string) (dlslList *[]DoorlockStatusLog, err error) {
-	result := dlsls.db.Where("created_at >= ? AND created_at <= ?", from, to).Find(&dlslList)
+func (dlsls *DoorlockStatusLogSvc) GetDoorlockStatusLogInTimeRange(ctx context.Context, from time.Time, to time.Time) (dlslList *[]DoorlockStatusLog, err error) {
+	result := conn(ctx, dlsls.db).Where("created_at >= ? AND created_at <= ?", from.UTC(), to.UTC()).Find(&dlslList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -65,12 +82,12 @@ func (dlsls *DoorlockStatusLogSvc) GetDoorlockStatusLogInTimeRange(from string,
 	return dlslList, nil
 }
 
-func (dlsls *DoorlockStatusLogSvc) DeleteDoorlockStatusLogInTimeRange(from string, to string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("created_at >= ? AND created_at <= ?", from, to).Delete(&DoorlockStatusLog{})
+func (dlsls *DoorlockStatusLogSvc) DeleteDoorlockStatusLogInTimeRange(ctx context.Context, from time.Time, to time.Time) (bool, error) {
+	result := conn(ctx, dlsls.db).Unscoped().Where("created_at >= ? AND created_at <= ?", from.UTC(), to.UTC()).Delete(&DoorlockStatusLog{})
 	return utils.ReturnBoolStateFromResult(result)
 }
 
-func (dlsls *DoorlockStatusLogSvc) DeleteDoorlockStatusLogByDoorID(doorId string) (bool, error) {
-	result := dlsls.db.Unscoped().Where("door_id = ?", doorId).Delete(&DoorlockStatusLog{})
+func (dlsls *DoorlockStatusLogSvc) DeleteDoorlockStatusLogByDoorID(ctx context.Context, doorId string) (bool, error) {
+	result := conn(ctx, dlsls.db).Unscoped().Where("door_id = ?", doorId).Delete(&DoorlockStatusLog{})
 	return utils.ReturnBoolStateFromResult(result)
 }
@@ -0,0 +1,42 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDoorlockStatusLogInTimeRange(t *testing.T) {
+	db := newTestDb(t)
+	dlsls := NewDoorlockStatusLogSvc(db)
+	now := time.Now()
+
+	for i, value := range []string{"open", "close", "open"} {
+		dlsl := &DoorlockStatusLog{DoorID: "1", StateType: "doorState", StateValue: value}
+		dlsl.CreatedAt = now.Add(time.Duration(-i) * 24 * time.Hour).UTC()
+		if _, err := dlsls.CreateDoorlockStatusLog(context.Background(), dlsl); err != nil {
+			t.Fatalf("create doorlock status log failed: %v", err)
+		}
+	}
+
+	dlslList, err := dlsls.GetDoorlockStatusLogInTimeRange(context.Background(), now.Add(-36*time.Hour), now)
+	if err != nil {
+		t.Fatalf("get doorlock status logs failed: %v", err)
+	}
+	if len(*dlslList) != 2 {
+		t.Errorf("got %d logs, wanted %d", len(*dlslList), 2)
+	}
+
+	isSuccess, err := dlsls.DeleteDoorlockStatusLogInTimeRange(context.Background(), now.Add(-72*time.Hour), now.Add(-12*time.Hour))
+	if err != nil || !isSuccess {
+		t.Fatalf("delete doorlock status logs failed: %v", err)
+	}
+
+	dlslAll, _ := dlsls.GetAllDoorlockStatusLogs(context.Background())
+	if len(dlslAll) != 1 {
+		t.Errorf("got %d logs, wanted %d", len(dlslAll), 1)
+	}
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -10,22 +12,99 @@ import (
 
 type Employee struct {
 	GormModel
-	MSNV       string `gorm:"type:varchar(256); unique; not null;" json:"msnv" binding:"required"`
-	Name       string `json:"name"`
-	Phone      string `gorm:"type:varchar(50)" json:"phone"`
-	Email      string `gorm:"type:varchar(256); not null;" json:"email"`
-	Department string `json:"department"`
-	Role       string `gorm:"not null;" json:"role"`
-	UserPass
+	SoftDelete
+	MSNV            string `gorm:"type:varchar(256); unique; not null;" json:"msnv" binding:"required"`
+	Name            string `json:"name"`
+	Phone           string `gorm:"type:varchar(50)" json:"phone"`
+	Email           string `gorm:"type:varchar(256); not null;" json:"email"`
+	Department      string `json:"department"`
+	Role            string `gorm:"not null;" json:"role"`
+	UserPass        `gorm:"-"`
+	PersonID        *uint       `gorm:"index" json:"personId"`
+	Person          *Person     `gorm:"foreignKey:PersonID" json:"person,omitempty"`
 	HighestPriority bool        `json:"highestPriority"`
 	Schedulers      []Scheduler `gorm:"foreignKey:EmployeeID;references:MSNV;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"schedulers"`
 }
 
+// Create person identity of a new employee, legacy password fields become its first credentials
+func (e *Employee) BeforeCreate(tx *gorm.DB) error {
+	return linkPerson(tx, &e.PersonID, &e.Person, PERSON_TYPE_EMPLOYEE, e.MSNV, e.Name, e.UserPass)
+}
+
+// Load the linked person so the created employee carries its credentials
+func (e *Employee) AfterCreate(tx *gorm.DB) (err error) {
+	e.Person, err = loadPerson(tx, e.PersonID)
+	return err
+}
+
+// Fill legacy password fields from the first usable credentials of the person
+func (e *Employee) AfterFind(tx *gorm.DB) error {
+	e.UserPass = e.Person.PrimaryUserPass(time.Now())
+	return nil
+}
+
+func (e *Employee) personInfo() (string, UserPass) {
+	return e.Name, e.UserPass
+}
+
 // Struct defines HTTP request payload for deleting employee
 type DeleteEmployee struct {
 	MSNV string `json:"msnv" binding:"required"`
 }
 
+// Columns of employee import/export sheet
+var EmployeeSheetColumns = []SheetColumn{
+	{Header: "msnv", Field: "MSNV", Required: true},
+	{Header: "name", Field: "Name"},
+	{Header: "phone", Field: "Phone"},
+	{Header: "email", Field: "Email"},
+	{Header: "department", Field: "Department"},
+	{Header: "role", Field: "Role", Required: true},
+	{Header: "rfidPass"},
+	{Header: "keypadPass"},
+	{Header: "highestPriority", Field: "HighestPriority"},
+}
+
+var employeeSheet = &userSheet{
+	columns:  EmployeeSheetColumns,
+	hasEmail: true,
+	newUser:  func() interface{} { return &Employee{} },
+	fromValues: func(v map[string]string) (interface{}, error) {
+		hp := false
+		if v["highestPriority"] != "" {
+			var err error
+			if hp, err = strconv.ParseBool(v["highestPriority"]); err != nil {
+				return nil, fmt.Errorf("highestPriority must be true or false")
+			}
+		}
+		return &Employee{
+			MSNV:            v["msnv"],
+			Name:            v["name"],
+			Phone:           v["phone"],
+			Email:           v["email"],
+			Department:      v["department"],
+			Role:            v["role"],
+			UserPass:        UserPass{RfidPass: v["rfidPass"], KeypadPass: v["keypadPass"]},
+			HighestPriority: hp,
+		}, nil
+	},
+}
+
+// Employees and their registers
+type EmployeeService interface {
+	FindAllEmployee(ctx context.Context) (eList []Employee, err error)
+	FindEmployeeByMSNV(ctx context.Context, msnv string) (e *Employee, err error)
+	FindAllHPEmployee(ctx context.Context) (eL []Employee, err error)
+	CreateEmployee(ctx context.Context, e *Employee) (*Employee, error)
+	UpdateEmployee(ctx context.Context, e *Employee) (bool, error)
+	DeleteEmployee(ctx context.Context, msnv string, deletedBy string) (bool, error)
+	DeleteHPEmployee(ctx context.Context, msnv string, deletedBy string) (bool, error)
+	RestoreEmployee(ctx context.Context, msnv string) (bool, error)
+	AppendEmployeeScheduler(ctx context.Context, e *Employee, usu *UserSchedulerReq, sche *Scheduler) (*Employee, error)
+	AppendEmployeeSchedulerExcel(ctx context.Context, sche *Scheduler) (*Employee, error)
+	ImportEmployees(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult
+}
+
 type EmployeeSvc struct {
 	db *gorm.DB
 }
@@ -37,7 +116,7 @@ func NewEmployeeSvc(db *gorm.DB) *EmployeeSvc {
 }
 
 func (es *EmployeeSvc) FindAllEmployee(ctx context.Context) (eList []Employee, err error) {
-	result := es.db.Preload("Schedulers").Find(&eList)
+	result := conn(ctx, es.db).Preload("Schedulers").Preload("Person.Credentials").Find(&eList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -47,7 +126,7 @@ func (es *EmployeeSvc) FindAllEmployee(ctx context.Context) (eList []Employee, e
 
 func (es *EmployeeSvc) FindEmployeeByMSNV(ctx context.Context, msnv string) (e *Employee, err error) {
 	var cnt int64
-	result := es.db.Preload("Schedulers").Where("msnv = ?", msnv).Find(&e).Count(&cnt)
+	result := conn(ctx, es.db).Preload("Schedulers").Preload("Person.Credentials").Where("msnv = ?", msnv).Find(&e).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -61,7 +140,7 @@ func (es *EmployeeSvc) FindEmployeeByMSNV(ctx context.Context, msnv string) (e *
 }
 
 func (es *EmployeeSvc) FindAllHPEmployee(ctx context.Context) (eL []Employee, err error) {
-	result := es.db.Where("highest_priority = ?", true).Find(&eL)
+	result := conn(ctx, es.db).Preload("Person.Credentials").Where("highest_priority = ?", true).Find(&eL)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -70,7 +149,11 @@ func (es *EmployeeSvc) FindAllHPEmployee(ctx context.Context) (eL []Employee, er
 }
 
 func (es *EmployeeSvc) CreateEmployee(ctx context.Context, e *Employee) (*Employee, error) {
-	if err := es.db.Create(&e).Error; err != nil {
+	db := conn(ctx, es.db)
+	if err := checkNotDeleted(db, e, "msnv", e.MSNV); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&e).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -78,44 +161,53 @@ func (es *EmployeeSvc) CreateEmployee(ctx context.Context, e *Employee) (*Employ
 }
 
 func (es *EmployeeSvc) UpdateEmployee(ctx context.Context, e *Employee) (bool, error) {
-	result := es.db.Model(&e).Where("id = ? AND msnv = ?", e.ID, e.MSNV).Updates(e)
-	_, err := utils.ReturnBoolStateFromResult(result)
-	if err != nil {
-		return false, err
-	}
-	result = es.db.Model(&e).Where("id = ? AND msnv = ?", e.ID, e.MSNV).Updates(map[string]interface{}{
-		"highest_priority": e.HighestPriority,
+	isSuccess := false
+	err := conn(ctx, es.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&e).Omit("Person").Where("id = ? AND msnv = ?", e.ID, e.MSNV).Updates(e)
+		_, err := utils.ReturnBoolStateFromResult(result)
+		if err != nil {
+			return err
+		}
+		result = tx.Model(&e).Where("id = ? AND msnv = ?", e.ID, e.MSNV).Updates(map[string]interface{}{
+			"highest_priority": e.HighestPriority,
+		})
+		if isSuccess, err = utils.ReturnBoolStateFromResult(result); err != nil {
+			return err
+		}
+		return syncPerson(tx, &Employee{}, e.Name, e.UserPass, "id = ?", e.ID)
 	})
-	return utils.ReturnBoolStateFromResult(result)
+	return isSuccess, err
+}
+
+func (es *EmployeeSvc) DeleteEmployee(ctx context.Context, msnv string, deletedBy string) (bool, error) {
+	return softDeleteProfile(conn(ctx, es.db), &Employee{}, deletedBy, "msnv = ?", msnv)
 }
 
-func (es *EmployeeSvc) DeleteEmployee(ctx context.Context, msnv string) (bool, error) {
-	result := es.db.Unscoped().Where("msnv = ?", msnv).Delete(&Employee{})
-	return utils.ReturnBoolStateFromResult(result)
+func (es *EmployeeSvc) DeleteHPEmployee(ctx context.Context, msnv string, deletedBy string) (bool, error) {
+	return softDeleteProfile(conn(ctx, es.db), &Employee{}, deletedBy, "msnv = ? AND highest_priority = ?", msnv, true)
 }
 
-func (es *EmployeeSvc) DeleteHPEmployee(ctx context.Context, msnv string) (bool, error) {
-	result := es.db.Unscoped().Where("msnv = ? AND highest_priority = ?", msnv, true).Delete(&Employee{})
-	return utils.ReturnBoolStateFromResult(result)
+func (es *EmployeeSvc) RestoreEmployee(ctx context.Context, msnv string) (bool, error) {
+	return restoreProfile(conn(ctx, es.db), &Employee{}, "msnv = ?", msnv)
 }
 
 func (es *EmployeeSvc) AppendEmployeeScheduler(ctx context.Context, e *Employee, usu *UserSchedulerReq, sche *Scheduler) (*Employee, error) {
 
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := es.db.Where("doorlock_address = ? AND gateway_id = ?", usu.DoorlockAddress, usu.GatewayID).First(door)
+	doorResult := conn(ctx, es.db).Where("doorlock_address = ? AND gateway_id = ?", usu.DoorlockAddress, usu.GatewayID).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := es.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, es.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	// Add scheduler for employee
-	if err := es.db.Model(&e).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, es.db).Model(&e).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -125,13 +217,13 @@ func (es *EmployeeSvc) AppendEmployeeScheduler(ctx context.Context, e *Employee,
 func (es *EmployeeSvc) AppendEmployeeSchedulerExcel(ctx context.Context, sche *Scheduler) (*Employee, error) {
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := es.db.Where("id = ?", sche.DoorID).First(door)
+	doorResult := conn(ctx, es.db).Where("id = ?", sche.DoorID).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := es.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, es.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -141,10 +233,25 @@ func (es *EmployeeSvc) AppendEmployeeSchedulerExcel(ctx context.Context, sche *S
 		return nil, err
 	}
 	// Add scheduler for employee
-	if err := es.db.Model(&userEmp).Association("Schedulers").Append(sche); err != nil {
+	if err := conn(ctx, es.db).Model(&userEmp).Association("Schedulers").Append(sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	return userEmp, nil
 }
+
+// Create or update employees keyed on MSNV, only fields of the sheet columns are updated
+func (es *EmployeeSvc) ImportEmployees(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult {
+	return importUsers(conn(ctx, es.db), employeeSheet, fields, sheetRows)
+}
+
+// Convert employees to sheet rows in the import format
+func EmployeeSheet(eList []Employee) [][]string {
+	rows := [][]string{SheetHeader(EmployeeSheetColumns)}
+	for _, e := range eList {
+		rows = append(rows, []string{e.MSNV, e.Name, e.Phone, e.Email, e.Department, e.Role,
+			e.RfidPass, e.KeypadPass, strconv.FormatBool(e.HighestPriority)})
+	}
+	return rows
+}
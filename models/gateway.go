@@ -10,6 +10,7 @@ import (
 
 type Gateway struct {
 	GormModel
+	SoftDelete
 	AreaID          string      `json:"areaId"`
 	GatewayID       string      `gorm:"type:varchar(256);unique;not null;" json:"gatewayId"`
 	Name            string      `json:"name"`
@@ -27,7 +28,26 @@ type DeleteGateway struct {
 type GatewayBlockCmd struct {
 	BlockId string `json:"block_id" binding:"required"`
 	Action  string `json:"action" binding:"required"`
+	Reason  string `json:"reason"` // kept on the unlock request of blocks with doorlocks requiring approval
+}
+
+// Gateways
+type GatewayService interface {
+	FindAllGateway(ctx context.Context) (gwList []Gateway, err error)
+	FindGatewayByID(ctx context.Context, id string) (gw *Gateway, err error)
+	FindGatewayByMacID(ctx context.Context, id string) (gw *Gateway, err error)
+	CreateGateway(ctx context.Context, g *Gateway) (*Gateway, error)
+	UpdateGateway(ctx context.Context, g *Gateway) (bool, error)
+	DeleteGateway(ctx context.Context, gwID string, deletedBy string) (bool, error)
+	RestoreGateway(ctx context.Context, gwID string) (bool, error)
+	AppendGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error)
+	UpdateGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error)
+	DeleteGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error)
+	FindAllGatewaysByBlockID(ctx context.Context, block_id string) (gwList []string, err error)
+	UpdateAllDoorlocksStateByBlockID(ctx context.Context, block_id string, state string) (bool, error)
+	UpdateGatewayConnectState(ctx context.Context, gwId string, state bool) (bool, error)
 }
+
 type GatewaySvc struct {
 	db *gorm.DB
 }
@@ -39,7 +59,7 @@ func NewGatewaySvc(db *gorm.DB) *GatewaySvc {
 }
 
 func (gs *GatewaySvc) FindAllGateway(ctx context.Context) (gwList []Gateway, err error) {
-	result := gs.db.Preload("Doorlocks").Preload("GwNetworks").Find(&gwList)
+	result := conn(ctx, gs.db).Preload("Doorlocks").Preload("GwNetworks").Find(&gwList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -48,7 +68,7 @@ func (gs *GatewaySvc) FindAllGateway(ctx context.Context) (gwList []Gateway, err
 }
 
 func (gs *GatewaySvc) FindGatewayByID(ctx context.Context, id string) (gw *Gateway, err error) {
-	result := gs.db.Preload("Doorlocks").Preload("GwNetworks").First(&gw, id)
+	result := conn(ctx, gs.db).Preload("Doorlocks").Preload("GwNetworks").First(&gw, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -58,7 +78,7 @@ func (gs *GatewaySvc) FindGatewayByID(ctx context.Context, id string) (gw *Gatew
 
 func (gs *GatewaySvc) FindGatewayByMacID(ctx context.Context, id string) (gw *Gateway, err error) {
 	var cnt int64
-	result := gs.db.Preload("GwNetworks").Where("gateway_id = ?", id).Find(&gw).Count(&cnt)
+	result := conn(ctx, gs.db).Preload("GwNetworks").Where("gateway_id = ?", id).Find(&gw).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -72,7 +92,7 @@ func (gs *GatewaySvc) FindGatewayByMacID(ctx context.Context, id string) (gw *Ga
 }
 
 func (gs *GatewaySvc) CreateGateway(ctx context.Context, g *Gateway) (*Gateway, error) {
-	if err := gs.db.Create(&g).Error; err != nil {
+	if err := conn(ctx, gs.db).Create(&g).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -80,18 +100,52 @@ func (gs *GatewaySvc) CreateGateway(ctx context.Context, g *Gateway) (*Gateway,
 }
 
 func (gs *GatewaySvc) UpdateGateway(ctx context.Context, g *Gateway) (bool, error) {
-	result := gs.db.Model(&g).Where("gateway_id = ?", g.GatewayID).Updates(g)
+	result := conn(ctx, gs.db).Model(&g).Where("gateway_id = ?", g.GatewayID).Updates(g)
 	return utils.ReturnBoolStateFromResult(result)
 
 }
 
-func (gs *GatewaySvc) DeleteGateway(ctx context.Context, gwID string) (bool, error) {
-	result := gs.db.Unscoped().Where("gateway_id = ?", gwID).Delete(&Gateway{})
-	return utils.ReturnBoolStateFromResult(result)
+// Delete gateway together with its doorlocks, they share deleted_at and deleted_by
+func (gs *GatewaySvc) DeleteGateway(ctx context.Context, gwID string, deletedBy string) (bool, error) {
+	isSuccess := false
+	err := conn(ctx, gs.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		deletedAt := tx.NowFunc()
+		if isSuccess, err = utils.ReturnBoolStateFromResult(softDeleteAt(tx, &Gateway{}, deletedAt, deletedBy, "gateway_id = ?", gwID)); err != nil {
+			return err
+		}
+		return softDeleteAt(tx, &Doorlock{}, deletedAt, deletedBy, "gateway_id = ?", gwID).Error
+	})
+	return isSuccess, err
+}
+
+// Restore gateway and the doorlocks deleted together with it
+func (gs *GatewaySvc) RestoreGateway(ctx context.Context, gwID string) (bool, error) {
+	var gw Gateway
+	result := conn(ctx, gs.db).Unscoped().Where("gateway_id = ? AND deleted_at IS NOT NULL", gwID).Limit(1).Find(&gw)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return false, err
+	}
+	if result.RowsAffected <= 0 {
+		return false, fmt.Errorf("find no deleted records")
+	}
+
+	err := conn(ctx, gs.db).Transaction(func(tx *gorm.DB) error {
+		if err := restoreDeleted(tx, &Doorlock{}, "gateway_id = ? AND deleted_at = ? AND deleted_by = ?", gwID, gw.DeletedAt.Time, gw.DeletedBy).Error; err != nil {
+			return err
+		}
+		return restoreDeleted(tx, &Gateway{}, "gateway_id = ?", gwID).Error
+	})
+	if err != nil {
+		err = utils.HandleQueryError(err)
+		return false, err
+	}
+	return true, nil
 }
 
 func (gs *GatewaySvc) AppendGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error) {
-	if err := gs.db.Model(&gw).Association("Doorlocks").Append(d); err != nil {
+	if err := conn(ctx, gs.db).Model(&gw).Association("Doorlocks").Append(d); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -99,7 +153,7 @@ func (gs *GatewaySvc) AppendGatewayDoorlock(ctx context.Context, gw *Gateway, d
 }
 
 func (gs *GatewaySvc) UpdateGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error) {
-	if err := gs.db.Model(&gw).Association("Doorlocks").Replace(d); err != nil {
+	if err := conn(ctx, gs.db).Model(&gw).Association("Doorlocks").Replace(d); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -107,7 +161,7 @@ func (gs *GatewaySvc) UpdateGatewayDoorlock(ctx context.Context, gw *Gateway, d
 }
 
 func (gs *GatewaySvc) DeleteGatewayDoorlock(ctx context.Context, gw *Gateway, d *Doorlock) (*Gateway, error) {
-	if err := gs.db.Model(&gw).Association("Doorlocks").Delete(d); err != nil {
+	if err := conn(ctx, gs.db).Model(&gw).Association("Doorlocks").Delete(d); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -115,7 +169,7 @@ func (gs *GatewaySvc) DeleteGatewayDoorlock(ctx context.Context, gw *Gateway, d
 }
 
 func (gs *GatewaySvc) FindAllGatewaysByBlockID(ctx context.Context, block_id string) (gwList []string, err error) {
-	if err := gs.db.Model(&Doorlock{}).Select("gateway_id").Where("block_id = ?", block_id).Group("gateway_id").Find(&gwList).Error; err != nil {
+	if err := conn(ctx, gs.db).Model(&Doorlock{}).Select("gateway_id").Where("block_id = ?", block_id).Group("gateway_id").Find(&gwList).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -123,12 +177,12 @@ func (gs *GatewaySvc) FindAllGatewaysByBlockID(ctx context.Context, block_id str
 }
 
 func (gs *GatewaySvc) UpdateAllDoorlocksStateByBlockID(ctx context.Context, block_id string, state string) (bool, error) {
-	result := gs.db.Model(&Doorlock{}).Where("block_id = ?", block_id).Update("lock_state", state)
+	result := conn(ctx, gs.db).Model(&Doorlock{}).Where("block_id = ?", block_id).Update("lock_state", state)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (gs *GatewaySvc) UpdateGatewayConnectState(ctx context.Context, gwId string, state bool) (bool, error) {
-	if err := gs.db.Model(&Gateway{}).Where("gateway_id = ?", gwId).Update("connect_state", state).Error; err != nil {
+	if err := conn(ctx, gs.db).Model(&Gateway{}).Where("gateway_id = ?", gwId).Update("connect_state", state).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return false, err
 	}
@@ -16,6 +16,14 @@ type GwNetwork struct {
 	MacAddress         string `gorm:"type:varchar(20);not null;"`
 }
 
+// Network interfaces of gateways
+type GwNetworkService interface {
+	CreateGwNetwork(ctx context.Context, gwNet *GwNetwork) (*GwNetwork, error)
+	FindGwNetworkByName(ctx context.Context, gwId string, ifName string) (gwNet *GwNetwork, err error)
+	UpdateGwNetwork(ctx context.Context, gwNet *GwNetwork) (bool, error)
+	DeleteGwNetwork(ctx context.Context, gwNet *GwNetwork) (bool, error)
+}
+
 type GwNetworkSvc struct {
 	db *gorm.DB
 }
@@ -27,7 +35,7 @@ func NewGwNetworkSvc(db *gorm.DB) *GwNetworkSvc {
 }
 
 func (gwns *GwNetworkSvc) CreateGwNetwork(ctx context.Context, gwNet *GwNetwork) (*GwNetwork, error) {
-	if err := gwns.db.Create(&gwNet).Error; err != nil {
+	if err := conn(ctx, gwns.db).Create(&gwNet).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -35,7 +43,7 @@ func (gwns *GwNetworkSvc) CreateGwNetwork(ctx context.Context, gwNet *GwNetwork)
 }
 
 func (gwns *GwNetworkSvc) FindGwNetworkByName(ctx context.Context, gwId string, ifName string) (gwNet *GwNetwork, err error) {
-	result := gwns.db.Where("gateway_id = ? AND interface_name = ?", gwId, ifName).Find(&gwNet)
+	result := conn(ctx, gwns.db).Where("gateway_id = ? AND interface_name = ?", gwId, ifName).Find(&gwNet)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -49,7 +57,7 @@ func (gwns *GwNetworkSvc) UpdateGwNetwork(ctx context.Context, gwNet *GwNetwork)
 		err = utils.HandleQueryError(err)
 		return false, err
 	}
-	if err = gwns.db.Model(&gw).Select("primary_ip_address", "secondary_ip_address", "mac_address").Where("gateway_id = ? AND interface_name = ?", gwNet.GatewayID, gwNet.InterfaceName).Updates(GwNetwork{
+	if err = conn(ctx, gwns.db).Model(&gw).Select("primary_ip_address", "secondary_ip_address", "mac_address").Where("gateway_id = ? AND interface_name = ?", gwNet.GatewayID, gwNet.InterfaceName).Updates(GwNetwork{
 		PrimaryIpAddress:   gwNet.PrimaryIpAddress,
 		SecondaryIpAddress: gwNet.SecondaryIpAddress,
 		MacAddress:         gwNet.MacAddress,
@@ -62,7 +70,7 @@ func (gwns *GwNetworkSvc) UpdateGwNetwork(ctx context.Context, gwNet *GwNetwork)
 
 func (gwns *GwNetworkSvc) DeleteGwNetwork(ctx context.Context, gwNet *GwNetwork) (bool, error) {
 	// Delete all rows match specific gateway_id
-	if err := gwns.db.Where("gateway_id = ?", gwNet.GatewayID).Delete(&GwNetwork{}).Error; err != nil {
+	if err := conn(ctx, gwns.db).Where("gateway_id = ?", gwNet.GatewayID).Delete(&GwNetwork{}).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return false, err
 	}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+// Database checks of the health endpoints
+type HealthService interface {
+	PingDatabase(ctx context.Context) error
+	CountOnlineGateway(ctx context.Context) (int64, error)
+}
+
+type HealthSvc struct {
+	db *gorm.DB
+}
+
+func NewHealthSvc(db *gorm.DB) *HealthSvc {
+	return &HealthSvc{
+		db: db,
+	}
+}
+
+func (hs *HealthSvc) PingDatabase(ctx context.Context) error {
+	sqlDb, err := conn(ctx, hs.db).DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+// Gateways connected to the broker, as reported by bootup and last will messages
+func (hs *HealthSvc) CountOnlineGateway(ctx context.Context) (int64, error) {
+	var cnt int64
+	if err := conn(ctx, hs.db).Model(&Gateway{}).Where("connect_state = ?", true).Count(&cnt).Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return cnt, nil
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	JOB_RECYCLE_BIN_PURGE   string = "recycleBinPurge"
+	JOB_RETENTION           string = "retention"
+	JOB_SCHEDULED_COMMANDS  string = "scheduledCommands"
+	JOB_CREDENTIAL_VALIDITY string = "credentialValidity"
+
+	JOB_STATUS_SUCCESS string = "success"
+	JOB_STATUS_FAILED  string = "failed"
+
+	// Due jobs are looked for on every tick, so it bounds how late a job starts
+	JOB_RUNNER_TICK time.Duration = 5 * time.Second
+)
+
+// Time source of the job runner, replaced in tests to run jobs at a chosen time
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Periodic task, now is the time it was due. The context is cancelled when the runner stops
+type JobFunc func(ctx context.Context, now time.Time) error
+
+// Run state of a registered job, NextRunAt is kept across restarts so a job does not run again early
+type Job struct {
+	GormModel
+	Name            string     `gorm:"type:varchar(64);unique;not null" json:"name"`
+	IntervalSeconds int64      `json:"intervalSeconds"`
+	NextRunAt       time.Time  `json:"nextRunAt"`
+	LastRunAt       *time.Time `json:"lastRunAt"`
+	LastStatus      string     `gorm:"type:varchar(16)" json:"lastStatus"`
+	LastError       string     `json:"lastError"`
+	LastDurationMs  int64      `json:"lastDurationMs"`
+	Running         bool       `gorm:"-" json:"running"`
+}
+
+type registeredJob struct {
+	interval time.Duration
+	fn       JobFunc
+	running  bool
+}
+
+type JobRunner struct {
+	db    *gorm.DB
+	clock Clock
+	mu    sync.Mutex
+	jobs  map[string]*registeredJob
+	wg    sync.WaitGroup
+}
+
+func NewJobRunner(db *gorm.DB, clock Clock) *JobRunner {
+	return &JobRunner{
+		db:    db,
+		clock: clock,
+		jobs:  map[string]*registeredJob{},
+	}
+}
+
+// Add a job run every interval, a new job is due right away and a known one keeps its persisted next run
+func (jr *JobRunner) Register(name string, interval time.Duration, fn JobFunc) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval of job %s must be positive", name)
+	}
+	now := jr.clock.Now().UTC()
+	job := &Job{Name: name, IntervalSeconds: int64(interval / time.Second), NextRunAt: now}
+	result := jr.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"interval_seconds", "updated_at"}),
+	}).Create(job)
+	if err := result.Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	// Interval shortened since the last run
+	if err := jr.db.Model(&Job{}).Where("name = ? AND next_run_at > ?", name, now.Add(interval)).
+		Update("next_run_at", now.Add(interval)).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.jobs[name] = &registeredJob{interval: interval, fn: fn}
+	return nil
+}
+
+// Run due jobs until ctx is cancelled, then wait for the running ones to return
+func (jr *JobRunner) Start(ctx context.Context) {
+	ticker := time.NewTicker(JOB_RUNNER_TICK)
+	jr.wg.Add(1)
+	go func() {
+		defer jr.wg.Done()
+		defer ticker.Stop()
+		jr.startDue(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				jr.startDue(ctx)
+			}
+		}
+	}()
+}
+
+// Block until the runner and its jobs returned after the Start context was cancelled
+func (jr *JobRunner) Wait() {
+	jr.wg.Wait()
+}
+
+// Run due jobs and wait for them, jobs still running from a previous tick are not started again
+func (jr *JobRunner) RunDue(ctx context.Context) {
+	jr.startDue(ctx).Wait()
+}
+
+// Registered jobs with their persisted state, ordered by name
+func (jr *JobRunner) FindAllJob(ctx context.Context) ([]Job, error) {
+	jr.mu.Lock()
+	names := make([]string, 0, len(jr.jobs))
+	running := map[string]bool{}
+	for name, rj := range jr.jobs {
+		names = append(names, name)
+		running[name] = rj.running
+	}
+	jr.mu.Unlock()
+	sort.Strings(names)
+
+	jobList := []Job{}
+	if len(names) == 0 {
+		return jobList, nil
+	}
+	if err := conn(ctx, jr.db).Where("name IN ?", names).Order("name").Find(&jobList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	for i := range jobList {
+		jobList[i].Running = running[jobList[i].Name]
+	}
+	return jobList, nil
+}
+
+// Persisted state of a job, registered or not
+func (jr *JobRunner) FindJobByName(ctx context.Context, name string) (*Job, error) {
+	job := &Job{}
+	if err := conn(ctx, jr.db).Where("name = ?", name).First(job).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return job, nil
+}
+
+func (jr *JobRunner) startDue(ctx context.Context) *sync.WaitGroup {
+	started := &sync.WaitGroup{}
+	if ctx.Err() != nil {
+		return started
+	}
+	now := jr.clock.Now().UTC()
+	var dueList []Job
+	if err := conn(ctx, jr.db).Where("next_run_at <= ?", now).Find(&dueList).Error; err != nil {
+		logger.LogfWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Find due jobs failed: %s", err.Error())
+		return started
+	}
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	for _, job := range dueList {
+		rj, ok := jr.jobs[job.Name]
+		if !ok || rj.running {
+			continue
+		}
+		rj.running = true
+		started.Add(1)
+		jr.wg.Add(1)
+		go func(name string, rj *registeredJob) {
+			defer jr.wg.Done()
+			defer started.Done()
+			jr.run(ctx, name, rj, now)
+		}(job.Name, rj)
+	}
+	return started
+}
+
+func (jr *JobRunner) run(ctx context.Context, name string, rj *registeredJob, now time.Time) {
+	defer func() {
+		jr.mu.Lock()
+		rj.running = false
+		jr.mu.Unlock()
+	}()
+
+	err := jr.call(ctx, rj.fn, now)
+	finished := jr.clock.Now().UTC()
+	status, errMsg := JOB_STATUS_SUCCESS, ""
+	if err != nil {
+		status, errMsg = JOB_STATUS_FAILED, err.Error()
+		logger.LogfWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Job %s failed: %s", name, errMsg)
+	}
+	// Next run is counted from the due time so the period does not drift, a run longer than it is not caught up
+	next := now.Add(rj.interval)
+	if next.Before(finished) {
+		next = finished
+	}
+	// Saved even when ctx was cancelled by the shutdown meanwhile
+	result := jr.db.Model(&Job{}).Where("name = ?", name).Updates(map[string]interface{}{
+		"next_run_at":      next,
+		"last_run_at":      now,
+		"last_status":      status,
+		"last_error":       errMsg,
+		"last_duration_ms": finished.Sub(now).Milliseconds(),
+	})
+	if result.Error != nil {
+		logger.LogfWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "Save job %s failed: %s", name, result.Error.Error())
+	}
+}
+
+// A panicking job fails its run instead of the server
+func (jr *JobRunner) call(ctx context.Context, fn JobFunc, now time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, now)
+}
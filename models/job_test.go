@@ -0,0 +1,86 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time { return fc.now }
+
+func TestJobRunner(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)}
+	jr := NewJobRunner(db, clock)
+
+	var runs []time.Time
+	fail := false
+	jr.Register("clean", time.Hour, func(ctx context.Context, now time.Time) error {
+		runs = append(runs, now)
+		if fail {
+			return errors.New("clean failed")
+		}
+		return nil
+	})
+	jr.Register("broken", time.Hour, func(ctx context.Context, now time.Time) error {
+		panic("nil map")
+	})
+
+	// New jobs are due right away, then every interval
+	jr.RunDue(ctx)
+	clock.now = clock.now.Add(30 * time.Minute)
+	jr.RunDue(ctx)
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run before the interval, got %d", len(runs))
+	}
+	clock.now = clock.now.Add(30 * time.Minute)
+	fail = true
+	jr.RunDue(ctx)
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs after the interval, got %d", len(runs))
+	}
+
+	jobList, err := jr.FindAllJob(ctx)
+	if err != nil || len(jobList) != 2 {
+		t.Fatalf("expected 2 jobs, got %+v, %v", jobList, err)
+	}
+	broken, clean := jobList[0], jobList[1]
+	if broken.LastStatus != JOB_STATUS_FAILED || broken.LastError != "panic: nil map" {
+		t.Fatalf("expected panicking job failed, got %+v", broken)
+	}
+	if clean.LastStatus != JOB_STATUS_FAILED || clean.LastError != "clean failed" || clean.IntervalSeconds != 3600 ||
+		!clean.NextRunAt.Equal(clock.now.Add(time.Hour)) || clean.Running {
+		t.Fatalf("expected failed run and next run in an hour, got %+v", clean)
+	}
+
+	// Next run survives a restart
+	restarted := NewJobRunner(db, clock)
+	restarted.Register("clean", time.Hour, func(ctx context.Context, now time.Time) error {
+		runs = append(runs, now)
+		return nil
+	})
+	restarted.RunDue(ctx)
+	if len(runs) != 2 {
+		t.Fatalf("expected no run after restart before the next run, got %d", len(runs))
+	}
+
+	// Cancelled runner does not start jobs
+	clock.now = clock.now.Add(time.Hour)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	restarted.RunDue(cancelled)
+	if len(runs) != 2 {
+		t.Fatalf("expected no run on cancelled context, got %d", len(runs))
+	}
+	restarted.Start(cancelled)
+	restarted.Wait()
+}
@@ -0,0 +1,70 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFindGatewayLogsByTime(t *testing.T) {
+	db := newTestDb(t)
+	ls := &LogSvc{db: db}
+	base := time.Date(2022, 4, 1, 8, 0, 0, 0, time.FixedZone("ICT", 7*60*60))
+
+	for i, gwId := range []string{"gw-1", "gw-1", "gw-1", "gw-2"} {
+		_, err := ls.CreateGatewayLog(context.Background(), &GatewayLog{
+			GatewayID: gwId,
+			LogType:   "INFO",
+			Content:   "content",
+			LogTime:   base.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("create gateway log failed: %v", err)
+		}
+	}
+
+	glList, err := ls.FindGatewayLogsByTime(context.Background(), "gw-1", base.Add(30*time.Minute), base.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("find gateway logs failed: %v", err)
+	}
+	if len(*glList) != 2 {
+		t.Errorf("got %d logs, wanted %d", len(*glList), 2)
+	}
+
+	// Same instant expressed in another time zone must match the same rows
+	glList, err = ls.FindGatewayLogsTypeByTime(context.Background(), "gw-1", "info", base.UTC(), base.Add(time.Hour).UTC())
+	if err != nil {
+		t.Fatalf("find gateway logs by type failed: %v", err)
+	}
+	if len(*glList) != 2 {
+		t.Errorf("got %d logs, wanted %d", len(*glList), 2)
+	}
+}
+
+func TestCleanGatewayLogs(t *testing.T) {
+	db := newTestDb(t)
+	ls := &LogSvc{db: db}
+	now := time.Now()
+
+	ls.CreateGatewayLog(context.Background(), &GatewayLog{GatewayID: "gw-1", LogTime: now.Add(-48 * time.Hour)})
+	ls.CreateGatewayLog(context.Background(), &GatewayLog{GatewayID: "gw-1", LogTime: now.Add(-1 * time.Hour)})
+
+	rs := &RetentionSvc{db: db, archiveDir: t.TempDir()}
+	period := GatewayLogTime{Day: 1}
+	maxAgeHours, _ := period.ToRetentionHours()
+	rs.SetRetentionMaxAge(context.Background(), RETENTION_TABLE_GATEWAY_LOGS, maxAgeHours)
+	if _, err := rs.RunRetention(context.Background(), now); err != nil {
+		t.Fatalf("run retention failed: %v", err)
+	}
+
+	glList, err := ls.FindAllGatewayLog(context.Background())
+	if err != nil {
+		t.Fatalf("find gateway logs failed: %v", err)
+	}
+	if len(glList) != 1 {
+		t.Errorf("got %d logs, wanted %d", len(glList), 1)
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	MQTT_DEAD_LETTER_PENDING   string = "pending"
+	MQTT_DEAD_LETTER_REPLAYED  string = "replayed"
+	MQTT_DEAD_LETTER_DISCARDED string = "discarded"
+)
+
+// Gateway message that was rejected by its topic contract or whose processing failed, kept until
+// it is replayed or discarded. Reason is the error of the latest attempt
+type MqttDeadLetter struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Topic     string    `gorm:"type:varchar(256);not null;index" json:"topic"`
+	GatewayID string    `gorm:"type:varchar(256);index" json:"gatewayId"`
+	Payload   string    `json:"payload"`
+	Reason    string    `json:"reason"`
+	Attempts  uint      `gorm:"not null;default:1" json:"attempts"`
+	Status    string    `gorm:"type:varchar(50);not null;default:pending;index" json:"status"`
+}
+
+// Gateway messages whose handling failed
+type MqttDeadLetterService interface {
+	CreateMqttDeadLetter(ctx context.Context, dlt *MqttDeadLetter) (*MqttDeadLetter, error)
+	FindAllMqttDeadLetter(ctx context.Context, status string) ([]MqttDeadLetter, error)
+	FindMqttDeadLetterByID(ctx context.Context, id string) (dlt *MqttDeadLetter, err error)
+	RecordMqttDeadLetterAttempt(ctx context.Context, id uint, err error) (bool, error)
+	DiscardMqttDeadLetter(ctx context.Context, id uint) (*MqttDeadLetter, error)
+}
+
+type MqttDeadLetterSvc struct {
+	db *gorm.DB
+}
+
+func NewMqttDeadLetterSvc(db *gorm.DB) *MqttDeadLetterSvc {
+	return &MqttDeadLetterSvc{
+		db: db,
+	}
+}
+
+// Keep message failed on its first attempt
+func (mds *MqttDeadLetterSvc) CreateMqttDeadLetter(ctx context.Context, dlt *MqttDeadLetter) (*MqttDeadLetter, error) {
+	dlt.Status = MQTT_DEAD_LETTER_PENDING
+	dlt.Attempts = 1
+	if err := conn(ctx, mds.db).Create(dlt).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return dlt, nil
+}
+
+// Find dead letters newest first, filtered by status when given
+func (mds *MqttDeadLetterSvc) FindAllMqttDeadLetter(ctx context.Context, status string) ([]MqttDeadLetter, error) {
+	var dltList []MqttDeadLetter
+	query := conn(ctx, mds.db)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("id desc").Find(&dltList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return dltList, nil
+}
+
+func (mds *MqttDeadLetterSvc) FindMqttDeadLetterByID(ctx context.Context, id string) (dlt *MqttDeadLetter, err error) {
+	result := conn(ctx, mds.db).First(&dlt, id)
+	if err := result.Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return dlt, nil
+}
+
+// Record the result of replaying a pending dead letter, it stays pending when err is not nil
+func (mds *MqttDeadLetterSvc) RecordMqttDeadLetterAttempt(ctx context.Context, id uint, err error) (bool, error) {
+	updates := map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + ?", 1),
+		"updated_at": time.Now(),
+	}
+	if err != nil {
+		updates["reason"] = err.Error()
+	} else {
+		updates["status"] = MQTT_DEAD_LETTER_REPLAYED
+	}
+	result := conn(ctx, mds.db).Model(&MqttDeadLetter{}).
+		Where("id = ? AND status = ?", id, MQTT_DEAD_LETTER_PENDING).
+		Updates(updates)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Discard pending dead letter, it is kept for inspection but can not be replayed anymore
+func (mds *MqttDeadLetterSvc) DiscardMqttDeadLetter(ctx context.Context, id uint) (*MqttDeadLetter, error) {
+	dlt := &MqttDeadLetter{}
+	if err := conn(ctx, mds.db).First(dlt, id).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if dlt.Status != MQTT_DEAD_LETTER_PENDING {
+		return nil, fmt.Errorf("dead letter is %s", dlt.Status)
+	}
+
+	now := time.Now()
+	// Status condition keeps a replay and a discard from both applying
+	result := conn(ctx, mds.db).Model(&MqttDeadLetter{}).Where("id = ? AND status = ?", id, MQTT_DEAD_LETTER_PENDING).
+		Updates(map[string]interface{}{"status": MQTT_DEAD_LETTER_DISCARDED, "updated_at": now})
+	if _, err := utils.ReturnBoolStateFromResult(result); err != nil {
+		return nil, err
+	}
+	dlt.Status, dlt.UpdatedAt = MQTT_DEAD_LETTER_DISCARDED, now
+	return dlt, nil
+}
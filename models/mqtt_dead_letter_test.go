@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMqttDeadLetterDiscard(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	mds := NewMqttDeadLetterSvc(db)
+
+	dlt, err := mds.CreateMqttDeadLetter(ctx, &MqttDeadLetter{Topic: "gateway/doorlock/update", Payload: "{}", Reason: "unknown doorlock"})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if dlt.Status != MQTT_DEAD_LETTER_PENDING || dlt.Attempts != 1 {
+		t.Errorf("got status %s attempts %d, wanted pending 1", dlt.Status, dlt.Attempts)
+	}
+
+	if _, err := mds.RecordMqttDeadLetterAttempt(ctx, dlt.ID, errors.New("still unknown")); err != nil {
+		t.Fatalf("record attempt failed: %v", err)
+	}
+	discarded, err := mds.DiscardMqttDeadLetter(ctx, dlt.ID)
+	if err != nil {
+		t.Fatalf("discard failed: %v", err)
+	}
+	if discarded.Status != MQTT_DEAD_LETTER_DISCARDED || discarded.Attempts != 2 || discarded.Reason != "still unknown" {
+		t.Errorf("got %+v, wanted discarded after 2 attempts", discarded)
+	}
+
+	// Discarded letter is final
+	if _, err := mds.DiscardMqttDeadLetter(ctx, dlt.ID); err == nil {
+		t.Error("discarded dead letter was discarded again")
+	}
+	if ok, _ := mds.RecordMqttDeadLetterAttempt(ctx, dlt.ID, nil); ok {
+		t.Error("discarded dead letter was replayed")
+	}
+	if pending, _ := mds.FindAllMqttDeadLetter(ctx, MQTT_DEAD_LETTER_PENDING); len(pending) != 0 {
+		t.Errorf("got %d pending, wanted 0", len(pending))
+	}
+}
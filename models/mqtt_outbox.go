@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_MQTT_OUTBOX_MAX uint = 10000
+	MQTT_OUTBOX_FLUSH_BATCH int  = 100
+)
+
+// Message published while the broker was unreachable, sent in id order once reconnected
+type MqttOutboxMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Topic     string    `gorm:"type:varchar(256);not null" json:"topic"`
+	Qos       byte      `json:"qos"`
+	Retained  bool      `gorm:"not null" json:"retained"`
+	Payload   []byte    `json:"payload"`
+}
+
+// Messages queued while the broker is unreachable
+type MqttOutboxService interface {
+	EnqueueMqttOutboxMessage(ctx context.Context, msg *MqttOutboxMessage) (int64, error)
+	FindMqttOutboxMessages(ctx context.Context, limit int) ([]MqttOutboxMessage, error)
+	DeleteMqttOutboxMessage(ctx context.Context, id uint) (bool, error)
+	CountMqttOutboxMessage(ctx context.Context) (int64, error)
+}
+
+type MqttOutboxSvc struct {
+	db  *gorm.DB
+	max uint
+}
+
+func NewMqttOutboxSvc(db *gorm.DB, max uint) *MqttOutboxSvc {
+	if max == 0 {
+		max = DEFAULT_MQTT_OUTBOX_MAX
+	}
+	return &MqttOutboxSvc{
+		db:  db,
+		max: max,
+	}
+}
+
+// Queue message, the oldest messages beyond the outbox size are dropped and counted
+func (mos *MqttOutboxSvc) EnqueueMqttOutboxMessage(ctx context.Context, msg *MqttOutboxMessage) (int64, error) {
+	var dropped int64
+	err := conn(ctx, mos.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Model(&MqttOutboxMessage{}).Order("id desc").Offset(int(mos.max)).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		result := tx.Where("id <= ?", ids[0]).Delete(&MqttOutboxMessage{})
+		dropped = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return dropped, nil
+}
+
+// Oldest queued messages first
+func (mos *MqttOutboxSvc) FindMqttOutboxMessages(ctx context.Context, limit int) ([]MqttOutboxMessage, error) {
+	var msgList []MqttOutboxMessage
+	if err := conn(ctx, mos.db).Order("id").Limit(limit).Find(&msgList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return msgList, nil
+}
+
+func (mos *MqttOutboxSvc) DeleteMqttOutboxMessage(ctx context.Context, id uint) (bool, error) {
+	result := conn(ctx, mos.db).Delete(&MqttOutboxMessage{}, id)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (mos *MqttOutboxSvc) CountMqttOutboxMessage(ctx context.Context) (int64, error) {
+	var cnt int64
+	if err := conn(ctx, mos.db).Model(&MqttOutboxMessage{}).Count(&cnt).Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return cnt, nil
+}
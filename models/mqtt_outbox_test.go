@@ -0,0 +1,46 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMqttOutboxDropsOldest(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	mos := NewMqttOutboxSvc(db, 3)
+
+	var dropped int64
+	for _, payload := range []string{"1", "2", "3", "4", "5"} {
+		cnt, err := mos.EnqueueMqttOutboxMessage(ctx, &MqttOutboxMessage{Topic: "test", Qos: 1, Payload: []byte(payload)})
+		if err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+		dropped += cnt
+	}
+	if dropped != 2 {
+		t.Errorf("got %d dropped, wanted %d", dropped, 2)
+	}
+
+	msgList, err := mos.FindMqttOutboxMessages(ctx, MQTT_OUTBOX_FLUSH_BATCH)
+	if err != nil {
+		t.Fatalf("find failed: %v", err)
+	}
+	got := ""
+	for _, msg := range msgList {
+		got += string(msg.Payload)
+	}
+	if got != "345" {
+		t.Errorf("got queued %q, wanted %q", got, "345")
+	}
+
+	if ok, err := mos.DeleteMqttOutboxMessage(ctx, msgList[0].ID); !ok || err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if cnt, _ := mos.CountMqttOutboxMessage(ctx); cnt != 2 {
+		t.Errorf("got %d queued, wanted %d", cnt, 2)
+	}
+}
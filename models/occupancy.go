@@ -0,0 +1,394 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	READER_DIRECTION_ENTRY string = "entry"
+	READER_DIRECTION_EXIT  string = "exit"
+
+	ANTI_PASSBACK_OFF  string = "off"
+	ANTI_PASSBACK_SOFT string = "soft" // violations open the door and are recorded as warned
+	ANTI_PASSBACK_HARD string = "hard" // gateways deny violations
+
+	ACCESS_RESULT_GRANTED string = "granted"
+	ACCESS_RESULT_WARNED  string = "warned"
+	ACCESS_RESULT_DENIED  string = "denied"
+
+	DEFAULT_ACCESS_EVENT_LIMIT int = 100
+	MAX_ACCESS_EVENT_LIMIT     int = 1000
+)
+
+// Anti-passback mode of a room, rooms are the roomId of their doorlocks and default to off
+type RoomSetting struct {
+	GormModel
+	RoomID       string `gorm:"type:varchar(256);unique;not null" json:"roomId" binding:"required"`
+	AntiPassback string `gorm:"type:varchar(16);not null" json:"antiPassback" binding:"required"`
+}
+
+// Entry or exit on a reader doorlock reported by its gateway, Result is denied when the gateway kept the door closed
+type AccessEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	EventTime time.Time `gorm:"index" json:"eventTime"`
+	GatewayID string    `gorm:"type:varchar(256)" json:"gatewayId"`
+	DoorID    uint      `gorm:"index" json:"doorId"`
+	RoomID    string    `gorm:"type:varchar(256);index" json:"roomId"`
+	BlockID   string    `gorm:"type:varchar(256)" json:"blockId"`
+	PersonID  *uint     `gorm:"index" json:"personId"`
+	UserID    string    `gorm:"type:varchar(256)" json:"userId"`
+	Direction string    `gorm:"type:varchar(16)" json:"direction"`
+	Result    string    `gorm:"type:varchar(16);not null" json:"result"`
+	Reason    string    `json:"reason"`
+}
+
+// Person inside a room, created on entry and deleted on exit
+type RoomPresence struct {
+	GormModel
+	RoomID    string    `gorm:"type:varchar(256);not null;uniqueIndex:idx_room_presences_room_person" json:"roomId"`
+	PersonID  uint      `gorm:"not null;uniqueIndex:idx_room_presences_room_person" json:"personId"`
+	UserID    string    `gorm:"type:varchar(256)" json:"userId"`
+	BlockID   string    `gorm:"type:varchar(256)" json:"blockId"`
+	EnteredAt time.Time `json:"enteredAt"`
+}
+
+// Live occupancy of a room, capacity is the largest capacity of the room schedulers, 0 when none is set
+type RoomOccupancy struct {
+	RoomID       string         `json:"roomId"`
+	BlockID      string         `json:"blockId"`
+	Occupancy    int64          `json:"occupancy"`
+	Capacity     uint           `json:"capacity"`
+	Full         bool           `json:"full"`
+	AntiPassback string         `json:"antiPassback"`
+	People       []RoomPresence `json:"people,omitempty"`
+}
+
+// Live occupancy of a building, summed over its rooms
+type BuildingOccupancy struct {
+	BlockID   string `json:"blockId"`
+	Rooms     int    `json:"rooms"`
+	Occupancy int64  `json:"occupancy"`
+	Capacity  uint   `json:"capacity"`
+}
+
+// Struct defines HTTP query for finding access events, time range in unix seconds
+type AccessEventFilter struct {
+	RoomID   string `form:"roomId"`
+	DoorID   uint   `form:"doorId"`
+	PersonID uint   `form:"personId"`
+	UserID   string `form:"userId"`
+	Result   string `form:"result"`
+	From     int64  `form:"from"`
+	To       int64  `form:"to"`
+	Limit    int    `form:"limit"`
+	Offset   int    `form:"offset"`
+}
+
+// Room occupancy and anti-passback
+type OccupancyService interface {
+	FindAccessEvents(ctx context.Context, filter *AccessEventFilter) (aeList []AccessEvent, err error)
+	RecordAccessEvent(ctx context.Context, ae *AccessEvent) (*AccessEvent, error)
+	FindAllRoomOccupancy(ctx context.Context) (roList []RoomOccupancy, err error)
+	FindRoomOccupancy(ctx context.Context, roomID string) (*RoomOccupancy, error)
+	FindAllBuildingOccupancy(ctx context.Context) (boList []BuildingOccupancy, err error)
+	UpdateRoomSetting(ctx context.Context, rs *RoomSetting) (*RoomSetting, error)
+	ResetRoomOccupancy(ctx context.Context, roomID string) (bool, error)
+}
+
+type OccupancySvc struct {
+	db *gorm.DB
+}
+
+func NewOccupancySvc(db *gorm.DB) *OccupancySvc {
+	return &OccupancySvc{
+		db: db,
+	}
+}
+
+// Find access events newest first, limit is capped at MAX_ACCESS_EVENT_LIMIT
+func (ocs *OccupancySvc) FindAccessEvents(ctx context.Context, filter *AccessEventFilter) (aeList []AccessEvent, err error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DEFAULT_ACCESS_EVENT_LIMIT
+	}
+	if limit > MAX_ACCESS_EVENT_LIMIT {
+		limit = MAX_ACCESS_EVENT_LIMIT
+	}
+	query := conn(ctx, ocs.db).Model(&AccessEvent{})
+	if filter.RoomID != "" {
+		query = query.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.DoorID > 0 {
+		query = query.Where("door_id = ?", filter.DoorID)
+	}
+	if filter.PersonID > 0 {
+		query = query.Where("person_id = ?", filter.PersonID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Result != "" {
+		query = query.Where("result = ?", filter.Result)
+	}
+	if filter.From > 0 {
+		query = query.Where("event_time >= ?", time.Unix(filter.From, 0).UTC())
+	}
+	if filter.To > 0 {
+		query = query.Where("event_time <= ?", time.Unix(filter.To, 0).UTC())
+	}
+	result := query.Order("event_time desc, id desc").Limit(limit).Offset(filter.Offset).Find(&aeList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return aeList, nil
+}
+
+// Record access event and move its person in or out of the room of the doorlock. Direction defaults to
+// the reader direction of the doorlock, events on doorlocks without direction or room are only recorded.
+// Re-entry without exit, exit without entry and entry into a full room are anti-passback violations
+func (ocs *OccupancySvc) RecordAccessEvent(ctx context.Context, ae *AccessEvent) (*AccessEvent, error) {
+	err := conn(ctx, ocs.db).Transaction(func(tx *gorm.DB) error {
+		dl := &Doorlock{}
+		if err := tx.First(dl, ae.DoorID).Error; err != nil {
+			return err
+		}
+		ae.RoomID, ae.BlockID = dl.RoomId, dl.BlockId
+		if ae.Direction == "" {
+			ae.Direction = dl.ReaderDirection
+		}
+		if ae.Direction != "" && ae.Direction != READER_DIRECTION_ENTRY && ae.Direction != READER_DIRECTION_EXIT {
+			return fmt.Errorf("direction must be %s or %s", READER_DIRECTION_ENTRY, READER_DIRECTION_EXIT)
+		}
+		if ae.Result != ACCESS_RESULT_DENIED {
+			ae.Result = ACCESS_RESULT_GRANTED
+		}
+		if ae.EventTime.IsZero() {
+			ae.EventTime = time.Now()
+		}
+		p := &Person{}
+		found := tx.Where("user_id = ?", ae.UserID).Order("id").Limit(1).Find(p)
+		if found.Error != nil {
+			return found.Error
+		}
+		if found.RowsAffected > 0 {
+			ae.PersonID = &p.ID
+		}
+
+		if ae.Result == ACCESS_RESULT_GRANTED && ae.PersonID != nil && ae.RoomID != "" && ae.Direction != "" {
+			if err := movePerson(tx, ae); err != nil {
+				return err
+			}
+		}
+		return tx.Create(ae).Error
+	})
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return ae, nil
+}
+
+// Update presence for a granted event, violations of rooms with anti-passback are recorded as warned
+func movePerson(tx *gorm.DB, ae *AccessEvent) error {
+	mode, err := roomAntiPassback(tx, ae.RoomID)
+	if err != nil {
+		return err
+	}
+	var inside int64
+	presence := tx.Model(&RoomPresence{}).Where("room_id = ? AND person_id = ?", ae.RoomID, *ae.PersonID)
+	if err := presence.Count(&inside).Error; err != nil {
+		return err
+	}
+
+	violation := ""
+	if ae.Direction == READER_DIRECTION_EXIT {
+		if inside == 0 {
+			violation = "exit without entry"
+		}
+		if err := tx.Where("room_id = ? AND person_id = ?", ae.RoomID, *ae.PersonID).Delete(&RoomPresence{}).Error; err != nil {
+			return err
+		}
+	} else {
+		if inside > 0 {
+			violation = "entry without exit"
+		} else {
+			occupancy, capacity, err := roomLoad(tx, ae.RoomID)
+			if err != nil {
+				return err
+			}
+			if capacity > 0 && occupancy >= int64(capacity) {
+				violation = "room is full"
+			}
+		}
+		rp := &RoomPresence{RoomID: ae.RoomID, PersonID: *ae.PersonID, UserID: ae.UserID, BlockID: ae.BlockID, EnteredAt: ae.EventTime}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "room_id"}, {Name: "person_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"entered_at", "updated_at"}),
+		}).Create(rp).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Hard mode is enforced by gateways, violations still reaching the server are only warned
+	if violation != "" && mode != ANTI_PASSBACK_OFF {
+		ae.Result, ae.Reason = ACCESS_RESULT_WARNED, violation
+	}
+	return nil
+}
+
+func roomAntiPassback(tx *gorm.DB, roomID string) (string, error) {
+	rs := &RoomSetting{}
+	result := tx.Where("room_id = ?", roomID).Limit(1).Find(rs)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ANTI_PASSBACK_OFF, nil
+	}
+	return rs.AntiPassback, nil
+}
+
+// People inside the room and its capacity, taken from schedulers of the room or of its doorlocks
+func roomLoad(tx *gorm.DB, roomID string) (int64, uint, error) {
+	var occupancy int64
+	if err := tx.Model(&RoomPresence{}).Where("room_id = ?", roomID).Count(&occupancy).Error; err != nil {
+		return 0, 0, err
+	}
+	var capacity uint
+	roomDoorIDs := tx.Model(&Doorlock{}).Select("id").Where("room_id = ?", roomID)
+	err := tx.Model(&Scheduler{}).Select("COALESCE(MAX(capacity), 0)").
+		Where("room_id = ? OR door_id IN (?)", roomID, roomDoorIDs).Scan(&capacity).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return occupancy, capacity, nil
+}
+
+// Live occupancy of every room with a reader doorlock or someone inside
+func (ocs *OccupancySvc) FindAllRoomOccupancy(ctx context.Context) (roList []RoomOccupancy, err error) {
+	rooms := map[string]string{}
+	dlList := []Doorlock{}
+	if err := conn(ctx, ocs.db).Where("room_id <> '' AND reader_direction <> ''").Order("id").Find(&dlList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	for _, dl := range dlList {
+		if _, ok := rooms[dl.RoomId]; !ok {
+			rooms[dl.RoomId] = dl.BlockId
+		}
+	}
+	rpList := []RoomPresence{}
+	if err := conn(ctx, ocs.db).Select("room_id", "block_id").Find(&rpList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	for _, rp := range rpList {
+		if _, ok := rooms[rp.RoomID]; !ok {
+			rooms[rp.RoomID] = rp.BlockID
+		}
+	}
+
+	roomIDs := []string{}
+	for roomID := range rooms {
+		roomIDs = append(roomIDs, roomID)
+	}
+	sort.Strings(roomIDs)
+	for _, roomID := range roomIDs {
+		ro, err := roomOccupancy(conn(ctx, ocs.db), roomID, rooms[roomID])
+		if err != nil {
+			return nil, utils.HandleQueryError(err)
+		}
+		roList = append(roList, *ro)
+	}
+	return roList, nil
+}
+
+// Live occupancy of the room with the people inside, oldest entry first
+func (ocs *OccupancySvc) FindRoomOccupancy(ctx context.Context, roomID string) (*RoomOccupancy, error) {
+	dl := &Doorlock{}
+	if err := conn(ctx, ocs.db).Where("room_id = ?", roomID).Limit(1).Find(dl).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	ro, err := roomOccupancy(conn(ctx, ocs.db), roomID, dl.BlockId)
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if err := conn(ctx, ocs.db).Where("room_id = ?", roomID).Order("entered_at, id").Find(&ro.People).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return ro, nil
+}
+
+func roomOccupancy(db *gorm.DB, roomID string, blockID string) (*RoomOccupancy, error) {
+	occupancy, capacity, err := roomLoad(db, roomID)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := roomAntiPassback(db, roomID)
+	if err != nil {
+		return nil, err
+	}
+	return &RoomOccupancy{
+		RoomID:       roomID,
+		BlockID:      blockID,
+		Occupancy:    occupancy,
+		Capacity:     capacity,
+		Full:         capacity > 0 && occupancy >= int64(capacity),
+		AntiPassback: mode,
+	}, nil
+}
+
+// Live occupancy of every building, rooms are grouped by the blockId of their doorlocks
+func (ocs *OccupancySvc) FindAllBuildingOccupancy(ctx context.Context) (boList []BuildingOccupancy, err error) {
+	roList, err := ocs.FindAllRoomOccupancy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buildings := map[string]*BuildingOccupancy{}
+	for _, ro := range roList {
+		bo, ok := buildings[ro.BlockID]
+		if !ok {
+			bo = &BuildingOccupancy{BlockID: ro.BlockID}
+			buildings[ro.BlockID] = bo
+		}
+		bo.Rooms++
+		bo.Occupancy += ro.Occupancy
+		bo.Capacity += ro.Capacity
+	}
+	for _, bo := range buildings {
+		boList = append(boList, *bo)
+	}
+	sort.Slice(boList, func(i, j int) bool { return boList[i].BlockID < boList[j].BlockID })
+	return boList, nil
+}
+
+// Create or update anti-passback mode of the room
+func (ocs *OccupancySvc) UpdateRoomSetting(ctx context.Context, rs *RoomSetting) (*RoomSetting, error) {
+	if rs.AntiPassback != ANTI_PASSBACK_OFF && rs.AntiPassback != ANTI_PASSBACK_SOFT && rs.AntiPassback != ANTI_PASSBACK_HARD {
+		return nil, fmt.Errorf("antiPassback must be %s, %s or %s", ANTI_PASSBACK_OFF, ANTI_PASSBACK_SOFT, ANTI_PASSBACK_HARD)
+	}
+	err := conn(ctx, ocs.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"anti_passback", "updated_at"}),
+	}).Create(rs).Error
+	if err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if err := conn(ctx, ocs.db).Where("room_id = ?", rs.RoomID).First(rs).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	return rs, nil
+}
+
+// Empty the room, used when people left without passing an exit reader
+func (ocs *OccupancySvc) ResetRoomOccupancy(ctx context.Context, roomID string) (bool, error) {
+	result := conn(ctx, ocs.db).Where("room_id = ?", roomID).Delete(&RoomPresence{})
+	return utils.ReturnBoolStateFromResult(result)
+}
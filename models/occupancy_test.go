@@ -0,0 +1,80 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordAccessEvent(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss, gs, dls, ocs := NewStudentSvc(db), NewGatewaySvc(db), NewDoorlockSvc(db), NewOccupancySvc(db)
+
+	s1, _ := ss.CreateStudent(ctx, &Student{MSSV: "s1", Email: "s1@mail", Major: "it"})
+	s2, _ := ss.CreateStudent(ctx, &Student{MSSV: "s2", Email: "s2@mail", Major: "it"})
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	in, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "in", GatewayID: "gw-1", DoorlockAddress: "1",
+		BlockId: "b1", RoomId: "r1", ReaderDirection: READER_DIRECTION_ENTRY})
+	out, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "out", GatewayID: "gw-1", DoorlockAddress: "2",
+		BlockId: "b1", RoomId: "r1", ReaderDirection: READER_DIRECTION_EXIT})
+	NewSchedulerSvc(db).CreateScheduler(ctx, &Scheduler{DoorID: in.ID, RoomID: "r1", Capacity: 1, UserID: "s1"})
+
+	record := func(door *Doorlock, userID string, result string) *AccessEvent {
+		t.Helper()
+		ae, err := ocs.RecordAccessEvent(ctx, &AccessEvent{GatewayID: "gw-1", DoorID: door.ID, UserID: userID, Result: result})
+		if err != nil {
+			t.Fatalf("record access event failed: %v", err)
+		}
+		return ae
+	}
+
+	if ae := record(in, "s1", ""); ae.Result != ACCESS_RESULT_GRANTED || ae.RoomID != "r1" || *ae.PersonID != *s1.PersonID {
+		t.Fatalf("expected granted entry of s1 into r1, got %+v", ae)
+	}
+	// Anti-passback is off by default
+	if ae := record(in, "s1", ""); ae.Result != ACCESS_RESULT_GRANTED {
+		t.Fatalf("expected granted re-entry without anti-passback, got %+v", ae)
+	}
+
+	if _, err := ocs.UpdateRoomSetting(ctx, &RoomSetting{RoomID: "r1", AntiPassback: "strict"}); err == nil {
+		t.Fatalf("expected error on unknown anti-passback mode")
+	}
+	if _, err := ocs.UpdateRoomSetting(ctx, &RoomSetting{RoomID: "r1", AntiPassback: ANTI_PASSBACK_SOFT}); err != nil {
+		t.Fatalf("update room setting failed: %v", err)
+	}
+	if ae := record(in, "s2", ""); ae.Result != ACCESS_RESULT_WARNED || ae.Reason != "room is full" {
+		t.Fatalf("expected warned entry into full room, got %+v", ae)
+	}
+	if ae := record(out, "s1", ""); ae.Result != ACCESS_RESULT_GRANTED {
+		t.Fatalf("expected granted exit, got %+v", ae)
+	}
+	if ae := record(out, "s1", ""); ae.Result != ACCESS_RESULT_WARNED || ae.Reason != "exit without entry" {
+		t.Fatalf("expected warned exit without entry, got %+v", ae)
+	}
+	// Denied events do not move people
+	record(out, "s2", ACCESS_RESULT_DENIED)
+
+	ro, err := ocs.FindRoomOccupancy(ctx, "r1")
+	if err != nil || ro.Occupancy != 1 || ro.Capacity != 1 || !ro.Full || ro.AntiPassback != ANTI_PASSBACK_SOFT ||
+		len(ro.People) != 1 || ro.People[0].PersonID != *s2.PersonID {
+		t.Fatalf("expected s2 alone in full room, got %+v, %v", ro, err)
+	}
+	boList, _ := ocs.FindAllBuildingOccupancy(ctx)
+	if len(boList) != 1 || boList[0].BlockID != "b1" || boList[0].Rooms != 1 || boList[0].Occupancy != 1 {
+		t.Fatalf("expected 1 person in b1, got %+v", boList)
+	}
+	aeList, _ := ocs.FindAccessEvents(ctx, &AccessEventFilter{RoomID: "r1", Result: ACCESS_RESULT_WARNED})
+	if len(aeList) != 2 {
+		t.Fatalf("expected 2 warned events, got %d", len(aeList))
+	}
+
+	if _, err := ocs.ResetRoomOccupancy(ctx, "r1"); err != nil {
+		t.Fatalf("reset room occupancy failed: %v", err)
+	}
+	if roList, _ := ocs.FindAllRoomOccupancy(ctx); len(roList) != 1 || roList[0].Occupancy != 0 || roList[0].Full {
+		t.Fatalf("expected empty room after reset, got %+v", roList)
+	}
+}
@@ -0,0 +1,372 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	PERSON_TYPE_STUDENT  string = "student"
+	PERSON_TYPE_EMPLOYEE string = "employee"
+	PERSON_TYPE_CUSTOMER string = "customer"
+
+	CREDENTIAL_TYPE_RFID   string = "rfid"
+	CREDENTIAL_TYPE_KEYPAD string = "keypad"
+
+	CREDENTIAL_STATUS_ACTIVE    string = "active"
+	CREDENTIAL_STATUS_LOST      string = "lost"
+	CREDENTIAL_STATUS_SUSPENDED string = "suspended"
+	// Set by revocation only, the value is refused by gateways and can not be used again
+	CREDENTIAL_STATUS_BLACKLISTED string = "blacklisted"
+
+	// Gateways do not know validity dates, people whose credentials passed one are sent again this often
+	CREDENTIAL_VALIDITY_PERIOD time.Duration = time.Minute
+)
+
+// Identity shared by student, employee and customer profiles, UserID is the MSSV, MSNV or CCCD of the profile
+type Person struct {
+	GormModel
+	SoftDelete
+	Type        string       `gorm:"type:varchar(50);not null;uniqueIndex:idx_people_type_user_id" json:"type"`
+	UserID      string       `gorm:"type:varchar(256);not null;uniqueIndex:idx_people_type_user_id" json:"userId"`
+	Name        string       `json:"name"`
+	Credentials []Credential `gorm:"constraint:OnDelete:CASCADE;" json:"credentials"`
+}
+
+// RFID card or keypad PIN of a person, only active credentials inside their validity dates open doors
+type Credential struct {
+	GormModel
+	PersonID  uint       `gorm:"not null;index" json:"personId"`
+	Type      string     `gorm:"type:varchar(50);not null" json:"type"`
+	Value     string     `gorm:"type:varchar(256);not null" json:"value"`
+	Status    string     `gorm:"type:varchar(50);not null" json:"status"`
+	ValidFrom *time.Time `json:"validFrom"`
+	ValidTo   *time.Time `json:"validTo"`
+	// Update only, removes both validity dates. Dates not given are kept otherwise
+	ClearValidity bool `gorm:"-" json:"clearValidity,omitempty"`
+}
+
+// Credential can be used to open doors at the given time
+func (c *Credential) IsUsable(now time.Time) bool {
+	return c.Status == CREDENTIAL_STATUS_ACTIVE &&
+		(c.ValidFrom == nil || !now.Before(*c.ValidFrom)) &&
+		(c.ValidTo == nil || !now.After(*c.ValidTo))
+}
+
+// Values of usable credentials of a type, oldest first
+func (p *Person) UsableCredentials(credType string, now time.Time) []string {
+	values := []string{}
+	if p == nil {
+		return values
+	}
+	for i := range p.Credentials {
+		if p.Credentials[i].Type == credType && p.Credentials[i].IsUsable(now) {
+			values = append(values, p.Credentials[i].Value)
+		}
+	}
+	return values
+}
+
+// First usable credential of each type, shown as rfidPass and keypadPass of user profiles
+func (p *Person) PrimaryUserPass(now time.Time) UserPass {
+	up := UserPass{}
+	if rfids := p.UsableCredentials(CREDENTIAL_TYPE_RFID, now); len(rfids) > 0 {
+		up.RfidPass = rfids[0]
+	}
+	if keypads := p.UsableCredentials(CREDENTIAL_TYPE_KEYPAD, now); len(keypads) > 0 {
+		up.KeypadPass = keypads[0]
+	}
+	return up
+}
+
+// People and their credentials
+type PersonService interface {
+	FindAllPerson(ctx context.Context) (pList []Person, err error)
+	FindPersonByID(ctx context.Context, id string) (p *Person, err error)
+	FindCredentialByID(ctx context.Context, id string) (c *Credential, err error)
+	CreateCredential(ctx context.Context, c *Credential) (*Credential, error)
+	UpdateCredential(ctx context.Context, c *Credential) (bool, error)
+	DeleteCredential(ctx context.Context, id uint) (bool, error)
+	FindValidityChangedPersonIDs(ctx context.Context, from time.Time, to time.Time) ([]uint, error)
+}
+
+type PersonSvc struct {
+	db *gorm.DB
+}
+
+func NewPersonSvc(db *gorm.DB) *PersonSvc {
+	return &PersonSvc{
+		db: db,
+	}
+}
+
+func (ps *PersonSvc) FindAllPerson(ctx context.Context) (pList []Person, err error) {
+	result := conn(ctx, ps.db).Preload("Credentials").Find(&pList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return pList, nil
+}
+
+func (ps *PersonSvc) FindPersonByID(ctx context.Context, id string) (p *Person, err error) {
+	result := conn(ctx, ps.db).Preload("Credentials").First(&p, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (ps *PersonSvc) FindCredentialByID(ctx context.Context, id string) (c *Credential, err error) {
+	result := conn(ctx, ps.db).First(&c, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (ps *PersonSvc) CreateCredential(ctx context.Context, c *Credential) (*Credential, error) {
+	if c.Status == "" {
+		c.Status = CREDENTIAL_STATUS_ACTIVE
+	}
+	if err := validateCredential(conn(ctx, ps.db), c); err != nil {
+		return nil, err
+	}
+	if err := conn(ctx, ps.db).Create(c).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update credential value, status and validity dates, fields not given are kept. The owner and type never change
+func (ps *PersonSvc) UpdateCredential(ctx context.Context, c *Credential) (bool, error) {
+	found, err := ps.FindCredentialByID(ctx, fmt.Sprint(c.ID))
+	if err != nil {
+		return false, err
+	}
+	if found.Status == CREDENTIAL_STATUS_BLACKLISTED {
+		return false, fmt.Errorf("blacklisted credential can not be changed")
+	}
+	if c.Status == CREDENTIAL_STATUS_BLACKLISTED {
+		return false, fmt.Errorf("use credential revoke to blacklist a credential")
+	}
+	updated := *found
+	if c.Value != "" {
+		updated.Value = c.Value
+	}
+	if c.Status != "" {
+		updated.Status = c.Status
+	}
+	if c.ClearValidity {
+		updated.ValidFrom, updated.ValidTo = nil, nil
+	}
+	if c.ValidFrom != nil {
+		updated.ValidFrom = c.ValidFrom
+	}
+	if c.ValidTo != nil {
+		updated.ValidTo = c.ValidTo
+	}
+	if err := validateCredential(conn(ctx, ps.db), &updated); err != nil {
+		return false, err
+	}
+
+	result := conn(ctx, ps.db).Model(found).Select("Value", "Status", "ValidFrom", "ValidTo").Updates(&updated)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (ps *PersonSvc) DeleteCredential(ctx context.Context, id uint) (bool, error) {
+	result := conn(ctx, ps.db).Where("id = ?", id).Delete(&Credential{})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// People not deleted with an active credential becoming valid or expiring after from until to, ValidTo is the last usable time
+func (ps *PersonSvc) FindValidityChangedPersonIDs(ctx context.Context, from time.Time, to time.Time) ([]uint, error) {
+	var personIDs []uint
+	result := conn(ctx, ps.db).Model(&Credential{}).
+		Joins("JOIN people ON people.id = credentials.person_id AND people.deleted_at IS NULL").
+		Where("credentials.status = ?", CREDENTIAL_STATUS_ACTIVE).
+		Where("(credentials.valid_from > ? AND credentials.valid_from <= ?) OR (credentials.valid_to >= ? AND credentials.valid_to < ?)", from, to, from, to).
+		Distinct().Order("credentials.person_id").Pluck("credentials.person_id", &personIDs)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return personIDs, nil
+}
+
+// An active RFID card belongs to one person only, blacklisted cards can not be used again
+func validateCredential(db *gorm.DB, c *Credential) error {
+	if c.Type != CREDENTIAL_TYPE_RFID && c.Type != CREDENTIAL_TYPE_KEYPAD {
+		return fmt.Errorf("credential type must be %s or %s", CREDENTIAL_TYPE_RFID, CREDENTIAL_TYPE_KEYPAD)
+	}
+	if c.Status != CREDENTIAL_STATUS_ACTIVE && c.Status != CREDENTIAL_STATUS_LOST &&
+		c.Status != CREDENTIAL_STATUS_SUSPENDED && c.Status != CREDENTIAL_STATUS_BLACKLISTED {
+		return fmt.Errorf("credential status must be %s, %s, %s or %s", CREDENTIAL_STATUS_ACTIVE, CREDENTIAL_STATUS_LOST,
+			CREDENTIAL_STATUS_SUSPENDED, CREDENTIAL_STATUS_BLACKLISTED)
+	}
+	if c.Value == "" {
+		return fmt.Errorf("credential value is required")
+	}
+	if c.ValidFrom != nil && c.ValidTo != nil && c.ValidTo.Before(*c.ValidFrom) {
+		return fmt.Errorf("validTo must be after validFrom")
+	}
+	if c.Type == CREDENTIAL_TYPE_RFID && c.Status == CREDENTIAL_STATUS_ACTIVE {
+		var cnt int64
+		err := db.Model(&Credential{}).
+			Where("type = ? AND value = ? AND status = ? AND person_id <> ?", c.Type, c.Value, CREDENTIAL_STATUS_ACTIVE, c.PersonID).
+			Count(&cnt).Error
+		if err != nil {
+			return utils.HandleQueryError(err)
+		}
+		if cnt > 0 {
+			return fmt.Errorf("rfid card is active for another person")
+		}
+	}
+	// Lost card may be found by someone else, keypad PINs are not unique so they are never blocked
+	if c.Type == CREDENTIAL_TYPE_RFID && c.Status == CREDENTIAL_STATUS_ACTIVE {
+		var cnt int64
+		err := db.Model(&Credential{}).
+			Where("type = ? AND value = ? AND status = ?", c.Type, c.Value, CREDENTIAL_STATUS_BLACKLISTED).
+			Count(&cnt).Error
+		if err != nil {
+			return utils.HandleQueryError(err)
+		}
+		if cnt > 0 {
+			return fmt.Errorf("rfid card is blacklisted")
+		}
+	}
+	return nil
+}
+
+// Student, employee and customer profiles linked to a person
+type personProfile interface {
+	personInfo() (name string, up UserPass)
+}
+
+// Create person of a profile before inserting it, unless the profile is linked to a person already
+func linkPerson(tx *gorm.DB, personID **uint, person **Person, personType string, userID string, name string, up UserPass) error {
+	// Person is saved here, never as an association of the profile
+	*person = nil
+	if *personID != nil {
+		return nil
+	}
+	p, err := createPerson(tx, personType, userID, name, up)
+	if err != nil {
+		return err
+	}
+	*personID = &p.ID
+	return nil
+}
+
+// Load person of a profile with its credentials
+func loadPerson(tx *gorm.DB, personID *uint) (*Person, error) {
+	if personID == nil {
+		return nil, nil
+	}
+	p := &Person{}
+	if err := tx.Preload("Credentials").First(p, *personID).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Create person identity of a new profile, user password fields become its first credentials
+func createPerson(tx *gorm.DB, personType string, userID string, name string, up UserPass) (*Person, error) {
+	p := &Person{Type: personType, UserID: userID, Name: name}
+	if err := tx.Create(p).Error; err != nil {
+		return nil, err
+	}
+	if err := setPrimaryCredentials(tx, p.ID, up); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Keep person name and first credentials in sync with an updated profile, empty values are left untouched
+func syncPerson(tx *gorm.DB, profile interface{}, name string, up UserPass, query interface{}, args ...interface{}) error {
+	var personID *uint
+	if err := tx.Model(profile).Select("person_id").Where(query, args...).Limit(1).Scan(&personID).Error; err != nil {
+		return err
+	}
+	if personID == nil {
+		return nil
+	}
+	if name != "" {
+		if err := tx.Model(&Person{}).Where("id = ?", *personID).Update("name", name).Error; err != nil {
+			return err
+		}
+	}
+	return setPrimaryCredentials(tx, *personID, up)
+}
+
+// Replace value of the first active credential of each type, create it when missing
+func setPrimaryCredentials(tx *gorm.DB, personID uint, up UserPass) error {
+	for _, cred := range []Credential{
+		{Type: CREDENTIAL_TYPE_RFID, Value: up.RfidPass},
+		{Type: CREDENTIAL_TYPE_KEYPAD, Value: up.KeypadPass},
+	} {
+		if cred.Value == "" {
+			continue
+		}
+		primary := &Credential{}
+		result := tx.Where("person_id = ? AND type = ? AND status = ?", personID, cred.Type, CREDENTIAL_STATUS_ACTIVE).
+			Order("id").Limit(1).Find(primary)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		cred.PersonID = personID
+		cred.Status = CREDENTIAL_STATUS_ACTIVE
+		if result.RowsAffected > 0 {
+			cred.ID = primary.ID
+		}
+		if err := validateCredential(tx, &cred); err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Create(&cred).Error; err != nil {
+				return err
+			}
+		} else if primary.Value != cred.Value {
+			if err := tx.Model(primary).Update("value", cred.Value).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Soft delete profile together with its person
+func softDeleteProfile(db *gorm.DB, profile interface{}, deletedBy string, query interface{}, args ...interface{}) (bool, error) {
+	isSuccess := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if isSuccess, err = utils.ReturnBoolStateFromResult(softDelete(tx, profile, deletedBy, query, args...)); err != nil {
+			return err
+		}
+		personIDs := tx.Unscoped().Model(profile).Select("person_id").Where(query, args...)
+		return softDelete(tx, &Person{}, deletedBy, "id IN (?)", personIDs).Error
+	})
+	return isSuccess, err
+}
+
+// Restore profile together with its person
+func restoreProfile(db *gorm.DB, profile interface{}, query interface{}, args ...interface{}) (bool, error) {
+	isSuccess := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if isSuccess, err = utils.ReturnBoolStateFromResult(restoreDeleted(tx, profile, query, args...)); err != nil {
+			return err
+		}
+		personIDs := tx.Unscoped().Model(profile).Select("person_id").Where(query, args...)
+		return restoreDeleted(tx, &Person{}, "id IN (?)", personIDs).Error
+	})
+	return isSuccess, err
+}
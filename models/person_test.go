@@ -0,0 +1,136 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPersonUsableCredentials(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	p := &Person{Credentials: []Credential{
+		{Type: CREDENTIAL_TYPE_RFID, Value: "lost", Status: CREDENTIAL_STATUS_LOST},
+		{Type: CREDENTIAL_TYPE_RFID, Value: "card1", Status: CREDENTIAL_STATUS_ACTIVE, ValidFrom: &past},
+		{Type: CREDENTIAL_TYPE_RFID, Value: "expired", Status: CREDENTIAL_STATUS_ACTIVE, ValidTo: &past},
+		{Type: CREDENTIAL_TYPE_RFID, Value: "later", Status: CREDENTIAL_STATUS_ACTIVE, ValidFrom: &future},
+		{Type: CREDENTIAL_TYPE_RFID, Value: "card2", Status: CREDENTIAL_STATUS_ACTIVE, ValidTo: &future},
+		{Type: CREDENTIAL_TYPE_KEYPAD, Value: "1234", Status: CREDENTIAL_STATUS_ACTIVE},
+	}}
+
+	if rfids := p.UsableCredentials(CREDENTIAL_TYPE_RFID, now); !reflect.DeepEqual(rfids, []string{"card1", "card2"}) {
+		t.Fatalf("unexpected usable rfid cards %v", rfids)
+	}
+	if up := p.PrimaryUserPass(now); up.RfidPass != "card1" || up.KeypadPass != "1234" {
+		t.Fatalf("unexpected primary user pass %+v", up)
+	}
+
+	var noPerson *Person
+	if up := noPerson.PrimaryUserPass(now); up.RfidPass != "" || up.KeypadPass != "" {
+		t.Fatalf("expected empty user pass without person, got %+v", up)
+	}
+}
+
+func TestStudentPerson(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss := NewStudentSvc(db)
+	ps := NewPersonSvc(db)
+
+	s, err := ss.CreateStudent(ctx, &Student{MSSV: "s1", Name: "A", Email: "s1@mail", Major: "it",
+		UserPass: UserPass{RfidPass: "card1", KeypadPass: "1234"}})
+	if err != nil {
+		t.Fatalf("create student failed: %v", err)
+	}
+	if s.PersonID == nil || s.Person == nil || len(s.Person.Credentials) != 2 {
+		t.Fatalf("expected person with 2 credentials, got %+v", s.Person)
+	}
+	personID := *s.PersonID
+
+	// Legacy password fields replace the first credential, others are kept
+	if _, err := ps.CreateCredential(ctx, &Credential{PersonID: personID, Type: CREDENTIAL_TYPE_RFID, Value: "card2"}); err != nil {
+		t.Fatalf("create credential failed: %v", err)
+	}
+	s.Name = "B"
+	s.UserPass = UserPass{RfidPass: "card3"}
+	if _, err := ss.UpdateStudent(ctx, s); err != nil {
+		t.Fatalf("update student failed: %v", err)
+	}
+	s, _ = ss.FindStudentByMSSV(ctx, "s1")
+	now := time.Now()
+	if rfids := s.Person.UsableCredentials(CREDENTIAL_TYPE_RFID, now); !reflect.DeepEqual(rfids, []string{"card3", "card2"}) {
+		t.Fatalf("unexpected rfid cards after update %v", rfids)
+	}
+	if s.RfidPass != "card3" || s.KeypadPass != "1234" || s.Person.Name != "B" {
+		t.Fatalf("unexpected student after update %+v", s)
+	}
+
+	// Active RFID card can not be shared
+	ss.CreateStudent(ctx, &Student{MSSV: "s2", Email: "s2@mail", Major: "it"})
+	s2, _ := ss.FindStudentByMSSV(ctx, "s2")
+	if _, err := ps.CreateCredential(ctx, &Credential{PersonID: *s2.PersonID, Type: CREDENTIAL_TYPE_RFID, Value: "card2"}); err == nil {
+		t.Fatalf("expected error on rfid card active for another person")
+	}
+	if _, err := ps.CreateCredential(ctx, &Credential{PersonID: *s2.PersonID, Type: "face", Value: "x"}); err == nil {
+		t.Fatalf("expected error on unknown credential type")
+	}
+
+	// Suspended credential is no longer sent
+	card2 := s.Person.Credentials[len(s.Person.Credentials)-1]
+	if _, err := ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card2.ID}, Status: CREDENTIAL_STATUS_SUSPENDED}); err != nil {
+		t.Fatalf("update credential failed: %v", err)
+	}
+	p, _ := ps.FindPersonByID(ctx, fmt.Sprint(personID))
+	if rfids := p.UsableCredentials(CREDENTIAL_TYPE_RFID, now); !reflect.DeepEqual(rfids, []string{"card3"}) {
+		t.Fatalf("unexpected rfid cards after suspend %v", rfids)
+	}
+
+	// Validity dates are kept by updates not giving them, removed on request only
+	validTo := now.Add(time.Hour)
+	if _, err := ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card2.ID}, ValidTo: &validTo}); err != nil {
+		t.Fatalf("update credential failed: %v", err)
+	}
+	if _, err := ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card2.ID}, Status: CREDENTIAL_STATUS_ACTIVE}); err != nil {
+		t.Fatalf("update credential failed: %v", err)
+	}
+	if c, _ := ps.FindCredentialByID(ctx, fmt.Sprint(card2.ID)); c.ValidTo == nil || !c.ValidTo.Equal(validTo) || c.Status != CREDENTIAL_STATUS_ACTIVE {
+		t.Fatalf("got %+v, wanted active credential valid to %v", c, validTo)
+	}
+	if _, err := ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card2.ID}, ClearValidity: true}); err != nil {
+		t.Fatalf("update credential failed: %v", err)
+	}
+	if c, _ := ps.FindCredentialByID(ctx, fmt.Sprint(card2.ID)); c.ValidTo != nil || c.Status != CREDENTIAL_STATUS_ACTIVE {
+		t.Fatalf("got %+v, wanted active credential without validity", c)
+	}
+	ps.UpdateCredential(ctx, &Credential{GormModel: GormModel{ID: card2.ID}, Status: CREDENTIAL_STATUS_SUSPENDED})
+
+	// Scheduler is linked to the person of its user
+	NewGatewaySvc(db).CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	dl, _ := NewDoorlockSvc(db).CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d1", GatewayID: "gw-1", DoorlockAddress: "1"})
+	sche, err := NewSchedulerSvc(db).CreateScheduler(ctx, &Scheduler{DoorID: dl.ID, Role: PERSON_TYPE_STUDENT, UserID: "s1"})
+	if err != nil {
+		t.Fatalf("create scheduler failed: %v", err)
+	}
+	if sche.PersonID == nil || *sche.PersonID != personID {
+		t.Fatalf("expected scheduler linked to person %d, got %v", personID, sche.PersonID)
+	}
+
+	// Person follows soft delete and restore of the student
+	if _, err := ss.DeleteStudent(ctx, "s1", "admin"); err != nil {
+		t.Fatalf("delete student failed: %v", err)
+	}
+	if _, err := ps.FindPersonByID(ctx, fmt.Sprint(personID)); err == nil {
+		t.Fatalf("expected person deleted with student")
+	}
+	if _, err := ss.RestoreStudent(ctx, "s1"); err != nil {
+		t.Fatalf("restore student failed: %v", err)
+	}
+	if _, err := ps.FindPersonByID(ctx, fmt.Sprint(personID)); err != nil {
+		t.Fatalf("expected person restored with student, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RECURRENCE_FREQ_DAILY  string = "DAILY"
+	RECURRENCE_FREQ_WEEKLY string = "WEEKLY"
+
+	DEFAULT_POLICY_TIME_ZONE string = "Asia/Ho_Chi_Minh"
+)
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Subset of RFC 5545 RRULE: FREQ=DAILY|WEEKLY with optional INTERVAL and BYDAY (weekly only).
+// Start and end of the recurrence are the policy dates, weeks start on Monday
+type Recurrence struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+}
+
+// Daily time window in minutes of the day, End is exclusive and may be 24:00
+type TimeWindow struct {
+	Start int
+	End   int
+}
+
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid recurrence part %q", part)
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			if value != RECURRENCE_FREQ_DAILY && value != RECURRENCE_FREQ_WEEKLY {
+				return nil, fmt.Errorf("recurrence FREQ must be %s or %s", RECURRENCE_FREQ_DAILY, RECURRENCE_FREQ_WEEKLY)
+			}
+			r.Freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("recurrence INTERVAL must be a positive number")
+			}
+			r.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := recurrenceWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("invalid recurrence BYDAY %q", day)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence part %s", key)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("recurrence FREQ is required")
+	}
+	if r.Freq == RECURRENCE_FREQ_DAILY && len(r.ByDay) > 0 {
+		return nil, fmt.Errorf("recurrence BYDAY needs FREQ=%s", RECURRENCE_FREQ_WEEKLY)
+	}
+	return r, nil
+}
+
+// Canonical form of the rule, sent to gateways
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := []string{}
+		for _, wd := range r.ByDay {
+			for name, d := range recurrenceWeekdays {
+				if d == wd {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Recurrence occurs on the day of date, start is the first day of the recurrence. Both are local dates
+func (r *Recurrence) OccursOn(start time.Time, date time.Time) bool {
+	days := daysBetween(start, date)
+	if days < 0 {
+		return false
+	}
+	if r.Freq == RECURRENCE_FREQ_DAILY {
+		return days%r.Interval == 0
+	}
+
+	byDay := r.ByDay
+	if len(byDay) == 0 {
+		byDay = []time.Weekday{start.Weekday()}
+	}
+	matched := false
+	for _, wd := range byDay {
+		if wd == date.Weekday() {
+			matched = true
+		}
+	}
+	weeks := daysBetween(startOfWeek(start), startOfWeek(date)) / 7
+	return matched && weeks%r.Interval == 0
+}
+
+// Parse comma separated windows like "07:00-12:00,13:00-19:00"
+func ParseTimeWindows(windows string) ([]TimeWindow, error) {
+	twList := []TimeWindow{}
+	for _, w := range strings.Split(windows, ",") {
+		bounds := strings.Split(strings.TrimSpace(w), "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("time window %q must be HH:MM-HH:MM", w)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("time window %q must end after it starts", w)
+		}
+		twList = append(twList, TimeWindow{Start: start, End: end})
+	}
+	return twList, nil
+}
+
+func (tw TimeWindow) String() string {
+	return fmt.Sprintf("%s-%s", FormatClock(tw.Start), FormatClock(tw.End))
+}
+
+// Minutes of the day from HH:MM, 24:00 is the end of the day
+func parseClock(clock string) (int, error) {
+	hm := strings.Split(strings.TrimSpace(clock), ":")
+	if len(hm) != 2 {
+		return 0, fmt.Errorf("time %q must be HH:MM", clock)
+	}
+	h, errH := strconv.Atoi(hm[0])
+	m, errM := strconv.Atoi(hm[1])
+	if errH != nil || errM != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("time %q must be HH:MM", clock)
+	}
+	return h*60 + m, nil
+}
+
+// HH:MM from minutes of the day
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func daysBetween(from time.Time, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset)
+}
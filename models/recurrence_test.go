@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	cases := []struct {
+		rule string
+		want string
+		ok   bool
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY", true},
+		{"RRULE:freq=weekly;byday=mo,tu,we,th,fr", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", true},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=SA", "FREQ=WEEKLY;INTERVAL=2;BYDAY=SA", true},
+		{"FREQ=WEEKLY;INTERVAL=1", "FREQ=WEEKLY", true},
+		{"", "", false},
+		{"BYDAY=MO", "", false},
+		{"FREQ=MONTHLY", "", false},
+		{"FREQ=DAILY;BYDAY=MO", "", false},
+		{"FREQ=WEEKLY;BYDAY=1MO", "", false},
+		{"FREQ=WEEKLY;INTERVAL=0", "", false},
+		{"FREQ=DAILY;COUNT=3", "", false},
+	}
+	for _, c := range cases {
+		r, err := ParseRecurrence(c.rule)
+		if (err == nil) != c.ok {
+			t.Fatalf("%q: expected ok %v, got error %v", c.rule, c.ok, err)
+		}
+		if c.ok && r.String() != c.want {
+			t.Fatalf("%q: expected %q, got %q", c.rule, c.want, r.String())
+		}
+	}
+}
+
+func TestParseTimeWindows(t *testing.T) {
+	twList, err := ParseTimeWindows("7:00-12:00, 13:30-24:00")
+	if err != nil {
+		t.Fatalf("parse time windows failed: %v", err)
+	}
+	if len(twList) != 2 || twList[0].String() != "07:00-12:00" || twList[1].Start != 13*60+30 || twList[1].End != 24*60 {
+		t.Fatalf("unexpected time windows %+v", twList)
+	}
+	for _, windows := range []string{"", "07:00", "07:00-07:00", "19:00-07:00", "07:60-08:00", "24:30-25:00", "7-8"} {
+		if _, err := ParseTimeWindows(windows); err == nil {
+			t.Fatalf("%q: expected error", windows)
+		}
+	}
+}
+
+func TestRecurrenceOccursOn(t *testing.T) {
+	// Thursday
+	start := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return start.AddDate(0, 0, d) }
+
+	daily, _ := ParseRecurrence("FREQ=DAILY;INTERVAL=3")
+	if !daily.OccursOn(start, day(0)) || daily.OccursOn(start, day(1)) || !daily.OccursOn(start, day(6)) || daily.OccursOn(start, day(-3)) {
+		t.Fatalf("unexpected daily occurrences")
+	}
+
+	// Without BYDAY the weekday of the start date is used
+	weekly, _ := ParseRecurrence("FREQ=WEEKLY")
+	if !weekly.OccursOn(start, day(7)) || weekly.OccursOn(start, day(1)) {
+		t.Fatalf("unexpected weekly occurrences")
+	}
+
+	// Every other week counts weeks from the Monday of the start week
+	biweekly, _ := ParseRecurrence("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SA")
+	if !biweekly.OccursOn(start, day(2)) || biweekly.OccursOn(start, day(4)) || !biweekly.OccursOn(start, day(11)) ||
+		!biweekly.OccursOn(start, day(16)) || biweekly.OccursOn(start, day(18)) {
+		t.Fatalf("unexpected biweekly occurrences")
+	}
+}
+
+func TestAccessPolicyIsActiveAt(t *testing.T) {
+	ap := &AccessPolicy{Name: "staff", AccessGroupID: 1, DoorGroupID: 1, StartDate: "1/9/2022", EndDate: "30/9/2022",
+		Recurrence: "freq=weekly;byday=mo,tu,we,th,fr", TimeWindows: "07:00-12:00,13:00-19:00"}
+	if err := validateAccessPolicy(ap); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if ap.TimeZone != DEFAULT_POLICY_TIME_ZONE || ap.Recurrence != "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR" {
+		t.Fatalf("expected canonical policy, got %+v", ap)
+	}
+
+	loc, _ := time.LoadLocation(DEFAULT_POLICY_TIME_ZONE)
+	at := func(d, h, m int) time.Time { return time.Date(2022, 9, d, h, m, 0, 0, loc) }
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(1, 7, 0), true},
+		{at(1, 6, 59), false},
+		{at(1, 12, 30), false},
+		{at(1, 18, 59), true},
+		{at(1, 19, 0), false},
+		// Saturday
+		{at(3, 9, 0), false},
+		{at(30, 18, 0), true},
+		{at(31, 9, 0), false},
+		// 08:00 in Ho Chi Minh city, given in UTC
+		{time.Date(2022, 9, 1, 1, 0, 0, 0, time.UTC), true},
+	}
+	for _, c := range cases {
+		if got := ap.IsActiveAt(c.t); got != c.want {
+			t.Fatalf("%v: expected %v, got %v", c.t, c.want, got)
+		}
+	}
+
+	ap.TimeZone = "Mars/Olympus"
+	if err := validateAccessPolicy(ap); err == nil {
+		t.Fatalf("expected error on unknown time zone")
+	}
+	legacy := &AccessPolicy{Name: "lab", AccessGroupID: 1, DoorGroupID: 1, StartDate: "1/9/2022", EndDate: "30/9/2022",
+		TimeWindows: "07:00-19:00"}
+	if err := validateAccessPolicy(legacy); err == nil {
+		t.Fatalf("expected error on time windows without recurrence")
+	}
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_PURGE_PERIOD time.Duration = time.Hour * 24 // 1 day
+
+	// Deleted by value for rows removed by gateway messages instead of HTTP requests
+	DELETED_BY_GATEWAY string = "gateway"
+)
+
+// Returned when creating a record whose unique value is still held by a soft deleted one
+var ErrDeletedRecord = errors.New("is deleted")
+
+// Soft deleted record keeps its unique values until purged, it has to be restored instead of created again
+func checkNotDeleted(db *gorm.DB, model interface{}, column string, value string) error {
+	var deleted int64
+	if err := db.Unscoped().Model(model).Where(column+" = ? AND deleted_at IS NOT NULL", value).Count(&deleted).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	if deleted > 0 {
+		return fmt.Errorf("%s %s %w, restore it instead", column, value, ErrDeletedRecord)
+	}
+	return nil
+}
+
+// Struct defines soft deleted records waiting to be restored or purged
+type RecycleBin struct {
+	Students  []Student  `json:"students"`
+	Employees []Employee `json:"employees"`
+	Customers []Customer `json:"customers"`
+	Doorlocks []Doorlock `json:"doorlocks"`
+	Gateways  []Gateway  `json:"gateways"`
+}
+
+// Soft deleted records
+type RecycleBinService interface {
+	FindRecycleBin(ctx context.Context) (*RecycleBin, error)
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+	PurgeExpiredJob(ctx context.Context, now time.Time) error
+}
+
+type RecycleBinSvc struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+func NewRecycleBinSvc(db *gorm.DB, retentionDays uint) *RecycleBinSvc {
+	return &RecycleBinSvc{
+		db:        db,
+		retention: time.Hour * 24 * time.Duration(retentionDays),
+	}
+}
+
+func (rbs *RecycleBinSvc) FindRecycleBin(ctx context.Context) (*RecycleBin, error) {
+	rb := &RecycleBin{}
+	deleted := conn(ctx, rbs.db).Unscoped().Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+	// Person of a deleted user is deleted with it
+	deletedUsers := deleted.Preload("Person", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Person.Credentials").Session(&gorm.Session{})
+	for _, dest := range []interface{}{&rb.Students, &rb.Employees, &rb.Customers} {
+		if err := deletedUsers.Find(dest).Error; err != nil {
+			err = utils.HandleQueryError(err)
+			return nil, err
+		}
+	}
+	for _, dest := range []interface{}{&rb.Doorlocks, &rb.Gateways} {
+		if err := deleted.Find(dest).Error; err != nil {
+			err = utils.HandleQueryError(err)
+			return nil, err
+		}
+	}
+	return rb, nil
+}
+
+// Hard delete soft deleted records older than the retention period
+func (rbs *RecycleBinSvc) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	before := now.Add(-rbs.retention).UTC()
+	var purged int64
+	for _, model := range []interface{}{&Student{}, &Employee{}, &Customer{}, &Doorlock{}, &Gateway{}} {
+		result := conn(ctx, rbs.db).Unscoped().Where("deleted_at IS NOT NULL AND deleted_at <= ?", before).Delete(model)
+		if err := result.Error; err != nil {
+			err = utils.HandleQueryError(err)
+			return purged, err
+		}
+		purged += result.RowsAffected
+	}
+
+	// People are purged after their profiles, credentials go with them
+	expiredPeople := conn(ctx, rbs.db).Unscoped().Model(&Person{}).Select("id").Where("deleted_at IS NOT NULL AND deleted_at <= ?", before)
+	if err := conn(ctx, rbs.db).Where("person_id IN (?)", expiredPeople).Delete(&Credential{}).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return purged, err
+	}
+	if err := conn(ctx, rbs.db).Unscoped().Where("deleted_at IS NOT NULL AND deleted_at <= ?", before).Delete(&Person{}).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return purged, err
+	}
+	return purged, nil
+}
+
+// Job run by the job runner every DEFAULT_PURGE_PERIOD
+func (rbs *RecycleBinSvc) PurgeExpiredJob(ctx context.Context, now time.Time) error {
+	purged, err := rbs.PurgeExpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	logger.LogfWithoutFields(logger.SQLSERVER, logger.InfoLevel, "Purged %d records from recycle bin", purged)
+	return nil
+}
+
+// Mark rows as deleted and record who deleted them
+func softDelete(db *gorm.DB, model interface{}, deletedBy string, query interface{}, args ...interface{}) *gorm.DB {
+	return softDeleteAt(db, model, db.NowFunc(), deletedBy, query, args...)
+}
+
+// Soft delete rows with the given deletion time, rows deleted together share it so they can be restored together
+func softDeleteAt(db *gorm.DB, model interface{}, deletedAt time.Time, deletedBy string, query interface{}, args ...interface{}) *gorm.DB {
+	return db.Model(model).Where(query, args...).Updates(map[string]interface{}{
+		"deleted_at": deletedAt,
+		"deleted_by": deletedBy,
+	})
+}
+
+// Bring soft deleted rows back
+func restoreDeleted(db *gorm.DB, model interface{}, query interface{}, args ...interface{}) *gorm.DB {
+	return db.Unscoped().Model(model).Where(query, args...).Where("deleted_at IS NOT NULL").Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"deleted_by": "",
+	})
+}
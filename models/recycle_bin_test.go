@@ -0,0 +1,114 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteAndRestoreStudent(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss := NewStudentSvc(db)
+	rbs := &RecycleBinSvc{db: db}
+
+	_, err := ss.CreateStudent(ctx, &Student{MSSV: "s1", Email: "s1@mail", Major: "it"})
+	if err != nil {
+		t.Fatalf("create student failed: %v", err)
+	}
+
+	if _, err := ss.DeleteStudent(ctx, "s1", "admin"); err != nil {
+		t.Fatalf("delete student failed: %v", err)
+	}
+	if s, _ := ss.FindStudentByMSSV(ctx, "s1"); s != nil {
+		t.Fatalf("deleted student still found")
+	}
+
+	// Its mssv and email are held until purged, it is restored instead of created again
+	for _, s := range []*Student{{MSSV: "s1", Email: "other@mail"}, {MSSV: "s2", Email: "s1@mail"}} {
+		if _, err := ss.CreateStudent(ctx, s); !errors.Is(err, ErrDeletedRecord) {
+			t.Fatalf("got %v, wanted %v", err, ErrDeletedRecord)
+		}
+	}
+
+	rb, err := rbs.FindRecycleBin(ctx)
+	if err != nil {
+		t.Fatalf("find recycle bin failed: %v", err)
+	}
+	if len(rb.Students) != 1 || rb.Students[0].DeletedBy != "admin" {
+		t.Fatalf("expected deleted student in recycle bin, got %+v", rb.Students)
+	}
+
+	if _, err := ss.RestoreStudent(ctx, "s1"); err != nil {
+		t.Fatalf("restore student failed: %v", err)
+	}
+	if _, err := ss.FindStudentByMSSV(ctx, "s1"); err != nil {
+		t.Fatalf("restored student not found: %v", err)
+	}
+	if _, err := ss.RestoreStudent(ctx, "s1"); err == nil {
+		t.Fatalf("expected error when restoring student not deleted")
+	}
+}
+
+func TestRestoreGatewayWithDoorlocks(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	gs := NewGatewaySvc(db)
+	dls := NewDoorlockSvc(db)
+
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1"})
+	dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d1", GatewayID: "gw-1", DoorlockAddress: "1"})
+	dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d2", GatewayID: "gw-1", DoorlockAddress: "2"})
+
+	// Doorlock 2 deleted on its own before the gateway, it stays deleted
+	dls.DeleteDoorlockByAddress(ctx, &Doorlock{GatewayID: "gw-1", DoorlockAddress: "2"}, "admin")
+	if ok, err := gs.DeleteGateway(ctx, "gw-1", "admin"); !ok || err != nil {
+		t.Fatalf("delete gateway failed: %v", err)
+	}
+	if dlList, _ := dls.FindAllDoorlockByGatewayID(ctx, "gw-1"); len(dlList) != 0 {
+		t.Fatalf("expected doorlocks deleted with gateway, got %+v", dlList)
+	}
+
+	if ok, err := gs.RestoreGateway(ctx, "gw-1"); !ok || err != nil {
+		t.Fatalf("restore gateway failed: %v", err)
+	}
+	if _, err := gs.FindGatewayByMacID(ctx, "gw-1"); err != nil {
+		t.Fatalf("restored gateway not found: %v", err)
+	}
+	dlList, _ := dls.FindAllDoorlockByGatewayID(ctx, "gw-1")
+	if len(dlList) != 1 || dlList[0].DoorlockAddress != "1" {
+		t.Fatalf("expected only doorlock 1 restored, got %+v", dlList)
+	}
+
+	if ok, _ := gs.RestoreGateway(ctx, "gw-2"); ok {
+		t.Fatalf("expected no restore for unknown gateway")
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	cs := NewCustomerSvc(db)
+	rbs := &RecycleBinSvc{db: db, retention: 0}
+
+	cs.CreateCustomer(ctx, &Customer{CCCD: "c1"})
+	cs.CreateCustomer(ctx, &Customer{CCCD: "c2"})
+	cs.DeleteCustomer(ctx, "c1", "admin")
+
+	purged, err := rbs.PurgeExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 purged record, got %d", purged)
+	}
+	var cnt int64
+	db.Unscoped().Model(&Customer{}).Count(&cnt)
+	if cnt != 1 {
+		t.Fatalf("expected 1 customer left, got %d", cnt)
+	}
+}
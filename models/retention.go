@@ -0,0 +1,322 @@
+package models
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RETENTION_TABLE_GATEWAY_LOGS           string = "gatewayLogs"
+	RETENTION_TABLE_DOORLOCK_STATUS_LOGS   string = "doorlockStatusLogs"
+	RETENTION_TABLE_DOORLOCK_STATUS_EVENTS string = "doorlockStatusEvents"
+	RETENTION_TABLE_ACCESS_EVENTS          string = "accessEvents"
+	RETENTION_TABLE_AUDIT_LOGS             string = "auditLogs"
+	RETENTION_TABLE_SCHEDULED_COMMAND_RUNS string = "scheduledCommandRuns"
+
+	DEFAULT_RETENTION_PERIOD time.Duration = time.Hour
+	RETENTION_BATCH_SIZE     int           = 1000
+)
+
+// Log-like table under retention, rows are aged by TimeColumn and counted by id
+type retentionTable struct {
+	TableName  string
+	TimeColumn string
+	// Rows are only deleted once archived
+	ArchiveRequired bool
+}
+
+var retentionTables = map[string]retentionTable{
+	RETENTION_TABLE_GATEWAY_LOGS:           {"gateway_logs", "log_time", false},
+	RETENTION_TABLE_DOORLOCK_STATUS_LOGS:   {"doorlock_status_logs", "created_at", false},
+	RETENTION_TABLE_DOORLOCK_STATUS_EVENTS: {"doorlock_status_events", "event_time", false},
+	RETENTION_TABLE_ACCESS_EVENTS:          {"access_events", "event_time", false},
+	RETENTION_TABLE_AUDIT_LOGS:             {"audit_logs", "created_at", true},
+	RETENTION_TABLE_SCHEDULED_COMMAND_RUNS: {"scheduled_command_runs", "ran_at", false},
+}
+
+// Policies of tables never configured, gateway logs keep the one week of the former cleaner
+var defaultRetentionPolicies = map[string]RetentionPolicy{
+	RETENTION_TABLE_GATEWAY_LOGS: {MaxAgeHours: uint(DEFAULT_CLEAN_LOGS_PERIOD / time.Hour)},
+}
+
+// Rows older than MaxAgeHours and rows beyond the newest MaxRows are deleted, 0 is no limit.
+// With Archive they are written to a gzipped JSON lines file first
+type RetentionPolicy struct {
+	GormModel
+	Table       string     `gorm:"column:log_table;type:varchar(64);unique;not null" json:"table" binding:"required"`
+	MaxAgeHours uint       `json:"maxAgeHours"`
+	MaxRows     uint       `json:"maxRows"`
+	Archive     bool       `gorm:"not null" json:"archive"`
+	LastRunAt   *time.Time `json:"lastRunAt"`
+	LastDeleted int64      `json:"lastDeleted"`
+	LastArchive string     `json:"lastArchive"` // file of the last run
+	LastError   string     `json:"lastError"`
+}
+
+// Retention policies and their runs
+type RetentionService interface {
+	FindAllRetentionPolicy(ctx context.Context) ([]RetentionPolicy, error)
+	UpdateRetentionPolicy(ctx context.Context, rp *RetentionPolicy) (*RetentionPolicy, error)
+	SetRetentionMaxAge(ctx context.Context, table string, maxAgeHours uint) (*RetentionPolicy, error)
+	RunRetention(ctx context.Context, now time.Time) ([]RetentionPolicy, error)
+	RunRetentionJob(ctx context.Context, now time.Time) error
+}
+
+type RetentionSvc struct {
+	db         *gorm.DB
+	archiveDir string
+	mu         sync.Mutex
+	running    bool
+}
+
+func NewRetentionSvc(db *gorm.DB, archiveDir string) *RetentionSvc {
+	return &RetentionSvc{
+		db:         db,
+		archiveDir: archiveDir,
+	}
+}
+
+// Policies of every table, tables never configured have their default policy
+func (rs *RetentionSvc) FindAllRetentionPolicy(ctx context.Context) ([]RetentionPolicy, error) {
+	var stored []RetentionPolicy
+	if err := conn(ctx, rs.db).Find(&stored).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	storedMap := map[string]RetentionPolicy{}
+	for _, rp := range stored {
+		storedMap[rp.Table] = rp
+	}
+
+	rpList := []RetentionPolicy{}
+	for _, table := range retentionTableNames() {
+		rp, ok := storedMap[table]
+		if !ok {
+			rp = defaultRetentionPolicies[table]
+			rp.Table = table
+		}
+		rpList = append(rpList, rp)
+	}
+	return rpList, nil
+}
+
+func (rs *RetentionSvc) UpdateRetentionPolicy(ctx context.Context, rp *RetentionPolicy) (*RetentionPolicy, error) {
+	rt, ok := retentionTables[rp.Table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", rp.Table)
+	}
+	if rt.ArchiveRequired && !rp.Archive && (rp.MaxAgeHours > 0 || rp.MaxRows > 0) {
+		return nil, fmt.Errorf("%s are only deleted once archived", rp.Table)
+	}
+	rp.ID = 0
+	result := conn(ctx, rs.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "log_table"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_age_hours", "max_rows", "archive", "updated_at"}),
+	}).Create(rp)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return rp, nil
+}
+
+// Change max age only, used by the gateway logs clean period
+func (rs *RetentionSvc) SetRetentionMaxAge(ctx context.Context, table string, maxAgeHours uint) (*RetentionPolicy, error) {
+	rpList, err := rs.FindAllRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range rpList {
+		if rp.Table == table {
+			rp.MaxAgeHours = maxAgeHours
+			return rs.UpdateRetentionPolicy(ctx, &rp)
+		}
+	}
+	return nil, fmt.Errorf("unknown table %s", table)
+}
+
+// Apply every policy once, a run already in progress is not started again
+func (rs *RetentionSvc) RunRetention(ctx context.Context, now time.Time) ([]RetentionPolicy, error) {
+	rs.mu.Lock()
+	if rs.running {
+		rs.mu.Unlock()
+		return nil, fmt.Errorf("retention is already running")
+	}
+	rs.running = true
+	rs.mu.Unlock()
+	defer func() {
+		rs.mu.Lock()
+		rs.running = false
+		rs.mu.Unlock()
+	}()
+
+	rpList, err := rs.FindAllRetentionPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rpList {
+		rp := &rpList[i]
+		if rp.MaxAgeHours == 0 && rp.MaxRows == 0 {
+			continue
+		}
+		deleted, archive, err := rs.apply(ctx, rp, now)
+		rp.LastRunAt, rp.LastDeleted, rp.LastArchive, rp.LastError = &now, deleted, archive, ""
+		if err != nil {
+			rp.LastError = err.Error()
+		}
+		result := conn(ctx, rs.db).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "log_table"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_run_at", "last_deleted", "last_archive", "last_error", "updated_at"}),
+		}).Create(rp)
+		if result.Error != nil {
+			return rpList, utils.HandleQueryError(result.Error)
+		}
+	}
+	return rpList, nil
+}
+
+// Archive then delete the expired rows of the policy table, rows are deleted up to the last archived id
+// so rows arriving meanwhile are never deleted without being archived
+func (rs *RetentionSvc) apply(ctx context.Context, rp *RetentionPolicy, now time.Time) (int64, string, error) {
+	rt := retentionTables[rp.Table]
+	expired, err := rs.expiredQuery(ctx, rt, rp, now)
+	if err != nil || expired == nil {
+		return 0, "", err
+	}
+
+	var maxID uint
+	if err := expired().Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return 0, "", err
+	}
+	if maxID == 0 {
+		return 0, "", nil
+	}
+
+	archive := ""
+	if rp.Archive {
+		if archive, err = rs.archive(rt, expired().Where("id <= ?", maxID), now); err != nil {
+			return 0, "", err
+		}
+	}
+	result := expired().Where("id <= ?", maxID).Delete(nil)
+	return result.RowsAffected, archive, result.Error
+}
+
+// Nil when the policy has no limit reached
+func (rs *RetentionSvc) expiredQuery(ctx context.Context, rt retentionTable, rp *RetentionPolicy, now time.Time) (func() *gorm.DB, error) {
+	var conds []string
+	var args []interface{}
+	if rp.MaxAgeHours > 0 {
+		conds = append(conds, rt.TimeColumn+" < ?")
+		args = append(args, now.Add(-time.Duration(rp.MaxAgeHours)*time.Hour).UTC())
+	}
+	if rp.MaxRows > 0 {
+		// Id of the newest row beyond MaxRows
+		var ids []uint
+		if err := conn(ctx, rs.db).Table(rt.TableName).Order("id desc").Offset(int(rp.MaxRows)).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			conds = append(conds, "id <= ?")
+			args = append(args, ids[0])
+		}
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+	where := conds[0]
+	if len(conds) > 1 {
+		where = "(" + conds[0] + " OR " + conds[1] + ")"
+	}
+	return func() *gorm.DB {
+		return conn(ctx, rs.db).Table(rt.TableName).Where(where, args...)
+	}, nil
+}
+
+// Write rows to <archiveDir>/<table>-<time>.jsonl.gz, the file is removed when writing fails
+func (rs *RetentionSvc) archive(rt retentionTable, query *gorm.DB, now time.Time) (string, error) {
+	if err := os.MkdirAll(rs.archiveDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(rs.archiveDir, fmt.Sprintf("%s-%s.jsonl.gz", rt.TableName, now.UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+
+	// Batched by id as rows are plain maps, which FindInBatches cannot page
+	var lastID uint64
+	for err == nil {
+		var rows []map[string]interface{}
+		if err = query.Session(&gorm.Session{}).Where("id > ?", lastID).Order("id").Limit(RETENTION_BATCH_SIZE).Find(&rows).Error; err != nil {
+			break
+		}
+		for _, row := range rows {
+			if err = enc.Encode(row); err != nil {
+				break
+			}
+		}
+		if err != nil || len(rows) < RETENTION_BATCH_SIZE {
+			break
+		}
+		lastID, err = strconv.ParseUint(rowIDString(rows[len(rows)-1]["id"]), 10, 64)
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// Job run by the job runner every DEFAULT_RETENTION_PERIOD, fails when a policy failed
+func (rs *RetentionSvc) RunRetentionJob(ctx context.Context, now time.Time) error {
+	rpList, err := rs.RunRetention(ctx, now)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, rp := range rpList {
+		if rp.LastError != "" {
+			failed = append(failed, rp.Table+": "+rp.LastError)
+		} else if rp.LastDeleted > 0 {
+			logger.LogfWithoutFields(logger.SQLSERVER, logger.InfoLevel, "Retention deleted %d %s", rp.LastDeleted, rp.Table)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("retention failed for %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func retentionTableNames() []string {
+	return []string{RETENTION_TABLE_GATEWAY_LOGS, RETENTION_TABLE_DOORLOCK_STATUS_LOGS, RETENTION_TABLE_DOORLOCK_STATUS_EVENTS,
+		RETENTION_TABLE_ACCESS_EVENTS, RETENTION_TABLE_AUDIT_LOGS, RETENTION_TABLE_SCHEDULED_COMMAND_RUNS}
+}
+
+// Drivers scan the id of a map row as different integer types or bytes
+func rowIDString(id interface{}) string {
+	if b, ok := id.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(id)
+}
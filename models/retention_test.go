@@ -0,0 +1,112 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunRetention(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	rs := NewRetentionSvc(db, t.TempDir())
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		db.Create(&GatewayLog{GatewayID: "gw-1", Content: "log", LogTime: now.Add(-time.Duration(i) * 24 * time.Hour)})
+		db.Create(&DoorlockStatusEvent{DoorID: 1, EventType: STATUS_EVENT_DOOR, Value: "open", Active: true, EventTime: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	if _, err := rs.UpdateRetentionPolicy(ctx, &RetentionPolicy{Table: "students"}); err == nil {
+		t.Fatalf("expected error on unknown table")
+	}
+	if _, err := rs.UpdateRetentionPolicy(ctx, &RetentionPolicy{Table: RETENTION_TABLE_AUDIT_LOGS, MaxAgeHours: 24}); err == nil {
+		t.Fatalf("expected error on audit logs retention without archive")
+	}
+	if _, err := rs.UpdateRetentionPolicy(ctx, &RetentionPolicy{Table: RETENTION_TABLE_DOORLOCK_STATUS_EVENTS, MaxRows: 4, Archive: true}); err != nil {
+		t.Fatalf("update retention policy failed: %v", err)
+	}
+	// Gateway logs keep the default week until the legacy clean period is changed
+	rp, err := rs.SetRetentionMaxAge(ctx, RETENTION_TABLE_GATEWAY_LOGS, 72)
+	if err != nil || rp.MaxAgeHours != 72 {
+		t.Fatalf("expected gateway logs max age 72h, got %+v, %v", rp, err)
+	}
+
+	rpList, err := rs.RunRetention(ctx, now)
+	if err != nil {
+		t.Fatalf("run retention failed: %v", err)
+	}
+	results := map[string]RetentionPolicy{}
+	for _, rp := range rpList {
+		results[rp.Table] = rp
+	}
+
+	var glCount, dseCount int64
+	db.Model(&GatewayLog{}).Count(&glCount)
+	db.Model(&DoorlockStatusEvent{}).Count(&dseCount)
+	if glCount != 4 || results[RETENTION_TABLE_GATEWAY_LOGS].LastDeleted != 6 {
+		t.Fatalf("expected 4 gateway logs up to 72h old left, got %d, %+v", glCount, results[RETENTION_TABLE_GATEWAY_LOGS])
+	}
+	dse := results[RETENTION_TABLE_DOORLOCK_STATUS_EVENTS]
+	if dseCount != 4 || dse.LastDeleted != 6 || dse.LastArchive == "" || dse.LastError != "" {
+		t.Fatalf("expected newest 4 status events left and 6 archived, got %d, %+v", dseCount, dse)
+	}
+
+	f, err := os.Open(dse.LastArchive)
+	if err != nil {
+		t.Fatalf("open archive failed: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("read archive failed: %v", err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		row := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil || row["value"] != "open" {
+			t.Fatalf("expected archived status event, got %s, %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	if lines != 6 {
+		t.Fatalf("expected 6 archived rows, got %d", lines)
+	}
+
+	stored, _ := rs.FindAllRetentionPolicy(ctx)
+	for _, rp := range stored {
+		if rp.Table == RETENTION_TABLE_DOORLOCK_STATUS_EVENTS && (rp.LastRunAt == nil || rp.LastDeleted != 6 || rp.MaxRows != 4) {
+			t.Fatalf("expected last run stored with policy, got %+v", rp)
+		}
+	}
+}
+
+func TestRetentionCancelled(t *testing.T) {
+	db := newTestDb(t)
+	rs := NewRetentionSvc(db, t.TempDir())
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		db.Create(&GatewayLog{GatewayID: "gw-1", Content: "log", LogTime: now.Add(-time.Duration(i) * 24 * time.Hour)})
+	}
+
+	// Shutdown cancels a run in progress, nothing is deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rp := &RetentionPolicy{Table: RETENTION_TABLE_GATEWAY_LOGS, MaxAgeHours: 72, MaxRows: 2}
+	if _, _, err := rs.apply(ctx, rp, now); err == nil {
+		t.Fatalf("expected error on cancelled retention")
+	}
+	var glCount int64
+	db.Model(&GatewayLog{}).Count(&glCount)
+	if glCount != 10 {
+		t.Fatalf("expected 10 gateway logs left, got %d", glCount)
+	}
+}
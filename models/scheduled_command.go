@@ -0,0 +1,328 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	SCHEDULE_TARGET_DOORLOCK string = "doorlock" // targetId is the doorlock ID
+	SCHEDULE_TARGET_ROOM     string = "room"     // targetId is the roomId of the doorlocks
+	SCHEDULE_TARGET_BLOCK    string = "block"    // targetId is the blockId of the doorlocks
+	SCHEDULE_TARGET_AREA     string = "area"     // targetId is the areaId of the doorlock gateways
+
+	MISSED_RUN_SKIP     string = "skip"    // occurrences missed while the server was down are recorded only
+	MISSED_RUN_RUN_ONCE string = "runOnce" // occurrences missed while the server was down are run once on start
+
+	SCHEDULE_RUN_SUCCESS string = "success"
+	SCHEDULE_RUN_FAILED  string = "failed"
+	SCHEDULE_RUN_SKIPPED string = "skipped"
+
+	SCHEDULED_COMMAND_TICK time.Duration = 30 * time.Second
+	// Runs later than this after their time were missed
+	SCHEDULED_COMMAND_GRACE time.Duration = 2 * time.Minute
+
+	DEFAULT_SCHEDULE_RUN_LIMIT int = 100
+	MAX_SCHEDULE_RUN_LIMIT     int = 1000
+)
+
+// Doorlock command sent to the target doorlocks at every occurrence of Cron in TimeZone
+type ScheduledCommand struct {
+	GormModel
+	Name            string     `gorm:"type:varchar(256);not null" json:"name"`
+	Cron            string     `gorm:"type:varchar(100);not null" json:"cron"`
+	TimeZone        string     `gorm:"type:varchar(64)" json:"timeZone"`
+	TargetType      string     `gorm:"type:varchar(16);not null" json:"targetType"`
+	TargetID        string     `gorm:"type:varchar(256);not null" json:"targetId"`
+	Action          string     `gorm:"type:varchar(50);not null" json:"action"`
+	Duration        string     `gorm:"type:varchar(50)" json:"duration"` // timed action when set, door mode otherwise
+	MissedRunPolicy string     `gorm:"type:varchar(16);not null" json:"missedRunPolicy"`
+	Enabled         bool       `gorm:"not null;index" json:"enabled"`
+	NextRunAt       *time.Time `gorm:"index" json:"nextRunAt"`
+	LastRunAt       *time.Time `json:"lastRunAt"`
+}
+
+// History of a scheduled command occurrence
+type ScheduledCommandRun struct {
+	GormModel
+	ScheduledCommandID uint      `gorm:"not null;index" json:"scheduledCommandId"`
+	ScheduledAt        time.Time `json:"scheduledAt"`
+	RanAt              time.Time `json:"ranAt"`
+	Missed             bool      `gorm:"not null" json:"missed"` // server was down at ScheduledAt
+	Status             string    `gorm:"type:varchar(16);not null" json:"status"`
+	Doorlocks          int       `json:"doorlocks"` // commands sent
+	Skipped            int       `json:"skipped"`   // doorlocks requiring approval for unlock
+	ErrorMsg           string    `json:"errorMsg"`
+}
+
+// Struct defines HTTP request payload for enabling or disabling a scheduled command
+type ScheduledCommandEnable struct {
+	ID      uint `json:"id" binding:"required"`
+	Enabled bool `json:"enabled"`
+}
+
+// Send command to the target doorlocks, returns the number of commands sent
+type ScheduledCommandDispatcher func(sc *ScheduledCommand, dlList []Doorlock) (int, error)
+
+// Scheduled doorlock commands and their runs
+type ScheduledCommandService interface {
+	FindAllScheduledCommand(ctx context.Context) (scList []ScheduledCommand, err error)
+	FindScheduledCommandByID(ctx context.Context, id string) (sc *ScheduledCommand, err error)
+	FindScheduledCommandRuns(ctx context.Context, id string, limit int) (runList []ScheduledCommandRun, err error)
+	CreateScheduledCommand(ctx context.Context, sc *ScheduledCommand) (*ScheduledCommand, error)
+	UpdateScheduledCommand(ctx context.Context, sc *ScheduledCommand) (bool, error)
+	EnableScheduledCommand(ctx context.Context, id uint, enabled bool) (bool, error)
+	DeleteScheduledCommand(ctx context.Context, id uint) (bool, error)
+	FindScheduledCommandDoorlocks(ctx context.Context, sc *ScheduledCommand) (dlList []Doorlock, err error)
+	RunDueScheduledCommands(ctx context.Context, now time.Time, dispatch ScheduledCommandDispatcher) ([]ScheduledCommandRun, error)
+}
+
+type ScheduledCommandSvc struct {
+	db *gorm.DB
+}
+
+func NewScheduledCommandSvc(db *gorm.DB) *ScheduledCommandSvc {
+	return &ScheduledCommandSvc{
+		db: db,
+	}
+}
+
+func (scs *ScheduledCommandSvc) FindAllScheduledCommand(ctx context.Context) (scList []ScheduledCommand, err error) {
+	result := conn(ctx, scs.db).Order("id").Find(&scList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return scList, nil
+}
+
+func (scs *ScheduledCommandSvc) FindScheduledCommandByID(ctx context.Context, id string) (sc *ScheduledCommand, err error) {
+	result := conn(ctx, scs.db).First(&sc, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Find run history newest first, limit is capped at MAX_SCHEDULE_RUN_LIMIT
+func (scs *ScheduledCommandSvc) FindScheduledCommandRuns(ctx context.Context, id string, limit int) (runList []ScheduledCommandRun, err error) {
+	if limit <= 0 {
+		limit = DEFAULT_SCHEDULE_RUN_LIMIT
+	}
+	if limit > MAX_SCHEDULE_RUN_LIMIT {
+		limit = MAX_SCHEDULE_RUN_LIMIT
+	}
+	result := conn(ctx, scs.db).Where("scheduled_command_id = ?", id).Order("id desc").Limit(limit).Find(&runList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return runList, nil
+}
+
+func (scs *ScheduledCommandSvc) CreateScheduledCommand(ctx context.Context, sc *ScheduledCommand) (*ScheduledCommand, error) {
+	cs, err := validateScheduledCommand(sc)
+	if err != nil {
+		return nil, err
+	}
+	sc.LastRunAt = nil
+	sc.NextRunAt = scheduleNextRun(sc, cs, time.Now())
+	if err := conn(ctx, scs.db).Create(sc).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return sc, nil
+}
+
+// Next run is computed again from now, occurrences before the update are not missed runs
+func (scs *ScheduledCommandSvc) UpdateScheduledCommand(ctx context.Context, sc *ScheduledCommand) (bool, error) {
+	cs, err := validateScheduledCommand(sc)
+	if err != nil {
+		return false, err
+	}
+	sc.NextRunAt = scheduleNextRun(sc, cs, time.Now())
+	result := conn(ctx, scs.db).Model(&ScheduledCommand{GormModel: GormModel{ID: sc.ID}}).
+		Select("Name", "Cron", "TimeZone", "TargetType", "TargetID", "Action", "Duration", "MissedRunPolicy", "Enabled", "NextRunAt").
+		Updates(sc)
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Enabled command runs from its next occurrence, runs while disabled are not missed runs
+func (scs *ScheduledCommandSvc) EnableScheduledCommand(ctx context.Context, id uint, enabled bool) (bool, error) {
+	sc := &ScheduledCommand{}
+	if err := conn(ctx, scs.db).First(sc, id).Error; err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	sc.Enabled = enabled
+	cs, err := validateScheduledCommand(sc)
+	if err != nil {
+		return false, err
+	}
+	result := conn(ctx, scs.db).Model(sc).Select("Enabled", "NextRunAt").
+		Updates(&ScheduledCommand{Enabled: enabled, NextRunAt: scheduleNextRun(sc, cs, time.Now())})
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (scs *ScheduledCommandSvc) DeleteScheduledCommand(ctx context.Context, id uint) (bool, error) {
+	var result *gorm.DB
+	err := conn(ctx, scs.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scheduled_command_id = ?", id).Delete(&ScheduledCommandRun{}).Error; err != nil {
+			return err
+		}
+		result = tx.Where("id = ?", id).Delete(&ScheduledCommand{})
+		return result.Error
+	})
+	if err != nil {
+		return false, utils.HandleQueryError(err)
+	}
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+// Doorlocks the command is sent to
+func (scs *ScheduledCommandSvc) FindScheduledCommandDoorlocks(ctx context.Context, sc *ScheduledCommand) (dlList []Doorlock, err error) {
+	query := conn(ctx, scs.db).Order("id")
+	switch sc.TargetType {
+	case SCHEDULE_TARGET_DOORLOCK:
+		query = query.Where("id = ?", sc.TargetID)
+	case SCHEDULE_TARGET_ROOM:
+		query = query.Where("room_id = ?", sc.TargetID)
+	case SCHEDULE_TARGET_BLOCK:
+		query = query.Where("block_id = ?", sc.TargetID)
+	case SCHEDULE_TARGET_AREA:
+		query = query.Where("gateway_id IN (?)", conn(ctx, scs.db).Model(&Gateway{}).Select("gateway_id").Where("area_id = ?", sc.TargetID))
+	default:
+		return nil, fmt.Errorf("unknown targetType %s", sc.TargetType)
+	}
+	if err := query.Find(&dlList).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return dlList, nil
+}
+
+// Run enabled commands due at now and record their history. Occurrences missed while the server was down
+// collapse into one run handled by the missed run policy of the command
+func (scs *ScheduledCommandSvc) RunDueScheduledCommands(ctx context.Context, now time.Time, dispatch ScheduledCommandDispatcher) ([]ScheduledCommandRun, error) {
+	var scList []ScheduledCommand
+	if err := conn(ctx, scs.db).Where("enabled = ? AND next_run_at <= ?", true, now.UTC()).Order("id").Find(&scList).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+
+	var runList []ScheduledCommandRun
+	for i := range scList {
+		sc := &scList[i]
+		cs, err := validateScheduledCommand(sc)
+		if err != nil {
+			// Command can not be scheduled anymore, e.g. its time zone was removed from the host
+			conn(ctx, scs.db).Model(sc).Update("enabled", false)
+			runList = append(runList, scs.recordRun(ctx, sc, &ScheduledCommandRun{
+				ScheduledAt: *sc.NextRunAt, RanAt: now, Status: SCHEDULE_RUN_FAILED, ErrorMsg: err.Error(),
+			}))
+			continue
+		}
+
+		// Claim the run, another server instance may have run it already
+		scheduledAt := *sc.NextRunAt
+		claimed := conn(ctx, scs.db).Model(&ScheduledCommand{}).Where("id = ? AND next_run_at = ?", sc.ID, scheduledAt).
+			Updates(map[string]interface{}{"next_run_at": scheduleNextRun(sc, cs, now), "last_run_at": now.UTC()})
+		if claimed.Error != nil {
+			return runList, utils.HandleQueryError(claimed.Error)
+		}
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+
+		run := &ScheduledCommandRun{ScheduledAt: scheduledAt, RanAt: now, Missed: now.Sub(scheduledAt) > SCHEDULED_COMMAND_GRACE}
+		if run.Missed && sc.MissedRunPolicy == MISSED_RUN_SKIP {
+			run.Status = SCHEDULE_RUN_SKIPPED
+			runList = append(runList, scs.recordRun(ctx, sc, run))
+			continue
+		}
+		scs.execute(ctx, sc, run, dispatch)
+		runList = append(runList, scs.recordRun(ctx, sc, run))
+	}
+	return runList, nil
+}
+
+func (scs *ScheduledCommandSvc) execute(ctx context.Context, sc *ScheduledCommand, run *ScheduledCommandRun, dispatch ScheduledCommandDispatcher) {
+	dlList, err := scs.FindScheduledCommandDoorlocks(ctx, sc)
+	if err != nil {
+		run.Status, run.ErrorMsg = SCHEDULE_RUN_FAILED, err.Error()
+		return
+	}
+	// Doorlocks requiring approval are only unlocked by an approved unlock request
+	var sendList []Doorlock
+	for _, dl := range dlList {
+		if dl.RequiresApproval && IsUnlockCommand(sc.Action) {
+			run.Skipped++
+			continue
+		}
+		sendList = append(sendList, dl)
+	}
+	run.Doorlocks, err = dispatch(sc, sendList)
+	if err != nil {
+		run.Status, run.ErrorMsg = SCHEDULE_RUN_FAILED, err.Error()
+		return
+	}
+	run.Status = SCHEDULE_RUN_SUCCESS
+}
+
+func (scs *ScheduledCommandSvc) recordRun(ctx context.Context, sc *ScheduledCommand, run *ScheduledCommandRun) ScheduledCommandRun {
+	run.ScheduledCommandID = sc.ID
+	run.ScheduledAt, run.RanAt = run.ScheduledAt.UTC(), run.RanAt.UTC()
+	conn(ctx, scs.db).Create(run)
+	return *run
+}
+
+func validateScheduledCommand(sc *ScheduledCommand) (*CronSchedule, error) {
+	if sc.Name == "" {
+		return nil, fmt.Errorf("scheduled command name is required")
+	}
+	cs, err := ParseCron(sc.Cron)
+	if err != nil {
+		return nil, err
+	}
+	if sc.TimeZone == "" {
+		sc.TimeZone = DEFAULT_POLICY_TIME_ZONE
+	}
+	if _, err := time.LoadLocation(sc.TimeZone); err != nil {
+		return nil, fmt.Errorf("unknown timeZone %s", sc.TimeZone)
+	}
+	switch sc.TargetType {
+	case SCHEDULE_TARGET_DOORLOCK, SCHEDULE_TARGET_ROOM, SCHEDULE_TARGET_BLOCK, SCHEDULE_TARGET_AREA:
+	default:
+		return nil, fmt.Errorf("targetType must be doorlock, room, block or area")
+	}
+	if sc.TargetID == "" {
+		return nil, fmt.Errorf("targetId is required")
+	}
+	if sc.Action == "" {
+		return nil, fmt.Errorf("action is required")
+	}
+	if sc.MissedRunPolicy == "" {
+		sc.MissedRunPolicy = MISSED_RUN_SKIP
+	}
+	if sc.MissedRunPolicy != MISSED_RUN_SKIP && sc.MissedRunPolicy != MISSED_RUN_RUN_ONCE {
+		return nil, fmt.Errorf("missedRunPolicy must be skip or runOnce")
+	}
+	return cs, nil
+}
+
+// Nil when disabled or never due
+func scheduleNextRun(sc *ScheduledCommand, cs *CronSchedule, after time.Time) *time.Time {
+	if !sc.Enabled {
+		return nil
+	}
+	loc, _ := time.LoadLocation(sc.TimeZone)
+	next := cs.Next(after, loc)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
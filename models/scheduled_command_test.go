@@ -0,0 +1,80 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRunDueScheduledCommands(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	gs, dls, scs := NewGatewaySvc(db), NewDoorlockSvc(db), NewScheduledCommandSvc(db)
+
+	gs.CreateGateway(ctx, &Gateway{GatewayID: "gw-1", AreaID: "a1"})
+	dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d1", GatewayID: "gw-1", DoorlockAddress: "1", RoomId: "r1"})
+	d2, _ := dls.CreateDoorlock(ctx, &Doorlock{DoorSerialID: "d2", GatewayID: "gw-1", DoorlockAddress: "2", RoomId: "r1"})
+	dls.SetDoorlockRequiresApproval(ctx, d2.ID, true)
+
+	if _, err := scs.CreateScheduledCommand(ctx, &ScheduledCommand{Name: "bad", Cron: "45 6 * *", TargetType: SCHEDULE_TARGET_ROOM, TargetID: "r1", Action: "unlock"}); err == nil {
+		t.Fatalf("expected error on invalid cron")
+	}
+	if _, err := scs.CreateScheduledCommand(ctx, &ScheduledCommand{Name: "bad", Cron: "45 6 * * *", TargetType: "floor", TargetID: "f1", Action: "unlock"}); err == nil {
+		t.Fatalf("expected error on unknown target")
+	}
+	unlock, err := scs.CreateScheduledCommand(ctx, &ScheduledCommand{Name: "open hall", Cron: "45 6 * * MON-FRI",
+		TargetType: SCHEDULE_TARGET_AREA, TargetID: "a1", Action: "unlock", Enabled: true})
+	if err != nil || unlock.NextRunAt == nil || unlock.MissedRunPolicy != MISSED_RUN_SKIP || unlock.TimeZone != DEFAULT_POLICY_TIME_ZONE {
+		t.Fatalf("expected enabled command with defaults, got %+v, %v", unlock, err)
+	}
+	lock, _ := scs.CreateScheduledCommand(ctx, &ScheduledCommand{Name: "close hall", Cron: "30 21 * * MON-FRI",
+		TargetType: SCHEDULE_TARGET_ROOM, TargetID: "r1", Action: "lock", MissedRunPolicy: MISSED_RUN_RUN_ONCE, Enabled: true})
+
+	sent := map[string]int{}
+	dispatch := func(sc *ScheduledCommand, dlList []Doorlock) (int, error) {
+		sent[sc.Action] += len(dlList)
+		return len(dlList), nil
+	}
+
+	// Both commands were due a day ago while the server was down
+	past := time.Now().Add(-24 * time.Hour).UTC()
+	db.Model(&ScheduledCommand{}).Where("id IN ?", []uint{unlock.ID, lock.ID}).Update("next_run_at", past)
+	runList, err := scs.RunDueScheduledCommands(ctx, time.Now(), dispatch)
+	if err != nil || len(runList) != 2 {
+		t.Fatalf("expected 2 runs, got %+v, %v", runList, err)
+	}
+	if run := runList[0]; !run.Missed || run.Status != SCHEDULE_RUN_SKIPPED || sent["unlock"] != 0 {
+		t.Fatalf("expected missed unlock to be skipped, got %+v", run)
+	}
+	// Doorlocks requiring approval are locked but not unlocked
+	if run := runList[1]; !run.Missed || run.Status != SCHEDULE_RUN_SUCCESS || run.Doorlocks != 2 || sent["lock"] != 2 {
+		t.Fatalf("expected missed lock to run once on 2 doorlocks, got %+v", run)
+	}
+	if runList, _ := scs.RunDueScheduledCommands(ctx, time.Now(), dispatch); len(runList) != 0 {
+		t.Fatalf("expected no run before next occurrence, got %+v", runList)
+	}
+
+	found, _ := scs.FindScheduledCommandByID(ctx, strconv.FormatUint(uint64(unlock.ID), 10))
+	if found.LastRunAt == nil || found.NextRunAt == nil || !found.NextRunAt.After(time.Now()) {
+		t.Fatalf("expected next run in the future, got %+v", found)
+	}
+	db.Model(&ScheduledCommand{}).Where("id = ?", unlock.ID).Update("next_run_at", time.Now().Add(-time.Minute).UTC())
+	runList, _ = scs.RunDueScheduledCommands(ctx, time.Now(), dispatch)
+	if len(runList) != 1 || runList[0].Missed || runList[0].Doorlocks != 1 || runList[0].Skipped != 1 {
+		t.Fatalf("expected on time unlock of 1 doorlock, got %+v", runList)
+	}
+
+	if _, err := scs.EnableScheduledCommand(ctx, lock.ID, false); err != nil {
+		t.Fatalf("disable scheduled command failed: %v", err)
+	}
+	if found, _ := scs.FindScheduledCommandByID(ctx, strconv.FormatUint(uint64(lock.ID), 10)); found.Enabled || found.NextRunAt != nil {
+		t.Fatalf("expected disabled command without next run, got %+v", found)
+	}
+	if runs, _ := scs.FindScheduledCommandRuns(ctx, strconv.FormatUint(uint64(unlock.ID), 10), 0); len(runs) != 2 || runs[0].Status != SCHEDULE_RUN_SUCCESS {
+		t.Fatalf("expected 2 unlock runs newest first, got %+v", runs)
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -32,6 +33,34 @@ type Scheduler struct {
 	CustomerID *string `gorm:"type:varchar(256);" json:"customerId"`
 	Role       string  `json:"role"` //value in ["employee", "student", "customer"]
 	UserID     string  `json:"userId"`
+	PersonID   *uint   `gorm:"index" json:"personId"`
+	// Set on registers generated from an access policy, they change with the policy only
+	AccessPolicyID *uint `gorm:"index" json:"accessPolicyId"`
+}
+
+// Link new scheduler to the person of its user
+func (s *Scheduler) BeforeCreate(tx *gorm.DB) error {
+	if s.PersonID != nil {
+		return nil
+	}
+	personType, userID := s.Role, s.UserID
+	switch {
+	case s.StudentID != nil:
+		personType, userID = PERSON_TYPE_STUDENT, *s.StudentID
+	case s.EmployeeID != nil:
+		personType, userID = PERSON_TYPE_EMPLOYEE, *s.EmployeeID
+	case s.CustomerID != nil:
+		personType, userID = PERSON_TYPE_CUSTOMER, *s.CustomerID
+	}
+	p := &Person{}
+	result := tx.Where("type = ? AND user_id = ?", personType, userID).Limit(1).Find(p)
+	if err := result.Error; err != nil {
+		return err
+	}
+	if result.RowsAffected > 0 {
+		s.PersonID = &p.ID
+	}
+	return nil
 }
 
 // Struct defines HTTP request payload for updating scheduler
@@ -48,6 +77,16 @@ type UserScheduler struct {
 	ScheInfo   Scheduler `json:"scheInfo"`
 }
 
+// Registers of users on doorlocks
+type SchedulerService interface {
+	FindAllScheduler(ctx context.Context) (sList []Scheduler, err error)
+	FindSchedulerByID(ctx context.Context, id string) (s *Scheduler, err error)
+	CreateScheduler(ctx context.Context, s *Scheduler) (*Scheduler, error)
+	UpdateScheduler(ctx context.Context, s *Scheduler) (bool, error)
+	DeleteScheduler(ctx context.Context, studentId uint) (bool, error)
+	FindSchedulerByListDoorID(ctx context.Context, doorId []uint) (sList []Scheduler, err error)
+}
+
 type SchedulerSvc struct {
 	db *gorm.DB
 }
@@ -59,7 +98,7 @@ func NewSchedulerSvc(db *gorm.DB) *SchedulerSvc {
 }
 
 func (ss *SchedulerSvc) FindAllScheduler(ctx context.Context) (sList []Scheduler, err error) {
-	result := ss.db.Find(&sList)
+	result := conn(ctx, ss.db).Find(&sList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -68,7 +107,7 @@ func (ss *SchedulerSvc) FindAllScheduler(ctx context.Context) (sList []Scheduler
 }
 
 func (ss *SchedulerSvc) FindSchedulerByID(ctx context.Context, id string) (s *Scheduler, err error) {
-	result := ss.db.First(&s, id)
+	result := conn(ctx, ss.db).First(&s, id)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -77,7 +116,7 @@ func (ss *SchedulerSvc) FindSchedulerByID(ctx context.Context, id string) (s *Sc
 }
 
 func (ss *SchedulerSvc) CreateScheduler(ctx context.Context, s *Scheduler) (*Scheduler, error) {
-	if err := ss.db.Create(&s).Error; err != nil {
+	if err := conn(ctx, ss.db).Create(&s).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -85,20 +124,38 @@ func (ss *SchedulerSvc) CreateScheduler(ctx context.Context, s *Scheduler) (*Sch
 }
 
 func (ss *SchedulerSvc) UpdateScheduler(ctx context.Context, s *Scheduler) (bool, error) {
-	result := ss.db.Model(&s).Where("id = ?", s.ID).Updates(s)
+	if err := checkSchedulerEditable(conn(ctx, ss.db), s.ID); err != nil {
+		return false, err
+	}
+	result := conn(ctx, ss.db).Model(&s).Where("id = ?", s.ID).Updates(s)
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (ss *SchedulerSvc) DeleteScheduler(ctx context.Context, studentId uint) (bool, error) {
-	result := ss.db.Unscoped().Where("id = ?", studentId).Delete(&Scheduler{})
+	if err := checkSchedulerEditable(conn(ctx, ss.db), studentId); err != nil {
+		return false, err
+	}
+	result := conn(ctx, ss.db).Unscoped().Where("id = ?", studentId).Delete(&Scheduler{})
 	return utils.ReturnBoolStateFromResult(result)
 }
 
 func (ss *SchedulerSvc) FindSchedulerByListDoorID(ctx context.Context, doorId []uint) (sList []Scheduler, err error) {
-	result := ss.db.Where("door_id IN ?", doorId).Find(&sList)
+	result := conn(ctx, ss.db).Where("door_id IN ?", doorId).Find(&sList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 	return sList, nil
 }
+
+// Registers generated from an access policy can not be changed one by one
+func checkSchedulerEditable(db *gorm.DB, id uint) error {
+	var cnt int64
+	if err := db.Model(&Scheduler{}).Where("id = ? AND access_policy_id IS NOT NULL", id).Count(&cnt).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	if cnt > 0 {
+		return fmt.Errorf("scheduler is managed by an access policy")
+	}
+	return nil
+}
@@ -17,6 +17,14 @@ type SecretKey struct {
 type UpdateSecretKey struct {
 	Secret string `json:"secret" binding:"required"`
 }
+
+// Secret key shared with gateways
+type SecretKeyService interface {
+	FindSecretKey(ctx context.Context) (sk *SecretKey, err error)
+	CreateSecretKey(ctx context.Context, sk *SecretKey) (*SecretKey, error)
+	UpdateSecretKey(ctx context.Context, sk *SecretKey) (bool, error)
+}
+
 type SecretKeySvc struct {
 	db *gorm.DB
 }
@@ -28,7 +36,7 @@ func NewSecretKeySvc(db *gorm.DB) *SecretKeySvc {
 }
 
 func (sks *SecretKeySvc) FindSecretKey(ctx context.Context) (sk *SecretKey, err error) {
-	result := sks.db.Find(&sk)
+	result := conn(ctx, sks.db).Find(&sk)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -38,11 +46,11 @@ func (sks *SecretKeySvc) FindSecretKey(ctx context.Context) (sk *SecretKey, err
 
 func (sks *SecretKeySvc) CreateSecretKey(ctx context.Context, sk *SecretKey) (*SecretKey, error) {
 	var cnt int64
-	sks.db.Find(&sk).Count(&cnt)
+	conn(ctx, sks.db).Find(&sk).Count(&cnt)
 	if cnt > 0 {
 		return nil, fmt.Errorf("secret key already exist. Use update instead")
 	}
-	if err := sks.db.Create(&sk).Error; err != nil {
+	if err := conn(ctx, sks.db).Create(&sk).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -50,6 +58,6 @@ func (sks *SecretKeySvc) CreateSecretKey(ctx context.Context, sk *SecretKey) (*S
 }
 
 func (sks *SecretKeySvc) UpdateSecretKey(ctx context.Context, sk *SecretKey) (bool, error) {
-	result := sks.db.Model(&sk).Where("id = ?", sk.ID).Updates(sk)
+	result := conn(ctx, sks.db).Model(&sk).Where("id = ?", sk.ID).Updates(sk)
 	return utils.ReturnBoolStateFromResult(result)
 }
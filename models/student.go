@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"gorm.io/gorm"
@@ -10,20 +11,84 @@ import (
 
 type Student struct {
 	GormModel
-	MSSV  string `gorm:"type:varchar(256); unique; not null;" json:"mssv"  binding:"required"`
-	Name  string `json:"name"`
-	Phone string `gorm:"type:varchar(50)" json:"phone"`
-	Email string `gorm:"type:varchar(256); unique; not null;" json:"email"`
-	Major string `gorm:"not null;" json:"major"`
-	UserPass
+	SoftDelete
+	MSSV       string `gorm:"type:varchar(256); unique; not null;" json:"mssv"  binding:"required"`
+	Name       string `json:"name"`
+	Phone      string `gorm:"type:varchar(50)" json:"phone"`
+	Email      string `gorm:"type:varchar(256); unique; not null;" json:"email"`
+	Major      string `gorm:"not null;" json:"major"`
+	UserPass   `gorm:"-"`
+	PersonID   *uint       `gorm:"index" json:"personId"`
+	Person     *Person     `gorm:"foreignKey:PersonID" json:"person,omitempty"`
 	Schedulers []Scheduler `gorm:"foreignKey:StudentID;references:MSSV;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"schedulers"`
 }
 
+// Create person identity of a new student, legacy password fields become its first credentials
+func (s *Student) BeforeCreate(tx *gorm.DB) error {
+	return linkPerson(tx, &s.PersonID, &s.Person, PERSON_TYPE_STUDENT, s.MSSV, s.Name, s.UserPass)
+}
+
+// Load the linked person so the created student carries its credentials
+func (s *Student) AfterCreate(tx *gorm.DB) (err error) {
+	s.Person, err = loadPerson(tx, s.PersonID)
+	return err
+}
+
+// Fill legacy password fields from the first usable credentials of the person
+func (s *Student) AfterFind(tx *gorm.DB) error {
+	s.UserPass = s.Person.PrimaryUserPass(time.Now())
+	return nil
+}
+
+func (s *Student) personInfo() (string, UserPass) {
+	return s.Name, s.UserPass
+}
+
 // Struct defines HTTP request payload for deleting student
 type DeleteStudent struct {
 	MSSV string `json:"mssv" binding:"required"`
 }
 
+// Columns of student import/export sheet
+var StudentSheetColumns = []SheetColumn{
+	{Header: "mssv", Field: "MSSV", Required: true},
+	{Header: "name", Field: "Name"},
+	{Header: "phone", Field: "Phone"},
+	{Header: "email", Field: "Email", Required: true},
+	{Header: "major", Field: "Major", Required: true},
+	{Header: "rfidPass"},
+	{Header: "keypadPass"},
+}
+
+var studentSheet = &userSheet{
+	columns:  StudentSheetColumns,
+	hasEmail: true,
+	newUser:  func() interface{} { return &Student{} },
+	fromValues: func(v map[string]string) (interface{}, error) {
+		return &Student{
+			MSSV:     v["mssv"],
+			Name:     v["name"],
+			Phone:    v["phone"],
+			Email:    v["email"],
+			Major:    v["major"],
+			UserPass: UserPass{RfidPass: v["rfidPass"], KeypadPass: v["keypadPass"]},
+		}, nil
+	},
+}
+
+// Students and their registers
+type StudentService interface {
+	FindAllStudent(ctx context.Context) (sList []Student, err error)
+	FindStudentByMSSV(ctx context.Context, mssv string) (s *Student, err error)
+	CreateStudent(ctx context.Context, s *Student) (*Student, error)
+	UpdateStudent(ctx context.Context, s *Student) (bool, error)
+	DeleteStudent(ctx context.Context, mssv string, deletedBy string) (bool, error)
+	RestoreStudent(ctx context.Context, mssv string) (bool, error)
+	AppendStudentScheduler(ctx context.Context, s *Student, usu *UserSchedulerReq, sche *Scheduler) (*Student, error)
+	AppendStudentSchedulerExcel(ctx context.Context, sche *Scheduler) (*Student, error)
+	ImportStudents(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult
+}
+
 type StudentSvc struct {
 	db *gorm.DB
 }
@@ -35,7 +100,7 @@ func NewStudentSvc(db *gorm.DB) *StudentSvc {
 }
 
 func (ss *StudentSvc) FindAllStudent(ctx context.Context) (sList []Student, err error) {
-	result := ss.db.Preload("Schedulers").Find(&sList)
+	result := conn(ctx, ss.db).Preload("Schedulers").Preload("Person.Credentials").Find(&sList)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -45,7 +110,7 @@ func (ss *StudentSvc) FindAllStudent(ctx context.Context) (sList []Student, err
 
 func (ss *StudentSvc) FindStudentByMSSV(ctx context.Context, mssv string) (s *Student, err error) {
 	var cnt int64
-	result := ss.db.Preload("Schedulers").Where("mssv = ?", mssv).Find(&s).Count(&cnt)
+	result := conn(ctx, ss.db).Preload("Schedulers").Preload("Person.Credentials").Where("mssv = ?", mssv).Find(&s).Count(&cnt)
 	if err := result.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
@@ -59,7 +124,14 @@ func (ss *StudentSvc) FindStudentByMSSV(ctx context.Context, mssv string) (s *St
 }
 
 func (ss *StudentSvc) CreateStudent(ctx context.Context, s *Student) (*Student, error) {
-	if err := ss.db.Create(&s).Error; err != nil {
+	db := conn(ctx, ss.db)
+	if err := checkNotDeleted(db, s, "mssv", s.MSSV); err != nil {
+		return nil, err
+	}
+	if err := checkNotDeleted(db, s, "email", s.Email); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&s).Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -67,32 +139,43 @@ func (ss *StudentSvc) CreateStudent(ctx context.Context, s *Student) (*Student,
 }
 
 func (ss *StudentSvc) UpdateStudent(ctx context.Context, s *Student) (bool, error) {
-	result := ss.db.Model(&s).Where("id = ? AND mssv = ?", s.ID, s.MSSV).Updates(s)
-	return utils.ReturnBoolStateFromResult(result)
+	isSuccess := false
+	err := conn(ctx, ss.db).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result := tx.Model(&s).Omit("Person").Where("id = ? AND mssv = ?", s.ID, s.MSSV).Updates(s)
+		if isSuccess, err = utils.ReturnBoolStateFromResult(result); err != nil {
+			return err
+		}
+		return syncPerson(tx, &Student{}, s.Name, s.UserPass, "id = ?", s.ID)
+	})
+	return isSuccess, err
+}
+
+func (ss *StudentSvc) DeleteStudent(ctx context.Context, mssv string, deletedBy string) (bool, error) {
+	return softDeleteProfile(conn(ctx, ss.db), &Student{}, deletedBy, "mssv = ?", mssv)
 }
 
-func (ss *StudentSvc) DeleteStudent(ctx context.Context, mssv string) (bool, error) {
-	result := ss.db.Unscoped().Where("mssv = ?", mssv).Delete(&Student{})
-	return utils.ReturnBoolStateFromResult(result)
+func (ss *StudentSvc) RestoreStudent(ctx context.Context, mssv string) (bool, error) {
+	return restoreProfile(conn(ctx, ss.db), &Student{}, "mssv = ?", mssv)
 }
 
 func (ss *StudentSvc) AppendStudentScheduler(ctx context.Context, s *Student, usu *UserSchedulerReq, sche *Scheduler) (*Student, error) {
 
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := ss.db.Where("gateway_id = ? AND doorlock_address = ?", usu.GatewayID, usu.DoorlockAddress).First(door)
+	doorResult := conn(ctx, ss.db).Where("gateway_id = ? AND doorlock_address = ?", usu.GatewayID, usu.DoorlockAddress).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := ss.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, ss.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	// Add scheduler for student
-	if err := ss.db.Model(&s).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, ss.db).Model(&s).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -102,13 +185,13 @@ func (ss *StudentSvc) AppendStudentScheduler(ctx context.Context, s *Student, us
 func (ss *StudentSvc) AppendStudentSchedulerExcel(ctx context.Context, sche *Scheduler) (*Student, error) {
 	// Add scheduler for door
 	var door = &Doorlock{}
-	doorResult := ss.db.Where("id = ?", sche.DoorID).First(door)
+	doorResult := conn(ctx, ss.db).Where("id = ?", sche.DoorID).First(door)
 	if err := doorResult.Error; err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
-	if err := ss.db.Model(door).Association("Schedulers").Append(&sche); err != nil {
+	if err := conn(ctx, ss.db).Model(door).Association("Schedulers").Append(&sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
@@ -118,10 +201,24 @@ func (ss *StudentSvc) AppendStudentSchedulerExcel(ctx context.Context, sche *Sch
 	}
 
 	// Add scheduler for student
-	if err := ss.db.Model(&userStu).Association("Schedulers").Append(sche); err != nil {
+	if err := conn(ctx, ss.db).Model(&userStu).Association("Schedulers").Append(sche); err != nil {
 		err = utils.HandleQueryError(err)
 		return nil, err
 	}
 
 	return userStu, nil
 }
+
+// Create or update students keyed on MSSV, only fields of the sheet columns are updated
+func (ss *StudentSvc) ImportStudents(ctx context.Context, fields []string, sheetRows []SheetRow) *ImportResult {
+	return importUsers(conn(ctx, ss.db), studentSheet, fields, sheetRows)
+}
+
+// Convert students to sheet rows in the import format
+func StudentSheet(sList []Student) [][]string {
+	rows := [][]string{SheetHeader(StudentSheetColumns)}
+	for _, s := range sList {
+		rows = append(rows, []string{s.MSSV, s.Name, s.Phone, s.Email, s.Major, s.RfidPass, s.KeypadPass})
+	}
+	return rows
+}
@@ -42,6 +42,7 @@ type SwagUpdateDoorlock struct {
 	FloorId         string `json:"floorId"`
 	RoomId          string `json:"roomId"`
 	Location        string `json:"location"`
+	ReaderDirection string `json:"readerDirection"`
 }
 
 type SwagUpdatePassword struct {
@@ -124,4 +125,80 @@ type SwaggerDoorlockCmd struct {
 	ID       string `json:"id"`
 	State    string `json:"state"`
 	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+type SwagCreateCredential struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Status    string `json:"status"`
+	ValidFrom string `json:"validFrom"`
+	ValidTo   string `json:"validTo"`
+}
+
+type SwagUpdateCredential struct {
+	GormModel
+	Value         string `json:"value"`
+	Status        string `json:"status"`
+	ValidFrom     string `json:"validFrom"`
+	ValidTo       string `json:"validTo"`
+	ClearValidity bool   `json:"clearValidity"`
+}
+
+type SwagCreateGroup struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type SwagUpdateGroup struct {
+	GormModel
+	SwagCreateGroup
+}
+
+type SwagCreateAccessPolicy struct {
+	Name           string `json:"name"`
+	AccessGroupID  uint   `json:"accessGroupId"`
+	DoorGroupID    uint   `json:"doorGroupId"`
+	StartDate      string `json:"startDate"`
+	EndDate        string `json:"endDate"`
+	WeekDay        uint   `json:"weekDay"`
+	StartClassTime uint   `json:"startClassTime"`
+	EndClassTime   uint   `json:"endClassTime"`
+	Recurrence     string `json:"recurrence"`
+	TimeWindows    string `json:"timeWindows"`
+	TimeZone       string `json:"timeZone"`
+}
+
+type SwagUpdateAccessPolicy struct {
+	GormModel
+	SwagCreateAccessPolicy
+}
+
+type SwagUpdateRoomSetting struct {
+	RoomID       string `json:"roomId"`
+	AntiPassback string `json:"antiPassback"`
+}
+
+type SwagCreateScheduledCommand struct {
+	Name            string `json:"name"`
+	Cron            string `json:"cron"`
+	TimeZone        string `json:"timeZone"`
+	TargetType      string `json:"targetType"`
+	TargetID        string `json:"targetId"`
+	Action          string `json:"action"`
+	Duration        string `json:"duration"`
+	MissedRunPolicy string `json:"missedRunPolicy"`
+	Enabled         bool   `json:"enabled"`
+}
+
+type SwagUpdateScheduledCommand struct {
+	GormModel
+	SwagCreateScheduledCommand
+}
+
+type SwagUpdateRetentionPolicy struct {
+	Table       string `json:"table"`
+	MaxAgeHours uint   `json:"maxAgeHours"`
+	MaxRows     uint   `json:"maxRows"`
+	Archive     bool   `json:"archive"`
 }
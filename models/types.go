@@ -3,6 +3,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type GormModel struct {
@@ -11,6 +13,12 @@ type GormModel struct {
 	UpdatedAt time.Time `swaggerignore:"true"`
 }
 
+// Soft deleted rows are hidden from normal queries until restored or purged
+type SoftDelete struct {
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt" swaggertype:"string"`
+	DeletedBy string         `gorm:"type:varchar(256)" json:"deletedBy"`
+}
+
 type DeleteID struct {
 	ID uint `json:"id"`
 }
@@ -30,15 +38,31 @@ type UserSchedulerReq struct {
 
 // Struct defines all services for our IoC
 type ServiceOptions struct {
-	StudentSvc           *StudentSvc
-	CustomerSvc          *CustomerSvc
-	EmployeeSvc          *EmployeeSvc
-	GatewaySvc           *GatewaySvc
-	DoorlockSvc          *DoorlockSvc
-	AreaSvc              *AreaSvc
-	LogSvc               *LogSvc
-	GwNetworkSvc         *GwNetworkSvc
-	SchedulerSvc         *SchedulerSvc
-	SecretKeySvc         *SecretKeySvc
-	DoorlockStatusLogSvc *DoorlockStatusLogSvc
+	StudentSvc           StudentService
+	CustomerSvc          CustomerService
+	EmployeeSvc          EmployeeService
+	GatewaySvc           GatewayService
+	DoorlockSvc          DoorlockService
+	AreaSvc              AreaService
+	LogSvc               LogService
+	GwNetworkSvc         GwNetworkService
+	SchedulerSvc         SchedulerService
+	SecretKeySvc         SecretKeyService
+	DoorlockStatusLogSvc DoorlockStatusLogService
+	RecycleBinSvc        RecycleBinService
+	AuditLogSvc          AuditLogService
+	PersonSvc            PersonService
+	RevocationSvc        CredentialRevocationService
+	AccessGroupSvc       AccessGroupService
+	DoorGroupSvc         DoorGroupService
+	AccessPolicySvc      AccessPolicyService
+	OccupancySvc         OccupancyService
+	UnlockRequestSvc     UnlockRequestService
+	ScheduledCommandSvc  ScheduledCommandService
+	RetentionSvc         RetentionService
+	JobRunner            *JobRunner
+	HealthSvc            HealthService
+	MqttOutboxSvc        MqttOutboxService
+	MqttDeadLetterSvc    MqttDeadLetterService
+	UnitOfWork           UnitOfWork
 }
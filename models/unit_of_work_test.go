@@ -0,0 +1,115 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	db := newTestDb(t)
+	uow := NewUnitOfWork(db)
+	as := NewAreaSvc(db)
+	ctx := context.Background()
+
+	// Rolled back with all its writes when fn fails
+	failed := errors.New("failed")
+	err := uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := as.CreateArea(ctx, &Area{Name: "A", Manager: "M"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("got %v, wanted %v", err, failed)
+	}
+	if aList, _ := as.FindAllArea(ctx); len(aList) != 0 {
+		t.Errorf("got %d areas after rollback, wanted 0", len(aList))
+	}
+
+	// Nested unit of work joins the outer one, committed together
+	err = uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := as.CreateArea(ctx, &Area{Name: "A", Manager: "M"}); err != nil {
+			return err
+		}
+		return uow.Do(ctx, func(ctx context.Context) error {
+			_, err := as.CreateArea(ctx, &Area{Name: "B", Manager: "M"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("unit of work failed: %v", err)
+	}
+	if aList, _ := as.FindAllArea(ctx); len(aList) != 2 {
+		t.Errorf("got %d areas after commit, wanted 2", len(aList))
+	}
+
+	// Nested failure rolls back the outer writes too
+	err = uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := as.CreateArea(ctx, &Area{Name: "C", Manager: "M"}); err != nil {
+			return err
+		}
+		return uow.Do(ctx, func(ctx context.Context) error { return failed })
+	})
+	if err != failed {
+		t.Fatalf("got %v, wanted %v", err, failed)
+	}
+	if aList, _ := as.FindAllArea(ctx); len(aList) != 2 {
+		t.Errorf("got %d areas after nested rollback, wanted 2", len(aList))
+	}
+
+	// Cancelled ctx reaches the queries
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := as.FindAllArea(cancelled); err == nil {
+		t.Error("query with cancelled ctx succeeded")
+	}
+}
+
+func TestUnitOfWorkRollsBackNestedTransactions(t *testing.T) {
+	db := newTestDb(t)
+	uow := NewUnitOfWork(db)
+	ctx := context.Background()
+	ags := NewAccessGroupSvc(db)
+	rs := NewCredentialRevocationSvc(db)
+
+	s, _ := NewStudentSvc(db).CreateStudent(ctx, &Student{MSSV: "s1", Name: "A", Email: "s1@mail", Major: "it",
+		UserPass: UserPass{RfidPass: "card1"}})
+	ag, _ := ags.CreateAccessGroup(ctx, &AccessGroup{Name: "staff"})
+
+	// Services opening their own transaction join the unit of work, and are rolled back with it
+	failed := errors.New("failed")
+	err := uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := ags.AddAccessGroupMembers(ctx, ag.ID, []uint{*s.PersonID}); err != nil {
+			return err
+		}
+		if _, _, err := rs.RevokeCredential(ctx, s.Person.Credentials[0].ID, &RevokeCredentialReq{Reason: "lost"}, "admin"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("got %v, wanted %v", err, failed)
+	}
+	if ag, _ = ags.FindAccessGroupByID(ctx, fmt.Sprint(ag.ID)); len(ag.Members) != 0 {
+		t.Errorf("got %d members after rollback, wanted 0", len(ag.Members))
+	}
+	if rList, _ := rs.FindAllRevocation(ctx, false); len(rList) != 0 {
+		t.Errorf("got %d revocations after rollback, wanted 0", len(rList))
+	}
+
+	err = uow.Do(ctx, func(ctx context.Context) error {
+		_, err := ags.AddAccessGroupMembers(ctx, ag.ID, []uint{*s.PersonID})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unit of work failed: %v", err)
+	}
+	if ag, _ = ags.FindAccessGroupByID(ctx, fmt.Sprint(ag.ID)); len(ag.Members) != 1 {
+		t.Errorf("got %d members after commit, wanted 1", len(ag.Members))
+	}
+}
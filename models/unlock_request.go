@@ -0,0 +1,249 @@
+package models
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	UNLOCK_CMD_DOORLOCK       string = "doorlockCmd"      // PATCH /v1/doorlock/cmd
+	UNLOCK_CMD_DOORLOCK_STATE string = "doorlockStateCmd" // PATCH /v1/doorlock/state/cmd
+	UNLOCK_CMD_BLOCK          string = "blockCmd"         // POST /v1/block/cmd
+	UNLOCK_CMD_APPROVAL_OFF   string = "approvalOff"      // PATCH /v1/doorlock/approval clearing the flag
+
+	UNLOCK_STATUS_PENDING  string = "pending"
+	UNLOCK_STATUS_APPROVED string = "approved"
+	UNLOCK_STATUS_REJECTED string = "rejected"
+	UNLOCK_STATUS_EXPIRED  string = "expired"
+
+	DOORLOCK_CMD_LOCK string = "lock"
+
+	DEFAULT_UNLOCK_APPROVAL_WINDOW time.Duration = 15 * time.Minute
+)
+
+// Returned when requesting, approving or rejecting without the token of an approver
+var ErrApproverTokenInvalid = errors.New("approver token is not valid")
+
+// Remote command on doorlocks requiring approval, it is sent to the gateways once a second operator approves it
+// before ExpiresAt
+type UnlockRequest struct {
+	GormModel
+	Command     string     `gorm:"type:varchar(50);not null" json:"command"`
+	DoorID      *uint      `gorm:"index" json:"doorId"`
+	BlockID     string     `gorm:"type:varchar(256)" json:"blockId"`
+	State       string     `gorm:"type:varchar(50)" json:"state"` // doorlock state or block action
+	Duration    string     `gorm:"type:varchar(50)" json:"duration"`
+	Reason      string     `json:"reason"`
+	Status      string     `gorm:"type:varchar(50);not null;index" json:"status"`
+	RequestedBy string     `gorm:"type:varchar(256);not null" json:"requestedBy"`
+	DecidedBy   string     `gorm:"type:varchar(256)" json:"decidedBy"`
+	DecidedAt   *time.Time `json:"decidedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	ExecutedAt  *time.Time `json:"executedAt"`
+}
+
+// Struct defines HTTP request payload for flagging a doorlock
+type DoorlockApproval struct {
+	ID               uint `json:"id" binding:"required"`
+	RequiresApproval bool `json:"requiresApproval"`
+}
+
+// Every command but lock opens the door
+func IsUnlockCommand(state string) bool {
+	return !strings.EqualFold(state, DOORLOCK_CMD_LOCK)
+}
+
+// Remote unlock requests waiting for a second operator
+type UnlockRequestService interface {
+	FindAllUnlockRequest(ctx context.Context, status string) (urList []UnlockRequest, err error)
+	FindUnlockRequestByID(ctx context.Context, id string) (ur *UnlockRequest, err error)
+	CountBlockApprovalDoorlocks(ctx context.Context, blockID string) (int64, error)
+	CreateUnlockRequest(ctx context.Context, ur *UnlockRequest, approverToken string) (*UnlockRequest, error)
+	IdentifyApprover(token string) (string, bool)
+	ApproveUnlockRequest(ctx context.Context, id uint, approverToken string) (*UnlockRequest, error)
+	RejectUnlockRequest(ctx context.Context, id uint, approverToken string) (*UnlockRequest, error)
+	MarkUnlockRequestExecuted(ctx context.Context, id uint) (bool, error)
+}
+
+// Operator allowed to request and approve unlock requests, identified by a secret token configured on the server
+type UnlockApprover struct {
+	Name  string
+	Token string
+}
+
+// Parse name:token entries of approvers
+func ParseUnlockApprovers(entries []string) ([]UnlockApprover, error) {
+	approvers := []UnlockApprover{}
+	names := map[string]bool{}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("unlock approver must be name:token")
+		}
+		a := UnlockApprover{Name: strings.TrimSpace(parts[0]), Token: strings.TrimSpace(parts[1])}
+		if names[a.Name] {
+			return nil, fmt.Errorf("unlock approver %s is given twice", a.Name)
+		}
+		names[a.Name] = true
+		approvers = append(approvers, a)
+	}
+	return approvers, nil
+}
+
+type UnlockRequestSvc struct {
+	db        *gorm.DB
+	approvers []UnlockApprover
+	window    time.Duration
+}
+
+// Requests can only be created, approved and rejected with the token of one of approvers
+func NewUnlockRequestSvc(db *gorm.DB, approvers []UnlockApprover, window time.Duration) *UnlockRequestSvc {
+	if window <= 0 {
+		window = DEFAULT_UNLOCK_APPROVAL_WINDOW
+	}
+	return &UnlockRequestSvc{
+		db:        db,
+		approvers: approvers,
+		window:    window,
+	}
+}
+
+// Name of the approver holding token, every approver is compared so the time taken does not tell which one matched
+func (urs *UnlockRequestSvc) IdentifyApprover(token string) (string, bool) {
+	name := ""
+	for _, a := range urs.approvers {
+		if subtle.ConstantTimeCompare([]byte(a.Token), []byte(token)) == 1 {
+			name = a.Name
+		}
+	}
+	return name, token != "" && name != ""
+}
+
+// Find unlock requests newest first, filtered by status when given
+func (urs *UnlockRequestSvc) FindAllUnlockRequest(ctx context.Context, status string) (urList []UnlockRequest, err error) {
+	if err := urs.expirePending(ctx); err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	query := conn(ctx, urs.db)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	result := query.Order("id desc").Find(&urList)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return urList, nil
+}
+
+func (urs *UnlockRequestSvc) FindUnlockRequestByID(ctx context.Context, id string) (ur *UnlockRequest, err error) {
+	if err := urs.expirePending(ctx); err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	result := conn(ctx, urs.db).First(&ur, id)
+	if err := result.Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return ur, nil
+}
+
+// Doorlocks of the block requiring approval
+func (urs *UnlockRequestSvc) CountBlockApprovalDoorlocks(ctx context.Context, blockID string) (int64, error) {
+	var cnt int64
+	result := conn(ctx, urs.db).Model(&Doorlock{}).Where("block_id = ? AND requires_approval = ?", blockID, true).Count(&cnt)
+	if err := result.Error; err != nil {
+		return 0, utils.HandleQueryError(err)
+	}
+	return cnt, nil
+}
+
+// Create pending request of the approver holding the token, it expires after the approval window.
+// The requester is only taken from the token so it can not approve its own request under another name
+func (urs *UnlockRequestSvc) CreateUnlockRequest(ctx context.Context, ur *UnlockRequest, approverToken string) (*UnlockRequest, error) {
+	requester, ok := urs.IdentifyApprover(approverToken)
+	if !ok {
+		return nil, ErrApproverTokenInvalid
+	}
+	ur.RequestedBy = requester
+	ur.Status = UNLOCK_STATUS_PENDING
+	ur.DecidedBy, ur.DecidedAt, ur.ExecutedAt = "", nil, nil
+	ur.ExpiresAt = time.Now().Add(urs.window)
+	if err := conn(ctx, urs.db).Create(ur).Error; err != nil {
+		err = utils.HandleQueryError(err)
+		return nil, err
+	}
+	return ur, nil
+}
+
+// Approve pending request with the token of an approver, who can not be the requester
+func (urs *UnlockRequestSvc) ApproveUnlockRequest(ctx context.Context, id uint, approverToken string) (*UnlockRequest, error) {
+	approver, ok := urs.IdentifyApprover(approverToken)
+	if !ok {
+		return nil, ErrApproverTokenInvalid
+	}
+	return urs.decide(ctx, id, approver, UNLOCK_STATUS_APPROVED, func(ur *UnlockRequest) error {
+		if approver == ur.RequestedBy {
+			return fmt.Errorf("unlock request must be approved by another operator")
+		}
+		return nil
+	})
+}
+
+// Reject pending request with the token of an approver, the requester included to cancel it
+func (urs *UnlockRequestSvc) RejectUnlockRequest(ctx context.Context, id uint, approverToken string) (*UnlockRequest, error) {
+	approver, ok := urs.IdentifyApprover(approverToken)
+	if !ok {
+		return nil, ErrApproverTokenInvalid
+	}
+	return urs.decide(ctx, id, approver, UNLOCK_STATUS_REJECTED, nil)
+}
+
+func (urs *UnlockRequestSvc) decide(ctx context.Context, id uint, decider string, status string, allowed func(ur *UnlockRequest) error) (*UnlockRequest, error) {
+	if err := urs.expirePending(ctx); err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	ur := &UnlockRequest{}
+	if err := conn(ctx, urs.db).First(ur, id).Error; err != nil {
+		return nil, utils.HandleQueryError(err)
+	}
+	if ur.Status != UNLOCK_STATUS_PENDING {
+		return nil, fmt.Errorf("unlock request is %s", ur.Status)
+	}
+	if allowed != nil {
+		if err := allowed(ur); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	// Status condition keeps two operators from deciding the same request
+	result := conn(ctx, urs.db).Model(&UnlockRequest{}).Where("id = ? AND status = ?", id, UNLOCK_STATUS_PENDING).
+		Updates(map[string]interface{}{"status": status, "decided_by": decider, "decided_at": now})
+	if _, err := utils.ReturnBoolStateFromResult(result); err != nil {
+		return nil, err
+	}
+	ur.Status, ur.DecidedBy, ur.DecidedAt = status, decider, &now
+	return ur, nil
+}
+
+// Approved command was sent to the gateways
+func (urs *UnlockRequestSvc) MarkUnlockRequestExecuted(ctx context.Context, id uint) (bool, error) {
+	result := conn(ctx, urs.db).Model(&UnlockRequest{}).Where("id = ?", id).Update("executed_at", time.Now())
+	return utils.ReturnBoolStateFromResult(result)
+}
+
+func (urs *UnlockRequestSvc) expirePending(ctx context.Context) error {
+	return conn(ctx, urs.db).Model(&UnlockRequest{}).Where("status = ? AND expires_at < ?", UNLOCK_STATUS_PENDING, time.Now()).
+		Update("status", UNLOCK_STATUS_EXPIRED).Error
+}
@@ -0,0 +1,103 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUnlockRequestDecision(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	approvers, err := ParseUnlockApprovers([]string{"alice:a-secret", " bob : b-secret ", "carol:c-secret"})
+	if err != nil {
+		t.Fatalf("parse approvers failed: %v", err)
+	}
+	urs := NewUnlockRequestSvc(db, approvers, time.Minute)
+
+	create := func(token string) *UnlockRequest {
+		t.Helper()
+		ur, err := urs.CreateUnlockRequest(ctx, &UnlockRequest{Command: UNLOCK_CMD_BLOCK, BlockID: "b1", State: "unlock"}, token)
+		if err != nil {
+			t.Fatalf("create unlock request failed: %v", err)
+		}
+		if ur.Status != UNLOCK_STATUS_PENDING {
+			t.Fatalf("expected pending request, got %s", ur.Status)
+		}
+		return ur
+	}
+
+	// Requester is only taken from the token
+	for _, token := range []string{"", "alice", "mallory"} {
+		if _, err := urs.CreateUnlockRequest(ctx, &UnlockRequest{Command: UNLOCK_CMD_BLOCK, RequestedBy: "bob"}, token); !errors.Is(err, ErrApproverTokenInvalid) {
+			t.Fatalf("got %v for token %q, wanted %v", err, token, ErrApproverTokenInvalid)
+		}
+	}
+	ur, err := urs.CreateUnlockRequest(ctx, &UnlockRequest{Command: UNLOCK_CMD_BLOCK, RequestedBy: "bob"}, "a-secret")
+	if err != nil || ur.RequestedBy != "alice" {
+		t.Fatalf("expected request of alice, got %+v, %v", ur, err)
+	}
+	if _, err := urs.ApproveUnlockRequest(ctx, ur.ID, "a-secret"); err == nil {
+		t.Fatalf("expected requester to be refused as approver")
+	}
+	for _, token := range []string{"", "bob", "b-secre", "mallory"} {
+		if _, err := urs.ApproveUnlockRequest(ctx, ur.ID, token); !errors.Is(err, ErrApproverTokenInvalid) {
+			t.Fatalf("got %v for token %q, wanted %v", err, token, ErrApproverTokenInvalid)
+		}
+	}
+	approved, err := urs.ApproveUnlockRequest(ctx, ur.ID, "b-secret")
+	if err != nil || approved.Status != UNLOCK_STATUS_APPROVED || approved.DecidedBy != "bob" {
+		t.Fatalf("expected request approved by bob, got %+v, %v", approved, err)
+	}
+	if _, err := urs.RejectUnlockRequest(ctx, ur.ID, "a-secret"); err == nil {
+		t.Fatalf("expected second decision to be refused")
+	}
+
+	// Requester cancels its own request with its token, nobody rejects without one
+	ur = create("c-secret")
+	if _, err := urs.RejectUnlockRequest(ctx, ur.ID, ""); !errors.Is(err, ErrApproverTokenInvalid) {
+		t.Fatalf("got %v, wanted %v", err, ErrApproverTokenInvalid)
+	}
+	if rejected, err := urs.RejectUnlockRequest(ctx, ur.ID, "c-secret"); err != nil || rejected.Status != UNLOCK_STATUS_REJECTED || rejected.DecidedBy != "carol" {
+		t.Fatalf("expected carol to cancel its request, got %+v, %v", rejected, err)
+	}
+	ur = create("c-secret")
+	if rejected, err := urs.RejectUnlockRequest(ctx, ur.ID, "a-secret"); err != nil || rejected.DecidedBy != "alice" {
+		t.Fatalf("expected request rejected by alice, got %+v, %v", rejected, err)
+	}
+
+	ur = create("a-secret")
+	db.Model(&UnlockRequest{}).Where("id = ?", ur.ID).Update("expires_at", time.Now().Add(-time.Second))
+	if _, err := urs.ApproveUnlockRequest(ctx, ur.ID, "b-secret"); err == nil {
+		t.Fatalf("expected expired request to be refused")
+	}
+	if urList, _ := urs.FindAllUnlockRequest(ctx, UNLOCK_STATUS_EXPIRED); len(urList) != 1 || urList[0].ID != ur.ID {
+		t.Fatalf("expected 1 expired request, got %+v", urList)
+	}
+
+	// Nobody can request nor approve without configured approvers
+	ur = create("a-secret")
+	noApprovers := NewUnlockRequestSvc(db, nil, time.Minute)
+	if _, err := noApprovers.ApproveUnlockRequest(ctx, ur.ID, ""); err == nil {
+		t.Fatalf("expected approval without approvers to be refused")
+	}
+	if _, err := noApprovers.CreateUnlockRequest(ctx, &UnlockRequest{Command: UNLOCK_CMD_BLOCK}, ""); err == nil {
+		t.Fatalf("expected request without approvers to be refused")
+	}
+}
+
+func TestParseUnlockApprovers(t *testing.T) {
+	for _, entries := range [][]string{{"alice"}, {"alice:"}, {":secret"}, {"alice:a", "alice:b"}} {
+		if _, err := ParseUnlockApprovers(entries); err == nil {
+			t.Errorf("expected %v to be refused", entries)
+		}
+	}
+	approvers, err := ParseUnlockApprovers([]string{"alice:a:b", ""})
+	if err != nil || len(approvers) != 1 || approvers[0].Token != "a:b" {
+		t.Errorf("expected token a:b of alice, got %+v, %v", approvers, err)
+	}
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	IMPORT_RESULT_CREATED string = "created"
+	IMPORT_RESULT_UPDATED string = "updated"
+	IMPORT_RESULT_FAILED  string = "failed"
+)
+
+// Struct defines a column of user import/export sheet, Field is the struct field updated by the column
+type SheetColumn struct {
+	Header   string
+	Field    string
+	Required bool
+}
+
+// Struct defines a sheet row with values by column header, Row is the row number in file with header as row 1
+type SheetRow struct {
+	Row    int
+	Values map[string]string
+}
+
+// Struct defines import result of one sheet row, Before and After are user snapshots for auditing and MQTT
+type ImportRowResult struct {
+	Row    int         `json:"row"`
+	UserID string      `json:"userId"`
+	Result string      `json:"result"`
+	Error  string      `json:"error,omitempty"`
+	Before interface{} `json:"-"`
+	After  interface{} `json:"-"`
+}
+
+type ImportResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// Struct defines how a user type is read from sheet rows, first column is the unique key
+type userSheet struct {
+	columns    []SheetColumn
+	hasEmail   bool
+	newUser    func() interface{}
+	fromValues func(values map[string]string) (interface{}, error)
+}
+
+func (us *userSheet) key() string {
+	return us.columns[0].Header
+}
+
+// Map rows to column headers, first row must be the header and contain the key column
+func ParseUserSheet(rows [][]string, columns []SheetColumn) ([]string, []SheetRow, error) {
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("sheet is empty")
+	}
+
+	colByIndex := map[int]SheetColumn{}
+	fields := []string{}
+	seen := map[string]bool{}
+	for i, h := range rows[0] {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		col, ok := findSheetColumn(columns, h)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown column %s", h)
+		}
+		if seen[col.Header] {
+			return nil, nil, fmt.Errorf("duplicated column %s", h)
+		}
+		seen[col.Header] = true
+		colByIndex[i] = col
+		// Credential columns have no profile field, they are saved to the person of the user
+		if col.Field != "" {
+			fields = append(fields, col.Field)
+		}
+	}
+	if !seen[columns[0].Header] {
+		return nil, nil, fmt.Errorf("missing key column %s", columns[0].Header)
+	}
+
+	sheetRows := []SheetRow{}
+	for r := 1; r < len(rows); r++ {
+		values := map[string]string{}
+		isEmpty := true
+		for i, col := range colByIndex {
+			v := ""
+			if i < len(rows[r]) {
+				v = strings.TrimSpace(rows[r][i])
+			}
+			if v != "" {
+				isEmpty = false
+			}
+			values[col.Header] = v
+		}
+		if isEmpty {
+			continue
+		}
+		sheetRows = append(sheetRows, SheetRow{Row: r + 1, Values: values})
+	}
+	return fields, sheetRows, nil
+}
+
+func SheetHeader(columns []SheetColumn) []string {
+	header := []string{}
+	for _, col := range columns {
+		header = append(header, col.Header)
+	}
+	return header
+}
+
+func findSheetColumn(columns []SheetColumn, header string) (SheetColumn, bool) {
+	for _, col := range columns {
+		if strings.EqualFold(col.Header, header) {
+			return col, true
+		}
+	}
+	return SheetColumn{}, false
+}
+
+// Create or update users row by row, a failed row does not stop the others
+func importUsers(db *gorm.DB, us *userSheet, fields []string, sheetRows []SheetRow) *ImportResult {
+	ir := &ImportResult{Rows: []ImportRowResult{}}
+	keyRows := map[string]int{}
+	emailKeys := map[string]string{}
+	for _, sr := range sheetRows {
+		rr := ImportRowResult{Row: sr.Row, UserID: sr.Values[us.key()]}
+		if err := importUserRow(db, us, fields, sr, keyRows, emailKeys, &rr); err != nil {
+			rr.Result = IMPORT_RESULT_FAILED
+			rr.Error = err.Error()
+			rr.Before, rr.After = nil, nil
+		}
+		switch rr.Result {
+		case IMPORT_RESULT_CREATED:
+			ir.Created++
+		case IMPORT_RESULT_UPDATED:
+			ir.Updated++
+		default:
+			ir.Failed++
+		}
+		ir.Rows = append(ir.Rows, rr)
+	}
+	return ir
+}
+
+func importUserRow(db *gorm.DB, us *userSheet, fields []string, sr SheetRow,
+	keyRows map[string]int, emailKeys map[string]string, rr *ImportRowResult) error {
+	key := sr.Values[us.key()]
+	if key == "" {
+		return fmt.Errorf("%s is required", us.key())
+	}
+	if row, ok := keyRows[key]; ok {
+		return fmt.Errorf("%s %s is duplicated with row %d", us.key(), key, row)
+	}
+	keyRows[key] = sr.Row
+
+	user, err := us.fromValues(sr.Values)
+	if err != nil {
+		return err
+	}
+
+	existing := us.newUser()
+	result := db.Preload("Person.Credentials").Where(us.key()+" = ?", key).Limit(1).Find(existing)
+	if err := result.Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	isCreate := result.RowsAffected == 0
+	if isCreate {
+		var deleted int64
+		if err := db.Unscoped().Model(us.newUser()).Where(us.key()+" = ? AND deleted_at IS NOT NULL", key).Count(&deleted).Error; err != nil {
+			return utils.HandleQueryError(err)
+		}
+		if deleted > 0 {
+			return fmt.Errorf("%s %s is deleted, restore it before importing", us.key(), key)
+		}
+	}
+
+	// Required columns must be filled on create, and when present in sheet on update
+	for _, col := range us.columns {
+		v, inSheet := sr.Values[col.Header]
+		if col.Required && (isCreate || inSheet) && v == "" {
+			return fmt.Errorf("%s is required", col.Header)
+		}
+	}
+
+	if email := sr.Values["email"]; us.hasEmail && email != "" {
+		lowerEmail := strings.ToLower(email)
+		if otherKey, ok := emailKeys[lowerEmail]; ok && otherKey != key {
+			return fmt.Errorf("email %s is duplicated with %s %s", email, us.key(), otherKey)
+		}
+		var cnt int64
+		if err := db.Unscoped().Model(us.newUser()).Where("email = ? AND "+us.key()+" <> ?", email, key).Count(&cnt).Error; err != nil {
+			return utils.HandleQueryError(err)
+		}
+		if cnt > 0 {
+			return fmt.Errorf("email %s is used by another %s", email, us.key())
+		}
+		emailKeys[lowerEmail] = key
+	}
+
+	if isCreate {
+		if err := db.Create(user).Error; err != nil {
+			return utils.HandleQueryError(err)
+		}
+		rr.Result = IMPORT_RESULT_CREATED
+		rr.After = user
+		return nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(us.newUser()).Where(us.key()+" = ?", key).Select(fields).Updates(user).Error; err != nil {
+			return err
+		}
+		name, up := user.(personProfile).personInfo()
+		return syncPerson(tx, us.newUser(), name, up, us.key()+" = ?", key)
+	})
+	if err != nil {
+		return utils.HandleQueryError(err)
+	}
+	after := us.newUser()
+	if err := db.Preload("Person.Credentials").Where(us.key()+" = ?", key).First(after).Error; err != nil {
+		return utils.HandleQueryError(err)
+	}
+	rr.Result = IMPORT_RESULT_UPDATED
+	rr.Before = existing
+	rr.After = after
+	return nil
+}
@@ -0,0 +1,115 @@
+//go:build unit
+// +build unit
+
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseUserSheet(t *testing.T) {
+	rows := [][]string{
+		{"MSSV", " email ", "major"},
+		{"s1", "s1@mail", "it"},
+		{"", "", ""},
+		{"s2", "s2@mail"},
+	}
+	fields, sheetRows, err := ParseUserSheet(rows, StudentSheetColumns)
+	if err != nil {
+		t.Fatalf("parse sheet failed: %v", err)
+	}
+	if len(fields) != 3 || fields[0] != "MSSV" || fields[1] != "Email" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if len(sheetRows) != 2 || sheetRows[1].Row != 4 {
+		t.Fatalf("expected empty row skipped, got %+v", sheetRows)
+	}
+	if v, ok := sheetRows[1].Values["major"]; !ok || v != "" {
+		t.Fatalf("expected missing cell as empty value, got %q", v)
+	}
+
+	if _, _, err := ParseUserSheet([][]string{{"email", "major"}}, StudentSheetColumns); err == nil {
+		t.Fatalf("expected error on missing key column")
+	}
+	if _, _, err := ParseUserSheet([][]string{{"mssv", "grade"}}, StudentSheetColumns); err == nil {
+		t.Fatalf("expected error on unknown column")
+	}
+}
+
+func TestImportStudents(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	ss := NewStudentSvc(db)
+
+	ss.CreateStudent(ctx, &Student{MSSV: "s1", Name: "old", Email: "s1@mail", Major: "it", UserPass: UserPass{RfidPass: "1"}})
+	ss.CreateStudent(ctx, &Student{MSSV: "s9", Email: "s9@mail", Major: "it"})
+	ss.CreateStudent(ctx, &Student{MSSV: "deleted", Email: "deleted@mail", Major: "it"})
+	ss.DeleteStudent(ctx, "deleted", "admin")
+
+	fields, sheetRows, err := ParseUserSheet([][]string{
+		{"mssv", "name", "email", "major", "rfidPass"},
+		{"s1", "new", "s1@mail", "it", ""},
+		{"s2", "two", "s2@mail", "math", "22"},
+		{"s3", "three", "s9@mail", "math", ""},
+		{"s4", "four", "s4@mail", "", ""},
+		{"s2", "again", "s2b@mail", "math", ""},
+		{"deleted", "d", "deleted@mail", "it", ""},
+	}, StudentSheetColumns)
+	if err != nil {
+		t.Fatalf("parse sheet failed: %v", err)
+	}
+
+	ir := ss.ImportStudents(ctx, fields, sheetRows)
+	if ir.Created != 1 || ir.Updated != 1 || ir.Failed != 4 {
+		t.Fatalf("unexpected import result %+v", ir)
+	}
+	expected := []string{IMPORT_RESULT_UPDATED, IMPORT_RESULT_CREATED, IMPORT_RESULT_FAILED,
+		IMPORT_RESULT_FAILED, IMPORT_RESULT_FAILED, IMPORT_RESULT_FAILED}
+	for i, rr := range ir.Rows {
+		if rr.Result != expected[i] {
+			t.Fatalf("row %d expected %s, got %+v", rr.Row, expected[i], rr)
+		}
+	}
+
+	// Empty credential cells leave credentials of the person untouched
+	s1, _ := ss.FindStudentByMSSV(ctx, "s1")
+	if s1.Name != "new" || s1.RfidPass != "1" || s1.Person.Name != "new" {
+		t.Fatalf("expected sheet columns to overwrite student, got %+v", s1)
+	}
+	s2, _ := ss.FindStudentByMSSV(ctx, "s2")
+	if s2.RfidPass != "22" {
+		t.Fatalf("expected credential of created student, got %+v", s2)
+	}
+	if before := ir.Rows[0].Before.(*Student); before.Name != "old" {
+		t.Fatalf("expected before snapshot, got %+v", before)
+	}
+}
+
+func TestImportEmployeesKeepsMissingColumns(t *testing.T) {
+	db := newTestDb(t)
+	ctx := context.Background()
+	es := NewEmployeeSvc(db)
+
+	es.CreateEmployee(ctx, &Employee{MSNV: "e1", Name: "emp", Email: "e1@mail", Role: "staff", HighestPriority: true})
+
+	fields, sheetRows, _ := ParseUserSheet([][]string{
+		{"msnv", "keypadPass", "highestPriority"},
+		{"e1", "1234", "false"},
+		{"e2", "", "maybe"},
+	}, EmployeeSheetColumns)
+	ir := es.ImportEmployees(ctx, fields, sheetRows)
+	if ir.Updated != 1 || ir.Failed != 1 {
+		t.Fatalf("unexpected import result %+v", ir)
+	}
+
+	e1, _ := es.FindEmployeeByMSNV(ctx, "e1")
+	if e1.Name != "emp" || e1.Role != "staff" || e1.KeypadPass != "1234" || e1.HighestPriority {
+		t.Fatalf("unexpected employee after import %+v", e1)
+	}
+
+	rows := EmployeeSheet([]Employee{*e1})
+	if len(rows) != 2 || rows[1][0] != "e1" || rows[1][8] != "false" {
+		t.Fatalf("unexpected export rows %v", rows)
+	}
+}
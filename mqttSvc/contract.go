@@ -0,0 +1,256 @@
+package mqttSvc
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/tidwall/gjson"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//go:generate go run ../cmd/mqttdoc -o ../docs/mqtt_contracts.md
+
+// Version of the payload envelope, gateways may omit it and a missing version is 1
+const CONTRACT_VERSION int = 1
+
+const (
+	CONTRACT_INBOUND  string = "gateway to server"
+	CONTRACT_OUTBOUND string = "server to gateway"
+)
+
+// Payload of every topic except server/gateway/update and server/lastwill
+type Envelope struct {
+	Version   int         `json:"version"`
+	GatewayId string      `json:"gateway_id"`
+	Message   interface{} `json:"message"`
+}
+
+// Integer sent by gateways either as a JSON number or as a string of digits, empty is 0
+type FlexInt int64
+
+func (fi *FlexInt) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*fi = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", string(b))
+	}
+	*fi = FlexInt(n)
+	return nil
+}
+
+// Text sent by gateways either as a JSON string or as any other JSON value, kept as its raw JSON
+type FlexString string
+
+func (fs *FlexString) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*fs = FlexString(s)
+		return nil
+	}
+	*fs = FlexString(b)
+	return nil
+}
+
+// Payloads of the gateway topics
+
+type GatewayEnvelope struct {
+	Version   int        `json:"version,omitempty"`
+	GatewayId string     `json:"gateway_id"`
+	Message   FlexString `json:"message,omitempty"`
+}
+
+type GatewayInterface struct {
+	InterfaceName      string `json:"interface_name"`
+	PrimaryIpAddress   string `json:"primary_ip_address"`
+	SecondaryIpAddress string `json:"secondary_ip_address"`
+	MacAddress         string `json:"mac_address"`
+}
+
+type GatewaySystem struct {
+	SecretKey       string             `json:"secret_key"`
+	SoftwareVersion string             `json:"software_version"`
+	Interfaces      []GatewayInterface `json:"interfaces"`
+}
+
+type GatewayBootupDoorlock struct {
+	DoorlockAddress  string `json:"doorlock_address"`
+	DoorlockSerialId string `json:"doorlock_serial_id,omitempty"`
+	Location         string `json:"location"`
+	Description      string `json:"description"`
+}
+
+type GatewayBootupMessage struct {
+	System    GatewaySystem           `json:"system"`
+	Doorlocks []GatewayBootupDoorlock `json:"doorlocks"`
+}
+
+type GatewayBootupPayload struct {
+	Version   int                  `json:"version,omitempty"`
+	GatewayId string               `json:"gateway_id"`
+	Message   GatewayBootupMessage `json:"message"`
+}
+
+type GatewayLogMessage struct {
+	LogType string     `json:"log_type"`
+	LogData FlexString `json:"log_data"`
+	LogTime FlexInt    `json:"log_time"` // unix seconds
+}
+
+type GatewayLogPayload struct {
+	Version   int               `json:"version,omitempty"`
+	GatewayId string            `json:"gateway_id"`
+	Message   GatewayLogMessage `json:"message"`
+}
+
+// Doorlock of gateway/doorlock/* topics, states missing from an update are left unchanged
+type GatewayDoorlockMessage struct {
+	DoorlockAddress string  `json:"doorlock_address"`
+	ActiveState     string  `json:"doorlock_active_state,omitempty"`
+	ConnectState    string  `json:"doorlock_connect_state,omitempty"`
+	OpenState       string  `json:"doorlock_open_state,omitempty"`
+	LockState       string  `json:"doorlock_lock_state,omitempty"`
+	LastOpenTime    FlexInt `json:"last_open_time,omitempty"`
+}
+
+type GatewayDoorlockPayload struct {
+	Version   int                    `json:"version,omitempty"`
+	GatewayId string                 `json:"gateway_id"`
+	Message   GatewayDoorlockMessage `json:"message"`
+}
+
+type GatewayRevokeAckMessage struct {
+	RevocationId FlexInt `json:"revocation_id"`
+}
+
+type GatewayRevokeAckPayload struct {
+	Version   int                     `json:"version,omitempty"`
+	GatewayId string                  `json:"gateway_id"`
+	Message   GatewayRevokeAckMessage `json:"message"`
+}
+
+type GatewayAccessEventMessage struct {
+	DoorlockAddress string  `json:"doorlock_address"`
+	UserId          string  `json:"user_id"`
+	EventTime       FlexInt `json:"event_time"` // unix seconds, receive time when missing
+	AccessResult    string  `json:"access_result"`
+	Reason          string  `json:"reason,omitempty"`
+	Direction       string  `json:"direction,omitempty"`
+}
+
+type GatewayAccessEventPayload struct {
+	Version   int                       `json:"version,omitempty"`
+	GatewayId string                    `json:"gateway_id"`
+	Message   GatewayAccessEventMessage `json:"message"`
+}
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// Contract of a topic, inbound payloads are validated against Schema
+type Contract struct {
+	Topic       string
+	Direction   string
+	Description string
+	Schema      string        // file in schemas/, inbound only
+	Examples    []interface{} // whole payloads
+}
+
+func (ct Contract) SchemaJSON() string {
+	if ct.Schema == "" {
+		return ""
+	}
+	b, _ := schemaFS.ReadFile("schemas/" + ct.Schema)
+	return string(b)
+}
+
+var inboundSchemas = map[string]*gojsonschema.Schema{}
+
+func init() {
+	for _, ct := range Contracts() {
+		if ct.Schema == "" {
+			continue
+		}
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(ct.SchemaJSON()))
+		if err != nil {
+			panic(fmt.Sprintf("invalid schema %s: %s", ct.Schema, err.Error()))
+		}
+		inboundSchemas[ct.Topic] = schema
+	}
+}
+
+// Check payload against the contract of topic. Old firmwares sending message as a JSON string are
+// still accepted, the returned payload has it unwrapped
+func ValidatePayload(topic string, payload []byte) ([]byte, error) {
+	payload = unwrapMessage(payload)
+	schema, ok := inboundSchemas[topic]
+	if !ok {
+		return payload, nil
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(payload))
+	if err != nil {
+		return payload, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if !result.Valid() {
+		errs := []string{}
+		for _, e := range result.Errors() {
+			errs = append(errs, e.String())
+		}
+		return payload, fmt.Errorf("invalid payload: %s", strings.Join(errs, "; "))
+	}
+	if version := gjson.GetBytes(payload, "version").Int(); version > int64(CONTRACT_VERSION) {
+		return payload, fmt.Errorf("unsupported contract version %d", version)
+	}
+	return payload, nil
+}
+
+func unwrapMessage(payload []byte) []byte {
+	msg := gjson.GetBytes(payload, "message")
+	if msg.Type != gjson.String || !strings.HasPrefix(strings.TrimSpace(msg.Str), "{") || !gjson.Valid(msg.Str) {
+		return payload
+	}
+	envelope := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return payload
+	}
+	envelope["message"] = json.RawMessage(msg.Str)
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return payload
+	}
+	return b
+}
+
+// Message with its payload checked against the topic contract
+type contractMessage struct {
+	mqtt.Message
+	payload []byte
+}
+
+func (cm *contractMessage) Payload() []byte { return cm.payload }
+
+// Handle only messages matching the topic contract
+func validateHandler(topic string, handler GatewayHandler) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload, err := ValidatePayload(topic, msg.Payload())
+		if err != nil {
+			return err
+		}
+		return handler(c, &contractMessage{Message: msg, payload: payload})
+	}
+}
+
+// Decode checked payload, only fails on a payload the schema does not describe
+func decodePayload(msg mqtt.Message, v interface{}) error {
+	if err := json.Unmarshal(msg.Payload(), v); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package mqttSvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const contractDocHeader = `# MQTT payload contracts
+
+Generated by ` + "`go generate ./mqttSvc`" + ` from mqttSvc/contract_topics.go and mqttSvc/schemas, do not edit.
+
+Contract version: %d
+
+Payloads are JSON objects. Except on server/gateway/update and server/lastwill they are an envelope:
+
+| Field | Type | Description |
+|---|---|---|
+| version | integer | Contract version, a missing version is 1. The server rejects versions it does not know |
+| gateway_id | string | ID of the gateway sending or receiving the message |
+| message | object | Topic specific message. Old firmwares may send it as a JSON encoded string |
+
+Inbound messages not matching their schema are not processed, they are kept in the mqtt_dead_letters table.
+`
+
+// Markdown document of every topic contract
+func ContractDoc() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, contractDocHeader, CONTRACT_VERSION)
+
+	for _, direction := range []string{CONTRACT_INBOUND, CONTRACT_OUTBOUND} {
+		fmt.Fprintf(&b, "\n## %s%s\n", strings.ToUpper(direction[:1]), direction[1:])
+		for _, ct := range Contracts() {
+			if ct.Direction != direction {
+				continue
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", ct.Topic, ct.Description)
+			if schema := ct.SchemaJSON(); schema != "" {
+				fmt.Fprintf(&b, "\nSchema (%s):\n\n```json\n%s\n```\n", ct.Schema, strings.TrimSpace(schema))
+			}
+			for _, example := range ct.Examples {
+				fmt.Fprintf(&b, "\nExample:\n\n```json\n%s\n```\n", indentExample(example))
+			}
+		}
+	}
+	return b.String()
+}
+
+func indentExample(example interface{}) string {
+	raw, err := json.Marshal(example)
+	if err != nil {
+		return err.Error()
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return out.String()
+}
@@ -0,0 +1,126 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+func TestContractExamples(t *testing.T) {
+	for _, ct := range Contracts() {
+		if len(ct.Examples) == 0 && ct.Schema != "" {
+			t.Errorf("%s has a schema but no example", ct.Topic)
+		}
+		for _, example := range ct.Examples {
+			b, err := json.Marshal(example)
+			if err != nil {
+				t.Fatalf("%s: marshal example failed: %v", ct.Topic, err)
+			}
+			if _, err := ValidatePayload(ct.Topic, b); err != nil {
+				t.Errorf("%s: example rejected: %v", ct.Topic, err)
+			}
+		}
+	}
+}
+
+func TestValidatePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		valid   bool
+	}{
+		{"version missing", TOPIC_GW_DOORLOCK_U, `{"gateway_id":"gw-1","message":{"doorlock_address":"3","doorlock_open_state":"open"}}`, true},
+		{"numeric string", TOPIC_GW_LOG_C, `{"gateway_id":"gw-1","message":{"log_type":"info","log_data":"boot","log_time":"1664557199"}}`, true},
+		{"gateway missing", TOPIC_GW_DOORLOCK_U, `{"message":{"doorlock_address":"3"}}`, false},
+		{"doorlock missing", TOPIC_GW_DOORLOCK_U, `{"gateway_id":"gw-1","message":{}}`, false},
+		{"newer version", TOPIC_GW_DOORLOCK_U, `{"version":2,"gateway_id":"gw-1","message":{"doorlock_address":"3"}}`, false},
+		{"unknown access result", TOPIC_GW_ACCESS_EVENT, `{"gateway_id":"gw-1","message":{"doorlock_address":"3","access_result":"maybe"}}`, false},
+		{"not JSON", TOPIC_GW_DOORLOCK_U, `{"gateway_id":"gw-1",}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidatePayload(tt.topic, []byte(tt.payload))
+			if (err == nil) != tt.valid {
+				t.Errorf("got error %v, wanted valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidatePayloadStringMessage(t *testing.T) {
+	payload := `{"gateway_id":"gw-1","message":"{\"doorlock_address\":\"3\",\"doorlock_lock_state\":\"lock\"}"}`
+
+	checked, err := ValidatePayload(TOPIC_GW_DOORLOCK_U, []byte(payload))
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	dl := &GatewayDoorlockPayload{}
+	if err := json.Unmarshal(checked, dl); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if dl.Message.DoorlockAddress != "3" || dl.Message.LockState != "lock" {
+		t.Errorf("got message %+v, wanted address 3 and lock state lock", dl.Message)
+	}
+}
+
+func TestOutboundPayloadEscaping(t *testing.T) {
+	gw := &models.Gateway{GatewayID: "gw-1", Name: `Block "A" C:\gw`}
+	dl := &models.Doorlock{GatewayID: "gw-1", DoorlockAddress: `3"`}
+
+	tests := []struct {
+		payload string
+		path    string
+		want    string
+	}{
+		{ServerUpdateGatewayPayload(gw), "name", gw.Name},
+		{ServerCreateDoorlockPayload(dl), "message.doorlock_address", dl.DoorlockAddress},
+		{ServerDeleteUserPayload("gw-1", `46"01`), "message.user_id", `46"01`},
+	}
+	for _, tt := range tests {
+		if !gjson.Valid(tt.payload) {
+			t.Fatalf("got invalid JSON %s", tt.payload)
+		}
+		if got := gjson.Get(tt.payload, tt.path).String(); got != tt.want {
+			t.Errorf("got %s %s, wanted %s", tt.path, got, tt.want)
+		}
+		if got := gjson.Get(tt.payload, "version").Int(); got != int64(CONTRACT_VERSION) {
+			t.Errorf("got version %d, wanted %d", got, CONTRACT_VERSION)
+		}
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	received := 0
+	handler := validateHandler(TOPIC_GW_DOORLOCK_U, func(c mqtt.Client, msg mqtt.Message) error {
+		received++
+		return nil
+	})
+
+	if err := handler(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: `{"gateway_id":"gw-1","message":{"doorlock_address":"3"}}`}); err != nil {
+		t.Errorf("valid message rejected: %v", err)
+	}
+	if err := handler(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: `{"gateway_id":"gw-1","message":{}}`}); err == nil {
+		t.Error("invalid message passed")
+	}
+	if received != 1 {
+		t.Errorf("got %d messages passed, wanted 1", received)
+	}
+}
+
+func TestContractDocUpToDate(t *testing.T) {
+	b, err := ioutil.ReadFile("../docs/mqtt_contracts.md")
+	if err != nil {
+		t.Fatalf("read doc failed: %v", err)
+	}
+	if string(b) != ContractDoc() {
+		t.Error("docs/mqtt_contracts.md is outdated, run go generate ./mqttSvc")
+	}
+}
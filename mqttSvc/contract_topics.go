@@ -0,0 +1,341 @@
+package mqttSvc
+
+import (
+	"encoding/json"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// Contracts of every topic in topic.go, outbound examples are built by the payload functions
+func Contracts() []Contract {
+	dl := &models.Doorlock{GatewayID: "gw-1", DoorlockAddress: "3", ActiveState: "active"}
+	sche := &models.Scheduler{StartDate: "1/9/2022", EndDate: "30/9/2022", WeekDay: 2, StartClassTime: 1, EndClassTime: 5}
+	sche.ID = 7
+	uP := &UserIDPassword{
+		UserId:       "46.01.104.001",
+		RfidPass:     "0A1B2C3D",
+		KeypadPass:   "123456",
+		RfidPasses:   []string{"0A1B2C3D"},
+		KeypadPasses: []string{"123456"},
+	}
+	ap := &models.AccessPolicy{Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", TimeWindows: "07:00-19:00", TimeZone: "Asia/Ho_Chi_Minh"}
+	registerV2 := NewRegisterV2("3", sche, ap, uP)
+	revocation := &models.CredentialRevocation{UserID: "46.01.104.001", Type: models.CREDENTIAL_TYPE_RFID, Value: "0A1B2C3D"}
+	revocation.ID = 12
+	room := AntiPassbackRoom{
+		RoomId:        "A.101",
+		Mode:          models.ANTI_PASSBACK_HARD,
+		Capacity:      40,
+		Doorlocks:     []AntiPassbackDoorlock{{DoorlockAddress: "3", Direction: models.READER_DIRECTION_ENTRY}},
+		InsideUserIds: []string{"46.01.104.001"},
+	}
+
+	return []Contract{
+		{
+			Topic:       TOPIC_GW_BOOTUP,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Gateway started, the server creates or restores it with its doorlocks and answers on the server bootup topics",
+			Schema:      "gateway_bootup.json",
+			Examples: []interface{}{GatewayBootupPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message: GatewayBootupMessage{
+					System: GatewaySystem{
+						SecretKey:       "secret",
+						SoftwareVersion: "1.2.0",
+						Interfaces: []GatewayInterface{{
+							InterfaceName:    "eth0",
+							PrimaryIpAddress: "192.168.1.10",
+							MacAddress:       "aa:bb:cc:dd:ee:ff",
+						}},
+					},
+					Doorlocks: []GatewayBootupDoorlock{{DoorlockAddress: "3", Location: "A.101", Description: "Main door"}},
+				},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_SHUTDOWN,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Gateway shuts down, the server deletes it. message is only logged",
+			Schema:      "gateway_envelope.json",
+			Examples:    []interface{}{GatewayEnvelope{Version: CONTRACT_VERSION, GatewayId: "gw-1", Message: "maintenance"}},
+		},
+		{
+			Topic:       TOPIC_GW_LASTWILL,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Last will of the gateway, the server marks it disconnected",
+			Schema:      "gateway_envelope.json",
+			Examples:    []interface{}{GatewayEnvelope{Version: CONTRACT_VERSION, GatewayId: "gw-1"}},
+		},
+		{
+			Topic:       TOPIC_GW_LOG_C,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Gateway log, log_time is unix seconds as a number or a string",
+			Schema:      "gateway_log.json",
+			Examples: []interface{}{GatewayLogPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message:   GatewayLogMessage{LogType: "info", LogData: "door 3 opened", LogTime: 1661965200},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_DOORLOCK_STATUS,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Reserved, not handled by the server. Doorlock states are sent on gateway/doorlock/update",
+		},
+		{
+			Topic:       TOPIC_GW_DOORLOCK_C,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Doorlock paired to the gateway, a deleted doorlock with the same address is restored",
+			Schema:      "gateway_doorlock.json",
+			Examples: []interface{}{GatewayDoorlockPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message:   GatewayDoorlockMessage{DoorlockAddress: "3", ActiveState: "active", OpenState: "close", LockState: "lock"},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_DOORLOCK_U,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Doorlock state changed, only the states present are updated",
+			Schema:      "gateway_doorlock.json",
+			Examples: []interface{}{GatewayDoorlockPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message:   GatewayDoorlockMessage{DoorlockAddress: "3", ConnectState: "connected", OpenState: "open", LastOpenTime: 1661965200},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_DOORLOCK_D,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Doorlock removed from the gateway",
+			Schema:      "gateway_doorlock.json",
+			Examples: []interface{}{GatewayDoorlockPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message:   GatewayDoorlockMessage{DoorlockAddress: "3"},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_CREDENTIAL_REVOKE_ACK,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Gateway applied a credential revocation, revocation_id as a number or a string",
+			Schema:      "gateway_credential_revoke_ack.json",
+			Examples: []interface{}{GatewayRevokeAckPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message:   GatewayRevokeAckMessage{RevocationId: 12},
+			}},
+		},
+		{
+			Topic:       TOPIC_GW_ACCESS_EVENT,
+			Direction:   CONTRACT_INBOUND,
+			Description: "Access on a reader doorlock, access_result defaults to granted and direction to the reader direction of the doorlock",
+			Schema:      "gateway_access_event.json",
+			Examples: []interface{}{GatewayAccessEventPayload{
+				Version:   CONTRACT_VERSION,
+				GatewayId: "gw-1",
+				Message: GatewayAccessEventMessage{
+					DoorlockAddress: "3",
+					UserId:          "46.01.104.001",
+					EventTime:       1661965200,
+					AccessResult:    models.ACCESS_RESULT_GRANTED,
+					Direction:       models.READER_DIRECTION_ENTRY,
+				},
+			}},
+		},
+
+		{
+			Topic:       TOPIC_SV_DOORLOCK_C,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Doorlock created through the API",
+			Examples:    []interface{}{rawPayload(ServerCreateDoorlockPayload(dl))},
+		},
+		{
+			Topic:       TOPIC_SV_DOORLOCK_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Doorlock active state changed through the API",
+			Examples:    []interface{}{rawPayload(ServerUpdateDoorlockPayload(dl))},
+		},
+		{
+			Topic:       TOPIC_SV_DOORLOCK_D,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Doorlock deleted through the API",
+			Examples:    []interface{}{rawPayload(ServerDeleteDoorlockPayload(dl))},
+		},
+		{
+			Topic:       TOPIC_SV_DOORLOCK_CMD,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Lock or unlock command, duration in seconds for a timed unlock. Without doorlock_address the command is for every doorlock of the gateway",
+			Examples: []interface{}{
+				rawPayload(ServerCmdDoorlockPayload("gw-1", "3", &models.DoorlockCmd{State: "unlock", Duration: "5"})),
+				rawPayload(ServerUpdateGatewayCmd("gw-1", "lock")),
+			},
+		},
+		{
+			Topic:       TOPIC_SV_DOORLOCK_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Doorlocks of the booting gateway",
+			Examples:    []interface{}{rawPayload(ServerBootupDoorlocksPayload("gw-1", []models.Doorlock{*dl}))},
+		},
+		{
+			Topic:       TOPIC_SV_GATEWAY_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Gateway area or name changed, not wrapped in message",
+			Examples:    []interface{}{rawPayload(ServerUpdateGatewayPayload(&models.Gateway{GatewayID: "gw-1", AreaID: "1", Name: "Block A"}))},
+		},
+		{
+			Topic:       TOPIC_SV_GATEWAY_D,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Gateway deleted through the API",
+			Examples:    []interface{}{rawPayload(ServerDeleteGatewayPayload("gw-1"))},
+		},
+		{
+			Topic:       TOPIC_SV_SCHEDULER_C,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Register created, start_date and end_date are unix seconds, week_day and classes are numbers as strings",
+			Examples:    []interface{}{rawPayload(ServerCreateRegisterPayload("gw-1", "3", sche, uP))},
+		},
+		{
+			Topic:       TOPIC_SV_SCHEDULER_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Register updated, credentials are sent on the user topics",
+			Examples: []interface{}{rawPayload(ServerUpdateRegisterPayload("gw-1", &models.UpdateScheduler{
+				UserID:          uP.UserId,
+				DoorlockAddress: "3",
+				Scheduler:       *sche,
+			}))},
+		},
+		{
+			Topic:       TOPIC_SV_SCHEDULER_D,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Register deleted, also used for version 2 registers",
+			Examples:    []interface{}{rawPayload(ServerDeleteRegisterPayload("gw-1", sche.ID))},
+		},
+		{
+			Topic:       TOPIC_SV_SCHEDULER_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Registers of the booting gateway not ended yet",
+			Examples: []interface{}{rawPayload(PayloadWithGatewayId("gw-1", []SchedulerBootUp{{
+				SchedulerId:     "7",
+				UserId:          uP.UserId,
+				RfidPass:        uP.RfidPass,
+				KeypadPass:      uP.KeypadPass,
+				RfidPasses:      uP.RfidPasses,
+				KeypadPasses:    uP.KeypadPasses,
+				DoorlockAddress: "3",
+				StartDate:       "1661965200",
+				EndDate:         "1664557199",
+				WeekDay:         "2",
+				StartClass:      "1",
+				EndClass:        "5",
+			}}))},
+		},
+		{
+			Topic:       TOPIC_SV_REGISTER_V2_C,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Register of a recurring access policy created, message version is the register format",
+			Examples:    []interface{}{rawPayload(ServerRegisterV2Payload("gw-1", registerV2))},
+		},
+		{
+			Topic:       TOPIC_SV_REGISTER_V2_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Register of a recurring access policy replaced",
+			Examples:    []interface{}{rawPayload(ServerRegisterV2Payload("gw-1", registerV2))},
+		},
+		{
+			Topic:       TOPIC_SV_REGISTER_V2_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Version 2 registers of the booting gateway not ended yet",
+			Examples:    []interface{}{rawPayload(PayloadWithGatewayId("gw-1", []RegisterV2{*registerV2}))},
+		},
+		{
+			Topic:       TOPIC_SV_HP_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Highest priority employees, they open every doorlock",
+			Examples:    []interface{}{rawPayload(PayloadWithGatewayId("gw-1", []UserIDPassword{*uP}))},
+		},
+		{
+			Topic:       TOPIC_SV_HP_C,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Highest priority employee added, sent to every gateway",
+			Examples:    []interface{}{rawPayload(ServerUpdateUserPayload("0", uP))},
+		},
+		{
+			Topic:       TOPIC_SV_HP_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Highest priority employee credentials changed, sent to every gateway",
+			Examples:    []interface{}{rawPayload(ServerUpdateUserPayload("0", uP))},
+		},
+		{
+			Topic:       TOPIC_SV_HP_D,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Highest priority employee removed, sent to every gateway",
+			Examples:    []interface{}{rawPayload(ServerDeleteUserPayload("0", uP.UserId))},
+		},
+		{
+			Topic:       TOPIC_SV_USER_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "User credentials changed, sent to every gateway",
+			Examples:    []interface{}{rawPayload(ServerUpdateUserPayload("0", uP))},
+		},
+		{
+			Topic:       TOPIC_SV_USER_D,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "User deleted, sent to every gateway",
+			Examples:    []interface{}{rawPayload(ServerDeleteUserPayload("0", uP.UserId))},
+		},
+		{
+			Topic:       TOPIC_SV_USER_BATCH_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Credentials of up to 100 imported users",
+			Examples:    []interface{}{rawPayload(ServerBatchUpdateUserPayload("0", []UserIDPassword{*uP}))},
+		},
+		{
+			Topic:       TOPIC_SV_CREDENTIAL_REVOKE,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Credential revoked, the gateway answers on gateway/credential/revoke/ack",
+			Examples:    []interface{}{rawPayload(ServerRevokeCredentialPayload("gw-1", revocation))},
+		},
+		{
+			Topic:       TOPIC_SV_BLACKLIST_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Every revoked credential, sent to the booting gateway",
+			Examples:    []interface{}{rawPayload(ServerBootupBlacklistPayload("gw-1", []models.CredentialRevocation{*revocation}))},
+		},
+		{
+			Topic:       TOPIC_SV_ANTIPASSBACK_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Anti-passback state of a room, only reader doorlocks of the receiving gateway are listed",
+			Examples:    []interface{}{rawPayload(ServerUpdateAntiPassbackPayload("gw-1", room))},
+		},
+		{
+			Topic:       TOPIC_SV_ANTIPASSBACK_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Anti-passback state of the rooms of the booting gateway reader doorlocks",
+			Examples:    []interface{}{rawPayload(ServerBootupAntiPassbackPayload("gw-1", []AntiPassbackRoom{room}))},
+		},
+		{
+			Topic:       TOPIC_SV_SYSTEM_U,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Secret key changed",
+			Examples:    []interface{}{rawPayload(ServerUpdateSecretKeyPayload("gw-1", "secret"))},
+		},
+		{
+			Topic:       TOPIC_SV_SYSTEM_BOOTUP,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Secret key, sent to the booting gateway",
+			Examples:    []interface{}{rawPayload(ServerBootupSystemPayload("gw-1", "secret"))},
+		},
+		{
+			Topic:       TOPIC_SV_LASTWILL,
+			Direction:   CONTRACT_OUTBOUND,
+			Description: "Server went down, sent by the broker as last will or by the server on shutdown. Not wrapped in an envelope",
+			Examples:    []interface{}{rawPayload(SERVER_SHUTDOWN_PAYLOAD)},
+		},
+	}
+}
+
+func rawPayload(payload string) json.RawMessage {
+	return json.RawMessage(payload)
+}
@@ -0,0 +1,70 @@
+package mqttSvc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// Send all usable credentials of person, HP employees go to the HP topic
+func PublishPersonCredentials(ctx context.Context, client mqtt.Client, optSvc *models.ServiceOptions, personID uint) error {
+	p, err := optSvc.PersonSvc.FindPersonByID(ctx, strconv.FormatUint(uint64(personID), 10))
+	if err != nil {
+		return err
+	}
+
+	userTopic := TOPIC_SV_USER_U
+	if p.Type == models.PERSON_TYPE_EMPLOYEE {
+		emp, err := optSvc.EmployeeSvc.FindEmployeeByMSNV(ctx, p.UserID)
+		if err == nil && emp.HighestPriority {
+			userTopic = TOPIC_SV_HP_U
+		}
+	}
+	t := client.Publish(userTopic, 1, false, ServerUpdateUserPayload("0", NewUserIDPassword(p.UserID, p)))
+	return HandleMqttErr(t)
+}
+
+// Gateways only get usable credentials, people whose credentials became valid or expired are sent again
+// every CREDENTIAL_VALIDITY_PERIOD. After a restart the job goes on from its last run
+func startCredentialValidityJob(client mqtt.Client, optSvc *models.ServiceOptions) {
+	var since time.Time
+	err := optSvc.JobRunner.Register(models.JOB_CREDENTIAL_VALIDITY, models.CREDENTIAL_VALIDITY_PERIOD,
+		func(ctx context.Context, now time.Time) error {
+			if since.IsZero() {
+				since = now.Add(-models.CREDENTIAL_VALIDITY_PERIOD)
+				if job, err := optSvc.JobRunner.FindJobByName(ctx, models.JOB_CREDENTIAL_VALIDITY); err == nil &&
+					job.LastRunAt != nil && job.LastRunAt.Before(since) {
+					since = *job.LastRunAt
+				}
+			}
+			if err := publishValidityChanges(ctx, client, optSvc, since, now); err != nil {
+				return err
+			}
+			since = now
+			return nil
+		})
+	if err != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Register credential validity job failed: %s", err.Error())
+	}
+}
+
+// A failed run keeps from, its people are sent again on the next run
+func publishValidityChanges(ctx context.Context, client mqtt.Client, optSvc *models.ServiceOptions, from time.Time, to time.Time) error {
+	personIDs, err := optSvc.PersonSvc.FindValidityChangedPersonIDs(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for _, personID := range personIDs {
+		if err := PublishPersonCredentials(ctx, client, optSvc, personID); err != nil {
+			return err
+		}
+	}
+	if len(personIDs) > 0 {
+		logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Sent credentials of %d people after validity changes", len(personIDs))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package mqttSvc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+// Processing of a gateway topic, a message whose processing returns an error is kept as a dead letter
+type GatewayHandler func(c mqtt.Client, msg mqtt.Message) error
+
+// Subscriber running handler, messages it fails or panics on are logged, counted and kept as dead letters
+func deadLetterSubscriber(topic string, optSvc *models.ServiceOptions, handler GatewayHandler) GatewaySubscriber {
+	return func(c mqtt.Client, msg mqtt.Message) {
+		err := runHandler(c, msg, handler)
+		if err == nil {
+			return
+		}
+		gwId := gjson.GetBytes(msg.Payload(), "gateway_id").String()
+		logger.LogfWithFields(logger.MQTT, logger.ErrorLevel, logger.LoggerFields{
+			"payload": string(msg.Payload()),
+		}, "Handle message of gateway ID %s on topic %s failed: %s", gwId, topic, err.Error())
+		subscriberError(msg)
+		_, dbErr := optSvc.MqttDeadLetterSvc.CreateMqttDeadLetter(context.Background(), &models.MqttDeadLetter{
+			Topic:     topic,
+			GatewayID: gwId,
+			Payload:   string(msg.Payload()),
+			Reason:    err.Error(),
+		})
+		if dbErr != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
+				"Keep dead letter of gateway ID %s on topic %s failed, err %s", gwId, topic, dbErr.Error())
+		}
+	}
+}
+
+// Run handler, a panic is returned as an error
+func runHandler(c mqtt.Client, msg mqtt.Message, handler GatewayHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogfWithFields(logger.MQTT, logger.ErrorLevel, logger.LoggerFields{
+				"stack": string(debug.Stack()),
+			}, "Handler of topic %s panicked: %v", msg.Topic(), r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(c, msg)
+}
+
+// Message kept as a dead letter, handled again as if it was just received
+type deadLetterMessage struct {
+	dlt *models.MqttDeadLetter
+}
+
+func (dm *deadLetterMessage) Duplicate() bool   { return false }
+func (dm *deadLetterMessage) Qos() byte         { return 1 }
+func (dm *deadLetterMessage) Retained() bool    { return false }
+func (dm *deadLetterMessage) Topic() string     { return dm.dlt.Topic }
+func (dm *deadLetterMessage) MessageID() uint16 { return 0 }
+func (dm *deadLetterMessage) Payload() []byte   { return []byte(dm.dlt.Payload) }
+func (dm *deadLetterMessage) Ack()              {}
+
+// Handle pending dead letter again with the current contract and handler of its topic. It bypasses the
+// worker queues and duplicate detection, the letter is marked replayed when it succeeds and stays
+// pending with the new reason otherwise
+func ReplayDeadLetter(client mqtt.Client, optSvc *models.ServiceOptions, dlt *models.MqttDeadLetter) error {
+	if dlt.Status != models.MQTT_DEAD_LETTER_PENDING {
+		return fmt.Errorf("dead letter is %s", dlt.Status)
+	}
+	handler, ok := gatewayHandlers(client, optSvc)[dlt.Topic]
+	if !ok {
+		return fmt.Errorf("no handler for topic %s", dlt.Topic)
+	}
+
+	msg := &deadLetterMessage{dlt: dlt}
+	err := runHandler(client, msg, validateHandler(dlt.Topic, handler))
+	if _, dbErr := optSvc.MqttDeadLetterSvc.RecordMqttDeadLetterAttempt(context.Background(), dlt.ID, err); dbErr != nil {
+		return dbErr
+	}
+	if err != nil {
+		subscriberError(msg)
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+const unknownDoorlockPayload = `{"gateway_id":"gw-1","message":{"doorlock_address":"3","doorlock_lock_state":"lock"}}`
+
+func TestDeadLetterSubscriber(t *testing.T) {
+	optSvc := &models.ServiceOptions{MqttDeadLetterSvc: models.NewMqttDeadLetterSvc(newMigratedDb(t))}
+	topic := "test/deadLetter"
+
+	failing := deadLetterSubscriber(topic, optSvc, func(c mqtt.Client, msg mqtt.Message) error {
+		return errors.New("db down")
+	})
+	panicking := deadLetterSubscriber(topic, optSvc, func(c mqtt.Client, msg mqtt.Message) error {
+		var dl *models.Doorlock
+		_ = dl.ID
+		return nil
+	})
+	succeeding := deadLetterSubscriber(topic, optSvc, func(c mqtt.Client, msg mqtt.Message) error {
+		return nil
+	})
+	failing(nil, &mockMessage{topic: topic, payload: `{"gateway_id":"gw-1"}`})
+	panicking(nil, &mockMessage{topic: topic, payload: `{"gateway_id":"gw-2"}`})
+	succeeding(nil, &mockMessage{topic: topic, payload: `{"gateway_id":"gw-3"}`})
+
+	dltList, err := optSvc.MqttDeadLetterSvc.FindAllMqttDeadLetter(context.Background(), models.MQTT_DEAD_LETTER_PENDING)
+	if err != nil {
+		t.Fatalf("find dead letters failed: %v", err)
+	}
+	if len(dltList) != 2 {
+		t.Fatalf("got %d dead letters, wanted 2", len(dltList))
+	}
+	// Newest first
+	if dltList[0].GatewayID != "gw-2" || dltList[0].Attempts != 1 || dltList[0].Reason[:6] != "panic:" {
+		t.Errorf("got %+v, wanted panic of gw-2", dltList[0])
+	}
+	if dltList[1].GatewayID != "gw-1" || dltList[1].Topic != topic || dltList[1].Reason != "db down" {
+		t.Errorf("got %+v, wanted db down of gw-1", dltList[1])
+	}
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	db := newMigratedDb(t)
+	ctx := context.Background()
+	optSvc := &models.ServiceOptions{
+		DoorlockSvc:          models.NewDoorlockSvc(db),
+		DoorlockStatusLogSvc: models.NewDoorlockStatusLogSvc(db),
+		MqttDeadLetterSvc:    models.NewMqttDeadLetterSvc(db),
+	}
+	rc := &recordingClient{open: true}
+
+	// Update of a doorlock the server does not know yet
+	sub := deadLetterSubscriber(TOPIC_GW_DOORLOCK_U, optSvc,
+		validateHandler(TOPIC_GW_DOORLOCK_U, gwDoorlockUpdateHandler(rc, optSvc)))
+	sub(rc, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: unknownDoorlockPayload})
+	dltList, _ := optSvc.MqttDeadLetterSvc.FindAllMqttDeadLetter(ctx, "")
+	if len(dltList) != 1 {
+		t.Fatalf("got %d dead letters, wanted 1", len(dltList))
+	}
+	dlt := &dltList[0]
+
+	if err := ReplayDeadLetter(rc, optSvc, dlt); err == nil {
+		t.Fatal("replay of unknown doorlock succeeded")
+	}
+	dlt, _ = optSvc.MqttDeadLetterSvc.FindMqttDeadLetterByID(ctx, "1")
+	if dlt.Status != models.MQTT_DEAD_LETTER_PENDING || dlt.Attempts != 2 {
+		t.Errorf("got status %s attempts %d, wanted pending 2", dlt.Status, dlt.Attempts)
+	}
+
+	// Known once its gateway reported it
+	optSvc.DoorlockSvc.CreateDoorlock(ctx, &models.Doorlock{GatewayID: "gw-1", DoorlockAddress: "3", DoorSerialID: "serial-3"})
+	if err := ReplayDeadLetter(rc, optSvc, dlt); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	dl, _ := optSvc.DoorlockSvc.FindDoorlockByAddress(ctx, "3", "gw-1")
+	if dl.LockState != "lock" {
+		t.Errorf("got lock state %q, wanted lock", dl.LockState)
+	}
+	dlt, _ = optSvc.MqttDeadLetterSvc.FindMqttDeadLetterByID(ctx, "1")
+	if dlt.Status != models.MQTT_DEAD_LETTER_REPLAYED || dlt.Attempts != 3 {
+		t.Errorf("got status %s attempts %d, wanted replayed 3", dlt.Status, dlt.Attempts)
+	}
+
+	if err := ReplayDeadLetter(rc, optSvc, dlt); err == nil {
+		t.Error("replayed dead letter was replayed again")
+	}
+}
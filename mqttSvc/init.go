@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -19,8 +20,21 @@ import (
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/utils"
 	"github.com/google/uuid"
-	"github.com/tidwall/gjson"
+)
+
+const (
+	// Sent on server/lastwill by the broker when the server drops and by the server when it shuts down
+	SERVER_SHUTDOWN_PAYLOAD string = `{"status":"shutdown"}`
+
+	MQTT_SHUTDOWN_TIMEOUT   time.Duration = 5 * time.Second
+	MQTT_DISCONNECT_QUIESCE uint          = 250 // ms
+
+	// Reconnect backoff doubles from 1s up to this, connect at startup is retried every MQTT_CONNECT_RETRY_INTERVAL
+	MQTT_MAX_RECONNECT_INTERVAL time.Duration = time.Minute
+	MQTT_CONNECT_RETRY_INTERVAL time.Duration = 10 * time.Second
+	MQTT_CONNECT_TIMEOUT        time.Duration = 30 * time.Second
 )
 
 func NewTlsConfig() *tls.Config {
@@ -44,19 +58,33 @@ var messagePubHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Me
 		"Received message: %s from topic: %s\n", msg.Payload(), msg.Topic())
 }
 
-var connectHandler mqtt.OnConnectHandler = func(client mqtt.Client) {
-	logger.LogWithoutFields(logger.MQTT, logger.DebugLevel, "Connected")
+var connectLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
+	logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Connect lost: %v, reconnecting\n", err)
 }
 
-var connectLostHandler mqtt.ConnectionLostHandler = func(client mqtt.Client, err error) {
-	logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Connect lost: %v\n", err)
+var reconnectingHandler mqtt.ReconnectHandler = func(client mqtt.Client, opts *mqtt.ClientOptions) {
+	logger.LogWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Reconnecting to broker")
+}
+
+// Broker the server connects to
+type BrokerConfig struct {
+	URL       string      // ssl://host:port, or tcp://host:port for an embedded broker without TLS
+	TLSConfig *tls.Config // used by ssl:// brokers
+	Username  string      // anonymous when empty
+	Password  string
+}
+
+// Client of the server, gateway messages are handled by its pipeline
+type serverClient struct {
+	*outboxClient
+	pipeline *pipeline
 }
 
 // Define mqtt connections and configs
 func MqttClient(
 	clientID string,
-	host string,
-	port string,
+	bc BrokerConfig,
+	pc PipelineConfig,
 	optSvc *models.ServiceOptions,
 ) mqtt.Client {
 
@@ -67,368 +95,670 @@ func MqttClient(
 
 	opts := mqtt.NewClientOptions()
 	// Setup server LWT message
-	opts.SetWill(TOPIC_SV_LASTWILL, string(`{"status":"shutdown"}`), 0, false)
+	opts.SetWill(TOPIC_SV_LASTWILL, SERVER_SHUTDOWN_PAYLOAD, 0, false)
 
-	opts.AddBroker(fmt.Sprintf("ssl://%s:%s", host, port))
+	opts.AddBroker(bc.URL)
 	opts.SetClientID(clientID) // Need to be unique per client
-	tlsConfig := NewTlsConfig()
-	opts.SetTLSConfig(tlsConfig)
-	// opts.SetUsername("emqx") // Use this when we want to improve security
-	// opts.SetPassword("public") // Use this when we want to improve security
+	if bc.TLSConfig != nil {
+		opts.SetTLSConfig(bc.TLSConfig)
+	}
+	if bc.Username != "" {
+		opts.SetUsername(bc.Username)
+		opts.SetPassword(bc.Password)
+	}
 	opts.SetDefaultPublishHandler(messagePubHandler)
-	opts.OnConnect = connectHandler
+	// Clean session drops broker side subscriptions, they are made again on every connect
+	opts.SetCleanSession(true)
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(MQTT_MAX_RECONNECT_INTERVAL)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(MQTT_CONNECT_RETRY_INTERVAL)
+
+	client := &serverClient{pipeline: newPipeline(pc)}
+	opts.OnConnect = func(c mqtt.Client) {
+		logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Connected")
+		client.onConnect(optSvc)
+	}
 	opts.OnConnectionLost = connectLostHandler
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		logger.LogWithoutFields(logger.MQTT, logger.PanicLevel, token.Error())
+	opts.OnReconnecting = reconnectingHandler
+	client.outboxClient = newOutboxClient(instrumentClient(mqtt.NewClient(opts)), optSvc.MqttOutboxSvc)
+	// Server keeps starting when the broker is down, publishes are queued until it connects
+	if token := client.Connect(); !token.WaitTimeout(MQTT_CONNECT_TIMEOUT) {
+		logger.LogWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Broker unreachable, retrying in background")
+	} else if token.Error() != nil {
+		logger.LogWithoutFields(logger.MQTT, logger.ErrorLevel, token.Error())
 	}
-	subGateway(client, optSvc)
+	startCommandScheduler(client, optSvc)
+	startCredentialValidityJob(client, optSvc)
+
+	return client
+}
 
+// Server client on a connection made elsewhere, e.g. a recording fake in tests.
+// Gateway topics are subscribed right away, transport must be connected
+func NewServerClient(transport mqtt.Client, pc PipelineConfig, optSvc *models.ServiceOptions) mqtt.Client {
+	client := &serverClient{
+		outboxClient: newOutboxClient(instrumentClient(transport), optSvc.MqttOutboxSvc),
+		pipeline:     newPipeline(pc),
+	}
+	client.onConnect(optSvc)
+	startCommandScheduler(client, optSvc)
+	startCredentialValidityJob(client, optSvc)
 	return client
 }
 
+func (sc *serverClient) onConnect(optSvc *models.ServiceOptions) {
+	subGateway(sc, sc.pipeline, optSvc)
+	go sc.flushOutbox()
+}
+
+// Wait until gateway messages received so far are handled, so tests can assert on their effects
+func WaitIdle(client mqtt.Client) {
+	if sc, ok := client.(*serverClient); ok {
+		sc.pipeline.drain()
+	}
+}
+
 type GatewaySubscriber = mqtt.MessageHandler
 
 // Define all subscribe logic callbacks for payloads that received from gateway
-func subGateway(client mqtt.Client, optSvc *models.ServiceOptions) {
-
-	topicSubscriberMap := map[string]GatewaySubscriber{}
-	topicSubscriberMap[TOPIC_GW_SHUTDOWN] = gwShutDownSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_BOOTUP] = gwBootupSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_LOG_C] = gwLogCreateSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_DOORLOCK_U] = gwDoorlockUpdateSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_DOORLOCK_C] = gwDoorlockCreateSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_DOORLOCK_D] = gwDoorlockDeleteSubscriber(client, optSvc)
-	topicSubscriberMap[TOPIC_GW_LASTWILL] = gwLastWillSubscriber(client, optSvc)
-
-	for topic, subscriber := range topicSubscriberMap {
-		t := client.Subscribe(topic, 1, subscriber)
+func subGateway(client mqtt.Client, p *pipeline, optSvc *models.ServiceOptions) {
+	for topic, handler := range gatewayHandlers(client, optSvc) {
+		subscriber := deadLetterSubscriber(topic, optSvc, validateHandler(topic, handler))
+		t := client.Subscribe(topic, 1, p.handler(topic, instrumentSubscriber(topic, subscriber)))
 		if err := HandleMqttErr(t); err == nil {
 			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Subscribed to topic %s", topic)
 		}
 	}
 }
 
+func gatewayHandlers(client mqtt.Client, optSvc *models.ServiceOptions) map[string]GatewayHandler {
+	topicSubscriberMap := map[string]GatewayHandler{}
+	topicSubscriberMap[TOPIC_GW_SHUTDOWN] = gwShutDownHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_BOOTUP] = gwBootupHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_LOG_C] = gwLogCreateHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_DOORLOCK_U] = gwDoorlockUpdateHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_DOORLOCK_C] = gwDoorlockCreateHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_DOORLOCK_D] = gwDoorlockDeleteHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_LASTWILL] = gwLastWillHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_CREDENTIAL_REVOKE_ACK] = gwCredentialRevokeAckHandler(client, optSvc)
+	topicSubscriberMap[TOPIC_GW_ACCESS_EVENT] = gwAccessEventHandler(client, optSvc)
+	return topicSubscriberMap
+}
+
+// Stop receiving gateway messages, handle the queued ones, tell gateways the server is
+// going down as the last will would, and disconnect once in-flight messages are handled
+func Shutdown(client mqtt.Client, optSvc *models.ServiceOptions) {
+	if !client.IsConnectionOpen() {
+		stopPipeline(client)
+		// Also stops reconnect attempts
+		client.Disconnect(0)
+		return
+	}
+	topics := []string{}
+	for topic := range gatewayHandlers(client, optSvc) {
+		topics = append(topics, topic)
+	}
+	t := client.Unsubscribe(topics...)
+	t.WaitTimeout(MQTT_SHUTDOWN_TIMEOUT)
+	if err := HandleMqttErr(t); err == nil {
+		logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Unsubscribed from gateway topics")
+	}
+	stopPipeline(client)
+	t = client.Publish(TOPIC_SV_LASTWILL, 1, false, SERVER_SHUTDOWN_PAYLOAD)
+	t.WaitTimeout(MQTT_SHUTDOWN_TIMEOUT)
+	HandleMqttErr(t)
+	client.Disconnect(MQTT_DISCONNECT_QUIESCE)
+	logger.LogWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Disconnected")
+}
+
+func stopPipeline(client mqtt.Client) {
+	if sc, ok := client.(*serverClient); ok {
+		sc.pipeline.stop(MQTT_SHUTDOWN_TIMEOUT)
+	}
+}
+
 // MQTT subscriber for gateway
-func gwShutDownSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		gwId := gjson.Get(payloadStr, "gateway_id")
-		gwMsg := gjson.Get(payloadStr, "message")
+func gwShutDownHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayEnvelope{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
 		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
-			"GwMsg": gwMsg.String(),
-		}, "Receive gateway shutdown message with ID %s", gwId.String())
-		optSvc.GatewaySvc.DeleteGateway(context.Background(), gwId.String())
+			"GwMsg": string(payload.Message),
+		}, "Receive gateway shutdown message with ID %s", payload.GatewayId)
+		_, err := optSvc.GatewaySvc.DeleteGateway(context.Background(), payload.GatewayId, models.DELETED_BY_GATEWAY)
+		// Gateway already deleted
+		if err != nil && !errors.Is(err, utils.ErrNoRecordAffected) {
+			return fmt.Errorf("delete gateway: %w", err)
+		}
+		return nil
 	}
 }
 
-func gwBootupSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		gwId := gjson.Get(payloadStr, "gateway_id")
+func gwBootupHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayBootupPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		gwId := payload.GatewayId
+		system := payload.Message.System
 
 		logger.LogfWithFields(logger.MQTT, logger.DebugLevel, logger.LoggerFields{
-			"payload": payloadStr,
-		}, "Gateway bootup with ID %s", gwId.String())
-
-		secretKey, _ := optSvc.SecretKeySvc.FindSecretKey(context.Background())
-		currentSecretKey := gjson.Get(payloadStr, "message.system.secret_key").String()
-		checkGw, _ := optSvc.GatewaySvc.FindGatewayByMacID(context.Background(), gwId.String())
-
-		// Add gateway connect state, secret key, software version
-		if checkGw == nil {
-			newGw := &models.Gateway{}
-			newGw.GatewayID = gwId.String()
-			newGw.ConnectState = true
-			newGw.SoftwareVersion = gjson.Get(payloadStr, "message.system.software_version").String()
-			if currentSecretKey != secretKey.Secret {
-				client.Publish(TOPIC_SV_SYSTEM_U, 1, false,
-					ServerUpdateSecretKeyPayload(newGw.GatewayID, secretKey.Secret))
-			}
-			optSvc.GatewaySvc.CreateGateway(context.Background(), newGw)
-		} else {
-			// Check gateway reconnect case
-			if !checkGw.ConnectState {
-				checkGw.ConnectState = true
-			}
-			checkGw.SoftwareVersion = gjson.Get(payloadStr, "message.system.software_version").String()
-			if currentSecretKey != secretKey.Secret {
-				client.Publish(TOPIC_SV_SYSTEM_U, 1, false,
-					ServerUpdateSecretKeyPayload(checkGw.GatewayID, secretKey.Secret))
-			}
-			optSvc.GatewaySvc.UpdateGateway(context.Background(), checkGw)
-		}
-
-		// Add doorlocks
-		doorlocks := gjson.Get(payloadStr, "message.doorlocks")
-		if doorlocks.Exists() {
-			for _, v := range doorlocks.Array() {
-				doorlockAdress := v.Get("doorlock_address")
-				location := v.Get("location")
-				description := v.Get("description")
-
-				dl := &models.Doorlock{
-					DoorlockAddress: doorlockAdress.String(),
-					Location:        location.String(),
-					GatewayID:       gwId.String(),
-					Description:     description.String(),
-				}
+			"payload": string(msg.Payload()),
+		}, "Gateway bootup with ID %s", gwId)
 
-				checkDl, _ := optSvc.DoorlockSvc.FindDoorlockByAddress(context.Background(), doorlockAdress.String(), gwId.String())
-				if checkDl == nil {
-					if !v.Get("doorlock_serial_id").Exists() {
-						dl.DoorSerialID = uuid.New().String()
-					} else {
-						dl.DoorSerialID = v.Get("doorlock_serial_id").String()
-					}
-					optSvc.DoorlockSvc.CreateDoorlock(context.Background(), dl)
-				}
-			}
+		secretKey, err := optSvc.SecretKeySvc.FindSecretKey(context.Background())
+		if err != nil {
+			return fmt.Errorf("find secret key: %w", err)
 		}
-
-		// Add gateway network info
-		gwNetworks := gjson.Get(payloadStr, "message.system.interfaces")
-		if gwNetworks.Exists() {
-			for _, gw := range gwNetworks.Array() {
-				ifName := gw.Get("interface_name")
-				priIpAddr := gw.Get("primary_ip_address")
-				secIpAddr := gw.Get("secondary_ip_address")
-				macAddr := gw.Get("mac_address")
-
-				gwNet := &models.GwNetwork{
-					GatewayID:          gwId.String(),
-					InterfaceName:      ifName.String(),
-					PrimaryIpAddress:   priIpAddr.String(),
-					SecondaryIpAddress: secIpAddr.String(),
-					MacAddress:         macAddr.String(),
-				}
-				if checkGw == nil || len(checkGw.GwNetworks) == 0 {
-					optSvc.GwNetworkSvc.CreateGwNetwork(context.Background(), gwNet)
-				} else {
-					optSvc.GwNetworkSvc.UpdateGwNetwork(context.Background(), gwNet)
-				}
-			}
+		// Gateway, doorlocks and networks of the bootup are saved together
+		err = optSvc.UnitOfWork.Do(context.Background(), func(ctx context.Context) error {
+			return saveBootupGateway(ctx, optSvc, payload)
+		})
+		if err != nil {
+			return err
+		}
+		if system.SecretKey != secretKey.Secret {
+			client.Publish(TOPIC_SV_SYSTEM_U, 1, false, ServerUpdateSecretKeyPayload(gwId, secretKey.Secret))
 		}
 
 		//HPUserIDPassword
 		hpEmployees, err := optSvc.EmployeeSvc.FindAllHPEmployee(context.Background())
 		if err != nil {
-			fmt.Println(err.Error())
+			return fmt.Errorf("find HP employees: %w", err)
 		}
 
-		t := client.Publish(TOPIC_SV_HP_BOOTUP, 1, false, ServerBootuptHPEmployeePayload(gwId.String(), hpEmployees))
+		t := client.Publish(TOPIC_SV_HP_BOOTUP, 1, false, ServerBootuptHPEmployeePayload(gwId, hpEmployees))
 		HandleMqttErr(t)
 
 		// Get doorlock first
-		dls, err := optSvc.DoorlockSvc.FindAllDoorlockByGatewayID(context.Background(), gwId.String())
+		dls, err := optSvc.DoorlockSvc.FindAllDoorlockByGatewayID(context.Background(), gwId)
 		if err != nil {
-			fmt.Println(err.Error())
+			return fmt.Errorf("find doorlocks: %w", err)
 		}
 
-		t = client.Publish(TOPIC_SV_DOORLOCK_BOOTUP, 1, false, ServerBootupDoorlocksPayload(gwId.String(), dls))
+		t = client.Publish(TOPIC_SV_DOORLOCK_BOOTUP, 1, false, ServerBootupDoorlocksPayload(gwId, dls))
 		HandleMqttErr(t)
 
 		//SCheduler - Register
-		scheBoUps := mergeInfoToScheBootUp(optSvc, dls)
+		scheBoUps, registerV2s := mergeInfoToScheBootUp(optSvc, dls)
 
-		t = client.Publish(TOPIC_SV_SCHEDULER_BOOTUP, 1, false, ServerBootupRegisterPayload(gwId.String(), scheBoUps))
+		t = client.Publish(TOPIC_SV_SCHEDULER_BOOTUP, 1, false, ServerBootupRegisterPayload(gwId, scheBoUps))
+		HandleMqttErr(t)
+		t = client.Publish(TOPIC_SV_REGISTER_V2_BOOTUP, 1, false, ServerBootupRegisterV2Payload(gwId, registerV2s))
 		HandleMqttErr(t)
 
-		//System
-		srKey, err := optSvc.SecretKeySvc.FindSecretKey(context.Background())
+		// Revoked credentials, gateway acknowledges the ones still pending for it
+		blacklist, err := optSvc.RevocationSvc.FindBlacklist(context.Background())
 		if err != nil {
-			fmt.Println(err.Error())
+			return fmt.Errorf("find blacklist: %w", err)
 		}
-		t = client.Publish(TOPIC_SV_SYSTEM_BOOTUP, 1, false, ServerBootupSystemPayload(gwId.String(), srKey.Secret))
+		t = client.Publish(TOPIC_SV_BLACKLIST_BOOTUP, 1, false, ServerBootupBlacklistPayload(gwId, blacklist))
+		HandleMqttErr(t)
+
+		// Anti-passback state of the rooms of the gateway reader doorlocks
+		t = client.Publish(TOPIC_SV_ANTIPASSBACK_BOOTUP, 1, false,
+			ServerBootupAntiPassbackPayload(gwId, mergeInfoToAntiPassbackBootUp(optSvc, dls)))
+		HandleMqttErr(t)
+
+		//System
+		t = client.Publish(TOPIC_SV_SYSTEM_BOOTUP, 1, false, ServerBootupSystemPayload(gwId, secretKey.Secret))
 		HandleMqttErr(t)
+		return nil
+	}
+}
+
+// Create or update the gateway of a bootup with its doorlocks and networks
+func saveBootupGateway(ctx context.Context, optSvc *models.ServiceOptions, payload *GatewayBootupPayload) error {
+	gwId := payload.GatewayId
+	system := payload.Message.System
+	checkGw, _ := optSvc.GatewaySvc.FindGatewayByMacID(ctx, gwId)
+	if checkGw == nil {
+		// Gateway deleted before (or shut down) is back, restore it instead of creating a duplicate
+		if restored, _ := optSvc.GatewaySvc.RestoreGateway(ctx, gwId); restored {
+			checkGw, _ = optSvc.GatewaySvc.FindGatewayByMacID(ctx, gwId)
+		}
+	}
+
+	// Add gateway connect state, secret key, software version
+	if checkGw == nil {
+		newGw := &models.Gateway{}
+		newGw.GatewayID = gwId
+		newGw.ConnectState = true
+		newGw.SoftwareVersion = system.SoftwareVersion
+		if _, err := optSvc.GatewaySvc.CreateGateway(ctx, newGw); err != nil {
+			return fmt.Errorf("create gateway: %w", err)
+		}
+	} else {
+		// Check gateway reconnect case
+		if !checkGw.ConnectState {
+			checkGw.ConnectState = true
+		}
+		checkGw.SoftwareVersion = system.SoftwareVersion
+		if _, err := optSvc.GatewaySvc.UpdateGateway(ctx, checkGw); err != nil {
+			return fmt.Errorf("update gateway: %w", err)
+		}
 	}
+
+	// Add doorlocks
+	for _, v := range payload.Message.Doorlocks {
+		dl := &models.Doorlock{
+			DoorlockAddress: v.DoorlockAddress,
+			Location:        v.Location,
+			GatewayID:       gwId,
+			Description:     v.Description,
+		}
+
+		checkDl, _ := optSvc.DoorlockSvc.FindDoorlockByAddress(ctx, v.DoorlockAddress, gwId)
+		if checkDl != nil {
+			continue
+		}
+		restored, _ := optSvc.DoorlockSvc.RestoreDoorlockByAddress(ctx, v.DoorlockAddress, gwId)
+		if !restored {
+			if v.DoorlockSerialId == "" {
+				dl.DoorSerialID = uuid.New().String()
+			} else {
+				dl.DoorSerialID = v.DoorlockSerialId
+			}
+			if _, err := optSvc.DoorlockSvc.CreateDoorlock(ctx, dl); err != nil {
+				return fmt.Errorf("create doorlock %s: %w", v.DoorlockAddress, err)
+			}
+		}
+	}
+
+	// Add gateway network info
+	for _, gw := range system.Interfaces {
+		gwNet := &models.GwNetwork{
+			GatewayID:          gwId,
+			InterfaceName:      gw.InterfaceName,
+			PrimaryIpAddress:   gw.PrimaryIpAddress,
+			SecondaryIpAddress: gw.SecondaryIpAddress,
+			MacAddress:         gw.MacAddress,
+		}
+		if checkGw == nil || len(checkGw.GwNetworks) == 0 {
+			if _, err := optSvc.GwNetworkSvc.CreateGwNetwork(ctx, gwNet); err != nil {
+				return fmt.Errorf("create network %s: %w", gw.InterfaceName, err)
+			}
+		} else {
+			// Interface not known before is only added on a first bootup, the bootup is still handled
+			if _, err := optSvc.GwNetworkSvc.UpdateGwNetwork(ctx, gwNet); err != nil {
+				logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel,
+					"Update network %s of gateway ID %s failed, err %s", gw.InterfaceName, gwId, err.Error())
+			}
+		}
+	}
+	return nil
 }
 
-func gwLogCreateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		logMsg := gjson.Get(payloadStr, "message").String()
-		gatewayId := gjson.Get(payloadStr, "gateway_id")
-		logType := gjson.Get(logMsg, "log_type")
-		content := gjson.Get(logMsg, "log_data")
-		logTime := gjson.Get(logMsg, "log_time")
+func gwLogCreateHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayLogPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		logMsg := payload.Message
 		logger.LogfWithFields(logger.MQTT, logger.DebugLevel, logger.LoggerFields{
-			"logPayload": logMsg,
-		}, "Receive gw:%s logs message", gatewayId.String())
-		logTimeInt, e := strconv.ParseInt(logTime.String(), 10, 64)
-		if e != nil {
-			fmt.Println(e.Error())
-			return
-		}
-		formatLogTime := time.Unix(logTimeInt, 0)
-		fmt.Printf(" %s: %s \n", msg.Topic(), payloadStr)
-		optSvc.LogSvc.CreateGatewayLog(context.Background(), &models.GatewayLog{
-			GatewayID: gatewayId.String(),
-			LogType:   logType.String(),
-			Content:   content.String(),
-			LogTime:   formatLogTime,
+			"logPayload": string(msg.Payload()),
+		}, "Receive gw:%s logs message", payload.GatewayId)
+		_, err := optSvc.LogSvc.CreateGatewayLog(context.Background(), &models.GatewayLog{
+			GatewayID: payload.GatewayId,
+			LogType:   logMsg.LogType,
+			Content:   string(logMsg.LogData),
+			LogTime:   time.Unix(int64(logMsg.LogTime), 0),
 		})
+		if err != nil {
+			return fmt.Errorf("create gateway log: %w", err)
+		}
+		return nil
 	}
 }
 
-func gwDoorlockUpdateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		gatewayId := gjson.Get(payloadStr, "gateway_id").String()
-		doorStateMsg := gjson.Get(payloadStr, "message").String()
-		doorlockAddress := gjson.Get(doorStateMsg, "doorlock_address").String()
-		state := gjson.Get(doorStateMsg, "doorlock_connect_state").String()
-		lastOpenTime := gjson.Get(doorStateMsg, "last_open_time")
-		activeState := gjson.Get(doorStateMsg, "doorlock_active_state").String()
-
-		dl, _ := optSvc.DoorlockSvc.FindDoorlockByAddress(context.Background(), doorlockAddress, gatewayId)
-
-		doorID := strconv.Itoa(int(dl.ID))
+func gwDoorlockUpdateHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayDoorlockPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		gatewayId := payload.GatewayId
+		doorStateMsg := payload.Message
+		doorlockAddress := doorStateMsg.DoorlockAddress
+		state := doorStateMsg.ConnectState
+		activeState := doorStateMsg.ActiveState
+
+		// Update may arrive before the doorlock is known, e.g. before the gateway bootup is handled
+		dl, err := optSvc.DoorlockSvc.FindDoorlockByAddress(context.Background(), doorlockAddress, gatewayId)
+		if err != nil || dl == nil {
+			return fmt.Errorf("unknown doorlock %s of gateway ID %s", doorlockAddress, gatewayId)
+		}
 
 		if activeState != "" {
 			dl.ActiveState = activeState
-			optSvc.DoorlockSvc.UpdateDoorlock(context.Background(), dl)
+			if _, err := optSvc.DoorlockSvc.UpdateDoorlock(context.Background(), dl); err != nil {
+				return fmt.Errorf("update active state: %w", err)
+			}
 		}
 
 		if state != "" {
-			optSvc.DoorlockSvc.UpdateDoorlockByAddress(context.Background(), &models.Doorlock{
+			_, err := optSvc.DoorlockSvc.UpdateDoorlockByAddress(context.Background(), &models.Doorlock{
 				DoorlockAddress: doorlockAddress,
 				ConnectState:    state,
-				LastOpenTime:    uint(lastOpenTime.Uint()),
+				LastOpenTime:    uint(doorStateMsg.LastOpenTime),
 				GatewayID:       gatewayId,
 			})
-			optSvc.DoorlockStatusLogSvc.CreateDoorlockStatusLog(context.Background(), &models.DoorlockStatusLog{
-				DoorID:     doorID,
-				StateType:  "connectState",
-				StateValue: state,
-			})
+			if err != nil {
+				return fmt.Errorf("update connect state: %w", err)
+			}
+			if err := recordDoorlockState(optSvc, dl, "connectState", models.STATUS_EVENT_CONNECT, state); err != nil {
+				return err
+			}
 		}
 
-		doorState := gjson.Get(doorStateMsg, "doorlock_open_state").String()
+		doorState := doorStateMsg.OpenState
 		if doorState != "" {
-			optSvc.DoorlockSvc.UpdateDoorState(context.Background(), &models.DoorlockStatus{
+			_, err := optSvc.DoorlockSvc.UpdateDoorState(context.Background(), &models.DoorlockStatus{
 				GatewayID:       gatewayId,
 				DoorlockAddress: doorlockAddress,
 				DoorState:       doorState,
 			})
-			optSvc.DoorlockStatusLogSvc.CreateDoorlockStatusLog(context.Background(), &models.DoorlockStatusLog{
-				DoorID:     doorID,
-				StateType:  "doorState",
-				StateValue: doorState,
-			})
+			if err != nil {
+				return fmt.Errorf("update door state: %w", err)
+			}
+			if err := recordDoorlockState(optSvc, dl, "doorState", models.STATUS_EVENT_DOOR, doorState); err != nil {
+				return err
+			}
 		}
 
-		lockState := gjson.Get(doorStateMsg, "doorlock_lock_state").String()
+		lockState := doorStateMsg.LockState
 		if lockState != "" {
-			optSvc.DoorlockSvc.UpdateLockState(context.Background(), &models.DoorlockStatus{
+			_, err := optSvc.DoorlockSvc.UpdateLockState(context.Background(), &models.DoorlockStatus{
 				GatewayID:       gatewayId,
 				DoorlockAddress: doorlockAddress,
 				LockState:       lockState,
 			})
-			optSvc.DoorlockStatusLogSvc.CreateDoorlockStatusLog(context.Background(), &models.DoorlockStatusLog{
-				DoorID:     doorID,
-				StateType:  "lockState",
-				StateValue: lockState,
-			})
+			if err != nil {
+				return fmt.Errorf("update lock state: %w", err)
+			}
+			if err := recordDoorlockState(optSvc, dl, "lockState", models.STATUS_EVENT_LOCK, lockState); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Keep state change in the doorlock status logs and statistics events
+func recordDoorlockState(optSvc *models.ServiceOptions, dl *models.Doorlock, stateType string, eventType string, value string) error {
+	_, err := optSvc.DoorlockStatusLogSvc.CreateDoorlockStatusLog(context.Background(), &models.DoorlockStatusLog{
+		DoorID:     strconv.Itoa(int(dl.ID)),
+		StateType:  stateType,
+		StateValue: value,
+	})
+	if err != nil {
+		return fmt.Errorf("create %s log: %w", stateType, err)
+	}
+	_, err = optSvc.DoorlockStatusLogSvc.CreateDoorlockStatusEvent(context.Background(), &models.DoorlockStatusEvent{
+		DoorID:    dl.ID,
+		RoomID:    dl.RoomId,
+		EventType: eventType,
+		Value:     value,
+	})
+	if err != nil {
+		return fmt.Errorf("create %s event: %w", stateType, err)
+	}
+	return nil
+}
+
+func gwDoorlockCreateHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayDoorlockPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		dl := parseDoorlockPayload(payload)
+		restored, err := optSvc.DoorlockSvc.RestoreDoorlockByAddress(context.Background(), dl.DoorlockAddress, dl.GatewayID)
+		if err != nil {
+			return fmt.Errorf("restore doorlock: %w", err)
+		}
+		if !restored {
+			if _, err := optSvc.DoorlockSvc.CreateDoorlock(context.Background(), dl); err != nil {
+				return fmt.Errorf("create doorlock: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+func gwDoorlockDeleteHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayDoorlockPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		_, err := optSvc.DoorlockSvc.DeleteDoorlockByAddress(context.Background(), &models.Doorlock{
+			DoorlockAddress: payload.Message.DoorlockAddress,
+			GatewayID:       payload.GatewayId,
+		}, models.DELETED_BY_GATEWAY)
+		// Doorlock already deleted
+		if err != nil && !errors.Is(err, utils.ErrNoRecordAffected) {
+			return fmt.Errorf("delete doorlock: %w", err)
+		}
+		return nil
+	}
+}
+
+func gwLastWillHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayEnvelope{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		gwId := payload.GatewayId
+		logger.LogfWithoutFields(logger.MQTT, logger.DebugLevel, "Gateway ID %s has disconnected", gwId)
+		// Unknown or deleted gateway has no connect state to update
+		gw, _ := optSvc.GatewaySvc.FindGatewayByMacID(context.Background(), gwId)
+		if gw == nil {
+			return nil
+		}
+		if _, err := optSvc.GatewaySvc.UpdateGatewayConnectState(context.Background(), gw.GatewayID, false); err != nil {
+			return fmt.Errorf("update connect_state: %w", err)
+		}
+		return nil
+	}
+}
+
+func gwCredentialRevokeAckHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayRevokeAckPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		gwId := payload.GatewayId
+		revocationId := payload.Message.RevocationId
+		if _, err := optSvc.RevocationSvc.AckRevocation(context.Background(), uint(revocationId), gwId); err != nil {
+			return fmt.Errorf("ack revocation %d: %w", revocationId, err)
+		}
+		return nil
+	}
+}
+
+func gwAccessEventHandler(client mqtt.Client, optSvc *models.ServiceOptions) GatewayHandler {
+	return func(c mqtt.Client, msg mqtt.Message) error {
+		payload := &GatewayAccessEventPayload{}
+		if err := decodePayload(msg, payload); err != nil {
+			return err
+		}
+		gwId := payload.GatewayId
+		eventMsg := payload.Message
+		doorlockAddress := eventMsg.DoorlockAddress
+
+		dl, err := optSvc.DoorlockSvc.FindDoorlockByAddress(context.Background(), doorlockAddress, gwId)
+		if err != nil {
+			return fmt.Errorf("unknown doorlock %s of gateway ID %s", doorlockAddress, gwId)
+		}
+		ae := &models.AccessEvent{
+			GatewayID: gwId,
+			DoorID:    dl.ID,
+			UserID:    eventMsg.UserId,
+			Direction: eventMsg.Direction,
+			Result:    eventMsg.AccessResult,
+			Reason:    eventMsg.Reason,
+		}
+		if eventMsg.EventTime > 0 {
+			ae.EventTime = time.Unix(int64(eventMsg.EventTime), 0)
+		}
+		ae, err = optSvc.OccupancySvc.RecordAccessEvent(context.Background(), ae)
+		if err != nil {
+			return fmt.Errorf("record access event: %w", err)
+		}
+		if ae.RoomID == "" || ae.Direction == "" || ae.Result == models.ACCESS_RESULT_DENIED {
+			return nil
 		}
+
+		// Event is recorded, a replay would count it twice. Gateways get the room state on their next bootup
+		ro, err := optSvc.OccupancySvc.FindRoomOccupancy(context.Background(), ae.RoomID)
+		if err != nil || ro.AntiPassback == models.ANTI_PASSBACK_OFF {
+			return nil
+		}
+		PublishRoomAntiPassback(client, optSvc, ro)
+		return nil
 	}
 }
 
-func gwDoorlockCreateSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		dl := parseDoorlockPayload(string(msg.Payload()))
-		optSvc.DoorlockSvc.CreateDoorlock(context.Background(), dl)
+// Send anti-passback state of the room to the gateways of its reader doorlocks
+func PublishRoomAntiPassback(client mqtt.Client, optSvc *models.ServiceOptions, ro *models.RoomOccupancy) error {
+	dls, err := optSvc.DoorlockSvc.FindAllDoorlocksByRoomID(context.Background(), ro.RoomID)
+	if err != nil {
+		return nil
+	}
+	gwDls := map[string][]*models.Doorlock{}
+	gwIds := []string{}
+	for _, dl := range dls {
+		if dl.ReaderDirection == "" {
+			continue
+		}
+		if _, ok := gwDls[dl.GatewayID]; !ok {
+			gwIds = append(gwIds, dl.GatewayID)
+		}
+		gwDls[dl.GatewayID] = append(gwDls[dl.GatewayID], dl)
 	}
+	for _, gwId := range gwIds {
+		t := client.Publish(TOPIC_SV_ANTIPASSBACK_U, 1, false,
+			ServerUpdateAntiPassbackPayload(gwId, NewAntiPassbackRoom(ro, gwDls[gwId])))
+		if err := HandleMqttErr(t); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func gwDoorlockDeleteSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		gatewayId := gjson.Get(payloadStr, "gateway_id").String()
-		doorStateMsg := gjson.Get(payloadStr, "message").String()
-		doorlockAddress := gjson.Get(doorStateMsg, "doorlock_address").String()
-		optSvc.DoorlockSvc.DeleteDoorlockByAddress(context.Background(), &models.Doorlock{
-			DoorlockAddress: doorlockAddress,
-			GatewayID:       gatewayId,
+// Send due scheduled commands every SCHEDULED_COMMAND_TICK, runs missed while down are handled on the first tick
+// Scheduled commands run as a job of the server job runner
+func startCommandScheduler(client mqtt.Client, optSvc *models.ServiceOptions) {
+	err := optSvc.JobRunner.Register(models.JOB_SCHEDULED_COMMANDS, models.SCHEDULED_COMMAND_TICK,
+		func(ctx context.Context, now time.Time) error {
+			return runDueScheduledCommands(ctx, client, optSvc, now)
 		})
+	if err != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Register scheduled commands job failed: %s", err.Error())
 	}
 }
 
-func gwLastWillSubscriber(client mqtt.Client, optSvc *models.ServiceOptions) mqtt.MessageHandler {
-	return func(c mqtt.Client, msg mqtt.Message) {
-		var payloadStr = string(msg.Payload())
-		gwId := gjson.Get(payloadStr, "gateway_id")
-		logger.LogfWithoutFields(logger.MQTT, logger.DebugLevel, "Gateway ID %s has disconnected", gwId.String())
-		gw, _ := optSvc.GatewaySvc.FindGatewayByMacID(context.Background(), gwId.String())
-		if gw != nil {
-			gw.ConnectState = false
-			_, err := optSvc.GatewaySvc.UpdateGatewayConnectState(context.Background(), gw.GatewayID, gw.ConnectState)
-			if err != nil {
-				logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel,
-					"Update connect_state for gateway ID %s failed, err %s", gwId.String(), err.Error())
+func runDueScheduledCommands(ctx context.Context, client mqtt.Client, optSvc *models.ServiceOptions, now time.Time) error {
+	runList, err := optSvc.ScheduledCommandSvc.RunDueScheduledCommands(ctx, now,
+		ScheduledCommandDispatcher(client, optSvc))
+	for _, run := range runList {
+		logger.LogfWithFields(logger.MQTT, logger.InfoLevel, logger.LoggerFields{
+			"missed":    run.Missed,
+			"doorlocks": run.Doorlocks,
+			"skipped":   run.Skipped,
+			"error":     run.ErrorMsg,
+		}, "Scheduled command %d run %s", run.ScheduledCommandID, run.Status)
+	}
+	return err
+}
+
+// Send scheduled command to each doorlock and save its state as PATCH /v1/doorlock/cmd does
+func ScheduledCommandDispatcher(client mqtt.Client, optSvc *models.ServiceOptions) models.ScheduledCommandDispatcher {
+	return func(sc *models.ScheduledCommand, dlList []models.Doorlock) (int, error) {
+		sent := 0
+		for _, dl := range dlList {
+			cmd := &models.DoorlockCmd{ID: strconv.FormatUint(uint64(dl.ID), 10), State: sc.Action, Duration: sc.Duration}
+			t := client.Publish(TOPIC_SV_DOORLOCK_CMD, 1, false, ServerCmdDoorlockPayload(dl.GatewayID, dl.DoorlockAddress, cmd))
+			if err := HandleMqttErr(t); err != nil {
+				return sent, err
+			}
+			sent++
+			// Timed action opens the door once, door mode keeps the lock state
+			if cmd.Duration != "" {
+				optSvc.DoorlockSvc.UpdateDoorlockState(context.Background(), cmd)
+			} else {
+				optSvc.DoorlockSvc.UpdateDoorlockStateCmd(context.Background(), cmd)
 			}
 		}
+		return sent, nil
 	}
 }
 
 // Util funcs
-func parseDoorlockPayload(payloadStr string) *models.Doorlock {
-	doorStateMsg := gjson.Get(payloadStr, "message").String()
-	doorlockAdress := gjson.Get(doorStateMsg, "doorlock_address")
-	active_state := gjson.Get(doorStateMsg, "doorlock_active_state")
-	gatewayId := gjson.Get(payloadStr, "gateway_id")
-	open_state := gjson.Get(doorStateMsg, "doorlock_open_state")
-	lock_state := gjson.Get(doorStateMsg, "doorlock_lock_state")
-	doorSerialId := uuid.New().String()
-
+func parseDoorlockPayload(payload *GatewayDoorlockPayload) *models.Doorlock {
+	doorStateMsg := payload.Message
 	dl := &models.Doorlock{
-		GatewayID:       gatewayId.String(),
-		DoorSerialID:    doorSerialId,
-		DoorlockAddress: doorlockAdress.String(),
-		ActiveState:     active_state.String(),
-		DoorState:       open_state.String(),
-		LockState:       lock_state.String(),
+		GatewayID:       payload.GatewayId,
+		DoorSerialID:    uuid.New().String(),
+		DoorlockAddress: doorStateMsg.DoorlockAddress,
+		ActiveState:     doorStateMsg.ActiveState,
+		DoorState:       doorStateMsg.OpenState,
+		LockState:       doorStateMsg.LockState,
 	}
 	return dl
 }
 
-func getUserPassInfoFromScheduler(optSvc *models.ServiceOptions, sche models.Scheduler) (userIdPwd UserIDPassword, err bool) {
-
-	err = false
-	if sche.Role == "employee" {
-		emp, _ := optSvc.EmployeeSvc.FindEmployeeByMSNV(context.Background(), sche.UserID)
-		userIdPwd.UserId = emp.MSNV
-		userIdPwd.RfidPass = emp.RfidPass
-		userIdPwd.KeypadPass = emp.KeypadPass
-		err = true
-	} else if sche.Role == "student" {
-		stu, _ := optSvc.StudentSvc.FindStudentByMSSV(context.Background(), sche.UserID)
-		userIdPwd.UserId = stu.MSSV
-		userIdPwd.RfidPass = stu.RfidPass
-		userIdPwd.KeypadPass = stu.KeypadPass
-		err = true
-	} else if sche.Role == "customer" {
-		cus, _ := optSvc.CustomerSvc.FindCustomerByCCCD(context.Background(), sche.UserID)
-		userIdPwd.UserId = cus.CCCD
-		userIdPwd.RfidPass = cus.RfidPass
-		userIdPwd.KeypadPass = cus.KeypadPass
-		err = true
+// Return false when scheduler user was deleted
+func GetUserPassInfoFromScheduler(optSvc *models.ServiceOptions, sche models.Scheduler) (userIdPwd UserIDPassword, err bool) {
+	if sche.PersonID == nil {
+		return userIdPwd, false
 	}
-
-	return userIdPwd, err
+	p, findErr := optSvc.PersonSvc.FindPersonByID(context.Background(), strconv.Itoa(int(*sche.PersonID)))
+	if findErr != nil {
+		return userIdPwd, false
+	}
+	return *NewUserIDPassword(p.UserID, p), true
 }
 
-func mergeInfoToScheBootUp(optSvc *models.ServiceOptions, dlList []models.Doorlock) (scheBoUpList []*SchedulerBootUp) {
+// Registers of recurring access policies are returned as version 2 registers
+func mergeInfoToScheBootUp(optSvc *models.ServiceOptions, dlList []models.Doorlock) (
+	scheBoUpList []*SchedulerBootUp, registerV2List []*RegisterV2) {
+	policies := map[uint]*models.AccessPolicy{}
 	for _, dl := range dlList {
 		for _, sche := range dl.Schedulers {
 
-			uIp, _ := getUserPassInfoFromScheduler(optSvc, sche)
+			uIp, ok := GetUserPassInfoFromScheduler(optSvc, sche)
+			if !ok {
+				continue
+			}
+
+			if sche.AccessPolicyID != nil {
+				ap, found := policies[*sche.AccessPolicyID]
+				if !found {
+					ap, _ = optSvc.AccessPolicySvc.FindAccessPolicyByID(context.Background(), strconv.Itoa(int(*sche.AccessPolicyID)))
+					policies[*sche.AccessPolicyID] = ap
+				}
+				if ap != nil && ap.IsRecurring() {
+					registerV2List = append(registerV2List, NewRegisterV2(dl.DoorlockAddress, &sche, ap, &uIp))
+					continue
+				}
+			}
 
 			scheBoUp := SchedulerBootUp{
 				SchedulerId:     strconv.Itoa(int(sche.ID)),
 				UserId:          uIp.UserId,
 				RfidPass:        uIp.RfidPass,
 				KeypadPass:      uIp.KeypadPass,
+				RfidPasses:      uIp.RfidPasses,
+				KeypadPasses:    uIp.KeypadPasses,
 				DoorlockAddress: dl.DoorlockAddress,
 				StartDate:       sche.StartDate,
 				EndDate:         sche.EndDate,
@@ -439,5 +769,30 @@ func mergeInfoToScheBootUp(optSvc *models.ServiceOptions, dlList []models.Doorlo
 			scheBoUpList = append(scheBoUpList, &scheBoUp)
 		}
 	}
-	return scheBoUpList
+	return scheBoUpList, registerV2List
+}
+
+func mergeInfoToAntiPassbackBootUp(optSvc *models.ServiceOptions, dlList []models.Doorlock) []AntiPassbackRoom {
+	roomDls := map[string][]*models.Doorlock{}
+	roomIds := []string{}
+	for i := range dlList {
+		dl := &dlList[i]
+		if dl.RoomId == "" || dl.ReaderDirection == "" {
+			continue
+		}
+		if _, ok := roomDls[dl.RoomId]; !ok {
+			roomIds = append(roomIds, dl.RoomId)
+		}
+		roomDls[dl.RoomId] = append(roomDls[dl.RoomId], dl)
+	}
+
+	rooms := []AntiPassbackRoom{}
+	for _, roomId := range roomIds {
+		ro, err := optSvc.OccupancySvc.FindRoomOccupancy(context.Background(), roomId)
+		if err != nil {
+			continue
+		}
+		rooms = append(rooms, NewAntiPassbackRoom(ro, roomDls[roomId]))
+	}
+	return rooms
 }
@@ -4,6 +4,9 @@
 package mqttSvc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -15,11 +18,19 @@ func TestParseDoorlockPayload(t *testing.T) {
 		"gateway_id":"%s",
 		"message": {
 		"doorlock_address":"%s",
-		"doorlock_active_state":"%s",
+		"doorlock_active_state":"%s"
 	}
 		
 	}`, "test", "test", "test")
-	dl := parseDoorlockPayload(payload)
+	checked, err := ValidatePayload(TOPIC_GW_DOORLOCK_C, []byte(payload))
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	dlPayload := &GatewayDoorlockPayload{}
+	if err := json.Unmarshal(checked, dlPayload); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	dl := parseDoorlockPayload(dlPayload)
 	expected := &models.Doorlock{
 		GatewayID:       "test",
 		DoorlockAddress: "test",
@@ -38,3 +49,82 @@ func TestParseDoorlockPayload(t *testing.T) {
 		t.Errorf("got %+v, wanted %+v", dl.ActiveState, expected.ActiveState)
 	}
 }
+
+// Doorlock service of a single doorlock, methods not overridden are not expected to be called
+type mockDoorlockSvc struct {
+	models.DoorlockService
+	dl         *models.Doorlock
+	lockErr    error
+	lockStates []string
+}
+
+func (m *mockDoorlockSvc) FindDoorlockByAddress(ctx context.Context, address string, gwId string) (*models.Doorlock, error) {
+	if m.dl == nil || m.dl.DoorlockAddress != address || m.dl.GatewayID != gwId {
+		return nil, errors.New("find no records")
+	}
+	return m.dl, nil
+}
+
+func (m *mockDoorlockSvc) UpdateLockState(ctx context.Context, dls *models.DoorlockStatus) (bool, error) {
+	if m.lockErr != nil {
+		return false, m.lockErr
+	}
+	m.lockStates = append(m.lockStates, dls.LockState)
+	return true, nil
+}
+
+type mockStatusLogSvc struct {
+	models.DoorlockStatusLogService
+	logs   []models.DoorlockStatusLog
+	events []models.DoorlockStatusEvent
+}
+
+func (m *mockStatusLogSvc) CreateDoorlockStatusLog(ctx context.Context, dlsl *models.DoorlockStatusLog) (*models.DoorlockStatusLog, error) {
+	m.logs = append(m.logs, *dlsl)
+	return dlsl, nil
+}
+
+func (m *mockStatusLogSvc) CreateDoorlockStatusEvent(ctx context.Context, dlse *models.DoorlockStatusEvent) (*models.DoorlockStatusEvent, error) {
+	m.events = append(m.events, *dlse)
+	return dlse, nil
+}
+
+func TestGwDoorlockUpdateHandler(t *testing.T) {
+	dl := &models.Doorlock{GatewayID: "gw-1", DoorlockAddress: "1", RoomId: "R1"}
+	dl.ID = 7
+	dlSvc := &mockDoorlockSvc{dl: dl}
+	logSvc := &mockStatusLogSvc{}
+	handler := gwDoorlockUpdateHandler(nil, &models.ServiceOptions{
+		DoorlockSvc:          dlSvc,
+		DoorlockStatusLogSvc: logSvc,
+	})
+	lockPayload := `{"gateway_id":"gw-1","message":{"doorlock_address":"%s","doorlock_lock_state":"unlock"}}`
+
+	if err := handler(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: fmt.Sprintf(lockPayload, "1")}); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if len(dlSvc.lockStates) != 1 || dlSvc.lockStates[0] != "unlock" {
+		t.Errorf("got lock states %v, wanted [unlock]", dlSvc.lockStates)
+	}
+	if len(logSvc.logs) != 1 || logSvc.logs[0].DoorID != "7" || logSvc.logs[0].StateType != "lockState" {
+		t.Errorf("got logs %+v, wanted lockState of door 7", logSvc.logs)
+	}
+	if len(logSvc.events) != 1 || logSvc.events[0].RoomID != "R1" || logSvc.events[0].EventType != models.STATUS_EVENT_LOCK {
+		t.Errorf("got events %+v, wanted lock event of room R1", logSvc.events)
+	}
+
+	// Unknown doorlock fails, nothing is recorded
+	if err := handler(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: fmt.Sprintf(lockPayload, "2")}); err == nil {
+		t.Error("unknown doorlock handled")
+	}
+
+	// Failed update is returned so the message becomes a dead letter, its state is not logged
+	dlSvc.lockErr = errors.New("db down")
+	err := handler(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: fmt.Sprintf(lockPayload, "1")})
+	if !errors.Is(err, dlSvc.lockErr) {
+		t.Errorf("got %v, wanted %v", err, dlSvc.lockErr)
+	}
+	if len(logSvc.logs) != 1 {
+		t.Errorf("got %d logs, wanted 1", len(logSvc.logs))
+	}
+}
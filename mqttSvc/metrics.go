@@ -0,0 +1,46 @@
+package mqttSvc
+
+import (
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+)
+
+const (
+	// Publish not acknowledged within this is counted as failed
+	MQTT_PUBLISH_TIMEOUT time.Duration = 10 * time.Second
+)
+
+// Client counting every message the server publishes and the failed ones
+type instrumentedClient struct {
+	mqtt.Client
+}
+
+func instrumentClient(client mqtt.Client) mqtt.Client {
+	return &instrumentedClient{client}
+}
+
+func (ic *instrumentedClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	metrics.MqttMessagesPublished.WithLabelValues(topic).Inc()
+	t := ic.Client.Publish(topic, qos, retained, payload)
+	go func() {
+		if !t.WaitTimeout(MQTT_PUBLISH_TIMEOUT) || t.Error() != nil {
+			metrics.MqttPublishFailures.WithLabelValues(topic).Inc()
+		}
+	}()
+	return t
+}
+
+// Count messages received on topic
+func instrumentSubscriber(topic string, subscriber GatewaySubscriber) GatewaySubscriber {
+	return func(c mqtt.Client, msg mqtt.Message) {
+		metrics.MqttMessagesReceived.WithLabelValues(topic).Inc()
+		subscriber(c, msg)
+	}
+}
+
+// Count a received message whose processing failed
+func subscriberError(msg mqtt.Message) {
+	metrics.MqttSubscriberErrors.WithLabelValues(msg.Topic()).Inc()
+}
@@ -0,0 +1,67 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Client whose publishes all fail, only Publish is used
+type failingClient struct {
+	mqtt.Client
+}
+
+func (fc *failingClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	return &MockToken{}
+}
+
+type mockMessage struct {
+	mqtt.Message
+	topic     string
+	payload   string
+	duplicate bool
+}
+
+func (mm *mockMessage) Topic() string   { return mm.topic }
+func (mm *mockMessage) Payload() []byte { return []byte(mm.payload) }
+func (mm *mockMessage) Duplicate() bool { return mm.duplicate }
+
+func TestInstrumentedClient(t *testing.T) {
+	topic := "test/publish"
+	client := instrumentClient(&failingClient{})
+	client.Publish(topic, 1, false, "{}")
+	client.Publish(topic, 1, false, "{}")
+
+	if got := testutil.ToFloat64(metrics.MqttMessagesPublished.WithLabelValues(topic)); got != 2 {
+		t.Errorf("got %v published, wanted %v", got, 2)
+	}
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(metrics.MqttPublishFailures.WithLabelValues(topic)) != 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(metrics.MqttPublishFailures.WithLabelValues(topic)); got != 2 {
+		t.Errorf("got %v publish failures, wanted %v", got, 2)
+	}
+}
+
+func TestInstrumentSubscriber(t *testing.T) {
+	topic := "test/subscribe"
+	sub := instrumentSubscriber(topic, func(c mqtt.Client, msg mqtt.Message) {
+		subscriberError(msg)
+	})
+	sub(nil, &mockMessage{topic: topic})
+	sub(nil, &mockMessage{topic: topic})
+
+	if got := testutil.ToFloat64(metrics.MqttMessagesReceived.WithLabelValues(topic)); got != 2 {
+		t.Errorf("got %v received, wanted %v", got, 2)
+	}
+	if got := testutil.ToFloat64(metrics.MqttSubscriberErrors.WithLabelValues(topic)); got != 2 {
+		t.Errorf("got %v subscriber errors, wanted %v", got, 2)
+	}
+}
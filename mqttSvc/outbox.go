@@ -0,0 +1,166 @@
+package mqttSvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+var ErrBrokerDisconnected = errors.New("MQTT broker is disconnected, command was not sent")
+
+// Doorlock commands are stale once the broker is back, they fail instead of being queued
+var unqueuedTopics = map[string]bool{
+	TOPIC_SV_DOORLOCK_CMD: true,
+}
+
+// Client queuing publishes in the outbox while the broker is unreachable.
+// Until the outbox is emptied publishes keep going through it, so none passes a queued one
+type outboxClient struct {
+	mqtt.Client
+	outbox   models.MqttOutboxService
+	flushing int32
+
+	mu      sync.Mutex // held while deciding to queue and queuing, so a flush finding the outbox empty sees every queued message
+	queuing bool
+}
+
+func newOutboxClient(client mqtt.Client, outbox models.MqttOutboxService) *outboxClient {
+	// Messages left by a previous run are sent before new ones
+	cnt, err := outbox.CountMqttOutboxMessage(context.Background())
+	if err != nil {
+		logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Count queued messages failed: %s", err.Error())
+	}
+	return &outboxClient{
+		Client:  client,
+		outbox:  outbox,
+		queuing: err != nil || cnt > 0,
+	}
+}
+
+func (oc *outboxClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	connected := oc.Client.IsConnectionOpen()
+	// Doorlock commands do not wait behind queued messages
+	if connected && unqueuedTopics[topic] {
+		return oc.Client.Publish(topic, qos, retained, payload)
+	}
+	oc.mu.Lock()
+	if connected && !oc.queuing {
+		oc.mu.Unlock()
+		return oc.Client.Publish(topic, qos, retained, payload)
+	}
+	token := oc.enqueue(topic, qos, retained, payload)
+	oc.mu.Unlock()
+	// Queued while connected, a flush still running sends it, otherwise one is started
+	if connected {
+		go oc.flushOutbox()
+	}
+	return token
+}
+
+// Called with mu held
+func (oc *outboxClient) enqueue(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	if unqueuedTopics[topic] {
+		return newDoneToken(ErrBrokerDisconnected)
+	}
+	b, err := payloadBytes(payload)
+	if err != nil {
+		return newDoneToken(err)
+	}
+	dropped, err := oc.outbox.EnqueueMqttOutboxMessage(context.Background(), &models.MqttOutboxMessage{
+		Topic:    topic,
+		Qos:      qos,
+		Retained: retained,
+		Payload:  b,
+	})
+	if err != nil {
+		return newDoneToken(fmt.Errorf("queue message for %s failed: %w", topic, err))
+	}
+	oc.queuing = true
+	if dropped > 0 {
+		metrics.MqttOutboxDropped.Add(float64(dropped))
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Outbox full, dropped %d oldest messages", dropped)
+	}
+	return newDoneToken(nil)
+}
+
+// Send queued messages oldest first, stop at the first failure so the rest waits for the next connect.
+// Publishes go out directly again only once the outbox is found empty
+func (oc *outboxClient) flushOutbox() {
+	if !atomic.CompareAndSwapInt32(&oc.flushing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&oc.flushing, 0)
+
+	sent := 0
+	defer func() {
+		if sent > 0 {
+			logger.LogfWithoutFields(logger.MQTT, logger.InfoLevel, "[MQTT-INFO] Sent %d queued messages", sent)
+		}
+	}()
+	for {
+		oc.mu.Lock()
+		msgList, err := oc.outbox.FindMqttOutboxMessages(context.Background(), models.MQTT_OUTBOX_FLUSH_BATCH)
+		if err == nil && len(msgList) == 0 {
+			oc.queuing = false
+		}
+		oc.mu.Unlock()
+		if err != nil {
+			logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Find queued messages failed: %s", err.Error())
+			return
+		}
+		if len(msgList) == 0 {
+			return
+		}
+		for _, msg := range msgList {
+			if !oc.Client.IsConnectionOpen() {
+				return
+			}
+			t := oc.Client.Publish(msg.Topic, msg.Qos, msg.Retained, msg.Payload)
+			if !t.WaitTimeout(MQTT_PUBLISH_TIMEOUT) || t.Error() != nil {
+				logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Send queued message %d failed, retry on next connect or publish", msg.ID)
+				return
+			}
+			if _, err := oc.outbox.DeleteMqttOutboxMessage(context.Background(), msg.ID); err != nil {
+				logger.LogfWithoutFields(logger.MQTT, logger.ErrorLevel, "Delete queued message %d failed: %s", msg.ID, err.Error())
+				return
+			}
+			sent++
+		}
+	}
+}
+
+func payloadBytes(payload interface{}) ([]byte, error) {
+	switch p := payload.(type) {
+	case string:
+		return []byte(p), nil
+	case []byte:
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown payload type %T", payload)
+	}
+}
+
+// Token of a publish completed without reaching the broker, queued or refused
+type doneToken struct {
+	err  error
+	done chan struct{}
+}
+
+func newDoneToken(err error) *doneToken {
+	done := make(chan struct{})
+	close(done)
+	return &doneToken{err: err, done: done}
+}
+
+func (dt *doneToken) Wait() bool                       { return true }
+func (dt *doneToken) WaitTimeout(_ time.Duration) bool { return true }
+func (dt *doneToken) Done() <-chan struct{}            { return dt.done }
+func (dt *doneToken) Error() error                     { return dt.err }
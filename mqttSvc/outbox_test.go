@@ -0,0 +1,114 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/migrations"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// Client recording publishes, connection is toggled by the test
+type recordingClient struct {
+	mqtt.Client
+	open      bool
+	published []string
+	onPublish func() // called after recording a publish when set
+}
+
+func (rc *recordingClient) IsConnectionOpen() bool { return rc.open }
+
+func (rc *recordingClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	b, _ := payloadBytes(payload)
+	rc.published = append(rc.published, string(b))
+	if rc.onPublish != nil {
+		onPublish := rc.onPublish
+		rc.onPublish = nil
+		onPublish()
+	}
+	return newDoneToken(nil)
+}
+
+func newOutboxSvc(t *testing.T) *models.MqttOutboxSvc {
+	t.Helper()
+	return models.NewMqttOutboxSvc(newMigratedDb(t), 0)
+}
+
+func newMigratedDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	sqlDb, _ := db.DB()
+	sqlDb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDb.Close() })
+	if err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	return db
+}
+
+func TestOutboxClient(t *testing.T) {
+	rc := &recordingClient{}
+	outbox := newOutboxSvc(t)
+	client := newOutboxClient(rc, outbox)
+
+	// Disconnected, commands are refused and the rest is queued
+	if err := client.Publish(TOPIC_SV_DOORLOCK_CMD, 1, false, "cmd").Error(); !errors.Is(err, ErrBrokerDisconnected) {
+		t.Errorf("got %v, wanted %v", err, ErrBrokerDisconnected)
+	}
+	for _, payload := range []string{"1", "2"} {
+		if err := client.Publish(TOPIC_SV_USER_U, 1, false, payload).Error(); err != nil {
+			t.Fatalf("queue failed: %v", err)
+		}
+	}
+	if len(rc.published) != 0 {
+		t.Fatalf("got %d published while disconnected, wanted 0", len(rc.published))
+	}
+
+	// Reconnected, queued messages are sent in order and removed
+	rc.open = true
+	client.flushOutbox()
+	client.Publish(TOPIC_SV_USER_U, 1, false, []byte("3"))
+	if got := rc.published; len(got) != 3 || got[0] != "1" || got[1] != "2" || got[2] != "3" {
+		t.Errorf("got published %v, wanted [1 2 3]", got)
+	}
+	if cnt, _ := outbox.CountMqttOutboxMessage(context.Background()); cnt != 0 {
+		t.Errorf("got %d queued after flush, wanted 0", cnt)
+	}
+}
+
+func TestOutboxClientKeepsOrderWhileFlushing(t *testing.T) {
+	rc := &recordingClient{}
+	outbox := newOutboxSvc(t)
+	newOutboxClient(rc, outbox).Publish(TOPIC_SV_USER_U, 1, false, "1")
+	newOutboxClient(rc, outbox).Publish(TOPIC_SV_USER_U, 1, false, "2")
+
+	// Messages left by a previous client are sent first, publishing meanwhile waits behind them
+	rc.open = true
+	client := newOutboxClient(rc, outbox)
+	rc.onPublish = func() {
+		client.Publish(TOPIC_SV_USER_U, 1, false, "3")
+		client.Publish(TOPIC_SV_DOORLOCK_CMD, 1, false, "cmd")
+	}
+	client.flushOutbox()
+	client.Publish(TOPIC_SV_USER_U, 1, false, "4")
+	if got := strings.Join(rc.published, " "); got != "1 cmd 2 3 4" {
+		t.Errorf("got published [%s], wanted [1 cmd 2 3 4]", got)
+	}
+	if cnt, _ := outbox.CountMqttOutboxMessage(context.Background()); cnt != 0 {
+		t.Errorf("got %d queued after flush, wanted 0", cnt)
+	}
+}
@@ -2,7 +2,6 @@ package mqttSvc
 
 import (
 	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -10,21 +9,207 @@ import (
 	"github.com/ecoprohcm/DMS_BackendServer/models"
 )
 
+// Struct defines user credentials sent to gateways, rfid_pw and keypad_pw are the first of the active lists
 type UserIDPassword struct {
-	UserId     string `json:"user_id"`
-	RfidPass   string `json:"rfid_pw"`
-	KeypadPass string `json:"keypad_pw"`
+	UserId       string   `json:"user_id"`
+	RfidPass     string   `json:"rfid_pw"`
+	KeypadPass   string   `json:"keypad_pw"`
+	RfidPasses   []string `json:"rfid_pws"`
+	KeypadPasses []string `json:"keypad_pws"`
 }
+
+// Build user credentials from all usable credentials of the person
+func NewUserIDPassword(userId string, p *models.Person) *UserIDPassword {
+	now := time.Now()
+	up := p.PrimaryUserPass(now)
+	return &UserIDPassword{
+		UserId:       userId,
+		RfidPass:     up.RfidPass,
+		KeypadPass:   up.KeypadPass,
+		RfidPasses:   p.UsableCredentials(models.CREDENTIAL_TYPE_RFID, now),
+		KeypadPasses: p.UsableCredentials(models.CREDENTIAL_TYPE_KEYPAD, now),
+	}
+}
+
+// Struct defines revoked credential sent to gateways, gateway answers with the revocation_id on the ack topic
+type RevokedCredential struct {
+	RevocationId string `json:"revocation_id"`
+	UserId       string `json:"user_id"`
+	Type         string `json:"type"`
+	Value        string `json:"value"`
+}
+
+func NewRevokedCredential(r *models.CredentialRevocation) RevokedCredential {
+	return RevokedCredential{
+		RevocationId: strconv.Itoa(int(r.ID)),
+		UserId:       r.UserID,
+		Type:         r.Type,
+		Value:        r.Value,
+	}
+}
+
+type AntiPassbackDoorlock struct {
+	DoorlockAddress string `json:"doorlock_address"`
+	Direction       string `json:"direction"`
+}
+
+// Anti-passback state of a room. In hard mode gateways deny entry of inside users, exit of the others
+// and entry into a full room. Only reader doorlocks of the receiving gateway are listed
+type AntiPassbackRoom struct {
+	RoomId        string                 `json:"room_id"`
+	Mode          string                 `json:"mode"`
+	Capacity      uint                   `json:"capacity"`
+	Full          bool                   `json:"full"`
+	Doorlocks     []AntiPassbackDoorlock `json:"doorlocks"`
+	InsideUserIds []string               `json:"inside_user_ids"`
+}
+
+func NewAntiPassbackRoom(ro *models.RoomOccupancy, dls []*models.Doorlock) AntiPassbackRoom {
+	room := AntiPassbackRoom{
+		RoomId:        ro.RoomID,
+		Mode:          ro.AntiPassback,
+		Capacity:      ro.Capacity,
+		Full:          ro.Full,
+		Doorlocks:     []AntiPassbackDoorlock{},
+		InsideUserIds: []string{},
+	}
+	for _, dl := range dls {
+		if dl.ReaderDirection != "" {
+			room.Doorlocks = append(room.Doorlocks, AntiPassbackDoorlock{
+				DoorlockAddress: dl.DoorlockAddress,
+				Direction:       dl.ReaderDirection,
+			})
+		}
+	}
+	for _, rp := range ro.People {
+		room.InsideUserIds = append(room.InsideUserIds, rp.UserID)
+	}
+	return room
+}
+
 type DoorlockBootUp struct {
 	DoorlockAddress string `json:"doorlock_address"`
 	ActiveState     string `json:"doorlock_active_state"`
 }
 
 type SchedulerBootUp struct {
+	SchedulerId     string   `json:"register_id"`
+	UserId          string   `json:"user_id"`
+	RfidPass        string   `json:"rfid_pw"`
+	KeypadPass      string   `json:"keypad_pw"`
+	RfidPasses      []string `json:"rfid_pws"`
+	KeypadPasses    []string `json:"keypad_pws"`
+	DoorlockAddress string   `json:"doorlock_address"`
+	StartDate       string   `json:"start_date"`
+	EndDate         string   `json:"end_date"`
+	WeekDay         string   `json:"week_day"`
+	StartClass      string   `json:"start_class"`
+	EndClass        string   `json:"end_class"`
+}
+
+// Version of RegisterV2 payload
+const REGISTER_PAYLOAD_VERSION_2 int = 2
+
+type RegisterWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Register of a recurring access policy. Access is open inside one of the windows on days the rrule occurs,
+// between start_date and end_date. Days and windows are local to time_zone, utc_offset is its offset in seconds
+// when the register is sent for gateways without a time zone database
+type RegisterV2 struct {
+	Version         int              `json:"version"`
+	SchedulerId     string           `json:"register_id"`
+	UserId          string           `json:"user_id"`
+	RfidPass        string           `json:"rfid_pw"`
+	KeypadPass      string           `json:"keypad_pw"`
+	RfidPasses      []string         `json:"rfid_pws"`
+	KeypadPasses    []string         `json:"keypad_pws"`
+	DoorlockAddress string           `json:"doorlock_address"`
+	StartDate       string           `json:"start_date"`
+	EndDate         string           `json:"end_date"`
+	TimeZone        string           `json:"time_zone"`
+	UtcOffset       int              `json:"utc_offset"`
+	Rrule           string           `json:"rrule"`
+	Windows         []RegisterWindow `json:"windows"`
+}
+
+// Compile register of recurring access policy into the version 2 payload
+func NewRegisterV2(
+	doorlockAddress string,
+	sche *models.Scheduler,
+	ap *models.AccessPolicy,
+	uP *UserIDPassword,
+) *RegisterV2 {
+	loc, err := time.LoadLocation(ap.TimeZone)
+	if err != nil {
+		loc, _ = time.LoadLocation(models.DEFAULT_POLICY_TIME_ZONE)
+	}
+	startDmySlice := getDayMonthYearSlice(sche.StartDate)
+	start := time.Date(startDmySlice[2], time.Month(startDmySlice[1]), startDmySlice[0], 0, 0, 0, 0, loc).Unix()
+	endDmySlice := getDayMonthYearSlice(sche.EndDate)
+	end := time.Date(endDmySlice[2], time.Month(endDmySlice[1]), endDmySlice[0], 23, 59, 59, 0, loc).Unix()
+	_, offset := time.Now().In(loc).Zone()
+
+	windows := []RegisterWindow{}
+	twList, _ := models.ParseTimeWindows(ap.TimeWindows)
+	for _, tw := range twList {
+		windows = append(windows, RegisterWindow{Start: models.FormatClock(tw.Start), End: models.FormatClock(tw.End)})
+	}
+
+	return &RegisterV2{
+		Version:         REGISTER_PAYLOAD_VERSION_2,
+		SchedulerId:     strconv.Itoa(int(sche.ID)),
+		UserId:          uP.UserId,
+		RfidPass:        uP.RfidPass,
+		KeypadPass:      uP.KeypadPass,
+		RfidPasses:      uP.RfidPasses,
+		KeypadPasses:    uP.KeypadPasses,
+		DoorlockAddress: doorlockAddress,
+		StartDate:       strconv.FormatInt(start, 10),
+		EndDate:         strconv.FormatInt(end, 10),
+		TimeZone:        loc.String(),
+		UtcOffset:       offset,
+		Rrule:           ap.Recurrence,
+		Windows:         windows,
+	}
+}
+
+// Messages of the server topics, every one is sent in an Envelope except server/gateway/update
+
+type DoorlockAddressMessage struct {
+	DoorlockAddress string `json:"doorlock_address"`
+}
+
+type DoorlockUpdateMessage struct {
+	DoorlockAddress string `json:"doorlock_address"`
+	ActiveState     string `json:"doorlock_active_state"`
+}
+
+type DoorlockCmdMessage struct {
+	DoorlockAddress string `json:"doorlock_address"`
+	Action          string `json:"action"`
+	Duration        string `json:"duration,omitempty"`
+}
+
+type GatewayCmdMessage struct {
+	Action string `json:"action"`
+}
+
+type GatewayUpdatePayload struct {
+	Version   int    `json:"version"`
+	GatewayId string `json:"gateway_id"`
+	AreaId    string `json:"area_id"`
+	Name      string `json:"name"`
+}
+
+type EmptyMessage struct{}
+
+// Register sent on update, credentials are sent on user topics
+type RegisterUpdateMessage struct {
 	SchedulerId     string `json:"register_id"`
 	UserId          string `json:"user_id"`
-	RfidPass        string `json:"rfid_pw"`
-	KeypadPass      string `json:"keypad_pw"`
 	DoorlockAddress string `json:"doorlock_address"`
 	StartDate       string `json:"start_date"`
 	EndDate         string `json:"end_date"`
@@ -33,39 +218,52 @@ type SchedulerBootUp struct {
 	EndClass        string `json:"end_class"`
 }
 
+type RegisterDeleteMessage struct {
+	SchedulerId string `json:"register_id"`
+}
+
+type UserDeleteMessage struct {
+	UserId string `json:"user_id"`
+}
+
+type SecretKeyMessage struct {
+	SecretKey string `json:"secret_key"`
+}
+
 func ServerCreateDoorlockPayload(doorlock *models.Doorlock) string {
-	msg := fmt.Sprintf(`{"doorlock_address":"%s"}`, doorlock.DoorlockAddress)
-	return PayloadWithGatewayId(doorlock.GatewayID, msg)
+	return PayloadWithGatewayId(doorlock.GatewayID, DoorlockAddressMessage{DoorlockAddress: doorlock.DoorlockAddress})
 }
 
 func ServerUpdateDoorlockPayload(doorlock *models.Doorlock) string {
-	msg := fmt.Sprintf(`{"doorlock_address":"%s","doorlock_active_state":"%s"}`,
-		doorlock.DoorlockAddress, doorlock.ActiveState)
-	return PayloadWithGatewayId(doorlock.GatewayID, msg)
+	return PayloadWithGatewayId(doorlock.GatewayID, DoorlockUpdateMessage{
+		DoorlockAddress: doorlock.DoorlockAddress,
+		ActiveState:     doorlock.ActiveState,
+	})
 }
 
 func ServerDeleteDoorlockPayload(doorlock *models.Doorlock) string {
-	msg := fmt.Sprintf(`{"doorlock_address":"%s"}`, doorlock.DoorlockAddress)
-	return PayloadWithGatewayId(doorlock.GatewayID, msg)
+	return PayloadWithGatewayId(doorlock.GatewayID, DoorlockAddressMessage{DoorlockAddress: doorlock.DoorlockAddress})
 }
 
 func ServerCmdDoorlockPayload(gwId string, doorlockAddress string, cmd *models.DoorlockCmd) string {
-	var duration string = ""
-	if cmd.Duration != "" {
-		duration = fmt.Sprintf(`,"duration":"%s"`, cmd.Duration)
-	}
-	msg := fmt.Sprintf(`{"doorlock_address":"%s","action":"%s"%s}`, doorlockAddress, cmd.State, duration)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, DoorlockCmdMessage{
+		DoorlockAddress: doorlockAddress,
+		Action:          cmd.State,
+		Duration:        cmd.Duration,
+	})
 }
 
 func ServerUpdateGatewayPayload(gw *models.Gateway) string {
-	return fmt.Sprintf(`{"gateway_id":"%s","area_id":"%s","name":"%s"}`,
-		gw.GatewayID, gw.AreaID, gw.Name)
+	return encodePayload(GatewayUpdatePayload{
+		Version:   CONTRACT_VERSION,
+		GatewayId: gw.GatewayID,
+		AreaId:    gw.AreaID,
+		Name:      gw.Name,
+	})
 }
 
 func ServerDeleteGatewayPayload(gwID string) string {
-	msg := `{}`
-	return PayloadWithGatewayId(gwID, msg)
+	return PayloadWithGatewayId(gwID, EmptyMessage{})
 }
 
 func ServerCreateRegisterPayload(
@@ -74,82 +272,112 @@ func ServerCreateRegisterPayload(
 	sche *models.Scheduler,
 	uP *UserIDPassword,
 ) string {
-
-	loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
-	startDmySlice := getDayMonthYearSlice(sche.StartDate)
-	start := time.Date(startDmySlice[2], time.Month(startDmySlice[1]), startDmySlice[0], 0, 0, 0, 0, loc).Unix()
-	endDmySlice := getDayMonthYearSlice(sche.EndDate)
-	end := time.Date(endDmySlice[2], time.Month(endDmySlice[1]), endDmySlice[0], 23, 59, 59, 0, loc).Unix()
-
-	msg := fmt.Sprintf(`{"register_id":"%d",
-	"user_id":"%s",
-	"doorlock_address":"%s",
-	"rfid_pw":"%s",
-	"keypad_pw":"%s",
-	"start_date":"%d",
-	"end_date":"%d",
-	"week_day":"%d",
-	"start_class":"%d",
-	"end_class":"%d"}`,
-		sche.ID, uP.UserId, doorlockAddress, uP.RfidPass, uP.KeypadPass,
-		start, end, sche.WeekDay, sche.StartClassTime, sche.EndClassTime)
-
-	return PayloadWithGatewayId(gwId, msg)
+	start, end := registerRange(sche.StartDate, sche.EndDate)
+	return PayloadWithGatewayId(gwId, SchedulerBootUp{
+		SchedulerId:     strconv.Itoa(int(sche.ID)),
+		UserId:          uP.UserId,
+		RfidPass:        uP.RfidPass,
+		KeypadPass:      uP.KeypadPass,
+		RfidPasses:      uP.RfidPasses,
+		KeypadPasses:    uP.KeypadPasses,
+		DoorlockAddress: doorlockAddress,
+		StartDate:       strconv.FormatInt(start, 10),
+		EndDate:         strconv.FormatInt(end, 10),
+		WeekDay:         strconv.Itoa(int(sche.WeekDay)),
+		StartClass:      strconv.Itoa(int(sche.StartClassTime)),
+		EndClass:        strconv.Itoa(int(sche.EndClassTime)),
+	})
 }
 
 func ServerUpdateRegisterPayload(gwId string, uSche *models.UpdateScheduler) string {
 	sche := uSche.Scheduler
-	loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
-	startDmySlice := getDayMonthYearSlice(sche.StartDate)
-	start := time.Date(startDmySlice[2], time.Month(startDmySlice[1]), startDmySlice[0], 0, 0, 0, 0, loc).Unix()
-	endDmySlice := getDayMonthYearSlice(sche.EndDate)
+	start, end := registerRange(sche.StartDate, sche.EndDate)
+	return PayloadWithGatewayId(gwId, RegisterUpdateMessage{
+		SchedulerId:     strconv.Itoa(int(sche.ID)),
+		UserId:          uSche.UserID,
+		DoorlockAddress: uSche.DoorlockAddress,
+		StartDate:       strconv.FormatInt(start, 10),
+		EndDate:         strconv.FormatInt(end, 10),
+		WeekDay:         strconv.Itoa(int(sche.WeekDay)),
+		StartClass:      strconv.Itoa(int(sche.StartClassTime)),
+		EndClass:        strconv.Itoa(int(sche.EndClassTime)),
+	})
+}
 
-	end := time.Date(endDmySlice[2], time.Month(endDmySlice[1]), endDmySlice[0], 23, 59, 59, 0, loc).Unix()
-	msg := fmt.Sprintf(`{"register_id":"%d",
-	"user_id":"%s",
-	"doorlock_address":"%s",
-	"start_date":"%d",
-	"end_date":"%d",
-	"week_day":"%d",
-	"start_class":"%d",
-	"end_class":"%d"}`,
-		sche.ID, uSche.UserID, uSche.DoorlockAddress,
-		start, end, sche.WeekDay, sche.StartClassTime, sche.EndClassTime)
-	return PayloadWithGatewayId(gwId, msg)
+// Create and update send the whole register, gateway replaces the register with the same register_id
+func ServerRegisterV2Payload(gwId string, register *RegisterV2) string {
+	return PayloadWithGatewayId(gwId, register)
 }
 
 func ServerDeleteRegisterPayload(gwId string, registerId uint) string {
-	msg := fmt.Sprintf(`{"register_id":"%d"}`, registerId)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, RegisterDeleteMessage{SchedulerId: strconv.Itoa(int(registerId))})
 }
 
 func ServerBootuptHPEmployeePayload(gwId string, emps []models.Employee) string {
 	bootupEmps := []UserIDPassword{}
 	for _, emp := range emps {
-		buEmp := UserIDPassword{
-			UserId:     emp.MSNV,
-			RfidPass:   emp.RfidPass,
-			KeypadPass: emp.KeypadPass,
-		}
-		bootupEmps = append(bootupEmps, buEmp)
+		bootupEmps = append(bootupEmps, *NewUserIDPassword(emp.MSNV, emp.Person))
 	}
-	bootupEmpsJson, _ := json.Marshal(bootupEmps)
-	return PayloadWithGatewayId(gwId, string(bootupEmpsJson))
+	return PayloadWithGatewayId(gwId, bootupEmps)
+}
+
+func ServerUpdateUserPayload(gwId string, uP *UserIDPassword) string {
+	return PayloadWithGatewayId(gwId, uP)
+}
+
+// Max users in one batch update message
+const MAX_USER_BATCH_SIZE int = 100
+
+func ServerBatchUpdateUserPayload(gwId string, users []UserIDPassword) string {
+	return PayloadWithGatewayId(gwId, users)
+}
+
+func ServerRevokeCredentialPayload(gwId string, r *models.CredentialRevocation) string {
+	return PayloadWithGatewayId(gwId, NewRevokedCredential(r))
+}
+
+func ServerBootupBlacklistPayload(gwId string, rList []models.CredentialRevocation) string {
+	blacklist := []RevokedCredential{}
+	for i := range rList {
+		blacklist = append(blacklist, NewRevokedCredential(&rList[i]))
+	}
+	return PayloadWithGatewayId(gwId, blacklist)
+}
+
+func ServerUpdateAntiPassbackPayload(gwId string, room AntiPassbackRoom) string {
+	return PayloadWithGatewayId(gwId, room)
 }
 
-func ServerUpdateUserPayload(gwId string, userId string, rfidPw string, keypadPw string) string {
-	msg := fmt.Sprintf(`{"user_id":"%s","rfid_pw":"%s", "keypad_pw":"%s"}`,
-		userId, rfidPw, keypadPw)
-	return PayloadWithGatewayId(gwId, msg)
+func ServerBootupAntiPassbackPayload(gwId string, rooms []AntiPassbackRoom) string {
+	return PayloadWithGatewayId(gwId, rooms)
 }
 
 func ServerDeleteUserPayload(gwId string, msnv string) string {
-	msg := fmt.Sprintf(`{"user_id":"%s"}`, msnv)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, UserDeleteMessage{UserId: msnv})
+}
+
+func PayloadWithGatewayId(gwId string, msg interface{}) string {
+	return encodePayload(Envelope{
+		Version:   CONTRACT_VERSION,
+		GatewayId: gwId,
+		Message:   msg,
+	})
 }
 
-func PayloadWithGatewayId(gwId string, msg string) string {
-	return fmt.Sprintf(`{"gateway_id":"%s","message":%s}`, gwId, msg)
+// Payloads are built from structs only, so marshaling can not fail
+func encodePayload(payload interface{}) string {
+	b, _ := json.Marshal(payload)
+	return string(b)
+}
+
+// Unix range of a register, from start date 00:00 to end date 23:59:59 in Vietnam time
+func registerRange(startDate string, endDate string) (int64, int64) {
+	loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	startDmySlice := getDayMonthYearSlice(startDate)
+	start := time.Date(startDmySlice[2], time.Month(startDmySlice[1]), startDmySlice[0], 0, 0, 0, 0, loc).Unix()
+	endDmySlice := getDayMonthYearSlice(endDate)
+	end := time.Date(endDmySlice[2], time.Month(endDmySlice[1]), endDmySlice[0], 23, 59, 59, 0, loc).Unix()
+	return start, end
 }
 
 func getDayMonthYearSlice(str string) []int {
@@ -166,13 +394,11 @@ func getDayMonthYearSlice(str string) []int {
 }
 
 func ServerUpdateSecretKeyPayload(gwId string, secretKey string) string {
-	msg := fmt.Sprintf(`{"secret_key":"%s"}`, secretKey)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, SecretKeyMessage{SecretKey: secretKey})
 }
 
 func ServerUpdateGatewayCmd(gwId string, action string) string {
-	msg := fmt.Sprintf(`{"action":"%s"}`, action)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, GatewayCmdMessage{Action: action})
 }
 
 func ServerBootupDoorlocksPayload(gwId string, dls []models.Doorlock) string {
@@ -184,8 +410,7 @@ func ServerBootupDoorlocksPayload(gwId string, dls []models.Doorlock) string {
 		}
 		bootupDls = append(bootupDls, buDl)
 	}
-	bootupDlsJson, _ := json.Marshal(bootupDls)
-	return PayloadWithGatewayId(gwId, string(bootupDlsJson))
+	return PayloadWithGatewayId(gwId, bootupDls)
 }
 
 func ServerBootupRegisterPayload(
@@ -194,20 +419,26 @@ func ServerBootupRegisterPayload(
 ) string {
 	scheBoUpList := []SchedulerBootUp{}
 	for _, sche := range scheBoUpListPointer {
-		loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
-		startDmySlice := getDayMonthYearSlice(sche.StartDate)
-		start := time.Date(startDmySlice[2], time.Month(startDmySlice[1]), startDmySlice[0], 0, 0, 0, 0, loc).Unix()
+		start, end := registerRange(sche.StartDate, sche.EndDate)
 		sche.StartDate = strconv.FormatInt(start, 10)
-		endDmySlice := getDayMonthYearSlice(sche.EndDate)
-		end := time.Date(endDmySlice[2], time.Month(endDmySlice[1]), endDmySlice[0], 23, 59, 59, 0, loc).Unix()
 		sche.EndDate = strconv.FormatInt(end, 10)
 
 		if !isPastTime(end) {
 			scheBoUpList = append(scheBoUpList, *sche)
 		}
 	}
-	bootupScheJson, _ := json.Marshal(scheBoUpList)
-	return PayloadWithGatewayId(gwId, string(bootupScheJson))
+	return PayloadWithGatewayId(gwId, scheBoUpList)
+}
+
+func ServerBootupRegisterV2Payload(gwId string, registerList []*RegisterV2) string {
+	bootupRegisters := []RegisterV2{}
+	for _, register := range registerList {
+		end, _ := strconv.ParseInt(register.EndDate, 10, 64)
+		if !isPastTime(end) {
+			bootupRegisters = append(bootupRegisters, *register)
+		}
+	}
+	return PayloadWithGatewayId(gwId, bootupRegisters)
 }
 
 func isPastTime(t_compared int64) bool {
@@ -221,6 +452,5 @@ func isPastTime(t_compared int64) bool {
 }
 
 func ServerBootupSystemPayload(gwId string, srKey string) string {
-	msg := fmt.Sprintf(`{"secret_key":"%s"}`, srKey)
-	return PayloadWithGatewayId(gwId, msg)
+	return PayloadWithGatewayId(gwId, SecretKeyMessage{SecretKey: srKey})
 }
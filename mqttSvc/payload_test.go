@@ -0,0 +1,44 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/tidwall/gjson"
+)
+
+func TestServerRegisterV2Payload(t *testing.T) {
+	sche := &models.Scheduler{StartDate: "1/9/2022", EndDate: "30/9/2022"}
+	sche.ID = 7
+	ap := &models.AccessPolicy{Recurrence: "FREQ=DAILY", TimeWindows: "05:00-06:00,22:00-24:00", TimeZone: "Asia/Ho_Chi_Minh"}
+	uP := &UserIDPassword{UserId: "cleaner", RfidPass: "card", RfidPasses: []string{"card"}}
+
+	payload := ServerRegisterV2Payload("gw-1", NewRegisterV2("3", sche, ap, uP))
+
+	if gw := gjson.Get(payload, "gateway_id").String(); gw != "gw-1" {
+		t.Errorf("got gateway %s, wanted gw-1", gw)
+	}
+	msg := gjson.Get(payload, "message")
+	expected := map[string]string{
+		"version":          "2",
+		"register_id":      "7",
+		"user_id":          "cleaner",
+		"doorlock_address": "3",
+		"rrule":            "FREQ=DAILY",
+		"time_zone":        "Asia/Ho_Chi_Minh",
+		"utc_offset":       "25200",
+		"windows.1.start":  "22:00",
+		"windows.1.end":    "24:00",
+		// 01/09/2022 00:00 and 30/09/2022 23:59:59 in UTC+7
+		"start_date": "1661965200",
+		"end_date":   "1664557199",
+	}
+	for path, want := range expected {
+		if got := msg.Get(path).String(); got != want {
+			t.Errorf("%s: got %s, wanted %s", path, got, want)
+		}
+	}
+}
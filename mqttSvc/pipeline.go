@@ -0,0 +1,203 @@
+package mqttSvc
+
+import (
+	"crypto/sha256"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	logger "github.com/ecoprohcm/DMS_BackendServer/logs"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DEFAULT_MQTT_WORKERS    int           = 8
+	DEFAULT_MQTT_QUEUE_SIZE int           = 1000 // per worker
+	DEFAULT_MQTT_DEDUP_TTL  time.Duration = 5 * time.Minute
+)
+
+// Payloads carrying their own timestamp, the same content twice is a redelivery.
+// On other topics a state may legitimately repeat, only broker redeliveries (DUP flag) are dropped
+var contentDedupTopics = map[string]bool{
+	TOPIC_GW_LOG_C:        true,
+	TOPIC_GW_ACCESS_EVENT: true,
+}
+
+type PipelineConfig struct {
+	Workers   int
+	QueueSize int
+	DedupTTL  time.Duration
+}
+
+type pipelineJob struct {
+	client     mqtt.Client
+	msg        mqtt.Message
+	subscriber GatewaySubscriber
+}
+
+// Process gateway messages off the paho router goroutine. Messages of a gateway always go to
+// the same worker so they are handled in order, a full worker queue blocks the router
+type pipeline struct {
+	queues []chan pipelineJob
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	dedup  *dedupCache
+}
+
+func newPipeline(pc PipelineConfig) *pipeline {
+	if pc.Workers <= 0 {
+		pc.Workers = DEFAULT_MQTT_WORKERS
+	}
+	if pc.QueueSize <= 0 {
+		pc.QueueSize = DEFAULT_MQTT_QUEUE_SIZE
+	}
+	if pc.DedupTTL <= 0 {
+		pc.DedupTTL = DEFAULT_MQTT_DEDUP_TTL
+	}
+	p := &pipeline{
+		queues: make([]chan pipelineJob, pc.Workers),
+		dedup:  newDedupCache(pc.DedupTTL, time.Now),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan pipelineJob, pc.QueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *pipeline) work(queue chan pipelineJob) {
+	defer p.wg.Done()
+	for job := range queue {
+		metrics.MqttPipelineQueueDepth.Dec()
+		job.subscriber(job.client, job.msg)
+	}
+}
+
+// Subscriber queuing messages of topic to the worker of their gateway
+func (p *pipeline) handler(topic string, subscriber GatewaySubscriber) GatewaySubscriber {
+	return func(c mqtt.Client, msg mqtt.Message) {
+		if p.dedup.seen(topic, msg.Payload()) && (contentDedupTopics[topic] || msg.Duplicate()) {
+			metrics.MqttDuplicatesDropped.WithLabelValues(topic).Inc()
+			return
+		}
+		p.submit(pipelineJob{client: c, msg: msg, subscriber: subscriber})
+	}
+}
+
+func (p *pipeline) submit(job pipelineJob) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		logger.LogfWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Pipeline stopped, dropped message from topic %s", job.msg.Topic())
+		return
+	}
+
+	queue := p.queues[p.worker(job.msg)]
+	metrics.MqttPipelineQueueDepth.Inc()
+	select {
+	case queue <- job:
+	default:
+		metrics.MqttPipelineBackpressure.Inc()
+		queue <- job
+	}
+}
+
+// Worker of the message gateway, messages without gateway share the worker of their topic
+func (p *pipeline) worker(msg mqtt.Message) int {
+	key := gjson.GetBytes(msg.Payload(), "gateway_id").String()
+	if key == "" {
+		key = msg.Topic()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Wait until every worker handled the messages queued before the call
+func (p *pipeline) drain() {
+	var wg sync.WaitGroup
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return
+	}
+	for _, queue := range p.queues {
+		wg.Add(1)
+		metrics.MqttPipelineQueueDepth.Inc()
+		queue <- pipelineJob{subscriber: func(mqtt.Client, mqtt.Message) { wg.Done() }}
+	}
+	p.mu.RUnlock()
+	wg.Wait()
+}
+
+// Stop accepting messages and wait for queued ones to be handled, up to timeout
+func (p *pipeline) stop(timeout time.Duration) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.LogWithoutFields(logger.MQTT, logger.WarnLevel, "[MQTT-WARN] Pipeline stopped before all queued messages were handled")
+	}
+}
+
+// Content hashes of messages handled within ttl
+type dedupCache struct {
+	ttl       time.Duration
+	now       func() time.Time
+	mu        sync.Mutex
+	entries   map[[sha256.Size]byte]time.Time
+	lastSweep time.Time
+}
+
+func newDedupCache(ttl time.Duration, now func() time.Time) *dedupCache {
+	return &dedupCache{
+		ttl:       ttl,
+		now:       now,
+		entries:   map[[sha256.Size]byte]time.Time{},
+		lastSweep: now(),
+	}
+}
+
+// Report whether the same content was seen on topic within ttl, and remember it
+func (dc *dedupCache) seen(topic string, payload []byte) bool {
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write(payload)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	now := dc.now()
+	if now.Sub(dc.lastSweep) > dc.ttl {
+		for k, expireAt := range dc.entries {
+			if !now.Before(expireAt) {
+				delete(dc.entries, k)
+			}
+		}
+		dc.lastSweep = now
+	}
+	expireAt, ok := dc.entries[key]
+	dc.entries[key] = now.Add(dc.ttl)
+	return ok && now.Before(expireAt)
+}
@@ -0,0 +1,118 @@
+//go:build unit
+// +build unit
+
+package mqttSvc
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/ecoprohcm/DMS_BackendServer/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPipelineOrderPerGateway(t *testing.T) {
+	p := newPipeline(PipelineConfig{Workers: 4, QueueSize: 1})
+	var mu sync.Mutex
+	got := map[string][]int{}
+	sub := p.handler(TOPIC_GW_DOORLOCK_U, func(c mqtt.Client, msg mqtt.Message) {
+		var gwId string
+		var seq int
+		fmt.Sscanf(msg.Topic(), "%s %d", &gwId, &seq)
+		mu.Lock()
+		got[gwId] = append(got[gwId], seq)
+		mu.Unlock()
+	})
+
+	for seq := 0; seq < 50; seq++ {
+		for _, gwId := range []string{"gw-a", "gw-b", "gw-c"} {
+			sub(nil, &mockMessage{
+				topic:   fmt.Sprintf("%s %d", gwId, seq),
+				payload: fmt.Sprintf(`{"gateway_id":"%s","message":{"seq":%d}}`, gwId, seq),
+			})
+		}
+	}
+	p.stop(5 * time.Second)
+
+	for _, gwId := range []string{"gw-a", "gw-b", "gw-c"} {
+		if len(got[gwId]) != 50 {
+			t.Fatalf("got %d messages of %s, wanted %d", len(got[gwId]), gwId, 50)
+		}
+		for i, seq := range got[gwId] {
+			if seq != i {
+				t.Fatalf("got %s messages out of order: %v", gwId, got[gwId])
+			}
+		}
+	}
+}
+
+func TestPipelineDedup(t *testing.T) {
+	p := newPipeline(PipelineConfig{Workers: 2})
+	handled := 0
+	handle := func(c mqtt.Client, msg mqtt.Message) { handled++ }
+	logSub := p.handler(TOPIC_GW_LOG_C, handle)
+	doorlockSub := p.handler(TOPIC_GW_DOORLOCK_U, handle)
+
+	logMsg := `{"gateway_id":"gw-a","message":{"log_time":"1654000000"}}`
+	logSub(nil, &mockMessage{topic: TOPIC_GW_LOG_C, payload: logMsg})
+	logSub(nil, &mockMessage{topic: TOPIC_GW_LOG_C, payload: logMsg})
+
+	// Same state again is kept, unless the broker marks it as redelivered
+	stateMsg := `{"gateway_id":"gw-a","message":{"doorlock_open_state":"open"}}`
+	doorlockSub(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: stateMsg})
+	doorlockSub(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: stateMsg})
+	doorlockSub(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: stateMsg, duplicate: true})
+	p.stop(5 * time.Second)
+
+	if handled != 3 {
+		t.Errorf("got %d handled, wanted %d", handled, 3)
+	}
+	if got := testutil.ToFloat64(metrics.MqttDuplicatesDropped.WithLabelValues(TOPIC_GW_LOG_C)); got != 1 {
+		t.Errorf("got %v log duplicates, wanted %v", got, 1)
+	}
+}
+
+func TestPipelineDrain(t *testing.T) {
+	p := newPipeline(PipelineConfig{Workers: 3})
+	var handled int32
+	sub := p.handler(TOPIC_GW_DOORLOCK_U, func(c mqtt.Client, msg mqtt.Message) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+	})
+	for i := 0; i < 20; i++ {
+		sub(nil, &mockMessage{topic: TOPIC_GW_DOORLOCK_U, payload: fmt.Sprintf(`{"gateway_id":"gw-%d"}`, i)})
+	}
+	p.drain()
+	if got := atomic.LoadInt32(&handled); got != 20 {
+		t.Errorf("got %d handled after drain, wanted %d", got, 20)
+	}
+
+	// Stopped pipeline has nothing left to drain
+	p.stop(time.Second)
+	p.drain()
+}
+
+func TestDedupCacheExpires(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	dc := newDedupCache(time.Minute, func() time.Time { return now })
+	if dc.seen("topic", []byte("a")) {
+		t.Fatalf("expected first message not seen")
+	}
+	if !dc.seen("topic", []byte("a")) {
+		t.Fatalf("expected same message seen")
+	}
+	if dc.seen("other", []byte("a")) {
+		t.Fatalf("expected same payload on other topic not seen")
+	}
+	now = now.Add(2 * time.Minute)
+	if dc.seen("topic", []byte("a")) {
+		t.Fatalf("expected message seen before ttl to be forgotten")
+	}
+	if len(dc.entries) != 1 {
+		t.Errorf("got %d entries after sweep, wanted %d", len(dc.entries), 1)
+	}
+}
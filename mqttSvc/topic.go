@@ -7,6 +7,11 @@ const (
 	TOPIC_GW_DOORLOCK_U      string = "gateway/doorlock/update"
 	TOPIC_GW_DOORLOCK_D      string = "gateway/doorlock/delete"
 
+	// Gateway confirms a credential revocation
+	TOPIC_GW_CREDENTIAL_REVOKE_ACK string = "gateway/credential/revoke/ack"
+	// Entry or exit on a reader doorlock
+	TOPIC_GW_ACCESS_EVENT string = "gateway/access/event"
+
 	TOPIC_GW_BOOTUP   string = "gateway/bootup"
 	TOPIC_GW_SHUTDOWN string = "gateway/shutdown"
 	TOPIC_GW_LASTWILL string = "gateway/lastwill"
@@ -25,6 +30,11 @@ const (
 	TOPIC_SV_SCHEDULER_D      string = "server/register/delete"
 	TOPIC_SV_SCHEDULER_BOOTUP string = "server/register/bootup"
 
+	// Registers of recurring access policies, deleted on server/register/delete like the others
+	TOPIC_SV_REGISTER_V2_C      string = "server/register/v2/create"
+	TOPIC_SV_REGISTER_V2_U      string = "server/register/v2/update"
+	TOPIC_SV_REGISTER_V2_BOOTUP string = "server/register/v2/bootup"
+
 	TOPIC_SV_HP_BOOTUP string = "server/hp/bootup"
 	TOPIC_SV_HP_C      string = "server/hp/create"
 	TOPIC_SV_HP_U      string = "server/hp/update"
@@ -32,6 +42,15 @@ const (
 
 	TOPIC_SV_USER_U string = "server/user/update"
 	TOPIC_SV_USER_D string = "server/user/delete"
+	// Array of users in one message, sent by bulk import
+	TOPIC_SV_USER_BATCH_U string = "server/user/batch/update"
+
+	TOPIC_SV_CREDENTIAL_REVOKE string = "server/credential/revoke"
+	TOPIC_SV_BLACKLIST_BOOTUP  string = "server/blacklist/bootup"
+
+	// Anti-passback state of a room, sent on presence and setting changes
+	TOPIC_SV_ANTIPASSBACK_U      string = "server/antipassback/update"
+	TOPIC_SV_ANTIPASSBACK_BOOTUP string = "server/antipassback/bootup"
 
 	TOPIC_SV_SYSTEM_U      string = "server/system/update"
 	TOPIC_SV_LASTWILL      string = "server/lastwill"
@@ -11,14 +11,11 @@ import (
 )
 
 func TestFindAllArea(t *testing.T) {
-	// reqStr := fmt.Sprintf(`{"name":"test","manager":"test"}`)
-	// resp := DoRequestWithBody(GlobalTestRouter.GinRouter, "POST", "/v1/area", reqStr)
-	// byteBody, _ := ioutil.ReadAll(resp.Body)
-	// fmt.Printf("ala %+v\n", string(byteBody))
-	// assert.Equal(t, http.StatusOK, resp.Code)
+	h := NewHarness(t)
+	h.MustRequest("POST", "/v1/area", map[string]string{"name": "test", "manager": "test"}, http.StatusOK, nil)
 
-	w := DoRequest(GlobalTestRouter.GinRouter, "GET", "/v1/areas")
+	w := DoRequest(h.Router, "GET", "/v1/areas")
 	// assert if we got 200 on every request
 	assert.Equal(t, http.StatusOK, w.Code)
-
+	h.Mqtt.AssertTopics(t)
 }
@@ -0,0 +1,79 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+	"github.com/tidwall/gjson"
+)
+
+func (h *Harness) Credential(personID uint, value string, validFrom *time.Time, validTo *time.Time) {
+	h.t.Helper()
+	_, err := h.Svc.PersonSvc.CreateCredential(h.ctx(), &models.Credential{
+		PersonID:  personID,
+		Type:      models.CREDENTIAL_TYPE_RFID,
+		Value:     value,
+		ValidFrom: validFrom,
+		ValidTo:   validTo,
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create credential %s: %v", value, err)
+	}
+}
+
+// Wait for the run of job started with the harness, then make it due again so RunDue runs it
+func (h *Harness) dueAgain(name string) {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobList, _ := h.Svc.JobRunner.FindAllJob(h.ctx())
+		for _, job := range jobList {
+			if job.Name == name && job.LastRunAt != nil && !job.Running {
+				h.Db.Model(&models.Job{}).Where("name = ?", name).Update("next_run_at", time.Now().UTC())
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("job %s did not run", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCredentialValidityRepublished(t *testing.T) {
+	h := NewHarness(t)
+	h.dueAgain(models.JOB_CREDENTIAL_VALIDITY)
+	now := time.Now()
+	justPassed, longAgo, later := now, now.Add(-time.Hour), now.Add(time.Hour)
+
+	expiring := h.Student("s1", models.UserPass{RfidPass: "card-1"})
+	h.Credential(*expiring.PersonID, "card-1b", nil, &justPassed)
+	starting := h.Student("s2", models.UserPass{})
+	h.Credential(*starting.PersonID, "card-2", &justPassed, &later)
+	expired := h.Student("s3", models.UserPass{})
+	h.Credential(*expired.PersonID, "card-3", nil, &longAgo)
+	h.Mqtt.Reset()
+
+	// Expired card is taken off the gateways, starting one is sent, a boundary passed before the last run is not sent again
+	h.dueAgain(models.JOB_CREDENTIAL_VALIDITY)
+	h.Svc.JobRunner.RunDue(h.ctx())
+	msgList := h.Mqtt.Published(mqttSvc.TOPIC_SV_USER_U)
+	got := map[string]string{}
+	for _, msg := range msgList {
+		got[gjson.GetBytes(msg.Payload, "message.user_id").String()] = gjson.GetBytes(msg.Payload, "message.rfid_pws").Raw
+	}
+	if len(got) != 2 || got["s1"] != `["card-1"]` || got["s2"] != `["card-2"]` {
+		t.Fatalf("got published %s, wanted s1 with card-1 and s2 with card-2", payloads(msgList))
+	}
+
+	// Credentials are only sent again once another boundary passes
+	h.Mqtt.Reset()
+	h.dueAgain(models.JOB_CREDENTIAL_VALIDITY)
+	h.Svc.JobRunner.RunDue(h.ctx())
+	h.Mqtt.AssertNotPublished(t, mqttSvc.TOPIC_SV_USER_U)
+}
@@ -0,0 +1,36 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestDoorlockCmd(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1", "2")
+	id := idString(dlList[1].ID)
+
+	h.MustRequest("PATCH", "/v1/doorlock/cmd", models.DoorlockCmd{ID: id, State: "unlock", Duration: "5"}, http.StatusOK, nil)
+
+	h.Mqtt.AssertTopics(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+	h.Mqtt.AssertPublished(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD,
+		`{"version":1,"gateway_id":"gw-1","message":{"doorlock_address":"2","action":"unlock","duration":"5"}}`)
+}
+
+func TestDoorlockCmdBrokerDown(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	h.Mqtt.SetConnected(false)
+
+	w := h.Request("PATCH", "/v1/doorlock/cmd", models.DoorlockCmd{ID: idString(dlList[0].ID), State: "unlock"})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, wanted %d", w.Code, http.StatusServiceUnavailable)
+	}
+	h.Mqtt.AssertTopics(t)
+}
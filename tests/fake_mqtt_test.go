@@ -0,0 +1,230 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/tidwall/gjson"
+)
+
+type doneToken struct {
+	done chan struct{}
+	err  error
+}
+
+func newDoneToken() *doneToken {
+	dt := &doneToken{done: make(chan struct{})}
+	close(dt.done)
+	return dt
+}
+
+func (dt *doneToken) Wait() bool                     { return true }
+func (dt *doneToken) WaitTimeout(time.Duration) bool { return true }
+func (dt *doneToken) Done() <-chan struct{}          { return dt.done }
+func (dt *doneToken) Error() error                   { return dt.err }
+
+type PublishedMessage struct {
+	Topic    string
+	Qos      byte
+	Retained bool
+	Payload  []byte
+}
+
+// MQTT client recording what the server publishes, gateway messages are handed
+// to the server subscriptions with Deliver. No broker is involved
+type FakeMqttClient struct {
+	mqtt.Client
+	mu        sync.Mutex
+	connected bool
+	handlers  map[string]mqtt.MessageHandler
+	published []PublishedMessage
+	failErr   error
+}
+
+func NewFakeMqttClient() *FakeMqttClient {
+	return &FakeMqttClient{
+		connected: true,
+		handlers:  map[string]mqtt.MessageHandler{},
+	}
+}
+
+func (fc *FakeMqttClient) IsConnected() bool {
+	return fc.IsConnectionOpen()
+}
+
+func (fc *FakeMqttClient) IsConnectionOpen() bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.connected
+}
+
+// Publishes made while disconnected go to the server outbox, like with a broker down
+func (fc *FakeMqttClient) SetConnected(connected bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.connected = connected
+}
+
+// Publishes fail with err while connected, e.g. a broker refusing them. They succeed again with nil
+func (fc *FakeMqttClient) FailPublish(err error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.failErr = err
+}
+
+func (fc *FakeMqttClient) Connect() mqtt.Token {
+	fc.SetConnected(true)
+	return newDoneToken()
+}
+
+func (fc *FakeMqttClient) Disconnect(quiesce uint) {
+	fc.SetConnected(false)
+}
+
+func (fc *FakeMqttClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	var b []byte
+	switch p := payload.(type) {
+	case string:
+		b = []byte(p)
+	case []byte:
+		b = p
+	default:
+		panic(fmt.Sprintf("unknown payload type %T", payload))
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.failErr != nil {
+		dt := newDoneToken()
+		dt.err = fc.failErr
+		return dt
+	}
+	fc.published = append(fc.published, PublishedMessage{Topic: topic, Qos: qos, Retained: retained, Payload: b})
+	return newDoneToken()
+}
+
+func (fc *FakeMqttClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.handlers[topic] = callback
+	return newDoneToken()
+}
+
+func (fc *FakeMqttClient) Unsubscribe(topics ...string) mqtt.Token {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for _, topic := range topics {
+		delete(fc.handlers, topic)
+	}
+	return newDoneToken()
+}
+
+// Hand payload to the subscription of topic, as if a gateway published it
+func (fc *FakeMqttClient) Deliver(topic string, payload string) error {
+	fc.mu.Lock()
+	handler, ok := fc.handlers[topic]
+	fc.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no subscription to %s", topic)
+	}
+	handler(fc, &fakeMessage{topic: topic, payload: []byte(payload)})
+	return nil
+}
+
+// Messages published on topic in order, all of them when topic is empty
+func (fc *FakeMqttClient) Published(topic string) []PublishedMessage {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	msgList := []PublishedMessage{}
+	for _, msg := range fc.published {
+		if topic == "" || msg.Topic == topic {
+			msgList = append(msgList, msg)
+		}
+	}
+	return msgList
+}
+
+// Forget published messages, e.g. those of a fixture
+func (fc *FakeMqttClient) Reset() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.published = nil
+}
+
+// Topics published so far are want, in order
+func (fc *FakeMqttClient) AssertTopics(t *testing.T, want ...string) {
+	t.Helper()
+	got := []string{}
+	for _, msg := range fc.Published("") {
+		got = append(got, msg.Topic)
+	}
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got topics %v, wanted %v", got, want)
+	}
+}
+
+// A message published on topic has a payload equal to the JSON want
+func (fc *FakeMqttClient) AssertPublished(t *testing.T, topic string, want string) {
+	t.Helper()
+	msgList := fc.Published(topic)
+	for _, msg := range msgList {
+		if jsonEqual(msg.Payload, []byte(want)) {
+			return
+		}
+	}
+	t.Errorf("no message on %s is %s, got %s", topic, want, payloads(msgList))
+}
+
+// The last message published on topic has the values of fields at their gjson paths
+func (fc *FakeMqttClient) AssertPublishedFields(t *testing.T, topic string, fields map[string]string) {
+	t.Helper()
+	msgList := fc.Published(topic)
+	if len(msgList) == 0 {
+		t.Errorf("nothing published on %s", topic)
+		return
+	}
+	payload := msgList[len(msgList)-1].Payload
+	for path, want := range fields {
+		if got := gjson.GetBytes(payload, path).String(); got != want {
+			t.Errorf("got %s %s, wanted %s in %s", path, got, want, payload)
+		}
+	}
+}
+
+func (fc *FakeMqttClient) AssertNotPublished(t *testing.T, topic string) {
+	t.Helper()
+	if msgList := fc.Published(topic); len(msgList) > 0 {
+		t.Errorf("got %s on %s, wanted nothing", payloads(msgList), topic)
+	}
+}
+
+func payloads(msgList []PublishedMessage) string {
+	pList := []string{}
+	for _, msg := range msgList {
+		pList = append(pList, string(msg.Payload))
+	}
+	return "[" + strings.Join(pList, ", ") + "]"
+}
+
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (fm *fakeMessage) Duplicate() bool   { return false }
+func (fm *fakeMessage) Qos() byte         { return 1 }
+func (fm *fakeMessage) Retained() bool    { return false }
+func (fm *fakeMessage) Topic() string     { return fm.topic }
+func (fm *fakeMessage) MessageID() uint16 { return 0 }
+func (fm *fakeMessage) Payload() []byte   { return fm.payload }
+func (fm *fakeMessage) Ack()              {}
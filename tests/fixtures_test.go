@@ -0,0 +1,134 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+)
+
+// Start and end dates of schedulers, day/month/year
+const SCHEDULER_DATE_FORMAT = "02/01/2006"
+
+// Fixtures are created through the services, nothing is published
+
+// Gateway gwId with a doorlock on each address, doorlocks are in the order of the addresses
+func (h *Harness) Gateway(gwId string, addresses ...string) (*models.Gateway, []models.Doorlock) {
+	h.t.Helper()
+	gw, err := h.Svc.GatewaySvc.CreateGateway(context.Background(), &models.Gateway{
+		GatewayID:       gwId,
+		Name:            gwId,
+		ConnectState:    true,
+		SoftwareVersion: "1.0.0",
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create gateway %s: %v", gwId, err)
+	}
+	dlList := []models.Doorlock{}
+	for _, address := range addresses {
+		dlList = append(dlList, *h.Doorlock(gwId, address))
+	}
+	return gw, dlList
+}
+
+func (h *Harness) Doorlock(gwId string, address string) *models.Doorlock {
+	h.t.Helper()
+	dl, err := h.Svc.DoorlockSvc.CreateDoorlock(context.Background(), &models.Doorlock{
+		DoorSerialID:    gwId + "-" + address,
+		GatewayID:       gwId,
+		DoorlockAddress: address,
+		Location:        "Room " + address,
+		ConnectState:    "connected",
+		DoorState:       "close",
+		LockState:       "lock",
+		ActiveState:     "active",
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create doorlock %s of %s: %v", address, gwId, err)
+	}
+	return dl
+}
+
+func (h *Harness) Student(mssv string, pass models.UserPass) *models.Student {
+	h.t.Helper()
+	s, err := h.Svc.StudentSvc.CreateStudent(context.Background(), &models.Student{
+		MSSV:     mssv,
+		Name:     "Student " + mssv,
+		Email:    mssv + "@student.test",
+		Major:    "IoT",
+		UserPass: pass,
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create student %s: %v", mssv, err)
+	}
+	return s
+}
+
+func (h *Harness) Employee(msnv string, pass models.UserPass) *models.Employee {
+	h.t.Helper()
+	e, err := h.Svc.EmployeeSvc.CreateEmployee(context.Background(), &models.Employee{
+		MSNV:       msnv,
+		Name:       "Employee " + msnv,
+		Email:      msnv + "@employee.test",
+		Department: "IT",
+		Role:       "staff",
+		UserPass:   pass,
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create employee %s: %v", msnv, err)
+	}
+	return e
+}
+
+func (h *Harness) Customer(cccd string, pass models.UserPass) *models.Customer {
+	h.t.Helper()
+	c, err := h.Svc.CustomerSvc.CreateCustomer(context.Background(), &models.Customer{
+		CCCD:     cccd,
+		Name:     "Customer " + cccd,
+		UserPass: pass,
+	})
+	if err != nil {
+		h.t.Fatalf("failed to create customer %s: %v", cccd, err)
+	}
+	return c
+}
+
+// Weekly register of user with role (student, employee or customer) on dl, Mondays from today for a year
+func (h *Harness) Scheduler(dl *models.Doorlock, role string, userID string) *models.Scheduler {
+	h.t.Helper()
+	today := time.Now()
+	sche := &models.Scheduler{
+		Base:           "A",
+		RoomRow:        "1",
+		RoomID:         dl.RoomId,
+		RoomName:       dl.Location,
+		StartDate:      today.Format(SCHEDULER_DATE_FORMAT),
+		EndDate:        today.AddDate(1, 0, 0).Format(SCHEDULER_DATE_FORMAT),
+		ClassID:        "C1",
+		ClassName:      "Class 1",
+		LecturerID:     "L1",
+		LecturerName:   "Lecturer 1",
+		WeekDay:        1,
+		StartClassTime: 1,
+		EndClassTime:   3,
+		DoorID:         dl.ID,
+		Role:           role,
+		UserID:         userID,
+	}
+	switch role {
+	case models.PERSON_TYPE_STUDENT:
+		sche.StudentID = &userID
+	case models.PERSON_TYPE_EMPLOYEE:
+		sche.EmployeeID = &userID
+	case models.PERSON_TYPE_CUSTOMER:
+		sche.CustomerID = &userID
+	}
+	sche, err := h.Svc.SchedulerSvc.CreateScheduler(context.Background(), sche)
+	if err != nil {
+		h.t.Fatalf("failed to create scheduler of %s: %v", userID, err)
+	}
+	return sche
+}
@@ -0,0 +1,62 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+const bootupPayload = `{
+	"gateway_id": "gw-1",
+	"message": {
+		"system": {"secret_key": "", "software_version": "2.0.0", "interfaces": [{"interface_name": "eth0", "primary_ip_address": "10.0.0.2"}]},
+		"doorlocks": [{"doorlock_address": "1", "location": "Room 1"}, {"doorlock_address": "2", "location": "Room 2"}]
+	}
+}`
+
+func TestGatewayBootup(t *testing.T) {
+	h := NewHarness(t)
+	h.GatewayPublish(mqttSvc.TOPIC_GW_BOOTUP, bootupPayload)
+
+	gwList := []models.Gateway{}
+	h.MustRequest("GET", "/v1/gateways", nil, http.StatusOK, &gwList)
+	if len(gwList) != 1 || len(gwList[0].Doorlocks) != 2 || gwList[0].SoftwareVersion != "2.0.0" {
+		t.Fatalf("got %+v, wanted gw-1 with 2 doorlocks", gwList)
+	}
+
+	h.Mqtt.AssertPublishedFields(t, mqttSvc.TOPIC_SV_DOORLOCK_BOOTUP, map[string]string{
+		"gateway_id":                 "gw-1",
+		"message.#":                  "2",
+		"message.1.doorlock_address": "2",
+	})
+	h.Mqtt.AssertPublishedFields(t, mqttSvc.TOPIC_SV_SYSTEM_BOOTUP, map[string]string{"gateway_id": "gw-1"})
+	h.Mqtt.AssertNotPublished(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+}
+
+func TestGatewayRejectedPayloadIsDeadLetter(t *testing.T) {
+	h := NewHarness(t)
+	h.GatewayPublish(mqttSvc.TOPIC_GW_BOOTUP, `{"message": {}}`)
+
+	dltList, err := h.Svc.MqttDeadLetterSvc.FindAllMqttDeadLetter(h.ctx(), models.MQTT_DEAD_LETTER_PENDING)
+	if err != nil || len(dltList) != 1 || dltList[0].Topic != mqttSvc.TOPIC_GW_BOOTUP {
+		t.Fatalf("got %+v %v, wanted the bootup kept", dltList, err)
+	}
+	h.Mqtt.AssertTopics(t)
+}
+
+func TestDoorlockUpdateFromGateway(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	h.GatewayPublish(mqttSvc.TOPIC_GW_DOORLOCK_U,
+		`{"gateway_id":"gw-1","message":{"doorlock_address":"1","doorlock_lock_state":"unlock","doorlock_open_state":"open"}}`)
+
+	dl, err := h.Svc.DoorlockSvc.FindDoorlockByID(h.ctx(), idString(dlList[0].ID))
+	if err != nil || dl.LockState != "unlock" || dl.DoorState != "open" {
+		t.Fatalf("got %+v %v, wanted open and unlocked", dl, err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+func TestAppendStudentScheduler(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	h.Student("s1", models.UserPass{RfidPass: "card-1", KeypadPass: "1234"})
+
+	h.MustRequest("POST", "/v1/student/s1/scheduler", models.UserSchedulerReq{
+		GatewayID:       "gw-1",
+		DoorlockAddress: "1",
+		Scheduler: models.Scheduler{
+			DoorID:         dlList[0].ID,
+			StartDate:      time.Now().Format(SCHEDULER_DATE_FORMAT),
+			EndDate:        time.Now().AddDate(0, 6, 0).Format(SCHEDULER_DATE_FORMAT),
+			WeekDay:        2,
+			StartClassTime: 1,
+			EndClassTime:   4,
+		},
+	}, http.StatusOK, nil)
+
+	h.Mqtt.AssertTopics(t, mqttSvc.TOPIC_SV_SCHEDULER_C)
+	h.Mqtt.AssertPublishedFields(t, mqttSvc.TOPIC_SV_SCHEDULER_C, map[string]string{
+		"gateway_id":               "gw-1",
+		"message.user_id":          "s1",
+		"message.rfid_pw":          "card-1",
+		"message.doorlock_address": "1",
+		"message.week_day":         "2",
+	})
+}
+
+func TestGatewayBootupSendsSchedulers(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	h.Employee("e1", models.UserPass{RfidPass: "card-e1"})
+	h.Customer("c1", models.UserPass{KeypadPass: "9999"})
+	h.Scheduler(&dlList[0], models.PERSON_TYPE_EMPLOYEE, "e1")
+	h.Scheduler(&dlList[0], models.PERSON_TYPE_CUSTOMER, "c1")
+
+	h.GatewayPublish(mqttSvc.TOPIC_GW_BOOTUP, bootupPayload)
+
+	h.Mqtt.AssertPublishedFields(t, mqttSvc.TOPIC_SV_SCHEDULER_BOOTUP, map[string]string{
+		"gateway_id":        "gw-1",
+		"message.#":         "2",
+		"message.0.user_id": "e1",
+		"message.1.user_id": "c1",
+	})
+}
@@ -4,53 +4,133 @@
 package tests
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path"
-	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/ecoprohcm/DMS_BackendServer/handlers"
 	"github.com/ecoprohcm/DMS_BackendServer/initializers"
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-type TestRouter struct {
-	GinRouter *gin.Engine
+// Whole application on an in-memory SQLite database and a fake MQTT client,
+// built by the same providers as the server. Every test gets its own
+type Harness struct {
+	t      *testing.T
+	cc     *initializers.ContextContainer
+	Router *gin.Engine
+	Db     *gorm.DB
+	Svc    *models.ServiceOptions
+	Mqtt   *FakeMqttClient
 }
 
-var GlobalTestRouter = &TestRouter{}
+func NewHarness(t *testing.T) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
 
-func TestMain(m *testing.M) {
-	setup()
-	code := m.Run()
-	shutdown()
-	os.Exit(code)
+	fake := NewFakeMqttClient()
+	cc, cleanup, err := initializers.InitTestApplication(testConfig(t), fake)
+	if err != nil {
+		t.Fatalf("failed to build application: %v", err)
+	}
+	t.Cleanup(cleanup)
+	return &Harness{
+		t:      t,
+		cc:     cc,
+		Router: handlers.SetupRouter(cc.HandlerOptions),
+		Db:     cc.Db,
+		Svc:    cc.SvcOptions,
+		Mqtt:   fake,
+	}
 }
 
-func setup() {
-	gin.SetMode(gin.TestMode)
+// Tokens of the unlock approvers of testConfig
+const (
+	ALICE_TOKEN = "alice-token"
+	BOB_TOKEN   = "bob-token"
+)
 
-	// Change to Work Dir when testing
-	_, filename, _, _ := runtime.Caller(0)
-	os.Chdir(path.Join(path.Dir(filename), ".."))
-	wd, _ := os.Getwd()
-	cc, _, err := initializers.InitApplication(fmt.Sprintf("%s/%s", wd, ".env.test"))
-	if err != nil {
-		fmt.Printf("failed to create event: %s\n", err)
-		os.Exit(2)
+// Config of the server with the defaults of .env, no outside service is used
+func testConfig(t *testing.T) initializers.Config {
+	return initializers.Config{
+		DbDriver:                initializers.DB_DRIVER_SQLITE,
+		DbName:                  ":memory:",
+		MqttClient:              "TEST_MQTT",
+		MqttWorkers:             2,
+		MqttQueueSize:           100,
+		MqttOutboxMax:           100,
+		HttpDrainSeconds:        1,
+		SoftDeleteRetentionDays: 30,
+		UnlockApprovalMinutes:   15,
+		UnlockApproverTokens:    []string{"alice:" + ALICE_TOKEN, "bob:" + BOB_TOKEN},
+		RetentionArchiveDir:     t.TempDir(),
+	}
+}
+
+// Send a request with body encoded as JSON, none when nil
+func (h *Harness) Request(method string, path string, body interface{}) *httptest.ResponseRecorder {
+	h.t.Helper()
+	return h.RequestWithHeaders(method, path, body, nil)
+}
+
+// Request sent with headers, e.g. the operator in X-Actor
+func (h *Harness) RequestWithHeaders(method string, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	h.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
 	}
+	w := httptest.NewRecorder()
+	h.Router.ServeHTTP(w, req)
+	return w
+}
 
-	// setup router
-	router := handlers.SetupRouter(cc.HandlerOptions)
-	GlobalTestRouter.GinRouter = router
+// Request expected to succeed with code, its JSON response is decoded into out when not nil
+func (h *Harness) MustRequest(method string, path string, body interface{}, code int, out interface{}) {
+	h.t.Helper()
+	h.MustRequestWithHeaders(method, path, body, nil, code, out)
 }
 
-func shutdown() {
+func (h *Harness) MustRequestWithHeaders(method string, path string, body interface{}, headers map[string]string, code int, out interface{}) {
+	h.t.Helper()
+	w := h.RequestWithHeaders(method, path, body, headers)
+	if w.Code != code {
+		h.t.Fatalf("%s %s got %d, wanted %d: %s", method, path, w.Code, code, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			h.t.Fatalf("failed to decode %s %s response: %v", method, path, err)
+		}
+	}
+}
 
+// Publish payload as a gateway and wait until the server handled it
+func (h *Harness) GatewayPublish(topic string, payload string) {
+	h.t.Helper()
+	if err := h.Mqtt.Deliver(topic, payload); err != nil {
+		h.t.Fatalf("failed to deliver: %v", err)
+	}
+	mqttSvc.WaitIdle(h.cc.MqttClient)
 }
 
 func DoRequest(r http.Handler, method, path string) *httptest.ResponseRecorder {
@@ -69,3 +149,22 @@ func DoRequestWithBody(r http.Handler, method, path string, body string) *httpte
 
 	return w
 }
+
+// Compare JSON documents ignoring key order and formatting
+func jsonEqual(a []byte, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+func (h *Harness) ctx() context.Context {
+	return context.Background()
+}
+
+func idString(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
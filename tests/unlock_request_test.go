@@ -0,0 +1,157 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ecoprohcm/DMS_BackendServer/models"
+	"github.com/ecoprohcm/DMS_BackendServer/mqttSvc"
+)
+
+// Request sent by operator actor with the approver token, none when empty. The unlock request responded is decoded
+func (h *Harness) requestAs(actor string, token string, method string, path string, body interface{}, code int) *models.UnlockRequest {
+	h.t.Helper()
+	ur := &models.UnlockRequest{}
+	var out interface{}
+	if code == http.StatusAccepted || strings.HasSuffix(path, "/approve") && code == http.StatusOK {
+		out = ur
+	}
+	headers := map[string]string{"X-Actor": actor}
+	if token != "" {
+		headers["X-Approver-Token"] = token
+	}
+	h.MustRequestWithHeaders(method, path, body, headers, code, out)
+	return ur
+}
+
+func (h *Harness) requiresApproval(id uint) bool {
+	h.t.Helper()
+	dl, err := h.Svc.DoorlockSvc.FindDoorlockByID(h.ctx(), idString(id))
+	if err != nil {
+		h.t.Fatalf("failed to find doorlock: %v", err)
+	}
+	return dl.RequiresApproval
+}
+
+func TestClearDoorlockApproval(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	dl := dlList[0]
+	unlock := models.DoorlockCmd{ID: idString(dl.ID), State: "unlock"}
+
+	// Setting the flag is not restricted
+	h.requestAs("alice", "", "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID, RequiresApproval: true}, http.StatusOK)
+	h.requestAs("alice", ALICE_TOKEN, "PATCH", "/v1/doorlock/cmd", unlock, http.StatusAccepted)
+
+	// Clearing it waits for a second operator, unlocking still does
+	h.requestAs("alice", "", "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID}, http.StatusUnauthorized)
+	ur := h.requestAs("alice", ALICE_TOKEN, "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID}, http.StatusAccepted)
+	if ur.Command != models.UNLOCK_CMD_APPROVAL_OFF || ur.Status != models.UNLOCK_STATUS_PENDING || ur.RequestedBy != "alice" {
+		t.Fatalf("got %+v, wanted pending approval off by alice", ur)
+	}
+	if !h.requiresApproval(dl.ID) {
+		t.Fatal("flag cleared before approval")
+	}
+	h.requestAs("alice", ALICE_TOKEN, "PATCH", "/v1/doorlock/cmd", unlock, http.StatusAccepted)
+	h.requestAs("alice", ALICE_TOKEN, "POST", "/v1/unlockRequest/"+idString(ur.ID)+"/approve", nil, http.StatusBadRequest)
+	h.Mqtt.AssertNotPublished(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+
+	approved := h.requestAs("bob", BOB_TOKEN, "POST", "/v1/unlockRequest/"+idString(ur.ID)+"/approve", nil, http.StatusOK)
+	if approved.Status != models.UNLOCK_STATUS_APPROVED || approved.ExecutedAt == nil {
+		t.Fatalf("got %+v, wanted executed approval", approved)
+	}
+	if h.requiresApproval(dl.ID) {
+		t.Fatal("flag not cleared after approval")
+	}
+	h.requestAs("alice", "", "PATCH", "/v1/doorlock/cmd", unlock, http.StatusOK)
+	h.Mqtt.AssertTopics(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+}
+
+func TestApproveUnlockRequestWithToken(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	dl := dlList[0]
+	unlock := models.DoorlockCmd{ID: idString(dl.ID), State: "unlock"}
+	h.requestAs("carol", "", "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID, RequiresApproval: true}, http.StatusOK)
+
+	// Unlocking needs an approver token, X-Actor alone requests nothing
+	h.requestAs("carol", "", "PATCH", "/v1/doorlock/cmd", unlock, http.StatusUnauthorized)
+	h.requestAs("carol", "wrong", "PATCH", "/v1/doorlock/cmd", unlock, http.StatusUnauthorized)
+
+	// Nor does it approve
+	ur := h.requestAs("carol", ALICE_TOKEN, "PATCH", "/v1/doorlock/cmd", unlock, http.StatusAccepted)
+	approve := "/v1/unlockRequest/" + idString(ur.ID) + "/approve"
+	h.requestAs("bob", "", "POST", approve, nil, http.StatusUnauthorized)
+	h.requestAs("bob", "wrong", "POST", approve, nil, http.StatusUnauthorized)
+
+	// Approver is the token holder whatever X-Actor says, and is the one audited
+	approved := h.requestAs("carol", BOB_TOKEN, "POST", approve, nil, http.StatusOK)
+	if approved.DecidedBy != "bob" || approved.ExecutedAt == nil {
+		t.Fatalf("got %+v, wanted executed approval of bob", approved)
+	}
+	alList, err := h.Svc.AuditLogSvc.FindAuditLogs(h.ctx(), &models.AuditLogFilter{Action: models.AUDIT_ACTION_APPROVE})
+	if err != nil || len(alList) != 1 || alList[0].Actor != "bob" {
+		t.Fatalf("got %+v, %v, wanted approval audited for bob", alList, err)
+	}
+	h.Mqtt.AssertTopics(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+}
+
+func TestUnlockRequestSpoofedRequester(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	dl := dlList[0]
+	unlock := models.DoorlockCmd{ID: idString(dl.ID), State: "unlock"}
+	h.requestAs("alice", "", "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID, RequiresApproval: true}, http.StatusOK)
+
+	// Alice can not request as bob without a token and approve it herself
+	h.requestAs("bob", "", "PATCH", "/v1/doorlock/cmd", unlock, http.StatusUnauthorized)
+	urList, _ := h.Svc.UnlockRequestSvc.FindAllUnlockRequest(h.ctx(), "")
+	if len(urList) != 0 {
+		t.Fatalf("got %d unlock requests, wanted none", len(urList))
+	}
+
+	// Her token makes her the requester whatever X-Actor says, so she can not approve it
+	ur := h.requestAs("bob", ALICE_TOKEN, "PATCH", "/v1/doorlock/cmd", unlock, http.StatusAccepted)
+	if ur.RequestedBy != "alice" {
+		t.Fatalf("got requester %s, wanted alice", ur.RequestedBy)
+	}
+	h.requestAs("bob", ALICE_TOKEN, "POST", "/v1/unlockRequest/"+idString(ur.ID)+"/approve", nil, http.StatusBadRequest)
+	h.Mqtt.AssertNotPublished(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+	alList, err := h.Svc.AuditLogSvc.FindAuditLogs(h.ctx(), &models.AuditLogFilter{EntityType: models.AUDIT_ENTITY_UNLOCK_REQUEST})
+	if err != nil || len(alList) != 1 || alList[0].Actor != "alice" {
+		t.Fatalf("got %+v, %v, wanted request audited for alice", alList, err)
+	}
+}
+
+func TestApproveUnlockRequestPublishFailed(t *testing.T) {
+	h := NewHarness(t)
+	_, dlList := h.Gateway("gw-1", "1")
+	dl := dlList[0]
+	h.requestAs("alice", "", "PATCH", "/v1/doorlock/approval", models.DoorlockApproval{ID: dl.ID, RequiresApproval: true}, http.StatusOK)
+	ur := h.requestAs("alice", ALICE_TOKEN, "PATCH", "/v1/doorlock/cmd", models.DoorlockCmd{ID: idString(dl.ID), State: "unlock"}, http.StatusAccepted)
+	approve := "/v1/unlockRequest/" + idString(ur.ID) + "/approve"
+
+	// Command not sent, the request stays pending and nothing of the approval is kept
+	h.Mqtt.FailPublish(errors.New("broker refused"))
+	h.requestAs("bob", BOB_TOKEN, "POST", approve, nil, http.StatusBadRequest)
+	pending, err := h.Svc.UnlockRequestSvc.FindUnlockRequestByID(h.ctx(), idString(ur.ID))
+	if err != nil || pending.Status != models.UNLOCK_STATUS_PENDING || pending.DecidedBy != "" {
+		t.Fatalf("got %+v, %v, wanted pending request", pending, err)
+	}
+	if alList, _ := h.Svc.AuditLogSvc.FindAuditLogs(h.ctx(), &models.AuditLogFilter{Action: models.AUDIT_ACTION_APPROVE}); len(alList) != 0 {
+		t.Fatalf("got %+v, wanted no approval audited", alList)
+	}
+
+	// Approved again once the broker takes it
+	h.Mqtt.FailPublish(nil)
+	approved := h.requestAs("bob", BOB_TOKEN, "POST", approve, nil, http.StatusOK)
+	if approved.Status != models.UNLOCK_STATUS_APPROVED || approved.ExecutedAt == nil {
+		t.Fatalf("got %+v, wanted executed approval", approved)
+	}
+	h.Mqtt.AssertTopics(t, mqttSvc.TOPIC_SV_DOORLOCK_CMD)
+}
@@ -9,6 +9,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// Update or delete query matching no record
+var ErrNoRecordAffected = errors.New("no record affected")
+
 // Error handler for ORM query
 func HandleQueryError(err error) error {
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -30,7 +33,7 @@ func ReturnBoolStateFromResult(result *gorm.DB) (bool, error) {
 	if ra > 0 {
 		return true, nil
 	} else {
-		logger.LogWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "No record affected", err.Error())
-		return false, fmt.Errorf("no record affected")
+		logger.LogWithoutFields(logger.SQLSERVER, logger.ErrorLevel, "No record affected")
+		return false, ErrNoRecordAffected
 	}
 }
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	SHEET_FORMAT_CSV  string = "csv"
+	SHEET_FORMAT_XLSX string = "xlsx"
+)
+
+// Read all rows of a CSV file or the first sheet of a XLSX file
+func ReadSheet(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case SHEET_FORMAT_CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		return cr.ReadAll()
+	case SHEET_FORMAT_XLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("xlsx file has no sheet")
+		}
+		return f.GetRows(sheets[0])
+	}
+	return nil, fmt.Errorf("unsupported sheet format %s", format)
+}
+
+// Write rows as CSV file or XLSX file with a single sheet
+func WriteSheet(w io.Writer, format string, rows [][]string) error {
+	switch format {
+	case SHEET_FORMAT_CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case SHEET_FORMAT_XLSX:
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		for i := range rows {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			if err := f.SetSheetRow(sheet, cell, &rows[i]); err != nil {
+				return err
+			}
+		}
+		return f.Write(w)
+	}
+	return fmt.Errorf("unsupported sheet format %s", format)
+}
+
+// Sheet format from file name extension, empty when not supported
+func SheetFormatFromFileName(fileName string) string {
+	lower := strings.ToLower(fileName)
+	if strings.HasSuffix(lower, "."+SHEET_FORMAT_CSV) {
+		return SHEET_FORMAT_CSV
+	}
+	if strings.HasSuffix(lower, "."+SHEET_FORMAT_XLSX) {
+		return SHEET_FORMAT_XLSX
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+//go:build unit
+// +build unit
+
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSheetRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"mssv", "name", "email"},
+		{"s1", "Nguyen, Van A", "s1@mail"},
+		{"s2", "B", "s2@mail"},
+	}
+	for _, format := range []string{SHEET_FORMAT_CSV, SHEET_FORMAT_XLSX} {
+		buf := &bytes.Buffer{}
+		if err := WriteSheet(buf, format, rows); err != nil {
+			t.Fatalf("write %s failed: %v", format, err)
+		}
+		readRows, err := ReadSheet(buf, format)
+		if err != nil {
+			t.Fatalf("read %s failed: %v", format, err)
+		}
+		if !reflect.DeepEqual(rows, readRows) {
+			t.Fatalf("%s round trip mismatch, got %v", format, readRows)
+		}
+	}
+
+	if _, err := ReadSheet(&bytes.Buffer{}, "xls"); err == nil {
+		t.Fatalf("expected error on unsupported format")
+	}
+}
+
+func TestSheetFormatFromFileName(t *testing.T) {
+	if SheetFormatFromFileName("students.XLSX") != SHEET_FORMAT_XLSX {
+		t.Fatalf("expected xlsx format")
+	}
+	if SheetFormatFromFileName("students.csv") != SHEET_FORMAT_CSV {
+		t.Fatalf("expected csv format")
+	}
+	if SheetFormatFromFileName("students.xls") != "" {
+		t.Fatalf("expected unsupported format")
+	}
+}